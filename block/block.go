@@ -58,6 +58,7 @@ import (
 	procurementdomain "github.com/erniealice/centymo-golang/domain/procurement"
 	productdom "github.com/erniealice/centymo-golang/domain/product"
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	subscriptiondom "github.com/erniealice/centymo-golang/domain/subscription"
 	treasurydomain "github.com/erniealice/centymo-golang/domain/treasury"
 )
@@ -447,6 +448,7 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 			invDeps.CreateInventoryItem = useCases.Inventory.CreateInventoryItem
 			invDeps.ReadInventoryItem = useCases.Inventory.ReadInventoryItem
 			invDeps.UpdateInventoryItem = useCases.Inventory.UpdateInventoryItem
+			invDeps.AdjustInventoryQuantity = useCases.Inventory.adjustInventoryQuantity()
 			invDeps.DeleteInventoryItem = useCases.Inventory.DeleteInventoryItem
 			invDeps.ListInventorySerials = useCases.Inventory.ListInventorySerials
 			invDeps.CreateInventorySerial = useCases.Inventory.CreateInventorySerial
@@ -490,29 +492,7 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 				// duck's ListSimple("payment_term"); the entity_scope filter stays
 				// in the block. Nil-safe: empty options when ListPaymentTerms is
 				// unwired (service-admin binds it in W7).
-				ListPaymentTerms: func(fctx context.Context) ([]*revenuedomain.PaymentTermOption, error) {
-					list := useCases.Entity.PaymentTerm.ListPaymentTerms
-					if list == nil {
-						return nil, nil
-					}
-					resp, err := list(fctx, &paymenttermpb.ListPaymentTermsRequest{})
-					if err != nil {
-						return nil, err
-					}
-					terms := resp.GetData()
-					opts := make([]*revenuedomain.PaymentTermOption, 0, len(terms))
-					for _, t := range terms {
-						id := t.GetId()
-						if id == "" {
-							continue
-						}
-						if scope := t.GetEntityScope(); scope != "client" && scope != "both" {
-							continue
-						}
-						opts = append(opts, &revenuedomain.PaymentTermOption{Id: id, Name: t.GetName(), NetDays: t.GetNetDays()})
-					}
-					return opts, nil
-				},
+				ListPaymentTerms: listClientPaymentTerms(useCases),
 				// Document generation + template CRUD
 				GenerateDoc:            generateDoc,
 				ListDocumentTemplates:  listDocTemplates,
@@ -520,6 +500,7 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 				UpdateDocumentTemplate: updateDocTemplate,
 				DeleteDocumentTemplate: deleteDocTemplate,
 				UploadTemplate:         uploadTemplate,
+				DownloadTemplate:       downloadFile,
 				SendEmail:              sendEmail,
 				// Attachments
 				UploadFile:       uploadFile,
@@ -553,6 +534,7 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 			// Inventory (for stock deduction on status change)
 			revDeps.ReadInventoryItem = useCases.Inventory.ReadInventoryItem
 			revDeps.UpdateInventoryItem = useCases.Inventory.UpdateInventoryItem
+			revDeps.AdjustInventoryQuantity = useCases.Inventory.adjustInventoryQuantity()
 			revDeps.ListInventoryItems = useCases.Inventory.ListInventoryItems
 			revDeps.UpdateInventorySerial = useCases.Inventory.UpdateInventorySerial
 			revDeps.CreateInventorySerialHistory = useCases.Inventory.CreateInventorySerialHistory
@@ -577,6 +559,34 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 			revDeps.ReadCollectionMethod = useCases.CollectionMethod.ReadCollectionMethod
 			revDeps.ListCollectionMethods = useCases.CollectionMethod.ListCollectionMethods
 			revDeps.ListLocations = useCases.Entity.Location.ListLocations
+			// Storefront order fulfillment — optional; the queue, advance
+			// action and status endpoint are not mounted when unwired.
+			revDeps.ListFulfillmentQueue = useCases.Revenue.Fulfillment.ListFulfillmentQueue
+			revDeps.GetOrderFulfillment = useCases.Revenue.Fulfillment.GetOrderFulfillment
+			revDeps.AdvanceFulfillment = advanceFulfillmentAsUser(useCases)
+			// Credit/debit notes — optional; not mounted when unwired.
+			revDeps.RevenueNotes = useCases.Revenue.Notes.Store
+			revDeps.CreateRevenueTaxLine = useCases.Revenue.Notes.CreateRevenueTaxLine
+			revDeps.DeleteRevenueTaxLine = useCases.Revenue.Notes.DeleteRevenueTaxLine
+			revDeps.UpdateRevenueIfUnchanged = useCases.Revenue.Notes.UpdateRevenueIfUnchanged
+			revDeps.ConfigureStatusMachine = useCases.Revenue.ConfigureStatusMachine
+			revDeps.InvoicePDFEngine = useCases.Revenue.InvoicePDF.Engine
+			revDeps.LoadInvoicePDFLayout = useCases.Revenue.InvoicePDF.LoadLayout
+			revDeps.LoadEInvoiceSeller = loadEInvoiceSeller(useCases)
+			revDeps.DocumentNumbering = shared.NewDocumentNumbering(useCases.Revenue.DocumentSequences)
+			revDeps.Dunning = useCases.Revenue.Dunning
+			revDeps.Emails = useCases.Revenue.Emails
+			revDeps.SendEmailMessage = useCases.Revenue.SendEmailMessage
+			revDeps.ExtractUserID = useCases.ExtractUserID
+			revDeps.ExtractWorkspaceID = useCases.ExtractWorkspaceID
+			revDeps.FXRates = useCases.FX.Rates
+			revDeps.LookupFXRate = useCases.FX.LookupRate
+			revDeps.ListCollections = useCases.Collection.ListCollections
+			revDeps.PaymentAllocations = useCases.Collection.PaymentAllocations
+			wireRevenueDashboard(revDeps, useCases)
+			revDeps.GetFunctionalCurrency = func(fctx context.Context) string {
+				return getFunctionalCurrency(fctx, useCases)
+			}
 
 			revenueMod := revenuedomain.NewRevenueModule(revDeps)
 			revenueMod.RegisterRoutes(ctx.Routes)
@@ -591,6 +601,16 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 			handleFunc(ctx.Routes, "GET", revenueRoutes.PriceLookupURL, revenueMod.PriceLookup)
 			// Tax recompute — 501 stub until Phase 4 wires ComputeTaxesForRevenue
 			handleFunc(ctx.Routes, "POST", revenueRoutes.RecomputeTaxesURL, revenueMod.RecomputeTaxes)
+			// Fulfillment status is JSON for storefront polling
+			handleFunc(ctx.Routes, "GET", revenueRoutes.FulfillmentStatusURL, revenueMod.FulfillmentStatus)
+			// Bulk export download streams the finished ZIP/PDF file
+			handleFunc(ctx.Routes, "GET", revenueRoutes.ExportDownloadURL, revenueMod.ExportDownload)
+			// AR aging export streams CSV/XLSX
+			handleFunc(ctx.Routes, "GET", revenueRoutes.AgingExportURL, revenueMod.AgingExport)
+			// Customer statement download streams PDF/DOCX
+			handleFunc(ctx.Routes, "GET", revenueRoutes.StatementDownloadURL, revenueMod.StatementDownload)
+			// Invoice template preview streams the generated PDF/DOCX
+			handleFunc(ctx.Routes, "GET", revenueRoutes.SettingsTemplatePreviewURL, revenueMod.SettingsPreview)
 		}
 
 		// See product.go for wireProductModules (Product 3-mount + ProductLine 2-mount).
@@ -701,6 +721,9 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 			collDeps.GetFunctionalCurrency = func(fctx context.Context) string {
 				return getFunctionalCurrency(fctx, useCases)
 			}
+			collDeps.SnapshotFXRate = snapshotFXRate(useCases, shared.FXDocumentCollection)
+			collDeps.DocumentNumbering = shared.NewDocumentNumbering(useCases.Revenue.DocumentSequences)
+			wirePaymentAllocation(collDeps, useCases)
 			treasurydomain.NewCollectionModule(collDeps).RegisterRoutes(ctx.Routes)
 		}
 
@@ -735,6 +758,8 @@ func Block(opts ...BlockOption) consumerapp.AppOption {
 				SettleUnscheduledAdvance: bridgeSettleAdvance(useCases.Disbursement.SettleUnscheduledAdvance),
 				RefundUnscheduledAdvance: bridgeRefundAdvance(useCases.Disbursement.RefundUnscheduledAdvance),
 				CancelAdvance:            bridgeCancelAdvance(useCases.Disbursement.CancelAdvance),
+				// Rate snapshot for foreign-currency payments (nil when FX is unwired).
+				SnapshotFXRate: snapshotFXRate(useCases, shared.FXDocumentDisbursement),
 			}).RegisterRoutes(ctx.Routes)
 		}
 
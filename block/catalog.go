@@ -45,6 +45,7 @@ import (
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue"
 	revenuepkg "github.com/erniealice/centymo-golang/domain/revenue/revenue"
 	revenuerunpkg "github.com/erniealice/centymo-golang/domain/revenue/revenue_run"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	subscriptiondom "github.com/erniealice/centymo-golang/domain/subscription"
 	planpkg "github.com/erniealice/centymo-golang/domain/subscription/plan"
 	priceplanpkg "github.com/erniealice/centymo-golang/domain/subscription/price_plan"
@@ -105,6 +106,7 @@ func InventoryUnit(uc *UseCases, infra *Infra) compose.Unit {
 		deps.CreateInventoryItem = uc.Inventory.CreateInventoryItem
 		deps.ReadInventoryItem = uc.Inventory.ReadInventoryItem
 		deps.UpdateInventoryItem = uc.Inventory.UpdateInventoryItem
+		deps.AdjustInventoryQuantity = uc.Inventory.adjustInventoryQuantity()
 		deps.DeleteInventoryItem = uc.Inventory.DeleteInventoryItem
 		deps.ListInventorySerials = uc.Inventory.ListInventorySerials
 		deps.CreateInventorySerial = uc.Inventory.CreateInventorySerial
@@ -156,6 +158,7 @@ func RevenueUnit(uc *UseCases, infra *Infra) compose.Unit {
 			UpdateDocumentTemplate: infra.UpdateDocTemplate,
 			DeleteDocumentTemplate: infra.DeleteDocTemplate,
 			UploadTemplate:         infra.UploadTemplate,
+			DownloadTemplate:       infra.DownloadFile,
 			SendEmail:              infra.SendEmail,
 			UploadFile:             infra.UploadFile,
 			ListAttachments:        infra.ListAttachments,
@@ -174,6 +177,11 @@ func RevenueUnit(uc *UseCases, infra *Infra) compose.Unit {
 		compose.HandleFunc(mc.Routes, "GET", r.SearchProductURL, revenueMod.SearchProducts)
 		compose.HandleFunc(mc.Routes, "GET", r.PriceLookupURL, revenueMod.PriceLookup)
 		compose.HandleFunc(mc.Routes, "POST", r.RecomputeTaxesURL, revenueMod.RecomputeTaxes)
+		compose.HandleFunc(mc.Routes, "GET", r.FulfillmentStatusURL, revenueMod.FulfillmentStatus)
+		compose.HandleFunc(mc.Routes, "GET", r.ExportDownloadURL, revenueMod.ExportDownload)
+		compose.HandleFunc(mc.Routes, "GET", r.AgingExportURL, revenueMod.AgingExport)
+		compose.HandleFunc(mc.Routes, "GET", r.StatementDownloadURL, revenueMod.StatementDownload)
+		compose.HandleFunc(mc.Routes, "GET", r.SettingsTemplatePreviewURL, revenueMod.SettingsPreview)
 		return nil
 	}
 	return u
@@ -202,6 +210,7 @@ func wireRevenueDeps(deps *revenuedomain.RevenueModuleDeps, uc *UseCases) {
 	deps.ListRevenueLineItems = uc.Revenue.ListRevenueLineItems
 	deps.ReadInventoryItem = uc.Inventory.ReadInventoryItem
 	deps.UpdateInventoryItem = uc.Inventory.UpdateInventoryItem
+	deps.AdjustInventoryQuantity = uc.Inventory.adjustInventoryQuantity()
 	deps.ListInventoryItems = uc.Inventory.ListInventoryItems
 	deps.UpdateInventorySerial = uc.Inventory.UpdateInventorySerial
 	deps.CreateInventorySerialHistory = uc.Inventory.CreateInventorySerialHistory
@@ -218,6 +227,46 @@ func wireRevenueDeps(deps *revenuedomain.RevenueModuleDeps, uc *UseCases) {
 	deps.ReadCollectionMethod = uc.CollectionMethod.ReadCollectionMethod
 	deps.ListCollectionMethods = uc.CollectionMethod.ListCollectionMethods
 	deps.ListLocations = uc.Entity.Location.ListLocations
+	deps.ListFulfillmentQueue = uc.Revenue.Fulfillment.ListFulfillmentQueue
+	deps.GetOrderFulfillment = uc.Revenue.Fulfillment.GetOrderFulfillment
+	deps.AdvanceFulfillment = advanceFulfillmentAsUser(uc)
+	deps.RevenueNotes = uc.Revenue.Notes.Store
+	deps.CreateRevenueTaxLine = uc.Revenue.Notes.CreateRevenueTaxLine
+	deps.DeleteRevenueTaxLine = uc.Revenue.Notes.DeleteRevenueTaxLine
+	deps.UpdateRevenueIfUnchanged = uc.Revenue.Notes.UpdateRevenueIfUnchanged
+	deps.ConfigureStatusMachine = uc.Revenue.ConfigureStatusMachine
+	deps.InvoicePDFEngine = uc.Revenue.InvoicePDF.Engine
+	deps.LoadInvoicePDFLayout = uc.Revenue.InvoicePDF.LoadLayout
+	deps.LoadEInvoiceSeller = loadEInvoiceSeller(uc)
+	deps.DocumentNumbering = shared.NewDocumentNumbering(uc.Revenue.DocumentSequences)
+	deps.Dunning = uc.Revenue.Dunning
+	deps.Emails = uc.Revenue.Emails
+	deps.SendEmailMessage = uc.Revenue.SendEmailMessage
+	deps.ExtractUserID = uc.ExtractUserID
+	deps.ExtractWorkspaceID = uc.ExtractWorkspaceID
+	deps.FXRates = uc.FX.Rates
+	deps.LookupFXRate = uc.FX.LookupRate
+	deps.ListCollections = uc.Collection.ListCollections
+	deps.PaymentAllocations = uc.Collection.PaymentAllocations
+	wireRevenueDashboard(deps, uc)
+	deps.GetFunctionalCurrency = func(fctx context.Context) string {
+		return getFunctionalCurrency(fctx, uc)
+	}
+}
+
+// advanceFulfillmentAsUser wraps UseCases.Revenue.Fulfillment.AdvanceFulfillment
+// so the acting user is sourced from the request context via
+// UseCases.ExtractUserID, keeping the fulfillment views free of ctx imports.
+// Returns nil when either closure is unwired.
+func advanceFulfillmentAsUser(uc *UseCases) shared.AdvanceFulfillment {
+	advanceUC := uc.Revenue.Fulfillment.AdvanceFulfillment
+	if advanceUC == nil || uc.ExtractUserID == nil {
+		return nil
+	}
+	return func(fctx context.Context, t shared.FulfillmentTransition) (*shared.OrderFulfillment, error) {
+		t.UserID = uc.ExtractUserID(fctx)
+		return advanceUC(fctx, t)
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -637,6 +686,9 @@ func CollectionUnit(uc *UseCases, infra *Infra) compose.Unit {
 		collDeps.GetFunctionalCurrency = func(fctx context.Context) string {
 			return getFunctionalCurrency(fctx, uc)
 		}
+		collDeps.SnapshotFXRate = snapshotFXRate(uc, shared.FXDocumentCollection)
+		collDeps.DocumentNumbering = shared.NewDocumentNumbering(uc.Revenue.DocumentSequences)
+		wirePaymentAllocation(collDeps, uc)
 		treasurydomain.NewCollectionModule(collDeps).RegisterRoutes(mc.Routes)
 		return nil
 	}
@@ -676,6 +728,8 @@ func DisbursementUnit(uc *UseCases, infra *Infra) compose.Unit {
 			SettleUnscheduledAdvance: bridgeSettleAdvance(uc.Disbursement.SettleUnscheduledAdvance),
 			RefundUnscheduledAdvance: bridgeRefundAdvance(uc.Disbursement.RefundUnscheduledAdvance),
 			CancelAdvance:            bridgeCancelAdvance(uc.Disbursement.CancelAdvance),
+			// Rate snapshot for foreign-currency payments (nil when FX is unwired).
+			SnapshotFXRate: snapshotFXRate(uc, shared.FXDocumentDisbursement),
 		}).RegisterRoutes(mc.Routes)
 		return nil
 	}
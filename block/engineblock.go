@@ -7,16 +7,21 @@ import (
 	expenseboard "github.com/erniealice/centymo-golang/domain/expenditure/expenditure/expense_dashboard"
 	purchaseboard "github.com/erniealice/centymo-golang/domain/expenditure/expenditure/purchase_dashboard"
 	productdashboardview "github.com/erniealice/centymo-golang/domain/product/product/dashboard"
+	revenuedashboard "github.com/erniealice/centymo-golang/domain/revenue/revenue/dashboard"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	cashdashboardview "github.com/erniealice/centymo-golang/domain/treasury/collection/dashboard"
 
 	"github.com/erniealice/espyna-golang/consumer"
 	consumerapp "github.com/erniealice/espyna-golang/consumer/app"
 	"github.com/erniealice/espyna-golang/reference"
+	commonpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
 	advancekindpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common/advance_kind"
 	attachmentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/attachment"
 	paymenttermpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/payment_term"
 	expenserecognitionrunpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/expenditure/expense_recognition_run"
 	productvariantoptionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/product_variant_option"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
 	revenuerunpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_run"
 	treasurycollectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
 	treasurydisbursementpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/disbursement"
@@ -25,6 +30,10 @@ import (
 	treasurydashpb "github.com/erniealice/esqyma/pkg/schema/v1/service/dashboard/treasury"
 )
 
+// revenueDashboardPageSize is how many revenues the dashboard closure reads per
+// list page.
+const revenueDashboardPageSize = 100
+
 // centymoEngineBlock returns a consumerapp.AppOption that registers all centymo
 // domain modules via the compose engine (replaces legacy centymoBlock).
 func EngineBlock() consumerapp.AppOption {
@@ -90,7 +99,8 @@ type centymoDBOps interface {
 // dropdown empty). 20260612-datasource-typed-path W7.
 func buildCentymoUseCases(uc *consumer.UseCases, db any) *UseCases {
 	result := &UseCases{
-		ExtractUserID: consumer.ExtractUserIDFromContext,
+		ExtractUserID:      consumer.ExtractUserIDFromContext,
+		ExtractWorkspaceID: consumer.GetWorkspaceIDFromContext,
 	}
 
 	// Assert ctx.DB to the capability-narrow ops surface once. ok==false (mock
@@ -462,6 +472,71 @@ func buildCentymoUseCases(uc *consumer.UseCases, db any) *UseCases {
 			result.Revenue.RevenuePayment.ListRevenuePayments = uc.Revenue.RevenuePayment.ListRevenuePayments.Execute
 		}
 
+		// -- Revenue dashboard ----------------------------------------------------
+		// espyna has no revenue dashboard aggregate yet, so the closure reads
+		// the workspace's revenues and payments through the list use cases
+		// bound above, with the note links of Revenue.Notes.Store, and
+		// aggregates them with revenuedashboard.SummarizeFX.
+		// When result.FX.Rates is wired, foreign-currency documents are
+		// translated into the workspace functional currency.
+		if result.Revenue.GetListPageData != nil {
+			listRevenues := result.Revenue.GetListPageData
+			listPayments := result.Revenue.RevenuePayment.ListRevenuePayments
+			result.Revenue.GetRevenueDashboard = func(ctx context.Context, req *revenuedashboard.Request) (*revenuedashboard.Response, error) {
+				if req == nil {
+					req = &revenuedashboard.Request{}
+				}
+				var revenues []*revenuepb.Revenue
+				for page := int32(1); ; page++ {
+					resp, err := listRevenues(ctx, &revenuepb.GetRevenueListPageDataRequest{
+						Pagination: &commonpb.PaginationRequest{
+							Limit:  revenueDashboardPageSize,
+							Method: &commonpb.PaginationRequest_Offset{Offset: &commonpb.OffsetPagination{Page: page}},
+						},
+					})
+					if err != nil {
+						return nil, err
+					}
+					revenues = append(revenues, resp.GetRevenueList()...)
+					if len(resp.GetRevenueList()) < revenueDashboardPageSize {
+						break
+					}
+				}
+				var payments []*revenuepaymentpb.RevenuePayment
+				if listPayments != nil {
+					resp, err := listPayments(ctx, &revenuepaymentpb.ListRevenuePaymentsRequest{})
+					if err != nil {
+						return nil, err
+					}
+					payments = resp.GetData()
+				}
+				var notes []*shared.RevenueNote
+				if store := result.Revenue.Notes.Store; store != nil {
+					for _, rv := range revenues {
+						if shared.RevenueNoteKindOf(rv.GetReferenceNumber()) == "" {
+							continue
+						}
+						note, err := store.ReadRevenueNote(ctx, rv.GetId())
+						if err != nil {
+							return nil, err
+						}
+						if note != nil {
+							notes = append(notes, note)
+						}
+					}
+				}
+				var fx *shared.FXTranslation
+				if load := loadFXTranslation(result); load != nil {
+					t, err := load(ctx)
+					if err != nil {
+						return nil, err
+					}
+					fx = t
+				}
+				return revenuedashboard.SummarizeFX(req, revenues, payments, notes, fx), nil
+			}
+		}
+
 		// -- RevenueRun: repo-direct pass-through via GenerateRevenueRun.RevenueRunRepo() --
 		// When the revenue_run postgres adapter isn't registered (e.g. mock_db, or
 		// when the factory is missing), repo is nil. Install nil-safe stubs that
@@ -52,6 +52,7 @@ func wireRevenueRunModule(ctx *consumerapp.AppContext, cfg *blockConfig, useCase
 		CommonLabels: ctx.Common,
 		TableLabels:  w.centymoTableLabels,
 	}
+	rrDeps.LoadFXTranslation = loadFXTranslation(useCases)
 
 	// Wire ListRevenueRuns — translate proto response to view-typed rows.
 	rrDeps.ListRevenueRuns = func(fctx context.Context, scope revenuedomain.ListRevenueRunsScope) ([]revenuedomain.RevenueRunRow, string, error) {
@@ -18,6 +18,10 @@ import (
 	"os"
 	"testing"
 
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+	"github.com/erniealice/centymo-golang/services/ubl"
+
 	commonv1pb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
 	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
 	locationpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/location"
@@ -88,6 +92,7 @@ import (
 	expenseboard "github.com/erniealice/centymo-golang/domain/expenditure/expenditure/expense_dashboard"
 	purchaseboard "github.com/erniealice/centymo-golang/domain/expenditure/expenditure/purchase_dashboard"
 	productdashboard "github.com/erniealice/centymo-golang/domain/product/product/dashboard"
+	revenuedashboard "github.com/erniealice/centymo-golang/domain/revenue/revenue/dashboard"
 	treasurydomain "github.com/erniealice/centymo-golang/domain/treasury"
 	collectiondashboard "github.com/erniealice/centymo-golang/domain/treasury/collection/dashboard"
 )
@@ -110,6 +115,11 @@ type UseCases struct {
 	// (e.g. ApprovedBy, ActivatedBy) without importing the espyna consumer package.
 	ExtractUserID func(context.Context) string
 
+	// ExtractWorkspaceID extracts the active workspace ID from a request
+	// context. Used to scope in-memory state, such as bulk invoice exports,
+	// to the workspace that created it.
+	ExtractWorkspaceID func(context.Context) string
+
 	// SetActive sets ONLY the `active` boolean column on the named collection. It
 	// is a deliberate, auditable, capability-narrow replacement for the deleted
 	// DataSource duck's generic Update — needed because proto3 omits false bools,
@@ -128,6 +138,7 @@ type UseCases struct {
 	Disbursement     DisbursementUseCases
 	Entity           EntityUseCases
 	Expenditure      ExpenditureUseCases
+	FX               FXUseCases
 	Inventory        InventoryUseCases
 	Operation        OperationUseCases
 	Plan             PlanUseCases
@@ -182,6 +193,19 @@ type ExpenseRecognitionRunUseCases struct {
 	GenerateExpenseRun                func(context.Context, *expenserecognitionrunpb.GenerateExpenseRunRequest) (*expenserecognitionrunpb.GenerateExpenseRunResponse, error)
 }
 
+// -- FX ----------------------------------------------------------------------
+
+// FXUseCases groups the daily exchange-rate table. Optional: when Rates is
+// nil the FX Rates settings page is not mounted, documents are not
+// snapshotted and dashboards sum amounts in their document currency.
+type FXUseCases struct {
+	Rates shared.FXRateStore
+	// LookupRate resolves the rate for a pair on a date. Nil defaults to
+	// shared.NewFXRateLookup(Rates) — the latest rate on or before the date,
+	// or the inverse of the reverse pair.
+	LookupRate shared.FXRateLookup
+}
+
 // -- Common ------------------------------------------------------------------
 
 type CommonUseCases struct {
@@ -250,6 +274,28 @@ type InventoryUseCases struct {
 	ReadInventoryDepreciation         func(context.Context, *inventorydepreciationpb.ReadInventoryDepreciationRequest) (*inventorydepreciationpb.ReadInventoryDepreciationResponse, error)
 	UpdateInventoryDepreciation       func(context.Context, *inventorydepreciationpb.UpdateInventoryDepreciationRequest) (*inventorydepreciationpb.UpdateInventoryDepreciationResponse, error)
 	CreateInventorySerialHistory      func(context.Context, *inventoryserialhistorypb.CreateInventorySerialHistoryRequest) (*inventoryserialhistorypb.CreateInventorySerialHistoryResponse, error)
+	// AdjustInventoryQuantity applies a signed quantity delta in one atomic
+	// backend write (UPDATE ... SET quantity = quantity + delta). OPTIONAL:
+	// when nil, UpdateInventoryItemIfUnchanged is used through
+	// shared.OptimisticInventoryAdjuster; when both are nil every
+	// stock-mutating view falls back to shared.SerializedInventoryAdjuster
+	// over Read/UpdateInventoryItem, which is only safe within a single
+	// process and logs a warning.
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
+	// UpdateInventoryItemIfUnchanged writes an item only while its
+	// date_modified is unchanged (UPDATE ... WHERE date_modified = ?).
+	// OPTIONAL; see AdjustInventoryQuantity.
+	UpdateInventoryItemIfUnchanged shared.UpdateInventoryItemIfUnchanged
+}
+
+// adjustInventoryQuantity returns the adjuster the modules are wired with:
+// the atomic AdjustInventoryQuantity, else the retrying conditional update,
+// else nil for the modules' process-local fallback.
+func (u *InventoryUseCases) adjustInventoryQuantity() shared.AdjustInventoryQuantity {
+	if u.AdjustInventoryQuantity != nil {
+		return u.AdjustInventoryQuantity
+	}
+	return shared.OptimisticInventoryAdjuster(u.ReadInventoryItem, u.UpdateInventoryItemIfUnchanged)
 }
 
 // -- Revenue -----------------------------------------------------------------
@@ -276,6 +322,81 @@ type RevenueUseCases struct {
 	// Nil-safe — the payment drawer + detail tab degrade to an empty state when
 	// unwired (mock builds, half-wired composition root).
 	RevenuePayment RevenuePaymentUseCases
+	// Storefront order fulfillment queue + status endpoint. Optional — the
+	// fulfillment views are not mounted when unwired.
+	Fulfillment RevenueFulfillmentUseCases
+	// Credit/debit notes against completed invoices. Optional — the note
+	// drawer and list actions are not mounted when unwired.
+	Notes RevenueNoteUseCases
+	// ConfigureStatusMachine registers workspace revenue statuses,
+	// transitions, guards and hooks on top of the defaults. Optional. Pass
+	// the same machine's rules to checkout so webhooks follow them too.
+	ConfigureStatusMachine func(*shared.RevenueStatusMachine)
+	// Per-workspace invoice PDF settings. Optional — LibreOffice with the
+	// native renderer as fallback and the default layouts when unwired.
+	InvoicePDF RevenueInvoicePDFUseCases
+	// DocumentSequences stores the workspace's numbering sequences and the
+	// register of issued numbers. Optional — when set, blank invoice
+	// references and notes are numbered from it and the numbering settings
+	// pages are mounted. Share the same store with checkout (CheckoutDeps.
+	// Numbering) so storefront orders draw from the order sequence.
+	DocumentSequences shared.DocumentSequenceStore
+	// Dunning stores the payment reminder policy, client opt-outs and the
+	// reminder log. Optional — the reminder pages are mounted when set and
+	// SendEmail is available; schedule RevenueModule.RunDunning daily.
+	Dunning shared.DunningStore
+	// Emails stores the per-workspace invoice email templates and the send
+	// log. Optional — the compose drawer and the detail emails tab are
+	// mounted when set and a sender is available; schedule
+	// RevenueModule.RunScheduledEmails to deliver scheduled sends.
+	// SendEmailMessage sends with CC/BCC and returns the provider message ID;
+	// the infra SendEmail is used when nil.
+	Emails           shared.EmailStore
+	SendEmailMessage func(ctx context.Context, msg *shared.EmailMessage) (string, error)
+	// Dashboard — centymo view-layer types. Nil-safe: the revenue dashboard
+	// renders zero values when unset. The engine block backs it with
+	// revenuedashboard.Summarize over the revenue and payment lists.
+	GetRevenueDashboard func(context.Context, *revenuedashboard.Request) (*revenuedashboard.Response, error)
+	// EInvoiceSeller returns the workspace's seller party for UBL / PEPPOL BIS
+	// e-invoices — at least its PEPPOL participant ID (EndpointID and
+	// EndpointScheme). Blank name, TIN and country are filled from the
+	// workspace. Optional — the e-invoice download and attachment are offered
+	// only when set.
+	EInvoiceSeller func(ctx context.Context) (*ubl.Party, error)
+}
+
+// RevenueInvoicePDFUseCases selects how invoice and note PDFs are produced for
+// the request's workspace. Engine returns one of the revenue action
+// PDFEngine* values; LoadLayout returns the native layout for a template
+// purpose ("invoice", "credit_note", "debit_note"), nil for the default.
+type RevenueInvoicePDFUseCases struct {
+	Engine     func(ctx context.Context) string
+	LoadLayout func(ctx context.Context, purpose string) (*invoicepdf.Layout, error)
+}
+
+// RevenueNoteUseCases groups the credit/debit note dependencies. Store keeps
+// the note → invoice links; it and RevenueUseCases.DocumentSequences, whose
+// credit_note / debit_note sequences number the notes, are required to enable
+// notes. CreateRevenueTaxLine writes the notes' prorated tax rows (same
+// closure as checkout's) and DeleteRevenueTaxLine removes them when a note
+// fails half-way. UpdateRevenueIfUnchanged is a conditional update on
+// date_modified (like UpdateInventoryItemIfUnchanged) that keeps notes against
+// one invoice from over-crediting it across processes.
+type RevenueNoteUseCases struct {
+	Store                    shared.RevenueNoteStore
+	CreateRevenueTaxLine     func(context.Context, *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error)
+	DeleteRevenueTaxLine     func(context.Context, string) error
+	UpdateRevenueIfUnchanged shared.UpdateRevenueIfUnchanged
+}
+
+// RevenueFulfillmentUseCases groups the storefront order fulfillment closures,
+// normally the consumer app's checkout.Service methods of the same name.
+// AdvanceFulfillment is wrapped by the block so the acting user comes from
+// UseCases.ExtractUserID.
+type RevenueFulfillmentUseCases struct {
+	ListFulfillmentQueue shared.ListFulfillmentQueue
+	GetOrderFulfillment  shared.GetOrderFulfillment
+	AdvanceFulfillment   shared.AdvanceFulfillment
 }
 
 // RevenuePaymentUseCases groups the typed revenue_payment CRUD closures the
@@ -469,6 +590,12 @@ type CollectionUseCases struct {
 	SettleUnscheduledAdvance func(ctx context.Context, in AdvanceSettleInput) (*AdvanceSettleOutput, error)
 	RefundUnscheduledAdvance func(ctx context.Context, in AdvanceRefundInput) (*AdvanceRefundOutput, error)
 	CancelAdvance            func(ctx context.Context, in AdvanceCancelInput) (*AdvanceCancelOutput, error)
+
+	// PaymentAllocations stores how client collections are applied to
+	// invoices. Optional — when set with Revenue.RevenuePayment create and
+	// delete, collection detail pages get the Allocations tab and statements
+	// list only the unapplied part of receipts on account.
+	PaymentAllocations shared.PaymentAllocationStore
 }
 
 // -- CollectionMethod (treasury) ---------------------------------------------
@@ -869,6 +996,10 @@ func (u *UseCases) RequireFor(cfg *blockConfig) error {
 		check(u.Revenue.ReadRevenue != nil, "UseCases.Revenue.ReadRevenue")
 		check(u.Revenue.UpdateRevenue != nil, "UseCases.Revenue.UpdateRevenue")
 		check(u.Revenue.DeleteRevenue != nil, "UseCases.Revenue.DeleteRevenue")
+		// The fulfillment queue records who moved each order.
+		if u.Revenue.Fulfillment.AdvanceFulfillment != nil {
+			check(u.ExtractUserID != nil, "UseCases.ExtractUserID")
+		}
 	}
 
 	if cfg.wantPricePlan() {
@@ -12,6 +12,7 @@ package block
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	expendituredomain "github.com/erniealice/centymo-golang/domain/expenditure"
@@ -19,10 +20,16 @@ import (
 	purchaseboard "github.com/erniealice/centymo-golang/domain/expenditure/expenditure/purchase_dashboard"
 	productdom "github.com/erniealice/centymo-golang/domain/product"
 	productdashboard "github.com/erniealice/centymo-golang/domain/product/product/dashboard"
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue"
+	revenueaging "github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
+	revenuedashboard "github.com/erniealice/centymo-golang/domain/revenue/revenue/dashboard"
 	shared "github.com/erniealice/centymo-golang/domain/shared"
 	treasurydomain "github.com/erniealice/centymo-golang/domain/treasury"
 	collectiondashboard "github.com/erniealice/centymo-golang/domain/treasury/collection/dashboard"
+	"github.com/erniealice/centymo-golang/services/ubl"
 	locationpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/location"
+	paymenttermpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/payment_term"
+	workspacepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/workspace"
 )
 
 // ---------------------------------------------------------------------------
@@ -84,6 +91,25 @@ func wireCashDashboard(deps *treasurydomain.CollectionModuleDeps, useCases *UseC
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Revenue (sales) dashboard wiring
+// ---------------------------------------------------------------------------
+
+// wireRevenueDashboard sets revDeps.GetRevenueDashboardPageData from
+// useCases.Revenue.GetRevenueDashboard if non-nil.
+func wireRevenueDashboard(deps *revenuedomain.RevenueModuleDeps, useCases *UseCases) {
+	if useCases == nil || useCases.Revenue.GetRevenueDashboard == nil {
+		return
+	}
+	cb := useCases.Revenue.GetRevenueDashboard
+	deps.GetRevenueDashboardPageData = func(ctx context.Context, req *revenuedashboard.Request) (*revenuedashboard.Response, error) {
+		if req == nil {
+			req = &revenuedashboard.Request{Now: time.Now()}
+		}
+		return cb(ctx, req)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Service (product kind=service) dashboard wiring
 // ---------------------------------------------------------------------------
@@ -140,3 +166,132 @@ func wireExpenseDashboard(deps *expendituredomain.ExpenditureModuleDeps, useCase
 		return cb(ctx, req)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// FX rate wiring (document snapshots + functional-currency translation)
+// ---------------------------------------------------------------------------
+
+// snapshotFXRate returns the treasury SnapshotFXRate callback for document
+// kind (shared.FXDocumentCollection / FXDocumentDisbursement): it records the
+// rate into the workspace functional currency on the document's date. Nil
+// when useCases.FX.Rates is unwired, so the action skips the snapshot.
+func snapshotFXRate(useCases *UseCases, kind string) func(context.Context, string, string, time.Time) error {
+	if useCases == nil || useCases.FX.Rates == nil {
+		return nil
+	}
+	rates := useCases.FX.Rates
+	lookup := useCases.FX.LookupRate
+	if lookup == nil {
+		lookup = shared.NewFXRateLookup(rates)
+	}
+	return func(ctx context.Context, id, currency string, on time.Time) error {
+		_, err := shared.SnapshotFXRate(ctx, rates, lookup, kind, id, currency, getFunctionalCurrency(ctx, useCases), on)
+		return err
+	}
+}
+
+// loadFXTranslation returns a loader for the workspace's functional-currency
+// translation (rates + document snapshots). Nil when useCases.FX.Rates is
+// unwired; the loader returns nil when no functional currency is set.
+func loadFXTranslation(useCases *UseCases) func(context.Context) (*shared.FXTranslation, error) {
+	if useCases == nil || useCases.FX.Rates == nil {
+		return nil
+	}
+	return func(ctx context.Context) (*shared.FXTranslation, error) {
+		return shared.LoadFXTranslation(ctx, useCases.FX.Rates, getFunctionalCurrency(ctx, useCases))
+	}
+}
+
+// listClientPaymentTerms returns the client-scope ("client" / "both") payment
+// terms as revenue options — the revenue drawer's dropdown and the due dates
+// of aged invoices. Nil-safe: empty options when ListPaymentTerms is unwired.
+func listClientPaymentTerms(useCases *UseCases) func(context.Context) ([]*revenuedomain.PaymentTermOption, error) {
+	return func(ctx context.Context) ([]*revenuedomain.PaymentTermOption, error) {
+		list := useCases.Entity.PaymentTerm.ListPaymentTerms
+		if list == nil {
+			return nil, nil
+		}
+		resp, err := list(ctx, &paymenttermpb.ListPaymentTermsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		terms := resp.GetData()
+		opts := make([]*revenuedomain.PaymentTermOption, 0, len(terms))
+		for _, t := range terms {
+			id := t.GetId()
+			if id == "" {
+				continue
+			}
+			if scope := t.GetEntityScope(); scope != "client" && scope != "both" {
+				continue
+			}
+			opts = append(opts, &revenuedomain.PaymentTermOption{Id: id, Name: t.GetName(), NetDays: t.GetNetDays()})
+		}
+		return opts, nil
+	}
+}
+
+// wirePaymentAllocation wires the collection Allocations tab: the allocation
+// store, the client's open invoices aged from the revenue and payment lists,
+// and the revenue payment create/delete the allocations go through. Left
+// unset (tab hidden) unless every piece is wired.
+func wirePaymentAllocation(deps *treasurydomain.CollectionModuleDeps, useCases *UseCases) {
+	payments := useCases.Revenue.RevenuePayment
+	if useCases.Collection.PaymentAllocations == nil || useCases.Revenue.GetListPageData == nil ||
+		payments.ListRevenuePayments == nil || payments.CreateRevenuePayment == nil || payments.DeleteRevenuePayment == nil {
+		return
+	}
+	agingDeps := &revenueaging.Deps{
+		GetListPageData:     useCases.Revenue.GetListPageData,
+		ListRevenuePayments: payments.ListRevenuePayments,
+		ListPaymentTerms:    listClientPaymentTerms(useCases),
+		Notes:               useCases.Revenue.Notes.Store,
+	}
+	deps.PaymentAllocations = useCases.Collection.PaymentAllocations
+	deps.ListOpenInvoices = func(ctx context.Context, clientID, currency string) ([]*shared.AgingLine, error) {
+		return revenueaging.LoadOpenInvoices(ctx, agingDeps, clientID, currency, time.Now())
+	}
+	deps.CreateRevenuePayment = payments.CreateRevenuePayment
+	deps.DeleteRevenuePayment = payments.DeleteRevenuePayment
+	deps.ExtractUserID = useCases.ExtractUserID
+}
+
+// loadEInvoiceSeller returns the revenue e-invoice seller loader: the
+// consumer's useCases.Revenue.EInvoiceSeller with a blank name, TIN and
+// country taken from the workspace (the country from its home jurisdiction,
+// e.g. "PH" or "PH-00"). Nil when EInvoiceSeller is unwired, which hides the
+// e-invoice format.
+func loadEInvoiceSeller(useCases *UseCases) func(context.Context) (*ubl.Party, error) {
+	seller := useCases.Revenue.EInvoiceSeller
+	if seller == nil {
+		return nil
+	}
+	return func(ctx context.Context) (*ubl.Party, error) {
+		p, err := seller(ctx)
+		if err != nil || p == nil {
+			return p, err
+		}
+		party := *p
+		read := useCases.Entity.Workspace.ReadWorkspace
+		if read == nil {
+			return &party, nil
+		}
+		resp, err := read(ctx, &workspacepb.ReadWorkspaceRequest{
+			Data: &workspacepb.Workspace{Id: getDefaultWorkspaceID()},
+		})
+		if err != nil || len(resp.GetData()) == 0 {
+			return &party, nil
+		}
+		ws := resp.GetData()[0]
+		if party.Name == "" {
+			party.Name = ws.GetName()
+		}
+		if party.TaxID == "" {
+			party.TaxID = ws.GetTin()
+		}
+		if country, _, _ := strings.Cut(ws.GetHomeJurisdiction(), "-"); party.CountryCode == "" && len(country) == 2 {
+			party.CountryCode = strings.ToUpper(country)
+		}
+		return &party, nil
+	}
+}
@@ -2,6 +2,7 @@ package action
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 
@@ -10,6 +11,7 @@ import (
 
 	sib_expenditure_expenditure "github.com/erniealice/centymo-golang/domain/expenditure/expenditure"
 	poform "github.com/erniealice/centymo-golang/domain/expenditure/purchase_order/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 
 	purchaseorderpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/expenditure/purchase_order"
 )
@@ -24,6 +26,10 @@ type Deps struct {
 	ReadPurchaseOrder   func(ctx context.Context, req *purchaseorderpb.ReadPurchaseOrderRequest) (*purchaseorderpb.ReadPurchaseOrderResponse, error)
 	UpdatePurchaseOrder func(ctx context.Context, req *purchaseorderpb.UpdatePurchaseOrderRequest) (*purchaseorderpb.UpdatePurchaseOrderResponse, error)
 	DeletePurchaseOrder func(ctx context.Context, req *purchaseorderpb.DeletePurchaseOrderRequest) (*purchaseorderpb.DeletePurchaseOrderResponse, error)
+
+	// Numbering (optional) fills a blank PO number from the purchase_order
+	// sequence; the number is voided if the PO is not created.
+	Numbering *shared.DocumentNumbering
 }
 
 // formLabels maps ExpenditureLabels into the flat Labels struct for the template.
@@ -81,9 +87,22 @@ func NewAddAction(deps *Deps) view.View {
 
 		r := viewCtx.Request
 
+		poNumber := r.FormValue("po_number")
+		issued := ""
+		if poNumber == "" {
+			num, err := deps.Numbering.Allocate(ctx, shared.DocumentPurchaseOrder, "")
+			switch {
+			case err == nil:
+				poNumber, issued = num.Number, num.Number
+			case !errors.Is(err, shared.ErrNoDocumentSequence):
+				log.Printf("Failed to number purchase order: %v", err)
+				return view.HTMXError(err.Error())
+			}
+		}
+
 		resp, err := deps.CreatePurchaseOrder(ctx, &purchaseorderpb.CreatePurchaseOrderRequest{
 			Data: &purchaseorderpb.PurchaseOrder{
-				PoNumber:        r.FormValue("po_number"),
+				PoNumber:        poNumber,
 				SupplierId:      r.FormValue("supplier_id"),
 				PoType:          r.FormValue("po_type"),
 				OrderDateString: strPtr(r.FormValue("order_date_string")),
@@ -94,6 +113,11 @@ func NewAddAction(deps *Deps) view.View {
 		})
 		if err != nil {
 			log.Printf("Failed to create purchase order: %v", err)
+			if issued != "" {
+				if voidErr := deps.Numbering.Void(context.WithoutCancel(ctx), issued, "purchase order not created"); voidErr != nil {
+					log.Printf("Failed to void %s: %v", issued, voidErr)
+				}
+			}
 			return view.HTMXError(err.Error())
 		}
 
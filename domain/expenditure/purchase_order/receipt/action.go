@@ -15,6 +15,7 @@ import (
 
 	sib_expenditure_expenditure "github.com/erniealice/centymo-golang/domain/expenditure/expenditure"
 	receiptform "github.com/erniealice/centymo-golang/domain/expenditure/purchase_order/receipt/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 
 	purchaseorderpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/expenditure/purchase_order"
 	purchaseorderlineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/expenditure/purchase_order_line_item"
@@ -36,6 +37,8 @@ type Deps struct {
 	CreateInventoryMovement     func(ctx context.Context, req *inventorymovementpb.CreateInventoryMovementRequest) (*inventorymovementpb.CreateInventoryMovementResponse, error)
 	ReadInventoryItem           func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error)
 	UpdateInventoryItem         func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error)
+	// Atomic stock delta (optional — nil falls back to a serialized read+update)
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 }
 
 func htmxError(message string) view.ViewResult {
@@ -135,6 +138,10 @@ func NewConfirmReceiptAction(deps *Deps) view.View {
 			receiptDate = time.Now().Format("2006-01-02")
 		}
 
+		// Received goods are added as a delta so a concurrent sale or receipt
+		// against the same inventory item is not overwritten.
+		adjust := shared.InventoryAdjuster(deps.AdjustInventoryQuantity, deps.ReadInventoryItem, deps.UpdateInventoryItem)
+
 		// Process each line item
 		for _, item := range listResp.GetData() {
 			if item.GetPurchaseOrderId() != id {
@@ -191,21 +198,13 @@ func NewConfirmReceiptAction(deps *Deps) view.View {
 				}
 
 				// Update inventory item quantity
-				if inventoryItemID := item.GetInventoryItemId(); inventoryItemID != "" && deps.ReadInventoryItem != nil {
-					itemResp, err := deps.ReadInventoryItem(ctx, &inventoryitempb.ReadInventoryItemRequest{
-						Data: &inventoryitempb.InventoryItem{Id: inventoryItemID},
-					})
-					if err == nil && len(itemResp.GetData()) > 0 {
-						invItem := itemResp.GetData()[0]
-						newOnHand := invItem.GetQuantityOnHand() + receivedQty
-						newAvailable := newOnHand - invItem.GetQuantityReserved()
-						_, _ = deps.UpdateInventoryItem(ctx, &inventoryitempb.UpdateInventoryItemRequest{
-							Data: &inventoryitempb.InventoryItem{
-								Id:                inventoryItemID,
-								QuantityOnHand:    newOnHand,
-								QuantityAvailable: newAvailable,
-							},
-						})
+				if inventoryItemID := item.GetInventoryItemId(); inventoryItemID != "" && adjust != nil {
+					if _, err := adjust(ctx, shared.InventoryDelta{
+						InventoryItemID: inventoryItemID,
+						OnHand:          receivedQty,
+						Available:       receivedQty,
+					}); err != nil {
+						log.Printf("Failed to add received stock to inventory item %s: %v", inventoryItemID, err)
 					}
 				}
 			}
@@ -18,6 +18,7 @@ import (
 	purchaseorderlineitem "github.com/erniealice/centymo-golang/domain/expenditure/purchase_order/line_item"
 	purchaseorderlist "github.com/erniealice/centymo-golang/domain/expenditure/purchase_order/list"
 	purchaseorderreceipt "github.com/erniealice/centymo-golang/domain/expenditure/purchase_order/receipt"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 )
 
 // PurchaseOrderModuleDeps holds all dependencies for the purchase order module.
@@ -45,6 +46,8 @@ type PurchaseOrderModuleDeps struct {
 	CreateInventoryMovement func(ctx context.Context, req *inventorymovementpb.CreateInventoryMovementRequest) (*inventorymovementpb.CreateInventoryMovementResponse, error)
 	ReadInventoryItem       func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error)
 	UpdateInventoryItem     func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error)
+	// Atomic stock delta. Optional — nil falls back to a serialized read+update.
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 
 	// Attachment operations
 	UploadFile       func(ctx context.Context, bucket, key string, content []byte, contentType string) error
@@ -52,6 +55,10 @@ type PurchaseOrderModuleDeps struct {
 	CreateAttachment func(ctx context.Context, req *attachmentpb.CreateAttachmentRequest) (*attachmentpb.CreateAttachmentResponse, error)
 	DeleteAttachment func(ctx context.Context, req *attachmentpb.DeleteAttachmentRequest) (*attachmentpb.DeleteAttachmentResponse, error)
 	NewAttachmentID  func() string
+
+	// DocumentNumbering fills a blank PO number from the workspace's
+	// purchase_order sequence. Optional — the typed number is kept when nil.
+	DocumentNumbering *shared.DocumentNumbering
 }
 
 // PurchaseOrderModule holds all constructed purchase order views.
@@ -100,6 +107,7 @@ func NewPurchaseOrderModule(deps *PurchaseOrderModuleDeps) *PurchaseOrderModule
 			ReadPurchaseOrder:   deps.ReadPurchaseOrder,
 			UpdatePurchaseOrder: deps.UpdatePurchaseOrder,
 			DeletePurchaseOrder: deps.DeletePurchaseOrder,
+			Numbering:           deps.DocumentNumbering,
 		}
 		m.PurchaseOrderAdd = purchaseorderaction.NewAddAction(actionDeps)
 		m.PurchaseOrderEdit = purchaseorderaction.NewEditAction(actionDeps)
@@ -170,6 +178,7 @@ func NewPurchaseOrderModule(deps *PurchaseOrderModuleDeps) *PurchaseOrderModule
 			CreateInventoryMovement:     deps.CreateInventoryMovement,
 			ReadInventoryItem:           deps.ReadInventoryItem,
 			UpdateInventoryItem:         deps.UpdateInventoryItem,
+			AdjustInventoryQuantity:     deps.AdjustInventoryQuantity,
 		}
 		m.PurchaseOrderConfirmReceipt = purchaseorderreceipt.NewConfirmReceiptAction(receiptDeps)
 	}
@@ -15,6 +15,7 @@ import (
 
 	inventory "github.com/erniealice/centymo-golang/domain/inventory/inventory"
 	transactionform "github.com/erniealice/centymo-golang/domain/inventory/inventory/transaction/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 
 	inventoryitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
 	inventorytransactionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_transaction"
@@ -28,6 +29,8 @@ type Deps struct {
 	CreateInventoryTransaction func(ctx context.Context, req *inventorytransactionpb.CreateInventoryTransactionRequest) (*inventorytransactionpb.CreateInventoryTransactionResponse, error)
 	ReadInventoryItem          func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error)
 	UpdateInventoryItem        func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error)
+	// Atomic stock delta (optional — nil falls back to a serialized read+update)
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 }
 
 func formLabels(t func(string) string, tx inventory.TransactionLabels) transactionform.Labels {
@@ -109,27 +112,19 @@ func NewAssignAction(deps *Deps) view.View {
 		}
 
 		// Update inventory quantities based on transaction type
-		itemResp, err := deps.ReadInventoryItem(ctx, &inventoryitempb.ReadInventoryItemRequest{
-			Data: &inventoryitempb.InventoryItem{Id: inventoryItemID},
-		})
-		if err == nil && len(itemResp.GetData()) > 0 {
-			item := itemResp.GetData()[0]
-			currentOnHand := item.GetQuantityOnHand()
-			switch txType {
-			case "received", "returned":
-				currentOnHand += qty
-			case "sold", "transferred", "write_off":
-				currentOnHand -= qty
-				if currentOnHand < 0 {
-					currentOnHand = 0
-				}
+		delta := shared.InventoryDelta{InventoryItemID: inventoryItemID}
+		switch txType {
+		case "received", "returned":
+			delta.OnHand = qty
+		case "sold", "transferred", "write_off":
+			delta.OnHand = -qty
+			delta.FloorOnHand = true
+		}
+		adjust := shared.InventoryAdjuster(deps.AdjustInventoryQuantity, deps.ReadInventoryItem, deps.UpdateInventoryItem)
+		if delta.OnHand != 0 && adjust != nil {
+			if _, err := adjust(ctx, delta); err != nil {
+				log.Printf("Failed to adjust inventory item %s for %s transaction: %v", inventoryItemID, txType, err)
 			}
-			_, _ = deps.UpdateInventoryItem(ctx, &inventoryitempb.UpdateInventoryItemRequest{
-				Data: &inventoryitempb.InventoryItem{
-					Id:             inventoryItemID,
-					QuantityOnHand: currentOnHand,
-				},
-			})
 		}
 
 		return view.HTMXSuccess("transaction-table")
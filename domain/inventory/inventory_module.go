@@ -47,6 +47,9 @@ type InventoryModuleDeps struct {
 	UpdateInventoryItem func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error)
 	DeleteInventoryItem func(ctx context.Context, req *inventoryitempb.DeleteInventoryItemRequest) (*inventoryitempb.DeleteInventoryItemResponse, error)
 	SetItemActive       func(ctx context.Context, id string, active bool) error
+	// Atomic stock delta used by the transaction drawer. Optional — nil falls
+	// back to a serialized Read/UpdateInventoryItem.
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 
 	// Inventory Serial CRUD
 	ListInventorySerials  func(ctx context.Context, req *inventoryserialpb.ListInventorySerialsRequest) (*inventoryserialpb.ListInventorySerialsResponse, error)
@@ -211,6 +214,7 @@ func NewInventoryModule(deps *InventoryModuleDeps) *InventoryModule {
 		CreateInventoryTransaction: deps.CreateInventoryTransaction,
 		ReadInventoryItem:          deps.ReadInventoryItem,
 		UpdateInventoryItem:        deps.UpdateInventoryItem,
+		AdjustInventoryQuantity:    deps.AdjustInventoryQuantity,
 	}
 
 	return &InventoryModule{
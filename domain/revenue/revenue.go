@@ -20,16 +20,24 @@ import (
 // Re-exported data/route types (type aliases — identity-preserving).
 type (
 	RevenueActionLabels            = revenuepkg.ActionLabels
+	RevenueAgingLabels             = revenuepkg.AgingLabels
 	RevenueBulkLabels              = revenuepkg.BulkLabels
 	RevenueButtonLabels            = revenuepkg.ButtonLabels
 	RevenueColumnLabels            = revenuepkg.ColumnLabels
 	RevenueConfirmLabels           = revenuepkg.ConfirmLabels
 	RevenueDashboardLabels         = revenuepkg.DashboardLabels
 	RevenueDetailLabels            = revenuepkg.DetailLabels
+	RevenueDunningLabels           = revenuepkg.DunningLabels
+	RevenueEmailLabels             = revenuepkg.EmailLabels
 	RevenueEmptyLabels             = revenuepkg.EmptyLabels
 	RevenueErrorLabels             = revenuepkg.ErrorLabels
+	RevenueExportLabels            = revenuepkg.ExportLabels
+	RevenueFXRateLabels            = revenuepkg.FXRateLabels
 	RevenueFormLabels              = revenuepkg.FormLabels
+	RevenueFulfillmentLabels       = revenuepkg.FulfillmentLabels
 	RevenueLabels                  = revenuepkg.Labels
+	RevenueNoteLabels              = revenuepkg.NoteLabels
+	RevenueNumberingLabels         = revenuepkg.NumberingLabels
 	RevenuePageLabels              = revenuepkg.PageLabels
 	RevenueRoutes                  = revenuepkg.Routes
 	RevenueRunActionLabels         = revenuerunpkg.ActionLabels
@@ -55,54 +63,98 @@ type (
 	RevenueRunStatusBadgeLabels    = revenuerunpkg.StatusBadgeLabels
 	RevenueRunSummaryLabels        = revenuerunpkg.SummaryLabels
 	RevenueSettingsLabels          = revenuepkg.SettingsLabels
+	RevenueStatementLabels         = revenuepkg.StatementLabels
 )
 
 // Re-exported URL route consts (const-identity preserved).
 const (
-	RevenueAddURL                     = revenuepkg.AddURL
-	RevenueAttachmentDeleteURL        = revenuepkg.AttachmentDeleteURL
-	RevenueAttachmentUploadURL        = revenuepkg.AttachmentUploadURL
-	RevenueBulkDeleteURL              = revenuepkg.BulkDeleteURL
-	RevenueBulkSetStatusURL           = revenuepkg.BulkSetStatusURL
-	RevenueDashboardURL               = revenuepkg.DashboardURL
-	RevenueDeleteURL                  = revenuepkg.DeleteURL
-	RevenueDetailURL                  = revenuepkg.DetailURL
-	RevenueEditURL                    = revenuepkg.EditURL
-	RevenueEmailURL                   = revenuepkg.EmailURL
-	RevenueInvoiceDownloadURL         = revenuepkg.InvoiceDownloadURL
-	RevenueLineItemAddURL             = revenuepkg.LineItemAddURL
-	RevenueLineItemDiscountURL        = revenuepkg.LineItemDiscountURL
-	RevenueLineItemEditURL            = revenuepkg.LineItemEditURL
-	RevenueLineItemRemoveURL          = revenuepkg.LineItemRemoveURL
-	RevenueLineItemTableURL           = revenuepkg.LineItemTableURL
-	RevenueListURL                    = revenuepkg.ListURL
-	RevenuePaymentAddURL              = revenuepkg.PaymentAddURL
-	RevenuePaymentEditURL             = revenuepkg.PaymentEditURL
-	RevenuePaymentRemoveURL           = revenuepkg.PaymentRemoveURL
-	RevenuePaymentTableURL            = revenuepkg.PaymentTableURL
-	RevenuePriceLookupURL             = revenuepkg.PriceLookupURL
-	RevenueRecomputeTaxesURL          = revenuepkg.RecomputeTaxesURL
-	RevenueRunAttachmentDeleteURL     = revenuerunpkg.AttachmentDeleteURL
-	RevenueRunAttachmentUploadURL     = revenuerunpkg.AttachmentUploadURL
-	RevenueRunDetailTabActionURL      = revenuerunpkg.DetailTabActionURL
-	RevenueRunDetailURL               = revenuerunpkg.DetailURL
-	RevenueRunListTableURL            = revenuerunpkg.ListTableURL
-	RevenueRunListURL                 = revenuerunpkg.ListURL
-	RevenueRunQueueTableURL           = revenuerunpkg.QueueTableURL
-	RevenueRunQueueURL                = revenuerunpkg.QueueURL
-	RevenueRunSubmitBatchURL          = revenuerunpkg.SubmitBatchURL
-	RevenueSearchClientURL            = revenuepkg.SearchClientURL
-	RevenueSearchLocationURL          = revenuepkg.SearchLocationURL
-	RevenueSearchProductURL           = revenuepkg.SearchProductURL
-	RevenueSearchSubscriptionURL      = revenuepkg.SearchSubscriptionURL
-	RevenueSetStatusURL               = revenuepkg.SetStatusURL
-	RevenueSettingsTemplateDefaultURL = revenuepkg.SettingsTemplateDefaultURL
-	RevenueSettingsTemplateDeleteURL  = revenuepkg.SettingsTemplateDeleteURL
-	RevenueSettingsTemplateUploadURL  = revenuepkg.SettingsTemplateUploadURL
-	RevenueSettingsTemplatesURL       = revenuepkg.SettingsTemplatesURL
-	RevenueSummaryURL                 = revenuepkg.SummaryURL
-	RevenueTabActionURL               = revenuepkg.TabActionURL
-	RevenueTableURL                   = revenuepkg.TableURL
+	RevenueAddURL                       = revenuepkg.AddURL
+	RevenueAgingClientURL               = revenuepkg.AgingClientURL
+	RevenueAgingExportURL               = revenuepkg.AgingExportURL
+	RevenueAgingTableURL                = revenuepkg.AgingTableURL
+	RevenueAgingURL                     = revenuepkg.AgingURL
+	RevenueAttachmentDeleteURL          = revenuepkg.AttachmentDeleteURL
+	RevenueAttachmentUploadURL          = revenuepkg.AttachmentUploadURL
+	RevenueBulkDeleteURL                = revenuepkg.BulkDeleteURL
+	RevenueBulkExportURL                = revenuepkg.BulkExportURL
+	RevenueBulkSetStatusURL             = revenuepkg.BulkSetStatusURL
+	RevenueDashboardURL                 = revenuepkg.DashboardURL
+	RevenueDeleteURL                    = revenuepkg.DeleteURL
+	RevenueDetailURL                    = revenuepkg.DetailURL
+	RevenueDunningOptOutURL             = revenuepkg.DunningOptOutURL
+	RevenueDunningPolicyURL             = revenuepkg.DunningPolicyURL
+	RevenueDunningRunURL                = revenuepkg.DunningRunURL
+	RevenueDunningTableURL              = revenuepkg.DunningTableURL
+	RevenueDunningURL                   = revenuepkg.DunningURL
+	RevenueEditURL                      = revenuepkg.EditURL
+	RevenueEmailCancelURL               = revenuepkg.EmailCancelURL
+	RevenueEmailComposeURL              = revenuepkg.EmailComposeURL
+	RevenueEmailTableURL                = revenuepkg.EmailTableURL
+	RevenueEmailTemplatesURL            = revenuepkg.EmailTemplatesURL
+	RevenueEmailURL                     = revenuepkg.EmailURL
+	RevenueExportDownloadURL            = revenuepkg.ExportDownloadURL
+	RevenueExportStatusURL              = revenuepkg.ExportStatusURL
+	RevenueExportURL                    = revenuepkg.ExportURL
+	RevenueFulfillmentAdvanceURL        = revenuepkg.FulfillmentAdvanceURL
+	RevenueFulfillmentQueueURL          = revenuepkg.FulfillmentQueueURL
+	RevenueFulfillmentStatusURL         = revenuepkg.FulfillmentStatusURL
+	RevenueFulfillmentTableURL          = revenuepkg.FulfillmentTableURL
+	RevenueInvoiceDownloadURL           = revenuepkg.InvoiceDownloadURL
+	RevenueLineItemAddURL               = revenuepkg.LineItemAddURL
+	RevenueLineItemDiscountURL          = revenuepkg.LineItemDiscountURL
+	RevenueLineItemEditURL              = revenuepkg.LineItemEditURL
+	RevenueLineItemRemoveURL            = revenuepkg.LineItemRemoveURL
+	RevenueLineItemTableURL             = revenuepkg.LineItemTableURL
+	RevenueListURL                      = revenuepkg.ListURL
+	RevenueNoteAddURL                   = revenuepkg.NoteAddURL
+	RevenuePaymentAddURL                = revenuepkg.PaymentAddURL
+	RevenuePaymentEditURL               = revenuepkg.PaymentEditURL
+	RevenuePaymentRemoveURL             = revenuepkg.PaymentRemoveURL
+	RevenuePaymentTableURL              = revenuepkg.PaymentTableURL
+	RevenuePriceLookupURL               = revenuepkg.PriceLookupURL
+	RevenueRecomputeTaxesURL            = revenuepkg.RecomputeTaxesURL
+	RevenueRunAttachmentDeleteURL       = revenuerunpkg.AttachmentDeleteURL
+	RevenueRunAttachmentUploadURL       = revenuerunpkg.AttachmentUploadURL
+	RevenueRunDetailTabActionURL        = revenuerunpkg.DetailTabActionURL
+	RevenueRunDetailURL                 = revenuerunpkg.DetailURL
+	RevenueRunListTableURL              = revenuerunpkg.ListTableURL
+	RevenueRunListURL                   = revenuerunpkg.ListURL
+	RevenueRunQueueTableURL             = revenuerunpkg.QueueTableURL
+	RevenueRunQueueURL                  = revenuerunpkg.QueueURL
+	RevenueRunSubmitBatchURL            = revenuerunpkg.SubmitBatchURL
+	RevenueSearchClientURL              = revenuepkg.SearchClientURL
+	RevenueSearchLocationURL            = revenuepkg.SearchLocationURL
+	RevenueSearchProductURL             = revenuepkg.SearchProductURL
+	RevenueSearchSubscriptionURL        = revenuepkg.SearchSubscriptionURL
+	RevenueSetStatusURL                 = revenuepkg.SetStatusURL
+	RevenueSettingsFXRateAddURL         = revenuepkg.SettingsFXRateAddURL
+	RevenueSettingsFXRateDeleteURL      = revenuepkg.SettingsFXRateDeleteURL
+	RevenueSettingsFXRateImportURL      = revenuepkg.SettingsFXRateImportURL
+	RevenueSettingsFXRatesTableURL      = revenuepkg.SettingsFXRatesTableURL
+	RevenueSettingsFXRatesURL           = revenuepkg.SettingsFXRatesURL
+	RevenueSettingsNumberingAddURL      = revenuepkg.SettingsNumberingAddURL
+	RevenueSettingsNumberingDeleteURL   = revenuepkg.SettingsNumberingDeleteURL
+	RevenueSettingsNumberingEditURL     = revenuepkg.SettingsNumberingEditURL
+	RevenueSettingsNumberingNumbersURL  = revenuepkg.SettingsNumberingNumbersURL
+	RevenueSettingsNumberingRegisterURL = revenuepkg.SettingsNumberingRegisterURL
+	RevenueSettingsNumberingTableURL    = revenuepkg.SettingsNumberingTableURL
+	RevenueSettingsNumberingURL         = revenuepkg.SettingsNumberingURL
+	RevenueSettingsNumberingVoidURL     = revenuepkg.SettingsNumberingVoidURL
+	RevenueSettingsTemplateCheckURL     = revenuepkg.SettingsTemplateCheckURL
+	RevenueSettingsTemplateDefaultURL   = revenuepkg.SettingsTemplateDefaultURL
+	RevenueSettingsTemplateDeleteURL    = revenuepkg.SettingsTemplateDeleteURL
+	RevenueSettingsTemplatePreviewURL   = revenuepkg.SettingsTemplatePreviewURL
+	RevenueSettingsTemplateUploadURL    = revenuepkg.SettingsTemplateUploadURL
+	RevenueSettingsTemplatesURL         = revenuepkg.SettingsTemplatesURL
+	RevenueStatementDownloadURL         = revenuepkg.StatementDownloadURL
+	RevenueStatementEmailURL            = revenuepkg.StatementEmailURL
+	RevenueStatementURL                 = revenuepkg.StatementURL
+	RevenueStatementsEmailURL           = revenuepkg.StatementsEmailURL
+	RevenueStatementsTableURL           = revenuepkg.StatementsTableURL
+	RevenueStatementsURL                = revenuepkg.StatementsURL
+	RevenueSummaryURL                   = revenuepkg.SummaryURL
+	RevenueTabActionURL                 = revenuepkg.TabActionURL
+	RevenueTableURL                     = revenuepkg.TableURL
 )
 
 // Re-exported Default* constructors (function values).
@@ -2,14 +2,16 @@ package action
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
 	"github.com/erniealice/centymo-golang/domain/revenue/revenue/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	"github.com/erniealice/pyeza-golang/route"
 	"github.com/erniealice/pyeza-golang/view"
 
@@ -80,6 +82,15 @@ type Deps struct {
 	UpdateRevenue func(ctx context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error)
 	DeleteRevenue func(ctx context.Context, req *revenuepb.DeleteRevenueRequest) (*revenuepb.DeleteRevenueResponse, error)
 
+	// Numbering (optional) fills a blank reference number from the invoice
+	// sequence of the revenue's location. Nil keeps the typed reference only.
+	Numbering *shared.DocumentNumbering
+
+	// SnapshotFXRate records the exchange rate a foreign-currency revenue is
+	// issued at, keyed by revenue ID (optional — no snapshot is taken when
+	// nil). Registered as the FX hook of NewStatusMachine.
+	SnapshotFXRate func(ctx context.Context, id, currency string, on time.Time) error
+
 	// Typed line item operations
 	CreateRevenueLineItem func(ctx context.Context, req *revenuelineitempb.CreateRevenueLineItemRequest) (*revenuelineitempb.CreateRevenueLineItemResponse, error)
 	ListRevenueLineItems  func(ctx context.Context, req *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error)
@@ -93,6 +104,9 @@ type Deps struct {
 	ListInventoryItems           func(ctx context.Context, req *inventoryitempb.ListInventoryItemsRequest) (*inventoryitempb.ListInventoryItemsResponse, error)
 	UpdateInventorySerial        func(ctx context.Context, req *inventoryserialpb.UpdateInventorySerialRequest) (*inventoryserialpb.UpdateInventorySerialResponse, error)
 	CreateInventorySerialHistory func(ctx context.Context, req *serialhistorypb.CreateInventorySerialHistoryRequest) (*serialhistorypb.CreateInventorySerialHistoryResponse, error)
+	// Atomic stock delta (optional — falls back to a per-item serialized
+	// read+update over ReadInventoryItem/UpdateInventoryItem when nil)
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 
 	// Price lookup for line item (optional — gracefully degrades when nil)
 	FindApplicablePriceList func(ctx context.Context, req *pricelistpb.FindApplicablePriceListRequest) (*pricelistpb.FindApplicablePriceListResponse, error)
@@ -113,6 +127,11 @@ type Deps struct {
 	AddWHTCertURL string
 	// WithholdingCertificateRoutes holds URL constants for withholding cert actions.
 	WithholdingCertAddURL string
+
+	// StatusMachine drives NewSetStatusAction and NewBulkSetStatusAction.
+	// Optional — nil uses NewStatusMachine(deps), the default statuses with
+	// the D5/D6/D20/D21 rules.
+	StatusMachine *shared.RevenueStatusMachine
 }
 
 // buildFormLabels constructs form.Labels from the translation function.
@@ -335,10 +354,25 @@ func NewAddAction(deps *Deps) view.View {
 		// Read activity_ids from form (optional — newline-separated, for "from_activities" type)
 		activityIDs := r.FormValue("activity_ids")
 
+		// A blank reference takes the next invoice number; it is voided
+		// below if the revenue is not written.
+		reference := r.FormValue("reference_number")
+		issued := ""
+		if reference == "" {
+			num, err := deps.Numbering.Allocate(ctx, shared.DocumentInvoice, r.FormValue("location_id"))
+			switch {
+			case err == nil:
+				reference, issued = num.Number, num.Number
+			case !errors.Is(err, shared.ErrNoDocumentSequence):
+				log.Printf("Failed to number sale: %v", err)
+				return view.HTMXError(deps.Labels.Numbering.AllocationFailed)
+			}
+		}
+
 		revenueData := &revenuepb.Revenue{
 			Name:            customerName,
 			ClientId:        clientID,
-			ReferenceNumber: strPtr(r.FormValue("reference_number")),
+			ReferenceNumber: strPtr(reference),
 			RevenueDate:     strPtr(r.FormValue("revenue_date_string")),
 			Currency:        r.FormValue("currency"),
 			Status:          r.FormValue("status"),
@@ -360,6 +394,11 @@ func NewAddAction(deps *Deps) view.View {
 		})
 		if err != nil {
 			log.Printf("Failed to create sale: %v", err)
+			if issued != "" {
+				if voidErr := deps.Numbering.Void(context.WithoutCancel(ctx), issued, "sale not created"); voidErr != nil {
+					log.Printf("Failed to void %s: %v", issued, voidErr)
+				}
+			}
 			return view.HTMXError(err.Error())
 		}
 
@@ -829,14 +868,12 @@ func NewBulkDeleteAction(deps *Deps) view.View {
 }
 
 // NewSetStatusAction creates the sales status update action (POST only).
-// Expects query params: ?id={saleId}&status={draft|complete|cancelled}
-//
-// Business rules:
-//   - D20: Block completion with zero line items
-//   - D21: Block cancellation if payments exist
-//   - D5: Deduct stock on completion
-//   - D6: Release serials on cancellation
+// Expects query params: ?id={saleId}&status={target}, where target is any
+// status staff may set on deps.StatusMachine (draft, complete, cancelled plus
+// workspace states). Business rules (D5/D6/D20/D21 and workspace hooks) run
+// as the machine's guards and hooks — see NewStatusMachine.
 func NewSetStatusAction(deps *Deps) view.View {
+	machine := statusMachine(deps)
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
 		perms := view.GetUserPermissions(ctx)
 		if !perms.Can("invoice", "update") {
@@ -854,70 +891,21 @@ func NewSetStatusAction(deps *Deps) view.View {
 		if id == "" {
 			return view.HTMXError(deps.Labels.Errors.IDRequired)
 		}
-		if targetStatus != "draft" && targetStatus != "complete" && targetStatus != "cancelled" {
+		if !machine.IsManual(targetStatus) {
 			return view.HTMXError(deps.Labels.Errors.InvalidStatus)
 		}
 
-		// D20: Block completion with zero items
-		if targetStatus == "complete" {
-			lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, id)
-			if err != nil {
-				log.Printf("Failed to list line items for sale %s: %v", id, err)
-				return view.HTMXError(err.Error())
-			}
-			if len(lineItems) == 0 {
+		t := revenueTransition(ctx, deps, id, targetStatus)
+		if err := machine.Apply(ctx, t, updateRevenueStatus(deps)); err != nil {
+			log.Printf("Failed to move sale %s to %s: %v", id, targetStatus, err)
+			switch {
+			case errors.Is(err, shared.ErrRevenueNoLineItems):
 				return view.HTMXError(deps.Labels.Errors.NoItemsCannotComplete)
-			}
-
-			// Update status
-			if _, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
-				Data: &revenuepb.Revenue{Id: id, Status: targetStatus},
-			}); err != nil {
-				log.Printf("Failed to update sale status %s: %v", id, err)
-				return view.HTMXError(err.Error())
-			}
-
-			// D5: Deduct stock on completion
-			deductStockForLineItems(ctx, deps, id, lineItems)
-
-			return view.HTMXSuccess("revenue-table")
-		}
-
-		// D21: Block cancellation if payments exist
-		if targetStatus == "cancelled" {
-			payments, err := getPaymentsForRevenue(ctx, deps.ListRevenuePayments, id)
-			if err != nil {
-				log.Printf("Failed to list payments for sale %s: %v", id, err)
-				return view.HTMXError(err.Error())
-			}
-			if len(payments) > 0 {
+			case errors.Is(err, shared.ErrRevenueHasPayments):
 				return view.HTMXError(deps.Labels.Errors.HasPaymentsCannotCancel)
+			case errors.Is(err, shared.ErrRevenueTransitionNotAllowed), errors.Is(err, shared.ErrUnknownRevenueStatus):
+				return view.HTMXError(deps.Labels.Errors.InvalidStatus)
 			}
-
-			// Update status
-			if _, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
-				Data: &revenuepb.Revenue{Id: id, Status: targetStatus},
-			}); err != nil {
-				log.Printf("Failed to update sale status %s: %v", id, err)
-				return view.HTMXError(err.Error())
-			}
-
-			// D6: Release serials on cancellation
-			lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, id)
-			if err != nil {
-				log.Printf("Failed to list line items for serial release on sale %s: %v", id, err)
-			} else {
-				releaseSerialsForLineItems(ctx, deps, id, lineItems)
-			}
-
-			return view.HTMXSuccess("revenue-table")
-		}
-
-		// Default: draft — just update status
-		if _, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
-			Data: &revenuepb.Revenue{Id: id, Status: targetStatus},
-		}); err != nil {
-			log.Printf("Failed to update sale status %s: %v", id, err)
 			return view.HTMXError(err.Error())
 		}
 
@@ -928,12 +916,11 @@ func NewSetStatusAction(deps *Deps) view.View {
 // NewBulkSetStatusAction creates the sales bulk status update action (POST only).
 // Selected IDs come as multiple "id" form fields; target status from "target_status" field.
 //
-// Business rules:
-//   - D20: Block bulk completion if any sale has zero line items
-//   - D21: Block bulk cancellation if any sale has payments
-//   - D5: Deduct stock on completion for each sale
-//   - D6: Release serials on cancellation for each sale
+// Every sale is checked against deps.StatusMachine before any is updated, so
+// a guard failure (D20 no items, D21 payments) or a disallowed transition
+// blocks the whole batch. Hooks (D5/D6, workspace hooks) run per sale.
 func NewBulkSetStatusAction(deps *Deps) view.View {
+	machine := statusMachine(deps)
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
 		perms := view.GetUserPermissions(ctx)
 		if !perms.Can("invoice", "update") {
@@ -948,79 +935,43 @@ func NewBulkSetStatusAction(deps *Deps) view.View {
 		if len(ids) == 0 {
 			return view.HTMXError(deps.Labels.Errors.NoIDsProvided)
 		}
-		if targetStatus != "draft" && targetStatus != "complete" && targetStatus != "cancelled" {
+		if !machine.IsManual(targetStatus) {
 			return view.HTMXError(deps.Labels.Errors.InvalidTargetStatus)
 		}
 
-		// D21: Block bulk cancellation if any sale has payments
-		if targetStatus == "cancelled" {
-			withPayments := 0
-			for _, id := range ids {
-				payments, err := getPaymentsForRevenue(ctx, deps.ListRevenuePayments, id)
-				if err != nil {
-					log.Printf("Failed to check payments for sale %s: %v", id, err)
-					continue
-				}
-				if len(payments) > 0 {
-					withPayments++
-				}
-			}
-			if withPayments > 0 {
-				return view.HTMXError(fmt.Sprintf(
-					deps.Labels.Errors.BulkHasPayments,
-					withPayments, len(ids),
-				))
+		transitions := make([]shared.RevenueTransition, 0, len(ids))
+		withPayments, emptyCount, notAllowed := 0, 0, 0
+		for _, id := range ids {
+			t := revenueTransition(ctx, deps, id, targetStatus)
+			transitions = append(transitions, t)
+			err := machine.Check(ctx, t)
+			switch {
+			case err == nil:
+			case errors.Is(err, shared.ErrRevenueHasPayments):
+				withPayments++
+			case errors.Is(err, shared.ErrRevenueNoLineItems):
+				emptyCount++
+			case errors.Is(err, shared.ErrRevenueTransitionNotAllowed):
+				notAllowed++
+			default:
+				log.Printf("Failed to check status change for sale %s: %v", id, err)
 			}
 		}
-
-		// D20: Block bulk completion if any sale has zero line items
-		if targetStatus == "complete" {
-			emptyCount := 0
-			for _, id := range ids {
-				lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, id)
-				if err != nil {
-					log.Printf("Failed to check line items for sale %s: %v", id, err)
-					continue
-				}
-				if len(lineItems) == 0 {
-					emptyCount++
-				}
-			}
-			if emptyCount > 0 {
-				return view.HTMXError(fmt.Sprintf(
-					deps.Labels.Errors.BulkNoItems,
-					emptyCount, len(ids),
-				))
-			}
+		if withPayments > 0 {
+			return view.HTMXError(fmt.Sprintf(deps.Labels.Errors.BulkHasPayments, withPayments, len(ids)))
+		}
+		if emptyCount > 0 {
+			return view.HTMXError(fmt.Sprintf(deps.Labels.Errors.BulkNoItems, emptyCount, len(ids)))
+		}
+		if notAllowed > 0 {
+			return view.HTMXError(deps.Labels.Errors.InvalidTargetStatus)
 		}
 
 		// Update all statuses and apply side-effects
-		for _, id := range ids {
-			if _, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
-				Data: &revenuepb.Revenue{Id: id, Status: targetStatus},
-			}); err != nil {
-				log.Printf("Failed to update sale status %s: %v", id, err)
-				continue
-			}
-
-			// D5: Deduct stock on completion
-			if targetStatus == "complete" {
-				lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, id)
-				if err != nil {
-					log.Printf("Failed to list line items for stock deduction on sale %s: %v", id, err)
-					continue
-				}
-				deductStockForLineItems(ctx, deps, id, lineItems)
-			}
-
-			// D6: Release serials on cancellation
-			if targetStatus == "cancelled" {
-				lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, id)
-				if err != nil {
-					log.Printf("Failed to list line items for serial release on sale %s: %v", id, err)
-					continue
-				}
-				releaseSerialsForLineItems(ctx, deps, id, lineItems)
+		update := updateRevenueStatus(deps)
+		for _, t := range transitions {
+			if err := machine.Apply(ctx, t, update); err != nil {
+				log.Printf("Failed to move sale %s to %s: %v", t.RevenueID, targetStatus, err)
 			}
 		}
 
@@ -1106,102 +1057,3 @@ func getPaymentsForRevenue(ctx context.Context, list func(ctx context.Context, r
 	}
 	return payments, nil
 }
-
-// deductStockForLineItems decrements inventory quantities and marks serials as sold.
-func deductStockForLineItems(ctx context.Context, deps *Deps, saleID string, lineItems []map[string]any) {
-	for _, item := range lineItems {
-		inventoryItemID, _ := item["inventory_item_id"].(string)
-		serialID, _ := item["inventory_serial_id"].(string)
-
-		// Deduct quantity from inventory item
-		if inventoryItemID != "" {
-			resp, err := deps.ReadInventoryItem(ctx, &inventoryitempb.ReadInventoryItemRequest{
-				Data: &inventoryitempb.InventoryItem{Id: inventoryItemID},
-			})
-			if err != nil {
-				log.Printf("Failed to read inventory item %s for stock deduction: %v", inventoryItemID, err)
-				continue
-			}
-			data := resp.GetData()
-			if len(data) == 0 {
-				log.Printf("Inventory item %s not found for stock deduction", inventoryItemID)
-				continue
-			}
-			invItem := data[0]
-
-			lineQtyStr, _ := item["quantity"].(string)
-			lineQty, _ := strconv.ParseFloat(lineQtyStr, 64)
-
-			newQty := invItem.GetQuantityOnHand() - lineQty
-			if _, err := deps.UpdateInventoryItem(ctx, &inventoryitempb.UpdateInventoryItemRequest{
-				Data: &inventoryitempb.InventoryItem{
-					Id:             inventoryItemID,
-					QuantityOnHand: newQty,
-				},
-			}); err != nil {
-				log.Printf("Failed to deduct stock for inventory item %s: %v", inventoryItemID, err)
-			}
-		}
-
-		// Mark serial as sold and create history
-		if serialID != "" {
-			if _, err := deps.UpdateInventorySerial(ctx, &inventoryserialpb.UpdateInventorySerialRequest{
-				Data: &inventoryserialpb.InventorySerial{
-					Id:     serialID,
-					Status: "sold",
-				},
-			}); err != nil {
-				log.Printf("Failed to mark serial %s as sold: %v", serialID, err)
-			}
-
-			if _, err := deps.CreateInventorySerialHistory(ctx, &serialhistorypb.CreateInventorySerialHistoryRequest{
-				Data: &serialhistorypb.InventorySerialHistory{
-					InventorySerialId: serialID,
-					InventoryItemId:   inventoryItemID,
-					FromStatus:        "reserved",
-					ToStatus:          "sold",
-					ReferenceType:     "revenue",
-					ReferenceId:       saleID,
-					Notes:             "Auto: sale completed",
-				},
-			}); err != nil {
-				log.Printf("Failed to create serial history for %s: %v", serialID, err)
-			}
-		}
-	}
-}
-
-// releaseSerialsForLineItems marks serials as available and creates history records.
-func releaseSerialsForLineItems(ctx context.Context, deps *Deps, saleID string, lineItems []map[string]any) {
-	for _, item := range lineItems {
-		serialID, _ := item["inventory_serial_id"].(string)
-		if serialID == "" {
-			continue
-		}
-
-		inventoryItemID, _ := item["inventory_item_id"].(string)
-
-		if _, err := deps.UpdateInventorySerial(ctx, &inventoryserialpb.UpdateInventorySerialRequest{
-			Data: &inventoryserialpb.InventorySerial{
-				Id:     serialID,
-				Status: "available",
-			},
-		}); err != nil {
-			log.Printf("Failed to release serial %s: %v", serialID, err)
-		}
-
-		if _, err := deps.CreateInventorySerialHistory(ctx, &serialhistorypb.CreateInventorySerialHistoryRequest{
-			Data: &serialhistorypb.InventorySerialHistory{
-				InventorySerialId: serialID,
-				InventoryItemId:   inventoryItemID,
-				FromStatus:        "available",
-				ToStatus:          "available",
-				ReferenceType:     "revenue",
-				ReferenceId:       saleID,
-				Notes:             "Auto: sale cancelled",
-			},
-		}); err != nil {
-			log.Printf("Failed to create serial history for %s: %v", serialID, err)
-		}
-	}
-}
@@ -8,8 +8,10 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
 
@@ -190,6 +192,57 @@ func TestNewAddAction_POST_CreateFailure(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// NewAddAction — blank reference takes the next invoice number
+// ---------------------------------------------------------------------------
+
+func TestNewAddAction_POST_Numbering(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	numbering := shared.NewDocumentNumbering(shared.NewMemoryDocumentSequenceStore())
+	if err := numbering.SaveSequence(ctx, &shared.DocumentSequence{DocumentType: shared.DocumentInvoice, Prefix: "INV-", Padding: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	var gotRef string
+	fail := false
+	deps := &Deps{
+		Routes:    testRoutes(),
+		Labels:    testLabels(),
+		Numbering: numbering,
+		CreateRevenue: func(_ context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			gotRef = req.GetData().GetReferenceNumber()
+			if fail {
+				return nil, fmt.Errorf("database unavailable")
+			}
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-new"}}}, nil
+		},
+	}
+	add := func(form url.Values) int {
+		return NewAddAction(deps).Handle(ctxWithPerms("invoice:create"), &view.ViewContext{
+			Request: postForm("/sales/add", form),
+		}).StatusCode
+	}
+
+	if code := add(url.Values{"name": {"Test"}}); code != http.StatusOK || gotRef != "INV-0001" {
+		t.Errorf("blank reference = %d %q, want 200 INV-0001", code, gotRef)
+	}
+	if code := add(url.Values{"name": {"Test"}, "reference_number": {"MANUAL-7"}}); code != http.StatusOK || gotRef != "MANUAL-7" {
+		t.Errorf("typed reference = %d %q, want it kept", code, gotRef)
+	}
+
+	fail = true
+	if code := add(url.Values{"name": {"Test"}}); code != http.StatusUnprocessableEntity || gotRef != "INV-0002" {
+		t.Errorf("failed create = %d %q", code, gotRef)
+	}
+	seq, _ := numbering.Sequence(ctx, shared.DocumentInvoice, "")
+	register, _ := numbering.Numbers(ctx, seq.ID)
+	if len(register) != 2 || register[0].Status != shared.DocumentNumberVoided {
+		t.Errorf("register = %+v, want INV-0002 voided", register)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // NewEditAction — permission denied
 // ---------------------------------------------------------------------------
@@ -433,6 +486,61 @@ func TestNewSetStatusAction_CancelWithPayments(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// NewStatusMachine — FX rate snapshot on issue
+// ---------------------------------------------------------------------------
+
+func TestNewStatusMachine_FXSnapshot(t *testing.T) {
+	t.Parallel()
+
+	type call struct {
+		id, currency string
+		on           time.Time
+	}
+	var calls []call
+	deps := &Deps{
+		ListRevenueLineItems: func(_ context.Context, _ *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error) {
+			return &revenuelineitempb.ListRevenueLineItemsResponse{
+				Data: []*revenuelineitempb.RevenueLineItem{{Id: "li-001", RevenueId: "rev-001"}},
+			}, nil
+		},
+		SnapshotFXRate: func(_ context.Context, id, currency string, on time.Time) error {
+			calls = append(calls, call{id, currency, on})
+			return nil
+		},
+	}
+	m := NewStatusMachine(deps)
+	noop := func(context.Context, shared.RevenueTransition) error { return nil }
+
+	err := m.Apply(context.Background(), shared.RevenueTransition{
+		RevenueID: "rev-001",
+		From:      shared.RevenueStatusDraft,
+		To:        shared.RevenueStatusComplete,
+		Revenue:   &revenuepb.Revenue{Id: "rev-001", Currency: "USD", RevenueDate: strPtr("2026-03-05")},
+	}, noop)
+	if err != nil {
+		t.Fatalf("Apply complete: %v", err)
+	}
+	want := call{"rev-001", "USD", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)}
+	if len(calls) != 1 || calls[0] != want {
+		t.Fatalf("snapshot calls = %+v, want [%+v]", calls, want)
+	}
+
+	// Cancelling issues nothing.
+	err = m.Apply(context.Background(), shared.RevenueTransition{
+		RevenueID: "rev-002",
+		From:      shared.RevenueStatusDraft,
+		To:        shared.RevenueStatusCancelled,
+		Revenue:   &revenuepb.Revenue{Id: "rev-002", Currency: "USD"},
+	}, noop)
+	if err != nil {
+		t.Fatalf("Apply cancel: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("snapshot calls after cancel = %d, want 1", len(calls))
+	}
+}
+
 // ---------------------------------------------------------------------------
 // NewBulkDeleteAction — permission denied
 // ---------------------------------------------------------------------------
@@ -3,21 +3,51 @@ package action
 import (
 	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
-	"github.com/erniealice/fycha-golang/services/pdfconv"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+	"github.com/erniealice/centymo-golang/services/ubl"
 
 	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
 	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
 )
 
-//go:embed templates/invoice-template.docx
+//go:embed templates/invoice-template.docx templates/credit-note-template.docx templates/debit-note-template.docx templates/statement-template.docx
 var invoiceTemplateFS embed.FS
 
+// revenueDocument describes how a revenue renders: as an invoice, or as a
+// credit/debit note when its reference number comes from a note sequence.
+type revenueDocument struct {
+	purpose  string // LoadDefaultTemplate purpose
+	embedded string // fallback template in invoiceTemplateFS
+	filename string // download/attachment file name prefix
+	title    string // email subject, e.g. "Credit Note CN-000001"
+	// layout is the native PDF layout used when the workspace has none
+	// (nil = invoicepdf.DefaultLayout(title)).
+	layout func() *invoicepdf.Layout
+}
+
+var revenueDocuments = map[string]revenueDocument{
+	"":                       {purpose: "invoice", embedded: "templates/invoice-template.docx", filename: "invoice", title: "Invoice"},
+	shared.RevenueNoteCredit: {purpose: shared.RevenueNoteCredit, embedded: "templates/credit-note-template.docx", filename: "credit-note", title: "Credit Note"},
+	shared.RevenueNoteDebit:  {purpose: shared.RevenueNoteDebit, embedded: "templates/debit-note-template.docx", filename: "debit-note", title: "Debit Note"},
+}
+
+// statementDocument renders customer statements; see RenderStatement.
+var statementDocument = revenueDocument{purpose: "statement", embedded: "templates/statement-template.docx", filename: "statement", title: "Statement of Account", layout: invoicepdf.StatementLayout}
+
+// documentFor returns the document a revenue renders as.
+func documentFor(revenue *revenuepb.Revenue) revenueDocument {
+	return revenueDocuments[shared.RevenueNoteKindOf(revenue.GetReferenceNumber())]
+}
+
 // InvoiceDownloadDeps holds dependencies for the invoice download handler.
 type InvoiceDownloadDeps struct {
 	Routes revenuedomain.Routes
@@ -26,20 +56,40 @@ type InvoiceDownloadDeps struct {
 	// Revenue operations
 	ReadRevenue          func(ctx context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error)
 	ListRevenueLineItems func(ctx context.Context, req *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error)
+	// Optional: tax lines for the tax summary (nil = no tax rows)
+	ListRevenueTaxLines func(ctx context.Context, req *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error)
 
 	// Document generation (injected by composition root — wraps fycha.DocumentService.ProcessBytes)
 	GenerateDoc func(templateData []byte, data map[string]any) ([]byte, error)
 
 	// Optional: load custom default template from storage (nil = use embedded fallback)
 	LoadDefaultTemplate func(ctx context.Context, purpose string) ([]byte, error)
+
+	// Optional: credit/debit note links, for the note templates' original
+	// invoice reference (nil = left blank)
+	Notes shared.RevenueNoteStore
+
+	// Optional: the workspace's PDF engine (PDFEngineAuto, PDFEngineLibreOffice
+	// or PDFEngineNative; nil = auto) and its native layout per template
+	// purpose (nil or a nil layout = invoicepdf.DefaultLayout).
+	PDFEngine     func(ctx context.Context) string
+	LoadPDFLayout func(ctx context.Context, purpose string) (*invoicepdf.Layout, error)
+
+	// Optional: the workspace as the e-invoice seller party (nil = the "ubl"
+	// format is unavailable)
+	LoadSeller func(ctx context.Context) (*ubl.Party, error)
 }
 
 // NewInvoiceDownloadHandler creates an http.HandlerFunc that generates and downloads
 // an invoice for a given sale/revenue.
 //
 // Query parameters:
-//   - format: "pdf" (default) or "docx" — controls the output file format.
+//   - format: "pdf" (default), "docx" or "ubl" — controls the output file format.
 //     Example: /action/sales/detail/{id}/invoice/download?format=docx
+//
+// PDFs come from LibreOffice or the native renderer per deps.PDFEngine; see
+// renderPDF. "ubl" is the PEPPOL BIS e-invoice XML, served only when it
+// passes validation; see renderUBL.
 func NewInvoiceDownloadHandler(deps *InvoiceDownloadDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -54,107 +104,223 @@ func NewInvoiceDownloadHandler(deps *InvoiceDownloadDeps) http.HandlerFunc {
 		if format == "" {
 			format = "pdf"
 		}
-		if format != "pdf" && format != "docx" {
-			http.Error(w, "invalid format: must be \"pdf\" or \"docx\"", http.StatusBadRequest)
+		if format != "pdf" && format != "docx" && format != formatUBL {
+			http.Error(w, "invalid format: must be \"pdf\", \"docx\" or \"ubl\"", http.StatusBadRequest)
 			return
 		}
 
-		// 1. Read revenue
-		resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{
-			Data: &revenuepb.Revenue{Id: id},
-		})
-		if err != nil {
-			log.Printf("invoice download: failed to read revenue %s: %v", id, err)
-			http.Error(w, "failed to load sale", http.StatusInternalServerError)
-			return
-		}
-		data := resp.GetData()
-		if len(data) == 0 {
+		// 1. Read the revenue and build its template data
+		in, err := loadInvoice(ctx, deps, id)
+		if errors.Is(err, errRevenueNotFound) {
 			http.Error(w, "sale not found", http.StatusNotFound)
 			return
 		}
-		revenue := data[0]
-
-		// 2. Read line items
-		lineItemResp, err := deps.ListRevenueLineItems(ctx, &revenuelineitempb.ListRevenueLineItemsRequest{
-			RevenueId: &id,
-		})
 		if err != nil {
-			log.Printf("invoice download: failed to list line items for %s: %v", id, err)
-			http.Error(w, "failed to load line items", http.StatusInternalServerError)
+			log.Printf("invoice download: %v", err)
+			http.Error(w, "failed to load sale", http.StatusInternalServerError)
 			return
 		}
 
-		// Filter line items belonging to this revenue
-		var lineItems []*revenuelineitempb.RevenueLineItem
-		for _, item := range lineItemResp.GetData() {
-			if item.GetRevenueId() == id {
-				lineItems = append(lineItems, item)
+		if format == formatUBL {
+			xmlBytes, err := renderUBL(ctx, deps.LoadSeller, in)
+			if err != nil {
+				writeUBLError(w, "invoice download", err)
+				return
 			}
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xml"`, in.name()))
+			w.Write(xmlBytes)
+			return
 		}
 
-		// 3. Build invoice data map (matches doctemplate placeholder format)
-		invoiceData := buildInvoiceData(revenue, lineItems)
-
-		// 4. Load template (prefer custom default, fall back to embedded)
-		templateBytes, err := loadTemplate(ctx, deps.LoadDefaultTemplate)
-		if err != nil {
-			log.Printf("invoice download: failed to load template: %v", err)
-			http.Error(w, "failed to load template", http.StatusInternalServerError)
+		// 2. Generate the document in the requested format
+		outputBytes, err := renderDocument(ctx, deps, in, format)
+		if errors.Is(err, errLibreOfficeMissing) {
+			log.Printf("invoice download: LibreOffice not installed — cannot generate PDF")
+			http.Error(w, "PDF generation unavailable: LibreOffice is not installed on the server", http.StatusServiceUnavailable)
 			return
 		}
-
-		// 5. Generate DOCX
-		docBytes, err := deps.GenerateDoc(templateBytes, invoiceData)
 		if err != nil {
-			log.Printf("invoice download: failed to generate document: %v", err)
+			log.Printf("invoice download: %v", err)
 			http.Error(w, "failed to generate invoice", http.StatusInternalServerError)
 			return
 		}
 
-		// 6. Prepare output based on requested format
-		refNumber := revenue.GetReferenceNumber()
-		if refNumber == "" {
-			refNumber = id
-		}
-		ts := time.Now().Unix()
-
-		var outputBytes []byte
-		var contentType, filename string
-
-		if format == "pdf" {
-			pdfBytes, ok, convErr := pdfconv.ConvertDocxToPDF(docBytes)
-			if convErr != nil {
-				log.Printf("invoice download: PDF conversion failed: %v", convErr)
-				http.Error(w, "failed to convert invoice to PDF", http.StatusInternalServerError)
-				return
-			}
-			if !ok {
-				log.Printf("invoice download: LibreOffice not installed — cannot generate PDF")
-				http.Error(w, "PDF generation unavailable: LibreOffice is not installed on the server", http.StatusServiceUnavailable)
-				return
-			}
-			outputBytes = pdfBytes
-			contentType = "application/pdf"
-			filename = fmt.Sprintf("invoice-%s-%d.pdf", refNumber, ts)
-		} else {
-			outputBytes = docBytes
+		// 3. Send as file download
+		contentType := "application/pdf"
+		if format == "docx" {
 			contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
-			filename = fmt.Sprintf("invoice-%s-%d.docx", refNumber, ts)
 		}
-
-		// 7. Send as file download
+		filename := fmt.Sprintf("%s-%d.%s", in.name(), time.Now().Unix(), format)
 		w.Header().Set("Content-Type", contentType)
 		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 		w.Write(outputBytes)
 	}
 }
 
-// loadTemplate loads the invoice template. Tries custom default first, falls back to embedded.
-func loadTemplate(ctx context.Context, loadDefault func(context.Context, string) ([]byte, error)) ([]byte, error) {
+// errRevenueNotFound is returned by loadInvoice for an unknown revenue ID.
+var errRevenueNotFound = errors.New("sale not found")
+
+// invoiceInput is a revenue with the template data its documents are
+// generated from. The single download and the bulk export both go through
+// loadInvoice and renderDocument so their output is identical; the line
+// items, tax lines and note are kept for the e-invoice (renderUBL).
+type invoiceInput struct {
+	id      string
+	revenue *revenuepb.Revenue
+	doc     revenueDocument
+	data    map[string]any
+
+	lineItems []*revenuelineitempb.RevenueLineItem
+	taxLines  []*revenuetaxlinepb.RevenueTaxLine
+	note      *shared.RevenueNote
+}
+
+// name is the file name without extension, e.g. "invoice-INV-000123".
+func (in *invoiceInput) name() string {
+	ref := in.revenue.GetReferenceNumber()
+	if ref == "" {
+		ref = in.id
+	}
+	return fmt.Sprintf("%s-%s", in.doc.filename, ref)
+}
+
+// loadInvoice reads a revenue with its line items, tax lines and note link and
+// builds its template data (matches doctemplate placeholder format).
+func loadInvoice(ctx context.Context, deps *InvoiceDownloadDeps, id string) (*invoiceInput, error) {
+	resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{
+		Data: &revenuepb.Revenue{Id: id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revenue %s: %w", id, err)
+	}
+	data := resp.GetData()
+	if len(data) == 0 {
+		return nil, errRevenueNotFound
+	}
+	revenue := data[0]
+
+	lineItemResp, err := deps.ListRevenueLineItems(ctx, &revenuelineitempb.ListRevenueLineItemsRequest{
+		RevenueId: &id,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list line items for %s: %w", id, err)
+	}
+
+	// Filter line items belonging to this revenue
+	var lineItems []*revenuelineitempb.RevenueLineItem
+	for _, item := range lineItemResp.GetData() {
+		if item.GetRevenueId() == id {
+			lineItems = append(lineItems, item)
+		}
+	}
+
+	taxLines := listTaxLines(ctx, deps.ListRevenueTaxLines, id)
+	note := loadNote(ctx, deps.Notes, id)
+	return &invoiceInput{
+		id:        id,
+		revenue:   revenue,
+		doc:       documentFor(revenue),
+		data:      buildInvoiceData(revenue, lineItems, taxLines, note),
+		lineItems: lineItems,
+		taxLines:  taxLines,
+		note:      note,
+	}, nil
+}
+
+// renderDocument generates in's DOCX from the workspace template and, for
+// format "pdf", converts it with the workspace's PDF engine.
+func renderDocument(ctx context.Context, deps *InvoiceDownloadDeps, in *invoiceInput, format string) ([]byte, error) {
+	// Load template (prefer custom default, fall back to embedded)
+	templateBytes, err := loadTemplate(ctx, deps.LoadDefaultTemplate, in.doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load template: %w", err)
+	}
+
+	docBytes, err := deps.GenerateDoc(templateBytes, in.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate document: %w", err)
+	}
+	if format == "docx" {
+		return docBytes, nil
+	}
+
+	pdfBytes, err := renderPDF(ctx, pdfOptions{engine: deps.PDFEngine, loadLayout: deps.LoadPDFLayout}, in.doc, docBytes, in.data)
+	if err != nil && !errors.Is(err, errLibreOfficeMissing) {
+		return nil, fmt.Errorf("PDF conversion failed: %w", err)
+	}
+	return pdfBytes, err
+}
+
+// RenderInvoiceAttachment renders revenue id's document for an email
+// attachment, as the send-email action does: the PDF, or the DOCX when the
+// PDF cannot be produced. It returns the attachment's file name and bytes.
+func RenderInvoiceAttachment(ctx context.Context, deps *InvoiceDownloadDeps, id string) (string, []byte, error) {
+	return RenderInvoiceAttachmentAs(ctx, deps, id, "pdf")
+}
+
+// RenderInvoiceAttachmentAs renders revenue id's document as an attachment
+// in format: "pdf" (the DOCX when the PDF cannot be produced), "docx" or
+// "ubl", the validated e-invoice XML.
+func RenderInvoiceAttachmentAs(ctx context.Context, deps *InvoiceDownloadDeps, id, format string) (string, []byte, error) {
+	in, err := loadInvoice(ctx, deps, id)
+	if err != nil {
+		return "", nil, err
+	}
+	switch format {
+	case formatUBL:
+		xmlBytes, err := renderUBL(ctx, deps.LoadSeller, in)
+		if err != nil {
+			return "", nil, err
+		}
+		return in.name() + ".xml", xmlBytes, nil
+	case "pdf":
+		pdfBytes, err := renderDocument(ctx, deps, in, "pdf")
+		if err == nil {
+			return in.name() + ".pdf", pdfBytes, nil
+		}
+		log.Printf("invoice attachment: PDF generation failed for %s, attaching DOCX: %v", id, err)
+	case "docx":
+	default:
+		return "", nil, fmt.Errorf("invalid attachment format %q", format)
+	}
+	docBytes, err := renderDocument(ctx, deps, in, "docx")
+	if err != nil {
+		return "", nil, err
+	}
+	return in.name() + ".docx", docBytes, nil
+}
+
+// InvoiceEmailData returns revenue id's data for email templates: its
+// document template data plus "document", the document's title ("Invoice",
+// "Credit Note", ...). A revenue without a reference number is referred to
+// by its ID.
+func InvoiceEmailData(ctx context.Context, deps *InvoiceDownloadDeps, id string) (map[string]any, error) {
+	in, err := loadInvoice(ctx, deps, id)
+	if err != nil {
+		return nil, err
+	}
+	if inv, ok := in.data["invoice"].(map[string]any); ok && inv["reference_number"] == "" {
+		inv["reference_number"] = id
+	}
+	in.data["document"] = in.doc.title
+	return in.data, nil
+}
+
+// RenderStatement renders a customer statement's template data as "docx" or
+// "pdf" through the invoice pipeline: the workspace's "statement" template (or
+// the embedded one) and its PDF engine, with invoicepdf.StatementLayout as the
+// native layout. The data keys are those of the embedded template:
+// statement.*, customer.name, currency, entries, aging and total.
+func RenderStatement(ctx context.Context, deps *InvoiceDownloadDeps, data map[string]any, format string) ([]byte, error) {
+	return renderDocument(ctx, deps, &invoiceInput{doc: statementDocument, data: data}, format)
+}
+
+// loadTemplate loads doc's template. Tries custom default first, falls back to embedded.
+func loadTemplate(ctx context.Context, loadDefault func(context.Context, string) ([]byte, error), doc revenueDocument) ([]byte, error) {
 	// Try custom default template if available
 	if loadDefault != nil {
-		templateBytes, err := loadDefault(ctx, "invoice")
+		templateBytes, err := loadDefault(ctx, doc.purpose)
 		if err == nil && len(templateBytes) > 0 {
 			return templateBytes, nil
 		}
@@ -165,12 +331,48 @@ func loadTemplate(ctx context.Context, loadDefault func(context.Context, string)
 	}
 
 	// Use embedded fallback
-	return invoiceTemplateFS.ReadFile("templates/invoice-template.docx")
+	return invoiceTemplateFS.ReadFile(doc.embedded)
+}
+
+// loadNote returns the note link for a credit/debit note revenue, or nil for
+// invoices and when no note store is wired.
+func loadNote(ctx context.Context, store shared.RevenueNoteStore, revenueID string) *shared.RevenueNote {
+	if store == nil {
+		return nil
+	}
+	note, err := store.ReadRevenueNote(ctx, revenueID)
+	if err != nil {
+		log.Printf("invoice download: failed to read note link for %s: %v", revenueID, err)
+		return nil
+	}
+	return note
+}
+
+// listTaxLines returns a revenue's tax lines, or nil when list is unwired or
+// fails (the document then has no tax rows).
+func listTaxLines(ctx context.Context, list func(context.Context, *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error), revenueID string) []*revenuetaxlinepb.RevenueTaxLine {
+	if list == nil {
+		return nil
+	}
+	resp, err := list(ctx, &revenuetaxlinepb.ListRevenueTaxLinesRequest{RevenueId: &revenueID})
+	if err != nil {
+		log.Printf("invoice download: failed to list tax lines for %s: %v", revenueID, err)
+		return nil
+	}
+	return resp.GetData()
 }
 
 // buildInvoiceData assembles the template data map from revenue + line items.
 // This matches the doctemplate placeholder format: {{invoice.reference_number}}, {{#items}}, etc.
-func buildInvoiceData(revenue *revenuepb.Revenue, lineItems []*revenuelineitempb.RevenueLineItem) map[string]any {
+// Note templates also read {{note.original_reference}} and {{note.reason}}; note
+// is nil for invoices. {{#taxes}} lists label/rate/amount per tax line, with
+// withholding amounts negative.
+func buildInvoiceData(revenue *revenuepb.Revenue, lineItems []*revenuelineitempb.RevenueLineItem, taxLines []*revenuetaxlinepb.RevenueTaxLine, note *shared.RevenueNote) map[string]any {
+	originalReference := ""
+	if note != nil {
+		originalReference = note.OriginalReference
+	}
+
 	// Build line item array
 	items := make([]any, 0, len(lineItems))
 	for _, item := range lineItems {
@@ -182,6 +384,28 @@ func buildInvoiceData(revenue *revenuepb.Revenue, lineItems []*revenuelineitempb
 		})
 	}
 
+	taxes := make([]any, 0, len(taxLines))
+	for _, line := range taxLines {
+		if line == nil {
+			continue
+		}
+		label, amount := line.GetTaxKindSnapshot(), line.GetTaxAmount()
+		if line.GetDirection() == revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING {
+			if label == "" {
+				label = "Withholding Tax"
+			}
+			amount = -amount
+		} else if label == "" {
+			label = "Tax"
+		}
+		rate := fmt.Sprintf("%.2f%%", float64(line.GetRateBasisPointsSnapshot())/100)
+		taxes = append(taxes, map[string]any{
+			"label":  fmt.Sprintf("%s (%s)", label, rate),
+			"rate":   rate,
+			"amount": formatCentavos(amount),
+		})
+	}
+
 	return map[string]any{
 		"invoice": map[string]any{
 			"reference_number": revenue.GetReferenceNumber(),
@@ -194,7 +418,12 @@ func buildInvoiceData(revenue *revenuepb.Revenue, lineItems []*revenuelineitempb
 		"customer": map[string]any{
 			"name": revenue.GetName(),
 		},
+		"note": map[string]any{
+			"original_reference": originalReference,
+			"reason":             revenue.GetNotes(),
+		},
 		"items":    items,
+		"taxes":    taxes,
 		"total":    formatCentavos(revenue.GetTotalAmount()),
 		"currency": revenue.GetCurrency(),
 	}
@@ -0,0 +1,573 @@
+package action
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// Bulk invoice export formats (the "format" form value).
+const (
+	// ExportFormatZip is a ZIP of one PDF per revenue, generated exactly as the
+	// single download is (workspace template and PDF engine).
+	ExportFormatZip = "zip"
+	// ExportFormatZipDocx is a ZIP of one DOCX per revenue.
+	ExportFormatZipDocx = "zip-docx"
+	// ExportFormatMerged is one PDF with every document in order. It is always
+	// drawn by the native renderer with the workspace's PDF layout, since
+	// LibreOffice output cannot be concatenated.
+	ExportFormatMerged = "merged"
+)
+
+// MaxExportDocuments caps a single export; larger filters must be narrowed.
+const MaxExportDocuments = 5000
+
+const (
+	exportRunning = "running"
+	exportReady   = "ready"
+	exportFailed  = "failed"
+
+	// exportTTL is how long an export and its file are kept after it starts.
+	exportTTL = time.Hour
+	// maxExportErrors is how many per-document failures a job lists.
+	maxExportErrors = 10
+	// maxRunningExports is how many exports generate at once; later ones
+	// wait for a slot. maxWorkspaceExports is how many one workspace may
+	// have running or waiting.
+	maxRunningExports   = 4
+	maxWorkspaceExports = 2
+)
+
+// errExportBusy is returned by start when the workspace already has
+// maxWorkspaceExports running.
+var errExportBusy = errors.New("too many exports running")
+
+// InvoiceExportDeps holds dependencies for the bulk invoice export. The
+// embedded download deps are the same pipeline the single download uses.
+type InvoiceExportDeps struct {
+	InvoiceDownloadDeps
+	CommonLabels pyeza.CommonLabels
+
+	// Jobs tracks running and finished exports; one registry must be shared
+	// by the action, progress views and download handler.
+	Jobs *ExportJobs
+
+	// Optional: resolves the list filter posted with scope=filter to revenue
+	// IDs, returning no IDs when more than limit match (nil = selected rows
+	// only). Wraps list.MatchingRevenueIDs.
+	MatchingRevenueIDs func(ctx context.Context, r *http.Request, limit int) (ids []string, total int, err error)
+
+	// Optional: identify who is exporting. A job can only be followed and
+	// downloaded from the workspace and by the user that started it; when
+	// nil, that part of the owner is empty.
+	WorkspaceID func(ctx context.Context) string
+	UserID      func(ctx context.Context) string
+}
+
+// owner returns the workspace and user of the request in ctx.
+func (deps *InvoiceExportDeps) owner(ctx context.Context) (workspaceID, userID string) {
+	if deps.WorkspaceID != nil {
+		workspaceID = deps.WorkspaceID(ctx)
+	}
+	if deps.UserID != nil {
+		userID = deps.UserID(ctx)
+	}
+	return workspaceID, userID
+}
+
+// ownedJob returns the job with id when the requester in ctx started it, or
+// nil, so another user's job reads as not found.
+func (deps *InvoiceExportDeps) ownedJob(ctx context.Context, id string) *exportJob {
+	job := deps.Jobs.get(id)
+	if job == nil {
+		return nil
+	}
+	workspaceID, userID := deps.owner(ctx)
+	if job.workspaceID != workspaceID || job.userID != userID {
+		return nil
+	}
+	return job
+}
+
+// ExportJobs tracks bulk invoice exports in memory. Each job writes its ZIP or
+// PDF to a temporary file as documents are generated, so a batch is never held
+// in memory; jobs and their files are removed an hour after they start. Jobs
+// do not survive a restart. At most maxRunningExports generate at once.
+type ExportJobs struct {
+	dir   string
+	slots chan struct{}
+
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+// NewExportJobs creates an export registry writing under dir ("" = the
+// system temp directory).
+func NewExportJobs(dir string) *ExportJobs {
+	return &ExportJobs{
+		dir:   dir,
+		slots: make(chan struct{}, maxRunningExports),
+		jobs:  map[string]*exportJob{},
+	}
+}
+
+// exportJob is one export. Fields above mu are fixed at creation.
+type exportJob struct {
+	id          string
+	workspaceID string // owner; see InvoiceExportDeps.ownedJob
+	userID      string
+	format      string
+	total       int
+	filename    string // download file name
+	path        string // export file being written
+	created     time.Time
+
+	mu     sync.Mutex
+	state  string
+	done   int
+	failed int
+	errs   []string
+}
+
+// start registers a job for total documents owned by workspaceID and userID
+// and creates its file, removing expired jobs first. It returns errExportBusy
+// when the workspace already has maxWorkspaceExports running.
+func (j *ExportJobs) start(format string, total int, workspaceID, userID string) (*exportJob, *os.File, error) {
+	j.sweep(time.Now())
+	if j.running(workspaceID) >= maxWorkspaceExports {
+		return nil, nil, errExportBusy
+	}
+
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, nil, fmt.Errorf("export job id: %w", err)
+	}
+	f, err := os.CreateTemp(j.dir, "revenue-export-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("export file: %w", err)
+	}
+
+	ext := "zip"
+	if format == ExportFormatMerged {
+		ext = "pdf"
+	}
+	now := time.Now()
+	job := &exportJob{
+		id:          hex.EncodeToString(raw[:]),
+		workspaceID: workspaceID,
+		userID:      userID,
+		format:      format,
+		total:       total,
+		filename:    fmt.Sprintf("invoices-%s.%s", now.Format("20060102-150405"), ext),
+		path:        f.Name(),
+		created:     now,
+		state:       exportRunning,
+	}
+
+	j.mu.Lock()
+	j.jobs[job.id] = job
+	j.mu.Unlock()
+	return job, f, nil
+}
+
+// running counts the workspace's jobs still generating or waiting for a slot.
+func (j *ExportJobs) running(workspaceID string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := 0
+	for _, job := range j.jobs {
+		if job.workspaceID != workspaceID {
+			continue
+		}
+		if state, _, _, _ := job.snapshot(); state == exportRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// get returns a job by ID, or nil if unknown or expired.
+func (j *ExportJobs) get(id string) *exportJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.jobs[id]
+	if job == nil || time.Since(job.created) > exportTTL {
+		return nil
+	}
+	return job
+}
+
+// sweep removes jobs older than exportTTL and their files. A job still
+// running past the TTL keeps writing to an unlinked file, which is released
+// when it finishes.
+func (j *ExportJobs) sweep(now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for id, job := range j.jobs {
+		if now.Sub(job.created) <= exportTTL {
+			continue
+		}
+		if err := os.Remove(job.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("invoice export: failed to remove %s: %v", job.path, err)
+		}
+		delete(j.jobs, id)
+	}
+}
+
+// step records one document as generated, or failed with err.
+func (job *exportJob) step(name string, err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.done++
+	if err != nil {
+		job.failed++
+		if len(job.errs) < maxExportErrors {
+			job.errs = append(job.errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+// finish marks the job ready, or failed when err is set or no document could
+// be generated.
+func (job *exportJob) finish(err error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.state = exportReady
+	if err != nil {
+		job.state = exportFailed
+		job.errs = append(job.errs, err.Error())
+	} else if job.failed == job.done {
+		job.state = exportFailed
+	}
+}
+
+// snapshot returns the job's progress under its lock.
+func (job *exportJob) snapshot() (state string, done, failed int, errs []string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.state, job.done, job.failed, append([]string(nil), job.errs...)
+}
+
+// runExport generates every document for job into f, then closes f. It runs
+// in the background with a context detached from the request, waiting for one
+// of the registry's slots first.
+func runExport(ctx context.Context, deps *InvoiceExportDeps, job *exportJob, f *os.File, ids []string) {
+	deps.Jobs.slots <- struct{}{}
+	defer func() { <-deps.Jobs.slots }()
+
+	w := bufio.NewWriter(f)
+	var err error
+	if job.format == ExportFormatMerged {
+		err = writeMergedExport(ctx, deps, job, w, ids)
+	} else {
+		err = writeZipExport(ctx, deps, job, w, ids)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("invoice export %s: %v", job.id, err)
+	}
+	job.finish(err)
+}
+
+// writeZipExport writes one PDF or DOCX per revenue into a ZIP on w,
+// compressing each entry as soon as it is generated.
+func writeZipExport(ctx context.Context, deps *InvoiceExportDeps, job *exportJob, w *bufio.Writer, ids []string) error {
+	format := "pdf"
+	if job.format == ExportFormatZipDocx {
+		format = "docx"
+	}
+	zw := zip.NewWriter(w)
+	names := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		in, err := loadInvoice(ctx, &deps.InvoiceDownloadDeps, id)
+		if err != nil {
+			job.step(id, err)
+			continue
+		}
+		docBytes, err := renderDocument(ctx, &deps.InvoiceDownloadDeps, in, format)
+		if errors.Is(err, errLibreOfficeMissing) {
+			// Every remaining PDF would fail the same way.
+			return err
+		}
+		if err != nil {
+			job.step(in.name(), err)
+			continue
+		}
+
+		name := in.name()
+		if names[name] {
+			name += "-" + id
+		}
+		names[name] = true
+		entry, err := zw.Create(name + "." + format)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(docBytes); err != nil {
+			return err
+		}
+		job.step(name, nil)
+	}
+	return zw.Close()
+}
+
+// writeMergedExport renders every revenue into a single PDF on w, writing
+// each document's pages as soon as it is laid out.
+func writeMergedExport(ctx context.Context, deps *InvoiceExportDeps, job *exportJob, w *bufio.Writer, ids []string) error {
+	opts := pdfOptions{engine: deps.PDFEngine, loadLayout: deps.LoadPDFLayout}
+	layouts := map[string]*invoicepdf.Layout{} // by template purpose
+	merger := invoicepdf.NewMerger(w)
+	for _, id := range ids {
+		in, err := loadInvoice(ctx, &deps.InvoiceDownloadDeps, id)
+		if err != nil {
+			job.step(id, err)
+			continue
+		}
+		layout, ok := layouts[in.doc.purpose]
+		if !ok {
+			layout = nativeLayout(ctx, opts, in.doc)
+			layouts[in.doc.purpose] = layout
+		}
+		if err := merger.Add(layout, in.data); err != nil {
+			return err
+		}
+		job.step(in.name(), nil)
+	}
+	return merger.Close()
+}
+
+// NewBulkExportAction creates the bulk invoice export action (POST only). It
+// takes the selected "id" values, or with scope=filter every revenue matching
+// the posted list filter, starts the export in the background and answers
+// with a toast linking to its progress page.
+func NewBulkExportAction(deps *InvoiceExportDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "read") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		_ = r.ParseMultipartForm(32 << 20)
+		l := deps.Labels.Export
+
+		format := r.FormValue("format")
+		if format == "" {
+			format = ExportFormatZip
+		}
+		if format != ExportFormatZip && format != ExportFormatZipDocx && format != ExportFormatMerged {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+
+		ids := r.Form["id"]
+		total := len(ids)
+		if r.FormValue("scope") == "filter" && deps.MatchingRevenueIDs != nil {
+			var err error
+			ids, total, err = deps.MatchingRevenueIDs(ctx, r, MaxExportDocuments)
+			if err != nil {
+				log.Printf("invoice export: failed to resolve list filter: %v", err)
+				return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+			}
+			if total == 0 {
+				return view.HTMXError(l.NothingToExport)
+			}
+		}
+		if total == 0 {
+			return view.HTMXError(deps.Labels.Errors.NoIDsProvided)
+		}
+		if total > MaxExportDocuments {
+			return view.HTMXError(fmt.Sprintf(l.TooMany, MaxExportDocuments))
+		}
+
+		workspaceID, userID := deps.owner(ctx)
+		job, f, err := deps.Jobs.start(format, len(ids), workspaceID, userID)
+		if errors.Is(err, errExportBusy) {
+			return view.HTMXError(fmt.Sprintf(l.Busy, maxWorkspaceExports))
+		}
+		if err != nil {
+			log.Printf("invoice export: %v", err)
+			return view.HTMXError(l.Failed)
+		}
+		go runExport(context.WithoutCancel(ctx), deps, job, f, ids)
+
+		toast := map[string]any{
+			"message":     fmt.Sprintf(l.Started, len(ids)),
+			"state":       "info",
+			"duration":    "0",
+			"dismissible": true,
+			"link": map[string]any{
+				"url":   route.ResolveURL(deps.Routes.ExportURL, "job", job.id),
+				"label": l.ViewProgress,
+			},
+		}
+		payload, err := json.Marshal(map[string]any{"pyeza:toast": toast})
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.ViewResult{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"HX-Trigger": string(payload)},
+		}
+	})
+}
+
+// ExportPageData holds the data for the export progress page.
+type ExportPageData struct {
+	types.PageData
+	ContentTemplate string
+	Export          ExportProgress
+}
+
+// ExportProgress is the progress card, rendered on the page and by the
+// status poll.
+type ExportProgress struct {
+	StatusURL     string
+	DownloadURL   string
+	DownloadLabel string
+	State         string
+	StateText     string
+	ProgressText  string
+	FailedText    string
+	Errors        []string
+	Percent       int
+	Running       bool
+	Ready         bool
+}
+
+// NewExportView creates the export progress page.
+func NewExportView(deps *InvoiceExportDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "read") {
+			return view.Forbidden("invoice:read")
+		}
+		job := deps.ownedJob(ctx, viewCtx.Request.PathValue("job"))
+		if job == nil {
+			return view.ViewResult{Error: errors.New(deps.Labels.Errors.NotFound), StatusCode: http.StatusNotFound}
+		}
+
+		l := deps.Labels.Export
+		pageData := &ExportPageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          l.PageTitle,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      "revenue",
+				HeaderTitle:    l.PageTitle,
+				HeaderSubtitle: l.Caption,
+				HeaderIcon:     "icon-download",
+				CommonLabels:   deps.CommonLabels,
+			},
+			ContentTemplate: "revenue-export-content",
+			Export:          exportProgress(deps.Labels, deps.Routes, job),
+		}
+		return view.OK("revenue-export", pageData)
+	})
+}
+
+// NewExportStatusView creates the progress card fragment the page polls while
+// the export runs.
+func NewExportStatusView(deps *InvoiceExportDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "read") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+		job := deps.ownedJob(ctx, viewCtx.Request.PathValue("job"))
+		if job == nil {
+			return view.HTMXError(deps.Labels.Errors.NotFound)
+		}
+		return view.OK("revenue-export-status", exportProgress(deps.Labels, deps.Routes, job))
+	})
+}
+
+func exportProgress(labels revenuedomain.Labels, routes revenuedomain.Routes, job *exportJob) ExportProgress {
+	l := labels.Export
+	state, done, failed, errs := job.snapshot()
+	p := ExportProgress{
+		StatusURL:     route.ResolveURL(routes.ExportStatusURL, "job", job.id),
+		DownloadURL:   route.ResolveURL(routes.ExportDownloadURL, "job", job.id),
+		DownloadLabel: l.Download,
+		State:         state,
+		ProgressText:  fmt.Sprintf(l.Progress, done, job.total),
+		Errors:        errs,
+		Percent:       100,
+		Running:       state == exportRunning,
+		Ready:         state == exportReady,
+	}
+	if job.total > 0 {
+		p.Percent = done * 100 / job.total
+	}
+	switch state {
+	case exportRunning:
+		p.StateText = l.Running
+	case exportReady:
+		p.StateText = l.Ready
+	default:
+		p.StateText = l.Failed
+	}
+	if failed > 0 {
+		p.FailedText = fmt.Sprintf(l.FailedCount, failed)
+	}
+	return p
+}
+
+// NewExportDownloadHandler creates an http.HandlerFunc that streams a
+// finished export file to the user who started it.
+func NewExportDownloadHandler(deps *InvoiceExportDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if !view.GetUserPermissions(ctx).Can("invoice", "read") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		job := deps.ownedJob(ctx, r.PathValue("job"))
+		if job == nil {
+			http.Error(w, "export not found", http.StatusNotFound)
+			return
+		}
+		if state, _, _, _ := job.snapshot(); state != exportReady {
+			http.Error(w, "export not ready", http.StatusConflict)
+			return
+		}
+
+		f, err := os.Open(job.path)
+		if err != nil {
+			log.Printf("invoice export: failed to open %s: %v", job.path, err)
+			http.Error(w, "export not found", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		contentType := "application/zip"
+		if job.format == ExportFormatMerged {
+			contentType = "application/pdf"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, job.filename))
+		http.ServeContent(w, r, job.filename, job.created, f)
+	}
+}
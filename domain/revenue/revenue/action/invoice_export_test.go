@@ -0,0 +1,343 @@
+package action
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/pyeza-golang/view"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+)
+
+// exportTestDeps serves revenues "a" (INV-1), "b" (INV-2) and "cn" (CN-1);
+// any other ID is not found. GenerateDoc echoes the reference number.
+func exportTestDeps(t *testing.T) *InvoiceExportDeps {
+	t.Helper()
+	refs := map[string]string{"a": "INV-1", "b": "INV-2", "cn": "CN-1"}
+
+	labels := testLabels()
+	labels.Export = revenuedomain.ExportLabels{
+		Started:         "Preparing %d documents",
+		ViewProgress:    "View progress",
+		Progress:        "%d of %d",
+		Running:         "Generating",
+		Ready:           "Ready",
+		Failed:          "Failed",
+		FailedCount:     "%d could not be generated",
+		NothingToExport: "Nothing to export",
+		TooMany:         "At most %d documents",
+		Busy:            "At most %d exports at a time",
+	}
+	return &InvoiceExportDeps{
+		InvoiceDownloadDeps: InvoiceDownloadDeps{
+			Routes: revenuedomain.DefaultRoutes(),
+			Labels: labels,
+			ReadRevenue: func(_ context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+				id := req.GetData().GetId()
+				ref, ok := refs[id]
+				if !ok {
+					return &revenuepb.ReadRevenueResponse{}, nil
+				}
+				return &revenuepb.ReadRevenueResponse{Data: []*revenuepb.Revenue{{Id: id, ReferenceNumber: strPtr(ref), Currency: "PHP", TotalAmount: 10000}}}, nil
+			},
+			ListRevenueLineItems: func(_ context.Context, req *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error) {
+				return &revenuelineitempb.ListRevenueLineItemsResponse{Data: []*revenuelineitempb.RevenueLineItem{
+					{RevenueId: req.GetRevenueId(), Description: "Widget", Quantity: 1, UnitPrice: 10000, TotalPrice: 10000},
+				}}, nil
+			},
+			GenerateDoc: func(_ []byte, data map[string]any) ([]byte, error) {
+				return []byte("docx:" + data["invoice"].(map[string]any)["reference_number"].(string)), nil
+			},
+			PDFEngine: func(context.Context) string { return PDFEngineNative },
+		},
+		Jobs: NewExportJobs(t.TempDir()),
+	}
+}
+
+// startExport posts form to the bulk export action and waits for the job to
+// finish, returning its ID.
+func startExport(t *testing.T, deps *InvoiceExportDeps, form url.Values) string {
+	t.Helper()
+	result := NewBulkExportAction(deps).Handle(ctxWithPerms("invoice:read"), &view.ViewContext{
+		Request: postForm(revenuedomain.BulkExportURL, form),
+	})
+	if result.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d (%s), want 200", result.StatusCode, result.Headers["HX-Error-Message"])
+	}
+
+	var trigger struct {
+		Toast struct {
+			Message string `json:"message"`
+			Link    struct {
+				URL string `json:"url"`
+			} `json:"link"`
+		} `json:"pyeza:toast"`
+	}
+	if err := json.Unmarshal([]byte(result.Headers["HX-Trigger"]), &trigger); err != nil {
+		t.Fatalf("HX-Trigger: %v", err)
+	}
+	id := strings.TrimPrefix(trigger.Toast.Link.URL, "/sales/exports/")
+	if id == "" || id == trigger.Toast.Link.URL {
+		t.Fatalf("toast link = %q, want the progress page", trigger.Toast.Link.URL)
+	}
+
+	job := deps.Jobs.get(id)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if state, _, _, _ := job.snapshot(); state != exportRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("export did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return id
+}
+
+// download fetches a finished export through the download handler.
+func download(t *testing.T, deps *InvoiceExportDeps, id string) *httptest.ResponseRecorder {
+	t.Helper()
+	return downloadAs(t, deps, id, ctxWithPerms("invoice:read"))
+}
+
+func downloadAs(t *testing.T, deps *InvoiceExportDeps, id string, ctx context.Context) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/action/revenue/export/"+id+"/download", nil).WithContext(ctx)
+	req.SetPathValue("job", id)
+	rec := httptest.NewRecorder()
+	NewExportDownloadHandler(deps)(rec, req)
+	return rec
+}
+
+func TestBulkExport_ZipDocx(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	id := startExport(t, deps, url.Values{"format": {ExportFormatZipDocx}, "id": {"a", "missing", "cn"}})
+
+	state, done, failed, errs := deps.Jobs.get(id).snapshot()
+	if state != exportReady || done != 3 || failed != 1 {
+		t.Errorf("job = %s %d done %d failed, want ready 3 done 1 failed", state, done, failed)
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "missing:") {
+		t.Errorf("errs = %v, want the missing revenue", errs)
+	}
+
+	rec := download(t, deps, id)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("download = %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	want := map[string]string{"invoice-INV-1.docx": "docx:INV-1", "credit-note-CN-1.docx": "docx:CN-1"}
+	if len(zr.File) != len(want) {
+		t.Errorf("zip has %d entries, want %d", len(zr.File), len(want))
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		if want[f.Name] != string(body) {
+			t.Errorf("%s = %q, want %q", f.Name, body, want[f.Name])
+		}
+	}
+}
+
+func TestBulkExport_ZipMatchesSingleDownload(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	id := startExport(t, deps, url.Values{"format": {ExportFormatZip}, "id": {"b"}})
+
+	rec := download(t, deps, id)
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil || len(zr.File) != 1 || zr.File[0].Name != "invoice-INV-2.pdf" {
+		t.Fatalf("zip = %v, %v; want invoice-INV-2.pdf", zr, err)
+	}
+	rc, _ := zr.File[0].Open()
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+
+	single := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/action/revenue/detail/b/invoice/download", nil)
+	req.SetPathValue("id", "b")
+	NewInvoiceDownloadHandler(&deps.InvoiceDownloadDeps)(single, req)
+	if !bytes.Equal(got, single.Body.Bytes()) {
+		t.Error("exported PDF differs from the single download")
+	}
+}
+
+func TestBulkExport_Merged(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	id := startExport(t, deps, url.Values{"format": {ExportFormatMerged}, "id": {"a", "b", "cn"}})
+
+	rec := download(t, deps, id)
+	if rec.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	pdf := rec.Body.Bytes()
+	if !bytes.Contains(pdf, []byte("/Count 3 >>")) {
+		t.Error("merged PDF does not have 3 pages")
+	}
+	for _, want := range []string{"(INV-1) Tj", "(INV-2) Tj", "(Credit Note) Tj"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("merged PDF missing %s", want)
+		}
+	}
+}
+
+func TestBulkExport_FilterScope(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	var gotLimit int
+	deps.MatchingRevenueIDs = func(_ context.Context, r *http.Request, limit int) ([]string, int, error) {
+		gotLimit = limit
+		if r.FormValue("search") == "huge" {
+			return nil, limit + 1, nil
+		}
+		return []string{"a", "b"}, 2, nil
+	}
+
+	id := startExport(t, deps, url.Values{"scope": {"filter"}, "id": {"ignored"}, "search": {"INV"}})
+	if _, done, failed, _ := deps.Jobs.get(id).snapshot(); done != 2 || failed != 0 {
+		t.Errorf("filter export = %d done %d failed, want 2 and 0", done, failed)
+	}
+	if gotLimit != MaxExportDocuments {
+		t.Errorf("limit = %d, want %d", gotLimit, MaxExportDocuments)
+	}
+
+	result := NewBulkExportAction(deps).Handle(ctxWithPerms("invoice:read"), &view.ViewContext{
+		Request: postForm(revenuedomain.BulkExportURL, url.Values{"scope": {"filter"}, "search": {"huge"}}),
+	})
+	if result.StatusCode != http.StatusUnprocessableEntity || result.Headers["HX-Error-Message"] != "At most 5000 documents" {
+		t.Errorf("oversized filter = %d %q", result.StatusCode, result.Headers["HX-Error-Message"])
+	}
+}
+
+func TestBulkExport_Rejects(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	tests := []struct {
+		name string
+		ctx  context.Context
+		form url.Values
+		want string
+	}{
+		{"no permission", ctxNoPerms(), url.Values{"id": {"a"}}, "Permission denied"},
+		{"no ids", ctxWithPerms("invoice:read"), url.Values{}, "No IDs provided"},
+		{"bad format", ctxWithPerms("invoice:read"), url.Values{"id": {"a"}, "format": {"xlsx"}}, "Invalid form data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewBulkExportAction(deps).Handle(tt.ctx, &view.ViewContext{
+				Request: postForm(revenuedomain.BulkExportURL, tt.form),
+			})
+			if result.Headers["HX-Error-Message"] != tt.want {
+				t.Errorf("HX-Error-Message = %q, want %q", result.Headers["HX-Error-Message"], tt.want)
+			}
+		})
+	}
+
+	if rec := download(t, deps, "unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job download = %d, want 404", rec.Code)
+	}
+}
+
+func TestBulkExport_OwnerOnly(t *testing.T) {
+	t.Parallel()
+
+	deps := exportTestDeps(t)
+	user := "alice"
+	deps.WorkspaceID = func(context.Context) string { return "ws-1" }
+	deps.UserID = func(context.Context) string { return user }
+	id := startExport(t, deps, url.Values{"id": {"a"}})
+
+	if rec := download(t, deps, id); rec.Code != http.StatusOK {
+		t.Errorf("owner download = %d, want 200", rec.Code)
+	}
+	if rec := downloadAs(t, deps, id, ctxNoPerms()); rec.Code != http.StatusForbidden {
+		t.Errorf("download without invoice:read = %d, want 403", rec.Code)
+	}
+
+	user = "bob"
+	if rec := download(t, deps, id); rec.Code != http.StatusNotFound {
+		t.Errorf("other user download = %d, want 404", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/sales/exports/"+id+"/status", nil)
+	req.SetPathValue("job", id)
+	result := NewExportStatusView(deps).Handle(ctxWithPerms("invoice:read"), &view.ViewContext{Request: req})
+	if result.Headers["HX-Error-Message"] != "Not found" {
+		t.Errorf("other user status = %+v, want not found", result)
+	}
+}
+
+func TestBulkExport_WorkspaceLimit(t *testing.T) {
+	t.Parallel()
+
+	jobs := NewExportJobs(t.TempDir())
+	for i := 0; i < maxWorkspaceExports; i++ {
+		if _, f, err := jobs.start(ExportFormatZip, 1, "ws-1", "alice"); err != nil {
+			t.Fatal(err)
+		} else {
+			f.Close()
+		}
+	}
+	if _, _, err := jobs.start(ExportFormatZip, 1, "ws-1", "bob"); !errors.Is(err, errExportBusy) {
+		t.Errorf("over the workspace limit: err = %v, want errExportBusy", err)
+	}
+	if _, f, err := jobs.start(ExportFormatZip, 1, "ws-2", "carol"); err != nil {
+		t.Errorf("other workspace: %v", err)
+	} else {
+		f.Close()
+	}
+}
+
+func TestRenderInvoiceAttachmentAs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	deps := &exportTestDeps(t).InvoiceDownloadDeps
+
+	name, data, err := RenderInvoiceAttachmentAs(ctx, deps, "cn", "docx")
+	if err != nil || name != "credit-note-CN-1.docx" || string(data) != "docx:CN-1" {
+		t.Errorf("docx: %q %q %v", name, data, err)
+	}
+	name, data, err = RenderInvoiceAttachmentAs(ctx, deps, "a", "pdf")
+	if err != nil || name != "invoice-INV-1.pdf" || !bytes.HasPrefix(data, []byte("%PDF")) {
+		t.Errorf("pdf: %q %v", name, err)
+	}
+	if _, _, err := RenderInvoiceAttachmentAs(ctx, deps, "a", "ubl"); !errors.Is(err, errNoSeller) {
+		t.Errorf("ubl without seller: %v", err)
+	}
+	if _, _, err := RenderInvoiceAttachmentAs(ctx, deps, "a", "odt"); err == nil {
+		t.Error("odt: want an error")
+	}
+
+	vars, err := InvoiceEmailData(ctx, deps, "cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["document"] != "Credit Note" || vars["invoice"].(map[string]any)["reference_number"] != "CN-1" {
+		t.Errorf("email data = %v", vars)
+	}
+}
@@ -0,0 +1,90 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+	"github.com/erniealice/fycha-golang/services/pdfconv"
+)
+
+// PDF engines a workspace can select for invoice and note PDFs.
+const (
+	// PDFEngineAuto converts the DOCX with LibreOffice and falls back to the
+	// native renderer when LibreOffice is not installed.
+	PDFEngineAuto = ""
+	// PDFEngineLibreOffice only converts the DOCX; PDFs are unavailable
+	// without LibreOffice.
+	PDFEngineLibreOffice = "libreoffice"
+	// PDFEngineNative always uses the pure-Go renderer (invoicepdf). Custom
+	// DOCX templates do not apply; the workspace's PDF layout does.
+	PDFEngineNative = "native"
+)
+
+// errLibreOfficeMissing is returned by renderPDF when the workspace selected
+// PDFEngineLibreOffice and it is not installed.
+var errLibreOfficeMissing = errors.New("LibreOffice is not installed")
+
+// pdfOptions are the PDF engine settings shared by the download and email
+// handlers.
+type pdfOptions struct {
+	engine     func(ctx context.Context) string
+	loadLayout func(ctx context.Context, purpose string) (*invoicepdf.Layout, error)
+}
+
+// renderPDF produces doc's PDF with the workspace's engine: docBytes is the
+// generated DOCX, invoiceData the map it was generated from.
+func renderPDF(ctx context.Context, opts pdfOptions, doc revenueDocument, docBytes []byte, invoiceData map[string]any) ([]byte, error) {
+	engine := PDFEngineAuto
+	if opts.engine != nil {
+		engine = opts.engine(ctx)
+	}
+	if engine == PDFEngineNative {
+		return renderNativePDF(ctx, opts, doc, invoiceData)
+	}
+
+	pdfBytes, ok, err := pdfconv.ConvertDocxToPDF(docBytes)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return pdfBytes, nil
+	}
+	if engine == PDFEngineLibreOffice {
+		return nil, errLibreOfficeMissing
+	}
+	log.Printf("invoice pdf: LibreOffice not installed, using native renderer")
+	return renderNativePDF(ctx, opts, doc, invoiceData)
+}
+
+// renderNativePDF renders invoiceData with the workspace's layout for doc, or
+// the default layout when none is configured.
+func renderNativePDF(ctx context.Context, opts pdfOptions, doc revenueDocument, invoiceData map[string]any) ([]byte, error) {
+	pdfBytes, err := invoicepdf.Render(nativeLayout(ctx, opts, doc), invoiceData)
+	if err != nil {
+		return nil, fmt.Errorf("render native PDF: %w", err)
+	}
+	return pdfBytes, nil
+}
+
+// nativeLayout returns the workspace's native PDF layout for doc, or the
+// default layout when none is configured or it fails to load.
+func nativeLayout(ctx context.Context, opts pdfOptions, doc revenueDocument) *invoicepdf.Layout {
+	var layout *invoicepdf.Layout
+	if opts.loadLayout != nil {
+		l, err := opts.loadLayout(ctx, doc.purpose)
+		if err != nil {
+			log.Printf("invoice pdf: custom layout load failed (using default): %v", err)
+		}
+		layout = l
+	}
+	if layout == nil && doc.layout != nil {
+		layout = doc.layout()
+	}
+	if layout == nil {
+		layout = invoicepdf.DefaultLayout(doc.title)
+	}
+	return layout
+}
@@ -0,0 +1,88 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+func TestBuildInvoiceData_Taxes(t *testing.T) {
+	t.Parallel()
+
+	data := buildInvoiceData(
+		&revenuepb.Revenue{ReferenceNumber: strPtr("INV-1"), TotalAmount: 11200},
+		[]*revenuelineitempb.RevenueLineItem{{Description: "Widget", Quantity: 1, UnitPrice: 10000, TotalPrice: 10000}},
+		[]*revenuetaxlinepb.RevenueTaxLine{
+			{TaxKindSnapshot: "VAT", RateBasisPointsSnapshot: 1200, TaxAmount: 1200},
+			{Direction: revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING, RateBasisPointsSnapshot: 200, TaxAmount: 200},
+		},
+		nil,
+	)
+
+	taxes, _ := data["taxes"].([]any)
+	if len(taxes) != 2 {
+		t.Fatalf("taxes = %v, want 2 rows", data["taxes"])
+	}
+	want := []map[string]any{
+		{"label": "VAT (12.00%)", "rate": "12.00%", "amount": "12.00"},
+		{"label": "Withholding Tax (2.00%)", "rate": "2.00%", "amount": "-2.00"},
+	}
+	for i, w := range want {
+		got := taxes[i].(map[string]any)
+		for k, v := range w {
+			if got[k] != v {
+				t.Errorf("taxes[%d][%s] = %v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestRenderPDF_NativeEngine(t *testing.T) {
+	t.Parallel()
+
+	revenue := &revenuepb.Revenue{ReferenceNumber: strPtr("CN-000001")}
+	data := buildInvoiceData(revenue, nil, nil, nil)
+	doc := documentFor(revenue)
+	if doc.purpose != shared.RevenueNoteCredit {
+		t.Fatalf("CN-000001 renders as %q, want a credit note", doc.purpose)
+	}
+
+	var gotPurpose string
+	opts := pdfOptions{
+		engine: func(context.Context) string { return PDFEngineNative },
+		loadLayout: func(_ context.Context, purpose string) (*invoicepdf.Layout, error) {
+			gotPurpose = purpose
+			return invoicepdf.DefaultLayout("Workspace Credit Memo"), nil
+		},
+	}
+	pdf, err := renderPDF(context.Background(), opts, doc, nil, data)
+	if err != nil {
+		t.Fatalf("renderPDF: %v", err)
+	}
+	if gotPurpose != doc.purpose {
+		t.Errorf("layout loaded for %q, want %q", gotPurpose, doc.purpose)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("(Workspace Credit Memo) Tj")) {
+		t.Error("expected a PDF drawn with the workspace layout")
+	}
+
+	// A failing layout loader falls back to the default layout.
+	opts.loadLayout = func(context.Context, string) (*invoicepdf.Layout, error) {
+		return nil, errors.New("storage down")
+	}
+	pdf, err = renderPDF(context.Background(), opts, doc, nil, data)
+	if err != nil {
+		t.Fatalf("renderPDF with failing loader: %v", err)
+	}
+	if !bytes.Contains(pdf, []byte("("+doc.title+") Tj")) {
+		t.Errorf("expected the default %q layout", doc.title)
+	}
+}
@@ -0,0 +1,78 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/fycha-golang/services/pdfconv"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+// SampleInvoiceData returns template data for a made-up invoice with two
+// lines, VAT and withholding. It has every key buildInvoiceData provides, so
+// it is both the preview data for templates and the reference their
+// placeholders are checked against (see shared.CheckDocTemplate).
+func SampleInvoiceData() map[string]any {
+	str := func(s string) *string { return &s }
+	revenue := &revenuepb.Revenue{
+		Id:              "sample",
+		Name:            "Sample Customer Inc.",
+		ReferenceNumber: str("INV-SAMPLE"),
+		RevenueDate:     str("2026-01-15"),
+		Status:          "complete",
+		Currency:        "PHP",
+		TotalAmount:     1096000,
+		Notes:           str("Thank you for your business."),
+	}
+	lineItems := []*revenuelineitempb.RevenueLineItem{
+		{RevenueId: "sample", Description: "Consulting services", Quantity: 10, UnitPrice: 75000, TotalPrice: 750000},
+		{RevenueId: "sample", Description: "Support plan", Quantity: 1, UnitPrice: 250000, TotalPrice: 250000},
+	}
+	taxLines := []*revenuetaxlinepb.RevenueTaxLine{
+		{TaxKindSnapshot: "VAT", RateBasisPointsSnapshot: 1200, TaxAmount: 120000},
+		{Direction: revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING, RateBasisPointsSnapshot: 200, TaxAmount: 20000},
+	}
+	note := &shared.RevenueNote{OriginalReference: "INV-000100"}
+	return buildInvoiceData(revenue, lineItems, taxLines, note)
+}
+
+// PreviewInvoiceTemplate generates templateBytes, an uploaded invoice
+// template, as format "pdf" or "docx": with revenue revenueID's data, or
+// SampleInvoiceData when revenueID is blank. The PDF is converted from the
+// DOCX even when the workspace uses the native engine, since that is what is
+// being previewed; without LibreOffice the DOCX is returned instead. It
+// returns the file name and bytes.
+func PreviewInvoiceTemplate(ctx context.Context, deps *InvoiceDownloadDeps, templateBytes []byte, revenueID, format string) (string, []byte, error) {
+	if format != "pdf" && format != "docx" {
+		return "", nil, fmt.Errorf("invalid preview format %q", format)
+	}
+	name, data := "template-preview-sample", SampleInvoiceData()
+	if revenueID != "" {
+		in, err := loadInvoice(ctx, deps, revenueID)
+		if err != nil {
+			return "", nil, err
+		}
+		name, data = "template-preview-"+in.name(), in.data
+	}
+
+	docBytes, err := deps.GenerateDoc(templateBytes, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate document: %w", err)
+	}
+	if format == "pdf" {
+		pdfBytes, ok, err := pdfconv.ConvertDocxToPDF(docBytes)
+		if err != nil {
+			return "", nil, fmt.Errorf("PDF conversion failed: %w", err)
+		}
+		if ok {
+			return name + ".pdf", pdfBytes, nil
+		}
+		log.Printf("template preview: LibreOffice not installed, returning DOCX")
+	}
+	return name + ".docx", docBytes, nil
+}
@@ -0,0 +1,48 @@
+package action
+
+import (
+	"context"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+)
+
+// The embedded templates are the fallback for every workspace, so each must
+// only use placeholders the invoice data provides.
+func TestEmbeddedTemplatesCheck(t *testing.T) {
+	t.Parallel()
+	for _, doc := range revenueDocuments {
+		tpl, err := invoiceTemplateFS.ReadFile(doc.embedded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := shared.CheckDocTemplate(tpl, SampleInvoiceData())
+		if err != nil {
+			t.Fatalf("%s: %v", doc.embedded, err)
+		}
+		if !report.OK() || len(report.Placeholders) == 0 {
+			t.Errorf("%s: placeholders %v, missing %v", doc.embedded, report.Placeholders, report.Missing)
+		}
+	}
+}
+
+func TestPreviewInvoiceTemplate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	deps := &exportTestDeps(t).InvoiceDownloadDeps
+
+	name, data, err := PreviewInvoiceTemplate(ctx, deps, []byte("tpl"), "", "docx")
+	if err != nil || name != "template-preview-sample.docx" || string(data) != "docx:INV-SAMPLE" {
+		t.Errorf("sample: %q %q %v", name, data, err)
+	}
+	name, data, err = PreviewInvoiceTemplate(ctx, deps, []byte("tpl"), "b", "docx")
+	if err != nil || name != "template-preview-invoice-INV-2.docx" || string(data) != "docx:INV-2" {
+		t.Errorf("revenue b: %q %q %v", name, data, err)
+	}
+	if _, _, err := PreviewInvoiceTemplate(ctx, deps, []byte("tpl"), "missing", "docx"); err == nil {
+		t.Error("unknown revenue: want an error")
+	}
+	if _, _, err := PreviewInvoiceTemplate(ctx, deps, []byte("tpl"), "", "ubl"); err == nil {
+		t.Error("ubl: want an error")
+	}
+}
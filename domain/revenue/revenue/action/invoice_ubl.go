@@ -0,0 +1,247 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/ubl"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+// formatUBL is the e-invoice download/attachment format: UBL 2.1 XML per
+// PEPPOL BIS Billing 3.0.
+const formatUBL = "ubl"
+
+// Exemption reasons written for lines that carry no VAT.
+const (
+	reasonExempt     = "Exempt from VAT"
+	reasonNotSubject = "Not subject to VAT"
+)
+
+// errNoSeller is returned by renderUBL when no seller loader is wired.
+var errNoSeller = errors.New("e-invoice export is not configured: no seller party")
+
+// renderUBL writes in as a UBL Invoice (or CreditNote for credit notes) and
+// validates it against the bundled schema and PEPPOL rules. An invalid
+// document returns a *ubl.ValidationError and no bytes, so it is never served.
+func renderUBL(ctx context.Context, loadSeller func(context.Context) (*ubl.Party, error), in *invoiceInput) ([]byte, error) {
+	if loadSeller == nil {
+		return nil, errNoSeller
+	}
+	seller, err := loadSeller(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load seller: %w", err)
+	}
+	if seller == nil {
+		return nil, errNoSeller
+	}
+
+	data, err := ubl.Marshal(buildUBLDocument(in.id, in.revenue, in.lineItems, in.taxLines, in.note, *seller))
+	if err != nil {
+		return nil, err
+	}
+	if err := ubl.Validate(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeUBLError reports a renderUBL failure: the rule violations with 422 so
+// the user can fix the sale, client or workspace data, 503 when the export is
+// not configured, and 500 otherwise.
+func writeUBLError(w http.ResponseWriter, logPrefix string, err error) {
+	log.Printf("%s: e-invoice: %v", logPrefix, err)
+	var verr *ubl.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprintln(w, "The e-invoice failed validation:")
+		for _, v := range verr.Violations {
+			fmt.Fprintf(w, "- %s\n", v)
+		}
+	case errors.Is(err, errNoSeller):
+		http.Error(w, errNoSeller.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "failed to generate e-invoice", http.StatusInternalServerError)
+	}
+}
+
+// buildUBLDocument maps a revenue to a UBL document. Output tax lines apply
+// to their AppliedToLineItemIds (all lines when empty) and are spread over
+// those lines by amount; a covered line is standard rated at the sum of its
+// tax lines' rates. Uncovered lines are exempt when their tax treatment says
+// so and zero rated otherwise, and a revenue with no output tax at all is not
+// subject to VAT. Withholding is the buyer's remittance and is left out. With
+// tax-inclusive pricing the line totals include their tax share, which is
+// taken off to get the net amounts.
+func buildUBLDocument(id string, revenue *revenuepb.Revenue, lineItems []*revenuelineitempb.RevenueLineItem, taxLines []*revenuetaxlinepb.RevenueTaxLine, note *shared.RevenueNote, seller ubl.Party) *ubl.Document {
+	doc := &ubl.Document{
+		TypeCode:  ubl.TypeInvoice,
+		ID:        revenue.GetReferenceNumber(),
+		IssueDate: ublDate(revenue.GetRevenueDate()),
+		DueDate:   ublDate(revenue.GetDueDate()),
+		Currency:  revenue.GetCurrency(),
+		Note:      revenue.GetNotes(),
+		Seller:    seller,
+		Buyer:     ublBuyer(revenue),
+	}
+	if doc.ID == "" {
+		doc.ID = id
+	}
+	if doc.Buyer.CountryCode == "" {
+		// A client without a country code is in the workspace's jurisdiction.
+		doc.Buyer.CountryCode = seller.CountryCode
+	}
+	if doc.IssueDate.IsZero() {
+		doc.IssueDate = time.UnixMilli(revenue.GetDateCreated()).UTC()
+	}
+	if pt := revenue.GetPaymentTerm(); pt != nil {
+		doc.PaymentTerms = pt.GetName()
+	}
+	switch shared.RevenueNoteKindOf(revenue.GetReferenceNumber()) {
+	case shared.RevenueNoteCredit:
+		doc.TypeCode = ubl.TypeCreditNote
+	case shared.RevenueNoteDebit:
+		doc.TypeCode = ubl.TypeDebitNote
+	}
+	if note != nil {
+		doc.BillingReference = note.OriginalReference
+	}
+
+	// Spread each output tax line over the lines it applies to.
+	share := make([]int64, len(lineItems))
+	rate := make([]int32, len(lineItems))
+	covered := make([]bool, len(lineItems))
+	anyTax := false
+	for _, tl := range taxLines {
+		if tl == nil || tl.GetDirection() == revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING {
+			continue
+		}
+		anyTax = true
+		applies := map[string]bool{}
+		for _, lineID := range tl.GetAppliedToLineItemIds() {
+			applies[lineID] = true
+		}
+		var idx []int
+		var base int64
+		for i, item := range lineItems {
+			if len(applies) == 0 || applies[item.GetId()] {
+				idx = append(idx, i)
+				base += item.GetTotalPrice()
+			}
+		}
+		remaining := tl.GetTaxAmount()
+		for n, i := range idx {
+			part := remaining
+			if n < len(idx)-1 && base != 0 {
+				part = tl.GetTaxAmount() * lineItems[i].GetTotalPrice() / base
+			}
+			share[i] += part
+			remaining -= part
+			rate[i] += tl.GetRateBasisPointsSnapshot()
+			covered[i] = true
+		}
+	}
+
+	type group struct {
+		cat string
+		bp  int32
+	}
+	taxes := map[group]int64{}
+	var order []group
+	for i, item := range lineItems {
+		line := ubl.Line{
+			ID:       item.GetId(),
+			Name:     item.GetDescription(),
+			Quantity: item.GetQuantity(),
+			Net:      item.GetTotalPrice(),
+		}
+		if line.Name == "" {
+			line.Name = item.GetProduct().GetName()
+		}
+		if revenue.GetTaxInclusivePricingSnapshot() {
+			line.Net -= share[i]
+		}
+		if line.Net == item.GetUnitPrice()*int64(line.Quantity) && float64(int64(line.Quantity)) == line.Quantity {
+			line.Price = ubl.Amount(item.GetUnitPrice())
+		}
+		switch {
+		case covered[i] && rate[i] > 0:
+			line.Category, line.PercentBP = ubl.CategoryStandard, rate[i]
+		case !anyTax:
+			line.Category = ubl.CategoryNotSubjectTax
+		case strings.Contains(strings.ToLower(item.GetTaxTreatmentSnapshot()), "exempt"):
+			line.Category = ubl.CategoryExempt
+		default:
+			line.Category = ubl.CategoryZeroRated
+		}
+		doc.Lines = append(doc.Lines, line)
+
+		g := group{line.Category, line.PercentBP}
+		if _, ok := taxes[g]; !ok {
+			order = append(order, g)
+		}
+		taxes[g] += share[i]
+	}
+	for _, g := range order {
+		tax := ubl.Tax{Category: g.cat, PercentBP: g.bp, Amount: taxes[g]}
+		switch g.cat {
+		case ubl.CategoryExempt:
+			tax.ExemptionReason = reasonExempt
+		case ubl.CategoryNotSubjectTax:
+			tax.ExemptionReason = reasonNotSubject
+		}
+		doc.Taxes = append(doc.Taxes, tax)
+	}
+	return doc
+}
+
+// ublBuyer maps the revenue's client to the buyer party; a revenue without a
+// client falls back to its customer name.
+func ublBuyer(revenue *revenuepb.Revenue) ubl.Party {
+	c := revenue.GetClient()
+	p := ubl.Party{
+		Name:           c.GetName(),
+		TaxID:          c.GetTaxId(),
+		RegistrationID: c.GetRegistrationNumber(),
+		Street:         c.GetStreetAddress(),
+		City:           c.GetCity(),
+		PostalCode:     c.GetPostalCode(),
+		Region:         c.GetProvince(),
+		CountryCode:    strings.ToUpper(c.GetCountryCode()),
+		Email:          c.GetEmail(),
+	}
+	if p.Name == "" {
+		p.Name = revenue.GetName()
+	}
+	if p.TaxID == "" {
+		p.TaxID = c.GetTin()
+	}
+	if p.CountryCode == "" && len(c.GetCountry()) == 2 {
+		p.CountryCode = strings.ToUpper(c.GetCountry())
+	}
+	if p.Email == "" {
+		p.Email = c.GetUser().GetEmailAddress()
+	}
+	return p
+}
+
+// ublDate reads a YYYY-MM-DD date, ignoring any time part; zero when unset.
+func ublDate(s string) time.Time {
+	const layout = "2006-01-02"
+	if len(s) < len(layout) {
+		return time.Time{}
+	}
+	t, _ := time.Parse(layout, s[:len(layout)])
+	return t
+}
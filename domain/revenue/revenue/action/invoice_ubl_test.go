@@ -0,0 +1,140 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/ubl"
+
+	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+func ublTestSeller(context.Context) (*ubl.Party, error) {
+	return &ubl.Party{Name: "Acme Inc.", EndpointID: "0088:123", EndpointScheme: "0088", TaxID: "123-456-789-000", CountryCode: "PH"}, nil
+}
+
+// ublTestInput is a 12% VAT sale of two lines plus an exempt line, with 2%
+// withholding that the e-invoice leaves out.
+func ublTestInput(ref string, inclusive bool) *invoiceInput {
+	total := func(net int64) int64 {
+		if inclusive {
+			return net * 112 / 100
+		}
+		return net
+	}
+	return &invoiceInput{
+		id: "rv1",
+		revenue: &revenuepb.Revenue{
+			Id: "rv1", ReferenceNumber: strPtr(ref), Currency: "PHP", RevenueDate: strPtr("2026-03-01"),
+			TaxInclusivePricingSnapshot: &inclusive,
+			Client:                      &clientpb.Client{Name: strPtr("Globex"), Email: strPtr("ap@globex.example")},
+		},
+		lineItems: []*revenuelineitempb.RevenueLineItem{
+			{Id: "l1", Description: "Consulting", Quantity: 2, UnitPrice: total(50000), TotalPrice: total(100000)},
+			{Id: "l2", Description: "Support", Quantity: 1, UnitPrice: total(25000), TotalPrice: total(25000)},
+			{Id: "l3", Description: "Seminar", Quantity: 1, UnitPrice: 10000, TotalPrice: 10000, TaxTreatmentSnapshot: strPtr("vat_exempt")},
+		},
+		taxLines: []*revenuetaxlinepb.RevenueTaxLine{
+			{TaxKindSnapshot: "VAT", RateBasisPointsSnapshot: 1200, TaxAmount: 15000, AppliedToLineItemIds: []string{"l1", "l2"}},
+			{Direction: revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING, RateBasisPointsSnapshot: 200, TaxAmount: 2500},
+		},
+	}
+}
+
+func TestRenderUBL(t *testing.T) {
+	t.Parallel()
+
+	for _, inclusive := range []bool{false, true} {
+		data, err := renderUBL(context.Background(), ublTestSeller, ublTestInput("INV-000001", inclusive))
+		if err != nil {
+			t.Fatalf("inclusive=%v: %v", inclusive, err)
+		}
+		for _, want := range []string{
+			`<cbc:TaxAmount currencyID="PHP">150.00</cbc:TaxAmount>`,
+			`<cbc:TaxExclusiveAmount currencyID="PHP">1350.00</cbc:TaxExclusiveAmount>`,
+			`<cbc:PayableAmount currencyID="PHP">1500.00</cbc:PayableAmount>`,
+			`<cbc:TaxExemptionReason>` + reasonExempt + `</cbc:TaxExemptionReason>`,
+			`<cbc:IdentificationCode>PH</cbc:IdentificationCode>`,
+		} {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("inclusive=%v: missing %s", inclusive, want)
+			}
+		}
+	}
+
+	in := ublTestInput("CN-000001", false)
+	in.note = &shared.RevenueNote{OriginalReference: "INV-000001"}
+	data, err := renderUBL(context.Background(), ublTestSeller, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("<CreditNote")) || !bytes.Contains(data, []byte("<cbc:ID>INV-000001</cbc:ID>")) {
+		t.Errorf("credit note:\n%s", data)
+	}
+
+	in = ublTestInput("INV-000002", false)
+	in.taxLines = nil
+	data, err = renderUBL(context.Background(), ublTestSeller, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("<cbc:TaxExemptionReason>"+reasonNotSubject)) || bytes.Contains(data, []byte("PartyTaxScheme")) {
+		t.Errorf("untaxed sale:\n%s", data)
+	}
+}
+
+func TestInvoiceDownloadUBL(t *testing.T) {
+	t.Parallel()
+
+	in := ublTestInput("INV-000001", false)
+	deps := &InvoiceDownloadDeps{
+		ReadRevenue: func(context.Context, *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+			return &revenuepb.ReadRevenueResponse{Data: []*revenuepb.Revenue{in.revenue}}, nil
+		},
+		ListRevenueLineItems: func(context.Context, *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error) {
+			items := make([]*revenuelineitempb.RevenueLineItem, len(in.lineItems))
+			for i, item := range in.lineItems {
+				item.RevenueId = "rv1"
+				items[i] = item
+			}
+			return &revenuelineitempb.ListRevenueLineItemsResponse{Data: items}, nil
+		},
+		ListRevenueTaxLines: func(context.Context, *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error) {
+			return &revenuetaxlinepb.ListRevenueTaxLinesResponse{Data: in.taxLines}, nil
+		},
+	}
+	download := func(deps *InvoiceDownloadDeps) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/invoice/download?format=ubl", nil)
+		req.SetPathValue("id", "rv1")
+		rec := httptest.NewRecorder()
+		NewInvoiceDownloadHandler(deps).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := download(deps); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without seller: status = %d, want 503", rec.Code)
+	}
+
+	deps.LoadSeller = ublTestSeller
+	rec := download(deps)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/xml" {
+		t.Fatalf("status = %d, Content-Type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="invoice-INV-000001.xml"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	in.revenue.Client.Email = nil
+	rec = download(deps)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "PEPPOL-EN16931-R010") {
+		t.Errorf("no buyer endpoint: status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
-	"github.com/erniealice/fycha-golang/services/pdfconv"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+	"github.com/erniealice/centymo-golang/services/ubl"
 
 	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
 	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
 )
 
 // SendEmailDeps holds dependencies for the send-email handler.
@@ -21,10 +25,19 @@ type SendEmailDeps struct {
 	// Revenue operations
 	ReadRevenue          func(ctx context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error)
 	ListRevenueLineItems func(ctx context.Context, req *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error)
+	ListRevenueTaxLines  func(ctx context.Context, req *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error) // optional
 
 	// Document generation
 	GenerateDoc         func(templateData []byte, data map[string]any) ([]byte, error)
 	LoadDefaultTemplate func(ctx context.Context, purpose string) ([]byte, error)
+	Notes               shared.RevenueNoteStore // optional: credit/debit note links
+
+	// Optional: PDF engine and native layout (see InvoiceDownloadDeps)
+	PDFEngine     func(ctx context.Context) string
+	LoadPDFLayout func(ctx context.Context, purpose string) (*invoicepdf.Layout, error)
+
+	// Optional: e-invoice seller party (see InvoiceDownloadDeps)
+	LoadSeller func(ctx context.Context) (*ubl.Party, error)
 
 	// Email sending function (injected from espyna email adapter)
 	SendEmail func(ctx context.Context, to []string, subject, htmlBody, textBody string, attachmentName string, attachmentData []byte) error
@@ -33,7 +46,8 @@ type SendEmailDeps struct {
 // NewSendEmailHandler creates an http.HandlerFunc that generates an invoice and emails it.
 //
 // Query parameters:
-//   - format: "pdf" (default) or "docx" — controls the attachment file format.
+//   - format: "pdf" (default), "docx" or "ubl" — controls the attachment file
+//     format; "ubl" attaches the validated PEPPOL BIS e-invoice XML.
 func NewSendEmailHandler(deps *SendEmailDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -48,8 +62,8 @@ func NewSendEmailHandler(deps *SendEmailDeps) http.HandlerFunc {
 		if format == "" {
 			format = "pdf"
 		}
-		if format != "pdf" && format != "docx" {
-			http.Error(w, "invalid format: must be \"pdf\" or \"docx\"", http.StatusBadRequest)
+		if format != "pdf" && format != "docx" && format != formatUBL {
+			http.Error(w, "invalid format: must be \"pdf\", \"docx\" or \"ubl\"", http.StatusBadRequest)
 			return
 		}
 
@@ -107,47 +121,37 @@ func NewSendEmailHandler(deps *SendEmailDeps) http.HandlerFunc {
 		}
 
 		// 4. Build invoice data and generate document
-		invoiceData := buildInvoiceData(revenue, lineItems)
-		templateBytes, err := loadTemplate(ctx, deps.LoadDefaultTemplate)
-		if err != nil {
-			log.Printf("send-email: failed to load template: %v", err)
-			http.Error(w, "failed to load template", http.StatusInternalServerError)
-			return
-		}
-		docBytes, err := deps.GenerateDoc(templateBytes, invoiceData)
-		if err != nil {
-			log.Printf("send-email: failed to generate document: %v", err)
-			http.Error(w, "failed to generate invoice", http.StatusInternalServerError)
-			return
-		}
+		doc := documentFor(revenue)
+		taxLines := listTaxLines(ctx, deps.ListRevenueTaxLines, id)
+		note := loadNote(ctx, deps.Notes, id)
 
 		// 5. Prepare attachment in requested format
 		var attachmentBytes []byte
 		var attachmentName string
 
-		if format == "pdf" {
-			pdfBytes, ok, convErr := pdfconv.ConvertDocxToPDF(docBytes)
-			if convErr != nil {
-				log.Printf("send-email: PDF conversion failed, attaching DOCX: %v", convErr)
-				attachmentBytes = docBytes
-				attachmentName = fmt.Sprintf("invoice-%s.docx", refNumber)
-			} else if ok {
-				attachmentBytes = pdfBytes
-				attachmentName = fmt.Sprintf("invoice-%s.pdf", refNumber)
-			} else {
-				log.Printf("send-email: LibreOffice not installed, attaching DOCX")
-				attachmentBytes = docBytes
-				attachmentName = fmt.Sprintf("invoice-%s.docx", refNumber)
+		if format == formatUBL {
+			in := &invoiceInput{id: id, revenue: revenue, doc: doc, lineItems: lineItems, taxLines: taxLines, note: note}
+			xmlBytes, err := renderUBL(ctx, deps.LoadSeller, in)
+			if err != nil {
+				writeUBLError(w, "send-email", err)
+				return
 			}
+			attachmentBytes = xmlBytes
+			attachmentName = fmt.Sprintf("%s-%s.xml", doc.filename, refNumber)
 		} else {
-			attachmentBytes = docBytes
-			attachmentName = fmt.Sprintf("invoice-%s.docx", refNumber)
+			attachmentName, attachmentBytes, err = renderEmailAttachment(ctx, deps, doc, buildInvoiceData(revenue, lineItems, taxLines, note), refNumber, format)
+			if err != nil {
+				log.Printf("send-email: %v", err)
+				http.Error(w, "failed to generate invoice", http.StatusInternalServerError)
+				return
+			}
 		}
 
 		// 6. Send email with invoice attachment
-		subject := fmt.Sprintf("Invoice %s", refNumber)
-		textBody := fmt.Sprintf("Dear %s,\n\nPlease find attached your invoice %s.\n\nThank you for your business.", customerName, refNumber)
-		htmlBody := fmt.Sprintf("<p>Dear %s,</p><p>Please find attached your invoice <strong>%s</strong>.</p><p>Thank you for your business.</p>", customerName, refNumber)
+		subject := fmt.Sprintf("%s %s", doc.title, refNumber)
+		noun := strings.ToLower(doc.title)
+		textBody := fmt.Sprintf("Dear %s,\n\nPlease find attached your %s %s.\n\nThank you for your business.", customerName, noun, refNumber)
+		htmlBody := fmt.Sprintf("<p>Dear %s,</p><p>Please find attached your %s <strong>%s</strong>.</p><p>Thank you for your business.</p>", customerName, noun, refNumber)
 
 		err = deps.SendEmail(ctx, []string{customerEmail}, subject, htmlBody, textBody, attachmentName, attachmentBytes)
 		if err != nil {
@@ -165,3 +169,27 @@ func NewSendEmailHandler(deps *SendEmailDeps) http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// renderEmailAttachment generates the DOCX for data and, for format "pdf",
+// converts it, attaching the DOCX when the PDF cannot be produced.
+func renderEmailAttachment(ctx context.Context, deps *SendEmailDeps, doc revenueDocument, data map[string]any, refNumber, format string) (string, []byte, error) {
+	templateBytes, err := loadTemplate(ctx, deps.LoadDefaultTemplate, doc)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	docBytes, err := deps.GenerateDoc(templateBytes, data)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate document: %w", err)
+	}
+	docxName := fmt.Sprintf("%s-%s.docx", doc.filename, refNumber)
+	if format != "pdf" {
+		return docxName, docBytes, nil
+	}
+	pdfOpts := pdfOptions{engine: deps.PDFEngine, loadLayout: deps.LoadPDFLayout}
+	pdfBytes, err := renderPDF(ctx, pdfOpts, doc, docBytes, data)
+	if err != nil {
+		log.Printf("send-email: PDF generation failed, attaching DOCX: %v", err)
+		return docxName, docBytes, nil
+	}
+	return fmt.Sprintf("%s-%s.pdf", doc.filename, refNumber), pdfBytes, nil
+}
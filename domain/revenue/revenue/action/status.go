@@ -0,0 +1,141 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+// NewStatusMachine returns the default revenue status machine with the
+// invoice business rules registered against deps:
+//
+//   - D20 guard: block completion with zero line items
+//   - D21 guard: block cancellation if payments exist
+//   - D5 hook: deduct stock on completion
+//   - D6 hook: release serials on cancellation
+//   - FX hook: snapshot the exchange rate of a foreign-currency revenue when
+//     it is issued (completed or paid), when deps.SnapshotFXRate is set
+//
+// Consumers add workspace states, transitions and hooks to the returned
+// machine before the actions serve requests.
+func NewStatusMachine(deps *Deps) *shared.RevenueStatusMachine {
+	m := shared.NewRevenueStatusMachine()
+
+	m.AddGuard("D20 line items required", shared.AnyRevenueStatus, shared.RevenueStatusComplete, func(ctx context.Context, t shared.RevenueTransition) error {
+		lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, t.RevenueID)
+		if err != nil {
+			return fmt.Errorf("list line items for sale %s: %w", t.RevenueID, err)
+		}
+		if len(lineItems) == 0 {
+			return shared.ErrRevenueNoLineItems
+		}
+		return nil
+	})
+	m.AddGuard("D21 no payments", shared.AnyRevenueStatus, shared.RevenueStatusCancelled, func(ctx context.Context, t shared.RevenueTransition) error {
+		payments, err := getPaymentsForRevenue(ctx, deps.ListRevenuePayments, t.RevenueID)
+		if err != nil {
+			return fmt.Errorf("list payments for sale %s: %w", t.RevenueID, err)
+		}
+		if len(payments) > 0 {
+			return shared.ErrRevenueHasPayments
+		}
+		return nil
+	})
+
+	m.AddHook("D5 deduct stock", shared.AnyRevenueStatus, shared.RevenueStatusComplete, func(ctx context.Context, t shared.RevenueTransition) error {
+		lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, t.RevenueID)
+		if err != nil {
+			return fmt.Errorf("list line items for stock deduction: %w", err)
+		}
+		deductStockForLineItems(ctx, deps, t.RevenueID, lineItems)
+		return nil
+	})
+	m.AddHook("D6 release serials", shared.AnyRevenueStatus, shared.RevenueStatusCancelled, func(ctx context.Context, t shared.RevenueTransition) error {
+		lineItems, err := getLineItemsForRevenueTyped(ctx, deps.ListRevenueLineItems, t.RevenueID)
+		if err != nil {
+			return fmt.Errorf("list line items for serial release: %w", err)
+		}
+		releaseSerialsForLineItems(ctx, deps, t.RevenueID, lineItems)
+		return nil
+	})
+	if deps.SnapshotFXRate != nil {
+		snapshot := func(ctx context.Context, t shared.RevenueTransition) error {
+			return snapshotRevenueFX(ctx, deps, t)
+		}
+		m.AddHook("FX rate snapshot", shared.AnyRevenueStatus, shared.RevenueStatusComplete, snapshot)
+		m.AddHook("FX rate snapshot", shared.AnyRevenueStatus, shared.RevenueStatusPaid, snapshot)
+	}
+
+	return m
+}
+
+// snapshotRevenueFX snapshots the rate of the transitioned revenue's currency
+// on its revenue date (today when unset). The record comes from the
+// transition, or is read when the transition carries none.
+func snapshotRevenueFX(ctx context.Context, deps *Deps, t shared.RevenueTransition) error {
+	rv := t.Revenue
+	if rv == nil && deps.ReadRevenue != nil {
+		resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: t.RevenueID}})
+		if err != nil {
+			return fmt.Errorf("read sale %s for FX snapshot: %w", t.RevenueID, err)
+		}
+		if data := resp.GetData(); len(data) > 0 {
+			rv = data[0]
+		}
+	}
+	if rv == nil {
+		return nil
+	}
+	on := time.Now()
+	if d := rv.GetRevenueDate(); len(d) >= len("2006-01-02") {
+		if parsed, err := time.Parse("2006-01-02", d[:len("2006-01-02")]); err == nil {
+			on = parsed
+		}
+	}
+	return deps.SnapshotFXRate(ctx, t.RevenueID, rv.GetCurrency(), on)
+}
+
+// statusMachine returns deps.StatusMachine, or the default machine when unset.
+func statusMachine(deps *Deps) *shared.RevenueStatusMachine {
+	if deps.StatusMachine != nil {
+		return deps.StatusMachine
+	}
+	return NewStatusMachine(deps)
+}
+
+// revenueTransition builds the transition of revenue id to status, reading the
+// current record when ReadRevenue is wired. A failed read leaves From empty so
+// only the target and guards are checked.
+func revenueTransition(ctx context.Context, deps *Deps, id, status string) shared.RevenueTransition {
+	t := shared.RevenueTransition{RevenueID: id, To: status}
+	if deps.ReadRevenue == nil {
+		return t
+	}
+	resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{
+		Data: &revenuepb.Revenue{Id: id},
+	})
+	if err != nil {
+		log.Printf("Failed to read sale %s for status change: %v", id, err)
+		return t
+	}
+	if data := resp.GetData(); len(data) > 0 {
+		t.Revenue = data[0]
+		t.From = data[0].GetStatus()
+	}
+	return t
+}
+
+// updateRevenueStatus stores a transition's target status.
+func updateRevenueStatus(deps *Deps) func(ctx context.Context, t shared.RevenueTransition) error {
+	return func(ctx context.Context, t shared.RevenueTransition) error {
+		_, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+			Data: &revenuepb.Revenue{Id: t.RevenueID, Status: t.To},
+		})
+		return err
+	}
+}
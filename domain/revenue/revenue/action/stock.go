@@ -0,0 +1,97 @@
+package action
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryserialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	serialhistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+)
+
+// deductStockForLineItems decrements inventory quantities and marks serials as sold.
+// Quantities move through an atomic delta (shared.AdjustInventoryQuantity) so two
+// sales completing at once cannot overwrite each other's deduction.
+func deductStockForLineItems(ctx context.Context, deps *Deps, saleID string, lineItems []map[string]any) {
+	adjust := shared.InventoryAdjuster(deps.AdjustInventoryQuantity, deps.ReadInventoryItem, deps.UpdateInventoryItem)
+	for _, item := range lineItems {
+		inventoryItemID, _ := item["inventory_item_id"].(string)
+		serialID, _ := item["inventory_serial_id"].(string)
+
+		// Deduct quantity from inventory item
+		if inventoryItemID != "" && adjust != nil {
+			lineQtyStr, _ := item["quantity"].(string)
+			lineQty, _ := strconv.ParseFloat(lineQtyStr, 64)
+
+			if _, err := adjust(ctx, shared.InventoryDelta{
+				InventoryItemID: inventoryItemID,
+				OnHand:          -lineQty,
+			}); err != nil {
+				log.Printf("Failed to deduct stock for inventory item %s: %v", inventoryItemID, err)
+			}
+		}
+
+		// Mark serial as sold and create history
+		if serialID != "" {
+			if _, err := deps.UpdateInventorySerial(ctx, &inventoryserialpb.UpdateInventorySerialRequest{
+				Data: &inventoryserialpb.InventorySerial{
+					Id:     serialID,
+					Status: "sold",
+				},
+			}); err != nil {
+				log.Printf("Failed to mark serial %s as sold: %v", serialID, err)
+			}
+
+			if _, err := deps.CreateInventorySerialHistory(ctx, &serialhistorypb.CreateInventorySerialHistoryRequest{
+				Data: &serialhistorypb.InventorySerialHistory{
+					InventorySerialId: serialID,
+					InventoryItemId:   inventoryItemID,
+					FromStatus:        "reserved",
+					ToStatus:          "sold",
+					ReferenceType:     "revenue",
+					ReferenceId:       saleID,
+					Notes:             "Auto: sale completed",
+				},
+			}); err != nil {
+				log.Printf("Failed to create serial history for %s: %v", serialID, err)
+			}
+		}
+	}
+}
+
+// releaseSerialsForLineItems marks serials as available and creates history records.
+func releaseSerialsForLineItems(ctx context.Context, deps *Deps, saleID string, lineItems []map[string]any) {
+	for _, item := range lineItems {
+		serialID, _ := item["inventory_serial_id"].(string)
+		if serialID == "" {
+			continue
+		}
+
+		inventoryItemID, _ := item["inventory_item_id"].(string)
+
+		if _, err := deps.UpdateInventorySerial(ctx, &inventoryserialpb.UpdateInventorySerialRequest{
+			Data: &inventoryserialpb.InventorySerial{
+				Id:     serialID,
+				Status: "available",
+			},
+		}); err != nil {
+			log.Printf("Failed to release serial %s: %v", serialID, err)
+		}
+
+		if _, err := deps.CreateInventorySerialHistory(ctx, &serialhistorypb.CreateInventorySerialHistoryRequest{
+			Data: &serialhistorypb.InventorySerialHistory{
+				InventorySerialId: serialID,
+				InventoryItemId:   inventoryItemID,
+				FromStatus:        "available",
+				ToStatus:          "available",
+				ReferenceType:     "revenue",
+				ReferenceId:       saleID,
+				Notes:             "Auto: sale cancelled",
+			},
+		}); err != nil {
+			log.Printf("Failed to create serial history for %s: %v", serialID, err)
+		}
+	}
+}
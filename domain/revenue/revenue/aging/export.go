@@ -0,0 +1,127 @@
+package aging
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/xlsx"
+
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// Export formats accepted by the export handler's ?format=.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// NewExportHandler creates an http.HandlerFunc that downloads the aging
+// report for the same as_of and buckets as the page. CSV holds one row per
+// open invoice; XLSX adds a client summary sheet in front of it. ?client=
+// limits the export to one client's invoices.
+func NewExportHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		l := deps.Labels.Aging
+
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			http.Error(w, deps.Labels.Errors.PermissionDenied, http.StatusForbidden)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatCSV
+		}
+		if format != FormatCSV && format != FormatXLSX {
+			http.Error(w, l.InvalidFormat, http.StatusUnprocessableEntity)
+			return
+		}
+		p, errMsg := parseParams(r, deps)
+		if errMsg != "" {
+			http.Error(w, errMsg, http.StatusUnprocessableEntity)
+			return
+		}
+
+		report, err := loadReport(ctx, deps, p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		clients := report.Clients
+		if clientID := r.URL.Query().Get("client"); clientID != "" {
+			clients = report.Client(clientID)
+		}
+
+		buckets := BucketLabels(l, p.limits)
+		detailHeader := []string{l.Client, l.Currency, l.Reference, l.InvoiceDate, l.DueDate, l.DaysOverdue, l.Bucket, l.Amount, l.Paid, l.Adjusted, l.Balance}
+		filename := "ar-aging-" + p.asOf.Format(dateLayout)
+
+		var buf bytes.Buffer
+		contentType := "text/csv; charset=utf-8"
+		if format == FormatCSV {
+			cw := csv.NewWriter(&buf)
+			_ = cw.Write(detailHeader)
+			for _, c := range clients {
+				for _, line := range c.Lines {
+					row := detailRow(c, line, buckets[line.Bucket])
+					record := make([]string, len(row))
+					for i, v := range row {
+						if n, ok := v.(float64); ok {
+							v = strconv.FormatFloat(n, 'f', 2, 64)
+						}
+						record[i] = fmt.Sprint(v)
+					}
+					_ = cw.Write(record)
+				}
+			}
+			cw.Flush()
+			err = cw.Error()
+		} else {
+			contentType = xlsx.ContentType
+			summary := xlsx.Sheet{Name: l.Summary, Header: append(append([]string{l.Client, l.Currency, l.Invoices}, buckets...), l.Total)}
+			detail := xlsx.Sheet{Name: l.Detail, Header: detailHeader}
+			for _, c := range clients {
+				row := []any{c.ClientName, c.Currency, len(c.Lines)}
+				for _, amount := range c.Buckets {
+					row = append(row, units(amount))
+				}
+				summary.Rows = append(summary.Rows, append(row, units(c.Total)))
+				for _, line := range c.Lines {
+					detail.Rows = append(detail.Rows, detailRow(c, line, buckets[line.Bucket]))
+				}
+			}
+			err = xlsx.Write(&buf, summary, detail)
+		}
+		if err != nil {
+			log.Printf("Failed to write aging export: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// detailRow is one invoice line of the export; amounts are in currency units.
+func detailRow(c *shared.AgingClient, line *shared.AgingLine, bucket string) []any {
+	return []any{
+		c.ClientName, c.Currency, line.Reference,
+		line.InvoiceDate.Format(dateLayout), line.DueDate.Format(dateLayout),
+		max(line.DaysOverdue, 0), bucket,
+		units(line.Amount), units(line.Paid), units(line.Adjusted), units(line.Balance),
+	}
+}
+
+// units converts centavos to currency units for the spreadsheet.
+func units(centavos int64) float64 {
+	return float64(centavos) / 100
+}
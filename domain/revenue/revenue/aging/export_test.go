@@ -0,0 +1,135 @@
+package aging
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+)
+
+func strPtr(s string) *string { return &s }
+func int64Ptr(n int64) *int64 { return &n }
+
+func testDeps() *Deps {
+	return &Deps{
+		Routes: revenuedomain.DefaultRoutes(),
+		Labels: revenuedomain.Labels{
+			Errors: revenuedomain.ErrorLabels{PermissionDenied: "Missing permission"},
+			Aging: revenuedomain.AgingLabels{
+				Client: "Client", Currency: "Currency", Reference: "Reference",
+				InvoiceDate: "Invoice date", DueDate: "Due date", DaysOverdue: "Days overdue",
+				Bucket: "Bucket", Amount: "Amount", Paid: "Paid", Adjusted: "Adjusted", Balance: "Balance",
+				Current: "Current", Range: "%d-%d days", Over: "%d+ days",
+				InvalidAsOf: "Invalid as-of date", InvalidBuckets: "Invalid buckets", InvalidFormat: "Invalid format",
+			},
+		},
+		GetListPageData: func(ctx context.Context, req *revenuepb.GetRevenueListPageDataRequest) (*revenuepb.GetRevenueListPageDataResponse, error) {
+			return &revenuepb.GetRevenueListPageDataResponse{RevenueList: []*revenuepb.Revenue{
+				{Id: "r1", ClientId: "c1", Name: "Acme", Currency: "PHP", TotalAmount: 100000,
+					ReferenceNumber: strPtr("INV-1"), RevenueDate: strPtr("2026-01-01"), DueDate: strPtr("2026-01-31")},
+				{Id: "r2", ClientId: "c2", Name: "Bolt", Currency: "PHP", TotalAmount: 50000,
+					ReferenceNumber: strPtr("INV-2"), RevenueDate: strPtr("2026-03-01"), DueDate: strPtr("2026-03-31")},
+			}}, nil
+		},
+		ListRevenuePayments: func(ctx context.Context, req *revenuepaymentpb.ListRevenuePaymentsRequest) (*revenuepaymentpb.ListRevenuePaymentsResponse, error) {
+			return &revenuepaymentpb.ListRevenuePaymentsResponse{Data: []*revenuepaymentpb.RevenuePayment{
+				{RevenueId: "r1", Amount: 40000, PaymentDate: strPtr("2026-02-10")},
+				// Recorded after the as-of date: ignored.
+				{RevenueId: "r1", Amount: 60000, PaymentDate: strPtr("2026-04-15")},
+				{RevenueId: "r2", Amount: 50000, Status: strPtr("voided"), DateCreated: int64Ptr(1772323200000)},
+			}}, nil
+		},
+	}
+}
+
+func ctxWithPerms(codes ...string) context.Context {
+	return view.WithUserPermissions(context.Background(), types.NewUserPermissions(codes))
+}
+
+func TestNewExportHandler_CSV(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/action/revenue/aging/export?format=csv&as_of=2026-03-31", nil)
+	req = req.WithContext(ctxWithPerms("invoice:list"))
+	rec := httptest.NewRecorder()
+	NewExportHandler(testDeps())(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="ar-aging-2026-03-31.csv"`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want header + 2: %v", len(records), records)
+	}
+	// Acme: 1,000.00 less the February payment, 59 days overdue.
+	want := []string{"Acme", "PHP", "INV-1", "2026-01-01", "2026-01-31", "59", "31-60 days", "1000.00", "400.00", "0.00", "600.00"}
+	if got := records[1]; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Acme row = %v, want %v", got, want)
+	}
+	// Bolt: the voided payment does not count and the invoice is due today.
+	if got := records[2]; got[0] != "Bolt" || got[6] != "Current" || got[10] != "500.00" {
+		t.Errorf("Bolt row = %v", got)
+	}
+}
+
+func TestNewExportHandler_ClientFilterAndXLSX(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/action/revenue/aging/export?format=xlsx&as_of=2026-03-31&client=c2", nil)
+	req = req.WithContext(ctxWithPerms("invoice:list"))
+	rec := httptest.NewRecorder()
+	NewExportHandler(testDeps())(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.HasPrefix(rec.Body.String(), "PK") {
+		t.Error("body is not a zip archive")
+	}
+}
+
+func TestNewExportHandler_Rejects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		code  int
+		body  string
+	}{
+		{"no permission", ctxWithPerms(), "", http.StatusForbidden, "Missing permission"},
+		{"bad format", ctxWithPerms("invoice:list"), "?format=pdf", http.StatusUnprocessableEntity, "Invalid format"},
+		{"bad as-of", ctxWithPerms("invoice:list"), "?as_of=31/03/2026", http.StatusUnprocessableEntity, "Invalid as-of date"},
+		{"bad buckets", ctxWithPerms("invoice:list"), "?buckets=60,30", http.StatusUnprocessableEntity, "Invalid buckets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/action/revenue/aging/export"+tt.query, nil).WithContext(tt.ctx)
+			rec := httptest.NewRecorder()
+			NewExportHandler(testDeps())(rec, req)
+			if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), tt.code, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,595 @@
+// Package aging owns the accounts receivable aging report: the per-client
+// summary page, the invoice drill-down for one client and the CSV/XLSX
+// export. Every view takes ?as_of=YYYY-MM-DD (default today) and
+// ?buckets=30,60,90 (default shared.DefaultAgingLimits), so a month-end report
+// can be reproduced later from the same parameters.
+package aging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	commonpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+)
+
+// dateLayout is the as_of query format and the stored revenue date format.
+const dateLayout = "2006-01-02"
+
+// pageSize is how many revenues loadReport reads per list page.
+const pageSize = 100
+
+// Deps holds dependencies for the aging views. Notes is optional: without it
+// credit and debit notes are left out of the balances.
+type Deps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	GetListPageData     func(ctx context.Context, req *revenuepb.GetRevenueListPageDataRequest) (*revenuepb.GetRevenueListPageDataResponse, error)
+	ListRevenuePayments func(ctx context.Context, req *revenuepaymentpb.ListRevenuePaymentsRequest) (*revenuepaymentpb.ListRevenuePaymentsResponse, error)
+	ListPaymentTerms    func(ctx context.Context) ([]*form.PaymentTermOption, error)
+	Notes               shared.RevenueNoteStore
+
+	// Now returns the current time for the default as-of date (nil =
+	// time.Now).
+	Now func() time.Time
+}
+
+// PageData holds the data for the aging summary and client pages.
+type PageData struct {
+	types.PageData
+	ContentTemplate string
+	Labels          revenuedomain.AgingLabels
+	AsOf            string
+	Buckets         string
+	Error           string
+	FilterURL       string
+	ExportURL       string
+	BackURL         string
+	ClientID        string // drill-down only; scopes the export
+	Table           *types.TableConfig
+}
+
+// params are the report parameters shared by every aging view.
+type params struct {
+	asOf   time.Time
+	limits shared.AgingBuckets
+}
+
+// query returns the ?as_of=&buckets= string that reproduces p.
+func (p params) query() string {
+	v := url.Values{"as_of": {p.asOf.Format(dateLayout)}, "buckets": {p.limits.String()}}
+	return "?" + v.Encode()
+}
+
+// parseParams reads as_of and buckets from r, defaulting to today and the
+// standard buckets. On error it still returns the defaults with the label of
+// the offending parameter.
+func parseParams(r *http.Request, deps *Deps) (params, string) {
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+	t := now()
+	p := params{asOf: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), limits: shared.DefaultAgingLimits}
+
+	l := deps.Labels.Aging
+	if s := r.URL.Query().Get("as_of"); s != "" {
+		asOf, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return p, l.InvalidAsOf
+		}
+		p.asOf = asOf
+	}
+	limits, err := shared.ParseAgingBuckets(r.URL.Query().Get("buckets"))
+	if err != nil {
+		return p, l.InvalidBuckets
+	}
+	p.limits = limits
+	return p, ""
+}
+
+// NewView creates the aging summary page: one row per client and currency
+// with the balance in each bucket.
+func NewView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		report, err := loadReport(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.Aging
+		pageData := newPageData(deps, viewCtx, p, errMsg, l.PageTitle)
+		pageData.FilterURL = deps.Routes.AgingURL
+		pageData.Table = buildSummaryTable(deps, report, p)
+		return view.OK("revenue-aging", pageData)
+	})
+}
+
+// NewTableView returns only the summary table-card HTML.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		if errMsg != "" {
+			return view.HTMXError(errMsg)
+		}
+		report, err := loadReport(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", buildSummaryTable(deps, report, p))
+	})
+}
+
+// NewClientView creates the drill-down page listing one client's open
+// invoices at the same as-of date and buckets.
+func NewClientView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		clientID := viewCtx.Request.PathValue("id")
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		report, err := loadReport(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+		groups := report.Client(clientID)
+
+		l := deps.Labels.Aging
+		title := clientID
+		if len(groups) > 0 {
+			title = groups[0].ClientName
+		}
+		pageData := newPageData(deps, viewCtx, p, errMsg, fmt.Sprintf(l.ClientTitle, title))
+		pageData.FilterURL = route.ResolveURL(deps.Routes.AgingClientURL, "id", url.PathEscape(clientID))
+		pageData.BackURL = deps.Routes.AgingURL + p.query()
+		pageData.ClientID = clientID
+		pageData.Table = buildClientTable(deps, groups, p)
+		return view.OK("revenue-aging", pageData)
+	})
+}
+
+func newPageData(deps *Deps, viewCtx *view.ViewContext, p params, errMsg, title string) *PageData {
+	l := deps.Labels.Aging
+	return &PageData{
+		PageData: types.PageData{
+			CacheVersion:   viewCtx.CacheVersion,
+			Title:          title,
+			CurrentPath:    viewCtx.CurrentPath,
+			ActiveNav:      "revenue",
+			ActiveSubNav:   "aging",
+			HeaderTitle:    title,
+			HeaderSubtitle: fmt.Sprintf(l.Caption, p.asOf.Format(types.DateReadable)),
+			HeaderIcon:     "icon-clock",
+			CommonLabels:   deps.CommonLabels,
+		},
+		ContentTemplate: "revenue-aging-content",
+		Labels:          l,
+		AsOf:            p.asOf.Format(dateLayout),
+		Buckets:         p.limits.String(),
+		Error:           errMsg,
+		ExportURL:       deps.Routes.AgingExportURL,
+	}
+}
+
+// LoadReport ages every open invoice at asOf with the given bucket limits —
+// the report behind the aging views, for callers such as the dunning runner
+// that act on open balances.
+func LoadReport(ctx context.Context, deps *Deps, asOf time.Time, limits shared.AgingBuckets) (*shared.AgingReport, error) {
+	return loadReport(ctx, deps, params{asOf: asOf, limits: limits})
+}
+
+// LoadOpenInvoices returns clientID's invoices in currency with a balance
+// due at asOf — the invoices a collection from that client can be applied to.
+func LoadOpenInvoices(ctx context.Context, deps *Deps, clientID, currency string, asOf time.Time) ([]*shared.AgingLine, error) {
+	report, err := LoadReport(ctx, deps, asOf, shared.DefaultAgingLimits)
+	if err != nil {
+		return nil, err
+	}
+	var open []*shared.AgingLine
+	for _, c := range report.Client(clientID) {
+		if c.Currency == currency {
+			open = append(open, c.Lines...)
+		}
+	}
+	return open, nil
+}
+
+// loadReport ages the receivables ledger at p.asOf.
+func loadReport(ctx context.Context, deps *Deps, p params) (*shared.AgingReport, error) {
+	r, err := LoadReceivables(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	return r.Age(p.asOf, p.limits), nil
+}
+
+// LoadReceivables reads every completed revenue with its payments and notes
+// into the receivables ledger that aging reports, statements, dunning and
+// allocations are built from. Note rows (CN-/DN- references) are not
+// invoices; their signed cash delta is applied to the invoice they correct
+// as an entry dated when the note was issued, the original keeping its
+// issued amount. Receipts on account are left for the caller.
+func LoadReceivables(ctx context.Context, deps *Deps) (*shared.Receivables, error) {
+	revenues, err := listCompleted(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	netDays := paymentTermDays(ctx, deps)
+
+	r := &shared.Receivables{}
+	for _, rv := range revenues {
+		if shared.RevenueNoteKindOf(rv.GetReferenceNumber()) == "" {
+			r.Invoices = append(r.Invoices, receivableInvoice(rv, netDays))
+			continue
+		}
+		if deps.Notes == nil {
+			continue
+		}
+		note, err := deps.Notes.ReadRevenueNote(ctx, rv.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read note %s: %w", rv.GetReferenceNumber(), err)
+		}
+		if note != nil {
+			r.Notes = append(r.Notes, note.Entry())
+		}
+	}
+
+	r.Payments, err = listPayments(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// listCompleted pages through every revenue in the "complete" status.
+func listCompleted(ctx context.Context, deps *Deps) ([]*revenuepb.Revenue, error) {
+	var out []*revenuepb.Revenue
+	for page := int32(1); ; page++ {
+		resp, err := deps.GetListPageData(ctx, &revenuepb.GetRevenueListPageDataRequest{
+			Filters: &commonpb.FilterRequest{Filters: []*commonpb.TypedFilter{{
+				Field: "rv.status",
+				FilterType: &commonpb.TypedFilter_StringFilter{
+					StringFilter: &commonpb.StringFilter{Value: shared.RevenueStatusComplete, Operator: commonpb.StringOperator_STRING_EQUALS},
+				},
+			}}},
+			Pagination: &commonpb.PaginationRequest{
+				Limit:  pageSize,
+				Method: &commonpb.PaginationRequest_Offset{Offset: &commonpb.OffsetPagination{Page: page}},
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to list revenues for aging: %v", err)
+			return nil, fmt.Errorf("failed to load sales: %w", err)
+		}
+		out = append(out, resp.GetRevenueList()...)
+		if len(resp.GetRevenueList()) < pageSize {
+			return out, nil
+		}
+	}
+}
+
+// listPayments returns every counted payment as a dated receivable entry.
+// Payments without a payment date are dated by when they were recorded.
+func listPayments(ctx context.Context, deps *Deps) ([]shared.ReceivableEntry, error) {
+	if deps.ListRevenuePayments == nil {
+		return nil, nil
+	}
+	resp, err := deps.ListRevenuePayments(ctx, &revenuepaymentpb.ListRevenuePaymentsRequest{})
+	if err != nil {
+		log.Printf("Failed to list payments for aging: %v", err)
+		return nil, fmt.Errorf("failed to load payments: %w", err)
+	}
+	var out []shared.ReceivableEntry
+	for _, pay := range resp.GetData() {
+		switch pay.GetStatus() {
+		case "failed", "cancelled", "voided":
+			continue
+		}
+		at, ok := parseDate(pay.GetPaymentDate())
+		if !ok {
+			at = time.UnixMilli(pay.GetDateCreated()).UTC()
+		}
+		out = append(out, shared.ReceivableEntry{RevenueID: pay.GetRevenueId(), Reference: pay.GetReferenceNumber(), Amount: pay.GetAmount(), At: at})
+	}
+	return out, nil
+}
+
+// paymentTermDays maps payment term IDs to net days, for revenues whose
+// payment term was not loaded with them. Nil on error (graceful degradation).
+func paymentTermDays(ctx context.Context, deps *Deps) map[string]int {
+	if deps.ListPaymentTerms == nil {
+		return nil
+	}
+	terms, err := deps.ListPaymentTerms(ctx)
+	if err != nil {
+		log.Printf("Failed to load payment terms: %v", err)
+		return nil
+	}
+	days := make(map[string]int, len(terms))
+	for _, t := range terms {
+		days[t.Id] = int(t.NetDays)
+	}
+	return days
+}
+
+// receivableInvoice converts a completed revenue. The due date is the stored
+// one when present, otherwise derived from the revenue's payment term; with
+// no term the invoice is due on its date. Revenues without a client are
+// grouped by customer name.
+func receivableInvoice(rv *revenuepb.Revenue, netDays map[string]int) shared.ReceivableInvoice {
+	issued, ok := parseDate(rv.GetRevenueDate())
+	if !ok {
+		issued = time.UnixMilli(rv.GetDateCreated()).UTC()
+	}
+	due, ok := parseDate(rv.GetDueDate())
+	if !ok {
+		if pt := rv.GetPaymentTerm(); pt != nil {
+			due = shared.DueDate(issued, pt.GetType(), int(pt.GetNetDays()), int(pt.GetProximateDay()))
+		} else {
+			due = shared.DueDate(issued, shared.PaymentTermNet, netDays[rv.GetPaymentTermId()], 0)
+		}
+	}
+
+	name := clientName(rv)
+	clientID := rv.GetClientId()
+	if clientID == "" {
+		clientID = name
+	}
+	return shared.ReceivableInvoice{
+		RevenueID:   rv.GetId(),
+		Reference:   rv.GetReferenceNumber(),
+		ClientID:    clientID,
+		ClientName:  name,
+		Currency:    rv.GetCurrency(),
+		InvoiceDate: issued,
+		DueDate:     due,
+		Amount:      shared.RevenueCashExpected(rv),
+	}
+}
+
+func clientName(rv *revenuepb.Revenue) string {
+	if c := rv.GetClient(); c != nil {
+		if name := c.GetName(); name != "" {
+			return name
+		}
+		if u := c.GetUser(); u != nil {
+			if name := strings.TrimSpace(u.GetFirstName() + " " + u.GetLastName()); name != "" {
+				return name
+			}
+		}
+	}
+	return rv.GetName()
+}
+
+// parseDate reads a YYYY-MM-DD date, ignoring any time part.
+func parseDate(s string) (time.Time, bool) {
+	if len(s) < len(dateLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(dateLayout, s[:len(dateLayout)])
+	return t, err == nil
+}
+
+// BucketLabels returns the heading of each bucket: current, "1-30 days",
+// ..., "90+ days".
+func BucketLabels(l revenuedomain.AgingLabels, limits shared.AgingBuckets) []string {
+	labels := make([]string, limits.Len())
+	for i := range labels {
+		from, to := limits.Range(i)
+		switch {
+		case i == 0:
+			labels[i] = l.Current
+		case to < 0:
+			labels[i] = fmt.Sprintf(l.Over, from-1)
+		default:
+			labels[i] = fmt.Sprintf(l.Range, from, to)
+		}
+	}
+	return labels
+}
+
+func bucketKey(i int) string { return "bucket_" + strconv.Itoa(i) }
+
+func moneyCell(centavos int64, currency string) types.TableCell {
+	return types.MoneyCell(float64(centavos), currency, true)
+}
+
+func buildSummaryTable(deps *Deps, report *shared.AgingReport, p params) *types.TableConfig {
+	l := deps.Labels.Aging
+	buckets := BucketLabels(l, p.limits)
+
+	columns := []types.TableColumn{
+		{Key: "client_name", Label: l.Client, WidthClass: "col-9xl"},
+		{Key: "currency", Label: l.Currency, WidthClass: "col-2xl"},
+		{Key: "invoices", Label: l.Invoices, WidthClass: "col-2xl", Align: "right"},
+	}
+	for i, label := range buckets {
+		columns = append(columns, types.TableColumn{Key: bucketKey(i), Label: label, WidthClass: "col-3xl", Align: "right"})
+	}
+	columns = append(columns, types.TableColumn{Key: "total", Label: l.Total, WidthClass: "col-3xl", Align: "right"})
+
+	rows := []types.TableRow{}
+	for _, c := range report.Clients {
+		href := route.ResolveURL(deps.Routes.AgingClientURL, "id", url.PathEscape(c.ClientID)) + p.query()
+		cells := []types.TableCell{
+			{Type: "text", Value: c.ClientName},
+			{Type: "text", Value: c.Currency},
+			{Type: "text", Value: strconv.Itoa(len(c.Lines))},
+		}
+		for _, amount := range c.Buckets {
+			cells = append(cells, moneyCell(amount, c.Currency))
+		}
+		cells = append(cells, moneyCell(c.Total, c.Currency))
+		rows = append(rows, types.TableRow{
+			ID:    c.ClientID + "-" + c.Currency,
+			Href:  href,
+			Cells: cells,
+			DataAttrs: map[string]string{
+				"client":   c.ClientName,
+				"currency": c.Currency,
+				"total":    strconv.FormatInt(c.Total, 10),
+			},
+			Actions: []types.TableAction{
+				{Type: "view", Label: deps.Labels.Actions.View, Action: "view", Href: href},
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:                   "aging-table",
+		RefreshURL:           deps.Routes.AgingTableURL + p.query(),
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowSort:             true,
+		ShowColumns:          true,
+		ShowDensity:          true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "client_name",
+		DefaultSortDirection: "asc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+	}
+	// Totals only add up within one currency.
+	if len(report.Totals) == 1 {
+		t := report.Totals[0]
+		totals := []types.TableCell{{Type: "text", Value: l.Total}, {Type: "text", Value: t.Currency}, {Type: "text"}}
+		for _, amount := range t.Buckets {
+			totals = append(totals, moneyCell(amount, t.Currency))
+		}
+		tableConfig.TotalsRow = append(totals, moneyCell(t.Total, t.Currency))
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+func buildClientTable(deps *Deps, groups []*shared.AgingClient, p params) *types.TableConfig {
+	l := deps.Labels.Aging
+	buckets := BucketLabels(l, p.limits)
+
+	columns := []types.TableColumn{
+		{Key: "reference_number", Label: l.Reference},
+		{Key: "invoice_date", Label: l.InvoiceDate, WidthClass: "col-3xl"},
+		{Key: "due_date", Label: l.DueDate, WidthClass: "col-3xl"},
+		{Key: "days_overdue", Label: l.DaysOverdue, WidthClass: "col-2xl", Align: "right"},
+		{Key: "bucket", Label: l.Bucket, WidthClass: "col-3xl"},
+		{Key: "amount", Label: l.Amount, WidthClass: "col-3xl", Align: "right"},
+		{Key: "paid", Label: l.Paid, WidthClass: "col-3xl", Align: "right"},
+		{Key: "adjusted", Label: l.Adjusted, WidthClass: "col-3xl", Align: "right"},
+		{Key: "balance", Label: l.Balance, WidthClass: "col-3xl", Align: "right"},
+	}
+
+	rows := []types.TableRow{}
+	for _, g := range groups {
+		for _, line := range g.Lines {
+			detailURL := route.ResolveURL(deps.Routes.DetailURL, "id", line.RevenueID)
+			days := ""
+			if line.DaysOverdue > 0 {
+				days = strconv.Itoa(line.DaysOverdue)
+			}
+			rows = append(rows, types.TableRow{
+				ID:   line.RevenueID,
+				Href: detailURL,
+				Cells: []types.TableCell{
+					{Type: "text", Value: line.Reference},
+					types.DateTimeCell(line.InvoiceDate.Format(dateLayout), types.DateReadable),
+					types.DateTimeCell(line.DueDate.Format(dateLayout), types.DateReadable),
+					{Type: "text", Value: days},
+					{Type: "badge", Value: buckets[line.Bucket], Variant: bucketVariant(line.Bucket, p.limits)},
+					moneyCell(line.Amount, line.Currency),
+					moneyCell(line.Paid, line.Currency),
+					moneyCell(line.Adjusted, line.Currency),
+					moneyCell(line.Balance, line.Currency),
+				},
+				DataAttrs: map[string]string{
+					"reference": line.Reference,
+					"balance":   strconv.FormatInt(line.Balance, 10),
+				},
+				Actions: []types.TableAction{
+					{Type: "view", Label: deps.Labels.Actions.View, Action: "view", Href: detailURL},
+				},
+			})
+		}
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:                   "aging-client-table",
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowSort:             true,
+		ShowColumns:          true,
+		ShowDensity:          true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "due_date",
+		DefaultSortDirection: "asc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+	}
+	if len(groups) == 1 {
+		g := groups[0]
+		tableConfig.TotalsRow = []types.TableCell{
+			{Type: "text", Value: l.Total}, {Type: "text"}, {Type: "text"}, {Type: "text"}, {Type: "text"},
+			{Type: "text"}, {Type: "text"}, {Type: "text"},
+			moneyCell(g.Total, g.Currency),
+		}
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+// bucketVariant colours a bucket badge from current (success) to the
+// open-ended last bucket (danger).
+func bucketVariant(bucket int, limits shared.AgingBuckets) string {
+	switch {
+	case bucket == 0:
+		return "success"
+	case bucket == limits.Len()-1:
+		return "danger"
+	default:
+		return "warning"
+	}
+}
@@ -0,0 +1,59 @@
+package aging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+func TestLoadReport_CreditNotes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// INV-1 (1,000.00, 400.00 paid in February) gets a 100.00 credit note in
+	// March; INV-2 (500.00) is credited in full.
+	deps := testDeps()
+	deps.GetListPageData = func(ctx context.Context, req *revenuepb.GetRevenueListPageDataRequest) (*revenuepb.GetRevenueListPageDataResponse, error) {
+		return &revenuepb.GetRevenueListPageDataResponse{RevenueList: []*revenuepb.Revenue{
+			{Id: "r1", ClientId: "c1", Name: "Acme", Currency: "PHP", TotalAmount: 100000, CashAmountExpected: int64Ptr(100000),
+				ReferenceNumber: strPtr("INV-1"), RevenueDate: strPtr("2026-01-01"), DueDate: strPtr("2026-01-31")},
+			{Id: "r2", ClientId: "c2", Name: "Bolt", Currency: "PHP", TotalAmount: 50000, CashAmountExpected: int64Ptr(50000),
+				ReferenceNumber: strPtr("INV-2"), RevenueDate: strPtr("2026-03-01"), DueDate: strPtr("2026-03-31")},
+			{Id: "cn1", ClientId: "c1", Currency: "PHP", TotalAmount: -10000, CashAmountExpected: int64Ptr(-10000),
+				ReferenceNumber: strPtr("CN-0001"), RevenueDate: strPtr("2026-03-10")},
+			{Id: "cn2", ClientId: "c2", Currency: "PHP", TotalAmount: -50000, CashAmountExpected: int64Ptr(-50000),
+				ReferenceNumber: strPtr("CN-0002"), RevenueDate: strPtr("2026-03-02")},
+		}}, nil
+	}
+	notes := shared.NewMemoryRevenueNoteStore()
+	_ = notes.SaveRevenueNote(ctx, &shared.RevenueNote{RevenueID: "cn1", Kind: shared.RevenueNoteCredit, ReferenceNumber: "CN-0001",
+		OriginalRevenueID: "r1", Subtotal: -10000, IssuedAt: time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)})
+	_ = notes.SaveRevenueNote(ctx, &shared.RevenueNote{RevenueID: "cn2", Kind: shared.RevenueNoteCredit, ReferenceNumber: "CN-0002",
+		OriginalRevenueID: "r2", Subtotal: -50000, IssuedAt: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)})
+	deps.Notes = notes
+
+	report, err := LoadReport(ctx, deps, time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), shared.DefaultAgingLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Clients) != 1 || len(report.Clients[0].Lines) != 1 {
+		t.Fatalf("clients = %+v, want only Acme's INV-1 open", report.Clients)
+	}
+	if line := report.Clients[0].Lines[0]; line.Amount != 100000 || line.Paid != 40000 || line.Adjusted != -10000 || line.Balance != 50000 {
+		t.Errorf("INV-1 = amount %d paid %d adjusted %d balance %d, want 100000/40000/-10000/50000",
+			line.Amount, line.Paid, line.Adjusted, line.Balance)
+	}
+
+	// Before the note was issued, the balance is as it stood then.
+	before, err := LoadReport(ctx, deps, time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), shared.DefaultAgingLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before.Totals) != 1 || before.Totals[0].Total != 60000 {
+		t.Errorf("February totals = %+v, want 60000", before.Totals)
+	}
+}
@@ -1,20 +1,129 @@
+// Package dashboard renders the revenue (sales) dashboard.
+//
+// Aggregates come from the GetPageData callback, which the orchestrator backs
+// with UseCases.Revenue.GetRevenueDashboard (workspace_id from the request
+// context inside the wrapper). The contract is expressed by the local
+// Request/Response types; Summarize computes them from the revenue and
+// payment lists for orchestrators without an aggregate query.
+//
+// ?date_from=, ?date_to= (YYYY-MM-DD) and ?location= drive the stat cards,
+// the charts and the recent list. The default period is month to date.
 package dashboard
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 
 	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
 	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
+
+	locationpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/location"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
 )
 
+// Stats holds one period's tile values (centavos for money).
+type Stats struct {
+	Invoiced     int64
+	Collected    int64
+	Outstanding  int64
+	InvoiceCount int64
+	// FXGainLoss is the realized exchange gain (positive) or loss on
+	// payments of foreign-currency revenues (SummarizeFX only).
+	FXGainLoss int64
+}
+
+// StatusCount is the number of sales in one status.
+type StatusCount struct {
+	Status string
+	Count  int64
+}
+
+// Request is the input to the GetPageData callback. From and To are
+// inclusive dates; zero values mean month to date as of Now.
+type Request struct {
+	Now        time.Time
+	From       time.Time
+	To         time.Time
+	LocationID string // "" = every location
+}
+
+// Period returns the inclusive date range the request covers.
+func (r *Request) Period() (from, to time.Time) {
+	now := r.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	to = truncateDay(r.To)
+	if r.To.IsZero() {
+		to = truncateDay(now.UTC())
+	}
+	from = truncateDay(r.From)
+	if r.From.IsZero() || from.After(to) {
+		from = time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return from, to
+}
+
+// PreviousPeriod returns the range of the same length ending the day before
+// Period starts — the baseline for the trend percentages.
+func (r *Request) PreviousPeriod() (from, to time.Time) {
+	curFrom, curTo := r.Period()
+	days := int(curTo.Sub(curFrom).Hours()/24) + 1
+	to = curFrom.AddDate(0, 0, -1)
+	return to.AddDate(0, 0, 1-days), to
+}
+
+// Response is the projection the view consumes.
+type Response struct {
+	From, To time.Time
+	Stats    Stats
+	Previous Stats // same-length period before From
+
+	StatusCounts []StatusCount // sorted by count, largest first
+
+	// Monthly series (12 months ending with To's month) — labels parallel
+	// to values, centavos.
+	MonthLabels     []string
+	InvoicedValues  []float64
+	CollectedValues []float64
+
+	Recent []*revenuepb.Revenue
+
+	// Translated reports that amounts are in the functional currency
+	// (SummarizeFX); the page then shows the FX gain/loss.
+	Translated bool
+}
+
 // Deps holds view dependencies.
 type Deps struct {
 	Labels       revenuedomain.Labels
 	Routes       revenuedomain.Routes
 	CommonLabels pyeza.CommonLabels
+
+	// GetPageData is nil-safe; when nil the dashboard renders zero values.
+	GetPageData func(ctx context.Context, req *Request) (*Response, error)
+
+	// GetFunctionalCurrency returns the workspace's ISO 4217 functional currency
+	// (e.g. "PHP"). Nil-safe — when absent, money strings omit the currency prefix.
+	GetFunctionalCurrency func(ctx context.Context) string
+
+	// ListLocations fills the location filter. Nil-safe — the filter is
+	// hidden when absent.
+	ListLocations func(ctx context.Context, req *locationpb.ListLocationsRequest) (*locationpb.ListLocationsResponse, error)
+}
+
+// LocationOption is one entry of the location filter.
+type LocationOption struct {
+	ID   string
+	Name string
 }
 
 // PageData is what the revenue dashboard template receives.
@@ -22,62 +131,148 @@ type PageData struct {
 	types.PageData
 	ContentTemplate string
 	Dashboard       types.DashboardData
+	Labels          revenuedomain.DashboardLabels
+	FilterURL       string
+	DateFrom        string
+	DateTo          string
+	LocationFilter  string
+	Locations       []LocationOption
 }
 
 // NewView creates the revenue dashboard view.
-//
-// Phase 1 refactor (2026-05-02): wired onto the pyeza "dashboard" block.
-// Aggregates remain placeholder until Phase 5 wires real Revenue/Invoice
-// repository aggregate methods.
 func NewView(deps *Deps) view.View {
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
 		l := deps.Labels.Dashboard
+		q := viewCtx.Request.URL.Query()
+
+		req := &Request{Now: time.Now(), LocationID: q.Get("location")}
+		if t, ok := parseDate(q.Get("date_from")); ok {
+			req.From = t
+		}
+		if t, ok := parseDate(q.Get("date_to")); ok {
+			req.To = t
+		}
+		from, to := req.Period()
 
+		currency := ""
+		if deps.GetFunctionalCurrency != nil {
+			currency = deps.GetFunctionalCurrency(ctx)
+		}
+
+		var resp *Response
+		if deps.GetPageData != nil {
+			r, err := deps.GetPageData(ctx, req)
+			if err != nil {
+				log.Printf("Failed to load revenue dashboard: %v", err)
+			} else {
+				resp = r
+			}
+		}
+		if resp == nil {
+			resp = &Response{}
+		}
+		cur, prev := resp.Stats, resp.Previous
+
+		// Monthly invoiced vs collected.
 		trend := &types.ChartData{
-			Labels: []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
-			Series: []types.ChartSeries{{
-				Name:   l.Revenue,
-				Values: []float64{42, 58, 71, 64, 88, 95, 102, 89, 110, 124, 118, 134},
-				Color:  "terracotta",
-			}},
+			Labels: resp.MonthLabels,
+			Series: []types.ChartSeries{
+				{Name: l.Invoiced, Values: resp.InvoicedValues, Color: "terracotta"},
+				{Name: l.Collected, Values: resp.CollectedValues, Color: "sage"},
+			},
+			Currency: currency,
+		}
+		if len(trend.Labels) == 0 {
+			trend.Labels = []string{"-"}
+			trend.Series[0].Values = []float64{0}
+			trend.Series[1].Values = []float64{0}
 		}
 		trend.AutoScale()
 
+		// Sales by status.
+		statusChart := &types.ChartData{
+			Series: []types.ChartSeries{{Name: l.ByStatus, Color: "navy"}},
+		}
+		for _, sc := range resp.StatusCounts {
+			statusChart.Labels = append(statusChart.Labels, statusLabel(l, sc.Status))
+			statusChart.Series[0].Values = append(statusChart.Series[0].Values, float64(sc.Count))
+		}
+		if len(statusChart.Labels) == 0 {
+			statusChart.Labels = []string{"-"}
+			statusChart.Series[0].Values = []float64{0}
+		}
+		statusChart.AutoScale()
+
+		recentItems := make([]types.ActivityItem, 0, len(resp.Recent))
+		for i, rv := range resp.Recent {
+			title := rv.GetReferenceNumber()
+			if title == "" {
+				title = rv.GetName()
+			}
+			icon, variant := statusIcon(rv.GetStatus())
+			rvCurrency := currency
+			if rv.GetCurrency() != "" {
+				rvCurrency = rv.GetCurrency()
+			}
+			recentItems = append(recentItems, types.ActivityItem{
+				IconName:    icon,
+				IconVariant: variant,
+				Title:       title,
+				Description: fmt.Sprintf("%s — %s", statusLabel(l, rv.GetStatus()), types.FormatMoney(rv.GetTotalAmount(), rvCurrency)),
+				Time:        revenueDate(rv).Format(dateLayout),
+				Href:        route.ResolveURL(deps.Routes.DetailURL, "id", rv.GetId()),
+				TestID:      fmt.Sprintf("revenue-activity-%d", i),
+			})
+		}
+
+		invoicedTrend, invoicedUp := trendPercent(cur.Invoiced, prev.Invoiced)
+		collectedTrend, collectedUp := trendPercent(cur.Collected, prev.Collected)
+		outstandingTrend, outstandingUp := trendPercent(cur.Outstanding, prev.Outstanding)
+		countTrend, countUp := trendPercent(cur.InvoiceCount, prev.InvoiceCount)
+
 		dash := types.DashboardData{
 			QuickActions: []types.QuickAction{
 				{Icon: "icon-plus", Label: l.QuickNewRevenue, Href: deps.Routes.AddURL, Variant: "primary", TestID: "revenue-action-new"},
 				{Icon: "icon-list", Label: l.QuickViewAll, Href: deps.Routes.ListURL, TestID: "revenue-action-list"},
 			},
 			Stats: []types.StatCardData{
-				{Icon: "icon-shopping-bag", Value: "156", Label: l.TotalRevenue, Trend: "+18%", TrendUp: true, Color: "terracotta", TestID: "revenue-stat-total"},
-				{Icon: "icon-dollar-sign", Value: "₱284K", Label: l.Revenue, Trend: "+12%", TrendUp: true, Color: "sage", TestID: "revenue-stat-amount"},
-				{Icon: "icon-check-circle", Value: "89", Label: l.Completed, Trend: "+7", TrendUp: true, Color: "navy", TestID: "revenue-stat-completed"},
-				{Icon: "icon-clock", Value: "42", Label: l.Active, Trend: "+3", TrendUp: true, Color: "amber", TestID: "revenue-stat-active"},
+				{Icon: "icon-dollar-sign", Value: types.FormatMoneyCompact(cur.Invoiced, currency), Label: l.Invoiced, Trend: invoicedTrend, TrendUp: invoicedUp, Color: "terracotta", TestID: "revenue-stat-invoiced"},
+				{Icon: "icon-check-circle", Value: types.FormatMoneyCompact(cur.Collected, currency), Label: l.Collected, Trend: collectedTrend, TrendUp: collectedUp, Color: "sage", TestID: "revenue-stat-collected"},
+				{Icon: "icon-clock", Value: types.FormatMoneyCompact(cur.Outstanding, currency), Label: l.Outstanding, Trend: outstandingTrend, TrendUp: outstandingUp, Color: "amber", TestID: "revenue-stat-outstanding"},
+				{Icon: "icon-shopping-bag", Value: fmt.Sprintf("%d", cur.InvoiceCount), Label: l.TotalRevenue, Trend: countTrend, TrendUp: countUp, Color: "navy", TestID: "revenue-stat-total"},
 			},
 			Widgets: []types.DashboardWidget{
 				{
 					ID: "trend", Title: l.RevenueTrend, Type: "chart", ChartKind: "line",
 					ChartData: trend, Span: 2,
-					HeaderActions: []types.QuickAction{
-						{Label: l.Week, Href: "#"},
-						{Label: l.Month, Href: "#"},
-						{Label: l.Year, Href: "#", Variant: "primary"},
-					},
+				},
+				{
+					ID: "by-status", Title: l.ByStatus, Type: "chart", ChartKind: "donut",
+					ChartData: statusChart, Span: 1,
 				},
 				{
 					ID: "recent", Title: l.RecentRevenue, Type: "list", Span: 1,
 					HeaderActions: []types.QuickAction{
 						{Label: l.ViewAll, Href: deps.Routes.ListURL},
 					},
-					ListItems: []types.ActivityItem{
-						{IconName: "icon-shopping-bag", IconVariant: "client", Title: l.NewRevenueCreated, Description: "REF-2024-001 — ₱12,500", Time: "30m ago", TestID: "revenue-activity-new"},
-						{IconName: "icon-check-circle", IconVariant: "quote", Title: l.RevenueCompleted, Description: "REF-2024-098 — ₱45,000", Time: "2h ago", TestID: "revenue-activity-completed"},
-						{IconName: "icon-edit", IconVariant: "award", Title: l.RevenueUpdated, Description: "REF-2024-095 amount adjusted", Time: "4h ago", TestID: "revenue-activity-updated"},
-						{IconName: "icon-x-circle", IconVariant: "integration", Title: l.RevenueCancelled, Description: "REF-2024-087 — ₱8,200", Time: "1d ago", TestID: "revenue-activity-cancelled"},
+					ListItems: recentItems,
+					EmptyState: &types.EmptyStateData{
+						Icon:  "icon-shopping-bag",
+						Title: l.EmptyRecentTitle,
+						Desc:  l.EmptyRecentDesc,
 					},
 				},
 			},
 		}
+		// Realized FX gain/loss, when amounts were translated.
+		if resp.Translated {
+			color := "sage"
+			if cur.FXGainLoss < 0 {
+				color = "terracotta"
+			}
+			fxTrend, fxUp := trendPercent(cur.FXGainLoss, prev.FXGainLoss)
+			dash.Stats = append(dash.Stats, types.StatCardData{Icon: "icon-refresh-cw", Value: types.FormatMoneyCompact(cur.FXGainLoss, currency), Label: deps.Labels.FXRates.FXGainLoss, Trend: fxTrend, TrendUp: fxUp, Color: color, TestID: "revenue-stat-fx-gain-loss"})
+		}
 
 		pageData := &PageData{
 			PageData: types.PageData{
@@ -92,8 +287,82 @@ func NewView(deps *Deps) view.View {
 			},
 			ContentTemplate: "revenue-dashboard-content",
 			Dashboard:       dash,
+			Labels:          l,
+			FilterURL:       deps.Routes.DashboardURL,
+			DateFrom:        from.Format(dateLayout),
+			DateTo:          to.Format(dateLayout),
+			LocationFilter:  req.LocationID,
+			Locations:       loadLocations(ctx, deps),
 		}
 
 		return view.OK("revenue-dashboard", pageData)
 	})
 }
+
+// trendPercent formats the period-over-period change ("+12%", "-5%"). It
+// returns "" when there is no previous value to compare against.
+func trendPercent(cur, prev int64) (string, bool) {
+	if prev == 0 {
+		return "", cur >= 0
+	}
+	pct := math.Round(float64(cur-prev) * 100 / math.Abs(float64(prev)))
+	if pct >= 0 {
+		return fmt.Sprintf("+%.0f%%", pct), true
+	}
+	return fmt.Sprintf("%.0f%%", pct), false
+}
+
+// statusLabel returns the translated name of a revenue status; workspace
+// statuses without a label show as stored.
+func statusLabel(l revenuedomain.DashboardLabels, status string) string {
+	var label string
+	switch status {
+	case shared.RevenueStatusDraft:
+		label = l.Draft
+	case shared.RevenueStatusComplete:
+		label = l.Completed
+	case shared.RevenueStatusCancelled:
+		label = l.Cancelled
+	case shared.RevenueStatusPending:
+		label = l.Pending
+	case shared.RevenueStatusPaid:
+		label = l.Paid
+	}
+	if label == "" {
+		return status
+	}
+	return label
+}
+
+func statusIcon(status string) (icon, variant string) {
+	switch status {
+	case shared.RevenueStatusComplete, shared.RevenueStatusPaid:
+		return "icon-check-circle", "quote"
+	case shared.RevenueStatusCancelled:
+		return "icon-x-circle", "integration"
+	case shared.RevenueStatusDraft:
+		return "icon-edit", "award"
+	default:
+		return "icon-shopping-bag", "client"
+	}
+}
+
+// loadLocations fetches locations for the filter. Nil on error (graceful
+// degradation — the filter is hidden).
+func loadLocations(ctx context.Context, deps *Deps) []LocationOption {
+	if deps.ListLocations == nil {
+		return nil
+	}
+	resp, err := deps.ListLocations(ctx, &locationpb.ListLocationsRequest{})
+	if err != nil {
+		log.Printf("Failed to list locations for revenue dashboard: %v", err)
+		return nil
+	}
+	var options []LocationOption
+	for _, loc := range resp.GetData() {
+		if loc.GetId() != "" && loc.GetName() != "" {
+			options = append(options, LocationOption{ID: loc.GetId(), Name: loc.GetName()})
+		}
+	}
+	return options
+}
@@ -0,0 +1,256 @@
+package dashboard
+
+import (
+	"sort"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+)
+
+// dateLayout is the stored revenue/payment date format and the filter format.
+const dateLayout = "2006-01-02"
+
+// trendMonths is the length of the monthly trend series.
+const trendMonths = 12
+
+// recentLimit caps the recent sales list.
+const recentLimit = 5
+
+// Summarize computes the dashboard aggregates for req from a workspace's
+// revenues and revenue payments, adding up amounts as stored whatever their
+// currency. Orchestrators without a dedicated aggregate query load both
+// lists and call it from the GetPageData closure; see SummarizeFX for
+// workspaces that invoice in more than one currency.
+//
+// Invoiced counts every issued revenue (not draft, pending or cancelled)
+// dated in the period, credit and debit notes included at their signed
+// totals. Collected counts payments received in the period. Outstanding is
+// the unpaid balance of issued revenues at the end of the period; notes
+// move the balance of the invoice they correct through their links (notes,
+// the same entries the aging report applies), from the day they were issued.
+func Summarize(req *Request, revenues []*revenuepb.Revenue, payments []*revenuepaymentpb.RevenuePayment, notes []*shared.RevenueNote) *Response {
+	return SummarizeFX(req, revenues, payments, notes, nil)
+}
+
+// SummarizeFX is Summarize with every amount translated into fx's functional
+// currency. Revenues and their outstanding balances translate at the rate
+// they were issued at (their snapshot), payments at the rate of the day they
+// were received; the difference on payments of foreign-currency revenues is
+// the realized FX gain or loss. Amounts without a known rate are added
+// untranslated. A nil fx is Summarize.
+func SummarizeFX(req *Request, revenues []*revenuepb.Revenue, payments []*revenuepaymentpb.RevenuePayment, notes []*shared.RevenueNote, fx *shared.FXTranslation) *Response {
+	from, to := req.Period()
+	prevFrom, prevTo := req.PreviousPeriod()
+
+	// Scope to the requested location; payments follow their revenue.
+	scoped := make(map[string]*revenuepb.Revenue, len(revenues))
+	var inScope []*revenuepb.Revenue
+	for _, rv := range revenues {
+		if req.LocationID != "" && rv.GetLocationId() != req.LocationID {
+			continue
+		}
+		scoped[rv.GetId()] = rv
+		inScope = append(inScope, rv)
+	}
+	type received struct {
+		revenueID string
+		amount    int64 // revenue currency
+		at        time.Time
+	}
+	var receipts []received
+	for _, pay := range payments {
+		switch pay.GetStatus() {
+		case "failed", "cancelled", "voided":
+			continue
+		}
+		if req.LocationID != "" && scoped[pay.GetRevenueId()] == nil {
+			continue
+		}
+		at, ok := parseDate(pay.GetPaymentDate())
+		if !ok {
+			at = truncateDay(time.UnixMilli(pay.GetDateCreated()).UTC())
+		}
+		receipts = append(receipts, received{pay.GetRevenueId(), pay.GetAmount(), at})
+	}
+
+	resp := &Response{From: from, To: to, Translated: fx != nil}
+
+	// booked translates a revenue's amount at its issue rate; settled
+	// translates a receipt at the rate of its day and returns the realized
+	// gain or loss against the revenue's issue rate.
+	booked := func(rv *revenuepb.Revenue, amount int64) int64 {
+		v, _ := fx.Booked(shared.FXDocumentRevenue, rv.GetId(), rv.GetCurrency(), amount, revenueDate(rv))
+		return v
+	}
+	settled := func(rc received) (int64, int64) {
+		rv := scoped[rc.revenueID]
+		if rv == nil || fx == nil {
+			return rc.amount, 0
+		}
+		currency := rv.GetCurrency()
+		value, ok := fx.Translate(currency, rc.amount, rc.at)
+		if !ok || currency == "" || currency == fx.Functional {
+			return value, 0
+		}
+		rate, _ := fx.Rate(currency, fx.Functional, rc.at)
+		bookedRate, ok := fx.BookedRate(shared.FXDocumentRevenue, rv.GetId(), currency, revenueDate(rv))
+		if !ok {
+			return value, 0
+		}
+		return value, shared.RealizedFXGainLoss(rc.amount, bookedRate, rate.RateMicroUnits)
+	}
+
+	// Month buckets for the trend, oldest first, ending with to's month.
+	first := time.Date(to.Year(), to.Month()-trendMonths+1, 1, 0, 0, 0, 0, time.UTC)
+	monthIndex := func(t time.Time) int {
+		return (t.Year()-first.Year())*12 + int(t.Month()) - int(first.Month())
+	}
+	resp.MonthLabels = make([]string, trendMonths)
+	resp.InvoicedValues = make([]float64, trendMonths)
+	resp.CollectedValues = make([]float64, trendMonths)
+	for i := range resp.MonthLabels {
+		resp.MonthLabels[i] = first.AddDate(0, i, 0).Format("Jan")
+	}
+
+	statusCounts := map[string]int64{}
+	var recent []*revenuepb.Revenue
+	for _, rv := range inScope {
+		at := revenueDate(rv)
+		isNote := shared.RevenueNoteKindOf(rv.GetReferenceNumber()) != ""
+		issued := isIssued(rv.GetStatus())
+
+		if inPeriod(at, from, to) && !isNote {
+			statusCounts[rv.GetStatus()]++
+			recent = append(recent, rv)
+		}
+		if !issued {
+			continue
+		}
+		total := booked(rv, rv.GetTotalAmount())
+		if inPeriod(at, from, to) {
+			resp.Stats.Invoiced += total
+			if !isNote {
+				resp.Stats.InvoiceCount++
+			}
+		}
+		if inPeriod(at, prevFrom, prevTo) {
+			resp.Previous.Invoiced += total
+			if !isNote {
+				resp.Previous.InvoiceCount++
+			}
+		}
+		if i := monthIndex(at); i >= 0 && i < trendMonths {
+			resp.InvoicedValues[i] += float64(total)
+		}
+	}
+	for _, rc := range receipts {
+		amount, gainLoss := settled(rc)
+		if inPeriod(rc.at, from, to) {
+			resp.Stats.Collected += amount
+			resp.Stats.FXGainLoss += gainLoss
+		}
+		if inPeriod(rc.at, prevFrom, prevTo) {
+			resp.Previous.Collected += amount
+			resp.Previous.FXGainLoss += gainLoss
+		}
+		if i := monthIndex(rc.at); i >= 0 && i < trendMonths {
+			resp.CollectedValues[i] += float64(amount)
+		}
+	}
+
+	// Outstanding at the end of each period: what issued invoices expect,
+	// moved by the notes issued against them and less what was received
+	// against them by then. Each invoice's balance is floored at zero. Note
+	// rows themselves are not invoices and only count through their links.
+	// Balances are worked out in the revenue's currency, then translated at
+	// its issue rate.
+	outstanding := func(asOf time.Time) int64 {
+		paid := map[string]int64{}
+		for _, rc := range receipts {
+			if !rc.at.After(asOf) {
+				paid[rc.revenueID] += rc.amount
+			}
+		}
+		adjusted := map[string]int64{}
+		for _, n := range notes {
+			if e := n.Entry(); !truncateDay(e.At).After(asOf) {
+				adjusted[e.RevenueID] += e.Amount
+			}
+		}
+		var total int64
+		for _, rv := range inScope {
+			status := rv.GetStatus()
+			if !isIssued(status) || status == shared.RevenueStatusPaid || revenueDate(rv).After(asOf) ||
+				shared.RevenueNoteKindOf(rv.GetReferenceNumber()) != "" {
+				continue
+			}
+			balance := shared.RevenueCashExpected(rv) + adjusted[rv.GetId()] - paid[rv.GetId()]
+			total += booked(rv, max(balance, 0))
+		}
+		return total
+	}
+	resp.Stats.Outstanding = outstanding(to)
+	resp.Previous.Outstanding = outstanding(prevTo)
+
+	for status, n := range statusCounts {
+		resp.StatusCounts = append(resp.StatusCounts, StatusCount{Status: status, Count: n})
+	}
+	sort.Slice(resp.StatusCounts, func(i, j int) bool {
+		a, b := resp.StatusCounts[i], resp.StatusCounts[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Status < b.Status
+	})
+
+	sort.SliceStable(recent, func(i, j int) bool {
+		a, b := revenueDate(recent[i]), revenueDate(recent[j])
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return recent[i].GetDateCreated() > recent[j].GetDateCreated()
+	})
+	if len(recent) > recentLimit {
+		recent = recent[:recentLimit]
+	}
+	resp.Recent = recent
+	return resp
+}
+
+// isIssued reports whether a revenue in status counts as invoiced: anything
+// past draft that was neither cancelled nor left awaiting checkout payment.
+func isIssued(status string) bool {
+	switch status {
+	case shared.RevenueStatusDraft, shared.RevenueStatusPending, shared.RevenueStatusCancelled:
+		return false
+	}
+	return true
+}
+
+// revenueDate is the revenue's date, or the day it was recorded when unset.
+func revenueDate(rv *revenuepb.Revenue) time.Time {
+	if t, ok := parseDate(rv.GetRevenueDate()); ok {
+		return t
+	}
+	return truncateDay(time.UnixMilli(rv.GetDateCreated()).UTC())
+}
+
+// parseDate reads a YYYY-MM-DD date, ignoring any time part.
+func parseDate(s string) (time.Time, bool) {
+	if len(s) < len(dateLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(dateLayout, s[:len(dateLayout)])
+	return t, err == nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func inPeriod(t, from, to time.Time) bool {
+	return !t.Before(from) && !t.After(to)
+}
@@ -0,0 +1,185 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+)
+
+func strPtr(s string) *string { return &s }
+func int64Ptr(n int64) *int64 { return &n }
+
+func day(s string) time.Time {
+	t, _ := time.Parse(dateLayout, s)
+	return t
+}
+
+func TestRequestPeriod(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 15, 18, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		req              Request
+		from, to         string
+		prevFrom, prevTo string
+	}{
+		{"month to date", Request{Now: now}, "2026-03-01", "2026-03-15", "2026-02-14", "2026-02-28"},
+		{"explicit range", Request{Now: now, From: day("2026-01-01"), To: day("2026-01-31")}, "2026-01-01", "2026-01-31", "2025-12-01", "2025-12-31"},
+		{"from after to", Request{Now: now, From: day("2026-04-01"), To: day("2026-02-10")}, "2026-02-01", "2026-02-10", "2026-01-22", "2026-01-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := tt.req.Period()
+			prevFrom, prevTo := tt.req.PreviousPeriod()
+			got := []string{from.Format(dateLayout), to.Format(dateLayout), prevFrom.Format(dateLayout), prevTo.Format(dateLayout)}
+			want := []string{tt.from, tt.to, tt.prevFrom, tt.prevTo}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("got %v, want %v", got, want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	revenues := []*revenuepb.Revenue{
+		// March, main branch: issued, partly paid in March.
+		{Id: "r1", LocationId: "main", Status: "complete", TotalAmount: 100000, RevenueDate: strPtr("2026-03-02"), ReferenceNumber: strPtr("INV-1")},
+		// March credit note against r1 — nets into invoiced and outstanding.
+		{Id: "cn1", LocationId: "main", Status: "complete", TotalAmount: -20000, CashAmountExpected: int64Ptr(-20000), RevenueDate: strPtr("2026-03-05"), ReferenceNumber: strPtr("CN-000001")},
+		// February, main: fully paid in February.
+		{Id: "r2", LocationId: "main", Status: "complete", TotalAmount: 50000, RevenueDate: strPtr("2026-02-20"), ReferenceNumber: strPtr("INV-2")},
+		// March, branch: storefront order paid at checkout.
+		{Id: "r3", LocationId: "branch", Status: "paid", TotalAmount: 30000, RevenueDate: strPtr("2026-03-10"), ReferenceNumber: strPtr("ORD-3")},
+		// March invoice credited in full: nothing outstanding.
+		{Id: "r6", LocationId: "main", Status: "complete", TotalAmount: 30000, CashAmountExpected: int64Ptr(30000), RevenueDate: strPtr("2026-03-06"), ReferenceNumber: strPtr("INV-6")},
+		{Id: "cn2", LocationId: "main", Status: "complete", TotalAmount: -30000, CashAmountExpected: int64Ptr(-30000), RevenueDate: strPtr("2026-03-07"), ReferenceNumber: strPtr("CN-000002")},
+		// March drafts and cancellations count by status only.
+		{Id: "r4", LocationId: "main", Status: "draft", TotalAmount: 99900, RevenueDate: strPtr("2026-03-11")},
+		{Id: "r5", LocationId: "main", Status: "cancelled", TotalAmount: 88800, RevenueDate: strPtr("2026-03-12")},
+	}
+	payments := []*revenuepaymentpb.RevenuePayment{
+		{RevenueId: "r1", Amount: 40000, PaymentDate: strPtr("2026-03-08")},
+		{RevenueId: "r2", Amount: 50000, PaymentDate: strPtr("2026-02-25")},
+		{RevenueId: "r3", Amount: 30000, PaymentDate: strPtr("2026-03-10")},
+		{RevenueId: "r1", Amount: 60000, Status: strPtr("voided"), DateCreated: int64Ptr(day("2026-03-09").UnixMilli())},
+	}
+	notes := []*shared.RevenueNote{
+		{RevenueID: "cn1", Kind: shared.RevenueNoteCredit, OriginalRevenueID: "r1", Subtotal: -20000, IssuedAt: day("2026-03-05")},
+		{RevenueID: "cn2", Kind: shared.RevenueNoteCredit, OriginalRevenueID: "r6", Subtotal: -30000, IssuedAt: day("2026-03-07")},
+	}
+	req := &Request{Now: time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)}
+
+	// r1: 1,000.00 less the 200.00 credit note and the 400.00 paid.
+	resp := Summarize(req, revenues, payments, notes)
+	if want := (Stats{Invoiced: 110000, Collected: 70000, Outstanding: 40000, InvoiceCount: 3}); resp.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", resp.Stats, want)
+	}
+	// Previous period 2026-02-14..2026-02-28: r2 issued and paid.
+	if want := (Stats{Invoiced: 50000, Collected: 50000, Outstanding: 0, InvoiceCount: 1}); resp.Previous != want {
+		t.Errorf("Previous = %+v, want %+v", resp.Previous, want)
+	}
+
+	if len(resp.MonthLabels) != trendMonths || resp.MonthLabels[0] != "Apr" || resp.MonthLabels[trendMonths-1] != "Mar" {
+		t.Errorf("MonthLabels = %v", resp.MonthLabels)
+	}
+	if got := resp.InvoicedValues[trendMonths-2:]; got[0] != 50000 || got[1] != 110000 {
+		t.Errorf("InvoicedValues tail = %v", got)
+	}
+	if got := resp.CollectedValues[trendMonths-2:]; got[0] != 50000 || got[1] != 70000 {
+		t.Errorf("CollectedValues tail = %v", got)
+	}
+
+	counts := map[string]int64{}
+	for _, sc := range resp.StatusCounts {
+		counts[sc.Status] = sc.Count
+	}
+	if len(counts) != 4 || counts["complete"] != 2 || counts["paid"] != 1 || counts["draft"] != 1 || counts["cancelled"] != 1 {
+		t.Errorf("StatusCounts = %+v", resp.StatusCounts)
+	}
+	if len(resp.Recent) != 5 || resp.Recent[0].GetId() != "r5" {
+		t.Errorf("Recent not newest first: %v", resp.Recent)
+	}
+
+	// The location filter scopes revenues and their payments.
+	branch := Summarize(&Request{Now: req.Now, LocationID: "branch"}, revenues, payments, notes)
+	if want := (Stats{Invoiced: 30000, Collected: 30000, Outstanding: 0, InvoiceCount: 1}); branch.Stats != want {
+		t.Errorf("branch Stats = %+v, want %+v", branch.Stats, want)
+	}
+}
+
+func TestSummarizeFX(t *testing.T) {
+	t.Parallel()
+
+	revenues := []*revenuepb.Revenue{
+		// USD invoice booked at its 55.5 snapshot, 600 USD paid at 57.
+		{Id: "u1", Status: "complete", Currency: "USD", TotalAmount: 100000, RevenueDate: strPtr("2026-03-02")},
+		// USD invoice without a snapshot: the 2026-03-01 rate of 56.
+		{Id: "u2", Status: "complete", Currency: "USD", TotalAmount: 10000, RevenueDate: strPtr("2026-03-05")},
+		// Functional-currency invoice, unpaid.
+		{Id: "p1", Status: "complete", Currency: "PHP", TotalAmount: 50000, RevenueDate: strPtr("2026-03-03")},
+	}
+	payments := []*revenuepaymentpb.RevenuePayment{
+		{RevenueId: "u1", Amount: 60000, PaymentDate: strPtr("2026-03-12")},
+	}
+	fx := shared.NewFXTranslation("PHP",
+		[]*shared.FXRate{
+			{From: "USD", To: "PHP", Date: day("2026-03-01"), RateMicroUnits: 56_000_000},
+			{From: "USD", To: "PHP", Date: day("2026-03-10"), RateMicroUnits: 57_000_000},
+		},
+		[]*shared.FXSnapshot{
+			{Kind: shared.FXDocumentRevenue, DocumentID: "u1", Currency: "USD", Functional: "PHP", RateMicroUnits: 55_500_000},
+		},
+	)
+	req := &Request{Now: time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)}
+
+	resp := SummarizeFX(req, revenues, payments, nil, fx)
+	want := Stats{
+		Invoiced:     5550000 + 560000 + 50000,
+		Collected:    3420000,
+		Outstanding:  2220000 + 560000 + 50000,
+		InvoiceCount: 3,
+		FXGainLoss:   90000, // 600 USD x (57 - 55.5)
+	}
+	if resp.Stats != want {
+		t.Errorf("Stats = %+v, want %+v", resp.Stats, want)
+	}
+	if !resp.Translated {
+		t.Error("Translated = false, want true")
+	}
+
+	// Without a translation the amounts add up as stored.
+	if raw := Summarize(req, revenues, payments, nil); raw.Stats.Invoiced != 160000 || raw.Translated {
+		t.Errorf("Summarize Invoiced = %d, Translated = %v", raw.Stats.Invoiced, raw.Translated)
+	}
+}
+
+func TestTrendPercent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cur, prev int64
+		want      string
+		up        bool
+	}{
+		{112, 100, "+12%", true},
+		{95, 100, "-5%", false},
+		{100, 100, "+0%", true},
+		{5, 0, "", true},
+	}
+	for _, tt := range tests {
+		got, up := trendPercent(tt.cur, tt.prev)
+		if got != tt.want || up != tt.up {
+			t.Errorf("trendPercent(%d, %d) = %q, %v; want %q, %v", tt.cur, tt.prev, got, up, tt.want, tt.up)
+		}
+	}
+}
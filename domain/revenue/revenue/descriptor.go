@@ -29,6 +29,21 @@ func Describe() compose.Unit {
 					Label: "Complete", Icon: "icon-check-circle", Permission: "invoice:list"},
 				{Key: "cancelled", Route: "revenue.list", Params: map[string]string{"status": "cancelled"},
 					Label: "Cancelled", Icon: "icon-x-circle", Permission: "invoice:list"},
+				// Storefront orders awaiting pickup or delivery, all locations
+				{Key: "fulfillment", Route: "revenue.fulfillment.queue", Params: map[string]string{"location": "all"},
+					Label: "Fulfillment", Icon: "icon-truck", Permission: "invoice:list"},
+				// Accounts receivable aging, as of today
+				{Key: "aging", Route: "revenue.aging",
+					Label: "AR Aging", Icon: "icon-clock", Permission: "invoice:list"},
+				// Payment reminder schedule and preview
+				{Key: "dunning", Route: "revenue.dunning",
+					Label: "Reminders", Icon: "icon-mail", Permission: "invoice:list"},
+				// Customer statements of account, month to date
+				{Key: "statements", Route: "revenue.statements",
+					Label: "Statements", Icon: "icon-file-text", Permission: "invoice:list"},
+				// Daily FX rates for foreign-currency invoices
+				{Key: "fx-rates", Route: "revenue.settings.fx_rates",
+					Label: "FX Rates", Icon: "icon-refresh-cw", Permission: "forex_rate:list"},
 				// Note: invoice templates URL (SettingsTemplatesURL) is not in the
 				// revenue RouteMap — it will be added in Phase 2 sidebar skeleton.
 			},
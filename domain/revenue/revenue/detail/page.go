@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	revenueemail "github.com/erniealice/centymo-golang/domain/revenue/revenue/email"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	lynguaV1 "github.com/erniealice/lyngua/golang/v1"
 	"log"
 
@@ -39,6 +41,18 @@ type DetailViewDeps struct {
 	// nil-safe (renders an empty payment table).
 	ListRevenuePayments func(ctx context.Context, req *revenuepaymentpb.ListRevenuePaymentsRequest) (*revenuepaymentpb.ListRevenuePaymentsResponse, error)
 
+	// Reminder log for the reminders tab (optional — the tab is hidden when
+	// nil).
+	Dunning shared.DunningStore
+
+	// Emails logs the sale's emails for the emails tab and the email button
+	// (optional — both are hidden unless email.Enabled).
+	Emails *revenueemail.Deps
+
+	// EInvoice shows the e-invoice (UBL XML) download next to the invoice
+	// download; set when the download handler has a seller party wired.
+	EInvoice bool
+
 	attachment.AttachmentOps
 	auditlog.AuditOps
 }
@@ -75,7 +89,12 @@ type PageData struct {
 	PaymentStatusVariant string
 	AuditTable           *types.TableConfig
 	AttachmentTable      *types.TableConfig
+	ReminderTable        *types.TableConfig
+	EmailTable           *types.TableConfig
+	EmailComposeURL      string // blank = invoice emails unavailable
+	EmailTemplatesURL    string
 	InvoiceDownloadURL   string
+	EInvoiceDownloadURL  string // blank = e-invoice export unavailable
 	// Audit history tab
 	AuditEntries    []auditlog.AuditEntryView
 	AuditHasNext    bool
@@ -116,7 +135,7 @@ func NewView(deps *DetailViewDeps) view.View {
 		if activeTab == "" {
 			activeTab = "info"
 		}
-		tabItems := buildTabItems(l, id, deps.Routes)
+		tabItems := buildTabItems(l, id, deps.Routes, deps.Dunning != nil, revenueemail.Enabled(deps.Emails))
 
 		pageData := &PageData{
 			PageData: types.PageData{
@@ -129,12 +148,14 @@ func NewView(deps *DetailViewDeps) view.View {
 				HeaderIcon:     "icon-shopping-bag",
 				CommonLabels:   deps.CommonLabels,
 			},
-			ContentTemplate:    "revenue-detail-content",
-			Revenue:            revenue,
-			Labels:             l,
-			ActiveTab:          activeTab,
-			TabItems:           tabItems,
-			InvoiceDownloadURL: route.ResolveURL(deps.Routes.InvoiceDownloadURL, "id", id),
+			ContentTemplate:     "revenue-detail-content",
+			Revenue:             revenue,
+			Labels:              l,
+			ActiveTab:           activeTab,
+			TabItems:            tabItems,
+			InvoiceDownloadURL:  route.ResolveURL(deps.Routes.InvoiceDownloadURL, "id", id),
+			EInvoiceDownloadURL: eInvoiceDownloadURL(deps, id),
+			EmailComposeURL:     emailComposeURL(deps, id),
 		}
 
 		// Load tab-specific data
@@ -181,6 +202,15 @@ func NewView(deps *DetailViewDeps) view.View {
 		case "audit":
 			pageData.AuditTable = buildAuditTable(l, deps.TableLabels)
 
+		case "reminders":
+			pageData.ReminderTable = buildReminderTable(ctx, deps, id)
+
+		case "emails":
+			if revenueemail.Enabled(deps.Emails) {
+				pageData.EmailTable = revenueemail.BuildTable(ctx, deps.Emails, id)
+				pageData.EmailTemplatesURL = deps.Routes.EmailTemplatesURL
+			}
+
 		case "attachments":
 			if deps.ListAttachments != nil {
 				cfg := attachmentConfig(deps)
@@ -230,10 +260,10 @@ func NewView(deps *DetailViewDeps) view.View {
 	})
 }
 
-func buildTabItems(l revenuedomain.Labels, id string, routes revenuedomain.Routes) []pyeza.TabItem {
+func buildTabItems(l revenuedomain.Labels, id string, routes revenuedomain.Routes, reminders, emails bool) []pyeza.TabItem {
 	base := route.ResolveURL(routes.DetailURL, "id", id)
 	action := route.ResolveURL(routes.TabActionURL, "id", id, "tab", "")
-	return []pyeza.TabItem{
+	items := []pyeza.TabItem{
 		{Key: "info", Label: l.Detail.TabBasicInfo, Href: base + "?tab=info", HxGet: action + "info", Icon: "icon-info"},
 		{Key: "items", Label: l.Detail.TabLineItems, Href: base + "?tab=items", HxGet: action + "items", Icon: "icon-list"},
 		{Key: "payment", Label: l.Detail.TabPayment, Href: base + "?tab=payment", HxGet: action + "payment", Icon: "icon-credit-card"},
@@ -241,6 +271,13 @@ func buildTabItems(l revenuedomain.Labels, id string, routes revenuedomain.Route
 		{Key: "attachments", Label: l.Detail.TabAttachments, Href: base + "?tab=attachments", HxGet: action + "attachments", Icon: "icon-paperclip"},
 		{Key: "audit-history", Label: l.Detail.TabAuditHistory, Href: base + "?tab=audit-history", HxGet: action + "audit-history", Icon: "icon-clock"},
 	}
+	if reminders {
+		items = append(items, pyeza.TabItem{Key: "reminders", Label: l.Detail.TabReminders, Href: base + "?tab=reminders", HxGet: action + "reminders", Icon: "icon-mail"})
+	}
+	if emails {
+		items = append(items, pyeza.TabItem{Key: "emails", Label: l.Detail.TabEmails, Href: base + "?tab=emails", HxGet: action + "emails", Icon: "icon-send"})
+	}
+	return items
 }
 
 // NewTabAction creates the tab action view (partial — returns only the tab content).
@@ -277,11 +314,13 @@ func NewTabAction(deps *DetailViewDeps) view.View {
 				CacheVersion: viewCtx.CacheVersion,
 				CommonLabels: deps.CommonLabels,
 			},
-			Revenue:            revenue,
-			Labels:             l,
-			ActiveTab:          tab,
-			TabItems:           buildTabItems(l, id, deps.Routes),
-			InvoiceDownloadURL: route.ResolveURL(deps.Routes.InvoiceDownloadURL, "id", id),
+			Revenue:             revenue,
+			Labels:              l,
+			ActiveTab:           tab,
+			TabItems:            buildTabItems(l, id, deps.Routes, deps.Dunning != nil, revenueemail.Enabled(deps.Emails)),
+			InvoiceDownloadURL:  route.ResolveURL(deps.Routes.InvoiceDownloadURL, "id", id),
+			EInvoiceDownloadURL: eInvoiceDownloadURL(deps, id),
+			EmailComposeURL:     emailComposeURL(deps, id),
 		}
 
 		switch tab {
@@ -325,6 +364,15 @@ func NewTabAction(deps *DetailViewDeps) view.View {
 		case "audit":
 			pageData.AuditTable = buildAuditTable(l, deps.TableLabels)
 
+		case "reminders":
+			pageData.ReminderTable = buildReminderTable(ctx, deps, id)
+
+		case "emails":
+			if revenueemail.Enabled(deps.Emails) {
+				pageData.EmailTable = revenueemail.BuildTable(ctx, deps.Emails, id)
+				pageData.EmailTemplatesURL = deps.Routes.EmailTemplatesURL
+			}
+
 		case "attachments":
 			if deps.ListAttachments != nil {
 				cfg := attachmentConfig(deps)
@@ -668,3 +716,21 @@ func findPayment(payments []*revenuepaymentpb.RevenuePayment, revenueID string,
 		Currency:   currency,
 	}
 }
+
+// eInvoiceDownloadURL is the invoice download in the "ubl" format, or "" when
+// deps.EInvoice is off.
+func eInvoiceDownloadURL(deps *DetailViewDeps, id string) string {
+	if !deps.EInvoice {
+		return ""
+	}
+	return route.ResolveURL(deps.Routes.InvoiceDownloadURL, "id", id) + "?format=ubl"
+}
+
+// emailComposeURL is the compose drawer of the sale's emails, or "" when
+// invoice emails are unwired.
+func emailComposeURL(deps *DetailViewDeps, id string) string {
+	if !revenueemail.Enabled(deps.Emails) {
+		return ""
+	}
+	return route.ResolveURL(deps.Routes.EmailComposeURL, "id", id)
+}
@@ -0,0 +1,78 @@
+package detail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/pyeza-golang/types"
+)
+
+// buildReminderTable lists the payment reminders sent, or attempted, for a
+// revenue, newest first.
+func buildReminderTable(ctx context.Context, deps *DetailViewDeps, revenueID string) *types.TableConfig {
+	l := deps.Labels.Dunning
+	reminders, err := deps.Dunning.ListDunningReminders(ctx, revenueID)
+	if err != nil {
+		log.Printf("Failed to list reminders for revenue %s: %v", revenueID, err)
+	}
+
+	columns := []types.TableColumn{
+		{Key: "sent_at", Label: l.SentAt, WidthClass: "col-4xl"},
+		{Key: "step", Label: l.Step, WidthClass: "col-4xl"},
+		{Key: "recipient", Label: l.Recipient, WidthClass: "col-6xl"},
+		{Key: "subject", Label: l.Subject},
+		{Key: "status", Label: l.Status, WidthClass: "col-3xl"},
+		{Key: "error", Label: l.Error},
+	}
+	rows := []types.TableRow{}
+	for i, r := range reminders {
+		status, variant := l.StatusSent, "success"
+		if r.Error != "" {
+			status, variant = l.StatusFailed, "danger"
+		}
+		rows = append(rows, types.TableRow{
+			ID: strconv.Itoa(i),
+			Cells: []types.TableCell{
+				{Type: "text", Value: r.SentAt.Format(types.DateTimeReadable)},
+				{Type: "text", Value: reminderStepLabel(l.BeforeDue, l.OnDueDate, l.AfterDue, r)},
+				{Type: "text", Value: r.Recipient},
+				{Type: "text", Value: r.Subject},
+				{Type: "badge", Value: status, Variant: variant},
+				{Type: "text", Value: r.Error},
+			},
+			DataAttrs: map[string]string{
+				"level": r.Level,
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:          "reminders-table",
+		Columns:     columns,
+		Rows:        rows,
+		ShowSearch:  false,
+		ShowActions: false,
+		Labels:      deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title: l.HistoryEmpty,
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+// reminderStepLabel describes when a reminder's step is sent, e.g. "7 days
+// overdue".
+func reminderStepLabel(before, on, after string, r *shared.DunningReminder) string {
+	switch {
+	case r.OffsetDays < 0:
+		return fmt.Sprintf(before, -r.OffsetDays)
+	case r.OffsetDays == 0:
+		return on
+	}
+	return fmt.Sprintf(after, r.OffsetDays)
+}
@@ -0,0 +1,442 @@
+package dunning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// PageData holds the data for the reminder preview page.
+type PageData struct {
+	types.PageData
+	ContentTemplate string
+	Labels          revenuedomain.DunningLabels
+	PolicyURL       string
+	PolicyEnabled   bool
+	CanEdit         bool
+	Table           *types.TableConfig
+}
+
+// RunFormData is the template data for the run-now drawer.
+type RunFormData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Message      string
+	CanRun       bool
+	CommonLabels any
+	Labels       revenuedomain.DunningLabels
+}
+
+// PolicyFormData is the template data for the policy drawer. Steps holds the
+// policy's steps plus one blank row for adding a step.
+type PolicyFormData struct {
+	FormAction    string
+	WorkspaceID   string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Enabled       bool
+	AttachInvoice bool
+	Steps         []PolicyStep
+	Levels        []pyeza.SelectOption
+	CommonLabels  any
+	Labels        revenuedomain.DunningLabels
+}
+
+// PolicyStep is one step row of the policy drawer.
+type PolicyStep struct {
+	Title   string
+	Offset  string
+	Level   string
+	Subject string
+	Body    string
+}
+
+// NewView creates the reminder preview page: today's dry run, with the
+// policy state and the run-now and policy actions.
+func NewView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		res, err := Run(ctx, deps, true)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.Dunning
+		return view.OK("revenue-dunning", &PageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          l.PageTitle,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      "revenue",
+				ActiveSubNav:   "dunning",
+				HeaderTitle:    l.PageTitle,
+				HeaderSubtitle: fmt.Sprintf(l.Caption, res.AsOf.Format(types.DateReadable)),
+				HeaderIcon:     "icon-mail",
+				CommonLabels:   deps.CommonLabels,
+			},
+			ContentTemplate: "revenue-dunning-content",
+			Labels:          l,
+			PolicyURL:       deps.Routes.DunningPolicyURL,
+			PolicyEnabled:   res.Policy.Enabled,
+			CanEdit:         perms.Can("invoice", "update"),
+			Table:           buildTable(ctx, deps, res),
+		})
+	})
+}
+
+// NewTableView returns only the preview table card.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		res, err := Run(ctx, deps, true)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", buildTable(ctx, deps, res))
+	})
+}
+
+// NewRunAction creates the run-now action (GET = confirmation drawer, POST =
+// send today's reminders).
+func NewRunAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		l := deps.Labels.Dunning
+		if viewCtx.Request.Method == http.MethodGet {
+			res, err := Run(ctx, deps, true)
+			if err != nil {
+				return view.Error(err)
+			}
+			data := &RunFormData{
+				FormAction:   deps.Routes.DunningRunURL,
+				CanRun:       res.Policy.Enabled && res.Due() > 0,
+				CommonLabels: deps.CommonLabels,
+				Labels:       l,
+			}
+			switch {
+			case !res.Policy.Enabled:
+				data.Message = l.RunDisabled
+			case res.Due() == 0:
+				data.Message = l.RunNothing
+			default:
+				data.Message = fmt.Sprintf(l.RunMessage, res.Due())
+			}
+			return view.OK("revenue-dunning-run-form", data)
+		}
+
+		res, err := Run(ctx, deps, false)
+		if err != nil {
+			log.Printf("dunning: run failed: %v", err)
+			return view.HTMXError(l.RunFailed)
+		}
+		if !res.Policy.Enabled {
+			return view.HTMXError(l.RunDisabled)
+		}
+		log.Printf("dunning: %d reminders sent, %d failed", res.Sent, res.Failed)
+		return view.HTMXSuccess("dunning-table")
+	})
+}
+
+// NewPolicyAction creates the policy action (GET = drawer form, POST = save).
+// Saving reloads the page so the policy state in the toolbar is current.
+func NewPolicyAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		if viewCtx.Request.Method == http.MethodGet {
+			policy, err := readPolicy(ctx, deps.Store)
+			if err != nil {
+				return view.Error(err)
+			}
+			return view.OK("revenue-dunning-policy-form", policyFormData(deps, policy))
+		}
+
+		if err := viewCtx.Request.ParseForm(); err != nil {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		policy, msg := parsePolicy(deps, viewCtx.Request)
+		if msg != "" {
+			return view.HTMXError(msg)
+		}
+		if err := policy.Validate(); err != nil {
+			return view.HTMXError(policyErrorMessage(deps, err))
+		}
+		if err := deps.Store.SaveDunningPolicy(ctx, policy); err != nil {
+			log.Printf("Failed to save dunning policy: %v", err)
+			return view.HTMXError(err.Error())
+		}
+		return view.ViewResult{
+			StatusCode: http.StatusOK,
+			Headers: map[string]string{
+				"HX-Trigger":  `{"formSuccess":true}`,
+				"HX-Redirect": deps.Routes.DunningURL,
+			},
+		}
+	})
+}
+
+// NewOptOutAction creates the client opt-out toggle (POST only). The client
+// ID and the new state come via ?client= and ?opt_out=1|0.
+func NewOptOutAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		_ = viewCtx.Request.ParseForm()
+		clientID := viewCtx.Request.FormValue("client")
+		if clientID == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+		optOut := viewCtx.Request.FormValue("opt_out") == "1"
+		if err := deps.Store.SetDunningOptOut(ctx, clientID, optOut); err != nil {
+			log.Printf("Failed to set reminder opt-out for client %s: %v", clientID, err)
+			return view.HTMXError(err.Error())
+		}
+		return view.HTMXSuccess("dunning-table")
+	})
+}
+
+func buildTable(ctx context.Context, deps *Deps, res *Result) *types.TableConfig {
+	perms := view.GetUserPermissions(ctx)
+	l := deps.Labels.Dunning
+
+	columns := []types.TableColumn{
+		{Key: "client", Label: l.Client, WidthClass: "col-7xl"},
+		{Key: "reference", Label: l.Reference, WidthClass: "col-4xl"},
+		{Key: "due_date", Label: l.DueDate, WidthClass: "col-3xl"},
+		{Key: "balance", Label: l.Balance, WidthClass: "col-3xl", Align: "right"},
+		{Key: "step", Label: l.Step, WidthClass: "col-4xl"},
+		{Key: "recipient", Label: l.Recipient, WidthClass: "col-6xl"},
+		{Key: "subject", Label: l.Subject},
+		{Key: "status", Label: l.Status, WidthClass: "col-3xl"},
+	}
+
+	rows := []types.TableRow{}
+	for _, rem := range res.Reminders {
+		line := rem.Line
+		href := route.ResolveURL(deps.Routes.DetailURL, "id", line.RevenueID) + "?tab=reminders"
+		reference := line.Reference
+		if reference == "" {
+			reference = line.RevenueID
+		}
+		status, variant := statusBadge(l, rem.Status)
+
+		optOut := types.TableAction{
+			Type: "deactivate", Label: l.OptOut, Action: "deactivate",
+			URL: optOutURL(deps, line.ClientID, true), ItemName: line.ClientName,
+			ConfirmTitle: l.OptOut, ConfirmMessage: fmt.Sprintf(l.OptOutConfirm, line.ClientName),
+			Disabled: !perms.Can("invoice", "update"), DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		}
+		if rem.Status == StatusOptedOut {
+			optOut = types.TableAction{
+				Type: "activate", Label: l.OptIn, Action: "activate",
+				URL: optOutURL(deps, line.ClientID, false), ItemName: line.ClientName,
+				ConfirmTitle: l.OptIn, ConfirmMessage: fmt.Sprintf(l.OptInConfirm, line.ClientName),
+				Disabled: !perms.Can("invoice", "update"), DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+			}
+		}
+
+		rows = append(rows, types.TableRow{
+			ID:   line.RevenueID,
+			Href: href,
+			Cells: []types.TableCell{
+				{Type: "text", Value: line.ClientName},
+				{Type: "text", Value: reference},
+				{Type: "text", Value: line.DueDate.Format(types.DateReadable)},
+				types.MoneyCell(float64(line.Balance), line.Currency, true),
+				{Type: "text", Value: stepLabel(l, rem.Step)},
+				{Type: "text", Value: rem.Recipient},
+				{Type: "text", Value: rem.Subject},
+				{Type: "badge", Value: status, Variant: variant},
+			},
+			DataAttrs: map[string]string{
+				"client": line.ClientName,
+				"status": rem.Status,
+			},
+			Actions: []types.TableAction{
+				{Type: "view", Label: deps.Labels.Actions.View, Action: "view", Href: href},
+				optOut,
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:                   "dunning-table",
+		RefreshURL:           deps.Routes.DunningTableURL,
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowSort:             true,
+		ShowColumns:          true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "client",
+		DefaultSortDirection: "asc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+		PrimaryAction: &types.PrimaryAction{
+			Label:           l.RunNow,
+			ActionURL:       deps.Routes.DunningRunURL,
+			Icon:            "icon-send",
+			Disabled:        !perms.Can("invoice", "update"),
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+func optOutURL(deps *Deps, clientID string, optOut bool) string {
+	v := "0"
+	if optOut {
+		v = "1"
+	}
+	return deps.Routes.DunningOptOutURL + "?client=" + url.QueryEscape(clientID) + "&opt_out=" + v
+}
+
+// stepLabel describes when a step is sent, e.g. "7 days overdue".
+func stepLabel(l revenuedomain.DunningLabels, s shared.DunningStep) string {
+	switch {
+	case s.OffsetDays < 0:
+		return fmt.Sprintf(l.BeforeDue, -s.OffsetDays)
+	case s.OffsetDays == 0:
+		return l.OnDueDate
+	}
+	return fmt.Sprintf(l.AfterDue, s.OffsetDays)
+}
+
+func statusBadge(l revenuedomain.DunningLabels, status string) (string, string) {
+	switch status {
+	case StatusSent:
+		return l.StatusSent, "success"
+	case StatusFailed:
+		return l.StatusFailed, "danger"
+	case StatusOptedOut:
+		return l.StatusOptedOut, "default"
+	case StatusNoEmail:
+		return l.StatusNoEmail, "warning"
+	}
+	return l.StatusDue, "info"
+}
+
+func levelOptions(l revenuedomain.DunningLabels) []pyeza.SelectOption {
+	names := map[string]string{
+		shared.DunningLevelReminder: l.LevelReminder,
+		shared.DunningLevelDue:      l.LevelDue,
+		shared.DunningLevelOverdue:  l.LevelOverdue,
+		shared.DunningLevelFinal:    l.LevelFinal,
+	}
+	var opts []pyeza.SelectOption
+	for _, level := range shared.DunningLevels() {
+		opts = append(opts, pyeza.SelectOption{Value: level, Label: names[level]})
+	}
+	return opts
+}
+
+func policyFormData(deps *Deps, policy *shared.DunningPolicy) *PolicyFormData {
+	l := deps.Labels.Dunning
+	data := &PolicyFormData{
+		FormAction:    deps.Routes.DunningPolicyURL,
+		Enabled:       policy.Enabled,
+		AttachInvoice: policy.AttachInvoice,
+		Levels:        levelOptions(l),
+		CommonLabels:  deps.CommonLabels,
+		Labels:        l,
+	}
+	for i, s := range policy.Steps {
+		data.Steps = append(data.Steps, PolicyStep{
+			Title:   fmt.Sprintf(l.StepTitle, i+1),
+			Offset:  strconv.Itoa(s.OffsetDays),
+			Level:   s.Level,
+			Subject: s.Subject,
+			Body:    s.Body,
+		})
+	}
+	data.Steps = append(data.Steps, PolicyStep{Title: l.NewStep, Level: shared.DunningLevelOverdue})
+	return data
+}
+
+// parsePolicy reads the policy drawer. Step fields are repeated, one value
+// per row; rows left without a subject and message are dropped, which is how
+// a step is removed.
+func parsePolicy(deps *Deps, r *http.Request) (*shared.DunningPolicy, string) {
+	policy := &shared.DunningPolicy{
+		Enabled:       r.PostForm.Get("enabled") != "",
+		AttachInvoice: r.PostForm.Get("attach_invoice") != "",
+	}
+	offsets := r.PostForm["step_offset"]
+	levels := r.PostForm["step_level"]
+	subjects := r.PostForm["step_subject"]
+	bodies := r.PostForm["step_body"]
+	for i := range offsets {
+		subject, body := strings.TrimSpace(at(subjects, i)), strings.TrimSpace(at(bodies, i))
+		if subject == "" && body == "" {
+			continue
+		}
+		offset, err := strconv.Atoi(strings.TrimSpace(offsets[i]))
+		if err != nil {
+			return nil, deps.Labels.Dunning.InvalidOffset
+		}
+		policy.Steps = append(policy.Steps, shared.DunningStep{
+			OffsetDays: offset,
+			Level:      at(levels, i),
+			Subject:    subject,
+			Body:       body,
+		})
+	}
+	return policy, ""
+}
+
+func at(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+func policyErrorMessage(deps *Deps, err error) string {
+	l := deps.Labels.Dunning
+	switch {
+	case errors.Is(err, shared.ErrDunningNoSteps):
+		return l.ErrorNoSteps
+	case errors.Is(err, shared.ErrDunningStepOrder):
+		return l.ErrorStepOrder
+	case errors.Is(err, shared.ErrDunningStepLevel):
+		return l.ErrorStepLevel
+	case errors.Is(err, shared.ErrDunningStepTemplate):
+		return l.ErrorStepTemplate
+	}
+	return deps.Labels.Errors.InvalidFormData
+}
@@ -0,0 +1,234 @@
+// Package dunning sends automated payment reminders. The workspace's
+// shared.DunningPolicy says when — e.g. 3 days before the due date, on it,
+// then 7, 14 and 30 days overdue — and what each reminder says. Run scans
+// the open invoices of the aging report, emails the reminders that are due
+// with the invoice attached and logs each one on its revenue; a dry run
+// returns the same plan without sending, for the preview page.
+//
+// Consumer apps schedule Run once a day per workspace (RevenueModule.RunDunning);
+// a missed day is caught up on the next run with the latest step only.
+package dunning
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/types"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+// Reminder statuses reported by Run.
+const (
+	StatusDue      = "due" // dry run: would be sent
+	StatusSent     = "sent"
+	StatusFailed   = "failed"
+	StatusOptedOut = "opted_out"
+	StatusNoEmail  = "no_email"
+)
+
+// Deps holds dependencies for the dunning runner and views.
+type Deps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	Store shared.DunningStore
+
+	// LoadReport ages the open invoices at asOf (aging.LoadReport), net of
+	// payments and of the credit and debit notes issued against them; paid
+	// and fully credited invoices are not in it, so their reminders stop.
+	LoadReport func(ctx context.Context, asOf time.Time) (*shared.AgingReport, error)
+
+	// ReadRevenue resolves the client's email address.
+	ReadRevenue func(ctx context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error)
+
+	// RenderAttachment renders the invoice to attach
+	// (action.RenderInvoiceAttachment). Optional — reminders are sent without
+	// an attachment when nil.
+	RenderAttachment func(ctx context.Context, revenueID string) (name string, data []byte, err error)
+
+	// SendEmail delivers a reminder (injected from the espyna email adapter).
+	SendEmail func(ctx context.Context, to []string, subject, htmlBody, textBody string, attachmentName string, attachmentData []byte) error
+
+	// Now returns the current time for the as-of date and the log (nil =
+	// time.Now).
+	Now func() time.Time
+}
+
+// Reminder is one reminder of a run: the invoice, the policy step and the
+// rendered message, with what happened to it.
+type Reminder struct {
+	Line      *shared.AgingLine
+	Step      shared.DunningStep
+	StepIndex int
+	Recipient string
+	Subject   string
+	Body      string
+	Status    string
+	Error     string
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	AsOf      time.Time
+	Policy    *shared.DunningPolicy
+	Reminders []*Reminder
+	Sent      int
+	Failed    int
+}
+
+// Due counts the reminders a dry run would send.
+func (r *Result) Due() int {
+	n := 0
+	for _, rem := range r.Reminders {
+		if rem.Status == StatusDue {
+			n++
+		}
+	}
+	return n
+}
+
+// Run plans today's reminders and, unless dryRun is set or the policy is
+// disabled, sends them. Every send attempt is logged on its revenue; a failed
+// one is retried on the next run. Invoices of opted-out clients and clients
+// without an email address are reported but never sent.
+func Run(ctx context.Context, deps *Deps, dryRun bool) (*Result, error) {
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+	t := now()
+	asOf := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	policy, err := readPolicy(ctx, deps.Store)
+	if err != nil {
+		return nil, err
+	}
+	report, err := deps.LoadReport(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+	sent, err := deps.Store.ListDunningReminders(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reminder log: %w", err)
+	}
+	optOuts, err := deps.Store.ListDunningOptOuts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reminder opt-outs: %w", err)
+	}
+
+	res := &Result{AsOf: asOf, Policy: policy}
+	send := !dryRun && policy.Enabled
+	for _, action := range shared.PlanDunning(policy, report, sent, optOuts, asOf) {
+		line := action.Line
+		step := policy.Steps[action.Step]
+		vars := shared.DunningVars(line, types.FormatMoney(line.Balance, line.Currency))
+		if vars["reference"] == "" {
+			vars["reference"] = line.RevenueID
+		}
+		rem := &Reminder{
+			Line:      line,
+			Step:      step,
+			StepIndex: action.Step,
+			Subject:   shared.ExpandEmail(step.Subject, vars),
+			Body:      shared.ExpandEmail(step.Body, vars),
+			Status:    StatusDue,
+		}
+		res.Reminders = append(res.Reminders, rem)
+
+		if action.OptedOut {
+			rem.Status = StatusOptedOut
+			continue
+		}
+		rem.Recipient = recipient(ctx, deps, line.RevenueID)
+		if rem.Recipient == "" {
+			rem.Status = StatusNoEmail
+			continue
+		}
+		if !send {
+			continue
+		}
+
+		deliver(ctx, deps, policy, rem)
+		entry := &shared.DunningReminder{
+			RevenueID:  line.RevenueID,
+			ClientID:   line.ClientID,
+			Step:       action.Step,
+			OffsetDays: step.OffsetDays,
+			Level:      step.Level,
+			Recipient:  rem.Recipient,
+			Subject:    rem.Subject,
+			SentAt:     now().UTC(),
+			Error:      rem.Error,
+		}
+		if rem.Status == StatusSent {
+			res.Sent++
+		} else {
+			res.Failed++
+		}
+		if err := deps.Store.LogDunningReminder(ctx, entry); err != nil {
+			log.Printf("dunning: failed to log reminder for %s: %v", line.RevenueID, err)
+		}
+	}
+	return res, nil
+}
+
+// deliver emails rem, attaching the invoice when the policy asks for it. An
+// attachment that fails to render is logged and the reminder goes without it.
+func deliver(ctx context.Context, deps *Deps, policy *shared.DunningPolicy, rem *Reminder) {
+	var name string
+	var data []byte
+	if policy.AttachInvoice && deps.RenderAttachment != nil {
+		n, d, err := deps.RenderAttachment(ctx, rem.Line.RevenueID)
+		if err != nil {
+			log.Printf("dunning: failed to render invoice %s, sending without it: %v", rem.Line.RevenueID, err)
+		} else {
+			name, data = n, d
+		}
+	}
+	if err := deps.SendEmail(ctx, []string{rem.Recipient}, rem.Subject, shared.EmailHTML(rem.Body), rem.Body, name, data); err != nil {
+		log.Printf("dunning: failed to send reminder for %s to %s: %v", rem.Line.RevenueID, rem.Recipient, err)
+		rem.Status = StatusFailed
+		rem.Error = err.Error()
+		return
+	}
+	rem.Status = StatusSent
+}
+
+// readPolicy returns the saved policy, or the default one.
+func readPolicy(ctx context.Context, store shared.DunningStore) (*shared.DunningPolicy, error) {
+	policy, err := store.ReadDunningPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dunning policy: %w", err)
+	}
+	if policy == nil || len(policy.Steps) == 0 {
+		return shared.DefaultDunningPolicy(), nil
+	}
+	return policy, nil
+}
+
+// recipient returns the email address of the revenue's client, "" when it
+// has none or cannot be read.
+func recipient(ctx context.Context, deps *Deps, revenueID string) string {
+	if deps.ReadRevenue == nil {
+		return ""
+	}
+	resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: revenueID}})
+	if err != nil {
+		log.Printf("dunning: failed to read revenue %s: %v", revenueID, err)
+		return ""
+	}
+	if data := resp.GetData(); len(data) > 0 {
+		return strings.TrimSpace(data[0].GetClient().GetUser().GetEmailAddress())
+	}
+	return ""
+}
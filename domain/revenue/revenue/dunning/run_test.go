@@ -0,0 +1,257 @@
+package dunning
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
+	userpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/user"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+type sentEmail struct {
+	to             []string
+	subject, text  string
+	attachmentName string
+}
+
+// strPtr returns a pointer to its argument for optional protobuf fields.
+func strPtr(s string) *string { return &s }
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// testDeps ages three open invoices at 2026-03-31: r1 (Acme, due 03-24, 7
+// days overdue), r2 (Acme, due 04-03, reminder before due) and r3 (Bolt, no
+// email address, due 03-01).
+func testDeps(store shared.DunningStore, outbox *[]sentEmail, sendErr *error) *Deps {
+	emails := map[string]string{"r1": "ap@acme.test", "r2": "ap@acme.test"}
+	return &Deps{
+		Store: store,
+		LoadReport: func(_ context.Context, asOf time.Time) (*shared.AgingReport, error) {
+			return shared.AgeReceivables([]shared.ReceivableInvoice{
+				{RevenueID: "r1", Reference: "INV-1", ClientID: "acme", ClientName: "Acme", Currency: "PHP", Amount: 150000, InvoiceDate: day("2026-02-22"), DueDate: day("2026-03-24")},
+				{RevenueID: "r2", Reference: "INV-2", ClientID: "acme", ClientName: "Acme", Currency: "PHP", Amount: 50000, InvoiceDate: day("2026-03-04"), DueDate: day("2026-04-03")},
+				{RevenueID: "r3", Reference: "INV-3", ClientID: "bolt", ClientName: "Bolt", Currency: "PHP", Amount: 20000, InvoiceDate: day("2026-01-30"), DueDate: day("2026-03-01")},
+			}, []shared.ReceivableEntry{
+				{RevenueID: "r1", Amount: 50000, At: day("2026-03-10")},
+			}, nil, asOf, shared.DefaultAgingLimits), nil
+		},
+		ReadRevenue: func(_ context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+			id := req.GetData().GetId()
+			return &revenuepb.ReadRevenueResponse{Data: []*revenuepb.Revenue{{
+				Id:     id,
+				Client: &clientpb.Client{User: &userpb.User{EmailAddress: emails[id]}},
+			}}}, nil
+		},
+		RenderAttachment: func(_ context.Context, id string) (string, []byte, error) {
+			return "invoice-" + id + ".pdf", []byte("%PDF"), nil
+		},
+		SendEmail: func(_ context.Context, to []string, subject, _, text, name string, _ []byte) error {
+			if *sendErr != nil {
+				return *sendErr
+			}
+			*outbox = append(*outbox, sentEmail{to, subject, text, name})
+			return nil
+		},
+		Now: func() time.Time { return time.Date(2026, 3, 31, 7, 0, 0, 0, time.UTC) },
+	}
+}
+
+func statuses(res *Result) map[string]string {
+	out := map[string]string{}
+	for _, rem := range res.Reminders {
+		out[rem.Line.RevenueID] = rem.Status
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := shared.NewMemoryDunningStore()
+	var outbox []sentEmail
+	var sendErr error
+	deps := testDeps(store, &outbox, &sendErr)
+
+	// The default policy starts disabled: a real run sends nothing.
+	res, err := Run(ctx, deps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 0 || res.Due() != 2 {
+		t.Fatalf("disabled policy: sent %d, due %d", len(outbox), res.Due())
+	}
+	if got := statuses(res); got["r1"] != StatusDue || got["r2"] != StatusDue || got["r3"] != StatusNoEmail {
+		t.Errorf("statuses = %v", got)
+	}
+
+	policy := shared.DefaultDunningPolicy()
+	policy.Enabled = true
+	_ = store.SaveDunningPolicy(ctx, policy)
+
+	// A dry run previews without sending or logging.
+	if _, err := Run(ctx, deps, true); err != nil {
+		t.Fatal(err)
+	}
+	if logged, _ := store.ListDunningReminders(ctx, ""); len(outbox) != 0 || len(logged) != 0 {
+		t.Fatalf("dry run sent %d, logged %d", len(outbox), len(logged))
+	}
+
+	res, err = Run(ctx, deps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 2 || len(outbox) != 2 {
+		t.Fatalf("sent %d (%d emails), want 2", res.Sent, len(outbox))
+	}
+	first := outbox[0]
+	if first.subject != "Overdue: INV-1" || first.attachmentName != "invoice-r1.pdf" || first.to[0] != "ap@acme.test" {
+		t.Errorf("first email = %+v", first)
+	}
+	if !strings.Contains(first.text, "PHP 1,000.00") || !strings.Contains(first.text, "7 days overdue") {
+		t.Errorf("body not expanded: %q", first.text)
+	}
+	logged, _ := store.ListDunningReminders(ctx, "r1")
+	if len(logged) != 1 || logged[0].OffsetDays != 7 || logged[0].Error != "" {
+		t.Errorf("r1 log = %+v", logged)
+	}
+
+	// Running again the same day sends nothing new.
+	if res, _ = Run(ctx, deps, false); res.Sent != 0 || len(outbox) != 2 {
+		t.Errorf("second run sent %d", res.Sent)
+	}
+
+	// Opted-out clients are skipped; failed sends are logged and retried.
+	_ = store.SetDunningOptOut(ctx, "acme", true)
+	deps.Now = func() time.Time { return time.Date(2026, 4, 7, 7, 0, 0, 0, time.UTC) }
+	res, _ = Run(ctx, deps, false)
+	if got := statuses(res); got["r1"] != StatusOptedOut {
+		t.Errorf("opted-out statuses = %v", got)
+	}
+	_ = store.SetDunningOptOut(ctx, "acme", false)
+	sendErr = errors.New("smtp down")
+	if res, _ = Run(ctx, deps, false); res.Failed != 2 {
+		t.Errorf("failed = %d, want 2", res.Failed)
+	}
+	if logged, _ = store.ListDunningReminders(ctx, "r1"); logged[0].Error != "smtp down" {
+		t.Errorf("failure not logged: %+v", logged[0])
+	}
+	sendErr = nil
+	if res, _ = Run(ctx, deps, false); res.Sent != 2 {
+		t.Errorf("retry sent %d, want 2", res.Sent)
+	}
+}
+
+func TestRunNotes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// Three invoices due 03-24: INV-1 (1,500.00) with a 500.00 credit note,
+	// INV-2 (200.00) with a 50.00 debit note and INV-3 (300.00) credited in
+	// full. Reminders ask for the balances the notes leave, once.
+	notes := shared.NewMemoryRevenueNoteStore()
+	for _, n := range []*shared.RevenueNote{
+		{RevenueID: "cn1", Kind: shared.RevenueNoteCredit, ReferenceNumber: "CN-1", OriginalRevenueID: "r1", Subtotal: -50000, IssuedAt: day("2026-03-20")},
+		{RevenueID: "dn1", Kind: shared.RevenueNoteDebit, ReferenceNumber: "DN-1", OriginalRevenueID: "r2", Subtotal: 5000, IssuedAt: day("2026-03-20")},
+		{RevenueID: "cn3", Kind: shared.RevenueNoteCredit, ReferenceNumber: "CN-3", OriginalRevenueID: "r3", Subtotal: -30000, IssuedAt: day("2026-03-20")},
+	} {
+		_ = notes.SaveRevenueNote(ctx, n)
+	}
+	invoice := func(id, ref, client string, amount int64) *revenuepb.Revenue {
+		return &revenuepb.Revenue{Id: id, ClientId: client, Name: client, Currency: "PHP", TotalAmount: amount, CashAmountExpected: &amount,
+			ReferenceNumber: &ref, RevenueDate: strPtr("2026-02-22"), DueDate: strPtr("2026-03-24")}
+	}
+	note := func(id, ref string, amount int64) *revenuepb.Revenue {
+		return &revenuepb.Revenue{Id: id, Currency: "PHP", TotalAmount: amount, CashAmountExpected: &amount, ReferenceNumber: &ref, RevenueDate: strPtr("2026-03-20")}
+	}
+	ledger := &aging.Deps{
+		GetListPageData: func(context.Context, *revenuepb.GetRevenueListPageDataRequest) (*revenuepb.GetRevenueListPageDataResponse, error) {
+			return &revenuepb.GetRevenueListPageDataResponse{RevenueList: []*revenuepb.Revenue{
+				invoice("r1", "INV-1", "acme", 150000), invoice("r2", "INV-2", "bolt", 20000), invoice("r3", "INV-3", "cara", 30000),
+				note("cn1", "CN-1", -50000), note("dn1", "DN-1", 5000), note("cn3", "CN-3", -30000),
+			}}, nil
+		},
+		Notes: notes,
+	}
+
+	store := shared.NewMemoryDunningStore()
+	var outbox []sentEmail
+	var sendErr error
+	deps := testDeps(store, &outbox, &sendErr)
+	deps.LoadReport = func(ctx context.Context, asOf time.Time) (*shared.AgingReport, error) {
+		return aging.LoadReport(ctx, ledger, asOf, shared.DefaultAgingLimits)
+	}
+	deps.ReadRevenue = func(_ context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+		return &revenuepb.ReadRevenueResponse{Data: []*revenuepb.Revenue{{
+			Id:     req.GetData().GetId(),
+			Client: &clientpb.Client{User: &userpb.User{EmailAddress: "ap@example.test"}},
+		}}}, nil
+	}
+	policy := shared.DefaultDunningPolicy()
+	policy.Enabled = true
+	_ = store.SaveDunningPolicy(ctx, policy)
+
+	res, err := Run(ctx, deps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 2 || len(outbox) != 2 {
+		t.Fatalf("sent %d, want INV-1 and INV-2 only: %+v", res.Sent, statuses(res))
+	}
+	for _, want := range []struct{ subject, amount string }{{"Overdue: INV-1", "PHP 1,000.00"}, {"Overdue: INV-2", "PHP 250.00"}} {
+		found := false
+		for _, e := range outbox {
+			if e.subject == want.subject {
+				found = true
+				if !strings.Contains(e.text, want.amount) {
+					t.Errorf("%s asks for %q, want %s", want.subject, e.text, want.amount)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no reminder %q", want.subject)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	form := url.Values{
+		"enabled":      {"on"},
+		"step_offset":  {"7", "-3", ""},
+		"step_level":   {"overdue", "reminder", "final"},
+		"step_subject": {"Late {reference}", "Soon {reference}", ""},
+		"step_body":    {"Pay {balance}", "Due {due_date}", ""},
+	}
+	r := &http.Request{PostForm: form}
+	policy, msg := parsePolicy(&Deps{}, r)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !policy.Enabled || policy.AttachInvoice || len(policy.Steps) != 2 || policy.Steps[0].OffsetDays != -3 {
+		t.Errorf("policy = %+v", policy)
+	}
+
+	deps := &Deps{}
+	deps.Labels.Dunning.InvalidOffset = "invalid offset"
+	form["step_offset"] = []string{"soon", "-3", ""}
+	if _, msg := parsePolicy(deps, r); msg != "invalid offset" {
+		t.Errorf("message = %q, want the invalid offset label", msg)
+	}
+}
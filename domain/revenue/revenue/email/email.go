@@ -0,0 +1,371 @@
+// Package email sends a sale's documents by email from the workspace's
+// templates: the compose drawer (recipients with CC and BCC, the subject and
+// message of the chosen purpose's template, the attachment format and an
+// optional send time) with its preview, the emails tab on the sale detail
+// page and the templates drawer. Every send is logged on the revenue — who
+// sent it and when, the recipients, the attachment's SHA-256 and the email
+// provider's message ID — whether it went out, failed or is scheduled.
+//
+// Consumer apps deliver scheduled sends by calling RunScheduled
+// (RevenueModule.RunScheduledEmails) from their scheduler, per workspace; a
+// send is delivered on the first run at or after its time.
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/types"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+// Attachment formats of a send; FormatNone sends the message alone.
+const (
+	FormatNone = ""
+	FormatPDF  = "pdf"
+	FormatDOCX = "docx"
+	FormatUBL  = "ubl"
+)
+
+// Deps holds dependencies for the email views and the scheduled run.
+type Deps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	Store shared.EmailStore
+
+	// ReadRevenue resolves the client's email address for the To field.
+	ReadRevenue func(ctx context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error)
+
+	// LoadData returns the revenue's template data, whose dotted keys are the
+	// placeholders (action.InvoiceEmailData).
+	LoadData func(ctx context.Context, revenueID string) (map[string]any, error)
+
+	// RenderAttachment renders the revenue's document in a format
+	// (action.RenderInvoiceAttachmentAs). Optional — only FormatNone is
+	// offered when nil.
+	RenderAttachment func(ctx context.Context, revenueID, format string) (name string, data []byte, err error)
+	// EInvoice offers FormatUBL, the PEPPOL BIS e-invoice.
+	EInvoice bool
+
+	// SendMessage delivers a message with its CC and BCC and returns the
+	// provider's message ID (injected from the espyna email adapter).
+	// Optional — SendEmail is used when nil: To and CC go out as one message
+	// and each BCC address gets its own copy, with no message ID.
+	SendMessage func(ctx context.Context, msg *shared.EmailMessage) (messageID string, err error)
+	SendEmail   func(ctx context.Context, to []string, subject, htmlBody, textBody string, attachmentName string, attachmentData []byte) error
+
+	// UserID returns the acting user for the log. Optional.
+	UserID func(ctx context.Context) string
+
+	// Now returns the current time for the log and the scheduled run (nil =
+	// time.Now).
+	Now func() time.Time
+}
+
+// Enabled reports whether the email views can run with deps.
+func Enabled(deps *Deps) bool {
+	return deps != nil && deps.Store != nil && deps.LoadData != nil &&
+		(deps.SendMessage != nil || deps.SendEmail != nil) &&
+		deps.Routes.EmailComposeURL != ""
+}
+
+// Deliver sends a logged email: it renders the attachment in send.Format,
+// sends the message and saves send as sent, with the attachment's name and
+// hash and the message ID, or as failed with the error, which it returns.
+// When the message went out but the log cannot be saved, the stored send
+// keeps its prior status — sending, for a claimed scheduled send — so the
+// scheduled run does not deliver it again.
+func Deliver(ctx context.Context, deps *Deps, send *shared.EmailSend) error {
+	err := deliver(ctx, deps, send)
+	send.SentAt = now(deps)
+	if err != nil {
+		send.Status, send.Error = shared.EmailStatusFailed, err.Error()
+	} else {
+		send.Status, send.Error = shared.EmailStatusSent, ""
+	}
+	if serr := deps.Store.SaveEmailSend(ctx, send); serr != nil {
+		log.Printf("email: failed to log send %s for revenue %s: %v", send.ID, send.RevenueID, serr)
+		if err == nil {
+			err = fmt.Errorf("sent, but failed to log: %w", serr)
+		}
+	}
+	return err
+}
+
+func deliver(ctx context.Context, deps *Deps, send *shared.EmailSend) error {
+	if len(send.To) == 0 {
+		return shared.ErrEmailNoRecipients
+	}
+	msg := &shared.EmailMessage{
+		To:       send.To,
+		CC:       send.CC,
+		BCC:      send.BCC,
+		Subject:  send.Subject,
+		HTMLBody: shared.EmailHTML(send.Body),
+		TextBody: send.Body,
+	}
+	if send.Format != FormatNone {
+		if deps.RenderAttachment == nil {
+			return fmt.Errorf("attachments are not available")
+		}
+		name, data, err := deps.RenderAttachment(ctx, send.RevenueID, send.Format)
+		if err != nil {
+			return fmt.Errorf("failed to render attachment: %w", err)
+		}
+		msg.AttachmentName, msg.AttachmentData = name, data
+		send.AttachmentName, send.AttachmentSHA256 = name, shared.AttachmentHash(data)
+	}
+
+	if deps.SendMessage != nil {
+		id, err := deps.SendMessage(ctx, msg)
+		if err != nil {
+			return err
+		}
+		send.MessageID = id
+		return nil
+	}
+	to := append(append([]string(nil), msg.To...), msg.CC...)
+	if err := deps.SendEmail(ctx, to, msg.Subject, msg.HTMLBody, msg.TextBody, msg.AttachmentName, msg.AttachmentData); err != nil {
+		return err
+	}
+	for _, bcc := range msg.BCC {
+		if err := deps.SendEmail(ctx, []string{bcc}, msg.Subject, msg.HTMLBody, msg.TextBody, msg.AttachmentName, msg.AttachmentData); err != nil {
+			return fmt.Errorf("sent to %s, but not to BCC %s: %w", strings.Join(to, ", "), bcc, err)
+		}
+	}
+	return nil
+}
+
+// RunResult counts the scheduled sends of a run. Skipped sends were
+// cancelled, or claimed by another run, after they were listed.
+type RunResult struct {
+	Sent    int
+	Failed  int
+	Skipped int
+}
+
+// RunScheduled delivers the scheduled sends that are due. Each send is
+// claimed (scheduled → sending) before it goes out, so overlapping runs and
+// a cancel racing the run cannot send it twice or after it was cancelled. A
+// failed send is logged as failed and does not stop the rest; it is not
+// retried.
+func RunScheduled(ctx context.Context, deps *Deps) (*RunResult, error) {
+	due, err := deps.Store.ListDueEmailSends(ctx, now(deps))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled emails: %w", err)
+	}
+	res := &RunResult{}
+	for _, send := range due {
+		claimed, err := deps.Store.UpdateEmailSendStatusIf(ctx, send.ID, shared.EmailStatusScheduled, shared.EmailStatusSending)
+		if err != nil {
+			log.Printf("email: failed to claim scheduled send %s for revenue %s: %v", send.ID, send.RevenueID, err)
+			res.Failed++
+			continue
+		}
+		if !claimed {
+			res.Skipped++
+			continue
+		}
+		send.Status = shared.EmailStatusSending
+		if err := Deliver(ctx, deps, send); err != nil {
+			log.Printf("email: scheduled send %s for revenue %s failed: %v", send.ID, send.RevenueID, err)
+			res.Failed++
+			continue
+		}
+		res.Sent++
+	}
+	return res, nil
+}
+
+// Draft is an email before it is sent: the addresses as typed and the
+// subject and message with their placeholders.
+type Draft struct {
+	RevenueID string
+	Purpose   string
+	To        string
+	CC        string
+	BCC       string
+	Subject   string
+	Body      string
+	Format    string
+	SendAt    time.Time // zero = now
+}
+
+// newSend validates d and expands its placeholders with the revenue's data
+// into a send, scheduled when d.SendAt is after now.
+func newSend(ctx context.Context, deps *Deps, d *Draft) (*shared.EmailSend, error) {
+	to, err := shared.ParseEmailAddresses(d.To)
+	if err != nil {
+		return nil, err
+	}
+	if len(to) == 0 {
+		return nil, shared.ErrEmailNoRecipients
+	}
+	cc, err := shared.ParseEmailAddresses(d.CC)
+	if err != nil {
+		return nil, err
+	}
+	bcc, err := shared.ParseEmailAddresses(d.BCC)
+	if err != nil {
+		return nil, err
+	}
+	tpl := shared.EmailTemplate{Purpose: d.Purpose, Subject: d.Subject, Body: d.Body}
+	if err := tpl.Validate(); err != nil {
+		return nil, err
+	}
+	if !formatAllowed(deps, d.Format) {
+		return nil, errFormat
+	}
+	vars, err := loadVars(ctx, deps, d.RevenueID)
+	if err != nil {
+		return nil, err
+	}
+
+	at := now(deps)
+	send := &shared.EmailSend{
+		RevenueID: d.RevenueID,
+		Purpose:   d.Purpose,
+		To:        to,
+		CC:        cc,
+		BCC:       bcc,
+		Subject:   shared.ExpandEmail(d.Subject, vars),
+		Body:      shared.ExpandEmail(d.Body, vars),
+		Format:    d.Format,
+		CreatedAt: at,
+	}
+	if deps.UserID != nil {
+		send.CreatedBy = deps.UserID(ctx)
+	}
+	if d.SendAt.After(at) {
+		send.Status, send.ScheduledFor = shared.EmailStatusScheduled, d.SendAt
+	}
+	return send, nil
+}
+
+// errFormat is returned for an attachment format deps cannot render.
+var errFormat = errors.New("unsupported attachment format")
+
+func formatAllowed(deps *Deps, format string) bool {
+	switch format {
+	case FormatNone:
+		return true
+	case FormatPDF, FormatDOCX:
+		return deps.RenderAttachment != nil
+	case FormatUBL:
+		return deps.RenderAttachment != nil && deps.EInvoice
+	}
+	return false
+}
+
+// loadVars returns the revenue's placeholder values.
+func loadVars(ctx context.Context, deps *Deps, revenueID string) (map[string]string, error) {
+	data, err := deps.LoadData(ctx, revenueID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sale %s: %w", revenueID, err)
+	}
+	return shared.EmailVars(data), nil
+}
+
+// NewSendHandler creates the quick-send handler for the sale list and the
+// subscription detail page: it emails the invoice to the client from the
+// workspace's invoice template and logs it. Query parameters:
+//   - format: "pdf" (default), "docx" or "ubl" — the attachment format.
+func NewSendHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := r.PathValue("id")
+		if id == "" {
+			http.Error(w, "sale ID required", http.StatusBadRequest)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatPDF
+		}
+		if format != FormatPDF && format != FormatDOCX && format != FormatUBL {
+			http.Error(w, "invalid format: must be \"pdf\", \"docx\" or \"ubl\"", http.StatusBadRequest)
+			return
+		}
+
+		to := recipient(ctx, deps, id)
+		if to == "" {
+			log.Printf("send-email: no email address for the client of revenue %s", id)
+			http.Error(w, "No email address found for the customer", http.StatusBadRequest)
+			return
+		}
+		tpl, err := shared.ReadEmailTemplateOrDefault(ctx, deps.Store, shared.EmailPurposeInvoice)
+		if err != nil {
+			log.Printf("send-email: %v", err)
+			http.Error(w, "failed to load email template", http.StatusInternalServerError)
+			return
+		}
+		send, err := newSend(ctx, deps, &Draft{
+			RevenueID: id,
+			Purpose:   shared.EmailPurposeInvoice,
+			To:        to,
+			Subject:   tpl.Subject,
+			Body:      tpl.Body,
+			Format:    format,
+		})
+		if err != nil {
+			log.Printf("send-email: revenue %s: %v", id, err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, errFormat) || errors.Is(err, shared.ErrEmailTemplate) || errors.Is(err, shared.ErrEmailAddress) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if err := Deliver(ctx, deps, send); err != nil {
+			log.Printf("send-email: failed to send email for revenue %s: %v", id, err)
+			http.Error(w, fmt.Sprintf("Failed to send email: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("send-email: revenue %s sent to %s (message %s)", id, to, send.MessageID)
+		w.Header().Set("HX-Trigger", `{"showToast":"Invoice email sent successfully"}`)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// recipient returns the email address of the revenue's client — its user's
+// address, else the client's own — or "" when it has none or cannot be read.
+func recipient(ctx context.Context, deps *Deps, revenueID string) string {
+	if deps.ReadRevenue == nil {
+		return ""
+	}
+	resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: revenueID}})
+	if err != nil {
+		log.Printf("email: failed to read revenue %s: %v", revenueID, err)
+		return ""
+	}
+	data := resp.GetData()
+	if len(data) == 0 {
+		return ""
+	}
+	client := data[0].GetClient()
+	if addr := strings.TrimSpace(client.GetUser().GetEmailAddress()); addr != "" {
+		return addr
+	}
+	return strings.TrimSpace(client.GetEmail())
+}
+
+func now(deps *Deps) time.Time {
+	if deps.Now != nil {
+		return deps.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,317 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
+	userpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/user"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+var testNow = time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)
+
+// testDeps serves revenue r1 (INV-1 for Acme, ap@acme.test) and sends
+// through SendMessage into outbox, or fails with *sendErr.
+func testDeps(outbox *[]*shared.EmailMessage, sendErr *error) *Deps {
+	deps := &Deps{
+		Routes: revenuedomain.DefaultRoutes(),
+		Store:  shared.NewMemoryEmailStore(),
+		ReadRevenue: func(_ context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+			return &revenuepb.ReadRevenueResponse{Data: []*revenuepb.Revenue{{
+				Id:     req.GetData().GetId(),
+				Client: &clientpb.Client{User: &userpb.User{EmailAddress: "ap@acme.test"}},
+			}}}, nil
+		},
+		LoadData: func(_ context.Context, id string) (map[string]any, error) {
+			return map[string]any{
+				"document": "Invoice",
+				"currency": "PHP",
+				"invoice":  map[string]any{"reference_number": "INV-1", "total_amount": "1,500.00"},
+				"customer": map[string]any{"name": "Acme"},
+			}, nil
+		},
+		RenderAttachment: func(_ context.Context, id, format string) (string, []byte, error) {
+			return "invoice-INV-1." + format, []byte("%PDF-" + id), nil
+		},
+		SendMessage: func(_ context.Context, msg *shared.EmailMessage) (string, error) {
+			if *sendErr != nil {
+				return "", *sendErr
+			}
+			*outbox = append(*outbox, msg)
+			return "msg-" + msg.To[0], nil
+		},
+		UserID: func(context.Context) string { return "u1" },
+		Now:    func() time.Time { return testNow },
+	}
+	deps.Labels.Email.Sent = "Email sent"
+	deps.Labels.Email.Scheduled = "Scheduled for %s"
+	deps.Labels.Email.SendFailed = "Failed: %s"
+	deps.Labels.Email.ErrorAddress = "Invalid address %s"
+	return deps
+}
+
+func ctxWithPerms(codes ...string) context.Context {
+	return view.WithUserPermissions(context.Background(), types.NewUserPermissions(codes))
+}
+
+func compose(deps *Deps, form url.Values) view.ViewResult {
+	req := httptest.NewRequest(http.MethodPost, "/action/revenue/detail/r1/email", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("id", "r1")
+	return NewComposeAction(deps).Handle(ctxWithPerms("invoice:read", "invoice:update"), &view.ViewContext{Request: req})
+}
+
+func TestDeliver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+
+	send, err := newSend(ctx, deps, &Draft{
+		RevenueID: "r1",
+		Purpose:   shared.EmailPurposeInvoice,
+		To:        "ap@acme.test",
+		CC:        "Jane <jane@acme.test>",
+		BCC:       "archive@us.test",
+		Subject:   "{document} {invoice.reference_number}",
+		Body:      "Dear {customer.name},\n\n{invoice.total_amount} {currency} is due.",
+		Format:    FormatPDF,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Deliver(ctx, deps, send); err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox) != 1 {
+		t.Fatalf("sent %d messages", len(outbox))
+	}
+	msg := outbox[0]
+	if msg.Subject != "Invoice INV-1" || msg.TextBody != "Dear Acme,\n\n1,500.00 PHP is due." || !reflect.DeepEqual(msg.CC, []string{"jane@acme.test"}) {
+		t.Errorf("message = %+v", msg)
+	}
+
+	logged, _ := deps.Store.ListEmailSends(ctx, "r1")
+	if len(logged) != 1 {
+		t.Fatalf("logged %d sends", len(logged))
+	}
+	got := logged[0]
+	if got.Status != shared.EmailStatusSent || got.MessageID != "msg-ap@acme.test" || got.CreatedBy != "u1" || !got.SentAt.Equal(testNow) ||
+		got.AttachmentName != "invoice-INV-1.pdf" || got.AttachmentSHA256 != shared.AttachmentHash([]byte("%PDF-r1")) {
+		t.Errorf("log = %+v", got)
+	}
+
+	sendErr = errors.New("mailbox full")
+	failed := &shared.EmailSend{RevenueID: "r1", To: []string{"ap@acme.test"}, Subject: "s", Body: "b"}
+	if err := Deliver(ctx, deps, failed); err == nil {
+		t.Fatal("want the send error")
+	}
+	if got, _ := deps.Store.ReadEmailSend(ctx, failed.ID); got.Status != shared.EmailStatusFailed || got.Error != "mailbox full" {
+		t.Errorf("failed send logged as %+v", got)
+	}
+}
+
+func TestDeliverLegacy(t *testing.T) {
+	t.Parallel()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+	deps.SendMessage = nil
+	var calls [][]string
+	deps.SendEmail = func(_ context.Context, to []string, _, _, _, _ string, _ []byte) error {
+		calls = append(calls, to)
+		return nil
+	}
+
+	send := &shared.EmailSend{RevenueID: "r1", To: []string{"a@x.test"}, CC: []string{"b@x.test"}, BCC: []string{"c@x.test", "d@x.test"}, Subject: "s", Body: "b"}
+	if err := Deliver(context.Background(), deps, send); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a@x.test", "b@x.test"}, {"c@x.test"}, {"d@x.test"}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestComposeAndSchedule(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+
+	form := url.Values{
+		"purpose": {shared.EmailPurposeReminder},
+		"to":      {"ap@acme.test"},
+		"subject": {"Reminder: {invoice.reference_number}"},
+		"body":    {"Dear {customer.name}"},
+		"format":  {FormatNone},
+		"mode":    {"preview"},
+	}
+	res := compose(deps, form)
+	data, ok := res.Data.(*ComposeFormData)
+	if res.Template != "revenue-email-compose-form" || !ok || data.Preview == nil {
+		t.Fatalf("preview = %+v", res)
+	}
+	if data.Preview.Subject != "Reminder: INV-1" || string(data.Preview.HTML) != "<p>Dear Acme</p>" || data.Subject != form.Get("subject") {
+		t.Errorf("preview = %+v", data.Preview)
+	}
+	if len(outbox) != 0 {
+		t.Error("preview sent the email")
+	}
+
+	form.Set("to", "ap@acme.test, nope")
+	form.Del("mode")
+	if res := compose(deps, form); res.Headers["HX-Error-Message"] != "Invalid address nope" {
+		t.Errorf("bad address = %+v", res)
+	}
+
+	form.Set("to", "ap@acme.test")
+	form.Set("send_at", testNow.Add(48*time.Hour).Local().Format(sendAtLayout))
+	if res := compose(deps, form); res.StatusCode != http.StatusOK || !strings.Contains(res.Headers["HX-Trigger"], "Scheduled for") {
+		t.Fatalf("schedule = %+v", res)
+	}
+	if len(outbox) != 0 {
+		t.Error("scheduled email sent right away")
+	}
+
+	if res, _ := RunScheduled(ctx, deps); res.Sent != 0 {
+		t.Errorf("sent %d before the send time", res.Sent)
+	}
+	deps.Now = func() time.Time { return testNow.Add(72 * time.Hour) }
+	res2, err := RunScheduled(ctx, deps)
+	if err != nil || res2.Sent != 1 || len(outbox) != 1 || outbox[0].Subject != "Reminder: INV-1" {
+		t.Fatalf("run = %+v, %v, outbox %d", res2, err, len(outbox))
+	}
+	if res2, _ := RunScheduled(ctx, deps); res2.Sent != 0 {
+		t.Error("scheduled email sent twice")
+	}
+
+	table := BuildTable(ctxWithPerms("invoice:read", "invoice:update"), deps, "r1")
+	if len(table.Rows) != 1 || table.Rows[0].DataAttrs["status"] != shared.EmailStatusSent {
+		t.Errorf("table rows = %+v", table.Rows)
+	}
+}
+
+func TestCancelScheduled(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+
+	send := &shared.EmailSend{RevenueID: "r1", To: []string{"a@x.test"}, Subject: "s", Body: "b", Status: shared.EmailStatusScheduled, ScheduledFor: testNow.Add(time.Hour)}
+	_ = deps.Store.SaveEmailSend(ctx, send)
+	table := BuildTable(ctxWithPerms("invoice:read", "invoice:update"), deps, "r1")
+	if len(table.Rows) != 1 || len(table.Rows[0].Actions) != 1 {
+		t.Fatalf("scheduled row has no cancel action: %+v", table.Rows)
+	}
+
+	cancel := func(revenueID string) view.ViewResult {
+		req := httptest.NewRequest(http.MethodPost, table.Rows[0].Actions[0].URL, nil)
+		req.SetPathValue("id", revenueID)
+		return NewCancelAction(deps).Handle(ctxWithPerms("invoice:update"), &view.ViewContext{Request: req})
+	}
+	if res := cancel("r2"); res.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("other revenue = %d", res.StatusCode)
+	}
+	if res := cancel("r1"); res.StatusCode != http.StatusOK {
+		t.Fatalf("cancel = %+v", res)
+	}
+	deps.Now = func() time.Time { return testNow.Add(2 * time.Hour) }
+	if res, _ := RunScheduled(ctx, deps); res.Sent != 0 || len(outbox) != 0 {
+		t.Error("cancelled email was sent")
+	}
+}
+
+// racyEmailStore cancels the due sends as the run lists them, as a cancel
+// racing the run would, or fails to log a send's outcome.
+type racyEmailStore struct {
+	*shared.MemoryEmailStore
+	cancelListed bool
+	failLog      bool
+}
+
+func (s *racyEmailStore) ListDueEmailSends(ctx context.Context, asOf time.Time) ([]*shared.EmailSend, error) {
+	due, err := s.MemoryEmailStore.ListDueEmailSends(ctx, asOf)
+	if s.cancelListed {
+		for _, d := range due {
+			_, _ = s.UpdateEmailSendStatusIf(ctx, d.ID, shared.EmailStatusScheduled, shared.EmailStatusCancelled)
+		}
+	}
+	return due, err
+}
+
+func (s *racyEmailStore) SaveEmailSend(ctx context.Context, send *shared.EmailSend) error {
+	if s.failLog && send.Status != shared.EmailStatusScheduled {
+		return errors.New("log store down")
+	}
+	return s.MemoryEmailStore.SaveEmailSend(ctx, send)
+}
+
+func TestRunScheduledClaims(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+	store := &racyEmailStore{MemoryEmailStore: shared.NewMemoryEmailStore(), cancelListed: true}
+	deps.Store = store
+	deps.Now = func() time.Time { return testNow.Add(2 * time.Hour) }
+
+	send := &shared.EmailSend{RevenueID: "r1", To: []string{"a@x.test"}, Subject: "s", Body: "b", Status: shared.EmailStatusScheduled, ScheduledFor: testNow.Add(time.Hour)}
+	_ = store.SaveEmailSend(ctx, send)
+	if res, _ := RunScheduled(ctx, deps); res.Sent != 0 || res.Skipped != 1 || len(outbox) != 0 {
+		t.Errorf("cancelled after listing: run = %+v, outbox %d", res, len(outbox))
+	}
+
+	store.cancelListed, store.failLog = false, true
+	send = &shared.EmailSend{RevenueID: "r1", To: []string{"a@x.test"}, Subject: "s", Body: "b", Status: shared.EmailStatusScheduled, ScheduledFor: testNow.Add(time.Hour)}
+	_ = store.SaveEmailSend(ctx, send)
+	if res, _ := RunScheduled(ctx, deps); res.Failed != 1 || len(outbox) != 1 {
+		t.Fatalf("unlogged send: run = %+v, outbox %d", res, len(outbox))
+	}
+	if got, _ := store.ReadEmailSend(ctx, send.ID); got.Status != shared.EmailStatusSending {
+		t.Errorf("unlogged send status = %s, want sending", got.Status)
+	}
+	if res, _ := RunScheduled(ctx, deps); res.Sent != 0 || len(outbox) != 1 {
+		t.Error("unlogged send was delivered again")
+	}
+}
+
+func TestSendHandler(t *testing.T) {
+	t.Parallel()
+	var outbox []*shared.EmailMessage
+	var sendErr error
+	deps := testDeps(&outbox, &sendErr)
+	_ = deps.Store.SaveEmailTemplate(context.Background(), &shared.EmailTemplate{Purpose: shared.EmailPurposeInvoice, Subject: "Your {document}", Body: "Hi {customer.name}"})
+
+	req := httptest.NewRequest(http.MethodPost, "/action/revenue/detail/r1/invoice/send-email?format=docx", nil)
+	req.SetPathValue("id", "r1")
+	rec := httptest.NewRecorder()
+	NewSendHandler(deps).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if len(outbox) != 1 || outbox[0].Subject != "Your Invoice" || outbox[0].AttachmentName != "invoice-INV-1.docx" {
+		t.Errorf("outbox = %+v", outbox)
+	}
+	if logged, _ := deps.Store.ListEmailSends(context.Background(), "r1"); len(logged) != 1 || logged[0].Format != FormatDOCX {
+		t.Errorf("log = %+v", logged)
+	}
+}
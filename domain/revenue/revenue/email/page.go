@@ -0,0 +1,478 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// tableID is the emails table, refreshed after a send or a cancel.
+const tableID = "revenue-emails-table"
+
+// sendAtLayout is the datetime-local input format of the send time.
+const sendAtLayout = "2006-01-02T15:04"
+
+// ComposeFormData is the template data for the compose drawer. Preview is
+// set after the preview button: the subject and message as they will be
+// sent.
+type ComposeFormData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Purpose      string
+	Purposes     []pyeza.SelectOption
+	To           string
+	CC           string
+	BCC          string
+	Subject      string
+	Body         string
+	Formats      []pyeza.SelectOption
+	SendAt       string
+	Placeholders string
+	Preview      *Preview
+	CommonLabels any
+	Labels       revenuedomain.EmailLabels
+}
+
+// Preview is a rendered draft.
+type Preview struct {
+	To      string
+	Subject string
+	HTML    template.HTML
+}
+
+// TemplatesFormData is the template data for the templates drawer: one
+// subject and message per purpose.
+type TemplatesFormData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Templates    []TemplateRow
+	Placeholders string
+	CommonLabels any
+	Labels       revenuedomain.EmailLabels
+}
+
+// TemplateRow is one purpose of the templates drawer.
+type TemplateRow struct {
+	Purpose string
+	Title   string
+	Subject string
+	Body    string
+}
+
+// NewComposeAction creates the compose drawer (GET = drawer prefilled from
+// the ?purpose= template and the client's address, POST = send, or schedule
+// when send_at is in the future). Posting with mode=preview re-renders the
+// drawer with the rendered subject and message instead of sending.
+func NewComposeAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels.Email
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+		r := viewCtx.Request
+		id := r.PathValue("id")
+		if id == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+
+		if r.Method == http.MethodGet {
+			purpose := r.URL.Query().Get("purpose")
+			if purpose == "" {
+				purpose = shared.EmailPurposeInvoice
+			}
+			tpl, err := shared.ReadEmailTemplateOrDefault(ctx, deps.Store, purpose)
+			if err != nil {
+				return view.HTMXError(errorMessage(l, err))
+			}
+			format := FormatNone
+			if formatAllowed(deps, FormatPDF) && purpose == shared.EmailPurposeInvoice {
+				format = FormatPDF
+			}
+			return view.OK("revenue-email-compose-form", composeFormData(ctx, deps, &Draft{
+				RevenueID: id,
+				Purpose:   purpose,
+				To:        recipient(ctx, deps, id),
+				Subject:   tpl.Subject,
+				Body:      tpl.Body,
+				Format:    format,
+			}))
+		}
+
+		if err := r.ParseForm(); err != nil {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		draft, msg := parseDraft(deps, r, id)
+		if msg != "" {
+			return view.HTMXError(msg)
+		}
+		send, err := newSend(ctx, deps, draft)
+		if err != nil {
+			return view.HTMXError(errorMessage(l, err))
+		}
+
+		if r.FormValue("mode") == "preview" {
+			data := composeFormData(ctx, deps, draft)
+			data.Preview = &Preview{
+				To:      strings.Join(send.Recipients(), ", "),
+				Subject: send.Subject,
+				HTML:    template.HTML(shared.EmailHTML(send.Body)),
+			}
+			return view.OK("revenue-email-compose-form", data)
+		}
+
+		if send.Status == shared.EmailStatusScheduled {
+			if err := deps.Store.SaveEmailSend(ctx, send); err != nil {
+				log.Printf("Failed to schedule email for revenue %s: %v", id, err)
+				return view.HTMXError(err.Error())
+			}
+			return success(fmt.Sprintf(l.Scheduled, send.ScheduledFor.Format(types.DateTimeReadable)))
+		}
+		if err := Deliver(ctx, deps, send); err != nil {
+			return view.HTMXError(fmt.Sprintf(l.SendFailed, err))
+		}
+		return success(l.Sent)
+	})
+}
+
+// NewCancelAction cancels a scheduled send (POST only). The send ID comes
+// via ?send=.
+func NewCancelAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels.Email
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+		_ = viewCtx.Request.ParseForm()
+		sendID := viewCtx.Request.FormValue("send")
+		if sendID == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+		send, err := deps.Store.ReadEmailSend(ctx, sendID)
+		if err != nil {
+			log.Printf("Failed to read email send %s: %v", sendID, err)
+			return view.HTMXError(err.Error())
+		}
+		if send == nil || send.RevenueID != viewCtx.Request.PathValue("id") {
+			return view.HTMXError(deps.Labels.Errors.NotFound)
+		}
+		if err := send.Cancel(); err != nil {
+			return view.HTMXError(errorMessage(l, err))
+		}
+		// The scheduled run may have claimed the send since it was read.
+		cancelled, err := deps.Store.UpdateEmailSendStatusIf(ctx, sendID, shared.EmailStatusScheduled, shared.EmailStatusCancelled)
+		if err != nil {
+			log.Printf("Failed to cancel email send %s: %v", sendID, err)
+			return view.HTMXError(err.Error())
+		}
+		if !cancelled {
+			return view.HTMXError(errorMessage(l, shared.ErrEmailNotScheduled))
+		}
+		return view.HTMXSuccess(tableID)
+	})
+}
+
+// NewTemplatesAction creates the templates drawer (GET = every purpose's
+// template, POST = save them).
+func NewTemplatesAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels.Email
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		if r.Method == http.MethodGet {
+			data := &TemplatesFormData{
+				FormAction:   deps.Routes.EmailTemplatesURL,
+				Placeholders: placeholders(nil),
+				Labels:       l,
+				CommonLabels: nil, // injected by ViewAdapter
+			}
+			for _, purpose := range shared.EmailPurposes() {
+				tpl, err := shared.ReadEmailTemplateOrDefault(ctx, deps.Store, purpose)
+				if err != nil {
+					return view.Error(err)
+				}
+				data.Templates = append(data.Templates, TemplateRow{
+					Purpose: purpose,
+					Title:   purposeLabel(l, purpose),
+					Subject: tpl.Subject,
+					Body:    tpl.Body,
+				})
+			}
+			return view.OK("revenue-email-templates-form", data)
+		}
+
+		if err := r.ParseForm(); err != nil {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		var templates []*shared.EmailTemplate
+		for _, purpose := range shared.EmailPurposes() {
+			tpl := &shared.EmailTemplate{
+				Purpose: purpose,
+				Subject: strings.TrimSpace(r.FormValue("subject_" + purpose)),
+				Body:    strings.TrimSpace(r.FormValue("body_" + purpose)),
+			}
+			if err := tpl.Validate(); err != nil {
+				return view.HTMXError(fmt.Sprintf("%s: %s", purposeLabel(l, purpose), errorMessage(l, err)))
+			}
+			templates = append(templates, tpl)
+		}
+		for _, tpl := range templates {
+			if err := deps.Store.SaveEmailTemplate(ctx, tpl); err != nil {
+				log.Printf("Failed to save %s email template: %v", tpl.Purpose, err)
+				return view.HTMXError(err.Error())
+			}
+		}
+		return view.ViewResult{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"HX-Trigger": `{"formSuccess":true}`},
+		}
+	})
+}
+
+// NewTableView returns the emails table partial for HTMX refresh.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "read") {
+			return view.Forbidden("invoice:read")
+		}
+		return view.OK("table-card", BuildTable(ctx, deps, viewCtx.Request.PathValue("id")))
+	})
+}
+
+// BuildTable lists the emails logged on a revenue, newest first, with a
+// Cancel action on the scheduled ones and the compose drawer as the primary
+// action.
+func BuildTable(ctx context.Context, deps *Deps, revenueID string) *types.TableConfig {
+	l := deps.Labels.Email
+	perms := view.GetUserPermissions(ctx)
+	canUpdate := perms.Can("invoice", "update")
+
+	sends, err := deps.Store.ListEmailSends(ctx, revenueID)
+	if err != nil {
+		log.Printf("Failed to list emails for revenue %s: %v", revenueID, err)
+	}
+
+	columns := []types.TableColumn{
+		{Key: "date", Label: l.SentAt, WidthClass: "col-4xl"},
+		{Key: "purpose", Label: l.Purpose, WidthClass: "col-3xl"},
+		{Key: "recipients", Label: l.Recipients, WidthClass: "col-6xl"},
+		{Key: "subject", Label: l.Subject},
+		{Key: "attachment", Label: l.Attachment, WidthClass: "col-5xl"},
+		{Key: "sent_by", Label: l.SentBy, WidthClass: "col-3xl"},
+		{Key: "message_id", Label: l.MessageID, WidthClass: "col-4xl"},
+		{Key: "status", Label: l.Status, WidthClass: "col-3xl"},
+	}
+	rows := []types.TableRow{}
+	for _, s := range sends {
+		status, variant, date := statusBadge(l, s)
+		attachment := s.AttachmentName
+		if s.AttachmentSHA256 != "" {
+			attachment = fmt.Sprintf("%s (%s %s)", attachment, l.AttachmentHash, s.AttachmentSHA256[:12])
+		}
+		row := types.TableRow{
+			ID: s.ID,
+			Cells: []types.TableCell{
+				{Type: "text", Value: date},
+				{Type: "text", Value: purposeLabel(l, s.Purpose)},
+				{Type: "text", Value: strings.Join(s.Recipients(), ", ")},
+				{Type: "text", Value: s.Subject},
+				{Type: "text", Value: attachment},
+				{Type: "text", Value: s.CreatedBy},
+				{Type: "text", Value: s.MessageID},
+				{Type: "badge", Value: status, Variant: variant},
+			},
+			DataAttrs: map[string]string{
+				"status":            s.Status,
+				"attachment-sha256": s.AttachmentSHA256,
+				"error":             s.Error,
+			},
+		}
+		if s.Status == shared.EmailStatusScheduled {
+			row.Actions = []types.TableAction{{
+				Type:            "delete",
+				Label:           l.Cancel,
+				Action:          "cancel",
+				URL:             route.ResolveURL(deps.Routes.EmailCancelURL, "id", revenueID) + "?send=" + s.ID,
+				ItemName:        s.Subject,
+				ConfirmTitle:    l.CancelConfirm,
+				ConfirmMessage:  fmt.Sprintf(l.CancelMessage, s.Subject),
+				Disabled:        !canUpdate,
+				DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+			}}
+		}
+		rows = append(rows, row)
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	cfg := &types.TableConfig{
+		ID:         tableID,
+		RefreshURL: route.ResolveURL(deps.Routes.EmailTableURL, "id", revenueID),
+		Columns:    columns,
+		Rows:       rows,
+		Labels:     deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+		PrimaryAction: &types.PrimaryAction{
+			Label:           l.Compose,
+			ActionURL:       route.ResolveURL(deps.Routes.EmailComposeURL, "id", revenueID),
+			Icon:            "icon-mail",
+			Disabled:        !canUpdate,
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		},
+	}
+	types.ApplyTableSettings(cfg)
+	return cfg
+}
+
+// statusBadge returns the badge and the date shown for a send: when it went
+// out, or when it is scheduled for.
+func statusBadge(l revenuedomain.EmailLabels, s *shared.EmailSend) (status, variant, date string) {
+	switch s.Status {
+	case shared.EmailStatusScheduled:
+		return l.StatusScheduled, "info", fmt.Sprintf(l.ScheduledFor, s.ScheduledFor.Format(types.DateTimeReadable))
+	case shared.EmailStatusSending:
+		return l.StatusSending, "warning", s.ScheduledFor.Format(types.DateTimeReadable)
+	case shared.EmailStatusCancelled:
+		return l.StatusCancelled, "default", s.ScheduledFor.Format(types.DateTimeReadable)
+	case shared.EmailStatusFailed:
+		return l.StatusFailed, "danger", s.SentAt.Format(types.DateTimeReadable)
+	}
+	return l.StatusSent, "success", s.SentAt.Format(types.DateTimeReadable)
+}
+
+// parseDraft reads the compose form. It returns a label message when the
+// send time cannot be read.
+func parseDraft(deps *Deps, r *http.Request, revenueID string) (*Draft, string) {
+	d := &Draft{
+		RevenueID: revenueID,
+		Purpose:   r.FormValue("purpose"),
+		To:        r.FormValue("to"),
+		CC:        r.FormValue("cc"),
+		BCC:       r.FormValue("bcc"),
+		Subject:   strings.TrimSpace(r.FormValue("subject")),
+		Body:      strings.TrimSpace(r.FormValue("body")),
+		Format:    r.FormValue("format"),
+	}
+	if s := strings.TrimSpace(r.FormValue("send_at")); s != "" {
+		at, err := time.ParseInLocation(sendAtLayout, s, time.Local)
+		if err != nil {
+			return nil, deps.Labels.Email.ErrorSendAt
+		}
+		d.SendAt = at
+	}
+	return d, ""
+}
+
+// composeFormData fills the compose drawer with d.
+func composeFormData(ctx context.Context, deps *Deps, d *Draft) *ComposeFormData {
+	l := deps.Labels.Email
+	data := &ComposeFormData{
+		FormAction:   route.ResolveURL(deps.Routes.EmailComposeURL, "id", d.RevenueID),
+		Purpose:      d.Purpose,
+		To:           d.To,
+		CC:           d.CC,
+		BCC:          d.BCC,
+		Subject:      d.Subject,
+		Body:         d.Body,
+		Labels:       l,
+		CommonLabels: nil, // injected by ViewAdapter
+	}
+	if !d.SendAt.IsZero() {
+		data.SendAt = d.SendAt.Format(sendAtLayout)
+	}
+	for _, purpose := range shared.EmailPurposes() {
+		data.Purposes = append(data.Purposes, pyeza.SelectOption{Value: purpose, Label: purposeLabel(l, purpose), Selected: purpose == d.Purpose})
+	}
+	for _, f := range []struct{ value, label string }{
+		{FormatNone, l.AttachmentNone},
+		{FormatPDF, l.AttachmentPdf},
+		{FormatDOCX, l.AttachmentDocx},
+		{FormatUBL, l.AttachmentUbl},
+	} {
+		if formatAllowed(deps, f.value) {
+			data.Formats = append(data.Formats, pyeza.SelectOption{Value: f.value, Label: f.label, Selected: f.value == d.Format})
+		}
+	}
+	vars, err := loadVars(ctx, deps, d.RevenueID)
+	if err != nil {
+		log.Printf("email: %v", err)
+	}
+	data.Placeholders = placeholders(vars)
+	return data
+}
+
+// placeholders lists the placeholders of vars for the form hint, or the
+// common ones when vars is nil.
+func placeholders(vars map[string]string) string {
+	if vars == nil {
+		return "{document}, {invoice.reference_number}, {invoice.date}, {invoice.total_amount}, {currency}, {customer.name}"
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, "{"+k+"}")
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+func purposeLabel(l revenuedomain.EmailLabels, purpose string) string {
+	switch purpose {
+	case shared.EmailPurposeInvoice:
+		return l.PurposeInvoice
+	case shared.EmailPurposeReminder:
+		return l.PurposeReminder
+	case shared.EmailPurposeStatement:
+		return l.PurposeStatement
+	case shared.EmailPurposeReceipt:
+		return l.PurposeReceipt
+	}
+	return purpose
+}
+
+// errorMessage maps a shared email error to its label.
+func errorMessage(l revenuedomain.EmailLabels, err error) string {
+	switch {
+	case errors.Is(err, shared.ErrEmailNoRecipients):
+		return l.ErrorNoRecipients
+	case errors.Is(err, shared.ErrEmailAddress):
+		return fmt.Sprintf(l.ErrorAddress, strings.TrimPrefix(err.Error(), shared.ErrEmailAddress.Error()+": "))
+	case errors.Is(err, shared.ErrEmailTemplate), errors.Is(err, shared.ErrEmailPurpose):
+		return l.ErrorTemplate
+	case errors.Is(err, shared.ErrEmailNotScheduled):
+		return l.ErrorNotScheduled
+	case errors.Is(err, errFormat):
+		return l.ErrorFormat
+	}
+	return err.Error()
+}
+
+// success closes the drawer, refreshes the emails table and shows msg.
+func success(msg string) view.ViewResult {
+	trigger := fmt.Sprintf(`{"formSuccess":true,"refreshTable":%q,"showToast":%q}`, tableID, msg)
+	return view.ViewResult{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"HX-Trigger": trigger},
+	}
+}
@@ -0,0 +1,112 @@
+package fulfillment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// NewAdvanceAction creates the fulfillment state-change action (POST only).
+// The order reference comes via query param (?id=ORD-...) appended by
+// table-actions.js; the target state comes as ?to=<state>.
+func NewAdvanceAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		_ = r.ParseForm()
+		ref := r.FormValue("id")
+		if ref == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+		to := r.FormValue("to")
+		if to == "" {
+			return view.HTMXError(deps.Labels.Errors.InvalidTargetStatus)
+		}
+
+		_, err := deps.AdvanceFulfillment(ctx, shared.FulfillmentTransition{
+			ReferenceNumber: ref,
+			To:              to,
+			Note:            r.FormValue("note"),
+		})
+		if err != nil {
+			log.Printf("Failed to move order %s to %s: %v", ref, to, err)
+			switch {
+			case errors.Is(err, shared.ErrOrderNotFound):
+				return view.HTMXError(deps.Labels.Errors.NotFound)
+			case errors.Is(err, shared.ErrInvalidFulfillmentTransition), errors.Is(err, shared.ErrOrderNotFulfillable):
+				return view.HTMXError(deps.Labels.Errors.InvalidTargetStatus)
+			}
+			return view.HTMXError(err.Error())
+		}
+
+		return view.HTMXSuccess("fulfillment-table")
+	})
+}
+
+// statusResponse is the JSON body storefronts poll. It deliberately leaves out
+// who moved the order and any staff notes.
+type statusResponse struct {
+	Reference       string        `json:"reference"`
+	OrderStatus     string        `json:"order_status"`
+	FulfillmentType string        `json:"fulfillment_type"`
+	State           string        `json:"state"`
+	StateLabel      string        `json:"state_label"`
+	Events          []statusEvent `json:"events"`
+}
+
+type statusEvent struct {
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+}
+
+// NewStatusHandler creates an http.HandlerFunc that returns the fulfillment
+// status of one order as JSON, looked up by its ORD- reference.
+func NewStatusHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := r.PathValue("reference")
+		if ref == "" {
+			http.Error(w, "order reference required", http.StatusBadRequest)
+			return
+		}
+
+		of, err := deps.GetOrderFulfillment(r.Context(), ref)
+		if err != nil {
+			if errors.Is(err, shared.ErrOrderNotFound) || errors.Is(err, shared.ErrOrderNotFulfillable) {
+				http.Error(w, "order not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("fulfillment: failed to load status for %s: %v", ref, err)
+			http.Error(w, "failed to load order status", http.StatusInternalServerError)
+			return
+		}
+
+		resp := statusResponse{
+			Reference:       of.ReferenceNumber,
+			OrderStatus:     of.OrderStatus,
+			FulfillmentType: of.FulfillmentType,
+			State:           of.State,
+			StateLabel:      StateLabel(deps.Labels, of.State),
+			Events:          []statusEvent{},
+		}
+		for _, e := range of.Events {
+			resp.Events = append(resp.Events, statusEvent{State: e.To, At: e.At})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("fulfillment: failed to encode status for %s: %v", ref, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package fulfillment owns the storefront order fulfillment views: the
+// per-location queue page, its table refresh, the state-change action and the
+// JSON status endpoint storefronts poll.
+package fulfillment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// allLocations is the {location} path value that lists every location.
+const allLocations = "all"
+
+// Deps holds dependencies for the fulfillment views. The closures come from
+// the consumer app's checkout service; AdvanceFulfillment is expected to fill
+// in the acting user from the request context.
+type Deps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	ListFulfillmentQueue shared.ListFulfillmentQueue
+	GetOrderFulfillment  shared.GetOrderFulfillment
+	AdvanceFulfillment   shared.AdvanceFulfillment
+}
+
+// PageData holds the data for the fulfillment queue page.
+type PageData struct {
+	types.PageData
+	ContentTemplate string
+	Table           *types.TableConfig
+}
+
+// NewView creates the fulfillment queue view (full page).
+func NewView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		tableConfig, err := buildTableConfig(ctx, deps, viewCtx.Request.PathValue("location"))
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.Fulfillment
+		pageData := &PageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          l.PageTitle,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      "revenue",
+				ActiveSubNav:   "fulfillment",
+				HeaderTitle:    l.PageTitle,
+				HeaderSubtitle: l.Caption,
+				HeaderIcon:     "icon-truck",
+				CommonLabels:   deps.CommonLabels,
+			},
+			ContentTemplate: "revenue-fulfillment-content",
+			Table:           tableConfig,
+		}
+		return view.OK("revenue-fulfillment", pageData)
+	})
+}
+
+// NewTableView creates a view that returns only the table-card HTML, the
+// refresh target after a state change.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		tableConfig, err := buildTableConfig(ctx, deps, viewCtx.Request.PathValue("location"))
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", tableConfig)
+	})
+}
+
+// buildTableConfig loads the queue for location ("all" or empty for every
+// location) and builds the table configuration.
+func buildTableConfig(ctx context.Context, deps *Deps, location string) (*types.TableConfig, error) {
+	perms := view.GetUserPermissions(ctx)
+	if location == "" {
+		location = allLocations
+	}
+	locationID := location
+	if locationID == allLocations {
+		locationID = ""
+	}
+
+	queue, err := deps.ListFulfillmentQueue(ctx, locationID)
+	if err != nil {
+		log.Printf("Failed to list fulfillment queue for %s: %v", location, err)
+		return nil, fmt.Errorf("failed to load fulfillment queue: %w", err)
+	}
+
+	columns := queueColumns(deps.Labels)
+	rows := buildTableRows(queue, deps.Labels, deps.Routes, perms)
+	types.ApplyColumnStyles(columns, rows)
+
+	l := deps.Labels.Fulfillment
+	tableConfig := &types.TableConfig{
+		ID:                   "fulfillment-table",
+		RefreshURL:           route.ResolveURL(deps.Routes.FulfillmentTableURL, "location", location),
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowFilters:          true,
+		ShowSort:             true,
+		ShowColumns:          true,
+		ShowDensity:          true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "ordered_at",
+		DefaultSortDirection: "asc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig, nil
+}
+
+func queueColumns(l revenuedomain.Labels) []types.TableColumn {
+	return []types.TableColumn{
+		{Key: "reference_number", Label: l.Columns.Reference},
+		{Key: "client_name", Label: l.Columns.Customer, WidthClass: "col-9xl"},
+		{Key: "fulfillment_type", Label: l.Fulfillment.Type, WidthClass: "col-3xl"},
+		{Key: "state", Label: l.Fulfillment.State, WidthClass: "col-3xl"},
+		{Key: "delivery_address", Label: l.Fulfillment.DeliveryAddress, NoSort: true, WidthClass: "col-9xl"},
+		{Key: "ordered_at", Label: l.Fulfillment.OrderedAt, WidthClass: "col-3xl"},
+		{Key: "total_amount", Label: l.Columns.Amount, WidthClass: "col-3xl", Align: "right"},
+	}
+}
+
+// buildTableRows renders one row per queued order with an action for each
+// state it may move to next. Rows are keyed by reference number, which is what
+// the advance action posts back.
+func buildTableRows(queue []*shared.OrderFulfillment, l revenuedomain.Labels, routes revenuedomain.Routes, perms *types.UserPermissions) []types.TableRow {
+	rows := []types.TableRow{}
+	for _, of := range queue {
+		ref := of.ReferenceNumber
+		detailURL := route.ResolveURL(routes.DetailURL, "id", of.RevenueID)
+
+		actions := []types.TableAction{
+			{Type: "view", Label: l.Actions.View, Action: "view", Href: detailURL},
+		}
+		for _, next := range of.NextStates {
+			stateLabel := StateLabel(l, next)
+			actions = append(actions, types.TableAction{
+				Type: actionIcon(next), Label: stateLabel, Action: "deactivate",
+				URL: routes.FulfillmentAdvanceURL + "?to=" + next, ItemName: ref,
+				ConfirmTitle: l.Fulfillment.ConfirmTitle, ConfirmMessage: fmt.Sprintf(l.Fulfillment.ConfirmMessage, ref, stateLabel),
+				Disabled: !perms.Can("invoice", "update"), DisabledTooltip: l.Errors.PermissionDenied,
+			})
+		}
+
+		orderedAt := ""
+		if !of.OrderedAt.IsZero() {
+			orderedAt = of.OrderedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, types.TableRow{
+			ID:   ref,
+			Href: detailURL,
+			Cells: []types.TableCell{
+				{Type: "text", Value: ref},
+				{Type: "text", Value: of.CustomerName},
+				{Type: "text", Value: typeLabel(l, of.FulfillmentType)},
+				{Type: "badge", Value: StateLabel(l, of.State), Variant: stateVariant(of.State)},
+				{Type: "text", Value: of.DeliveryAddress},
+				types.DateTimeCell(orderedAt, types.DateTimeReadable),
+				types.MoneyCell(float64(of.TotalAmount), of.Currency, true),
+			},
+			DataAttrs: map[string]string{
+				"reference": ref,
+				"customer":  of.CustomerName,
+				"state":     of.State,
+			},
+			Actions: actions,
+		})
+	}
+	return rows
+}
+
+// StateLabel returns the translated label for a fulfillment state, or the raw
+// state when no translation is configured.
+func StateLabel(l revenuedomain.Labels, state string) string {
+	if s := l.Fulfillment.States[state]; s != "" {
+		return s
+	}
+	return state
+}
+
+func typeLabel(l revenuedomain.Labels, fulfillmentType string) string {
+	if s := l.Fulfillment.Types[fulfillmentType]; s != "" {
+		return s
+	}
+	return fulfillmentType
+}
+
+func actionIcon(state string) string {
+	switch state {
+	case shared.FulfillmentFailedDelivery:
+		return "undo"
+	default:
+		return "check"
+	}
+}
+
+func stateVariant(state string) string {
+	switch state {
+	case shared.FulfillmentReadyForPickup, shared.FulfillmentShipped:
+		return "info"
+	case shared.FulfillmentFailedDelivery:
+		return "danger"
+	case shared.FulfillmentQueued:
+		return "warning"
+	default:
+		return "default"
+	}
+}
@@ -6,18 +6,27 @@ package revenue
 
 // Labels holds all translatable strings for the revenue module.
 type Labels struct {
-	Page      PageLabels      `json:"page"`
-	Buttons   ButtonLabels    `json:"buttons"`
-	Columns   ColumnLabels    `json:"columns"`
-	Empty     EmptyLabels     `json:"empty"`
-	Form      FormLabels      `json:"form"`
-	Actions   ActionLabels    `json:"actions"`
-	Bulk      BulkLabels      `json:"bulkActions"`
-	Detail    DetailLabels    `json:"detail"`
-	Confirm   ConfirmLabels   `json:"confirm"`
-	Errors    ErrorLabels     `json:"errors"`
-	Dashboard DashboardLabels `json:"dashboard"`
-	Settings  SettingsLabels  `json:"settings"`
+	Page        PageLabels        `json:"page"`
+	Buttons     ButtonLabels      `json:"buttons"`
+	Columns     ColumnLabels      `json:"columns"`
+	Empty       EmptyLabels       `json:"empty"`
+	Form        FormLabels        `json:"form"`
+	Actions     ActionLabels      `json:"actions"`
+	Bulk        BulkLabels        `json:"bulkActions"`
+	Detail      DetailLabels      `json:"detail"`
+	Confirm     ConfirmLabels     `json:"confirm"`
+	Errors      ErrorLabels       `json:"errors"`
+	Dashboard   DashboardLabels   `json:"dashboard"`
+	Settings    SettingsLabels    `json:"settings"`
+	Fulfillment FulfillmentLabels `json:"fulfillment"`
+	Notes       NoteLabels        `json:"notes"`
+	Export      ExportLabels      `json:"export"`
+	Numbering   NumberingLabels   `json:"numbering"`
+	Aging       AgingLabels       `json:"aging"`
+	Dunning     DunningLabels     `json:"dunning"`
+	Email       EmailLabels       `json:"email"`
+	Statement   StatementLabels   `json:"statement"`
+	FXRates     FXRateLabels      `json:"fxRates"`
 }
 
 type PageLabels struct {
@@ -108,9 +117,12 @@ type ActionLabels struct {
 	Complete          string `json:"complete"`
 	Reactivate        string `json:"reactivate"`
 	DownloadInvoice   string `json:"downloadInvoice"`
+	DownloadEInvoice  string `json:"downloadEInvoice"`
 	SendEmail         string `json:"sendEmail"`
 	Cancel            string `json:"cancel"`
 	ReclassifyToDraft string `json:"reclassifyToDraft"`
+	CreditNote        string `json:"creditNote"`
+	DebitNote         string `json:"debitNote"`
 }
 
 type BulkLabels struct {
@@ -139,6 +151,8 @@ type DetailLabels struct {
 	TabAttachments  string `json:"tabAttachments"`
 	TabAuditTrail   string `json:"tabAuditTrail"`
 	TabAuditHistory string `json:"tabAuditHistory"`
+	TabReminders    string `json:"tabReminders"`
+	TabEmails       string `json:"tabEmails"`
 
 	// Basic info fields
 	Customer     string `json:"customer"`
@@ -239,6 +253,7 @@ type ErrorLabels struct {
 	BulkHasPayments         string `json:"bulkHasPayments"`
 	BulkNoItems             string `json:"bulkNoItems"`
 	PaymentNotFound         string `json:"paymentNotFound"`
+	PaymentAllocated        string `json:"paymentAllocated"`
 	InvalidDiscount         string `json:"invalidDiscount"`
 	// RecomputeUnavailable is the 501 body returned by the RecomputeTaxes stub
 	// until Phase 4 wires ComputeTaxesForRevenue (Phase 5 M2).
@@ -263,6 +278,23 @@ type DashboardLabels struct {
 	RevenueCancelled  string `json:"revenueCancelled"`
 	QuickNewRevenue   string `json:"quickNewRevenue"`
 	QuickViewAll      string `json:"quickViewAll"`
+
+	// Aggregates and filters.
+	Invoiced         string `json:"invoiced"`
+	Collected        string `json:"collected"`
+	Outstanding      string `json:"outstanding"`
+	ByStatus         string `json:"byStatus"`
+	Draft            string `json:"draft"`
+	Cancelled        string `json:"cancelled"`
+	Pending          string `json:"pending"`
+	Paid             string `json:"paid"`
+	EmptyRecentTitle string `json:"emptyRecentTitle"`
+	EmptyRecentDesc  string `json:"emptyRecentDesc"`
+	DateRange        string `json:"dateRange"`
+	DateTo           string `json:"dateTo"`
+	Location         string `json:"location"`
+	AllLocations     string `json:"allLocations"`
+	Apply            string `json:"apply"`
 }
 
 // SettingsLabels holds translatable strings for the revenue settings page
@@ -281,4 +313,385 @@ type SettingsLabels struct {
 	EmptyMessage   string `json:"emptyMessage"`
 	UploadSuccess  string `json:"uploadSuccess"`
 	DeleteConfirm  string `json:"deleteConfirm"`
+
+	// Template check drawer: the placeholder report and the preview form.
+	Check              string `json:"check"`
+	CheckTitle         string `json:"checkTitle"`
+	CheckPassed        string `json:"checkPassed"`
+	CheckFailed        string `json:"checkFailed"`
+	Placeholders       string `json:"placeholders"`
+	Missing            string `json:"missing"`
+	MissingInfo        string `json:"missingInfo"`
+	Unused             string `json:"unused"`
+	UnusedInfo         string `json:"unusedInfo"`
+	Preview            string `json:"preview"`
+	PreviewRevenue     string `json:"previewRevenue"`
+	PreviewRevenueInfo string `json:"previewRevenueInfo"`
+	PreviewFormat      string `json:"previewFormat"`
+	PreviewFailed      string `json:"previewFailed"`
+	InvalidTemplate    string `json:"invalidTemplate"` // %s = parse error
+	TemplateNotFound   string `json:"templateNotFound"`
+	NotSettable        string `json:"notSettable"` // %s = missing placeholders
+}
+
+// FulfillmentLabels holds translatable strings for the storefront order
+// fulfillment queue. States and Types are keyed by the raw value
+// (e.g. "ready_for_pickup", "home_delivery"); a missing key shows the raw value.
+type FulfillmentLabels struct {
+	PageTitle       string            `json:"pageTitle"`
+	Caption         string            `json:"caption"`
+	Type            string            `json:"type"`
+	State           string            `json:"state"`
+	OrderedAt       string            `json:"orderedAt"`
+	DeliveryAddress string            `json:"deliveryAddress"`
+	EmptyTitle      string            `json:"emptyTitle"`
+	EmptyMessage    string            `json:"emptyMessage"`
+	ConfirmTitle    string            `json:"confirmTitle"`
+	ConfirmMessage  string            `json:"confirmMessage"` // %s reference, %s state
+	States          map[string]string `json:"states"`
+	Types           map[string]string `json:"types"`
+}
+
+// NoteLabels holds translatable strings for the credit/debit note drawer.
+type NoteLabels struct {
+	CreditNoteTitle  string `json:"creditNoteTitle"`
+	DebitNoteTitle   string `json:"debitNoteTitle"`
+	OriginalInvoice  string `json:"originalInvoice"`
+	Description      string `json:"description"`
+	Invoiced         string `json:"invoiced"`
+	Creditable       string `json:"creditable"`
+	Quantity         string `json:"quantity"`
+	QuantityInfo     string `json:"quantityInfo"`
+	Reason           string `json:"reason"`
+	ReasonInfo       string `json:"reasonInfo"`
+	Restock          string `json:"restock"`
+	NoLineItems      string `json:"noLineItems"`
+	Unavailable      string `json:"unavailable"`
+	NotAllowed       string `json:"notAllowed"`
+	EmptyNote        string `json:"emptyNote"`
+	QuantityExceeded string `json:"quantityExceeded"`
+	NoSequence       string `json:"noSequence"`
+	Conflict         string `json:"conflict"`
+	NumberingFailed  string `json:"numberingFailed"`
+	IssueFailed      string `json:"issueFailed"`
+}
+
+// ExportLabels holds translatable strings for the bulk invoice export: the
+// list bulk actions, the started toast and the progress page.
+type ExportLabels struct {
+	DownloadZip     string `json:"downloadZip"`
+	DownloadDocx    string `json:"downloadDocx"`
+	DownloadMerged  string `json:"downloadMerged"`
+	DownloadAll     string `json:"downloadAll"`
+	DownloadAllInfo string `json:"downloadAllInfo"`
+	Started         string `json:"started"` // %d documents
+	ViewProgress    string `json:"viewProgress"`
+	PageTitle       string `json:"pageTitle"`
+	Caption         string `json:"caption"`
+	Progress        string `json:"progress"` // %d done, %d total
+	Running         string `json:"running"`
+	Ready           string `json:"ready"`
+	Failed          string `json:"failed"`
+	FailedCount     string `json:"failedCount"` // %d documents
+	Download        string `json:"download"`
+	NothingToExport string `json:"nothingToExport"`
+	TooMany         string `json:"tooMany"` // %d limit
+	Busy            string `json:"busy"`    // %d exports running
+}
+
+// NumberingLabels holds translatable strings for the document numbering
+// settings: the sequence table and drawer, the issued-number register and the
+// void drawer. DocumentTypes is keyed by shared.Document* values.
+type NumberingLabels struct {
+	PageTitle        string            `json:"pageTitle"`
+	Caption          string            `json:"caption"`
+	AddSequence      string            `json:"addSequence"`
+	EditSequence     string            `json:"editSequence"`
+	DocumentType     string            `json:"documentType"`
+	Location         string            `json:"location"`
+	LocationInfo     string            `json:"locationInfo"`
+	AllLocations     string            `json:"allLocations"`
+	Prefix           string            `json:"prefix"`
+	PrefixInfo       string            `json:"prefixInfo"`
+	Suffix           string            `json:"suffix"`
+	SuffixInfo       string            `json:"suffixInfo"`
+	Padding          string            `json:"padding"`
+	PaddingInfo      string            `json:"paddingInfo"`
+	Reset            string            `json:"reset"`
+	ResetNever       string            `json:"resetNever"`
+	ResetYearly      string            `json:"resetYearly"`
+	ResetMonthly     string            `json:"resetMonthly"`
+	Pattern          string            `json:"pattern"`
+	Example          string            `json:"example"`
+	EmptyTitle       string            `json:"emptyTitle"`
+	EmptyMessage     string            `json:"emptyMessage"`
+	DeleteConfirm    string            `json:"deleteConfirm"` // %s pattern
+	Register         string            `json:"register"`
+	RegisterCaption  string            `json:"registerCaption"` // %s pattern
+	RegisterEmpty    string            `json:"registerEmpty"`
+	Number           string            `json:"number"`
+	Status           string            `json:"status"`
+	Issued           string            `json:"issued"`
+	Voided           string            `json:"voided"`
+	IssuedAt         string            `json:"issuedAt"`
+	VoidReason       string            `json:"voidReason"`
+	VoidReasonInfo   string            `json:"voidReasonInfo"`
+	Void             string            `json:"void"`
+	DocumentTypes    map[string]string `json:"documentTypes"`
+	InvalidType      string            `json:"invalidType"`
+	MissingMarker    string            `json:"missingMarker"` // %s marker
+	InvalidToken     string            `json:"invalidToken"`
+	InvalidPadding   string            `json:"invalidPadding"` // %d max
+	InvalidReset     string            `json:"invalidReset"`
+	MissingPeriod    string            `json:"missingPeriod"`
+	Conflict         string            `json:"conflict"`
+	InUse            string            `json:"inUse"`
+	Locked           string            `json:"locked"`
+	AlreadyVoided    string            `json:"alreadyVoided"`
+	AllocationFailed string            `json:"allocationFailed"`
+}
+
+// AgingLabels holds translatable strings for the accounts receivable aging
+// report: the filter bar, the client summary and invoice drill-down tables,
+// and the export buttons. Bucket headings are built from Current, Range
+// (%d-%d days) and Over (%d+ days).
+type AgingLabels struct {
+	PageTitle      string `json:"pageTitle"`
+	Caption        string `json:"caption"`     // %s as-of date
+	ClientTitle    string `json:"clientTitle"` // %s client name
+	AsOf           string `json:"asOf"`
+	Buckets        string `json:"buckets"`
+	BucketsInfo    string `json:"bucketsInfo"`
+	Apply          string `json:"apply"`
+	ExportCsv      string `json:"exportCsv"`
+	ExportXlsx     string `json:"exportXlsx"`
+	Back           string `json:"back"`
+	Client         string `json:"client"`
+	Currency       string `json:"currency"`
+	Invoices       string `json:"invoices"`
+	Reference      string `json:"reference"`
+	InvoiceDate    string `json:"invoiceDate"`
+	DueDate        string `json:"dueDate"`
+	DaysOverdue    string `json:"daysOverdue"`
+	Amount         string `json:"amount"`
+	Paid           string `json:"paid"`
+	Adjusted       string `json:"adjusted"`
+	Balance        string `json:"balance"`
+	Bucket         string `json:"bucket"`
+	Total          string `json:"total"`
+	Current        string `json:"current"`
+	Range          string `json:"range"` // %d from, %d to
+	Over           string `json:"over"`  // %d from
+	Summary        string `json:"summary"`
+	Detail         string `json:"detail"`
+	EmptyTitle     string `json:"emptyTitle"`
+	EmptyMessage   string `json:"emptyMessage"`
+	InvalidAsOf    string `json:"invalidAsOf"`
+	InvalidBuckets string `json:"invalidBuckets"`
+	InvalidFormat  string `json:"invalidFormat"`
+}
+
+// DunningLabels holds translatable strings for payment reminders: the preview
+// page and its table, the run-now and policy drawers, the client opt-out
+// actions and the reminders tab on the sale detail page.
+type DunningLabels struct {
+	PageTitle      string `json:"pageTitle"`
+	Caption        string `json:"caption"` // %s as-of date
+	PolicyOn       string `json:"policyOn"`
+	PolicyOff      string `json:"policyOff"`
+	EditPolicy     string `json:"editPolicy"`
+	RunNow         string `json:"runNow"`
+	RunMessage     string `json:"runMessage"` // %d reminders to send
+	RunNothing     string `json:"runNothing"`
+	RunDisabled    string `json:"runDisabled"`
+	RunFailed      string `json:"runFailed"`
+	Client         string `json:"client"`
+	Reference      string `json:"reference"`
+	DueDate        string `json:"dueDate"`
+	DaysOverdue    string `json:"daysOverdue"`
+	Balance        string `json:"balance"`
+	Step           string `json:"step"`
+	Recipient      string `json:"recipient"`
+	Subject        string `json:"subject"`
+	Status         string `json:"status"`
+	SentAt         string `json:"sentAt"`
+	Error          string `json:"error"`
+	StatusDue      string `json:"statusDue"`
+	StatusSent     string `json:"statusSent"`
+	StatusFailed   string `json:"statusFailed"`
+	StatusOptedOut string `json:"statusOptedOut"`
+	StatusNoEmail  string `json:"statusNoEmail"`
+	BeforeDue      string `json:"beforeDue"` // %d days
+	OnDueDate      string `json:"onDueDate"`
+	AfterDue       string `json:"afterDue"` // %d days
+	LevelReminder  string `json:"levelReminder"`
+	LevelDue       string `json:"levelDue"`
+	LevelOverdue   string `json:"levelOverdue"`
+	LevelFinal     string `json:"levelFinal"`
+	OptOut         string `json:"optOut"`
+	OptIn          string `json:"optIn"`
+	OptOutConfirm  string `json:"optOutConfirm"` // %s client name
+	OptInConfirm   string `json:"optInConfirm"`  // %s client name
+	EmptyTitle     string `json:"emptyTitle"`
+	EmptyMessage   string `json:"emptyMessage"`
+	HistoryEmpty   string `json:"historyEmpty"`
+
+	// Policy drawer
+	PolicyTitle       string `json:"policyTitle"`
+	Enabled           string `json:"enabled"`
+	AttachInvoice     string `json:"attachInvoice"`
+	StepTitle         string `json:"stepTitle"` // %d step number
+	NewStep           string `json:"newStep"`
+	OffsetDays        string `json:"offsetDays"`
+	OffsetDaysInfo    string `json:"offsetDaysInfo"`
+	Level             string `json:"level"`
+	Body              string `json:"body"`
+	PlaceholdersInfo  string `json:"placeholdersInfo"`
+	InvalidOffset     string `json:"invalidOffset"`
+	ErrorNoSteps      string `json:"errorNoSteps"`
+	ErrorStepOrder    string `json:"errorStepOrder"`
+	ErrorStepLevel    string `json:"errorStepLevel"`
+	ErrorStepTemplate string `json:"errorStepTemplate"`
+}
+
+// EmailLabels holds translatable strings for invoice emails: the compose
+// drawer and its preview, the emails tab on the sale detail page and the
+// workspace templates drawer.
+type EmailLabels struct {
+	Compose          string `json:"compose"`
+	ComposeTitle     string `json:"composeTitle"`
+	Purpose          string `json:"purpose"`
+	PurposeInvoice   string `json:"purposeInvoice"`
+	PurposeReminder  string `json:"purposeReminder"`
+	PurposeStatement string `json:"purposeStatement"`
+	PurposeReceipt   string `json:"purposeReceipt"`
+	To               string `json:"to"`
+	CC               string `json:"cc"`
+	BCC              string `json:"bcc"`
+	AddressesInfo    string `json:"addressesInfo"`
+	Subject          string `json:"subject"`
+	Body             string `json:"body"`
+	PlaceholdersInfo string `json:"placeholdersInfo"`
+	Attachment       string `json:"attachment"`
+	AttachmentNone   string `json:"attachmentNone"`
+	AttachmentPdf    string `json:"attachmentPdf"`
+	AttachmentDocx   string `json:"attachmentDocx"`
+	AttachmentUbl    string `json:"attachmentUbl"`
+	SendAt           string `json:"sendAt"`
+	SendAtInfo       string `json:"sendAtInfo"`
+	Preview          string `json:"preview"`
+	PreviewTitle     string `json:"previewTitle"`
+	Sent             string `json:"sent"`
+	Scheduled        string `json:"scheduled"` // %s send time
+
+	// Emails tab
+	Recipients      string `json:"recipients"`
+	Status          string `json:"status"`
+	SentAt          string `json:"sentAt"`
+	SentBy          string `json:"sentBy"`
+	AttachmentHash  string `json:"attachmentHash"`
+	MessageID       string `json:"messageId"`
+	StatusScheduled string `json:"statusScheduled"`
+	StatusSending   string `json:"statusSending"`
+	StatusSent      string `json:"statusSent"`
+	StatusFailed    string `json:"statusFailed"`
+	StatusCancelled string `json:"statusCancelled"`
+	ScheduledFor    string `json:"scheduledFor"` // %s send time
+	Cancel          string `json:"cancel"`
+	CancelConfirm   string `json:"cancelConfirm"`
+	CancelMessage   string `json:"cancelMessage"` // %s subject
+	EmptyTitle      string `json:"emptyTitle"`
+	EmptyMessage    string `json:"emptyMessage"`
+
+	// Templates drawer
+	Templates      string `json:"templates"`
+	TemplatesTitle string `json:"templatesTitle"`
+
+	ErrorNoRecipients string `json:"errorNoRecipients"`
+	ErrorAddress      string `json:"errorAddress"` // %s address
+	ErrorTemplate     string `json:"errorTemplate"`
+	ErrorSendAt       string `json:"errorSendAt"`
+	ErrorNotScheduled string `json:"errorNotScheduled"`
+	ErrorFormat       string `json:"errorFormat"`
+	SendFailed        string `json:"sendFailed"` // %s error
+}
+
+// StatementLabels holds translatable strings for customer statements: the
+// client list, the statement page, the email drawers and the line
+// descriptions of the statement document.
+type StatementLabels struct {
+	PageTitle      string `json:"pageTitle"`
+	Caption        string `json:"caption"`     // %s from, %s to
+	ClientTitle    string `json:"clientTitle"` // %s client name
+	From           string `json:"from"`
+	To             string `json:"to"`
+	Apply          string `json:"apply"`
+	Back           string `json:"back"`
+	Client         string `json:"client"`
+	Currency       string `json:"currency"`
+	Opening        string `json:"opening"`
+	Charges        string `json:"charges"`
+	Credits        string `json:"credits"`
+	Closing        string `json:"closing"`
+	Date           string `json:"date"`
+	Details        string `json:"details"`
+	Balance        string `json:"balance"`
+	AgingSummary   string `json:"agingSummary"`
+	View           string `json:"view"`
+	DownloadPdf    string `json:"downloadPdf"`
+	DownloadDocx   string `json:"downloadDocx"`
+	BroughtForward string `json:"broughtForward"`
+	KindInvoice    string `json:"kindInvoice"`    // %s reference
+	KindDebitNote  string `json:"kindDebitNote"`  // %s reference
+	KindPayment    string `json:"kindPayment"`    // %s reference
+	KindCreditNote string `json:"kindCreditNote"` // %s reference
+	KindReceipt    string `json:"kindReceipt"`    // %s reference
+	AppliesTo      string `json:"appliesTo"`      // %s description, %s invoice reference
+	EmptyTitle     string `json:"emptyTitle"`
+	EmptyMessage   string `json:"emptyMessage"`
+	InvalidPeriod  string `json:"invalidPeriod"`
+
+	// Email drawers and the message sent
+	Email           string `json:"email"`
+	EmailAll        string `json:"emailAll"`
+	EmailTitle      string `json:"emailTitle"`
+	EmailMessage    string `json:"emailMessage"`    // %s client name, %s address
+	EmailAllMessage string `json:"emailAllMessage"` // %d statements
+	EmailNoAddress  string `json:"emailNoAddress"`  // %s client name
+	EmailNothing    string `json:"emailNothing"`
+	EmailResult     string `json:"emailResult"` // %d sent, %d without address, %d failed
+	EmailFailed     string `json:"emailFailed"`
+	EmailSubject    string `json:"emailSubject"` // %s period
+	EmailBody       string `json:"emailBody"`    // %s client name, %s period, %s balance
+}
+
+// FXRateLabels holds translatable strings for the FX rate tables: the rate
+// list, its entry and CSV import drawers, and the translated dashboard and
+// queue figures.
+type FXRateLabels struct {
+	PageTitle       string `json:"pageTitle"`
+	Caption         string `json:"caption"` // %s functional currency
+	AddRate         string `json:"addRate"`
+	ImportRates     string `json:"importRates"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Date            string `json:"date"`
+	Rate            string `json:"rate"`
+	RateInfo        string `json:"rateInfo"`
+	Source          string `json:"source"`
+	SourceOperator  string `json:"sourceOperator"`
+	SourceImport    string `json:"sourceImport"`
+	File            string `json:"file"`
+	FileInfo        string `json:"fileInfo"`
+	EmptyTitle      string `json:"emptyTitle"`
+	EmptyMessage    string `json:"emptyMessage"`
+	DeleteConfirm   string `json:"deleteConfirm"` // %s rate ID
+	InvalidCurrency string `json:"invalidCurrency"`
+	InvalidRate     string `json:"invalidRate"`
+	InvalidDate     string `json:"invalidDate"`
+	InvalidFile     string `json:"invalidFile"`
+
+	// Translated figures on the dashboard and the revenue-run queue
+	FXGainLoss string `json:"fxGainLoss"`
+	Translated string `json:"translated"` // %s functional currency
 }
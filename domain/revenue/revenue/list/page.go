@@ -2,11 +2,14 @@ package list
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"math"
+	"net/http"
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	espynahttp "github.com/erniealice/espyna-golang/contrib/http"
 	"github.com/erniealice/espyna-golang/tableparams"
 	pyeza "github.com/erniealice/pyeza-golang"
@@ -27,6 +30,14 @@ type ListViewDeps struct {
 	Labels          revenuedomain.Labels
 	CommonLabels    pyeza.CommonLabels
 	TableLabels     types.TableLabels
+
+	// NotesEnabled shows the credit/debit note actions on completed invoices
+	// (set when the module registers the note route).
+	NotesEnabled bool
+
+	// ExportEnabled shows the bulk invoice download actions (set when the
+	// module registers the export routes).
+	ExportEnabled bool
 }
 
 // PageData holds the data for the sales list page.
@@ -121,35 +132,14 @@ func NewTableView(deps *ListViewDeps) view.View {
 func buildTableConfig(ctx context.Context, deps *ListViewDeps, columns []types.TableColumn, status string, p tableparams.TableQueryParams) (*types.TableConfig, error) {
 	perms := view.GetUserPermissions(ctx)
 
-	listParams := espynahttp.ToListParams(p, revenueSearchFields)
-
-	// Inject status filter for server-side pagination
-	if listParams.Filters == nil {
-		listParams.Filters = &commonpb.FilterRequest{}
-	}
-	listParams.Filters.Filters = append(listParams.Filters.Filters, &commonpb.TypedFilter{
-		Field: "rv.status",
-		FilterType: &commonpb.TypedFilter_StringFilter{
-			StringFilter: &commonpb.StringFilter{
-				Value:    status,
-				Operator: commonpb.StringOperator_STRING_EQUALS,
-			},
-		},
-	})
-
-	resp, err := deps.GetListPageData(ctx, &revenuepb.GetRevenueListPageDataRequest{
-		Search:     listParams.Search,
-		Filters:    listParams.Filters,
-		Sort:       listParams.Sort,
-		Pagination: listParams.Pagination,
-	})
+	resp, err := deps.GetListPageData(ctx, listRequest(status, p))
 	if err != nil {
 		log.Printf("Failed to list sales: %v", err)
 		return nil, fmt.Errorf("failed to load sales: %w", err)
 	}
 
 	l := deps.Labels
-	rows := buildTableRows(resp.GetRevenueList(), status, l, deps.Routes, perms)
+	rows := buildTableRows(resp.GetRevenueList(), status, l, deps.Routes, perms, deps.NotesEnabled)
 	types.ApplyColumnStyles(columns, rows)
 
 	// Check if any revenue in list has a treasury collection (blocks bulk revert)
@@ -163,6 +153,9 @@ func buildTableConfig(ctx context.Context, deps *ListViewDeps, columns []types.T
 
 	bulkCfg := pyeza.MapBulkConfig(deps.CommonLabels)
 	bulkCfg.Actions = buildBulkActions(deps.CommonLabels, l, status, deps.Routes, hasAnyCollection)
+	if deps.ExportEnabled {
+		bulkCfg.Actions = append(bulkCfg.Actions, exportBulkActions(l, status, deps.Routes, p, perms)...)
+	}
 
 	refreshURL := route.ResolveURL(deps.Routes.TableURL, "status", status)
 
@@ -218,6 +211,74 @@ func buildTableConfig(ctx context.Context, deps *ListViewDeps, columns []types.T
 	return tableConfig, nil
 }
 
+// listRequest builds the list query for status from the parsed table params.
+func listRequest(status string, p tableparams.TableQueryParams) *revenuepb.GetRevenueListPageDataRequest {
+	listParams := espynahttp.ToListParams(p, revenueSearchFields)
+
+	// Inject status filter for server-side pagination
+	if listParams.Filters == nil {
+		listParams.Filters = &commonpb.FilterRequest{}
+	}
+	listParams.Filters.Filters = append(listParams.Filters.Filters, &commonpb.TypedFilter{
+		Field: "rv.status",
+		FilterType: &commonpb.TypedFilter_StringFilter{
+			StringFilter: &commonpb.StringFilter{
+				Value:    status,
+				Operator: commonpb.StringOperator_STRING_EQUALS,
+			},
+		},
+	})
+
+	return &revenuepb.GetRevenueListPageDataRequest{
+		Search:     listParams.Search,
+		Filters:    listParams.Filters,
+		Sort:       listParams.Sort,
+		Pagination: listParams.Pagination,
+	}
+}
+
+// exportPageSize is how many revenues MatchingRevenueIDs reads per page.
+const exportPageSize = 100
+
+// MatchingRevenueIDs returns the IDs of every revenue matching the list
+// filter posted in r (status, search, filters, sort, dir — the fields the
+// table sends), in list order, along with the total match count. When more
+// than limit match it returns no IDs, so callers can refuse oversized exports
+// before doing any work.
+func MatchingRevenueIDs(ctx context.Context, deps *ListViewDeps, r *http.Request, limit int) ([]string, int, error) {
+	status := r.FormValue("status")
+	if status == "" {
+		status = "draft"
+	}
+	columns := revenueColumns(deps.Labels)
+	p, err := espynahttp.ParseTableParamsWithFilters(r, types.SortableKeys(columns), types.FilterableKeys(columns), "revenue_date_string", "desc")
+	if err != nil {
+		return nil, 0, err
+	}
+	p.PageSize = exportPageSize
+
+	var ids []string
+	for p.Page = 1; ; p.Page++ {
+		resp, err := deps.GetListPageData(ctx, listRequest(status, p))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to load sales: %w", err)
+		}
+		if total := int(resp.GetPagination().GetTotalItems()); total > limit {
+			return nil, total, nil
+		}
+		for _, rv := range resp.GetRevenueList() {
+			ids = append(ids, rv.GetId())
+		}
+		if len(resp.GetRevenueList()) < p.PageSize {
+			break
+		}
+		if len(ids) > limit {
+			return nil, len(ids), nil
+		}
+	}
+	return ids, len(ids), nil
+}
+
 func revenueColumns(l revenuedomain.Labels) []types.TableColumn {
 	return []types.TableColumn{
 		{Key: "reference_number", Label: l.Columns.Reference},
@@ -229,7 +290,7 @@ func revenueColumns(l revenuedomain.Labels) []types.TableColumn {
 	}
 }
 
-func buildTableRows(revenues []*revenuepb.Revenue, status string, l revenuedomain.Labels, routes revenuedomain.Routes, perms *types.UserPermissions) []types.TableRow {
+func buildTableRows(revenues []*revenuepb.Revenue, status string, l revenuedomain.Labels, routes revenuedomain.Routes, perms *types.UserPermissions, notesEnabled bool) []types.TableRow {
 	rows := []types.TableRow{}
 	for _, r := range revenues {
 		recordStatus := r.GetStatus()
@@ -285,6 +346,14 @@ func buildTableRows(revenues []*revenuepb.Revenue, status string, l revenuedomai
 				types.TableAction{Type: "download", Label: l.Actions.DownloadInvoice, Action: "download", URL: route.ResolveURL(routes.InvoiceDownloadURL, "id", id), ItemName: refNumber, ConfirmTitle: l.Actions.DownloadInvoice, ConfirmMessage: fmt.Sprintf("Download invoice for %s?", refNumber), Disabled: !perms.Can("invoice", "read"), DisabledTooltip: l.Errors.PermissionDenied},
 				types.TableAction{Type: "mail", Label: l.Actions.SendEmail, Action: "send-email", URL: route.ResolveURL(routes.SendEmailURL, "id", id), ItemName: refNumber, ConfirmTitle: l.Confirm.SendEmail, ConfirmMessage: fmt.Sprintf(l.Confirm.SendEmailMessage, refNumber), Disabled: !perms.Can("invoice", "read"), DisabledTooltip: l.Errors.PermissionDenied},
 			)
+			// Credit/debit notes correct a completed invoice; notes themselves
+			// are not corrected by further notes.
+			if notesEnabled && shared.RevenueNoteKindOf(refNumber) == "" {
+				actions = append(actions,
+					types.TableAction{Type: "deactivate", Label: l.Actions.CreditNote, Action: "edit", URL: route.ResolveURL(routes.NoteAddURL, "id", id, "kind", shared.RevenueNoteCredit), DrawerTitle: l.Notes.CreditNoteTitle, Disabled: !perms.Can("invoice", "update"), DisabledTooltip: l.Errors.PermissionDenied},
+					types.TableAction{Type: "activate", Label: l.Actions.DebitNote, Action: "edit", URL: route.ResolveURL(routes.NoteAddURL, "id", id, "kind", shared.RevenueNoteDebit), DrawerTitle: l.Notes.DebitNoteTitle, Disabled: !perms.Can("invoice", "update"), DisabledTooltip: l.Errors.PermissionDenied},
+				)
+			}
 		case "cancelled":
 			// view only — no other actions
 		}
@@ -402,3 +471,37 @@ func buildBulkActions(common pyeza.CommonLabels, l revenuedomain.Labels, status
 
 	return actions
 }
+
+// exportBulkActions returns the invoice download actions: the selected rows as
+// a ZIP of PDFs, a ZIP of DOCX files or one merged PDF, or every row matching
+// the current search and filters as a ZIP of PDFs.
+func exportBulkActions(l revenuedomain.Labels, status string, routes revenuedomain.Routes, p tableparams.TableQueryParams, perms *types.UserPermissions) []types.BulkAction {
+	disabled := !perms.Can("invoice", "read")
+	action := func(key, label, icon, params string) types.BulkAction {
+		return types.BulkAction{
+			Key: key, Label: label, Icon: icon, Variant: "default",
+			Endpoint: routes.BulkExportURL, ExtraParamsJSON: params,
+			Disabled: disabled, DisabledTooltip: l.Errors.PermissionDenied,
+		}
+	}
+
+	filter, _ := json.Marshal(map[string]string{
+		"format":  "zip",
+		"scope":   "filter",
+		"status":  status,
+		"search":  p.Search,
+		"filters": p.FiltersRaw,
+		"sort":    p.SortColumn,
+		"dir":     p.SortDir,
+	})
+	all := action("export-all", l.Export.DownloadAll, "icon-archive", string(filter))
+	all.ConfirmTitle = l.Export.DownloadAll
+	all.ConfirmMessage = l.Export.DownloadAllInfo
+
+	return []types.BulkAction{
+		action("export-zip", l.Export.DownloadZip, "icon-download", `{"format":"zip"}`),
+		action("export-docx", l.Export.DownloadDocx, "icon-file-text", `{"format":"zip-docx"}`),
+		action("export-merged", l.Export.DownloadMerged, "icon-file", `{"format":"merged"}`),
+		all,
+	}
+}
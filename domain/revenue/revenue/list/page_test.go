@@ -36,6 +36,8 @@ func testRevenueLabels() revenuedomain.Labels {
 			SendEmail:         "Send email",
 			ReclassifyToDraft: "Reclassify to draft",
 			Delete:            "Delete",
+			CreditNote:        "Credit note",
+			DebitNote:         "Debit note",
 		},
 	}
 }
@@ -119,7 +121,7 @@ func TestBuildTableRows_RevenuePermissionMatrix(t *testing.T) {
 			t.Parallel()
 
 			perms := types.NewUserPermissions(tc.perms)
-			rows := buildTableRows(revenues, "draft", l, routes, perms)
+			rows := buildTableRows(revenues, "draft", l, routes, perms, false)
 			if len(rows) != 1 {
 				t.Fatalf("rows = %d, want 1", len(rows))
 			}
@@ -179,7 +181,7 @@ func TestBuildTableRows_CompleteRow_StatusGatedUndo(t *testing.T) {
 
 	// Admin perms but the revenue has a collection → undo must stay disabled.
 	perms := types.NewUserPermissions([]string{"invoice:list", "invoice:read", "invoice:update", "invoice:delete"})
-	rows := buildTableRows(revenues, "complete", l, routes, perms)
+	rows := buildTableRows(revenues, "complete", l, routes, perms, false)
 
 	if len(rows) != 1 {
 		t.Fatalf("rows = %d, want 1", len(rows))
@@ -209,7 +211,7 @@ func TestBuildTableRows_CancelledRow_NoMutatingActions(t *testing.T) {
 	routes := testRevenueRoutes()
 
 	perms := types.NewUserPermissions([]string{"invoice:list", "invoice:read", "invoice:update", "invoice:delete"})
-	rows := buildTableRows(revenues, "cancelled", l, routes, perms)
+	rows := buildTableRows(revenues, "cancelled", l, routes, perms, false)
 
 	if len(rows) != 1 {
 		t.Fatalf("rows = %d, want 1", len(rows))
@@ -222,3 +224,48 @@ func TestBuildTableRows_CancelledRow_NoMutatingActions(t *testing.T) {
 		t.Error("view action missing on cancelled row")
 	}
 }
+
+// TestBuildTableRows_CompleteRow_NoteActions verifies that completed invoices
+// offer credit/debit note drawers once notes are wired, and that notes do not
+// offer notes against themselves.
+func TestBuildTableRows_CompleteRow_NoteActions(t *testing.T) {
+	t.Parallel()
+
+	revenues := []*revenuepb.Revenue{
+		{Id: "rev-1", ReferenceNumber: strPtr("INV-001"), Status: "complete", Active: true},
+		{Id: "rev-cn", ReferenceNumber: strPtr("CN-000001"), Status: "complete", Active: true},
+	}
+	l := testRevenueLabels()
+	routes := testRevenueRoutes()
+	perms := types.NewUserPermissions([]string{"invoice:list", "invoice:read", "invoice:update"})
+
+	rows := buildTableRows(revenues, "complete", l, routes, perms, true)
+	credit := findRevActionByLabel(rows[0].Actions, l.Actions.CreditNote)
+	debit := findRevActionByLabel(rows[0].Actions, l.Actions.DebitNote)
+	if credit == nil || debit == nil {
+		t.Fatalf("invoice row is missing note actions: %+v", rows[0].Actions)
+	}
+	if credit.URL != "/action/revenue/detail/rev-1/note/credit_note" || credit.Action != "edit" || credit.Disabled {
+		t.Errorf("credit note action = %+v", credit)
+	}
+	if debit.URL != "/action/revenue/detail/rev-1/note/debit_note" {
+		t.Errorf("debit note URL = %q", debit.URL)
+	}
+	if findRevActionByLabel(rows[1].Actions, l.Actions.CreditNote) != nil {
+		t.Error("credit note row should not offer a note against itself")
+	}
+
+	rows = buildTableRows(revenues[:1], "complete", l, routes, perms, false)
+	if findRevActionByLabel(rows[0].Actions, l.Actions.CreditNote) != nil {
+		t.Error("note actions shown while notes are not wired")
+	}
+}
+
+func findRevActionByLabel(actions []types.TableAction, label string) *types.TableAction {
+	for i := range actions {
+		if actions[i].Label == label {
+			return &actions[i]
+		}
+	}
+	return nil
+}
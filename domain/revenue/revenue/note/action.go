@@ -0,0 +1,537 @@
+// Package note owns the credit/debit note drawer
+// (revenue-note-drawer-form.html) and the action that issues a note against a
+// completed invoice.
+package note
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/note/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	inventoryserialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	serialhistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/view"
+	"google.golang.org/protobuf/proto"
+)
+
+// qtyFieldPrefix prefixes the per-line quantity fields (qty_<line id>).
+const qtyFieldPrefix = "qty_"
+
+// Deps holds dependencies for the note action.
+//
+// Notes and Numbering are required; the module only registers the route when
+// both are wired. CreateRevenueTaxLine may be nil, in which case
+// the note's prorated taxes still count toward its totals but no tax rows are
+// written. The inventory closures are only used when a credit note restocks.
+// DeleteRevenue, DeleteRevenueLineItem and DeleteRevenueTaxLine undo a note
+// that fails half-way; without them its revenue row is cancelled instead.
+// UpdateRevenueIfUnchanged makes the creditable check hold across processes;
+// without it, notes against one invoice are only serialized within this
+// process.
+type Deps struct {
+	Routes revenuedomain.Routes
+	Labels revenuedomain.Labels
+
+	ReadRevenue           func(ctx context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error)
+	CreateRevenue         func(ctx context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error)
+	UpdateRevenue         func(ctx context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error)
+	ListRevenueLineItems  func(ctx context.Context, req *revenuelineitempb.ListRevenueLineItemsRequest) (*revenuelineitempb.ListRevenueLineItemsResponse, error)
+	CreateRevenueLineItem func(ctx context.Context, req *revenuelineitempb.CreateRevenueLineItemRequest) (*revenuelineitempb.CreateRevenueLineItemResponse, error)
+	DeleteRevenue         func(ctx context.Context, req *revenuepb.DeleteRevenueRequest) (*revenuepb.DeleteRevenueResponse, error)
+	DeleteRevenueLineItem func(ctx context.Context, req *revenuelineitempb.DeleteRevenueLineItemRequest) (*revenuelineitempb.DeleteRevenueLineItemResponse, error)
+	ListRevenueTaxLines   func(ctx context.Context, req *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error)
+	// CreateRevenueTaxLine writes one tax row (esqyma has no create RPC for
+	// revenue_tax_line; same shape as checkout's closure).
+	CreateRevenueTaxLine func(ctx context.Context, line *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error)
+	// DeleteRevenueTaxLine removes a tax row written by a note that fails.
+	// DeleteRevenueTaxLine removes a tax row written by a note that fails.
+	DeleteRevenueTaxLine func(ctx context.Context, id string) error
+	// UpdateRevenueIfUnchanged bumps the original's date_modified as the
+	// note's commit point (see touchOriginal).
+	UpdateRevenueIfUnchanged shared.UpdateRevenueIfUnchanged
+
+	// Restock
+	AdjustInventoryQuantity      shared.AdjustInventoryQuantity
+	ReadInventoryItem            func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error)
+	UpdateInventoryItem          func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error)
+	UpdateInventorySerial        func(ctx context.Context, req *inventoryserialpb.UpdateInventorySerialRequest) (*inventoryserialpb.UpdateInventorySerialResponse, error)
+	CreateInventorySerialHistory func(ctx context.Context, req *serialhistorypb.CreateInventorySerialHistoryRequest) (*serialhistorypb.CreateInventorySerialHistoryResponse, error)
+
+	Notes shared.RevenueNoteStore
+	// Numbering issues note numbers from the workspace's credit_note /
+	// debit_note sequences. A kind without a sequence cannot be issued.
+	Numbering *shared.DocumentNumbering
+}
+
+// NewAddAction creates the note action (GET = drawer form, POST = issue).
+// The note kind comes from the {kind} path value.
+func NewAddAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		revenueID := r.PathValue("id")
+		kind := r.PathValue("kind")
+		if shared.RevenueNotePrefix(kind) == "" {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		l := deps.Labels.Notes
+		if r.Method != http.MethodGet {
+			unlock := lockInvoice(revenueID)
+			defer unlock()
+		}
+
+		original, err := readRevenue(ctx, deps, revenueID)
+		if err != nil {
+			log.Printf("Failed to read revenue %s for note: %v", revenueID, err)
+			return view.HTMXError(deps.Labels.Errors.NotFound)
+		}
+		items, err := listLineItems(ctx, deps, revenueID)
+		if err != nil {
+			log.Printf("Failed to list line items of %s for note: %v", revenueID, err)
+			return view.HTMXError(l.Unavailable)
+		}
+		existing, err := deps.Notes.ListRevenueNotes(ctx, revenueID)
+		if err != nil {
+			log.Printf("Failed to list notes of %s: %v", revenueID, err)
+			return view.HTMXError(l.Unavailable)
+		}
+
+		if r.Method == http.MethodGet {
+			if original.GetStatus() != "complete" || shared.RevenueNoteKindOf(original.GetReferenceNumber()) != "" {
+				return view.HTMXError(l.NotAllowed)
+			}
+			return view.OK("revenue-note-drawer-form", buildFormData(deps, original, items, existing, kind))
+		}
+
+		// POST — issue the note
+		if err := r.ParseForm(); err != nil {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		req := shared.RevenueNoteRequest{
+			Kind:       kind,
+			Quantities: map[string]float64{},
+			Reason:     strings.TrimSpace(r.FormValue("reason")),
+			Restock:    r.FormValue("restock") == "true" || r.FormValue("restock") == "on",
+		}
+		for key, values := range r.PostForm {
+			id, ok := strings.CutPrefix(key, qtyFieldPrefix)
+			if !ok || len(values) == 0 || values[0] == "" {
+				continue
+			}
+			qty, err := strconv.ParseFloat(values[0], 64)
+			if err != nil || qty < 0 {
+				return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+			}
+			req.Quantities[id] = qty
+		}
+
+		var taxLines []*revenuetaxlinepb.RevenueTaxLine
+		if deps.ListRevenueTaxLines != nil {
+			resp, err := deps.ListRevenueTaxLines(ctx, &revenuetaxlinepb.ListRevenueTaxLinesRequest{RevenueId: &revenueID})
+			if err != nil {
+				log.Printf("Failed to list tax lines of %s for note: %v", revenueID, err)
+				return view.HTMXError(l.Unavailable)
+			}
+			taxLines = resp.GetData()
+		}
+
+		note, taxRows, err := shared.BuildRevenueNote(original, items, taxLines, existing, req)
+		if err != nil {
+			switch {
+			case errors.Is(err, shared.ErrRevenueNoteNotAllowed):
+				return view.HTMXError(l.NotAllowed)
+			case errors.Is(err, shared.ErrRevenueNoteEmpty):
+				return view.HTMXError(l.EmptyNote)
+			case errors.Is(err, shared.ErrRevenueNoteOverCredit):
+				return view.HTMXError(l.QuantityExceeded)
+			}
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+
+		num, err := deps.Numbering.Allocate(ctx, kind, original.GetLocationId())
+		if errors.Is(err, shared.ErrNoDocumentSequence) {
+			return view.HTMXError(l.NoSequence)
+		}
+		if err != nil {
+			log.Printf("Failed to number %s for %s: %v", kind, revenueID, err)
+			return view.HTMXError(l.NumberingFailed)
+		}
+		ref := num.Number
+		note.ReferenceNumber = ref
+		note.IssuedAt = time.Now()
+
+		// issueNote has undone its writes when it fails, so the number is
+		// not in use and is voided to keep the register gapless.
+		if err := issueNote(ctx, deps, original, note, taxRows); err != nil {
+			log.Printf("Failed to issue %s %s against %s: %v", kind, note.ReferenceNumber, revenueID, err)
+			if voidErr := deps.Numbering.Void(context.WithoutCancel(ctx), ref, "note not issued"); voidErr != nil {
+				log.Printf("Failed to void %s: %v", ref, voidErr)
+			}
+			if errors.Is(err, shared.ErrRevenueNoteConflict) {
+				return view.HTMXError(l.Conflict)
+			}
+			return view.HTMXError(l.IssueFailed)
+		}
+
+		return view.ViewResult{
+			StatusCode: http.StatusOK,
+			Headers: map[string]string{
+				"HX-Trigger":  `{"formSuccess":true}`,
+				"HX-Redirect": route.ResolveURL(deps.Routes.DetailURL, "id", note.RevenueID) + "?tab=items",
+			},
+		}
+	})
+}
+
+// buildFormData lists the original's noteable lines. Credit notes show what is
+// left to credit and hide lines that are fully credited.
+func buildFormData(deps *Deps, original *revenuepb.Revenue, items []*revenuelineitempb.RevenueLineItem, existing []*shared.RevenueNote, kind string) *form.Data {
+	isCredit := kind == shared.RevenueNoteCredit
+	creditable := shared.CreditableQuantities(items, existing)
+
+	var lines []form.Line
+	for _, item := range shared.NoteableLineItems(items) {
+		line := form.Line{
+			Name:        qtyFieldPrefix + item.GetId(),
+			Description: item.GetDescription(),
+			Invoiced:    formatQuantity(item.GetQuantity()),
+			UnitPrice:   fmt.Sprintf("%.2f", float64(item.GetUnitPrice())/100),
+		}
+		if isCredit {
+			left := creditable[item.GetId()]
+			if left <= 0 {
+				continue
+			}
+			line.Creditable = formatQuantity(left)
+		}
+		lines = append(lines, line)
+	}
+
+	return &form.Data{
+		FormAction:        route.ResolveURL(deps.Routes.NoteAddURL, "id", original.GetId(), "kind", kind),
+		Kind:              kind,
+		IsCredit:          isCredit,
+		RevenueID:         original.GetId(),
+		OriginalReference: original.GetReferenceNumber(),
+		Currency:          original.GetCurrency(),
+		Lines:             lines,
+		CommonLabels:      nil, // injected by ViewAdapter
+		Labels:            deps.Labels,
+	}
+}
+
+// issueNote writes the note's revenue row, lines and tax rows, records the
+// link, commits against the original (see touchOriginal) and then restocks
+// when asked. The original's amounts are left as issued: the note moves its
+// open balance through the link (see aging's receivables ledger). When a
+// step fails, the writes before it are undone (see rollback) so no note
+// revenue is left carrying the number.
+func issueNote(ctx context.Context, deps *Deps, original *revenuepb.Revenue, note *shared.RevenueNote, taxRows []*revenuetaxlinepb.RevenueTaxLine) (err error) {
+	now := note.IssuedAt
+	nowStr := now.Format(time.RFC3339)
+	today := now.Format("2006-01-02")
+	nowMillis := now.UnixMilli()
+
+	rev := &revenuepb.Revenue{
+		Active:                      true,
+		Name:                        note.ReferenceNumber,
+		ClientId:                    original.GetClientId(),
+		LocationId:                  original.GetLocationId(),
+		Currency:                    original.GetCurrency(),
+		RevenueDate:                 &today,
+		DateCreated:                 &nowMillis,
+		DateCreatedString:           &nowStr,
+		DateModified:                &nowMillis,
+		DateModifiedString:          &nowStr,
+		TotalAmount:                 note.Total(),
+		Status:                      "complete",
+		ReferenceNumber:             &note.ReferenceNumber,
+		CashAmountExpected:          ptr(note.CashDelta()),
+		WhtAmountExpected:           ptr(note.Withholding),
+		TaxInclusivePricingSnapshot: ptr(note.TaxInclusive),
+	}
+	if note.Reason != "" {
+		rev.Notes = &note.Reason
+	}
+	resp, err := deps.CreateRevenue(ctx, &revenuepb.CreateRevenueRequest{Data: rev})
+	if err != nil {
+		return fmt.Errorf("create note revenue: %w", err)
+	}
+	if len(resp.GetData()) == 0 {
+		return fmt.Errorf("create note revenue: empty response")
+	}
+	note.RevenueID = resp.GetData()[0].GetId()
+
+	w := &written{}
+	defer func() {
+		if err != nil {
+			rollback(context.WithoutCancel(ctx), deps, note, w)
+		}
+	}()
+
+	// Lines — remember which note line each original line became so the tax
+	// rows can point at the note's own lines.
+	lineIDs := map[string]string{}
+	for _, line := range note.Lines {
+		item := &revenuelineitempb.RevenueLineItem{
+			Active:            true,
+			RevenueId:         note.RevenueID,
+			Description:       line.Description,
+			Quantity:          line.Quantity,
+			UnitPrice:         line.UnitPrice,
+			TotalPrice:        line.Total,
+			LineAmount:        line.Total,
+			LineItemType:      "item",
+			InventoryItemId:   line.InventoryItemID,
+			InventorySerialId: line.InventorySerialID,
+			ProductId:         nonEmptyPtr(line.ProductID),
+			LocationId:        nonEmptyPtr(original.GetLocationId()),
+		}
+		created, err := deps.CreateRevenueLineItem(ctx, &revenuelineitempb.CreateRevenueLineItemRequest{Data: item})
+		if err != nil {
+			return fmt.Errorf("create note line %q: %w", line.Description, err)
+		}
+		if data := created.GetData(); len(data) > 0 {
+			lineIDs[line.SourceLineItemID] = data[0].GetId()
+			w.lineItemIDs = append(w.lineItemIDs, data[0].GetId())
+		}
+	}
+
+	if deps.CreateRevenueTaxLine != nil {
+		for _, row := range taxRows {
+			applied := make([]string, 0, len(row.GetAppliedToLineItemIds()))
+			for _, id := range row.GetAppliedToLineItemIds() {
+				if mapped := lineIDs[id]; mapped != "" {
+					applied = append(applied, mapped)
+				}
+			}
+			row.RevenueId = note.RevenueID
+			row.AppliedToLineItemIds = applied
+			row.ComputedAt = &nowStr
+			row.DateCreated = &nowMillis
+			row.DateCreatedString = &nowStr
+			row.DateModified = &nowMillis
+			row.DateModifiedString = &nowStr
+			created, err := deps.CreateRevenueTaxLine(ctx, row)
+			if err != nil {
+				return fmt.Errorf("create note tax %s: %w", row.GetTaxKindSnapshot(), err)
+			}
+			if created.GetId() != "" {
+				w.taxLineIDs = append(w.taxLineIDs, created.GetId())
+			}
+		}
+	}
+
+	// The link goes in before the commit, so a note checked against the
+	// original once this one has committed always counts it.
+	if err := deps.Notes.SaveRevenueNote(ctx, note); err != nil {
+		return fmt.Errorf("save note link: %w", err)
+	}
+	w.linked = true
+	if err := touchOriginal(ctx, deps, original, nowMillis); err != nil {
+		return err
+	}
+
+	// Restocking only logs its failures, so it runs once nothing can fail.
+	if note.Restock {
+		restock(ctx, deps, note)
+	}
+	return nil
+}
+
+// written records what issueNote has stored so far, for rollback.
+type written struct {
+	lineItemIDs []string
+	taxLineIDs  []string
+	linked      bool
+}
+
+// invoiceLocks holds one mutex per original invoice ID, so notes against an
+// invoice are checked and issued one at a time within the process.
+var invoiceLocks sync.Map
+
+// lockInvoice takes revenueID's note lock and returns its unlock.
+func lockInvoice(revenueID string) func() {
+	v, _ := invoiceLocks.LoadOrStore(revenueID, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// touchOriginal is the note's commit point: it bumps the original's
+// date_modified only while the original still has the version the note was
+// checked against. A note issued against the same invoice in between has
+// bumped it, so this one fails with ErrRevenueNoteConflict instead of
+// crediting quantities the other already took. A no-op without
+// UpdateRevenueIfUnchanged.
+func touchOriginal(ctx context.Context, deps *Deps, original *revenuepb.Revenue, now int64) error {
+	if deps.UpdateRevenueIfUnchanged == nil {
+		return nil
+	}
+	version := original.GetDateModified()
+	// The version must move even within the same millisecond, or a stale
+	// note would still match.
+	if now <= version {
+		now = version + 1
+	}
+	next := proto.Clone(original).(*revenuepb.Revenue)
+	next.DateModified = &now
+	next.DateModifiedString = ptr(time.UnixMilli(now).Format(time.RFC3339))
+	ok, err := deps.UpdateRevenueIfUnchanged(ctx, next, version)
+	if err != nil {
+		return fmt.Errorf("commit against %s: %w", original.GetId(), err)
+	}
+	if !ok {
+		return shared.ErrRevenueNoteConflict
+	}
+	return nil
+}
+
+// rollback undoes a note that failed after its revenue row was created: its
+// link, tax rows, lines and revenue row are deleted, or the row is cancelled
+// when deletes are not wired. Tax rows stay with the row when
+// DeleteRevenueTaxLine is not wired. Failures are logged; the caller still
+// voids the number.
+func rollback(ctx context.Context, deps *Deps, note *shared.RevenueNote, w *written) {
+	if w.linked {
+		if err := deps.Notes.DeleteRevenueNote(ctx, note.RevenueID); err != nil {
+			log.Printf("Rollback of %s: failed to delete note link: %v", note.ReferenceNumber, err)
+		}
+	}
+	if deps.DeleteRevenueTaxLine != nil {
+		for _, id := range w.taxLineIDs {
+			if err := deps.DeleteRevenueTaxLine(ctx, id); err != nil {
+				log.Printf("Rollback of %s: failed to delete tax line %s: %v", note.ReferenceNumber, id, err)
+			}
+		}
+	}
+	if deps.DeleteRevenue != nil {
+		if deps.DeleteRevenueLineItem != nil {
+			for _, id := range w.lineItemIDs {
+				if _, err := deps.DeleteRevenueLineItem(ctx, &revenuelineitempb.DeleteRevenueLineItemRequest{
+					Data: &revenuelineitempb.RevenueLineItem{Id: id},
+				}); err != nil {
+					log.Printf("Rollback of %s: failed to delete line %s: %v", note.ReferenceNumber, id, err)
+				}
+			}
+		}
+		_, err := deps.DeleteRevenue(ctx, &revenuepb.DeleteRevenueRequest{Data: &revenuepb.Revenue{Id: note.RevenueID}})
+		if err == nil {
+			return
+		}
+		log.Printf("Rollback of %s: failed to delete revenue %s, cancelling it: %v", note.ReferenceNumber, note.RevenueID, err)
+	}
+	if _, err := deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{Data: &revenuepb.Revenue{
+		Id:     note.RevenueID,
+		Status: "cancelled",
+		Active: false,
+	}}); err != nil {
+		log.Printf("Rollback of %s: failed to cancel revenue %s: %v", note.ReferenceNumber, note.RevenueID, err)
+	}
+}
+
+// restock returns credited quantities to stock and frees credited serials.
+// Failures are logged, not returned: the note is already issued.
+func restock(ctx context.Context, deps *Deps, note *shared.RevenueNote) {
+	adjust := shared.InventoryAdjuster(deps.AdjustInventoryQuantity, deps.ReadInventoryItem, deps.UpdateInventoryItem)
+	for _, line := range note.Lines {
+		if line.InventoryItemID != "" && adjust != nil {
+			if _, err := adjust(ctx, shared.InventoryDelta{
+				InventoryItemID: line.InventoryItemID,
+				OnHand:          -line.Quantity, // credit lines are negative
+				Available:       -line.Quantity,
+			}); err != nil {
+				log.Printf("Failed to restock inventory item %s for %s: %v", line.InventoryItemID, note.ReferenceNumber, err)
+			}
+		}
+
+		if line.InventorySerialID == "" || deps.UpdateInventorySerial == nil {
+			continue
+		}
+		if _, err := deps.UpdateInventorySerial(ctx, &inventoryserialpb.UpdateInventorySerialRequest{
+			Data: &inventoryserialpb.InventorySerial{
+				Id:     line.InventorySerialID,
+				Status: "available",
+			},
+		}); err != nil {
+			log.Printf("Failed to restock serial %s for %s: %v", line.InventorySerialID, note.ReferenceNumber, err)
+			continue
+		}
+		if deps.CreateInventorySerialHistory == nil {
+			continue
+		}
+		if _, err := deps.CreateInventorySerialHistory(ctx, &serialhistorypb.CreateInventorySerialHistoryRequest{
+			Data: &serialhistorypb.InventorySerialHistory{
+				InventorySerialId: line.InventorySerialID,
+				InventoryItemId:   line.InventoryItemID,
+				FromStatus:        "sold",
+				ToStatus:          "available",
+				ReferenceType:     "revenue",
+				ReferenceId:       note.RevenueID,
+				Notes:             "Auto: credit note " + note.ReferenceNumber,
+			},
+		}); err != nil {
+			log.Printf("Failed to create serial history for %s: %v", line.InventorySerialID, err)
+		}
+	}
+}
+
+func readRevenue(ctx context.Context, deps *Deps, id string) (*revenuepb.Revenue, error) {
+	resp, err := deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: id}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.GetData()) == 0 {
+		return nil, fmt.Errorf("revenue %s not found", id)
+	}
+	return resp.GetData()[0], nil
+}
+
+func listLineItems(ctx context.Context, deps *Deps, revenueID string) ([]*revenuelineitempb.RevenueLineItem, error) {
+	resp, err := deps.ListRevenueLineItems(ctx, &revenuelineitempb.ListRevenueLineItemsRequest{RevenueId: &revenueID})
+	if err != nil {
+		return nil, err
+	}
+	var items []*revenuelineitempb.RevenueLineItem
+	for _, item := range resp.GetData() {
+		if item.GetRevenueId() == revenueID {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// formatQuantity drops the decimals of whole quantities (4 → "4", 1.5 → "1.5").
+func formatQuantity(q float64) string {
+	return strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func nonEmptyPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,32 @@
+// Package form owns the template data shape for the credit/debit note drawer
+// (revenue-note-drawer-form.html). Pure types only — no Deps, no
+// context.Context, no repository imports.
+package form
+
+import (
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+)
+
+// Line is one original invoice line the note may copy. Name is the form field
+// carrying the quantity to note (qty_<line id>).
+type Line struct {
+	Name        string
+	Description string
+	Invoiced    string
+	Creditable  string // remaining quantity; empty on debit notes
+	UnitPrice   string
+}
+
+// Data is the template data for the note drawer form.
+type Data struct {
+	FormAction        string
+	WorkspaceID       string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Kind              string
+	IsCredit          bool
+	RevenueID         string
+	OriginalReference string
+	Currency          string
+	Lines             []Line
+	CommonLabels      any
+	Labels            revenuedomain.Labels
+}
@@ -10,6 +10,7 @@ import (
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
 	"github.com/erniealice/centymo-golang/domain/revenue/revenue/payment/form"
+	"github.com/erniealice/centymo-golang/domain/shared"
 	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
 	collectionmethodpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection_method"
 	"github.com/erniealice/pyeza-golang/route"
@@ -36,6 +37,26 @@ type Deps struct {
 	// Typed collection_method reads (replaces DataSource on "collection_method").
 	ReadCollectionMethod  func(ctx context.Context, req *collectionmethodpb.ReadCollectionMethodRequest) (*collectionmethodpb.ReadCollectionMethodResponse, error)
 	ListCollectionMethods func(ctx context.Context, req *collectionmethodpb.ListCollectionMethodsRequest) (*collectionmethodpb.ListCollectionMethodsResponse, error)
+
+	// Allocations finds payments created by applying a treasury collection;
+	// those are only undone by reversing the allocation, which keeps the
+	// collection's unapplied amount right. Optional.
+	Allocations shared.PaymentAllocationStore
+}
+
+// allocated reports whether paymentID was created by a collection
+// allocation. A failed lookup counts as allocated so the payment is not
+// edited blind.
+func allocated(ctx context.Context, deps *Deps, paymentID string) bool {
+	if deps.Allocations == nil {
+		return false
+	}
+	a, err := deps.Allocations.ReadPaymentAllocationByPayment(ctx, paymentID)
+	if err != nil {
+		log.Printf("Failed to look up the allocation of payment %s: %v", paymentID, err)
+		return true
+	}
+	return a != nil
 }
 
 // strPtr returns a pointer to s for optional proto string fields. Returns nil
@@ -190,6 +211,9 @@ func NewEditAction(deps *Deps) view.View {
 
 		revenueID := viewCtx.Request.PathValue("id")
 		paymentID := viewCtx.Request.PathValue("pid")
+		if allocated(ctx, deps, paymentID) {
+			return view.HTMXError(deps.Labels.Errors.PaymentAllocated)
+		}
 
 		if viewCtx.Request.Method == http.MethodGet {
 			if deps.ReadRevenuePayment == nil {
@@ -285,6 +309,9 @@ func NewRemoveAction(deps *Deps) view.View {
 		if id == "" {
 			return view.HTMXError(deps.Labels.Errors.IDRequired)
 		}
+		if allocated(ctx, deps, id) {
+			return view.HTMXError(deps.Labels.Errors.PaymentAllocated)
+		}
 
 		if deps.DeleteRevenuePayment == nil {
 			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
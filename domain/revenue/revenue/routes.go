@@ -33,6 +33,10 @@ const (
 	PaymentEditURL   = "/action/revenue/detail/{id}/payment/edit/{pid}"
 	PaymentRemoveURL = "/action/revenue/detail/{id}/payment/remove"
 
+	// Credit/debit note drawer (within revenue detail). {kind} is
+	// "credit_note" or "debit_note".
+	NoteAddURL = "/action/revenue/detail/{id}/note/{kind}"
+
 	// Revenue report routes
 	SummaryURL = "/sales/reports/sales-summary"
 
@@ -45,12 +49,81 @@ const (
 	SettingsTemplateUploadURL  = "/action/revenue/settings/templates/upload"
 	SettingsTemplateDeleteURL  = "/action/revenue/settings/templates/delete"
 	SettingsTemplateDefaultURL = "/action/revenue/settings/templates/set-default/{id}"
+	SettingsTemplateCheckURL   = "/action/revenue/settings/templates/check/{id}"
+	SettingsTemplatePreviewURL = "/action/revenue/settings/templates/preview/{id}"
 	SearchClientURL            = "/action/revenue/search/clients"
 	SearchSubscriptionURL      = "/action/revenue/search/subscriptions"
 	SearchLocationURL          = "/action/revenue/search/locations"
 	SearchProductURL           = "/action/revenue/search/products"
 	PriceLookupURL             = "/action/revenue/price-lookup"
 	RecomputeTaxesURL          = "/action/revenue/detail/{id}/taxes/recompute"
+
+	// Storefront order fulfillment routes. {location} is a location ID or
+	// "all"; {reference} is the ORD-xxxx-xxxx order reference.
+	FulfillmentQueueURL   = "/sales/fulfillment/{location}"
+	FulfillmentTableURL   = "/action/revenue/fulfillment/table/{location}"
+	FulfillmentAdvanceURL = "/action/revenue/fulfillment/advance"
+	FulfillmentStatusURL  = "/action/revenue/fulfillment/status/{reference}"
+
+	// Bulk invoice export routes. {job} is the export job ID returned by
+	// BulkExportURL.
+	BulkExportURL     = "/action/revenue/bulk-export"
+	ExportURL         = "/sales/exports/{job}"
+	ExportStatusURL   = "/action/revenue/export/{job}/status"
+	ExportDownloadURL = "/action/revenue/export/{job}/download"
+
+	// Document numbering settings routes. {id} is a sequence ID; the void
+	// action takes the number to void as ?number=.
+	SettingsNumberingURL         = "/sales/settings/numbering"
+	SettingsNumberingTableURL    = "/action/revenue/settings/numbering/table"
+	SettingsNumberingAddURL      = "/action/revenue/settings/numbering/add"
+	SettingsNumberingEditURL     = "/action/revenue/settings/numbering/edit/{id}"
+	SettingsNumberingDeleteURL   = "/action/revenue/settings/numbering/delete"
+	SettingsNumberingRegisterURL = "/sales/settings/numbering/{id}"
+	SettingsNumberingNumbersURL  = "/action/revenue/settings/numbering/{id}/table"
+	SettingsNumberingVoidURL     = "/action/revenue/settings/numbering/{id}/void"
+
+	// Accounts receivable aging routes. Each takes ?as_of=YYYY-MM-DD and
+	// ?buckets=30,60,90; {id} is a client ID and export takes ?format=csv|xlsx.
+	AgingURL       = "/sales/aging"
+	AgingTableURL  = "/action/revenue/aging/table"
+	AgingClientURL = "/sales/aging/client/{id}"
+	AgingExportURL = "/action/revenue/aging/export"
+
+	// Dunning routes: the reminder preview page, its table refresh, the
+	// run-now and policy drawers, and the client opt-out toggle (?client= and
+	// ?opt_out=1|0).
+	DunningURL       = "/sales/dunning"
+	DunningTableURL  = "/action/revenue/dunning/table"
+	DunningRunURL    = "/action/revenue/dunning/run"
+	DunningPolicyURL = "/action/revenue/dunning/policy"
+	DunningOptOutURL = "/action/revenue/dunning/opt-out"
+
+	// Invoice email routes: the compose drawer (?purpose= one of the
+	// shared.EmailPurposes, default invoice), the emails tab table refresh,
+	// cancelling a scheduled send (?send=) and the workspace templates drawer.
+	EmailComposeURL   = "/action/revenue/detail/{id}/email"
+	EmailTableURL     = "/action/revenue/detail/{id}/email/table"
+	EmailCancelURL    = "/action/revenue/detail/{id}/email/cancel"
+	EmailTemplatesURL = "/action/revenue/settings/email-templates"
+
+	// Customer statement routes. Each takes ?from=&to=YYYY-MM-DD (default
+	// the month to date); {id} is a client ID and the client routes also take
+	// ?currency=. Download takes ?format=pdf|docx.
+	StatementsURL        = "/sales/statements"
+	StatementsTableURL   = "/action/revenue/statements/table"
+	StatementsEmailURL   = "/action/revenue/statements/email"
+	StatementURL         = "/sales/statements/client/{id}"
+	StatementDownloadURL = "/action/revenue/statements/client/{id}/download"
+	StatementEmailURL    = "/action/revenue/statements/client/{id}/email"
+
+	// FX rate settings routes. Delete takes the rate ID as ?id=, e.g.
+	// USD-PHP-2026-03-01.
+	SettingsFXRatesURL      = "/sales/settings/fx-rates"
+	SettingsFXRatesTableURL = "/action/revenue/settings/fx-rates/table"
+	SettingsFXRateAddURL    = "/action/revenue/settings/fx-rates/add"
+	SettingsFXRateImportURL = "/action/revenue/settings/fx-rates/import"
+	SettingsFXRateDeleteURL = "/action/revenue/settings/fx-rates/delete"
 )
 
 // Routes holds all route paths for revenue views and actions,
@@ -86,6 +159,9 @@ type Routes struct {
 	PaymentEditURL   string `json:"payment_edit_url"`
 	PaymentRemoveURL string `json:"payment_remove_url"`
 
+	// Credit/debit note route
+	NoteAddURL string `json:"note_add_url"`
+
 	// Report routes
 	RevenueSummaryURL string `json:"revenue_summary_url"`
 
@@ -100,6 +176,8 @@ type Routes struct {
 	SettingsTemplateUploadURL  string `json:"settings_template_upload_url"`
 	SettingsTemplateDeleteURL  string `json:"settings_template_delete_url"`
 	SettingsTemplateDefaultURL string `json:"settings_template_default_url"`
+	SettingsTemplateCheckURL   string `json:"settings_template_check_url"`
+	SettingsTemplatePreviewURL string `json:"settings_template_preview_url"`
 
 	// Client search for revenue form autocomplete
 	SearchClientURL string `json:"search_client_url"`
@@ -118,6 +196,70 @@ type Routes struct {
 
 	// Tax recompute (Phase 4 wiring — stub until ComputeTaxesForRevenue is available)
 	RecomputeTaxesURL string `json:"recompute_taxes_url"`
+
+	// Storefront order fulfillment (queue page, table refresh, state change,
+	// JSON status poll)
+	FulfillmentQueueURL   string `json:"fulfillment_queue_url"`
+	FulfillmentTableURL   string `json:"fulfillment_table_url"`
+	FulfillmentAdvanceURL string `json:"fulfillment_advance_url"`
+	FulfillmentStatusURL  string `json:"fulfillment_status_url"`
+
+	// Bulk invoice export (start action, progress page, progress fragment
+	// poll, file download)
+	BulkExportURL     string `json:"bulk_export_url"`
+	ExportURL         string `json:"export_url"`
+	ExportStatusURL   string `json:"export_status_url"`
+	ExportDownloadURL string `json:"export_download_url"`
+
+	// Document numbering settings (sequence page, table refresh, add/edit
+	// drawer, delete, issued-number register and its table refresh, void
+	// drawer)
+	SettingsNumberingURL         string `json:"settings_numbering_url"`
+	SettingsNumberingTableURL    string `json:"settings_numbering_table_url"`
+	SettingsNumberingAddURL      string `json:"settings_numbering_add_url"`
+	SettingsNumberingEditURL     string `json:"settings_numbering_edit_url"`
+	SettingsNumberingDeleteURL   string `json:"settings_numbering_delete_url"`
+	SettingsNumberingRegisterURL string `json:"settings_numbering_register_url"`
+	SettingsNumberingNumbersURL  string `json:"settings_numbering_numbers_url"`
+	SettingsNumberingVoidURL     string `json:"settings_numbering_void_url"`
+
+	// Accounts receivable aging (client summary page, table refresh, client
+	// invoice drill-down, CSV/XLSX export)
+	AgingURL       string `json:"aging_url"`
+	AgingTableURL  string `json:"aging_table_url"`
+	AgingClientURL string `json:"aging_client_url"`
+	AgingExportURL string `json:"aging_export_url"`
+
+	// Dunning (reminder preview, run now, policy, client opt-out)
+	DunningURL       string `json:"dunning_url"`
+	DunningTableURL  string `json:"dunning_table_url"`
+	DunningRunURL    string `json:"dunning_run_url"`
+	DunningPolicyURL string `json:"dunning_policy_url"`
+	DunningOptOutURL string `json:"dunning_opt_out_url"`
+
+	// Invoice emails (compose, emails tab refresh, cancel scheduled,
+	// templates)
+	EmailComposeURL   string `json:"email_compose_url"`
+	EmailTableURL     string `json:"email_table_url"`
+	EmailCancelURL    string `json:"email_cancel_url"`
+	EmailTemplatesURL string `json:"email_templates_url"`
+
+	// Customer statements (client list, table refresh, bulk email, client
+	// statement page, download, email)
+	StatementsURL        string `json:"statements_url"`
+	StatementsTableURL   string `json:"statements_table_url"`
+	StatementsEmailURL   string `json:"statements_email_url"`
+	StatementURL         string `json:"statement_url"`
+	StatementDownloadURL string `json:"statement_download_url"`
+	StatementEmailURL    string `json:"statement_email_url"`
+
+	// FX rate settings (rate page, table refresh, add drawer, CSV import
+	// drawer, delete)
+	SettingsFXRatesURL      string `json:"settings_fx_rates_url"`
+	SettingsFXRatesTableURL string `json:"settings_fx_rates_table_url"`
+	SettingsFXRateAddURL    string `json:"settings_fx_rate_add_url"`
+	SettingsFXRateImportURL string `json:"settings_fx_rate_import_url"`
+	SettingsFXRateDeleteURL string `json:"settings_fx_rate_delete_url"`
 }
 
 // DefaultRoutes returns a Routes populated from the package-level
@@ -151,6 +293,8 @@ func DefaultRoutes() Routes {
 		PaymentEditURL:   PaymentEditURL,
 		PaymentRemoveURL: PaymentRemoveURL,
 
+		NoteAddURL: NoteAddURL,
+
 		RevenueSummaryURL:          SummaryURL,
 		InvoiceDownloadURL:         InvoiceDownloadURL,
 		SendEmailURL:               EmailURL,
@@ -158,12 +302,60 @@ func DefaultRoutes() Routes {
 		SettingsTemplateUploadURL:  SettingsTemplateUploadURL,
 		SettingsTemplateDeleteURL:  SettingsTemplateDeleteURL,
 		SettingsTemplateDefaultURL: SettingsTemplateDefaultURL,
+		SettingsTemplateCheckURL:   SettingsTemplateCheckURL,
+		SettingsTemplatePreviewURL: SettingsTemplatePreviewURL,
 		SearchClientURL:            SearchClientURL,
 		SearchSubscriptionURL:      SearchSubscriptionURL,
 		SearchLocationURL:          SearchLocationURL,
 		SearchProductURL:           SearchProductURL,
 		PriceLookupURL:             PriceLookupURL,
 		RecomputeTaxesURL:          RecomputeTaxesURL,
+		FulfillmentQueueURL:        FulfillmentQueueURL,
+		FulfillmentTableURL:        FulfillmentTableURL,
+		FulfillmentAdvanceURL:      FulfillmentAdvanceURL,
+		FulfillmentStatusURL:       FulfillmentStatusURL,
+		BulkExportURL:              BulkExportURL,
+		ExportURL:                  ExportURL,
+		ExportStatusURL:            ExportStatusURL,
+		ExportDownloadURL:          ExportDownloadURL,
+
+		SettingsNumberingURL:         SettingsNumberingURL,
+		SettingsNumberingTableURL:    SettingsNumberingTableURL,
+		SettingsNumberingAddURL:      SettingsNumberingAddURL,
+		SettingsNumberingEditURL:     SettingsNumberingEditURL,
+		SettingsNumberingDeleteURL:   SettingsNumberingDeleteURL,
+		SettingsNumberingRegisterURL: SettingsNumberingRegisterURL,
+		SettingsNumberingNumbersURL:  SettingsNumberingNumbersURL,
+		SettingsNumberingVoidURL:     SettingsNumberingVoidURL,
+
+		AgingURL:       AgingURL,
+		AgingTableURL:  AgingTableURL,
+		AgingClientURL: AgingClientURL,
+		AgingExportURL: AgingExportURL,
+
+		DunningURL:       DunningURL,
+		DunningTableURL:  DunningTableURL,
+		DunningRunURL:    DunningRunURL,
+		DunningPolicyURL: DunningPolicyURL,
+		DunningOptOutURL: DunningOptOutURL,
+
+		EmailComposeURL:   EmailComposeURL,
+		EmailTableURL:     EmailTableURL,
+		EmailCancelURL:    EmailCancelURL,
+		EmailTemplatesURL: EmailTemplatesURL,
+
+		StatementsURL:        StatementsURL,
+		StatementsTableURL:   StatementsTableURL,
+		StatementsEmailURL:   StatementsEmailURL,
+		StatementURL:         StatementURL,
+		StatementDownloadURL: StatementDownloadURL,
+		StatementEmailURL:    StatementEmailURL,
+
+		SettingsFXRatesURL:      SettingsFXRatesURL,
+		SettingsFXRatesTableURL: SettingsFXRatesTableURL,
+		SettingsFXRateAddURL:    SettingsFXRateAddURL,
+		SettingsFXRateImportURL: SettingsFXRateImportURL,
+		SettingsFXRateDeleteURL: SettingsFXRateDeleteURL,
 	}
 }
 
@@ -198,6 +390,8 @@ func (r Routes) RouteMap() map[string]string {
 		"revenue.payment.edit":   r.PaymentEditURL,
 		"revenue.payment.remove": r.PaymentRemoveURL,
 
+		"revenue.note.add": r.NoteAddURL,
+
 		"revenue.summary":                   r.RevenueSummaryURL,
 		"revenue.invoice_download":          r.InvoiceDownloadURL,
 		"revenue.send_email":                r.SendEmailURL,
@@ -205,11 +399,59 @@ func (r Routes) RouteMap() map[string]string {
 		"revenue.settings.template_upload":  r.SettingsTemplateUploadURL,
 		"revenue.settings.template_delete":  r.SettingsTemplateDeleteURL,
 		"revenue.settings.template_default": r.SettingsTemplateDefaultURL,
+		"revenue.settings.template_check":   r.SettingsTemplateCheckURL,
+		"revenue.settings.template_preview": r.SettingsTemplatePreviewURL,
 		"revenue.search_client":             r.SearchClientURL,
 		"revenue.search.subscriptions":      r.SearchSubscriptionURL,
 		"revenue.search.locations":          r.SearchLocationURL,
 		"revenue.search.products":           r.SearchProductURL,
 		"revenue.price_lookup":              r.PriceLookupURL,
 		"revenue.taxes.recompute":           r.RecomputeTaxesURL,
+		"revenue.fulfillment.queue":         r.FulfillmentQueueURL,
+		"revenue.fulfillment.table":         r.FulfillmentTableURL,
+		"revenue.fulfillment.advance":       r.FulfillmentAdvanceURL,
+		"revenue.fulfillment.status":        r.FulfillmentStatusURL,
+		"revenue.bulk_export":               r.BulkExportURL,
+		"revenue.export":                    r.ExportURL,
+		"revenue.export.status":             r.ExportStatusURL,
+		"revenue.export.download":           r.ExportDownloadURL,
+
+		"revenue.settings.numbering":          r.SettingsNumberingURL,
+		"revenue.settings.numbering.table":    r.SettingsNumberingTableURL,
+		"revenue.settings.numbering.add":      r.SettingsNumberingAddURL,
+		"revenue.settings.numbering.edit":     r.SettingsNumberingEditURL,
+		"revenue.settings.numbering.delete":   r.SettingsNumberingDeleteURL,
+		"revenue.settings.numbering.register": r.SettingsNumberingRegisterURL,
+		"revenue.settings.numbering.numbers":  r.SettingsNumberingNumbersURL,
+		"revenue.settings.numbering.void":     r.SettingsNumberingVoidURL,
+
+		"revenue.aging":        r.AgingURL,
+		"revenue.aging.table":  r.AgingTableURL,
+		"revenue.aging.client": r.AgingClientURL,
+		"revenue.aging.export": r.AgingExportURL,
+
+		"revenue.dunning":         r.DunningURL,
+		"revenue.dunning.table":   r.DunningTableURL,
+		"revenue.dunning.run":     r.DunningRunURL,
+		"revenue.dunning.policy":  r.DunningPolicyURL,
+		"revenue.dunning.opt_out": r.DunningOptOutURL,
+
+		"revenue.email.compose":   r.EmailComposeURL,
+		"revenue.email.table":     r.EmailTableURL,
+		"revenue.email.cancel":    r.EmailCancelURL,
+		"revenue.email.templates": r.EmailTemplatesURL,
+
+		"revenue.statements":         r.StatementsURL,
+		"revenue.statements.table":   r.StatementsTableURL,
+		"revenue.statements.email":   r.StatementsEmailURL,
+		"revenue.statement":          r.StatementURL,
+		"revenue.statement.download": r.StatementDownloadURL,
+		"revenue.statement.email":    r.StatementEmailURL,
+
+		"revenue.settings.fx_rates":        r.SettingsFXRatesURL,
+		"revenue.settings.fx_rates.table":  r.SettingsFXRatesTableURL,
+		"revenue.settings.fx_rates.add":    r.SettingsFXRateAddURL,
+		"revenue.settings.fx_rates.import": r.SettingsFXRateImportURL,
+		"revenue.settings.fx_rates.delete": r.SettingsFXRateDeleteURL,
 	}
 }
@@ -0,0 +1,154 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	settingsform "github.com/erniealice/centymo-golang/domain/revenue/revenue/settings/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	documenttemplatepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/template"
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// errTemplateNotFound is returned by loadTemplate for an ID that is not an
+// active invoice template.
+var errTemplateNotFound = errors.New("template not found")
+
+// checkEnabled reports whether templates can be checked with deps.
+func checkEnabled(deps *SettingsViewDeps) bool {
+	return deps.DownloadTemplate != nil && deps.SampleData != nil && deps.ListDocumentTemplates != nil
+}
+
+// NewCheckAction creates the template check drawer (GET only): the
+// placeholders the template uses that the invoice data does not provide and
+// the reverse, with a form to preview the template when RenderPreview is set.
+// Route: GET /action/revenue/settings/templates/check/{id}
+func NewCheckAction(deps *SettingsViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels.Settings
+		id := viewCtx.Request.PathValue("id")
+
+		tpl, report, err := checkTemplate(ctx, deps, id)
+		if errors.Is(err, errTemplateNotFound) {
+			return view.HTMXError(l.TemplateNotFound)
+		}
+		data := &settingsform.TemplateCheckData{
+			TemplateName: tpl.GetName(),
+			Report:       report,
+			Formats: []pyeza.SelectOption{
+				{Value: "pdf", Label: "PDF", Selected: true},
+				{Value: "docx", Label: "DOCX"},
+			},
+			CommonLabels: nil, // injected by ViewAdapter
+			Labels:       l,
+		}
+		if deps.RenderPreview != nil {
+			data.PreviewURL = route.ResolveURL(deps.Routes.SettingsTemplatePreviewURL, "id", id)
+		}
+		if err != nil {
+			log.Printf("Failed to check invoice template %s: %v", id, err)
+			data.Error = templateErrorMessage(l, err)
+		}
+		return view.OK("revenue-settings-template-check", data)
+	})
+}
+
+// NewPreviewHandler creates an http.HandlerFunc that generates an uploaded
+// template for viewing in the browser.
+//
+// Query parameters:
+//   - revenue: the revenue whose data fills the template (blank = sample data)
+//   - format: "pdf" (default) or "docx"
+//
+// Route: GET /action/revenue/settings/templates/preview/{id}
+func NewPreviewHandler(deps *SettingsViewDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := r.PathValue("id")
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "pdf"
+		}
+		if format != "pdf" && format != "docx" {
+			http.Error(w, "invalid format: must be \"pdf\" or \"docx\"", http.StatusBadRequest)
+			return
+		}
+
+		_, templateBytes, err := loadTemplate(ctx, deps, id)
+		if errors.Is(err, errTemplateNotFound) {
+			http.Error(w, deps.Labels.Settings.TemplateNotFound, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("template preview: %v", err)
+			http.Error(w, deps.Labels.Settings.PreviewFailed, http.StatusInternalServerError)
+			return
+		}
+
+		revenueID := strings.TrimSpace(r.URL.Query().Get("revenue"))
+		name, out, err := deps.RenderPreview(ctx, templateBytes, revenueID, format)
+		if err != nil {
+			log.Printf("template preview: template %s, revenue %q: %v", id, revenueID, err)
+			http.Error(w, deps.Labels.Settings.PreviewFailed, http.StatusUnprocessableEntity)
+			return
+		}
+
+		contentType := "application/pdf"
+		if strings.HasSuffix(name, ".docx") {
+			contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, name))
+		w.Write(out)
+	}
+}
+
+// checkTemplate loads template id and checks its placeholders against the
+// sample invoice data.
+func checkTemplate(ctx context.Context, deps *SettingsViewDeps, id string) (*documenttemplatepb.DocumentTemplate, *shared.DocTemplateReport, error) {
+	tpl, templateBytes, err := loadTemplate(ctx, deps, id)
+	if err != nil {
+		return tpl, nil, err
+	}
+	report, err := shared.CheckDocTemplate(templateBytes, deps.SampleData())
+	return tpl, report, err
+}
+
+// loadTemplate returns active invoice template id and its file.
+func loadTemplate(ctx context.Context, deps *SettingsViewDeps, id string) (*documenttemplatepb.DocumentTemplate, []byte, error) {
+	cfg := templateConfig(deps)
+	resp, err := deps.ListDocumentTemplates(ctx, &documenttemplatepb.ListDocumentTemplatesRequest{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	for _, t := range resp.GetData() {
+		if t.GetId() != id || !t.GetActive() || t.GetDocumentPurpose() != cfg.DocumentPurpose {
+			continue
+		}
+		bucket := t.GetStorageContainer()
+		if bucket == "" {
+			bucket = cfg.BucketName
+		}
+		templateBytes, err := deps.DownloadTemplate(ctx, bucket, t.GetStorageKey())
+		if err != nil {
+			return t, nil, fmt.Errorf("failed to download template %s: %w", id, err)
+		}
+		return t, templateBytes, nil
+	}
+	return nil, nil, errTemplateNotFound
+}
+
+// templateErrorMessage maps a template load or parse error to its label.
+func templateErrorMessage(l revenuedomain.SettingsLabels, err error) string {
+	if errors.Is(err, shared.ErrDocTemplate) {
+		return fmt.Sprintf(l.InvalidTemplate, strings.TrimPrefix(err.Error(), shared.ErrDocTemplate.Error()+": "))
+	}
+	return l.PreviewFailed
+}
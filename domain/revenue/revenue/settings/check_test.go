@@ -0,0 +1,113 @@
+package settings
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	settingsform "github.com/erniealice/centymo-golang/domain/revenue/revenue/settings/form"
+	documenttemplatepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/template"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+func docxWith(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte("<w:document><w:body><w:p><w:r><w:t>" + text + "</w:t></w:r></w:p></w:body></w:document>"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkTestDeps serves invoice templates "good" and "bad" (which uses
+// {{invoice.po_number}}); updates are recorded in *defaults.
+func checkTestDeps(t *testing.T, defaults *[]string) *SettingsViewDeps {
+	files := map[string][]byte{
+		"templates/invoice/good.docx": docxWith(t, "{{invoice.reference_number}} {{customer.name}}"),
+		"templates/invoice/bad.docx":  docxWith(t, "{{invoice.reference_number}} {{invoice.po_number}}"),
+	}
+	deps := &SettingsViewDeps{
+		Routes: revenuedomain.DefaultRoutes(),
+		ListDocumentTemplates: func(context.Context, *documenttemplatepb.ListDocumentTemplatesRequest) (*documenttemplatepb.ListDocumentTemplatesResponse, error) {
+			var data []*documenttemplatepb.DocumentTemplate
+			for _, id := range []string{"good", "bad"} {
+				key := "templates/invoice/" + id + ".docx"
+				data = append(data, &documenttemplatepb.DocumentTemplate{Id: id, Name: id, DocumentPurpose: "invoice", Active: true, StorageKey: &key})
+			}
+			return &documenttemplatepb.ListDocumentTemplatesResponse{Data: data}, nil
+		},
+		UpdateDocumentTemplate: func(_ context.Context, req *documenttemplatepb.UpdateDocumentTemplateRequest) (*documenttemplatepb.UpdateDocumentTemplateResponse, error) {
+			if req.GetData().GetIsDefault() {
+				*defaults = append(*defaults, req.GetData().GetId())
+			}
+			return &documenttemplatepb.UpdateDocumentTemplateResponse{}, nil
+		},
+		DownloadTemplate: func(_ context.Context, bucket, key string) ([]byte, error) {
+			if bucket != "templates" {
+				t.Errorf("bucket = %q", bucket)
+			}
+			return files[key], nil
+		},
+		SampleData: func() map[string]any {
+			return map[string]any{
+				"invoice":  map[string]any{"reference_number": "INV-1", "date": "2026-01-15"},
+				"customer": map[string]any{"name": "Acme"},
+			}
+		},
+	}
+	deps.Labels.Settings.NotSettable = "Missing: %s"
+	deps.Labels.Settings.TemplateNotFound = "Not found"
+	return deps
+}
+
+func TestSetDefaultRefusesBrokenTemplate(t *testing.T) {
+	t.Parallel()
+	var defaults []string
+	deps := checkTestDeps(t, &defaults)
+
+	setDefault := func(id string) view.ViewResult {
+		req := httptest.NewRequest(http.MethodPost, "/action/revenue/settings/templates/set-default/"+id, nil)
+		req.SetPathValue("id", id)
+		return NewSetDefaultAction(deps).Handle(context.Background(), &view.ViewContext{Request: req})
+	}
+	if res := setDefault("bad"); res.Headers["HX-Error-Message"] != "Missing: invoice.po_number" {
+		t.Errorf("bad = %+v", res)
+	}
+	if res := setDefault("gone"); res.Headers["HX-Error-Message"] != "Not found" {
+		t.Errorf("unknown = %+v", res)
+	}
+	if len(defaults) != 0 {
+		t.Fatalf("broken template set as default: %v", defaults)
+	}
+	if res := setDefault("good"); res.Headers["HX-Redirect"] == "" || len(defaults) != 1 || defaults[0] != "good" {
+		t.Errorf("good = %+v, defaults %v", res, defaults)
+	}
+}
+
+func TestCheckAction(t *testing.T) {
+	t.Parallel()
+	var defaults []string
+	deps := checkTestDeps(t, &defaults)
+
+	req := httptest.NewRequest(http.MethodGet, "/action/revenue/settings/templates/check/bad", nil)
+	req.SetPathValue("id", "bad")
+	res := NewCheckAction(deps).Handle(context.Background(), &view.ViewContext{Request: req})
+	data, ok := res.Data.(*settingsform.TemplateCheckData)
+	if !ok || data.Report == nil {
+		t.Fatalf("check = %+v", res)
+	}
+	if data.Report.OK() || len(data.Report.Missing) != 1 || data.PreviewURL != "" {
+		t.Errorf("report = %+v, preview %q", data.Report, data.PreviewURL)
+	}
+	if want := []string{"customer.name", "invoice.date"}; !reflect.DeepEqual(data.Report.Unused, want) {
+		t.Errorf("unused = %v, want %v", data.Report.Unused, want)
+	}
+}
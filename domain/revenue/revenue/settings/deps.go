@@ -26,6 +26,15 @@ type SettingsViewDeps struct {
 
 	// Storage operations (injected by composition root)
 	UploadTemplate func(ctx context.Context, bucketName, objectKey string, content []byte, contentType string) error
+
+	// Template check and preview (optional — the check drawer, the preview
+	// and the set-default check are off unless DownloadTemplate and
+	// SampleData are set). SampleData is the invoice data placeholders are
+	// checked against; RenderPreview generates an uploaded template with a
+	// revenue's data, or the sample data for a blank revenue ID.
+	DownloadTemplate func(ctx context.Context, bucket, key string) ([]byte, error)
+	SampleData       func() map[string]any
+	RenderPreview    func(ctx context.Context, templateBytes []byte, revenueID, format string) (string, []byte, error)
 }
 
 func templateConfig(deps *SettingsViewDeps) *templateview.Config {
@@ -0,0 +1,75 @@
+// Package form owns the template data shapes for the document numbering
+// drawers (revenue-numbering-drawer-form.html, revenue-numbering-void-form.html)
+// the FX rate drawers (fx_rates.html) and the invoice template check drawer
+// (template_check.html).
+// Pure types only — no Deps, no context.Context, no repository imports.
+package form
+
+import (
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	pyeza "github.com/erniealice/pyeza-golang"
+)
+
+// SequenceData is the template data for the sequence add/edit drawer. The
+// document type is fixed once a sequence exists (Editing), since its register
+// belongs to that type.
+type SequenceData struct {
+	FormAction    string
+	WorkspaceID   string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Editing       bool
+	DocumentType  string
+	LocationID    string
+	Prefix        string
+	Suffix        string
+	Padding       int
+	Reset         string
+	DocumentTypes []pyeza.SelectOption
+	Locations     []pyeza.SelectOption
+	Resets        []pyeza.SelectOption
+	MaxPadding    int
+	CommonLabels  any
+	Labels        revenuedomain.NumberingLabels
+}
+
+// VoidData is the template data for the void-number drawer.
+type VoidData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Number       string
+	CommonLabels any
+	Labels       revenuedomain.NumberingLabels
+}
+
+// FXRateData is the template data for the rate entry drawer. To defaults to
+// the workspace's functional currency.
+type FXRateData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	From         string
+	To           string
+	Date         string
+	CommonLabels any
+	Labels       revenuedomain.FXRateLabels
+}
+
+// FXImportData is the template data for the rate CSV import drawer.
+type FXImportData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	CommonLabels any
+	Labels       revenuedomain.FXRateLabels
+}
+
+// TemplateCheckData is the template data for the invoice template check
+// drawer: the placeholder report (nil with Error set when the template could
+// not be read) and the preview form, which opens PreviewURL in a new tab.
+type TemplateCheckData struct {
+	TemplateName string
+	Report       *shared.DocTemplateReport
+	Error        string
+	PreviewURL   string
+	Formats      []pyeza.SelectOption
+	CommonLabels any
+	Labels       revenuedomain.SettingsLabels
+}
@@ -0,0 +1,312 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	settingsform "github.com/erniealice/centymo-golang/domain/revenue/revenue/settings/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// fxRateEntity is the permission entity guarding the FX rate settings.
+const fxRateEntity = "forex_rate"
+
+// maxFXImportSize caps the rate CSV upload.
+const maxFXImportSize = 2 << 20
+
+// FXRateViewDeps holds view dependencies for the FX rate settings: the daily
+// rate table, its entry drawer and the CSV import drawer.
+type FXRateViewDeps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	Rates shared.FXRateStore
+
+	// GetFunctionalCurrency returns the workspace's reporting currency, the
+	// default quote currency of a new rate (optional).
+	GetFunctionalCurrency func(ctx context.Context) string
+}
+
+// FXRatePageData holds the data for the FX rate settings page.
+type FXRatePageData struct {
+	types.PageData
+	ContentTemplate string
+	Table           *types.TableConfig
+}
+
+// NewFXRatesView creates the rate list page.
+func NewFXRatesView(deps *FXRateViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(fxRateEntity, "list") {
+			return view.Forbidden(fxRateEntity + ":list")
+		}
+
+		tableConfig, err := buildFXRateTable(ctx, deps)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.FXRates
+		caption := fmt.Sprintf(l.Caption, functionalCurrency(ctx, deps))
+		return view.OK("revenue-settings-fx-rates", &FXRatePageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          l.PageTitle,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      "revenue",
+				ActiveSubNav:   "fx-rates",
+				HeaderTitle:    l.PageTitle,
+				HeaderSubtitle: caption,
+				HeaderIcon:     "icon-refresh-cw",
+				CommonLabels:   deps.CommonLabels,
+			},
+			ContentTemplate: "revenue-settings-fx-rates-content",
+			Table:           tableConfig,
+		})
+	})
+}
+
+// NewFXRatesTableView returns only the rate table card, the refresh target
+// after an entry, import or delete.
+func NewFXRatesTableView(deps *FXRateViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		tableConfig, err := buildFXRateTable(ctx, deps)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", tableConfig)
+	})
+}
+
+// NewFXRateAddAction creates the rate entry action (GET = drawer form, POST =
+// save). Entering a rate for a pair and day that already has one replaces it.
+func NewFXRateAddAction(deps *FXRateViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(fxRateEntity, "create") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		if r.Method == http.MethodGet {
+			return view.OK("revenue-fx-rate-drawer-form", &settingsform.FXRateData{
+				FormAction:   deps.Routes.SettingsFXRateAddURL,
+				To:           functionalCurrency(ctx, deps),
+				Date:         time.Now().Format("2006-01-02"),
+				CommonLabels: nil, // injected by ViewAdapter
+				Labels:       deps.Labels.FXRates,
+			})
+		}
+
+		if err := r.ParseForm(); err != nil {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		rate := &shared.FXRate{From: r.FormValue("from"), To: r.FormValue("to"), Source: shared.FXSourceOperator}
+		date, err := time.Parse("2006-01-02", r.FormValue("date"))
+		if err != nil {
+			return view.HTMXError(deps.Labels.FXRates.InvalidDate)
+		}
+		rate.Date = date
+		if rate.RateMicroUnits, err = shared.ParseFXRate(r.FormValue("rate")); err != nil {
+			return view.HTMXError(deps.Labels.FXRates.InvalidRate)
+		}
+		if err := rate.Validate(); err != nil {
+			return view.HTMXError(fxRateErrorMessage(deps.Labels, err))
+		}
+
+		if err := deps.Rates.SaveFXRates(ctx, []*shared.FXRate{rate}); err != nil {
+			log.Printf("Failed to save FX rate %s: %v", rate.ID(), err)
+			return view.HTMXError(err.Error())
+		}
+		return view.HTMXSuccess("fx-rates-table")
+	})
+}
+
+// NewFXRateImportAction creates the rate CSV import action (GET = upload
+// drawer, POST = import). The file is validated as a whole: one bad line
+// imports nothing.
+func NewFXRateImportAction(deps *FXRateViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(fxRateEntity, "create") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		if r.Method == http.MethodGet {
+			return view.OK("revenue-fx-rate-import-form", &settingsform.FXImportData{
+				FormAction:   deps.Routes.SettingsFXRateImportURL,
+				CommonLabels: nil, // injected by ViewAdapter
+				Labels:       deps.Labels.FXRates,
+			})
+		}
+
+		if err := r.ParseMultipartForm(maxFXImportSize); err != nil {
+			return view.HTMXError(deps.Labels.FXRates.InvalidFile)
+		}
+		file, _, err := r.FormFile("rates_file")
+		if err != nil {
+			return view.HTMXError(deps.Labels.FXRates.InvalidFile)
+		}
+		defer file.Close()
+
+		rates, err := shared.ParseFXRatesCSV(file, shared.FXSourceImport)
+		if err != nil {
+			log.Printf("Failed to parse FX rate import: %v", err)
+			return view.HTMXError(fxRateErrorMessage(deps.Labels, err))
+		}
+		if len(rates) == 0 {
+			return view.HTMXError(deps.Labels.FXRates.InvalidFile)
+		}
+		if err := deps.Rates.SaveFXRates(ctx, rates); err != nil {
+			log.Printf("Failed to save %d imported FX rates: %v", len(rates), err)
+			return view.HTMXError(err.Error())
+		}
+		log.Printf("Imported %d FX rates", len(rates))
+		return view.HTMXSuccess("fx-rates-table")
+	})
+}
+
+// NewFXRateDeleteAction creates the rate delete action (POST only). The rate
+// ID comes via query param (?id=) appended by table-actions.js. Snapshots
+// already taken from the rate keep their value.
+func NewFXRateDeleteAction(deps *FXRateViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(fxRateEntity, "delete") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		_ = viewCtx.Request.ParseForm()
+		id := viewCtx.Request.FormValue("id")
+		if id == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+
+		if err := deps.Rates.DeleteFXRate(ctx, id); err != nil {
+			log.Printf("Failed to delete FX rate %s: %v", id, err)
+			return view.HTMXError(err.Error())
+		}
+		return view.HTMXSuccess("fx-rates-table")
+	})
+}
+
+func functionalCurrency(ctx context.Context, deps *FXRateViewDeps) string {
+	if deps.GetFunctionalCurrency == nil {
+		return ""
+	}
+	return deps.GetFunctionalCurrency(ctx)
+}
+
+// fxRateErrorMessage maps a shared FX rate error to its label, keeping the
+// CSV line number when there is one.
+func fxRateErrorMessage(labels revenuedomain.Labels, err error) string {
+	l := labels.FXRates
+	var msg string
+	switch {
+	case errors.Is(err, shared.ErrFXRateCurrency):
+		msg = l.InvalidCurrency
+	case errors.Is(err, shared.ErrFXRateValue):
+		msg = l.InvalidRate
+	case errors.Is(err, shared.ErrFXRateDate):
+		msg = l.InvalidDate
+	default:
+		return l.InvalidFile
+	}
+	if prefix, _, ok := strings.Cut(err.Error(), ": "); ok && strings.HasPrefix(prefix, "line ") {
+		return prefix + ": " + msg
+	}
+	return msg
+}
+
+func buildFXRateTable(ctx context.Context, deps *FXRateViewDeps) (*types.TableConfig, error) {
+	perms := view.GetUserPermissions(ctx)
+	rates, err := deps.Rates.ListFXRates(ctx)
+	if err != nil {
+		log.Printf("Failed to list FX rates: %v", err)
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+
+	l := deps.Labels.FXRates
+	columns := []types.TableColumn{
+		{Key: "date", Label: l.Date, WidthClass: "col-4xl"},
+		{Key: "from", Label: l.From, WidthClass: "col-2xl"},
+		{Key: "to", Label: l.To, WidthClass: "col-2xl"},
+		{Key: "rate", Label: l.Rate},
+		{Key: "source", Label: l.Source, WidthClass: "col-3xl"},
+	}
+	rows := []types.TableRow{}
+	// Newest first, so today's rates are on the first page.
+	for i := len(rates) - 1; i >= 0; i-- {
+		rt := rates[i]
+		source := l.SourceOperator
+		if rt.Source == shared.FXSourceImport {
+			source = l.SourceImport
+		}
+		rows = append(rows, types.TableRow{
+			ID: rt.ID(),
+			Cells: []types.TableCell{
+				{Type: "text", Value: rt.Date.Format("2006-01-02")},
+				{Type: "text", Value: rt.From},
+				{Type: "text", Value: rt.To},
+				{Type: "text", Value: shared.FormatFXRate(rt.RateMicroUnits)},
+				{Type: "badge", Value: source, Variant: "default"},
+			},
+			DataAttrs: map[string]string{
+				"from":   rt.From,
+				"to":     rt.To,
+				"source": rt.Source,
+			},
+			Actions: []types.TableAction{
+				{Type: "delete", Label: deps.Labels.Actions.Delete, Action: "delete", URL: deps.Routes.SettingsFXRateDeleteURL, ItemName: rt.ID(),
+					ConfirmTitle: deps.Labels.Actions.Delete, ConfirmMessage: fmt.Sprintf(l.DeleteConfirm, rt.ID()),
+					Disabled: !perms.Can(fxRateEntity, "delete"), DisabledTooltip: deps.Labels.Errors.PermissionDenied},
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:          "fx-rates-table",
+		RefreshURL:  deps.Routes.SettingsFXRatesTableURL,
+		Columns:     columns,
+		Rows:        rows,
+		ShowSearch:  true,
+		ShowActions: true,
+		ShowSort:    true,
+		ShowEntries: true,
+		Labels:      deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+		PrimaryAction: &types.PrimaryAction{
+			Label:           l.AddRate,
+			ActionURL:       deps.Routes.SettingsFXRateAddURL,
+			Icon:            "icon-plus",
+			Disabled:        !perms.Can(fxRateEntity, "create"),
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		},
+	}
+	if perms.Can(fxRateEntity, "create") {
+		tableConfig.ImportAction = &types.ImportAction{
+			Label:     l.ImportRates,
+			Icon:      "icon-upload",
+			ActionURL: deps.Routes.SettingsFXRateImportURL,
+		}
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig, nil
+}
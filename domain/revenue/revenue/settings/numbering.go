@@ -0,0 +1,543 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	numberingform "github.com/erniealice/centymo-golang/domain/revenue/revenue/settings/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	locationpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/location"
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// numberingEntity is the permission entity guarding the numbering settings.
+const numberingEntity = "document_sequence"
+
+// NumberingViewDeps holds view dependencies for the document numbering
+// settings: the sequence table, its add/edit drawer and the register of
+// issued numbers per sequence.
+type NumberingViewDeps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	Numbering *shared.DocumentNumbering
+
+	// Location names for per-location series (optional — the location
+	// select is hidden and IDs are shown when nil).
+	ListLocations func(ctx context.Context, req *locationpb.ListLocationsRequest) (*locationpb.ListLocationsResponse, error)
+}
+
+// NumberingPageData holds the data for the numbering settings pages.
+type NumberingPageData struct {
+	types.PageData
+	ContentTemplate string
+	Table           *types.TableConfig
+}
+
+// NewNumberingView creates the sequence list page.
+func NewNumberingView(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "list") {
+			return view.Forbidden(numberingEntity + ":list")
+		}
+
+		tableConfig, err := buildSequenceTable(ctx, deps)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.Numbering
+		return view.OK("revenue-settings-numbering", &NumberingPageData{
+			PageData:        numberingPageData(deps, viewCtx, l.PageTitle, l.Caption),
+			ContentTemplate: "revenue-settings-numbering-content",
+			Table:           tableConfig,
+		})
+	})
+}
+
+// NewNumberingTableView returns only the sequence table card, the refresh
+// target after a save or delete.
+func NewNumberingTableView(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		tableConfig, err := buildSequenceTable(ctx, deps)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", tableConfig)
+	})
+}
+
+// NewNumberingAddAction creates the sequence add action (GET = drawer form,
+// POST = create).
+func NewNumberingAddAction(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "create") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		if viewCtx.Request.Method == http.MethodGet {
+			return view.OK("revenue-numbering-drawer-form", sequenceFormData(ctx, deps, deps.Routes.SettingsNumberingAddURL, &shared.DocumentSequence{
+				DocumentType: shared.DocumentInvoice,
+				Prefix:       "INV-",
+				Padding:      6,
+			}, false))
+		}
+
+		return saveSequence(ctx, deps, viewCtx.Request, &shared.DocumentSequence{})
+	})
+}
+
+// NewNumberingEditAction creates the sequence edit action (GET = pre-filled
+// drawer form, POST = update). The document type cannot change.
+func NewNumberingEditAction(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		seq, err := findSequence(ctx, deps, viewCtx.Request.PathValue("id"))
+		if err != nil {
+			return view.HTMXError(deps.Labels.Errors.NotFound)
+		}
+
+		if viewCtx.Request.Method == http.MethodGet {
+			formAction := route.ResolveURL(deps.Routes.SettingsNumberingEditURL, "id", seq.ID)
+			return view.OK("revenue-numbering-drawer-form", sequenceFormData(ctx, deps, formAction, seq, true))
+		}
+
+		return saveSequence(ctx, deps, viewCtx.Request, seq)
+	})
+}
+
+// NewNumberingDeleteAction creates the sequence delete action (POST only).
+// The sequence ID comes via query param (?id=) appended by table-actions.js.
+// Sequences that issued numbers cannot be deleted.
+func NewNumberingDeleteAction(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "delete") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		_ = viewCtx.Request.ParseForm()
+		id := viewCtx.Request.FormValue("id")
+		if id == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+
+		if err := deps.Numbering.DeleteSequence(ctx, id); err != nil {
+			log.Printf("Failed to delete numbering sequence %s: %v", id, err)
+			return view.HTMXError(numberingErrorMessage(deps.Labels, err))
+		}
+		return view.HTMXSuccess("numbering-table")
+	})
+}
+
+// NewNumberingRegisterView creates the page listing the numbers one sequence
+// issued, voided ones included.
+func NewNumberingRegisterView(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "list") {
+			return view.Forbidden(numberingEntity + ":list")
+		}
+
+		seq, err := findSequence(ctx, deps, viewCtx.Request.PathValue("id"))
+		if err != nil {
+			return view.Error(err)
+		}
+		tableConfig, err := buildRegisterTable(ctx, deps, seq)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		l := deps.Labels.Numbering
+		return view.OK("revenue-settings-numbering", &NumberingPageData{
+			PageData:        numberingPageData(deps, viewCtx, l.Register, fmt.Sprintf(l.RegisterCaption, seq.Pattern())),
+			ContentTemplate: "revenue-settings-numbering-content",
+			Table:           tableConfig,
+		})
+	})
+}
+
+// NewNumberingNumbersView returns only the register table card, the refresh
+// target after a void.
+func NewNumberingNumbersView(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		seq, err := findSequence(ctx, deps, viewCtx.Request.PathValue("id"))
+		if err != nil {
+			return view.Error(err)
+		}
+		tableConfig, err := buildRegisterTable(ctx, deps, seq)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", tableConfig)
+	})
+}
+
+// NewNumberingVoidAction creates the void action for an issued number (GET =
+// reason drawer, POST = void). The number comes as ?number=. A voided number
+// stays in the register and is never issued again.
+func NewNumberingVoidAction(deps *NumberingViewDeps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can(numberingEntity, "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		r := viewCtx.Request
+		_ = r.ParseForm()
+		number := r.FormValue("number")
+		if number == "" {
+			return view.HTMXError(deps.Labels.Errors.IDRequired)
+		}
+
+		if r.Method == http.MethodGet {
+			return view.OK("revenue-numbering-void-form", &numberingform.VoidData{
+				FormAction:   route.ResolveURL(deps.Routes.SettingsNumberingVoidURL, "id", r.PathValue("id")) + "?number=" + url.QueryEscape(number),
+				Number:       number,
+				CommonLabels: nil, // injected by ViewAdapter
+				Labels:       deps.Labels.Numbering,
+			})
+		}
+
+		reason := strings.TrimSpace(r.FormValue("reason"))
+		if reason == "" {
+			return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+		}
+		if err := deps.Numbering.Void(ctx, number, reason); err != nil {
+			log.Printf("Failed to void document number %s: %v", number, err)
+			return view.HTMXError(numberingErrorMessage(deps.Labels, err))
+		}
+		return view.HTMXSuccess("numbering-register-table")
+	})
+}
+
+func numberingPageData(deps *NumberingViewDeps, viewCtx *view.ViewContext, title, caption string) types.PageData {
+	return types.PageData{
+		CacheVersion:   viewCtx.CacheVersion,
+		Title:          title,
+		CurrentPath:    viewCtx.CurrentPath,
+		ActiveNav:      "revenue",
+		ActiveSubNav:   "settings-numbering",
+		HeaderTitle:    title,
+		HeaderSubtitle: caption,
+		HeaderIcon:     "icon-list",
+		CommonLabels:   deps.CommonLabels,
+	}
+}
+
+// saveSequence reads the drawer fields into seq (keeping its ID and, for an
+// existing sequence, its document type) and saves it.
+func saveSequence(ctx context.Context, deps *NumberingViewDeps, r *http.Request, seq *shared.DocumentSequence) view.ViewResult {
+	if err := r.ParseForm(); err != nil {
+		return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+	}
+	padding, err := strconv.Atoi(r.FormValue("padding"))
+	if err != nil {
+		return view.HTMXError(deps.Labels.Errors.InvalidFormData)
+	}
+	if seq.ID == "" {
+		seq.DocumentType = r.FormValue("document_type")
+	}
+	seq.LocationID = r.FormValue("location_id")
+	seq.Prefix = strings.TrimSpace(r.FormValue("prefix"))
+	seq.Suffix = strings.TrimSpace(r.FormValue("suffix"))
+	seq.Padding = padding
+	seq.Reset = r.FormValue("reset")
+
+	if err := deps.Numbering.SaveSequence(ctx, seq); err != nil {
+		log.Printf("Failed to save numbering sequence %s: %v", seq.ID, err)
+		if errors.Is(err, shared.ErrDocumentSequenceMarker) {
+			return view.HTMXError(fmt.Sprintf(deps.Labels.Numbering.MissingMarker, shared.DocumentTypeMarker(seq.DocumentType)))
+		}
+		return view.HTMXError(numberingErrorMessage(deps.Labels, err))
+	}
+	return view.HTMXSuccess("numbering-table")
+}
+
+// findSequence returns the configured sequence with id.
+func findSequence(ctx context.Context, deps *NumberingViewDeps, id string) (*shared.DocumentSequence, error) {
+	seqs, err := deps.Numbering.Sequences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range seqs {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, shared.ErrDocumentSequenceNotFound
+}
+
+// numberingErrorMessage maps a shared numbering error to its label.
+func numberingErrorMessage(labels revenuedomain.Labels, err error) string {
+	l := labels.Numbering
+	switch {
+	case errors.Is(err, shared.ErrDocumentSequenceType):
+		return l.InvalidType
+	case errors.Is(err, shared.ErrDocumentSequenceToken):
+		return l.InvalidToken
+	case errors.Is(err, shared.ErrDocumentSequencePadding):
+		return fmt.Sprintf(l.InvalidPadding, shared.MaxSequencePadding)
+	case errors.Is(err, shared.ErrDocumentSequenceReset):
+		return l.InvalidReset
+	case errors.Is(err, shared.ErrDocumentSequencePeriod):
+		return l.MissingPeriod
+	case errors.Is(err, shared.ErrDocumentSequenceConflict):
+		return l.Conflict
+	case errors.Is(err, shared.ErrDocumentSequenceInUse):
+		return l.InUse
+	case errors.Is(err, shared.ErrDocumentSequenceLocked):
+		return l.Locked
+	case errors.Is(err, shared.ErrDocumentNumberAlreadyVoid):
+		return l.AlreadyVoided
+	case errors.Is(err, shared.ErrDocumentSequenceNotFound), errors.Is(err, shared.ErrDocumentNumberNotFound):
+		return labels.Errors.NotFound
+	}
+	return err.Error()
+}
+
+func sequenceFormData(ctx context.Context, deps *NumberingViewDeps, formAction string, seq *shared.DocumentSequence, editing bool) *numberingform.SequenceData {
+	l := deps.Labels.Numbering
+
+	docTypes := make([]pyeza.SelectOption, 0, len(shared.DocumentTypes()))
+	for _, t := range shared.DocumentTypes() {
+		docTypes = append(docTypes, pyeza.SelectOption{Value: t, Label: documentTypeLabel(l, t), Description: shared.DocumentTypeMarker(t)})
+	}
+
+	var locations []pyeza.SelectOption
+	if names := locationNames(ctx, deps); names != nil {
+		locations = append(locations, pyeza.SelectOption{Value: "", Label: l.AllLocations})
+		for _, loc := range names.order {
+			locations = append(locations, pyeza.SelectOption{Value: loc, Label: names.byID[loc]})
+		}
+	}
+
+	return &numberingform.SequenceData{
+		FormAction:    formAction,
+		Editing:       editing,
+		DocumentType:  seq.DocumentType,
+		LocationID:    seq.LocationID,
+		Prefix:        seq.Prefix,
+		Suffix:        seq.Suffix,
+		Padding:       seq.Padding,
+		Reset:         seq.Reset,
+		DocumentTypes: docTypes,
+		Locations:     locations,
+		Resets: []pyeza.SelectOption{
+			{Value: shared.SequenceResetNever, Label: l.ResetNever},
+			{Value: shared.SequenceResetYearly, Label: l.ResetYearly},
+			{Value: shared.SequenceResetMonthly, Label: l.ResetMonthly},
+		},
+		MaxPadding:   shared.MaxSequencePadding,
+		CommonLabels: nil, // injected by ViewAdapter
+		Labels:       l,
+	}
+}
+
+// locationList is the workspace's locations in listing order.
+type locationList struct {
+	order []string
+	byID  map[string]string
+}
+
+// locationNames lists the workspace's locations, or nil when ListLocations is
+// unwired or fails.
+func locationNames(ctx context.Context, deps *NumberingViewDeps) *locationList {
+	if deps.ListLocations == nil {
+		return nil
+	}
+	resp, err := deps.ListLocations(ctx, &locationpb.ListLocationsRequest{})
+	if err != nil {
+		log.Printf("Failed to list locations for numbering settings: %v", err)
+		return nil
+	}
+	list := &locationList{byID: map[string]string{}}
+	for _, loc := range resp.GetData() {
+		name := loc.GetName()
+		if name == "" {
+			name = loc.GetId()
+		}
+		list.order = append(list.order, loc.GetId())
+		list.byID[loc.GetId()] = name
+	}
+	return list
+}
+
+func buildSequenceTable(ctx context.Context, deps *NumberingViewDeps) (*types.TableConfig, error) {
+	perms := view.GetUserPermissions(ctx)
+	seqs, err := deps.Numbering.Sequences(ctx)
+	if err != nil {
+		log.Printf("Failed to list numbering sequences: %v", err)
+		return nil, fmt.Errorf("failed to load numbering sequences: %w", err)
+	}
+
+	l := deps.Labels.Numbering
+	names := locationNames(ctx, deps)
+	now := time.Now()
+
+	columns := []types.TableColumn{
+		{Key: "document_type", Label: l.DocumentType, WidthClass: "col-5xl"},
+		{Key: "location", Label: l.Location, WidthClass: "col-5xl"},
+		{Key: "pattern", Label: l.Pattern},
+		{Key: "reset", Label: l.Reset, WidthClass: "col-3xl"},
+		{Key: "example", Label: l.Example, NoSort: true},
+	}
+	rows := []types.TableRow{}
+	for _, s := range seqs {
+		registerURL := route.ResolveURL(deps.Routes.SettingsNumberingRegisterURL, "id", s.ID)
+		location := l.AllLocations
+		if s.LocationID != "" {
+			location = s.LocationID
+			if names != nil && names.byID[s.LocationID] != "" {
+				location = names.byID[s.LocationID]
+			}
+		}
+		rows = append(rows, types.TableRow{
+			ID:   s.ID,
+			Href: registerURL,
+			Cells: []types.TableCell{
+				{Type: "text", Value: documentTypeLabel(l, s.DocumentType)},
+				{Type: "text", Value: location},
+				{Type: "text", Value: s.Pattern()},
+				{Type: "text", Value: resetLabel(l, s.Reset)},
+				{Type: "text", Value: s.Number(1, now)},
+			},
+			DataAttrs: map[string]string{
+				"document-type": s.DocumentType,
+				"location":      s.LocationID,
+			},
+			Actions: []types.TableAction{
+				{Type: "view", Label: l.Register, Action: "view", Href: registerURL},
+				{Type: "edit", Label: l.EditSequence, Action: "edit", URL: route.ResolveURL(deps.Routes.SettingsNumberingEditURL, "id", s.ID), DrawerTitle: l.EditSequence, Disabled: !perms.Can(numberingEntity, "update"), DisabledTooltip: deps.Labels.Errors.PermissionDenied},
+				{Type: "delete", Label: deps.Labels.Actions.Delete, Action: "delete", URL: deps.Routes.SettingsNumberingDeleteURL, ItemName: s.Pattern(),
+					ConfirmTitle: deps.Labels.Actions.Delete, ConfirmMessage: fmt.Sprintf(l.DeleteConfirm, s.Pattern()),
+					Disabled: !perms.Can(numberingEntity, "delete"), DisabledTooltip: deps.Labels.Errors.PermissionDenied},
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:          "numbering-table",
+		RefreshURL:  deps.Routes.SettingsNumberingTableURL,
+		Columns:     columns,
+		Rows:        rows,
+		ShowSearch:  true,
+		ShowActions: true,
+		ShowSort:    true,
+		ShowEntries: true,
+		Labels:      deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+		PrimaryAction: &types.PrimaryAction{
+			Label:           l.AddSequence,
+			ActionURL:       deps.Routes.SettingsNumberingAddURL,
+			Icon:            "icon-plus",
+			Disabled:        !perms.Can(numberingEntity, "create"),
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig, nil
+}
+
+func buildRegisterTable(ctx context.Context, deps *NumberingViewDeps, seq *shared.DocumentSequence) (*types.TableConfig, error) {
+	perms := view.GetUserPermissions(ctx)
+	numbers, err := deps.Numbering.Numbers(ctx, seq.ID)
+	if err != nil {
+		log.Printf("Failed to list numbers of sequence %s: %v", seq.ID, err)
+		return nil, fmt.Errorf("failed to load issued numbers: %w", err)
+	}
+
+	l := deps.Labels.Numbering
+	voidURL := route.ResolveURL(deps.Routes.SettingsNumberingVoidURL, "id", seq.ID)
+	columns := []types.TableColumn{
+		{Key: "number", Label: l.Number},
+		{Key: "status", Label: l.Status, WidthClass: "col-3xl"},
+		{Key: "issued_at", Label: l.IssuedAt, WidthClass: "col-5xl"},
+		{Key: "void_reason", Label: l.VoidReason, NoSort: true, WidthClass: "col-9xl"},
+	}
+	rows := []types.TableRow{}
+	for _, n := range numbers {
+		status, variant := l.Issued, "success"
+		if n.Status == shared.DocumentNumberVoided {
+			status, variant = l.Voided, "danger"
+		}
+		rows = append(rows, types.TableRow{
+			ID: n.Number,
+			Cells: []types.TableCell{
+				{Type: "text", Value: n.Number},
+				{Type: "badge", Value: status, Variant: variant},
+				types.DateTimeCell(n.IssuedAt.Format(time.RFC3339), types.DateTimeReadable),
+				{Type: "text", Value: n.VoidReason},
+			},
+			DataAttrs: map[string]string{
+				"number": n.Number,
+				"status": n.Status,
+			},
+			Actions: []types.TableAction{
+				{Type: "delete", Label: l.Void, Action: "edit", URL: voidURL + "?number=" + url.QueryEscape(n.Number), DrawerTitle: l.Void,
+					Disabled: !perms.Can(numberingEntity, "update") || n.Status == shared.DocumentNumberVoided, DisabledTooltip: deps.Labels.Errors.PermissionDenied},
+			},
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:                   "numbering-register-table",
+		RefreshURL:           route.ResolveURL(deps.Routes.SettingsNumberingNumbersURL, "id", seq.ID),
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowSort:             true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "issued_at",
+		DefaultSortDirection: "desc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.Register,
+			Message: l.RegisterEmpty,
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig, nil
+}
+
+func documentTypeLabel(l revenuedomain.NumberingLabels, docType string) string {
+	if s := l.DocumentTypes[docType]; s != "" {
+		return s
+	}
+	return docType
+}
+
+func resetLabel(l revenuedomain.NumberingLabels, reset string) string {
+	switch reset {
+	case shared.SequenceResetYearly:
+		return l.ResetYearly
+	case shared.SequenceResetMonthly:
+		return l.ResetMonthly
+	}
+	return l.ResetNever
+}
@@ -1,12 +1,90 @@
 package settings
 
 import (
+	"context"
+	"log"
+
+	documenttemplatepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/template"
 	templateview "github.com/erniealice/hybra-golang/views/template"
+	templateviewform "github.com/erniealice/hybra-golang/views/template/form"
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
 )
 
-// NewView creates the sales settings templates list view.
+// NewView creates the sales settings templates list view. When templates can
+// be checked, each row also opens the check drawer.
 // Route: GET /app/sales/settings/templates
 func NewView(deps *SettingsViewDeps) view.View {
-	return templateview.NewListView(templateConfig(deps))
+	cfg := templateConfig(deps)
+	if !checkEnabled(deps) {
+		return templateview.NewListView(cfg)
+	}
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := cfg.Labels
+		tableConfig := templateview.BuildTable(listTemplates(ctx, deps), cfg)
+		for i := range tableConfig.Rows {
+			row := &tableConfig.Rows[i]
+			check := types.TableAction{
+				Type:        "view",
+				Label:       deps.Labels.Settings.Check,
+				Action:      "edit",
+				URL:         route.ResolveURL(deps.Routes.SettingsTemplateCheckURL, "id", row.ID),
+				DrawerTitle: deps.Labels.Settings.CheckTitle,
+			}
+			row.Actions = append([]types.TableAction{check}, row.Actions...)
+		}
+		tableConfig.RefreshURL = cfg.ListURL
+		tableConfig.ShowSearch = true
+		tableConfig.ShowActions = true
+		tableConfig.ShowEntries = true
+		tableConfig.PrimaryAction = &types.PrimaryAction{
+			Label:     l.UploadTemplate,
+			ActionURL: cfg.UploadURL,
+			Icon:      "icon-upload",
+		}
+		types.ApplyTableSettings(tableConfig)
+
+		return view.OK("template-list", &templateviewform.PageData{
+			PageData: types.PageData{
+				CacheVersion:   viewCtx.CacheVersion,
+				Title:          l.PageTitle,
+				CurrentPath:    viewCtx.CurrentPath,
+				ActiveNav:      cfg.ActiveNav,
+				HeaderTitle:    l.PageTitle,
+				HeaderSubtitle: l.Caption,
+				HeaderIcon:     cfg.PageIcon,
+				CommonLabels:   cfg.CommonLabels,
+			},
+			ContentTemplate: "template-list-content",
+			Table:           tableConfig,
+		})
+	})
+}
+
+// listTemplates returns the active invoice templates as list rows.
+func listTemplates(ctx context.Context, deps *SettingsViewDeps) []templateviewform.TemplateData {
+	cfg := templateConfig(deps)
+	resp, err := deps.ListDocumentTemplates(ctx, &documenttemplatepb.ListDocumentTemplatesRequest{})
+	if err != nil {
+		log.Printf("Failed to list document templates: %v", err)
+		return nil
+	}
+	var templates []templateviewform.TemplateData
+	for _, t := range resp.GetData() {
+		if !t.GetActive() || t.GetDocumentPurpose() != cfg.DocumentPurpose {
+			continue
+		}
+		templates = append(templates, templateviewform.TemplateData{
+			ID:              t.GetId(),
+			Name:            t.GetName(),
+			TemplateType:    t.GetTemplateType(),
+			DocumentPurpose: t.GetDocumentPurpose(),
+			OriginalFile:    t.GetOriginalFilename(),
+			FileSizeBytes:   t.GetFileSizeBytes(),
+			IsDefault:       t.GetIsDefault(),
+			SetDefaultURL:   route.ResolveURL(cfg.SetDefaultURL, "id", t.GetId()),
+		})
+	}
+	return templates
 }
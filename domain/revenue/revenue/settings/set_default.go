@@ -1,12 +1,41 @@
 package settings
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
 	templateview "github.com/erniealice/hybra-golang/views/template"
 	"github.com/erniealice/pyeza-golang/view"
 )
 
 // NewSetDefaultAction creates the set-default handler for invoice templates.
+// When templates can be checked, one that cannot be read or uses
+// placeholders the invoice data does not provide is refused, since every
+// invoice download would then come out broken.
 // Route: POST /action/sales/settings/templates/set-default/{id}
 func NewSetDefaultAction(deps *SettingsViewDeps) view.View {
-	return templateview.NewSetDefaultAction(templateConfig(deps))
+	setDefault := templateview.NewSetDefaultAction(templateConfig(deps))
+	if !checkEnabled(deps) {
+		return setDefault
+	}
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels.Settings
+		id := viewCtx.Request.PathValue("id")
+
+		_, report, err := checkTemplate(ctx, deps, id)
+		if err != nil {
+			log.Printf("Refusing default invoice template %s: %v", id, err)
+			if errors.Is(err, errTemplateNotFound) {
+				return view.HTMXError(l.TemplateNotFound)
+			}
+			return view.HTMXError(templateErrorMessage(l, err))
+		}
+		if !report.OK() {
+			return view.HTMXError(fmt.Sprintf(l.NotSettable, strings.Join(report.Missing, ", ")))
+		}
+		return setDefault.Handle(ctx, viewCtx)
+	})
 }
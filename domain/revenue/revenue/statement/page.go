@@ -0,0 +1,522 @@
+package statement
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+)
+
+// PageData holds the data for the statement list and client pages.
+type PageData struct {
+	types.PageData
+	ContentTemplate string
+	Labels          revenuedomain.StatementLabels
+	From            string
+	To              string
+	Error           string
+	FilterURL       string
+
+	// Client page only
+	BackURL     string
+	Currency    string
+	DownloadURL string // "" when documents are unavailable
+	EmailURL    string // "" when email is unavailable
+	Opening     string
+	Charges     string
+	Credits     string
+	Closing     string
+	AgingTable  *types.TableConfig
+
+	Table *types.TableConfig
+}
+
+// EmailFormData is the template data for the email drawers.
+type EmailFormData struct {
+	FormAction   string
+	WorkspaceID  string // injected by C1: populated by ViewAdapter.injectWorkspaceID for action_workspace_guard
+	Message      string
+	CanSend      bool
+	CommonLabels any
+	Labels       revenuedomain.StatementLabels
+}
+
+// canEmail reports whether statements can be emailed.
+func canEmail(deps *Deps) bool {
+	return deps.Render != nil && deps.SendEmail != nil && deps.ListClients != nil
+}
+
+// NewView creates the statement list page: one row per client and currency
+// with the period's opening balance, movements and closing balance.
+func NewView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		pageData := newPageData(deps, viewCtx, p, errMsg, deps.Labels.Statement.PageTitle)
+		pageData.FilterURL = deps.Routes.StatementsURL
+		pageData.Table = buildListTable(ctx, deps, statements, p)
+		return view.OK("revenue-statements", pageData)
+	})
+}
+
+// NewTableView returns only the list table-card HTML.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		if errMsg != "" {
+			return view.HTMXError(errMsg)
+		}
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+		return view.OK("table-card", buildListTable(ctx, deps, statements, p))
+	})
+}
+
+// NewClientView creates one client's statement page: the summary, the ledger
+// with its running balance and the aging of the invoices open at the end of
+// the period. ?currency= picks the account when the client has several.
+func NewClientView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			return view.Forbidden("invoice:list")
+		}
+
+		clientID := viewCtx.Request.PathValue("id")
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+		st := shared.FindStatement(statements, clientID, viewCtx.Request.URL.Query().Get("currency"))
+		if st == nil {
+			st = &shared.Statement{ClientID: clientID, ClientName: clientID, Currency: viewCtx.Request.URL.Query().Get("currency"), From: p.from, To: p.to}
+		}
+
+		l := deps.Labels.Statement
+		query := clientQuery(p, st.Currency)
+		pageData := newPageData(deps, viewCtx, p, errMsg, fmt.Sprintf(l.ClientTitle, st.ClientName))
+		pageData.FilterURL = route.ResolveURL(deps.Routes.StatementURL, "id", url.PathEscape(clientID))
+		pageData.BackURL = deps.Routes.StatementsURL + p.query()
+		pageData.Currency = st.Currency
+		if deps.Render != nil {
+			pageData.DownloadURL = route.ResolveURL(deps.Routes.StatementDownloadURL, "id", url.PathEscape(clientID)) + query
+		}
+		if canEmail(deps) && perms.Can("invoice", "update") {
+			pageData.EmailURL = route.ResolveURL(deps.Routes.StatementEmailURL, "id", url.PathEscape(clientID)) + query
+		}
+		pageData.Opening = types.FormatMoney(st.Opening, st.Currency)
+		pageData.Charges = types.FormatMoney(st.Charges, st.Currency)
+		pageData.Credits = types.FormatMoney(st.Credits, st.Currency)
+		pageData.Closing = types.FormatMoney(st.Closing, st.Currency)
+		pageData.Table = buildLedgerTable(deps, st)
+		pageData.AgingTable = buildAgingTable(deps, st)
+		return view.OK("revenue-statements", pageData)
+	})
+}
+
+func newPageData(deps *Deps, viewCtx *view.ViewContext, p params, errMsg, title string) *PageData {
+	l := deps.Labels.Statement
+	return &PageData{
+		PageData: types.PageData{
+			CacheVersion:   viewCtx.CacheVersion,
+			Title:          title,
+			CurrentPath:    viewCtx.CurrentPath,
+			ActiveNav:      "revenue",
+			ActiveSubNav:   "statements",
+			HeaderTitle:    title,
+			HeaderSubtitle: fmt.Sprintf(l.Caption, p.from.Format(types.DateReadable), p.to.Format(types.DateReadable)),
+			HeaderIcon:     "icon-file-text",
+			CommonLabels:   deps.CommonLabels,
+		},
+		ContentTemplate: "revenue-statements-content",
+		Labels:          l,
+		From:            p.from.Format(dateLayout),
+		To:              p.to.Format(dateLayout),
+		Error:           errMsg,
+	}
+}
+
+// clientQuery returns the ?from=&to=&currency= string of a client statement.
+func clientQuery(p params, currency string) string {
+	return p.query() + "&" + url.Values{"currency": {currency}}.Encode()
+}
+
+// NewDownloadHandler creates the statement download handler. It takes the
+// client statement's query plus ?format=pdf (default) or docx.
+func NewDownloadHandler(deps *Deps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "list") {
+			http.Error(w, deps.Labels.Errors.PermissionDenied, http.StatusForbidden)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "pdf"
+		}
+		if format != "pdf" && format != "docx" {
+			http.Error(w, "invalid format: must be \"pdf\" or \"docx\"", http.StatusBadRequest)
+			return
+		}
+		p, errMsg := parseParams(r, deps)
+		if errMsg != "" {
+			http.Error(w, errMsg, http.StatusUnprocessableEntity)
+			return
+		}
+
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		st := shared.FindStatement(statements, r.PathValue("id"), r.URL.Query().Get("currency"))
+		if st == nil {
+			http.Error(w, "statement not found", http.StatusNotFound)
+			return
+		}
+
+		out, err := deps.Render(ctx, Data(deps.Labels, st, now(deps)), format)
+		if err != nil {
+			log.Printf("statement download: %v", err)
+			http.Error(w, "failed to generate statement", http.StatusInternalServerError)
+			return
+		}
+		contentType := "application/pdf"
+		if format == "docx" {
+			contentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename(st), format))
+		w.Write(out)
+	}
+}
+
+// NewEmailAction creates the client statement email action (GET =
+// confirmation drawer, POST = send). It takes the client statement's query.
+func NewEmailAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		l := deps.Labels.Statement
+		r := viewCtx.Request
+		p, errMsg := parseParams(r, deps)
+		if errMsg != "" {
+			return view.HTMXError(errMsg)
+		}
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+		clientID := r.PathValue("id")
+		st := shared.FindStatement(statements, clientID, r.URL.Query().Get("currency"))
+		if st == nil {
+			return view.HTMXError(l.EmailNothing)
+		}
+		emails, err := clientEmails(ctx, deps)
+		if err != nil {
+			return view.Error(err)
+		}
+		address := emails[st.ClientID]
+
+		if r.Method == http.MethodGet {
+			data := &EmailFormData{
+				FormAction:   route.ResolveURL(deps.Routes.StatementEmailURL, "id", url.PathEscape(clientID)) + clientQuery(p, st.Currency),
+				CanSend:      address != "",
+				CommonLabels: deps.CommonLabels,
+				Labels:       l,
+				Message:      fmt.Sprintf(l.EmailMessage, st.ClientName, address),
+			}
+			if address == "" {
+				data.Message = fmt.Sprintf(l.EmailNoAddress, st.ClientName)
+			}
+			return view.OK("revenue-statement-email-form", data)
+		}
+
+		if address == "" {
+			return view.HTMXError(fmt.Sprintf(l.EmailNoAddress, st.ClientName))
+		}
+		if err := send(ctx, deps, st, address, now(deps)); err != nil {
+			log.Printf("statement: failed to email %s: %v", st.ClientID, err)
+			return view.HTMXError(l.EmailFailed)
+		}
+		return view.HTMXSuccess("statement-table")
+	})
+}
+
+// NewEmailAllAction creates the bulk email action (GET = confirmation drawer,
+// POST = email every statement with a balance due for the period).
+func NewEmailAllAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("invoice", "update") {
+			return view.HTMXError(deps.Labels.Errors.PermissionDenied)
+		}
+
+		l := deps.Labels.Statement
+		p, errMsg := parseParams(viewCtx.Request, deps)
+		if errMsg != "" {
+			return view.HTMXError(errMsg)
+		}
+		statements, err := load(ctx, deps, p)
+		if err != nil {
+			return view.Error(err)
+		}
+
+		if viewCtx.Request.Method == http.MethodGet {
+			n := due(statements)
+			data := &EmailFormData{
+				FormAction:   deps.Routes.StatementsEmailURL + p.query(),
+				CanSend:      n > 0,
+				CommonLabels: deps.CommonLabels,
+				Labels:       l,
+				Message:      fmt.Sprintf(l.EmailAllMessage, n),
+			}
+			if n == 0 {
+				data.Message = l.EmailNothing
+			}
+			return view.OK("revenue-statement-email-form", data)
+		}
+
+		res, err := emailAll(ctx, deps, statements, now(deps))
+		if err != nil {
+			log.Printf("statement: bulk email failed: %v", err)
+			return view.HTMXError(l.EmailFailed)
+		}
+		log.Printf("statement: %d statements emailed, %d without address, %d failed", res.Sent, res.NoAddress, res.Failed)
+		if res.NoAddress > 0 || res.Failed > 0 {
+			return view.HTMXError(fmt.Sprintf(l.EmailResult, res.Sent, res.NoAddress, res.Failed))
+		}
+		return view.HTMXSuccess("statements-table")
+	})
+}
+
+func now(deps *Deps) time.Time {
+	if deps.Now != nil {
+		return deps.Now()
+	}
+	return time.Now()
+}
+
+func moneyCell(centavos int64, currency string) types.TableCell {
+	return types.MoneyCell(float64(centavos), currency, true)
+}
+
+func buildListTable(ctx context.Context, deps *Deps, statements []*shared.Statement, p params) *types.TableConfig {
+	perms := view.GetUserPermissions(ctx)
+	l := deps.Labels.Statement
+
+	columns := []types.TableColumn{
+		{Key: "client_name", Label: l.Client, WidthClass: "col-9xl"},
+		{Key: "currency", Label: l.Currency, WidthClass: "col-2xl"},
+		{Key: "opening", Label: l.Opening, WidthClass: "col-3xl", Align: "right"},
+		{Key: "charges", Label: l.Charges, WidthClass: "col-3xl", Align: "right"},
+		{Key: "credits", Label: l.Credits, WidthClass: "col-3xl", Align: "right"},
+		{Key: "closing", Label: l.Closing, WidthClass: "col-3xl", Align: "right"},
+	}
+
+	rows := []types.TableRow{}
+	for _, st := range statements {
+		query := clientQuery(p, st.Currency)
+		href := route.ResolveURL(deps.Routes.StatementURL, "id", url.PathEscape(st.ClientID)) + query
+		actions := []types.TableAction{
+			{Type: "view", Label: l.View, Action: "view", Href: href},
+		}
+		if deps.Render != nil {
+			actions = append(actions, types.TableAction{
+				Type: "download", Label: l.DownloadPdf, Action: "download",
+				URL:      route.ResolveURL(deps.Routes.StatementDownloadURL, "id", url.PathEscape(st.ClientID)) + query,
+				ItemName: st.ClientName, ConfirmTitle: l.DownloadPdf, ConfirmMessage: fmt.Sprintf(l.ClientTitle, st.ClientName),
+			})
+		}
+		rows = append(rows, types.TableRow{
+			ID:   st.ClientID + "-" + st.Currency,
+			Href: href,
+			Cells: []types.TableCell{
+				{Type: "text", Value: st.ClientName},
+				{Type: "text", Value: st.Currency},
+				moneyCell(st.Opening, st.Currency),
+				moneyCell(st.Charges, st.Currency),
+				moneyCell(st.Credits, st.Currency),
+				moneyCell(st.Closing, st.Currency),
+			},
+			DataAttrs: map[string]string{
+				"client":   st.ClientName,
+				"currency": st.Currency,
+				"closing":  strconv.FormatInt(st.Closing, 10),
+			},
+			Actions: actions,
+		})
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:                   "statements-table",
+		RefreshURL:           deps.Routes.StatementsTableURL + p.query(),
+		Columns:              columns,
+		Rows:                 rows,
+		ShowSearch:           true,
+		ShowActions:          true,
+		ShowSort:             true,
+		ShowColumns:          true,
+		ShowDensity:          true,
+		ShowEntries:          true,
+		DefaultSortColumn:    "client_name",
+		DefaultSortDirection: "asc",
+		Labels:               deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+	}
+	if canEmail(deps) {
+		tableConfig.PrimaryAction = &types.PrimaryAction{
+			Label:           l.EmailAll,
+			ActionURL:       deps.Routes.StatementsEmailURL + p.query(),
+			Icon:            "icon-mail",
+			Disabled:        !perms.Can("invoice", "update"),
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		}
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+func buildLedgerTable(deps *Deps, st *shared.Statement) *types.TableConfig {
+	l := deps.Labels.Statement
+
+	columns := []types.TableColumn{
+		{Key: "date", Label: l.Date, WidthClass: "col-3xl"},
+		{Key: "details", Label: l.Details},
+		{Key: "charges", Label: l.Charges, WidthClass: "col-3xl", Align: "right"},
+		{Key: "credits", Label: l.Credits, WidthClass: "col-3xl", Align: "right"},
+		{Key: "balance", Label: l.Balance, WidthClass: "col-3xl", Align: "right"},
+	}
+
+	rows := []types.TableRow{{
+		ID: "opening",
+		Cells: []types.TableCell{
+			types.DateTimeCell(st.From.Format(dateLayout), types.DateReadable),
+			{Type: "text", Value: l.BroughtForward},
+			{Type: "text"},
+			{Type: "text"},
+			moneyCell(st.Opening, st.Currency),
+		},
+	}}
+	for i, line := range st.Lines {
+		row := types.TableRow{
+			ID: strconv.Itoa(i),
+			Cells: []types.TableCell{
+				types.DateTimeCell(line.At.Format(dateLayout), types.DateReadable),
+				{Type: "text", Value: describe(l, line)},
+				amountCell(line.Charge, st.Currency),
+				amountCell(line.Credit, st.Currency),
+				moneyCell(line.Balance, st.Currency),
+			},
+			DataAttrs: map[string]string{
+				"kind": line.Kind,
+			},
+		}
+		if line.RevenueID != "" {
+			row.Href = route.ResolveURL(deps.Routes.DetailURL, "id", line.RevenueID)
+		}
+		rows = append(rows, row)
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:          "statement-table",
+		Columns:     columns,
+		Rows:        rows,
+		ShowSearch:  true,
+		ShowActions: false,
+		ShowDensity: true,
+		ShowEntries: true,
+		Labels:      deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+		TotalsRow: []types.TableCell{
+			{Type: "text"},
+			{Type: "text", Value: l.Closing},
+			moneyCell(st.Charges, st.Currency),
+			moneyCell(st.Credits, st.Currency),
+			moneyCell(st.Closing, st.Currency),
+		},
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
+
+// amountCell is a money cell left blank for zero, so each ledger line shows
+// only its charge or its credit.
+func amountCell(centavos int64, currency string) types.TableCell {
+	if centavos == 0 {
+		return types.TableCell{Type: "text"}
+	}
+	return moneyCell(centavos, currency)
+}
+
+// buildAgingTable is the one-row aging summary of the invoices open at the end
+// of the period, in the default buckets.
+func buildAgingTable(deps *Deps, st *shared.Statement) *types.TableConfig {
+	buckets := make([]int64, shared.DefaultAgingLimits.Len())
+	total := int64(0)
+	if st.Aging != nil {
+		buckets, total = st.Aging.Buckets, st.Aging.Total
+	}
+
+	var columns []types.TableColumn
+	var cells []types.TableCell
+	for i, label := range aging.BucketLabels(deps.Labels.Aging, shared.DefaultAgingLimits) {
+		columns = append(columns, types.TableColumn{Key: "bucket_" + strconv.Itoa(i), Label: label, Align: "right"})
+		cells = append(cells, moneyCell(buckets[i], st.Currency))
+	}
+	columns = append(columns, types.TableColumn{Key: "total", Label: deps.Labels.Aging.Total, Align: "right"})
+	cells = append(cells, moneyCell(total, st.Currency))
+	rows := []types.TableRow{{ID: "aging", Cells: cells}}
+	types.ApplyColumnStyles(columns, rows)
+
+	tableConfig := &types.TableConfig{
+		ID:      "statement-aging-table",
+		Caption: deps.Labels.Statement.AgingSummary,
+		Columns: columns,
+		Rows:    rows,
+		Labels:  deps.TableLabels,
+	}
+	types.ApplyTableSettings(tableConfig)
+	return tableConfig
+}
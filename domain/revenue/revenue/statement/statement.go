@@ -0,0 +1,363 @@
+// Package statement owns customer statements of account: the client list for
+// a period, one client's statement with its running balance and aging
+// summary, the PDF/DOCX download through the invoice document pipeline, and
+// emailing statements one at a time or to every client with a balance. Every
+// view takes ?from=&to=YYYY-MM-DD (default the month to date).
+package statement
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	pyeza "github.com/erniealice/pyeza-golang"
+	"github.com/erniealice/pyeza-golang/types"
+
+	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
+	collectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
+)
+
+// dateLayout is the from/to query format and the stored collection date
+// format.
+const dateLayout = "2006-01-02"
+
+// Deps holds dependencies for the statement views. ListCollections,
+// ListClients, Render and SendEmail are optional: without collections,
+// receipts on account are left out; without Render the download and email
+// actions are hidden; email also needs ListClients and SendEmail.
+type Deps struct {
+	Routes       revenuedomain.Routes
+	Labels       revenuedomain.Labels
+	CommonLabels pyeza.CommonLabels
+	TableLabels  types.TableLabels
+
+	// LoadReceivables reads the receivables ledger (aging.LoadReceivables).
+	LoadReceivables func(ctx context.Context) (*shared.Receivables, error)
+	ListCollections func(ctx context.Context, req *collectionpb.ListCollectionsRequest) (*collectionpb.ListCollectionsResponse, error)
+	ListClients     func(ctx context.Context, req *clientpb.ListClientsRequest) (*clientpb.ListClientsResponse, error)
+	// Allocations (optional) are the parts of receipts on account applied to
+	// invoices; those already show as payments, so only the unapplied rest
+	// of a receipt is listed.
+	Allocations shared.PaymentAllocationStore
+
+	// Render renders statement template data (see Data) as "pdf" or "docx"
+	// — action.RenderStatement.
+	Render    func(ctx context.Context, data map[string]any, format string) ([]byte, error)
+	SendEmail func(ctx context.Context, to []string, subject, htmlBody, textBody string, attachmentName string, attachmentData []byte) error
+
+	// Now returns the current time for the default period (nil = time.Now).
+	Now func() time.Time
+}
+
+// params is the statement period shared by every view.
+type params struct {
+	from, to time.Time
+}
+
+// query returns the ?from=&to= string that reproduces p.
+func (p params) query() string {
+	v := url.Values{"from": {p.from.Format(dateLayout)}, "to": {p.to.Format(dateLayout)}}
+	return "?" + v.Encode()
+}
+
+// period formats p for documents and emails, e.g. "2026-03-01 to 2026-03-31".
+func (p params) period() string {
+	return p.from.Format(dateLayout) + " to " + p.to.Format(dateLayout)
+}
+
+// parseParams reads from and to from r, defaulting to the first of this
+// month through today. On error it still returns the defaults with the
+// invalid-period label.
+func parseParams(r *http.Request, deps *Deps) (params, string) {
+	now := time.Now
+	if deps.Now != nil {
+		now = deps.Now
+	}
+	t := now()
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	p := params{from: today.AddDate(0, 0, 1-today.Day()), to: today}
+
+	invalid := deps.Labels.Statement.InvalidPeriod
+	q := r.URL.Query()
+	if s := q.Get("from"); s != "" {
+		from, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return p, invalid
+		}
+		p.from = from
+	}
+	if s := q.Get("to"); s != "" {
+		to, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return p, invalid
+		}
+		p.to = to
+	}
+	if p.to.Before(p.from) {
+		return params{from: today.AddDate(0, 0, 1-today.Day()), to: today}, invalid
+	}
+	return p, ""
+}
+
+// load builds every client's statement for p.
+func load(ctx context.Context, deps *Deps, p params) ([]*shared.Statement, error) {
+	r, err := deps.LoadReceivables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.Receipts, err = listReceipts(ctx, deps, p.to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return shared.BuildStatements(r, p.from, p.to, shared.DefaultAgingLimits), nil
+}
+
+// listReceipts returns the completed treasury collections taken from a client
+// without an invoice — advances and other cash on account. Collections for an
+// invoice are already counted through its payments, as are the parts of a
+// receipt allocated to invoices; only the rest unapplied at end (the day
+// after the period) is listed, so a statement for a past period does not
+// lose a receipt to an allocation made after it.
+func listReceipts(ctx context.Context, deps *Deps, end time.Time) ([]shared.AccountReceipt, error) {
+	if deps.ListCollections == nil {
+		return nil, nil
+	}
+	resp, err := deps.ListCollections(ctx, &collectionpb.ListCollectionsRequest{})
+	if err != nil {
+		log.Printf("Failed to list collections for statements: %v", err)
+		return nil, fmt.Errorf("failed to load collections: %w", err)
+	}
+	applied := map[string]int64{}
+	if deps.Allocations != nil {
+		allocations, err := deps.Allocations.ListPaymentAllocations(ctx, "")
+		if err != nil {
+			log.Printf("Failed to list payment allocations for statements: %v", err)
+			return nil, fmt.Errorf("failed to load payment allocations: %w", err)
+		}
+		applied = shared.AppliedByCollectionAsOf(allocations, end)
+	}
+	var out []shared.AccountReceipt
+	for _, c := range resp.GetData() {
+		if c.GetStatus() != "completed" || c.GetRevenueId() != "" || c.GetClientId() == "" {
+			continue
+		}
+		unapplied := c.GetAmount() - applied[c.GetId()]
+		if unapplied <= 0 {
+			continue
+		}
+		at, err := time.Parse(dateLayout, c.GetPaymentDate())
+		if err != nil {
+			at = time.UnixMilli(c.GetDateCreated()).UTC()
+		}
+		reference := c.GetReferenceNumber()
+		if reference == "" {
+			reference = c.GetName()
+		}
+		out = append(out, shared.AccountReceipt{
+			ClientID:  c.GetClientId(),
+			Currency:  c.GetCurrency(),
+			Reference: reference,
+			Amount:    unapplied,
+			At:        at,
+		})
+	}
+	return out, nil
+}
+
+// amount formats centavos without a currency, e.g. "1,250.00"; zero is blank.
+func amount(centavos int64) string {
+	if centavos == 0 {
+		return ""
+	}
+	return types.FormatMoney(centavos, "")
+}
+
+// describe returns a statement line's details, e.g. "Payment OR-12 - INV-3".
+func describe(l revenuedomain.StatementLabels, line *shared.StatementLine) string {
+	format := map[string]string{
+		shared.StatementInvoice: l.KindInvoice,
+		shared.StatementDebit:   l.KindDebitNote,
+		shared.StatementPayment: l.KindPayment,
+		shared.StatementCredit:  l.KindCreditNote,
+		shared.StatementReceipt: l.KindReceipt,
+	}[line.Kind]
+	s := strings.TrimSpace(fmt.Sprintf(format, line.Reference))
+	if line.AppliesTo != "" && line.AppliesTo != line.Reference {
+		s = fmt.Sprintf(l.AppliesTo, s, line.AppliesTo)
+	}
+	return s
+}
+
+// Data returns st's template data: the keys of the embedded statement
+// template and of invoicepdf.StatementLayout. The first entry is the balance
+// brought forward; the aging rows use the default buckets.
+func Data(labels revenuedomain.Labels, st *shared.Statement, asOf time.Time) map[string]any {
+	l := labels.Statement
+	entries := []any{map[string]any{
+		"date":        st.From.Format(dateLayout),
+		"description": l.BroughtForward,
+		"charge":      "",
+		"credit":      "",
+		"balance":     types.FormatMoney(st.Opening, ""),
+	}}
+	for _, line := range st.Lines {
+		entries = append(entries, map[string]any{
+			"date":        line.At.Format(dateLayout),
+			"description": describe(l, line),
+			"charge":      amount(line.Charge),
+			"credit":      amount(line.Credit),
+			"balance":     types.FormatMoney(line.Balance, ""),
+		})
+	}
+
+	var agingRows []any
+	buckets := make([]int64, shared.DefaultAgingLimits.Len())
+	if st.Aging != nil {
+		buckets = st.Aging.Buckets
+	}
+	for i, label := range aging.BucketLabels(labels.Aging, shared.DefaultAgingLimits) {
+		agingRows = append(agingRows, map[string]any{"label": label, "amount": types.FormatMoney(buckets[i], "")})
+	}
+
+	p := params{from: st.From, to: st.To}
+	return map[string]any{
+		"statement": map[string]any{
+			"date":            asOf.Format(dateLayout),
+			"from":            st.From.Format(dateLayout),
+			"to":              st.To.Format(dateLayout),
+			"period":          p.period(),
+			"opening_balance": types.FormatMoney(st.Opening, ""),
+			"charges":         types.FormatMoney(st.Charges, ""),
+			"credits":         types.FormatMoney(st.Credits, ""),
+			"closing_balance": types.FormatMoney(st.Closing, ""),
+		},
+		"customer": map[string]any{
+			"name": st.ClientName,
+		},
+		"entries":  entries,
+		"aging":    agingRows,
+		"total":    types.FormatMoney(st.Closing, ""),
+		"currency": st.Currency,
+	}
+}
+
+// filename is the download/attachment name without extension, e.g.
+// "statement-acme-2026-03-31".
+func filename(st *shared.Statement) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, st.ClientName)
+	return fmt.Sprintf("statement-%s-%s-%s", strings.Trim(slug, "-"), st.Currency, st.To.Format(dateLayout))
+}
+
+// attachment renders st for an email: the PDF, or the DOCX when the PDF
+// cannot be produced.
+func attachment(ctx context.Context, deps *Deps, st *shared.Statement, asOf time.Time) (string, []byte, error) {
+	data := Data(deps.Labels, st, asOf)
+	pdf, err := deps.Render(ctx, data, "pdf")
+	if err == nil {
+		return filename(st) + ".pdf", pdf, nil
+	}
+	log.Printf("statement: PDF generation failed for %s, attaching DOCX: %v", st.ClientID, err)
+	docx, err := deps.Render(ctx, data, "docx")
+	if err != nil {
+		return "", nil, err
+	}
+	return filename(st) + ".docx", docx, nil
+}
+
+// clientEmails maps client IDs to their billing email address: the client's
+// email, or its user's.
+func clientEmails(ctx context.Context, deps *Deps) (map[string]string, error) {
+	resp, err := deps.ListClients(ctx, &clientpb.ListClientsRequest{})
+	if err != nil {
+		log.Printf("Failed to list clients for statements: %v", err)
+		return nil, fmt.Errorf("failed to load clients: %w", err)
+	}
+	emails := map[string]string{}
+	for _, c := range resp.GetData() {
+		email := strings.TrimSpace(c.GetEmail())
+		if email == "" {
+			email = strings.TrimSpace(c.GetUser().GetEmailAddress())
+		}
+		if email != "" {
+			emails[c.GetId()] = email
+		}
+	}
+	return emails, nil
+}
+
+// send emails st to address with the rendered statement attached.
+func send(ctx context.Context, deps *Deps, st *shared.Statement, address string, asOf time.Time) error {
+	name, data, err := attachment(ctx, deps, st, asOf)
+	if err != nil {
+		return err
+	}
+	l := deps.Labels.Statement
+	p := params{from: st.From, to: st.To}
+	subject := fmt.Sprintf(l.EmailSubject, p.period())
+	body := fmt.Sprintf(l.EmailBody, st.ClientName, p.period(), types.FormatMoney(st.Closing, st.Currency))
+	htmlBody := "<p>" + strings.ReplaceAll(html.EscapeString(body), "\n", "<br>") + "</p>"
+	return deps.SendEmail(ctx, []string{address}, subject, htmlBody, body, name, data)
+}
+
+// EmailResult counts the outcome of emailing statements.
+type EmailResult struct {
+	Sent      int
+	NoAddress int
+	Failed    int
+}
+
+// emailAll emails every statement with a balance due to its client. Clients
+// without an email address are counted and skipped; a failed send is logged
+// and does not stop the rest.
+func emailAll(ctx context.Context, deps *Deps, statements []*shared.Statement, asOf time.Time) (*EmailResult, error) {
+	emails, err := clientEmails(ctx, deps)
+	if err != nil {
+		return nil, err
+	}
+	res := &EmailResult{}
+	for _, st := range statements {
+		if st.Closing <= 0 {
+			continue
+		}
+		address := emails[st.ClientID]
+		if address == "" {
+			res.NoAddress++
+			continue
+		}
+		if err := send(ctx, deps, st, address, asOf); err != nil {
+			log.Printf("statement: failed to email %s (%s): %v", st.ClientID, st.Currency, err)
+			res.Failed++
+			continue
+		}
+		res.Sent++
+	}
+	return res, nil
+}
+
+// due counts the statements with a balance due.
+func due(statements []*shared.Statement) int {
+	n := 0
+	for _, st := range statements {
+		if st.Closing > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,277 @@
+package statement
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue"
+	"github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
+	userpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/user"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+	collectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(dateLayout, s)
+	return t
+}
+
+// strPtr returns a pointer to its argument for optional protobuf fields.
+func strPtr(s string) *string { return &s }
+
+func testLabels() revenuedomain.Labels {
+	var labels revenuedomain.Labels
+	labels.Statement = revenuedomain.StatementLabels{
+		BroughtForward: "Balance brought forward",
+		KindInvoice:    "Invoice %s",
+		KindPayment:    "Payment %s",
+		KindCreditNote: "Credit note %s",
+		KindReceipt:    "Receipt %s",
+		AppliesTo:      "%s - %s",
+		InvalidPeriod:  "invalid period",
+		EmailSubject:   "Statement of account %s",
+		EmailBody:      "Dear %s, your statement for %s shows %s due.",
+	}
+	return labels
+}
+
+func TestParseParams(t *testing.T) {
+	t.Parallel()
+
+	deps := &Deps{Labels: testLabels(), Now: func() time.Time { return day("2026-03-18") }}
+	tests := []struct {
+		query    string
+		from, to string
+		invalid  bool
+	}{
+		{"", "2026-03-01", "2026-03-18", false},
+		{"?from=2026-01-01&to=2026-01-31", "2026-01-01", "2026-01-31", false},
+		{"?from=2026-02-01&to=2026-01-31", "2026-03-01", "2026-03-18", true},
+		{"?from=yesterday", "2026-03-01", "2026-03-18", true},
+	}
+	for _, tt := range tests {
+		p, errMsg := parseParams(httptest.NewRequest("GET", "/sales/statements"+tt.query, nil), deps)
+		if got := p.from.Format(dateLayout) + " " + p.to.Format(dateLayout); got != tt.from+" "+tt.to {
+			t.Errorf("%q: period = %s, want %s %s", tt.query, got, tt.from, tt.to)
+		}
+		if (errMsg != "") != tt.invalid {
+			t.Errorf("%q: error = %q, want invalid %v", tt.query, errMsg, tt.invalid)
+		}
+	}
+}
+
+func TestListReceipts(t *testing.T) {
+	t.Parallel()
+
+	deps := &Deps{
+		ListCollections: func(context.Context, *collectionpb.ListCollectionsRequest) (*collectionpb.ListCollectionsResponse, error) {
+			return &collectionpb.ListCollectionsResponse{Data: []*collectionpb.Collection{
+				{ClientId: strPtr("acme"), Currency: "PHP", Amount: 10000, Status: "completed", PaymentDate: "2026-03-25", ReferenceNumber: "ADV-1"},
+				{ClientId: strPtr("acme"), Currency: "PHP", Amount: 20000, Status: "completed", RevenueId: "r1"},
+				{ClientId: strPtr("acme"), Currency: "PHP", Amount: 30000, Status: "pending"},
+				{Currency: "PHP", Amount: 40000, Status: "completed"},
+			}}, nil
+		},
+	}
+	receipts, err := listReceipts(context.Background(), deps, day("2026-04-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the completed collection on account counts; invoice collections
+	// are already in the payments.
+	if len(receipts) != 1 || receipts[0].Reference != "ADV-1" || !receipts[0].At.Equal(day("2026-03-25")) {
+		t.Fatalf("receipts = %+v", receipts)
+	}
+}
+
+func TestListReceiptsAllocated(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	allocations := shared.NewMemoryPaymentAllocationStore()
+	for _, a := range []*shared.PaymentAllocation{
+		{ID: "a1", CollectionID: "c1", RevenueID: "r1", Amount: 6000, AllocatedAt: day("2026-03-10")},
+		{ID: "a2", CollectionID: "c1", RevenueID: "r2", Amount: 1000, AllocatedAt: day("2026-03-10"), ReversedAt: day("2026-03-26")},
+		{ID: "a3", CollectionID: "c2", RevenueID: "r3", Amount: 5000, AllocatedAt: day("2026-03-15")},
+	} {
+		if err := allocations.SavePaymentAllocation(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deps := &Deps{
+		ListCollections: func(context.Context, *collectionpb.ListCollectionsRequest) (*collectionpb.ListCollectionsResponse, error) {
+			return &collectionpb.ListCollectionsResponse{Data: []*collectionpb.Collection{
+				{Id: "c1", ClientId: strPtr("acme"), Currency: "PHP", Amount: 10000, Status: "completed", ReferenceNumber: "ADV-1"},
+				{Id: "c2", ClientId: strPtr("acme"), Currency: "PHP", Amount: 5000, Status: "completed", ReferenceNumber: "ADV-2"},
+			}}, nil
+		},
+		Allocations: allocations,
+	}
+	receipts, err := listReceipts(ctx, deps, day("2026-04-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Allocated parts are already payments on their invoices: ADV-1 keeps
+	// its unapplied 4,000 (the reversed allocation returned to credit) and
+	// the fully applied ADV-2 drops out.
+	if len(receipts) != 1 || receipts[0].Reference != "ADV-1" || receipts[0].Amount != 4000 {
+		t.Fatalf("receipts = %+v", receipts)
+	}
+
+	// As of an earlier period end, the later allocation to r3 is not yet
+	// made and the reversed one is still applied.
+	receipts, err = listReceipts(ctx, deps, day("2026-03-12"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 2 || receipts[0].Amount != 3000 || receipts[1].Reference != "ADV-2" || receipts[1].Amount != 5000 {
+		t.Fatalf("receipts as of 2026-03-12 = %+v", receipts)
+	}
+}
+
+func testStatement() *shared.Statement {
+	statements := shared.BuildStatements(&shared.Receivables{
+		Invoices: []shared.ReceivableInvoice{
+			{RevenueID: "r1", Reference: "INV-1", ClientID: "acme", ClientName: "Acme", Currency: "PHP", Amount: 100000, InvoiceDate: day("2026-02-10"), DueDate: day("2026-03-12")},
+		},
+		Payments: []shared.ReceivableEntry{
+			{RevenueID: "r1", Reference: "OR-1", Amount: 40000, At: day("2026-03-05")},
+		},
+	}, day("2026-03-01"), day("2026-03-31"), shared.DefaultAgingLimits)
+	return statements[0]
+}
+
+func TestLoadCreditNote(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// INV-1 for 1,000.00, 400.00 paid and a 100.00 credit note in March.
+	cashExpected := func(n int64) *int64 { return &n }
+	notes := shared.NewMemoryRevenueNoteStore()
+	_ = notes.SaveRevenueNote(ctx, &shared.RevenueNote{RevenueID: "cn1", Kind: shared.RevenueNoteCredit, ReferenceNumber: "CN-0001",
+		OriginalRevenueID: "r1", Subtotal: -10000, IssuedAt: day("2026-03-10")})
+	ledger := &aging.Deps{
+		GetListPageData: func(context.Context, *revenuepb.GetRevenueListPageDataRequest) (*revenuepb.GetRevenueListPageDataResponse, error) {
+			return &revenuepb.GetRevenueListPageDataResponse{RevenueList: []*revenuepb.Revenue{
+				{Id: "r1", ClientId: "c1", Name: "Acme", Currency: "PHP", TotalAmount: 100000, CashAmountExpected: cashExpected(100000),
+					ReferenceNumber: strPtr("INV-1"), RevenueDate: strPtr("2026-02-01"), DueDate: strPtr("2026-03-03")},
+				{Id: "cn1", ClientId: "c1", Currency: "PHP", TotalAmount: -10000, CashAmountExpected: cashExpected(-10000),
+					ReferenceNumber: strPtr("CN-0001"), RevenueDate: strPtr("2026-03-10")},
+			}}, nil
+		},
+		ListRevenuePayments: func(context.Context, *revenuepaymentpb.ListRevenuePaymentsRequest) (*revenuepaymentpb.ListRevenuePaymentsResponse, error) {
+			return &revenuepaymentpb.ListRevenuePaymentsResponse{Data: []*revenuepaymentpb.RevenuePayment{
+				{RevenueId: "r1", Amount: 40000, ReferenceNumber: strPtr("OR-1"), PaymentDate: strPtr("2026-03-05")},
+			}}, nil
+		},
+		Notes: notes,
+	}
+	deps := &Deps{
+		Labels:          testLabels(),
+		LoadReceivables: func(ctx context.Context) (*shared.Receivables, error) { return aging.LoadReceivables(ctx, ledger) },
+	}
+
+	statements, err := load(ctx, deps, params{from: day("2026-03-01"), to: day("2026-03-31")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	st := statements[0]
+	if st.Opening != 100000 || st.Credits != 50000 || st.Closing != 50000 {
+		t.Errorf("opening/credits/closing = %d/%d/%d, want 100000/50000/50000", st.Opening, st.Credits, st.Closing)
+	}
+	var credit *shared.StatementLine
+	for _, line := range st.Lines {
+		if line.Kind == shared.StatementCredit {
+			credit = line
+		}
+	}
+	if credit == nil || credit.Credit != 10000 || credit.Reference != "CN-0001" || credit.Balance != 50000 {
+		t.Errorf("credit note line = %+v, want 100.00 leaving 500.00", credit)
+	}
+}
+
+func TestData(t *testing.T) {
+	t.Parallel()
+
+	data := Data(testLabels(), testStatement(), day("2026-04-01"))
+	entries := data["entries"].([]any)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	opening := entries[0].(map[string]any)
+	if opening["description"] != "Balance brought forward" || opening["balance"] != "1,000.00" {
+		t.Errorf("opening entry = %v", opening)
+	}
+	payment := entries[1].(map[string]any)
+	if payment["description"] != "Payment OR-1 - INV-1" || payment["charge"] != "" || payment["credit"] != "400.00" || payment["balance"] != "600.00" {
+		t.Errorf("payment entry = %v", payment)
+	}
+	if data["total"] != "600.00" || data["currency"] != "PHP" {
+		t.Errorf("total = %v %v", data["currency"], data["total"])
+	}
+	if n := len(data["aging"].([]any)); n != shared.DefaultAgingLimits.Len() {
+		t.Errorf("got %d aging rows, want %d", n, shared.DefaultAgingLimits.Len())
+	}
+}
+
+func TestEmailAll(t *testing.T) {
+	t.Parallel()
+
+	st := testStatement()
+	bolt := &shared.Statement{ClientID: "bolt", ClientName: "Bolt", Currency: "PHP", From: st.From, To: st.To, Closing: 5000}
+	cora := &shared.Statement{ClientID: "cora", ClientName: "Cora", Currency: "PHP", From: st.From, To: st.To, Closing: 7000}
+	settled := &shared.Statement{ClientID: "dune", ClientName: "Dune", Currency: "PHP", From: st.From, To: st.To}
+
+	var sent []string
+	var names []string
+	deps := &Deps{
+		Labels: testLabels(),
+		ListClients: func(context.Context, *clientpb.ListClientsRequest) (*clientpb.ListClientsResponse, error) {
+			return &clientpb.ListClientsResponse{Data: []*clientpb.Client{
+				{Id: "acme", User: &userpb.User{EmailAddress: "ap@acme.test"}},
+				{Id: "bolt"},
+				{Id: "cora", Email: strPtr("billing@cora.test")},
+				{Id: "dune", Email: strPtr("ap@dune.test")},
+			}}, nil
+		},
+		// The PDF fails for everyone, so the DOCX is attached.
+		Render: func(_ context.Context, _ map[string]any, format string) ([]byte, error) {
+			if format == "pdf" {
+				return nil, errors.New("no converter")
+			}
+			return []byte("docx"), nil
+		},
+		SendEmail: func(_ context.Context, to []string, _, _, _ string, name string, _ []byte) error {
+			if to[0] == "billing@cora.test" {
+				return errors.New("mailbox full")
+			}
+			sent = append(sent, to[0])
+			names = append(names, name)
+			return nil
+		},
+	}
+
+	res, err := emailAll(context.Background(), deps, []*shared.Statement{st, bolt, cora, settled}, day("2026-04-01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 1 || res.NoAddress != 1 || res.Failed != 1 {
+		t.Errorf("result = %+v", res)
+	}
+	if len(sent) != 1 || sent[0] != "ap@acme.test" || names[0] != "statement-acme-PHP-2026-03-31.docx" {
+		t.Errorf("sent %v with %v", sent, names)
+	}
+	if n := due([]*shared.Statement{st, bolt, cora, settled}); n != 3 {
+		t.Errorf("due = %d, want 3", n)
+	}
+}
@@ -3,15 +3,25 @@ package revenue
 import (
 	"context"
 	"net/http"
+	"time"
 
 	epkg "github.com/erniealice/centymo-golang/domain/revenue/revenue"
 	revenueaction "github.com/erniealice/centymo-golang/domain/revenue/revenue/action"
+	revenueaging "github.com/erniealice/centymo-golang/domain/revenue/revenue/aging"
 	revenuedashboard "github.com/erniealice/centymo-golang/domain/revenue/revenue/dashboard"
 	revenuedetail "github.com/erniealice/centymo-golang/domain/revenue/revenue/detail"
+	revenuedunning "github.com/erniealice/centymo-golang/domain/revenue/revenue/dunning"
+	revenueemail "github.com/erniealice/centymo-golang/domain/revenue/revenue/email"
+	revenuefulfillment "github.com/erniealice/centymo-golang/domain/revenue/revenue/fulfillment"
 	revenuelist "github.com/erniealice/centymo-golang/domain/revenue/revenue/list"
+	revenuenote "github.com/erniealice/centymo-golang/domain/revenue/revenue/note"
 	revenuepayment "github.com/erniealice/centymo-golang/domain/revenue/revenue/payment"
 	revenuesearch "github.com/erniealice/centymo-golang/domain/revenue/revenue/search"
 	revenuesettings "github.com/erniealice/centymo-golang/domain/revenue/revenue/settings"
+	revenuestatement "github.com/erniealice/centymo-golang/domain/revenue/revenue/statement"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+	"github.com/erniealice/centymo-golang/services/invoicepdf"
+	"github.com/erniealice/centymo-golang/services/ubl"
 	attachmentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/attachment"
 	documenttemplatepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/template"
 	clientpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/entity/client"
@@ -27,10 +37,11 @@ import (
 	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
 	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
 	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
-	collectionmethodpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection_method"
 	priceplanpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/subscription/price_plan"
 	productpriceplanpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/subscription/product_price_plan"
 	subscriptionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/subscription/subscription"
+	collectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
+	collectionmethodpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection_method"
 	"github.com/erniealice/hybra-golang/views/attachment"
 	"github.com/erniealice/hybra-golang/views/auditlog"
 	pyeza "github.com/erniealice/pyeza-golang"
@@ -106,6 +117,8 @@ type RevenueModuleDeps struct {
 	ListInventoryItems           func(ctx context.Context, req *inventoryitempb.ListInventoryItemsRequest) (*inventoryitempb.ListInventoryItemsResponse, error)
 	UpdateInventorySerial        func(ctx context.Context, req *inventoryserialpb.UpdateInventorySerialRequest) (*inventoryserialpb.UpdateInventorySerialResponse, error)
 	CreateInventorySerialHistory func(ctx context.Context, req *serialhistorypb.CreateInventorySerialHistoryRequest) (*serialhistorypb.CreateInventorySerialHistoryResponse, error)
+	// Atomic stock delta. Optional — nil falls back to a serialized read+update.
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
 
 	// Document generation (wraps fycha.DocumentService.ProcessBytes)
 	GenerateDoc func(templateData []byte, data map[string]any) ([]byte, error)
@@ -113,6 +126,24 @@ type RevenueModuleDeps struct {
 	// Optional: load custom default template from storage
 	LoadDefaultTemplate func(ctx context.Context, purpose string) ([]byte, error)
 
+	// Optional: the workspace's invoice PDF engine (revenueaction.PDFEngine*;
+	// nil = LibreOffice with the native renderer as fallback) and its native
+	// PDF layout per template purpose (nil = invoicepdf.DefaultLayout).
+	InvoicePDFEngine     func(ctx context.Context) string
+	LoadInvoicePDFLayout func(ctx context.Context, purpose string) (*invoicepdf.Layout, error)
+
+	// Optional: the workspace as the seller party of UBL / PEPPOL BIS
+	// e-invoices. When set, the invoice download and send-email actions offer
+	// the "ubl" format and the detail page shows an e-invoice download.
+	LoadEInvoiceSeller func(ctx context.Context) (*ubl.Party, error)
+
+	// Optional: directory for bulk invoice export files ("" = the system temp
+	// directory). Bulk export is mounted whenever GenerateDoc is set; an
+	// export is only visible to the workspace (ExtractWorkspaceID) and user
+	// (ExtractUserID) that started it.
+	InvoiceExportDir   string
+	ExtractWorkspaceID func(ctx context.Context) string
+
 	// Document template CRUD operations
 	ListDocumentTemplates  func(ctx context.Context, req *documenttemplatepb.ListDocumentTemplatesRequest) (*documenttemplatepb.ListDocumentTemplatesResponse, error)
 	CreateDocumentTemplate func(ctx context.Context, req *documenttemplatepb.CreateDocumentTemplateRequest) (*documenttemplatepb.CreateDocumentTemplateResponse, error)
@@ -121,10 +152,22 @@ type RevenueModuleDeps struct {
 
 	// Storage operations for template file upload
 	UploadTemplate func(ctx context.Context, bucketName, objectKey string, content []byte, contentType string) error
+	// Optional: read uploaded templates back, for the template check drawer,
+	// the preview and refusing broken templates as default
+	DownloadTemplate func(ctx context.Context, bucket, key string) ([]byte, error)
 
 	// Email sending for invoice delivery
 	SendEmail func(ctx context.Context, to []string, subject, htmlBody, textBody string, attachmentName string, attachmentData []byte) error
 
+	// Emails stores the workspace's invoice email templates and the log of
+	// every send, and mounts the compose drawer and the detail emails tab.
+	// SendEmailMessage delivers with CC/BCC and returns the provider message
+	// ID (SendEmail is used when nil); ExtractUserID names the sender in the
+	// log. Optional — emails also need GenerateDoc and a sender.
+	Emails           shared.EmailStore
+	SendEmailMessage func(ctx context.Context, msg *shared.EmailMessage) (string, error)
+	ExtractUserID    func(ctx context.Context) string
+
 	// Attachment operations
 	UploadFile       func(ctx context.Context, bucket, key string, content []byte, contentType string) error
 	ListAttachments  func(ctx context.Context, moduleKey, foreignKey string) (*attachmentpb.ListAttachmentsResponse, error)
@@ -152,6 +195,70 @@ type RevenueModuleDeps struct {
 	// Tax lines for revenue drawer (Phase 5 — optional, gracefully degrades when nil)
 	ListRevenueTaxLines func(ctx context.Context, req *revenuetaxlinepb.ListRevenueTaxLinesRequest) (*revenuetaxlinepb.ListRevenueTaxLinesResponse, error)
 
+	// Storefront order fulfillment (optional — the queue, advance action and
+	// status endpoint are not mounted when ListFulfillmentQueue is nil).
+	// AdvanceFulfillment must fill in the acting user from ctx.
+	ListFulfillmentQueue shared.ListFulfillmentQueue
+	GetOrderFulfillment  shared.GetOrderFulfillment
+	AdvanceFulfillment   shared.AdvanceFulfillment
+
+	// Credit/debit notes (optional — the note drawer and list actions are not
+	// mounted unless RevenueNotes and DocumentNumbering are set; notes are
+	// numbered from the credit_note / debit_note sequences).
+	// CreateRevenueTaxLine writes the notes' prorated tax rows; when nil the
+	// note totals still include taxes but no rows are written.
+	// DeleteRevenueTaxLine removes them again when a note fails half-way.
+	// UpdateRevenueIfUnchanged serializes notes against one invoice across
+	// processes; without it they are only serialized within this one.
+	RevenueNotes             shared.RevenueNoteStore
+	CreateRevenueTaxLine     func(ctx context.Context, line *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error)
+	DeleteRevenueTaxLine     func(ctx context.Context, id string) error
+	UpdateRevenueIfUnchanged shared.UpdateRevenueIfUnchanged
+
+	// ConfigureStatusMachine customises the revenue status machine before the
+	// status actions are built — add workspace states ("sent",
+	// "partially_paid"), transitions, guards and hooks (email, accounting
+	// export). Optional; the default machine is used when nil.
+	ConfigureStatusMachine func(m *shared.RevenueStatusMachine)
+
+	// DocumentNumbering issues invoice and note numbers from the workspace's
+	// sequences and mounts the numbering settings pages. Optional — manual
+	// revenues keep the typed reference and notes are not mounted when nil.
+	DocumentNumbering *shared.DocumentNumbering
+
+	// GetRevenueDashboardPageData loads the dashboard aggregates for the
+	// request's period and location. Optional — the dashboard renders zero
+	// values when nil.
+	GetRevenueDashboardPageData func(ctx context.Context, req *revenuedashboard.Request) (*revenuedashboard.Response, error)
+
+	// GetFunctionalCurrency returns the workspace ISO 4217 currency code for
+	// dashboard money strings. Optional — amounts show without a currency
+	// prefix when nil.
+	GetFunctionalCurrency func(ctx context.Context) string
+
+	// Dunning stores the workspace's payment reminder policy, client opt-outs
+	// and reminder log. Optional — the reminder pages, the detail reminders
+	// tab and RunDunning are unavailable when nil; reminders also need
+	// SendEmail, GetListPageData and ListRevenuePayments.
+	Dunning shared.DunningStore
+
+	// ListCollections lists treasury collections; completed collections from
+	// a client without an invoice show on its statement as receipts on
+	// account. Optional — statements leave them out when nil.
+	ListCollections func(ctx context.Context, req *collectionpb.ListCollectionsRequest) (*collectionpb.ListCollectionsResponse, error)
+	// PaymentAllocations holds the parts of those collections applied to
+	// invoices, which statements leave out of the receipts; the payments
+	// they created can only be undone by reversing the allocation. Optional.
+	PaymentAllocations shared.PaymentAllocationStore
+
+	// FXRates stores the workspace's daily exchange rates and the rates
+	// snapshotted on issued revenues, and mounts the FX rate settings pages.
+	// LookupFXRate resolves a pair's rate on a day (shared.NewFXRateLookup
+	// over FXRates when nil). Optional — foreign-currency revenues keep no
+	// rate snapshot when FXRates or GetFunctionalCurrency is nil.
+	FXRates      shared.FXRateStore
+	LookupFXRate shared.FXRateLookup
+
 	// WithholdingCertAddURL is the URL pattern for the Add WHT Certificate CTA
 	// in the revenue taxes section. Substitutes {id} with the revenue ID.
 	WithholdingCertAddURL string
@@ -191,8 +298,67 @@ type RevenueModule struct {
 	SettingsUpload      view.View
 	SettingsDelete      view.View
 	SettingsSetDefault  view.View
+	SettingsCheck       view.View        // nil when DownloadTemplate is unwired
+	SettingsPreview     http.HandlerFunc // nil when DownloadTemplate or GenerateDoc is unwired
 	AttachmentUpload    view.View
 	AttachmentDelete    view.View
+	FulfillmentQueue    view.View
+	FulfillmentTable    view.View
+	FulfillmentAdvance  view.View
+	FulfillmentStatus   http.HandlerFunc
+	NoteAdd             view.View
+	BulkExport          view.View
+	ExportPage          view.View
+	ExportStatus        view.View
+	ExportDownload      http.HandlerFunc
+
+	// Document numbering settings (nil when DocumentNumbering is unwired)
+	SettingsNumbering         view.View
+	SettingsNumberingTable    view.View
+	SettingsNumberingAdd      view.View
+	SettingsNumberingEdit     view.View
+	SettingsNumberingDelete   view.View
+	SettingsNumberingRegister view.View
+	SettingsNumberingNumbers  view.View
+	SettingsNumberingVoid     view.View
+
+	// Accounts receivable aging (nil when GetListPageData or
+	// ListRevenuePayments is unwired)
+	Aging       view.View
+	AgingTable  view.View
+	AgingClient view.View
+	AgingExport http.HandlerFunc
+
+	// Payment reminders (nil when Dunning, SendEmail or the aging
+	// dependencies are unwired)
+	Dunning        view.View
+	DunningTable   view.View
+	DunningRun     view.View
+	DunningPolicy  view.View
+	DunningOptOut  view.View
+	dunningRunDeps *revenuedunning.Deps
+
+	// Invoice emails (nil when Emails, GenerateDoc or a sender is unwired)
+	EmailCompose   view.View
+	EmailTable     view.View
+	EmailCancel    view.View
+	EmailTemplates view.View
+	emailRunDeps   *revenueemail.Deps
+
+	// FX rate settings (nil when FXRates is unwired)
+	SettingsFXRates      view.View
+	SettingsFXRatesTable view.View
+	SettingsFXRateAdd    view.View
+	SettingsFXRateImport view.View
+	SettingsFXRateDelete view.View
+
+	// Customer statements (nil when the aging dependencies are unwired)
+	Statements        view.View
+	StatementsTable   view.View
+	StatementsEmail   view.View
+	Statement         view.View
+	StatementEmail    view.View
+	StatementDownload http.HandlerFunc
 
 	// RecomputeTaxes is a 501 stub until Phase 4 (ComputeTaxesForRevenue) wires the use case.
 	RecomputeTaxes http.HandlerFunc
@@ -225,22 +391,39 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 		ListInventoryItems:               deps.ListInventoryItems,
 		UpdateInventorySerial:            deps.UpdateInventorySerial,
 		CreateInventorySerialHistory:     deps.CreateInventorySerialHistory,
+		AdjustInventoryQuantity:          deps.AdjustInventoryQuantity,
 		FindApplicablePriceList:          deps.FindApplicablePriceList,
 		ListPriceProducts:                deps.ListPriceProducts,
 		ReadJobActivity:                  deps.ReadJobActivity,
 		RecognizeRevenueFromSubscription: deps.RecognizeRevenueFromSubscription,
 		ListRevenueTaxLines:              deps.ListRevenueTaxLines,
 		WithholdingCertAddURL:            deps.WithholdingCertAddURL,
+		Numbering:                        deps.DocumentNumbering,
+	}
+	if deps.FXRates != nil && deps.GetFunctionalCurrency != nil {
+		lookup := deps.LookupFXRate
+		if lookup == nil {
+			lookup = shared.NewFXRateLookup(deps.FXRates)
+		}
+		actionDeps.SnapshotFXRate = func(ctx context.Context, id, currency string, on time.Time) error {
+			_, err := shared.SnapshotFXRate(ctx, deps.FXRates, lookup, shared.FXDocumentRevenue, id, currency, deps.GetFunctionalCurrency(ctx), on)
+			return err
+		}
+	}
+	actionDeps.StatusMachine = revenueaction.NewStatusMachine(actionDeps)
+	if deps.ConfigureStatusMachine != nil {
+		deps.ConfigureStatusMachine(actionDeps.StatusMachine)
 	}
 	paymentDeps := &revenuepayment.Deps{
 		Routes:                deps.Routes,
-		Labels:               deps.Labels,
+		Labels:                deps.Labels,
 		CreateRevenuePayment:  deps.CreateRevenuePayment,
 		ReadRevenuePayment:    deps.ReadRevenuePayment,
 		UpdateRevenuePayment:  deps.UpdateRevenuePayment,
 		DeleteRevenuePayment:  deps.DeleteRevenuePayment,
 		ReadCollectionMethod:  deps.ReadCollectionMethod,
 		ListCollectionMethods: deps.ListCollectionMethods,
+		Allocations:           deps.PaymentAllocations,
 	}
 	searchDeps := &revenuesearch.Deps{
 		ListLocations:       deps.ListLocations,
@@ -267,6 +450,8 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 		AuditOps: auditlog.AuditOps{
 			ListAuditHistory: deps.ListAuditHistory,
 		},
+		Dunning:  deps.Dunning,
+		EInvoice: deps.GenerateDoc != nil && deps.LoadEInvoiceSeller != nil,
 	}
 	lineItemDeps := &revenuedetail.LineItemDeps{
 		Routes:                deps.Routes,
@@ -284,17 +469,74 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 		ListRevenueLineItems:  deps.ListRevenueLineItems,
 	}
 
-	// Invoice download handler (nil-guarded)
+	// Invoice download handler and bulk export (nil-guarded)
 	var invoiceDownload http.HandlerFunc
+	var bulkExport, exportPage, exportStatus view.View
+	var exportDownload http.HandlerFunc
+	var renderAttachment func(ctx context.Context, revenueID string) (string, []byte, error)
+	var renderStatement func(ctx context.Context, data map[string]any, format string) ([]byte, error)
+	var emailDeps *revenueemail.Deps
+	var renderTemplatePreview func(ctx context.Context, templateBytes []byte, revenueID, format string) (string, []byte, error)
 	if deps.GenerateDoc != nil {
-		invoiceDownload = revenueaction.NewInvoiceDownloadHandler(&revenueaction.InvoiceDownloadDeps{
+		downloadDeps := revenueaction.InvoiceDownloadDeps{
 			Routes:               deps.Routes,
 			Labels:               deps.Labels,
 			ReadRevenue:          deps.ReadRevenue,
 			ListRevenueLineItems: deps.ListRevenueLineItems,
 			GenerateDoc:          deps.GenerateDoc,
 			LoadDefaultTemplate:  deps.LoadDefaultTemplate,
-		})
+			Notes:                deps.RevenueNotes,
+			ListRevenueTaxLines:  deps.ListRevenueTaxLines,
+			PDFEngine:            deps.InvoicePDFEngine,
+			LoadPDFLayout:        deps.LoadInvoicePDFLayout,
+			LoadSeller:           deps.LoadEInvoiceSeller,
+		}
+		invoiceDownload = revenueaction.NewInvoiceDownloadHandler(&downloadDeps)
+		renderAttachment = func(ctx context.Context, revenueID string) (string, []byte, error) {
+			return revenueaction.RenderInvoiceAttachment(ctx, &downloadDeps, revenueID)
+		}
+		renderStatement = func(ctx context.Context, data map[string]any, format string) ([]byte, error) {
+			return revenueaction.RenderStatement(ctx, &downloadDeps, data, format)
+		}
+		renderTemplatePreview = func(ctx context.Context, templateBytes []byte, revenueID, format string) (string, []byte, error) {
+			return revenueaction.PreviewInvoiceTemplate(ctx, &downloadDeps, templateBytes, revenueID, format)
+		}
+		if deps.Emails != nil {
+			emailDeps = &revenueemail.Deps{
+				Routes:       deps.Routes,
+				Labels:       deps.Labels,
+				CommonLabels: deps.CommonLabels,
+				TableLabels:  deps.TableLabels,
+				Store:        deps.Emails,
+				ReadRevenue:  deps.ReadRevenue,
+				LoadData: func(ctx context.Context, revenueID string) (map[string]any, error) {
+					return revenueaction.InvoiceEmailData(ctx, &downloadDeps, revenueID)
+				},
+				RenderAttachment: func(ctx context.Context, revenueID, format string) (string, []byte, error) {
+					return revenueaction.RenderInvoiceAttachmentAs(ctx, &downloadDeps, revenueID, format)
+				},
+				EInvoice:    deps.LoadEInvoiceSeller != nil,
+				SendMessage: deps.SendEmailMessage,
+				SendEmail:   deps.SendEmail,
+				UserID:      deps.ExtractUserID,
+			}
+		}
+
+		listDeps := &revenuelist.ListViewDeps{Routes: deps.Routes, GetListPageData: deps.GetListPageData, Labels: deps.Labels}
+		exportDeps := &revenueaction.InvoiceExportDeps{
+			InvoiceDownloadDeps: downloadDeps,
+			CommonLabels:        deps.CommonLabels,
+			Jobs:                revenueaction.NewExportJobs(deps.InvoiceExportDir),
+			MatchingRevenueIDs: func(ctx context.Context, r *http.Request, limit int) ([]string, int, error) {
+				return revenuelist.MatchingRevenueIDs(ctx, listDeps, r, limit)
+			},
+			WorkspaceID: deps.ExtractWorkspaceID,
+			UserID:      deps.ExtractUserID,
+		}
+		bulkExport = revenueaction.NewBulkExportAction(exportDeps)
+		exportPage = revenueaction.NewExportView(exportDeps)
+		exportStatus = revenueaction.NewExportStatusView(exportDeps)
+		exportDownload = revenueaction.NewExportDownloadHandler(exportDeps)
 	}
 
 	// Send email handler (nil-guarded)
@@ -307,12 +549,31 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 			ListRevenueLineItems: deps.ListRevenueLineItems,
 			GenerateDoc:          deps.GenerateDoc,
 			LoadDefaultTemplate:  deps.LoadDefaultTemplate,
+			Notes:                deps.RevenueNotes,
+			ListRevenueTaxLines:  deps.ListRevenueTaxLines,
+			PDFEngine:            deps.InvoicePDFEngine,
+			LoadPDFLayout:        deps.LoadInvoicePDFLayout,
+			LoadSeller:           deps.LoadEInvoiceSeller,
 			SendEmail:            deps.SendEmail,
 		})
 	}
 
+	// Invoice emails (nil-guarded); once wired, quick sends are logged too.
+	var emailCompose, emailTable, emailCancel, emailTemplates view.View
+	if revenueemail.Enabled(emailDeps) {
+		detailDeps.Emails = emailDeps
+		sendEmailHandler = revenueemail.NewSendHandler(emailDeps)
+		emailCompose = revenueemail.NewComposeAction(emailDeps)
+		emailTable = revenueemail.NewTableView(emailDeps)
+		emailCancel = revenueemail.NewCancelAction(emailDeps)
+		emailTemplates = revenueemail.NewTemplatesAction(emailDeps)
+	} else {
+		emailDeps = nil
+	}
+
 	// Settings views (nil-guarded)
-	var settingsTemplates, settingsUpload, settingsDelete, settingsSetDefault view.View
+	var settingsTemplates, settingsUpload, settingsDelete, settingsSetDefault, settingsCheck view.View
+	var settingsPreview http.HandlerFunc
 	if deps.ListDocumentTemplates != nil {
 		settingsDeps := &revenuesettings.SettingsViewDeps{
 			Routes:                 deps.Routes,
@@ -325,12 +586,186 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 			DeleteDocumentTemplate: deps.DeleteDocumentTemplate,
 			UploadTemplate:         deps.UploadTemplate,
 		}
+		if deps.DownloadTemplate != nil {
+			settingsDeps.DownloadTemplate = deps.DownloadTemplate
+			settingsDeps.SampleData = revenueaction.SampleInvoiceData
+			settingsDeps.RenderPreview = renderTemplatePreview
+			settingsCheck = revenuesettings.NewCheckAction(settingsDeps)
+			if renderTemplatePreview != nil {
+				settingsPreview = revenuesettings.NewPreviewHandler(settingsDeps)
+			}
+		}
 		settingsTemplates = revenuesettings.NewView(settingsDeps)
 		settingsUpload = revenuesettings.NewUploadAction(settingsDeps)
 		settingsDelete = revenuesettings.NewDeleteAction(settingsDeps)
 		settingsSetDefault = revenuesettings.NewSetDefaultAction(settingsDeps)
 	}
 
+	// Document numbering settings (nil-guarded)
+	var numberingPage, numberingTable, numberingAdd, numberingEdit, numberingDelete view.View
+	var numberingRegister, numberingNumbers, numberingVoid view.View
+	if deps.DocumentNumbering != nil {
+		numberingDeps := &revenuesettings.NumberingViewDeps{
+			Routes:        deps.Routes,
+			Labels:        deps.Labels,
+			CommonLabels:  deps.CommonLabels,
+			TableLabels:   deps.TableLabels,
+			Numbering:     deps.DocumentNumbering,
+			ListLocations: deps.ListLocations,
+		}
+		numberingPage = revenuesettings.NewNumberingView(numberingDeps)
+		numberingTable = revenuesettings.NewNumberingTableView(numberingDeps)
+		numberingAdd = revenuesettings.NewNumberingAddAction(numberingDeps)
+		numberingEdit = revenuesettings.NewNumberingEditAction(numberingDeps)
+		numberingDelete = revenuesettings.NewNumberingDeleteAction(numberingDeps)
+		numberingRegister = revenuesettings.NewNumberingRegisterView(numberingDeps)
+		numberingNumbers = revenuesettings.NewNumberingNumbersView(numberingDeps)
+		numberingVoid = revenuesettings.NewNumberingVoidAction(numberingDeps)
+	}
+
+	// FX rate settings (nil-guarded)
+	var fxRates, fxRatesTable, fxRateAdd, fxRateImport, fxRateDelete view.View
+	if deps.FXRates != nil {
+		fxDeps := &revenuesettings.FXRateViewDeps{
+			Routes:                deps.Routes,
+			Labels:                deps.Labels,
+			CommonLabels:          deps.CommonLabels,
+			TableLabels:           deps.TableLabels,
+			Rates:                 deps.FXRates,
+			GetFunctionalCurrency: deps.GetFunctionalCurrency,
+		}
+		fxRates = revenuesettings.NewFXRatesView(fxDeps)
+		fxRatesTable = revenuesettings.NewFXRatesTableView(fxDeps)
+		fxRateAdd = revenuesettings.NewFXRateAddAction(fxDeps)
+		fxRateImport = revenuesettings.NewFXRateImportAction(fxDeps)
+		fxRateDelete = revenuesettings.NewFXRateDeleteAction(fxDeps)
+	}
+
+	// AR aging views (nil-guarded)
+	var aging, agingTable, agingClient view.View
+	var agingExport http.HandlerFunc
+	var agingDeps *revenueaging.Deps
+	if deps.GetListPageData != nil && deps.ListRevenuePayments != nil {
+		agingDeps = &revenueaging.Deps{
+			Routes:              deps.Routes,
+			Labels:              deps.Labels,
+			CommonLabels:        deps.CommonLabels,
+			TableLabels:         deps.TableLabels,
+			GetListPageData:     deps.GetListPageData,
+			ListRevenuePayments: deps.ListRevenuePayments,
+			ListPaymentTerms:    deps.ListPaymentTerms,
+			Notes:               deps.RevenueNotes,
+		}
+		aging = revenueaging.NewView(agingDeps)
+		agingTable = revenueaging.NewTableView(agingDeps)
+		agingClient = revenueaging.NewClientView(agingDeps)
+		agingExport = revenueaging.NewExportHandler(agingDeps)
+	}
+
+	// Payment reminders (nil-guarded); they age the same open invoices as
+	// the aging report.
+	var dunning, dunningTable, dunningRun, dunningPolicy, dunningOptOut view.View
+	var dunningDeps *revenuedunning.Deps
+	if deps.Dunning != nil && deps.SendEmail != nil && agingDeps != nil {
+		dunningDeps = &revenuedunning.Deps{
+			Routes:       deps.Routes,
+			Labels:       deps.Labels,
+			CommonLabels: deps.CommonLabels,
+			TableLabels:  deps.TableLabels,
+			Store:        deps.Dunning,
+			LoadReport: func(ctx context.Context, asOf time.Time) (*shared.AgingReport, error) {
+				return revenueaging.LoadReport(ctx, agingDeps, asOf, shared.DefaultAgingLimits)
+			},
+			ReadRevenue:      deps.ReadRevenue,
+			RenderAttachment: renderAttachment,
+			SendEmail:        deps.SendEmail,
+		}
+		dunning = revenuedunning.NewView(dunningDeps)
+		dunningTable = revenuedunning.NewTableView(dunningDeps)
+		dunningRun = revenuedunning.NewRunAction(dunningDeps)
+		dunningPolicy = revenuedunning.NewPolicyAction(dunningDeps)
+		dunningOptOut = revenuedunning.NewOptOutAction(dunningDeps)
+	}
+
+	// Customer statements (nil-guarded); they share the aging report's
+	// receivables ledger and the invoice document pipeline.
+	var statements, statementsTable, statementsEmail, statement, statementEmail view.View
+	var statementDownload http.HandlerFunc
+	if agingDeps != nil {
+		statementDeps := &revenuestatement.Deps{
+			Routes:       deps.Routes,
+			Labels:       deps.Labels,
+			CommonLabels: deps.CommonLabels,
+			TableLabels:  deps.TableLabels,
+			LoadReceivables: func(ctx context.Context) (*shared.Receivables, error) {
+				return revenueaging.LoadReceivables(ctx, agingDeps)
+			},
+			ListCollections: deps.ListCollections,
+			ListClients:     deps.ListClients,
+			Allocations:     deps.PaymentAllocations,
+			Render:          renderStatement,
+			SendEmail:       deps.SendEmail,
+		}
+		statements = revenuestatement.NewView(statementDeps)
+		statementsTable = revenuestatement.NewTableView(statementDeps)
+		statementsEmail = revenuestatement.NewEmailAllAction(statementDeps)
+		statement = revenuestatement.NewClientView(statementDeps)
+		statementEmail = revenuestatement.NewEmailAction(statementDeps)
+		if renderStatement != nil {
+			statementDownload = revenuestatement.NewDownloadHandler(statementDeps)
+		}
+	}
+
+	// Fulfillment views (nil-guarded)
+	var fulfillmentQueue, fulfillmentTable, fulfillmentAdvance view.View
+	var fulfillmentStatus http.HandlerFunc
+	if deps.ListFulfillmentQueue != nil {
+		fulfillmentDeps := &revenuefulfillment.Deps{
+			Routes:               deps.Routes,
+			Labels:               deps.Labels,
+			CommonLabels:         deps.CommonLabels,
+			TableLabels:          deps.TableLabels,
+			ListFulfillmentQueue: deps.ListFulfillmentQueue,
+			GetOrderFulfillment:  deps.GetOrderFulfillment,
+			AdvanceFulfillment:   deps.AdvanceFulfillment,
+		}
+		fulfillmentQueue = revenuefulfillment.NewView(fulfillmentDeps)
+		fulfillmentTable = revenuefulfillment.NewTableView(fulfillmentDeps)
+		if deps.AdvanceFulfillment != nil {
+			fulfillmentAdvance = revenuefulfillment.NewAdvanceAction(fulfillmentDeps)
+		}
+		if deps.GetOrderFulfillment != nil {
+			fulfillmentStatus = revenuefulfillment.NewStatusHandler(fulfillmentDeps)
+		}
+	}
+
+	// Credit/debit note drawer (nil-guarded)
+	var noteAdd view.View
+	if deps.RevenueNotes != nil && deps.DocumentNumbering != nil {
+		noteAdd = revenuenote.NewAddAction(&revenuenote.Deps{
+			Routes:                       deps.Routes,
+			Labels:                       deps.Labels,
+			ReadRevenue:                  deps.ReadRevenue,
+			CreateRevenue:                deps.CreateRevenue,
+			UpdateRevenue:                deps.UpdateRevenue,
+			ListRevenueLineItems:         deps.ListRevenueLineItems,
+			CreateRevenueLineItem:        deps.CreateRevenueLineItem,
+			DeleteRevenue:                deps.DeleteRevenue,
+			DeleteRevenueLineItem:        deps.DeleteRevenueLineItem,
+			ListRevenueTaxLines:          deps.ListRevenueTaxLines,
+			CreateRevenueTaxLine:         deps.CreateRevenueTaxLine,
+			DeleteRevenueTaxLine:         deps.DeleteRevenueTaxLine,
+			UpdateRevenueIfUnchanged:     deps.UpdateRevenueIfUnchanged,
+			AdjustInventoryQuantity:      deps.AdjustInventoryQuantity,
+			ReadInventoryItem:            deps.ReadInventoryItem,
+			UpdateInventoryItem:          deps.UpdateInventoryItem,
+			UpdateInventorySerial:        deps.UpdateInventorySerial,
+			CreateInventorySerialHistory: deps.CreateInventorySerialHistory,
+			Notes:                        deps.RevenueNotes,
+			Numbering:                    deps.DocumentNumbering,
+		})
+	}
+
 	// RecomputeTaxes stub — returns 501 until Phase 4 wires ComputeTaxesForRevenue.
 	recomputeUnavailableMsg := deps.Labels.Errors.RecomputeUnavailable
 	if recomputeUnavailableMsg == "" {
@@ -341,16 +776,27 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 		http.Error(w, recomputeUnavailableMsg, http.StatusNotImplemented)
 	})
 
+	dashboardView := revenuedashboard.NewView(&revenuedashboard.Deps{
+		Labels:                deps.Labels,
+		Routes:                deps.Routes,
+		CommonLabels:          deps.CommonLabels,
+		GetPageData:           deps.GetRevenueDashboardPageData,
+		GetFunctionalCurrency: deps.GetFunctionalCurrency,
+		ListLocations:         deps.ListLocations,
+	})
+
 	return &RevenueModule{
 		routes:    deps.Routes,
-		Dashboard: revenuedashboard.NewView(&revenuedashboard.Deps{Labels: deps.Labels, Routes: deps.Routes, CommonLabels: deps.CommonLabels}),
+		Dashboard: dashboardView,
 		List: revenuelist.NewView(&revenuelist.ListViewDeps{
 			Routes: deps.Routes, GetListPageData: deps.GetListPageData,
 			Labels: deps.Labels, CommonLabels: deps.CommonLabels, TableLabels: deps.TableLabels,
+			NotesEnabled: noteAdd != nil, ExportEnabled: bulkExport != nil,
 		}),
 		Table: revenuelist.NewTableView(&revenuelist.ListViewDeps{
 			Routes: deps.Routes, GetListPageData: deps.GetListPageData,
 			Labels: deps.Labels, CommonLabels: deps.CommonLabels, TableLabels: deps.TableLabels,
+			NotesEnabled: noteAdd != nil, ExportEnabled: bulkExport != nil,
 		}),
 		Detail:              revenuedetail.NewView(detailDeps),
 		TabAction:           revenuedetail.NewTabAction(detailDeps),
@@ -380,10 +826,81 @@ func NewRevenueModule(deps *RevenueModuleDeps) *RevenueModule {
 		SettingsUpload:      settingsUpload,
 		SettingsDelete:      settingsDelete,
 		SettingsSetDefault:  settingsSetDefault,
+		SettingsCheck:       settingsCheck,
+		SettingsPreview:     settingsPreview,
 		AttachmentUpload:    revenuedetail.NewAttachmentUploadAction(detailDeps),
 		AttachmentDelete:    revenuedetail.NewAttachmentDeleteAction(detailDeps),
+		FulfillmentQueue:    fulfillmentQueue,
+		FulfillmentTable:    fulfillmentTable,
+		FulfillmentAdvance:  fulfillmentAdvance,
+		FulfillmentStatus:   fulfillmentStatus,
+		NoteAdd:             noteAdd,
+		BulkExport:          bulkExport,
+		ExportPage:          exportPage,
+		ExportStatus:        exportStatus,
+		ExportDownload:      exportDownload,
 		RecomputeTaxes:      recomputeTaxesStub,
+
+		SettingsNumbering:         numberingPage,
+		SettingsNumberingTable:    numberingTable,
+		SettingsNumberingAdd:      numberingAdd,
+		SettingsNumberingEdit:     numberingEdit,
+		SettingsNumberingDelete:   numberingDelete,
+		SettingsNumberingRegister: numberingRegister,
+		SettingsNumberingNumbers:  numberingNumbers,
+		SettingsNumberingVoid:     numberingVoid,
+
+		SettingsFXRates:      fxRates,
+		SettingsFXRatesTable: fxRatesTable,
+		SettingsFXRateAdd:    fxRateAdd,
+		SettingsFXRateImport: fxRateImport,
+		SettingsFXRateDelete: fxRateDelete,
+
+		Aging:       aging,
+		AgingTable:  agingTable,
+		AgingClient: agingClient,
+		AgingExport: agingExport,
+
+		Dunning:        dunning,
+		DunningTable:   dunningTable,
+		DunningRun:     dunningRun,
+		DunningPolicy:  dunningPolicy,
+		DunningOptOut:  dunningOptOut,
+		dunningRunDeps: dunningDeps,
+
+		EmailCompose:   emailCompose,
+		EmailTable:     emailTable,
+		EmailCancel:    emailCancel,
+		EmailTemplates: emailTemplates,
+		emailRunDeps:   emailDeps,
+
+		Statements:        statements,
+		StatementsTable:   statementsTable,
+		StatementsEmail:   statementsEmail,
+		Statement:         statement,
+		StatementEmail:    statementEmail,
+		StatementDownload: statementDownload,
+	}
+}
+
+// RunDunning sends the payment reminders due today for the workspace in ctx
+// (see dunning.Run). Consumer apps call it from their daily scheduler; it
+// returns nil when reminders are unwired.
+func (m *RevenueModule) RunDunning(ctx context.Context) (*revenuedunning.Result, error) {
+	if m.dunningRunDeps == nil {
+		return nil, nil
+	}
+	return revenuedunning.Run(ctx, m.dunningRunDeps, false)
+}
+
+// RunScheduledEmails sends the invoice emails whose scheduled time has come
+// for the workspace in ctx (see email.RunScheduled). Consumer apps call it
+// from their scheduler; it returns nil when emails are unwired.
+func (m *RevenueModule) RunScheduledEmails(ctx context.Context) (*revenueemail.RunResult, error) {
+	if m.emailRunDeps == nil {
+		return nil, nil
 	}
+	return revenueemail.RunScheduled(ctx, m.emailRunDeps)
 }
 
 func (m *RevenueModule) RegisterRoutes(r view.RouteRegistrar) {
@@ -424,6 +941,36 @@ func (m *RevenueModule) RegisterRoutes(r view.RouteRegistrar) {
 		r.POST(m.routes.SettingsTemplateUploadURL, m.SettingsUpload)
 		r.POST(m.routes.SettingsTemplateDeleteURL, m.SettingsDelete)
 		r.POST(m.routes.SettingsTemplateDefaultURL, m.SettingsSetDefault)
+		if m.SettingsCheck != nil {
+			r.GET(m.routes.SettingsTemplateCheckURL, m.SettingsCheck)
+		}
+	}
+	// Settings (document numbering)
+	if m.SettingsNumbering != nil {
+		r.GET(m.routes.SettingsNumberingURL, m.SettingsNumbering)
+		r.GET(m.routes.SettingsNumberingTableURL, m.SettingsNumberingTable)
+		r.POST(m.routes.SettingsNumberingTableURL, m.SettingsNumberingTable)
+		r.GET(m.routes.SettingsNumberingAddURL, m.SettingsNumberingAdd)
+		r.POST(m.routes.SettingsNumberingAddURL, m.SettingsNumberingAdd)
+		r.GET(m.routes.SettingsNumberingEditURL, m.SettingsNumberingEdit)
+		r.POST(m.routes.SettingsNumberingEditURL, m.SettingsNumberingEdit)
+		r.POST(m.routes.SettingsNumberingDeleteURL, m.SettingsNumberingDelete)
+		r.GET(m.routes.SettingsNumberingRegisterURL, m.SettingsNumberingRegister)
+		r.GET(m.routes.SettingsNumberingNumbersURL, m.SettingsNumberingNumbers)
+		r.POST(m.routes.SettingsNumberingNumbersURL, m.SettingsNumberingNumbers)
+		r.GET(m.routes.SettingsNumberingVoidURL, m.SettingsNumberingVoid)
+		r.POST(m.routes.SettingsNumberingVoidURL, m.SettingsNumberingVoid)
+	}
+	// Settings (FX rates)
+	if m.SettingsFXRates != nil {
+		r.GET(m.routes.SettingsFXRatesURL, m.SettingsFXRates)
+		r.GET(m.routes.SettingsFXRatesTableURL, m.SettingsFXRatesTable)
+		r.POST(m.routes.SettingsFXRatesTableURL, m.SettingsFXRatesTable)
+		r.GET(m.routes.SettingsFXRateAddURL, m.SettingsFXRateAdd)
+		r.POST(m.routes.SettingsFXRateAddURL, m.SettingsFXRateAdd)
+		r.GET(m.routes.SettingsFXRateImportURL, m.SettingsFXRateImport)
+		r.POST(m.routes.SettingsFXRateImportURL, m.SettingsFXRateImport)
+		r.POST(m.routes.SettingsFXRateDeleteURL, m.SettingsFXRateDelete)
 	}
 	// Attachments
 	if m.AttachmentUpload != nil {
@@ -431,6 +978,65 @@ func (m *RevenueModule) RegisterRoutes(r view.RouteRegistrar) {
 		r.POST(m.routes.AttachmentUploadURL, m.AttachmentUpload)
 		r.POST(m.routes.AttachmentDeleteURL, m.AttachmentDelete)
 	}
+	// Fulfillment queue
+	if m.FulfillmentQueue != nil {
+		r.GET(m.routes.FulfillmentQueueURL, m.FulfillmentQueue)
+		r.GET(m.routes.FulfillmentTableURL, m.FulfillmentTable)
+		r.POST(m.routes.FulfillmentTableURL, m.FulfillmentTable)
+	}
+	if m.FulfillmentAdvance != nil {
+		r.POST(m.routes.FulfillmentAdvanceURL, m.FulfillmentAdvance)
+	}
+	// Credit/debit notes
+	if m.NoteAdd != nil {
+		r.GET(m.routes.NoteAddURL, m.NoteAdd)
+		r.POST(m.routes.NoteAddURL, m.NoteAdd)
+	}
+	// Bulk invoice export
+	if m.BulkExport != nil {
+		r.POST(m.routes.BulkExportURL, m.BulkExport)
+		r.GET(m.routes.ExportURL, m.ExportPage)
+		r.GET(m.routes.ExportStatusURL, m.ExportStatus)
+	}
+	// Accounts receivable aging
+	if m.Aging != nil {
+		r.GET(m.routes.AgingURL, m.Aging)
+		r.GET(m.routes.AgingTableURL, m.AgingTable)
+		r.POST(m.routes.AgingTableURL, m.AgingTable)
+		r.GET(m.routes.AgingClientURL, m.AgingClient)
+	}
+	// Payment reminders
+	if m.Dunning != nil {
+		r.GET(m.routes.DunningURL, m.Dunning)
+		r.GET(m.routes.DunningTableURL, m.DunningTable)
+		r.POST(m.routes.DunningTableURL, m.DunningTable)
+		r.GET(m.routes.DunningRunURL, m.DunningRun)
+		r.POST(m.routes.DunningRunURL, m.DunningRun)
+		r.GET(m.routes.DunningPolicyURL, m.DunningPolicy)
+		r.POST(m.routes.DunningPolicyURL, m.DunningPolicy)
+		r.POST(m.routes.DunningOptOutURL, m.DunningOptOut)
+	}
+	if m.EmailCompose != nil {
+		r.GET(m.routes.EmailComposeURL, m.EmailCompose)
+		r.POST(m.routes.EmailComposeURL, m.EmailCompose)
+		r.GET(m.routes.EmailTableURL, m.EmailTable)
+		r.POST(m.routes.EmailTableURL, m.EmailTable)
+		r.POST(m.routes.EmailCancelURL, m.EmailCancel)
+		r.GET(m.routes.EmailTemplatesURL, m.EmailTemplates)
+		r.POST(m.routes.EmailTemplatesURL, m.EmailTemplates)
+	}
+
+	// Customer statements
+	if m.Statements != nil {
+		r.GET(m.routes.StatementsURL, m.Statements)
+		r.GET(m.routes.StatementsTableURL, m.StatementsTable)
+		r.POST(m.routes.StatementsTableURL, m.StatementsTable)
+		r.GET(m.routes.StatementsEmailURL, m.StatementsEmail)
+		r.POST(m.routes.StatementsEmailURL, m.StatementsEmail)
+		r.GET(m.routes.StatementURL, m.Statement)
+		r.GET(m.routes.StatementEmailURL, m.StatementEmail)
+		r.POST(m.routes.StatementEmailURL, m.StatementEmail)
+	}
 	// Taxes recompute stub (501 until Phase 4 wires ComputeTaxesForRevenue)
-	// Note: InvoiceDownload + SendEmailHandler are http.HandlerFunc — register via routes.HandleFunc() in views.go
+	// Note: InvoiceDownload + SendEmailHandler + FulfillmentStatus + ExportDownload + AgingExport + StatementDownload + SettingsPreview are http.HandlerFunc — register via routes.HandleFunc() in views.go
 }
@@ -19,6 +19,7 @@ import (
 
 	revenuedomain "github.com/erniealice/centymo-golang/domain/revenue/revenue_run"
 	queueform "github.com/erniealice/centymo-golang/domain/revenue/revenue_run/queue/form"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	pyeza "github.com/erniealice/pyeza-golang"
 	"github.com/erniealice/pyeza-golang/route"
 	"github.com/erniealice/pyeza-golang/types"
@@ -73,6 +74,11 @@ type QueueViewDeps struct {
 	// ListRevenueRunCandidates returns pending billing periods for the given client
 	// and as-of-date. Called once per client row in a bounded fan-out goroutine.
 	ListRevenueRunCandidates func(ctx context.Context, clientID, asOfDate string) ([]RevenueRunCandidateInput, error)
+
+	// LoadFXTranslation loads the workspace's FX rates for translating a
+	// multi-currency client total into the functional currency. Optional —
+	// such totals add up the raw amounts when nil.
+	LoadFXTranslation func(ctx context.Context) (*shared.FXTranslation, error)
 }
 
 // NewView creates the full-page revenue-run queue view.
@@ -199,6 +205,15 @@ func buildTableConfig(
 	_ = eg.Wait()
 
 	// 3. Build QueueRow list, filtering out clients with zero pending periods.
+	// Multi-currency totals are translated at the as-of date's rates.
+	var fx *shared.FXTranslation
+	if deps.LoadFXTranslation != nil {
+		var err error
+		if fx, err = deps.LoadFXTranslation(ctx); err != nil {
+			log.Printf("revenue-run queue: failed to load FX rates: %v", err)
+		}
+	}
+	asOf, _ := time.Parse(types.DateInputLayout, asOfDate)
 	queueRows := make([]queueform.QueueRow, 0, len(clients))
 	for i, c := range clients {
 		res := results[i]
@@ -220,7 +235,7 @@ func buildTableConfig(
 		currency := ""
 		multiCurrency := false
 		if res.errMsg == "" {
-			totalAmount, currency, multiCurrency = flattenCurrencyTotals(res.summary.TotalByCurrency)
+			totalAmount, currency, multiCurrency = flattenCurrencyTotals(res.summary.TotalByCurrency, fx, asOf)
 		}
 
 		queueRows = append(queueRows, queueform.QueueRow{
@@ -409,10 +424,12 @@ func aggregateCandidates(candidates []RevenueRunCandidateInput) CandidateSummary
 }
 
 // flattenCurrencyTotals returns the primary currency + total, and whether there
-// are multiple currencies. When multi-currency, returns the first currency key
-// and the sum of all amounts as the "total" (which will be displayed alongside
-// a warning badge on the row).
-func flattenCurrencyTotals(totals map[string]int64) (total int64, currency string, multi bool) {
+// are multiple currencies. When multi-currency, the amounts are translated into
+// fx's functional currency at the rates of asOf and that currency is returned;
+// without fx, or when a currency has no rate, it returns the first currency key
+// and the sum of all amounts as the "total". Either way the row shows a
+// warning badge.
+func flattenCurrencyTotals(totals map[string]int64, fx *shared.FXTranslation, asOf time.Time) (total int64, currency string, multi bool) {
 	if len(totals) == 0 {
 		return 0, "", false
 	}
@@ -421,6 +438,17 @@ func flattenCurrencyTotals(totals map[string]int64) (total int64, currency strin
 			return amt, cur, false
 		}
 	}
+	if fx != nil && fx.Functional != "" {
+		translated, ok := int64(0), true
+		for cur, amt := range totals {
+			v, found := fx.Translate(cur, amt, asOf)
+			translated += v
+			ok = ok && found
+		}
+		if ok {
+			return translated, fx.Functional, true
+		}
+	}
 	// Multi-currency: sum everything, pick first key (alphabetically stable via map).
 	// In practice, this is rare and the UI will show a warning badge.
 	for cur, amt := range totals {
@@ -12,6 +12,7 @@ import (
 	revenuerunqueue "github.com/erniealice/centymo-golang/domain/revenue/revenue_run/queue"
 	revenuerunqueueaction "github.com/erniealice/centymo-golang/domain/revenue/revenue_run/queue/action"
 	rrshared "github.com/erniealice/centymo-golang/domain/revenue/revenue_run/shared"
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 	attachmentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/attachment"
 	pyeza "github.com/erniealice/pyeza-golang"
 	"github.com/erniealice/pyeza-golang/types"
@@ -96,6 +97,10 @@ type RevenueRunModuleDeps struct {
 	// Called per-client in a bounded fan-out goroutine on the queue page.
 	ListRevenueRunCandidates func(ctx context.Context, clientID, asOfDate string) ([]QueueCandidateInput, error)
 
+	// LoadFXTranslation loads the workspace's FX rates so the queue shows a
+	// client's multi-currency total in the functional currency. Optional.
+	LoadFXTranslation func(ctx context.Context) (*shared.FXTranslation, error)
+
 	// GenerateRevenueRun executes the revenue run for a single client.
 	// Called by the batch-run POST handler.
 	GenerateRevenueRun func(ctx context.Context, in BatchRunInput) (*BatchRunOutput, error)
@@ -160,6 +165,7 @@ func NewRevenueRunModule(deps *RevenueRunModuleDeps) *RevenueRunModule {
 		ClientDrawerURLTemplate:  deps.ClientDrawerURLTemplate,
 		ListClients:              deps.ListClients,
 		ListRevenueRunCandidates: deps.ListRevenueRunCandidates,
+		LoadFXTranslation:        deps.LoadFXTranslation,
 	}
 	batchRunDeps := &revenuerunqueueaction.BatchRunDeps{
 		Routes:             deps.Routes,
@@ -0,0 +1,257 @@
+package shared
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAgingLimits are the upper day limits of the standard overdue
+// buckets: current, 1–30, 31–60, 61–90 and 90+.
+var DefaultAgingLimits = AgingBuckets{30, 60, 90}
+
+// ErrAgingBuckets is returned by ParseAgingBuckets for limits that are not
+// positive and strictly ascending.
+var ErrAgingBuckets = errors.New("aging buckets must be ascending positive day counts")
+
+// AgingBuckets holds the upper day limit of each overdue bucket. An invoice
+// not yet due is "current" (bucket 0); one 1..limits[0] days overdue is in
+// bucket 1, and so on; anything past the last limit is in the open-ended last
+// bucket. Limits {30, 60, 90} give five buckets.
+type AgingBuckets []int
+
+// ParseAgingBuckets reads comma-separated limits such as "30,60,90". An empty
+// string returns DefaultAgingLimits.
+func ParseAgingBuckets(s string) (AgingBuckets, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DefaultAgingLimits, nil
+	}
+	var limits AgingBuckets
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n <= 0 || (len(limits) > 0 && n <= limits[len(limits)-1]) {
+			return nil, ErrAgingBuckets
+		}
+		limits = append(limits, n)
+	}
+	return limits, nil
+}
+
+// Len is the number of buckets, current and open-ended included.
+func (b AgingBuckets) Len() int { return len(b) + 2 }
+
+// Index returns the bucket for an invoice daysOverdue days past due.
+func (b AgingBuckets) Index(daysOverdue int) int {
+	if daysOverdue <= 0 {
+		return 0
+	}
+	for i, limit := range b {
+		if daysOverdue <= limit {
+			return i + 1
+		}
+	}
+	return len(b) + 1
+}
+
+// Range returns the inclusive day range of bucket i; to is -1 for the
+// open-ended last bucket and both are 0 for current.
+func (b AgingBuckets) Range(i int) (from, to int) {
+	switch {
+	case i <= 0:
+		return 0, 0
+	case i > len(b):
+		return b[len(b)-1] + 1, -1
+	case i == 1:
+		return 1, b[0]
+	default:
+		return b[i-2] + 1, b[i-1]
+	}
+}
+
+// String formats the limits the way ParseAgingBuckets reads them.
+func (b AgingBuckets) String() string {
+	parts := make([]string, len(b))
+	for i, limit := range b {
+		parts[i] = strconv.Itoa(limit)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Payment term types that decide how a due date is derived, as stored on
+// esqyma's payment_term.type.
+const (
+	PaymentTermNet          = "net"
+	PaymentTermDueOnReceipt = "due_on_receipt"
+	PaymentTermCOD          = "cod"
+	PaymentTermProximate    = "proximate"
+)
+
+// DueDate derives an invoice's due date from its payment term the same way
+// espyna's CreateRevenue does: net terms add netDays, proximate terms fall on
+// proximateDay of the following month, and receipt/COD terms are due on the
+// invoice date. An unknown type with netDays set is treated as net.
+func DueDate(invoiceDate time.Time, termType string, netDays, proximateDay int) time.Time {
+	switch strings.ToLower(termType) {
+	case PaymentTermDueOnReceipt, PaymentTermCOD:
+		return invoiceDate
+	case PaymentTermProximate:
+		if proximateDay >= 1 && proximateDay <= 28 {
+			return time.Date(invoiceDate.Year(), invoiceDate.Month()+1, proximateDay, 0, 0, 0, 0, invoiceDate.Location())
+		}
+		return invoiceDate
+	}
+	return invoiceDate.AddDate(0, 0, netDays)
+}
+
+// ReceivableInvoice is an issued invoice as of its issue date. Amount is the
+// cash the customer was originally asked to pay, in centavos.
+type ReceivableInvoice struct {
+	RevenueID   string
+	Reference   string
+	ClientID    string
+	ClientName  string
+	Currency    string
+	InvoiceDate time.Time
+	DueDate     time.Time
+	Amount      int64
+}
+
+// ReceivableEntry is a dated change to an invoice's balance. For payments
+// Amount is the centavos received; for notes it is the note's signed
+// CashDelta (negative for credit notes). Reference is the payment or note's
+// own reference, shown on statements.
+type ReceivableEntry struct {
+	RevenueID string
+	Reference string
+	Amount    int64
+	At        time.Time
+}
+
+// AgingLine is one open invoice in an aging report.
+type AgingLine struct {
+	ReceivableInvoice
+	Paid        int64
+	Adjusted    int64 // signed note total; negative reduces the balance
+	Balance     int64
+	DaysOverdue int
+	Bucket      int
+}
+
+// AgingClient groups a client's open invoices in one currency.
+type AgingClient struct {
+	ClientID   string
+	ClientName string
+	Currency   string
+	Buckets    []int64
+	Total      int64
+	Lines      []*AgingLine
+}
+
+// AgingTotal sums every client in one currency.
+type AgingTotal struct {
+	Currency string
+	Buckets  []int64
+	Total    int64
+}
+
+// AgingReport is the receivables outstanding at AsOf, grouped by client.
+type AgingReport struct {
+	AsOf    time.Time
+	Limits  AgingBuckets
+	Clients []*AgingClient
+	Totals  []*AgingTotal
+}
+
+// Client returns the client's groups (one per currency), or nil.
+func (r *AgingReport) Client(clientID string) []*AgingClient {
+	var out []*AgingClient
+	for _, c := range r.Clients {
+		if c.ClientID == clientID {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// AgeReceivables builds the aging report at asOf. Only invoices issued on or
+// before asOf count, and only payments and notes dated on or before asOf are
+// applied, so a past report can be reproduced later. Invoices with nothing
+// left to collect are left out. Days overdue are whole calendar days between
+// the due date and asOf.
+func AgeReceivables(invoices []ReceivableInvoice, payments, notes []ReceivableEntry, asOf time.Time, limits AgingBuckets) *AgingReport {
+	asOfDay := truncateDay(asOf)
+	paid := sumEntries(payments, asOfDay)
+	adjusted := sumEntries(notes, asOfDay)
+
+	report := &AgingReport{AsOf: asOfDay, Limits: limits}
+	groups := map[string]*AgingClient{}
+	totals := map[string]*AgingTotal{}
+	for _, inv := range invoices {
+		if truncateDay(inv.InvoiceDate).After(asOfDay) {
+			continue
+		}
+		line := &AgingLine{
+			ReceivableInvoice: inv,
+			Paid:              paid[inv.RevenueID],
+			Adjusted:          adjusted[inv.RevenueID],
+		}
+		line.Balance = inv.Amount + line.Adjusted - line.Paid
+		if line.Balance <= 0 {
+			continue
+		}
+		line.DaysOverdue = int(asOfDay.Sub(truncateDay(inv.DueDate)).Hours() / 24)
+		line.Bucket = limits.Index(line.DaysOverdue)
+
+		key := inv.ClientID + "\x00" + inv.Currency
+		group, ok := groups[key]
+		if !ok {
+			group = &AgingClient{ClientID: inv.ClientID, ClientName: inv.ClientName, Currency: inv.Currency, Buckets: make([]int64, limits.Len())}
+			groups[key] = group
+			report.Clients = append(report.Clients, group)
+		}
+		group.Lines = append(group.Lines, line)
+		group.Buckets[line.Bucket] += line.Balance
+		group.Total += line.Balance
+
+		total, ok := totals[inv.Currency]
+		if !ok {
+			total = &AgingTotal{Currency: inv.Currency, Buckets: make([]int64, limits.Len())}
+			totals[inv.Currency] = total
+			report.Totals = append(report.Totals, total)
+		}
+		total.Buckets[line.Bucket] += line.Balance
+		total.Total += line.Balance
+	}
+
+	sort.SliceStable(report.Clients, func(i, j int) bool {
+		a, b := report.Clients[i], report.Clients[j]
+		if a.ClientName != b.ClientName {
+			return a.ClientName < b.ClientName
+		}
+		return a.Currency < b.Currency
+	})
+	for _, c := range report.Clients {
+		sort.SliceStable(c.Lines, func(i, j int) bool { return c.Lines[i].DueDate.Before(c.Lines[j].DueDate) })
+	}
+	sort.Slice(report.Totals, func(i, j int) bool { return report.Totals[i].Currency < report.Totals[j].Currency })
+	return report
+}
+
+// sumEntries totals entries per revenue, skipping those dated after asOfDay.
+func sumEntries(entries []ReceivableEntry, asOfDay time.Time) map[string]int64 {
+	sums := map[string]int64{}
+	for _, e := range entries {
+		if truncateDay(e.At).After(asOfDay) {
+			continue
+		}
+		sums[e.RevenueID] += e.Amount
+	}
+	return sums
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestAgingBuckets(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"", "30,60,90", nil},
+		{" 15, 45 ", "15,45", nil},
+		{"30,30", "", ErrAgingBuckets},
+		{"60,30", "", ErrAgingBuckets},
+		{"0,30", "", ErrAgingBuckets},
+		{"x", "", ErrAgingBuckets},
+	} {
+		got, err := ParseAgingBuckets(tt.in)
+		if !errors.Is(err, tt.err) || (err == nil && got.String() != tt.want) {
+			t.Errorf("ParseAgingBuckets(%q) = %v, %v; want %s, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+
+	b := DefaultAgingLimits
+	for days, want := range map[int]int{-5: 0, 0: 0, 1: 1, 30: 1, 31: 2, 60: 2, 61: 3, 90: 3, 91: 4, 400: 4} {
+		if got := b.Index(days); got != want {
+			t.Errorf("Index(%d) = %d, want %d", days, got, want)
+		}
+	}
+	if b.Len() != 5 {
+		t.Errorf("Len = %d, want 5", b.Len())
+	}
+	for i, want := range [][2]int{{0, 0}, {1, 30}, {31, 60}, {61, 90}, {91, -1}} {
+		if from, to := b.Range(i); from != want[0] || to != want[1] {
+			t.Errorf("Range(%d) = %d..%d, want %v", i, from, to, want)
+		}
+	}
+}
+
+func TestDueDate(t *testing.T) {
+	t.Parallel()
+
+	inv := day("2026-01-20")
+	for _, tt := range []struct {
+		termType          string
+		netDays, proxDays int
+		want              string
+	}{
+		{PaymentTermNet, 30, 0, "2026-02-19"},
+		{"NET", 15, 0, "2026-02-04"},
+		{PaymentTermDueOnReceipt, 30, 0, "2026-01-20"},
+		{PaymentTermCOD, 0, 0, "2026-01-20"},
+		{PaymentTermProximate, 0, 10, "2026-02-10"},
+		{PaymentTermProximate, 0, 31, "2026-01-20"},
+		{"", 45, 0, "2026-03-06"},
+	} {
+		if got := DueDate(inv, tt.termType, tt.netDays, tt.proxDays).Format("2006-01-02"); got != tt.want {
+			t.Errorf("DueDate(%s, %d, %d) = %s, want %s", tt.termType, tt.netDays, tt.proxDays, got, tt.want)
+		}
+	}
+}
+
+func TestAgeReceivables(t *testing.T) {
+	t.Parallel()
+
+	invoices := []ReceivableInvoice{
+		{RevenueID: "a1", Reference: "INV-1", ClientID: "acme", ClientName: "Acme", Currency: "PHP", InvoiceDate: day("2026-01-01"), DueDate: day("2026-01-31"), Amount: 100000},
+		{RevenueID: "a2", Reference: "INV-2", ClientID: "acme", ClientName: "Acme", Currency: "PHP", InvoiceDate: day("2026-03-01"), DueDate: day("2026-03-31"), Amount: 50000},
+		{RevenueID: "a3", Reference: "INV-3", ClientID: "acme", ClientName: "Acme", Currency: "USD", InvoiceDate: day("2025-10-01"), DueDate: day("2025-10-01"), Amount: 7000},
+		{RevenueID: "b1", Reference: "INV-4", ClientID: "bolt", ClientName: "Bolt", Currency: "PHP", InvoiceDate: day("2026-02-01"), DueDate: day("2026-02-15"), Amount: 20000},
+		{RevenueID: "b2", Reference: "INV-5", ClientID: "bolt", ClientName: "Bolt", Currency: "PHP", InvoiceDate: day("2026-04-01"), DueDate: day("2026-04-30"), Amount: 90000},
+	}
+	payments := []ReceivableEntry{
+		{RevenueID: "a1", Amount: 30000, At: day("2026-02-10")},
+		{RevenueID: "a1", Amount: 70000, At: day("2026-04-01")}, // after the as-of date
+		{RevenueID: "b1", Amount: 15000, At: day("2026-03-01")},
+	}
+	notes := []ReceivableEntry{
+		{RevenueID: "b1", Amount: -5000, At: day("2026-03-05")}, // credit note clears b1
+		{RevenueID: "a2", Amount: 2500, At: day("2026-03-10")},  // debit note
+	}
+
+	r := AgeReceivables(invoices, payments, notes, time.Date(2026, 3, 31, 18, 0, 0, 0, time.UTC), DefaultAgingLimits)
+
+	if len(r.Clients) != 2 {
+		t.Fatalf("clients = %d, want Acme PHP and Acme USD (Bolt is settled, INV-5 is not issued yet)", len(r.Clients))
+	}
+	acme := r.Clients[0]
+	if acme.ClientName != "Acme" || acme.Currency != "PHP" || len(acme.Lines) != 2 {
+		t.Fatalf("first group = %s %s with %d lines", acme.ClientName, acme.Currency, len(acme.Lines))
+	}
+	if l := acme.Lines[0]; l.Reference != "INV-1" || l.Paid != 30000 || l.Balance != 70000 || l.DaysOverdue != 59 || l.Bucket != 2 {
+		t.Errorf("INV-1 = %+v", l)
+	}
+	if l := acme.Lines[1]; l.Reference != "INV-2" || l.Adjusted != 2500 || l.Balance != 52500 || l.DaysOverdue != 0 || l.Bucket != 0 {
+		t.Errorf("INV-2 = %+v", l)
+	}
+	if acme.Total != 122500 || acme.Buckets[0] != 52500 || acme.Buckets[2] != 70000 {
+		t.Errorf("Acme PHP buckets = %v total %d", acme.Buckets, acme.Total)
+	}
+	if usd := r.Clients[1]; usd.Currency != "USD" || usd.Buckets[4] != 7000 {
+		t.Errorf("Acme USD = %+v", usd)
+	}
+	if len(r.Client("acme")) != 2 || r.Client("bolt") != nil {
+		t.Error("Client lookup")
+	}
+	if len(r.Totals) != 2 || r.Totals[0].Currency != "PHP" || r.Totals[0].Total != 122500 || r.Totals[1].Total != 7000 {
+		t.Errorf("totals = %+v %+v", r.Totals[0], r.Totals[1])
+	}
+
+	// Reproducing an earlier month end ignores later activity.
+	jan := AgeReceivables(invoices, payments, notes, day("2026-01-31"), DefaultAgingLimits)
+	if len(jan.Clients) != 2 || jan.Clients[0].Lines[0].Balance != 100000 || jan.Clients[0].Lines[0].Bucket != 0 {
+		t.Errorf("January report = %+v", jan.Clients[0].Lines[0])
+	}
+}
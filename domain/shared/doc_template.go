@@ -0,0 +1,231 @@
+package shared
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrDocTemplate is returned for a DOCX template that cannot be read or whose
+// sections do not nest.
+var ErrDocTemplate = errors.New("invalid document template")
+
+// DocTemplateReport lists a DOCX template's placeholders against the data it
+// is generated from. Paths are dotted; a field inside a section is prefixed
+// with the section, e.g. "items.description" inside {{#items}}.
+type DocTemplateReport struct {
+	// Placeholders are the paths the template uses.
+	Placeholders []string
+	// Missing are used paths the data does not provide; they render blank.
+	Missing []string
+	// Unused are paths the data provides that the template does not use.
+	Unused []string
+}
+
+// OK reports whether every placeholder the template uses is provided.
+func (r *DocTemplateReport) OK() bool {
+	return len(r.Missing) == 0
+}
+
+// docTemplatePart matches the DOCX parts placeholders are read from.
+var docTemplatePart = regexp.MustCompile(`^word/(document|header[0-9]*|footer[0-9]*)\.xml$`)
+
+var (
+	docXMLTag      = regexp.MustCompile(`<[^>]*>`)
+	docPlaceholder = regexp.MustCompile(`\{\{\s*([#^/]?)\s*([^{}]*?)\s*\}\}`)
+)
+
+// DocTemplateText returns the text of a DOCX template's body, headers and
+// footers, one line per paragraph. Word splits typed text into runs, so
+// placeholders are only whole in the text, not in the XML.
+func DocTemplateText(docx []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDocTemplate, err)
+	}
+	var parts []*zip.File
+	hasDocument := false
+	for _, f := range zr.File {
+		if docTemplatePart.MatchString(f.Name) {
+			parts = append(parts, f)
+			hasDocument = hasDocument || f.Name == "word/document.xml"
+		}
+	}
+	if !hasDocument {
+		return "", fmt.Errorf("%w: no word/document.xml", ErrDocTemplate)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Name < parts[j].Name })
+
+	var text strings.Builder
+	for _, f := range parts {
+		rc, err := f.Open()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrDocTemplate, f.Name, err)
+		}
+		raw, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrDocTemplate, f.Name, err)
+		}
+		xml := strings.ReplaceAll(string(raw), "</w:p>", "\n")
+		text.WriteString(html.UnescapeString(docXMLTag.ReplaceAllString(xml, "")))
+		text.WriteByte('\n')
+	}
+	return text.String(), nil
+}
+
+// CheckDocTemplate checks a DOCX template's {{name}}, {{#section}} and
+// {{/section}} placeholders against data, the map it is generated from.
+// Inside a section over a list, names resolve against the list's elements
+// first and then the outer data, as the generator does.
+func CheckDocTemplate(docx []byte, data map[string]any) (*DocTemplateReport, error) {
+	text, err := DocTemplateText(docx)
+	if err != nil {
+		return nil, err
+	}
+
+	type frame struct {
+		name   string // as written in the template
+		path   string
+		fields map[string]any
+	}
+	stack := []frame{{fields: data}}
+	used := map[string]bool{}
+	missing := map[string]bool{}
+
+	// resolve looks name up from the innermost section outwards.
+	resolve := func(name string) (string, any, bool) {
+		for i := len(stack) - 1; i >= 0; i-- {
+			if v, ok := lookupPath(stack[i].fields, name); ok {
+				return joinPath(stack[i].path, name), v, true
+			}
+		}
+		return joinPath(stack[len(stack)-1].path, name), nil, false
+	}
+
+	for _, m := range docPlaceholder.FindAllStringSubmatch(text, -1) {
+		kind, name := m[1], m[2]
+		if name == "" || name == "." {
+			continue
+		}
+		switch kind {
+		case "/":
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.name != name {
+				return nil, fmt.Errorf("%w: {{/%s}} without {{#%s}}", ErrDocTemplate, name, name)
+			}
+			stack = stack[:len(stack)-1]
+		case "#", "^":
+			path, v, ok := resolve(name)
+			used[path] = true
+			if !ok {
+				missing[path] = true
+			}
+			stack = append(stack, frame{name: name, path: path, fields: sectionFields(v)})
+		default:
+			path, _, ok := resolve(name)
+			used[path] = true
+			if !ok {
+				missing[path] = true
+			}
+		}
+	}
+	if len(stack) > 1 {
+		name := stack[len(stack)-1].name
+		return nil, fmt.Errorf("%w: {{#%s}} is not closed", ErrDocTemplate, name)
+	}
+
+	report := &DocTemplateReport{
+		Placeholders: sortedKeys(used),
+		Missing:      sortedKeys(missing),
+	}
+	for _, path := range DocTemplatePaths(data) {
+		if !used[path] {
+			report.Unused = append(report.Unused, path)
+		}
+	}
+	return report, nil
+}
+
+// DocTemplatePaths lists the placeholder paths data provides, sorted: its
+// values by dotted path, and each list with the fields of its elements.
+func DocTemplatePaths(data map[string]any) []string {
+	paths := map[string]bool{}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			path := joinPath(prefix, k)
+			switch v := v.(type) {
+			case map[string]any:
+				walk(path, v)
+			case []any:
+				paths[path] = true
+				walk(path, sectionFields(v))
+			default:
+				paths[path] = true
+			}
+		}
+	}
+	walk("", data)
+	return sortedKeys(paths)
+}
+
+// lookupPath finds a dotted name in m.
+func lookupPath(m map[string]any, name string) (any, bool) {
+	var v any = m
+	for _, key := range strings.Split(name, ".") {
+		mm, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = mm[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// sectionFields returns the names visible inside a section over v: a map's
+// own keys, or the keys of a list's map elements.
+func sectionFields(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		return v
+	case []any:
+		fields := map[string]any{}
+		for _, el := range v {
+			if m, ok := el.(map[string]any); ok {
+				for k, fv := range m {
+					fields[k] = fv
+				}
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
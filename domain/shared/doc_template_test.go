@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testDocx builds a DOCX whose body has one paragraph per entry of paras,
+// each split into runs at "|" the way Word splits typed text.
+func testDocx(t *testing.T, paras ...string) []byte {
+	t.Helper()
+	body := `<?xml version="1.0"?><w:document><w:body>`
+	for _, p := range paras {
+		body += "<w:p>"
+		for _, run := range bytes.Split([]byte(p), []byte("|")) {
+			body += "<w:r><w:t>" + string(run) + "</w:t></w:r>"
+		}
+		body += "</w:p>"
+	}
+	body += "</w:body></w:document>"
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"[Content_Types].xml": "<Types/>",
+		"word/document.xml":   body,
+		"word/footer1.xml":    "<w:ftr><w:p><w:r><w:t>{{invoice.reference_number}}</w:t></w:r></w:p></w:ftr>",
+		"word/styles.xml":     "<w:styles><w:t>{{ignored}}</w:t></w:styles>",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckDocTemplate(t *testing.T) {
+	t.Parallel()
+	data := map[string]any{
+		"invoice":  map[string]any{"reference_number": "INV-1", "date": "2026-03-01"},
+		"customer": map[string]any{"name": "Acme"},
+		"items":    []any{map[string]any{"description": "Widget", "total": "10.00"}},
+		"total":    "10.00",
+		"currency": "PHP",
+	}
+
+	docx := testDocx(t,
+		"Invoice {{|invoice.reference_number}|} for {{ customer.name }}",
+		"{{#items}}{{description}} {{total}} {{sku}}{{/items}}",
+		"Total {{currency}} {{total}} due {{invoice.due_date}}",
+	)
+	report, err := CheckDocTemplate(docx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUsed := []string{"currency", "customer.name", "invoice.due_date", "invoice.reference_number", "items", "items.description", "items.sku", "items.total", "total"}
+	if !reflect.DeepEqual(report.Placeholders, wantUsed) {
+		t.Errorf("placeholders = %v, want %v", report.Placeholders, wantUsed)
+	}
+	if want := []string{"invoice.due_date", "items.sku"}; !reflect.DeepEqual(report.Missing, want) || report.OK() {
+		t.Errorf("missing = %v, want %v", report.Missing, want)
+	}
+	if want := []string{"invoice.date"}; !reflect.DeepEqual(report.Unused, want) {
+		t.Errorf("unused = %v, want %v", report.Unused, want)
+	}
+
+	ok, err := CheckDocTemplate(testDocx(t, "{{#items}}{{description}}{{/items}} {{currency}}"), data)
+	if err != nil || !ok.OK() {
+		t.Errorf("valid template = %+v, %v", ok, err)
+	}
+
+	for name, docx := range map[string][]byte{
+		"unclosed":   testDocx(t, "{{#items}}{{description}}"),
+		"mismatched": testDocx(t, "{{#items}}{{/taxes}}"),
+		"stray":      testDocx(t, "{{/items}}"),
+		"not a docx": []byte("plain text"),
+	} {
+		if _, err := CheckDocTemplate(docx, data); !errors.Is(err, ErrDocTemplate) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document types a DocumentSequence can number. Notes reuse the revenue note
+// kinds so a note kind doubles as its sequence's document type.
+const (
+	DocumentInvoice         = "invoice"
+	DocumentOrder           = "order"
+	DocumentCreditNote      = RevenueNoteCredit
+	DocumentDebitNote       = RevenueNoteDebit
+	DocumentOfficialReceipt = "official_receipt"
+	DocumentPurchaseOrder   = "purchase_order"
+)
+
+// DocumentTypes lists the numbered document types in settings order.
+func DocumentTypes() []string {
+	return []string{
+		DocumentInvoice,
+		DocumentOrder,
+		DocumentCreditNote,
+		DocumentDebitNote,
+		DocumentOfficialReceipt,
+		DocumentPurchaseOrder,
+	}
+}
+
+// DocumentTypeMarker returns the literal prefix numbers of docType must start
+// with, or "" when any prefix will do. Storefront orders and notes are told
+// apart from invoices by their reference prefix (see RevenueNoteKindOf and the
+// checkout reservation sweep), so their sequences have to keep it.
+func DocumentTypeMarker(docType string) string {
+	switch docType {
+	case DocumentOrder:
+		return "ORD-"
+	case DocumentCreditNote, DocumentDebitNote:
+		return RevenueNotePrefix(docType) + "-"
+	}
+	return ""
+}
+
+// Sequence reset periods. A sequence that resets restarts at 1 on the first
+// number of each calendar year or month.
+const (
+	SequenceResetNever   = ""
+	SequenceResetYearly  = "yearly"
+	SequenceResetMonthly = "monthly"
+)
+
+// Document number states. Numbers are never handed out twice: a number whose
+// document was never written or was cancelled is voided, not reused.
+const (
+	DocumentNumberIssued = "issued"
+	DocumentNumberVoided = "voided"
+)
+
+// MaxSequencePadding bounds DocumentSequence.Padding.
+const MaxSequencePadding = 12
+
+// Document numbering errors, returned so views can map them to labels.
+var (
+	ErrNoDocumentSequence        = errors.New("no numbering sequence configured for this document type")
+	ErrDocumentSequenceType      = errors.New("unknown document type")
+	ErrDocumentSequenceMarker    = errors.New("prefix must start with the document type's marker")
+	ErrDocumentSequenceToken     = errors.New("unknown date token in prefix or suffix")
+	ErrDocumentSequencePadding   = errors.New("padding out of range")
+	ErrDocumentSequenceReset     = errors.New("invalid reset period")
+	ErrDocumentSequencePeriod    = errors.New("a resetting sequence needs the date tokens of its period in its prefix or suffix")
+	ErrDocumentSequenceConflict  = errors.New("another sequence already numbers this document type and location, or uses the same pattern")
+	ErrDocumentSequenceInUse     = errors.New("sequence has issued numbers")
+	ErrDocumentSequenceLocked    = errors.New("format of a sequence with issued numbers cannot change")
+	ErrDocumentSequenceNotFound  = errors.New("sequence not found")
+	ErrDocumentNumberNotFound    = errors.New("document number not found")
+	ErrDocumentNumberAlreadyVoid = errors.New("document number is already voided")
+)
+
+// sequenceToken matches one {TOKEN} placeholder in a prefix or suffix.
+var sequenceToken = regexp.MustCompile(`\{[^{}]*\}`)
+
+// DocumentSequence defines how one document type is numbered, for the whole
+// workspace (LocationID "") or for a single location's series. Prefix and
+// Suffix may carry the date tokens {YYYY}, {YY}, {MM} and {DD}, filled from
+// the allocation time; the counter is zero-padded to Padding digits, e.g.
+// Prefix "INV-{YYYY}-", Padding 5 → INV-2026-00042.
+type DocumentSequence struct {
+	ID           string
+	DocumentType string
+	LocationID   string
+	Prefix       string
+	Suffix       string
+	Padding      int
+	Reset        string
+}
+
+// Validate reports whether s can number documents. A resetting sequence must
+// print the period it resets on, or numbers would repeat after each reset.
+func (s *DocumentSequence) Validate() error {
+	known := false
+	for _, t := range DocumentTypes() {
+		known = known || t == s.DocumentType
+	}
+	if !known {
+		return ErrDocumentSequenceType
+	}
+	if !strings.HasPrefix(s.Prefix, DocumentTypeMarker(s.DocumentType)) {
+		return ErrDocumentSequenceMarker
+	}
+	tokens := map[string]bool{}
+	for _, tok := range sequenceToken.FindAllString(s.Prefix+s.Suffix, -1) {
+		switch tok {
+		case "{YYYY}", "{YY}", "{MM}", "{DD}":
+			tokens[tok] = true
+		default:
+			return fmt.Errorf("%w: %s", ErrDocumentSequenceToken, tok)
+		}
+	}
+	if s.Padding < 0 || s.Padding > MaxSequencePadding {
+		return ErrDocumentSequencePadding
+	}
+	hasYear := tokens["{YYYY}"] || tokens["{YY}"]
+	switch s.Reset {
+	case SequenceResetNever:
+	case SequenceResetYearly:
+		if !hasYear {
+			return ErrDocumentSequencePeriod
+		}
+	case SequenceResetMonthly:
+		if !hasYear || !tokens["{MM}"] {
+			return ErrDocumentSequencePeriod
+		}
+	default:
+		return ErrDocumentSequenceReset
+	}
+	return nil
+}
+
+// Period returns the counter period t falls in: "" for a sequence that never
+// resets, "2026" when yearly, "2026-10" when monthly.
+func (s *DocumentSequence) Period(t time.Time) string {
+	switch s.Reset {
+	case SequenceResetYearly:
+		return t.Format("2006")
+	case SequenceResetMonthly:
+		return t.Format("2006-01")
+	}
+	return ""
+}
+
+// Number formats the n-th number of the sequence as allocated at t.
+func (s *DocumentSequence) Number(n int64, t time.Time) string {
+	return expandDateTokens(s.Prefix, t) + fmt.Sprintf("%0*d", s.Padding, n) + expandDateTokens(s.Suffix, t)
+}
+
+// Pattern is the prefix and suffix around a "#" standing for the counter,
+// for display, e.g. INV-{YYYY}-#####.
+func (s *DocumentSequence) Pattern() string {
+	return s.Prefix + strings.Repeat("#", max(s.Padding, 1)) + s.Suffix
+}
+
+func expandDateTokens(s string, t time.Time) string {
+	return strings.NewReplacer(
+		"{YYYY}", t.Format("2006"),
+		"{YY}", t.Format("06"),
+		"{MM}", t.Format("01"),
+		"{DD}", t.Format("02"),
+	).Replace(s)
+}
+
+// DocumentNumber is one number a sequence handed out. Seq is the counter
+// value within Period; Number is the formatted reference.
+type DocumentNumber struct {
+	SequenceID   string
+	DocumentType string
+	LocationID   string
+	Period       string
+	Seq          int64
+	Number       string
+	Status       string
+	VoidReason   string
+	IssuedAt     time.Time
+	VoidedAt     time.Time
+}
+
+// DocumentSequenceStore persists sequence definitions, their counters and the
+// register of issued numbers. The consumer app scopes every call to the
+// request's workspace.
+type DocumentSequenceStore interface {
+	ListDocumentSequences(ctx context.Context) ([]*DocumentSequence, error)
+	// SaveDocumentSequence inserts seq (assigning its ID when empty) or
+	// replaces the sequence with the same ID. Counters are kept.
+	SaveDocumentSequence(ctx context.Context, seq *DocumentSequence) error
+	DeleteDocumentSequence(ctx context.Context, id string) error
+	// AllocateDocumentNumber takes the next counter value of seq for
+	// seq.Period(at) and records the issued number, formatted with
+	// seq.Number, in one atomic step: concurrent calls never see the same
+	// value and a value is never skipped. A database store increments the
+	// counter row and inserts the number row in the same transaction.
+	AllocateDocumentNumber(ctx context.Context, seq *DocumentSequence, at time.Time) (*DocumentNumber, error)
+	// VoidDocumentNumber marks an issued number voided. The counter is not
+	// rewound. Returns ErrDocumentNumberNotFound or
+	// ErrDocumentNumberAlreadyVoid.
+	VoidDocumentNumber(ctx context.Context, number, reason string, at time.Time) error
+	// ListDocumentNumbers returns the numbers sequenceID issued, newest
+	// first.
+	ListDocumentNumbers(ctx context.Context, sequenceID string) ([]*DocumentNumber, error)
+}
+
+// MemoryDocumentSequenceStore is an in-process DocumentSequenceStore for mock
+// builds and tests. Counters restart when the process does.
+type MemoryDocumentSequenceStore struct {
+	mu        sync.Mutex
+	lastID    int
+	sequences []*DocumentSequence
+	counters  map[string]int64 // by sequence ID + "/" + period
+	numbers   []*DocumentNumber
+}
+
+// NewMemoryDocumentSequenceStore returns an empty MemoryDocumentSequenceStore.
+func NewMemoryDocumentSequenceStore() *MemoryDocumentSequenceStore {
+	return &MemoryDocumentSequenceStore{counters: map[string]int64{}}
+}
+
+// ListDocumentSequences returns copies of the stored sequences.
+func (m *MemoryDocumentSequenceStore) ListDocumentSequences(_ context.Context) ([]*DocumentSequence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*DocumentSequence, len(m.sequences))
+	for i, s := range m.sequences {
+		cp := *s
+		out[i] = &cp
+	}
+	return out, nil
+}
+
+// SaveDocumentSequence stores a copy of seq.
+func (m *MemoryDocumentSequenceStore) SaveDocumentSequence(_ context.Context, seq *DocumentSequence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if seq.ID == "" {
+		m.lastID++
+		seq.ID = "seq-" + strconv.Itoa(m.lastID)
+	}
+	cp := *seq
+	for i, s := range m.sequences {
+		if s.ID == seq.ID {
+			m.sequences[i] = &cp
+			return nil
+		}
+	}
+	m.sequences = append(m.sequences, &cp)
+	return nil
+}
+
+// DeleteDocumentSequence removes the sequence with id.
+func (m *MemoryDocumentSequenceStore) DeleteDocumentSequence(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, s := range m.sequences {
+		if s.ID == id {
+			m.sequences = append(m.sequences[:i], m.sequences[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDocumentSequenceNotFound
+}
+
+// AllocateDocumentNumber issues the next number of seq under the store lock.
+func (m *MemoryDocumentSequenceStore) AllocateDocumentNumber(_ context.Context, seq *DocumentSequence, at time.Time) (*DocumentNumber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	period := seq.Period(at)
+	key := seq.ID + "/" + period
+	m.counters[key]++
+	n := &DocumentNumber{
+		SequenceID:   seq.ID,
+		DocumentType: seq.DocumentType,
+		LocationID:   seq.LocationID,
+		Period:       period,
+		Seq:          m.counters[key],
+		Number:       seq.Number(m.counters[key], at),
+		Status:       DocumentNumberIssued,
+		IssuedAt:     at,
+	}
+	m.numbers = append(m.numbers, n)
+	cp := *n
+	return &cp, nil
+}
+
+// VoidDocumentNumber marks number voided.
+func (m *MemoryDocumentSequenceStore) VoidDocumentNumber(_ context.Context, number, reason string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.numbers {
+		if n.Number != number {
+			continue
+		}
+		if n.Status == DocumentNumberVoided {
+			return ErrDocumentNumberAlreadyVoid
+		}
+		n.Status = DocumentNumberVoided
+		n.VoidReason = reason
+		n.VoidedAt = at
+		return nil
+	}
+	return ErrDocumentNumberNotFound
+}
+
+// ListDocumentNumbers returns copies of the numbers sequenceID issued, newest
+// first.
+func (m *MemoryDocumentSequenceStore) ListDocumentNumbers(_ context.Context, sequenceID string) ([]*DocumentNumber, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*DocumentNumber
+	for i := len(m.numbers) - 1; i >= 0; i-- {
+		if m.numbers[i].SequenceID == sequenceID {
+			cp := *m.numbers[i]
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// DocumentNumbering allocates and voids document numbers over a
+// DocumentSequenceStore, picking the location's series when it has one and
+// the workspace default otherwise. A nil *DocumentNumbering has no sequences,
+// so callers can treat numbering as optional and fall back on
+// ErrNoDocumentSequence.
+type DocumentNumbering struct {
+	store DocumentSequenceStore
+	now   func() time.Time
+}
+
+// NewDocumentNumbering returns a DocumentNumbering over store, or nil when
+// store is nil.
+func NewDocumentNumbering(store DocumentSequenceStore) *DocumentNumbering {
+	if store == nil {
+		return nil
+	}
+	return &DocumentNumbering{store: store, now: time.Now}
+}
+
+// Sequences returns the configured sequences ordered by document type (in
+// DocumentTypes order), then workspace default before location series.
+func (d *DocumentNumbering) Sequences(ctx context.Context) ([]*DocumentSequence, error) {
+	if d == nil {
+		return nil, nil
+	}
+	seqs, err := d.store.ListDocumentSequences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rank := map[string]int{}
+	for i, t := range DocumentTypes() {
+		rank[t] = i
+	}
+	sort.SliceStable(seqs, func(i, j int) bool {
+		if seqs[i].DocumentType != seqs[j].DocumentType {
+			return rank[seqs[i].DocumentType] < rank[seqs[j].DocumentType]
+		}
+		return seqs[i].LocationID < seqs[j].LocationID
+	})
+	return seqs, nil
+}
+
+// Sequence returns the sequence numbering docType at locationID: the
+// location's own series, else the workspace default, else nil.
+func (d *DocumentNumbering) Sequence(ctx context.Context, docType, locationID string) (*DocumentSequence, error) {
+	seqs, err := d.Sequences(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var fallback *DocumentSequence
+	for _, s := range seqs {
+		if s.DocumentType != docType {
+			continue
+		}
+		if locationID != "" && s.LocationID == locationID {
+			return s, nil
+		}
+		if s.LocationID == "" {
+			fallback = s
+		}
+	}
+	return fallback, nil
+}
+
+// Allocate issues the next number for docType at locationID. It returns
+// ErrNoDocumentSequence when neither the location nor the workspace has a
+// sequence for docType. Callers that fail to write the document must Void
+// the number.
+func (d *DocumentNumbering) Allocate(ctx context.Context, docType, locationID string) (*DocumentNumber, error) {
+	seq, err := d.Sequence(ctx, docType, locationID)
+	if err != nil {
+		return nil, err
+	}
+	if seq == nil {
+		return nil, ErrNoDocumentSequence
+	}
+	return d.store.AllocateDocumentNumber(ctx, seq, d.now())
+}
+
+// Void records that number will never be used, with the reason shown in the
+// sequence's register.
+func (d *DocumentNumbering) Void(ctx context.Context, number, reason string) error {
+	if d == nil {
+		return ErrDocumentNumberNotFound
+	}
+	return d.store.VoidDocumentNumber(ctx, number, reason, d.now())
+}
+
+// Numbers returns the register of numbers sequenceID issued, newest first.
+func (d *DocumentNumbering) Numbers(ctx context.Context, sequenceID string) ([]*DocumentNumber, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return d.store.ListDocumentNumbers(ctx, sequenceID)
+}
+
+// SaveSequence validates seq and stores it. Two sequences may not number the
+// same document type at the same location, nor share a prefix and suffix in
+// any document type, since their numbers would collide. Once a sequence has
+// issued numbers its format is fixed, so the register keeps one pattern.
+func (d *DocumentNumbering) SaveSequence(ctx context.Context, seq *DocumentSequence) error {
+	if err := seq.Validate(); err != nil {
+		return err
+	}
+	seqs, err := d.Sequences(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		if s.ID == seq.ID {
+			if err := d.checkFormatChange(ctx, s, seq); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.DocumentType == seq.DocumentType && s.LocationID == seq.LocationID {
+			return ErrDocumentSequenceConflict
+		}
+		if s.Prefix == seq.Prefix && s.Suffix == seq.Suffix {
+			return ErrDocumentSequenceConflict
+		}
+	}
+	return d.store.SaveDocumentSequence(ctx, seq)
+}
+
+// checkFormatChange returns ErrDocumentSequenceLocked when next changes the
+// numbers prev prints and prev has already issued some.
+func (d *DocumentNumbering) checkFormatChange(ctx context.Context, prev, next *DocumentSequence) error {
+	if prev.DocumentType == next.DocumentType && prev.Prefix == next.Prefix && prev.Suffix == next.Suffix &&
+		prev.Padding == next.Padding && prev.Reset == next.Reset {
+		return nil
+	}
+	numbers, err := d.Numbers(ctx, prev.ID)
+	if err != nil {
+		return err
+	}
+	if len(numbers) > 0 {
+		return ErrDocumentSequenceLocked
+	}
+	return nil
+}
+
+// DeleteSequence removes a sequence that has not issued any number; a used
+// sequence stays so its register remains auditable.
+func (d *DocumentNumbering) DeleteSequence(ctx context.Context, id string) error {
+	numbers, err := d.Numbers(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(numbers) > 0 {
+		return ErrDocumentSequenceInUse
+	}
+	return d.store.DeleteDocumentSequence(ctx, id)
+}
@@ -0,0 +1,214 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDocumentSequenceValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		seq  DocumentSequence
+		want error
+	}{
+		{"plain invoice", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-", Padding: 6}, nil},
+		{"yearly with year", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-{YY}-", Reset: SequenceResetYearly}, nil},
+		{"monthly with suffix tokens", DocumentSequence{DocumentType: DocumentOfficialReceipt, Prefix: "OR-", Suffix: "/{MM}/{YYYY}", Reset: SequenceResetMonthly}, nil},
+		{"unknown type", DocumentSequence{DocumentType: "quote", Prefix: "Q-"}, ErrDocumentSequenceType},
+		{"order without marker", DocumentSequence{DocumentType: DocumentOrder, Prefix: "SO-"}, ErrDocumentSequenceMarker},
+		{"credit note without marker", DocumentSequence{DocumentType: DocumentCreditNote, Prefix: "CR-"}, ErrDocumentSequenceMarker},
+		{"unknown token", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-{HH}-"}, ErrDocumentSequenceToken},
+		{"padding too wide", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-", Padding: 13}, ErrDocumentSequencePadding},
+		{"bad reset", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-", Reset: "weekly"}, ErrDocumentSequenceReset},
+		{"yearly without year", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-", Reset: SequenceResetYearly}, ErrDocumentSequencePeriod},
+		{"monthly without month", DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-{YYYY}-", Reset: SequenceResetMonthly}, ErrDocumentSequencePeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.seq.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocumentSequenceNumber(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, time.March, 7, 9, 0, 0, 0, time.UTC)
+	seq := DocumentSequence{Prefix: "INV-{YYYY}{MM}-", Suffix: "-{DD}/{YY}", Padding: 5, Reset: SequenceResetMonthly}
+	if got := seq.Number(42, at); got != "INV-202603-00042-07/26" {
+		t.Errorf("Number = %q", got)
+	}
+	if got := seq.Period(at); got != "2026-03" {
+		t.Errorf("Period = %q", got)
+	}
+	seq.Padding = 0
+	if got := seq.Number(1234567, at); got != "INV-202603-1234567-07/26" {
+		t.Errorf("unpadded Number = %q", got)
+	}
+	if got := seq.Pattern(); got != "INV-{YYYY}{MM}-#-{DD}/{YY}" {
+		t.Errorf("Pattern = %q", got)
+	}
+}
+
+func TestDocumentNumbering(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	numbering := NewDocumentNumbering(NewMemoryDocumentSequenceStore())
+	clock := time.Date(2026, time.December, 31, 23, 0, 0, 0, time.UTC)
+	numbering.now = func() time.Time { return clock }
+
+	if _, err := numbering.Allocate(ctx, DocumentInvoice, ""); !errors.Is(err, ErrNoDocumentSequence) {
+		t.Fatalf("unconfigured Allocate = %v, want ErrNoDocumentSequence", err)
+	}
+	if _, err := (*DocumentNumbering)(nil).Allocate(ctx, DocumentInvoice, ""); !errors.Is(err, ErrNoDocumentSequence) {
+		t.Fatalf("nil Allocate = %v, want ErrNoDocumentSequence", err)
+	}
+
+	def := &DocumentSequence{DocumentType: DocumentInvoice, Prefix: "INV-{YYYY}-", Padding: 4, Reset: SequenceResetYearly}
+	branch := &DocumentSequence{DocumentType: DocumentInvoice, LocationID: "loc-2", Prefix: "B2-INV-", Padding: 3}
+	for _, s := range []*DocumentSequence{def, branch} {
+		if err := numbering.SaveSequence(ctx, s); err != nil {
+			t.Fatalf("SaveSequence: %v", err)
+		}
+	}
+	dup := &DocumentSequence{DocumentType: DocumentInvoice, LocationID: "loc-3", Prefix: "B2-INV-", Padding: 3}
+	if err := numbering.SaveSequence(ctx, dup); !errors.Is(err, ErrDocumentSequenceConflict) {
+		t.Errorf("same pattern = %v, want ErrDocumentSequenceConflict", err)
+	}
+	if err := numbering.SaveSequence(ctx, &DocumentSequence{DocumentType: DocumentInvoice, Prefix: "X-"}); !errors.Is(err, ErrDocumentSequenceConflict) {
+		t.Errorf("second default = %v, want ErrDocumentSequenceConflict", err)
+	}
+	if err := numbering.SaveSequence(ctx, &DocumentSequence{DocumentType: DocumentPurchaseOrder, Prefix: "B2-INV-", Padding: 3}); !errors.Is(err, ErrDocumentSequenceConflict) {
+		t.Errorf("same pattern, other type = %v, want ErrDocumentSequenceConflict", err)
+	}
+	edited := *branch
+	edited.Padding = 5
+	if err := numbering.SaveSequence(ctx, &edited); err != nil {
+		t.Errorf("format change before any number = %v", err)
+	}
+	edited.Padding = 3
+	_ = numbering.SaveSequence(ctx, &edited)
+
+	allocate := func(loc string) string {
+		t.Helper()
+		n, err := numbering.Allocate(ctx, DocumentInvoice, loc)
+		if err != nil {
+			t.Fatalf("Allocate(%q): %v", loc, err)
+		}
+		return n.Number
+	}
+	if got := allocate("loc-1"); got != "INV-2026-0001" {
+		t.Errorf("location without series = %q, want the default sequence", got)
+	}
+	if got := allocate("loc-2"); got != "B2-INV-001" {
+		t.Errorf("location series = %q", got)
+	}
+	if got := allocate(""); got != "INV-2026-0002" {
+		t.Errorf("default = %q", got)
+	}
+
+	clock = clock.Add(2 * time.Hour)
+	if got := allocate(""); got != "INV-2027-0001" {
+		t.Errorf("after yearly reset = %q", got)
+	}
+	if got := allocate("loc-2"); got != "B2-INV-002" {
+		t.Errorf("non-resetting series = %q", got)
+	}
+
+	if err := numbering.Void(ctx, "INV-2026-0002", "draft discarded"); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if err := numbering.Void(ctx, "INV-2026-0002", "again"); !errors.Is(err, ErrDocumentNumberAlreadyVoid) {
+		t.Errorf("second Void = %v", err)
+	}
+	if err := numbering.Void(ctx, "INV-9999-0001", ""); !errors.Is(err, ErrDocumentNumberNotFound) {
+		t.Errorf("unknown Void = %v", err)
+	}
+	if got := allocate(""); got != "INV-2027-0002" {
+		t.Errorf("after void = %q, want the voided number skipped, not reused", got)
+	}
+
+	register, _ := numbering.Numbers(ctx, def.ID)
+	if len(register) != 4 || register[0].Number != "INV-2027-0002" {
+		t.Fatalf("register = %d numbers, newest %q", len(register), register[0].Number)
+	}
+	if v := register[2]; v.Number != "INV-2026-0002" || v.Status != DocumentNumberVoided || v.VoidReason != "draft discarded" {
+		t.Errorf("voided entry = %+v", v)
+	}
+
+	for name, change := range map[string]func(s *DocumentSequence){
+		"prefix":  func(s *DocumentSequence) { s.Prefix = "FAC-{YYYY}-" },
+		"suffix":  func(s *DocumentSequence) { s.Suffix = "-A" },
+		"padding": func(s *DocumentSequence) { s.Padding = 6 },
+		"reset":   func(s *DocumentSequence) { s.Reset = SequenceResetMonthly; s.Prefix = "INV-{YYYY}{MM}-" },
+	} {
+		used := *def
+		change(&used)
+		if err := numbering.SaveSequence(ctx, &used); !errors.Is(err, ErrDocumentSequenceLocked) {
+			t.Errorf("%s change on used sequence = %v, want ErrDocumentSequenceLocked", name, err)
+		}
+	}
+	if err := numbering.SaveSequence(ctx, def); err != nil {
+		t.Errorf("resave used sequence unchanged = %v", err)
+	}
+
+	if err := numbering.DeleteSequence(ctx, def.ID); !errors.Is(err, ErrDocumentSequenceInUse) {
+		t.Errorf("delete used sequence = %v, want ErrDocumentSequenceInUse", err)
+	}
+	unused := &DocumentSequence{DocumentType: DocumentPurchaseOrder, Prefix: "PO-"}
+	_ = numbering.SaveSequence(ctx, unused)
+	if err := numbering.DeleteSequence(ctx, unused.ID); err != nil {
+		t.Errorf("delete unused sequence = %v", err)
+	}
+}
+
+func TestDocumentNumberingConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	numbering := NewDocumentNumbering(NewMemoryDocumentSequenceStore())
+	seq := &DocumentSequence{DocumentType: DocumentOrder, Prefix: "ORD-", Padding: 6}
+	if err := numbering.SaveSequence(ctx, seq); err != nil {
+		t.Fatal(err)
+	}
+
+	const workers, each = 16, 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				n, err := numbering.Allocate(ctx, DocumentOrder, "")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				seen[n.Number] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != workers*each {
+		t.Fatalf("%d distinct numbers, want %d", len(seen), workers*each)
+	}
+	for i := 1; i <= workers*each; i++ {
+		if want := fmt.Sprintf("ORD-%06d", i); !seen[want] {
+			t.Fatalf("gap at %s", want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dunning step levels, in escalating order. A step's level picks its badge
+// and the default wording of its reminder.
+const (
+	DunningLevelReminder = "reminder"
+	DunningLevelDue      = "due"
+	DunningLevelOverdue  = "overdue"
+	DunningLevelFinal    = "final"
+)
+
+// DunningLevels lists the step levels in escalating order.
+func DunningLevels() []string {
+	return []string{DunningLevelReminder, DunningLevelDue, DunningLevelOverdue, DunningLevelFinal}
+}
+
+// Dunning errors, returned so views can map them to labels.
+var (
+	ErrDunningNoSteps      = errors.New("a dunning policy needs at least one step")
+	ErrDunningStepOrder    = errors.New("dunning steps must have distinct offsets")
+	ErrDunningStepLevel    = errors.New("unknown dunning step level")
+	ErrDunningStepTemplate = errors.New("every dunning step needs a subject and a message")
+)
+
+// DunningStep is one reminder of a policy, sent OffsetDays after the
+// invoice's due date (negative = before it). Subject and Body are the
+// reminder's template; see DunningVars for the placeholders.
+type DunningStep struct {
+	OffsetDays int
+	Level      string
+	Subject    string
+	Body       string
+}
+
+// DunningPolicy is a workspace's reminder schedule. Steps are kept sorted by
+// offset; each open invoice gets at most one reminder per step.
+type DunningPolicy struct {
+	Enabled bool
+	Steps   []DunningStep
+	// AttachInvoice attaches the invoice document to every reminder.
+	AttachInvoice bool
+}
+
+// DefaultDunningPolicy returns the schedule used until a workspace saves its
+// own: a reminder 3 days before the due date, one on it, then 7, 14 and 30
+// days overdue, each firmer than the last. It starts disabled.
+func DefaultDunningPolicy() *DunningPolicy {
+	return &DunningPolicy{
+		AttachInvoice: true,
+		Steps: []DunningStep{
+			{OffsetDays: -3, Level: DunningLevelReminder,
+				Subject: "Upcoming payment: {reference}",
+				Body:    "Dear {client},\n\nThis is a friendly reminder that {reference} for {balance} is due on {due_date}.\n\nThank you for your business."},
+			{OffsetDays: 0, Level: DunningLevelDue,
+				Subject: "Payment due today: {reference}",
+				Body:    "Dear {client},\n\n{reference} for {balance} is due today, {due_date}. Please disregard this message if you have already paid."},
+			{OffsetDays: 7, Level: DunningLevelOverdue,
+				Subject: "Overdue: {reference}",
+				Body:    "Dear {client},\n\n{reference} for {balance} was due on {due_date} and is now {days_overdue} days overdue. Please arrange payment at your earliest convenience."},
+			{OffsetDays: 14, Level: DunningLevelOverdue,
+				Subject: "Second notice: {reference} is {days_overdue} days overdue",
+				Body:    "Dear {client},\n\nWe have not yet received payment of {balance} for {reference}, due on {due_date}. Please settle the balance or contact us if there is a problem with the invoice."},
+			{OffsetDays: 30, Level: DunningLevelFinal,
+				Subject: "Final notice: {reference}",
+				Body:    "Dear {client},\n\n{reference} for {balance} is now {days_overdue} days overdue. Please pay the balance immediately to avoid further action."},
+		},
+	}
+}
+
+// Validate sorts the steps by offset and checks them.
+func (p *DunningPolicy) Validate() error {
+	if len(p.Steps) == 0 {
+		return ErrDunningNoSteps
+	}
+	sort.SliceStable(p.Steps, func(i, j int) bool { return p.Steps[i].OffsetDays < p.Steps[j].OffsetDays })
+	for i, s := range p.Steps {
+		if i > 0 && s.OffsetDays == p.Steps[i-1].OffsetDays {
+			return ErrDunningStepOrder
+		}
+		known := false
+		for _, level := range DunningLevels() {
+			known = known || s.Level == level
+		}
+		if !known {
+			return ErrDunningStepLevel
+		}
+		if s.Subject == "" || s.Body == "" {
+			return ErrDunningStepTemplate
+		}
+	}
+	return nil
+}
+
+// DunningReminder is the log entry of one reminder sent, or attempted, for a
+// revenue. Error is set when sending failed; failed steps are retried on the
+// next run.
+type DunningReminder struct {
+	RevenueID  string
+	ClientID   string
+	Step       int // index into the policy's steps at send time
+	OffsetDays int
+	Level      string
+	Recipient  string
+	Subject    string
+	SentAt     time.Time
+	Error      string
+}
+
+// DunningStore persists the workspace's dunning policy, the clients that
+// opted out of reminders and the reminder log. The consumer app scopes every
+// call to the request's workspace.
+type DunningStore interface {
+	// ReadDunningPolicy returns the saved policy, or nil when none was
+	// saved (DefaultDunningPolicy applies).
+	ReadDunningPolicy(ctx context.Context) (*DunningPolicy, error)
+	SaveDunningPolicy(ctx context.Context, policy *DunningPolicy) error
+	ListDunningOptOuts(ctx context.Context) ([]string, error)
+	SetDunningOptOut(ctx context.Context, clientID string, optOut bool) error
+	LogDunningReminder(ctx context.Context, reminder *DunningReminder) error
+	// ListDunningReminders returns revenueID's reminders ("" = every
+	// revenue's), newest first.
+	ListDunningReminders(ctx context.Context, revenueID string) ([]*DunningReminder, error)
+}
+
+// MemoryDunningStore is an in-process DunningStore for mock builds and tests.
+type MemoryDunningStore struct {
+	mu        sync.Mutex
+	policy    *DunningPolicy
+	optOuts   map[string]bool
+	reminders []*DunningReminder
+}
+
+// NewMemoryDunningStore returns an empty MemoryDunningStore.
+func NewMemoryDunningStore() *MemoryDunningStore {
+	return &MemoryDunningStore{optOuts: map[string]bool{}}
+}
+
+// ReadDunningPolicy returns a copy of the saved policy.
+func (m *MemoryDunningStore) ReadDunningPolicy(_ context.Context) (*DunningPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.policy == nil {
+		return nil, nil
+	}
+	cp := *m.policy
+	cp.Steps = append([]DunningStep(nil), m.policy.Steps...)
+	return &cp, nil
+}
+
+// SaveDunningPolicy stores a copy of policy.
+func (m *MemoryDunningStore) SaveDunningPolicy(_ context.Context, policy *DunningPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *policy
+	cp.Steps = append([]DunningStep(nil), policy.Steps...)
+	m.policy = &cp
+	return nil
+}
+
+// ListDunningOptOuts returns the opted-out client IDs, sorted.
+func (m *MemoryDunningStore) ListDunningOptOuts(_ context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]string, 0, len(m.optOuts))
+	for id := range m.optOuts {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// SetDunningOptOut adds or removes clientID from the opt-out list.
+func (m *MemoryDunningStore) SetDunningOptOut(_ context.Context, clientID string, optOut bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if optOut {
+		m.optOuts[clientID] = true
+	} else {
+		delete(m.optOuts, clientID)
+	}
+	return nil
+}
+
+// LogDunningReminder appends a copy of reminder.
+func (m *MemoryDunningStore) LogDunningReminder(_ context.Context, reminder *DunningReminder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *reminder
+	m.reminders = append(m.reminders, &cp)
+	return nil
+}
+
+// ListDunningReminders returns copies of revenueID's reminders, newest first.
+func (m *MemoryDunningStore) ListDunningReminders(_ context.Context, revenueID string) ([]*DunningReminder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*DunningReminder
+	for i := len(m.reminders) - 1; i >= 0; i-- {
+		if r := m.reminders[i]; revenueID == "" || r.RevenueID == revenueID {
+			cp := *r
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// DunningAction is a reminder the schedule calls for: the invoice line, the
+// step that is due and whether the client opted out.
+type DunningAction struct {
+	Line     *AgingLine
+	Step     int
+	OptedOut bool
+}
+
+// PlanDunning returns the reminders due on asOf for the open invoices of
+// report. Each invoice gets the latest step whose send date (due date plus
+// offset) has been reached, unless that step or a later one was already sent
+// — so a missed run never sends a burst of old reminders, and paid invoices,
+// which the report leaves out, get nothing. Opted-out clients' invoices are
+// returned with OptedOut set so previews can show them.
+func PlanDunning(policy *DunningPolicy, report *AgingReport, sent []*DunningReminder, optOuts []string, asOf time.Time) []DunningAction {
+	lastSent := map[string]int{}
+	for _, r := range sent {
+		if r.Error != "" {
+			continue
+		}
+		if i, ok := lastSent[r.RevenueID]; !ok || stepAt(policy, r) > i {
+			lastSent[r.RevenueID] = stepAt(policy, r)
+		}
+	}
+	optedOut := map[string]bool{}
+	for _, id := range optOuts {
+		optedOut[id] = true
+	}
+
+	day := truncateDay(asOf)
+	var actions []DunningAction
+	for _, c := range report.Clients {
+		for _, line := range c.Lines {
+			step := -1
+			for i, s := range policy.Steps {
+				if !line.DueDate.AddDate(0, 0, s.OffsetDays).After(day) {
+					step = i
+				}
+			}
+			if step < 0 {
+				continue
+			}
+			if i, ok := lastSent[line.RevenueID]; ok && i >= step {
+				continue
+			}
+			actions = append(actions, DunningAction{Line: line, Step: step, OptedOut: optedOut[line.ClientID]})
+		}
+	}
+	return actions
+}
+
+// stepAt maps a logged reminder to a step index of policy by its offset, so
+// editing the policy does not resend steps already sent. Reminders whose
+// offset is no longer in the policy count as the last step at or before it.
+func stepAt(policy *DunningPolicy, r *DunningReminder) int {
+	idx := -1
+	for i, s := range policy.Steps {
+		if s.OffsetDays <= r.OffsetDays {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// DunningVars returns the placeholder values of a reminder for line:
+// {client}, {reference}, {due_date}, {balance} (formatted by the caller),
+// {days_overdue} and {days_until_due}.
+func DunningVars(line *AgingLine, balance string) map[string]string {
+	return map[string]string{
+		"client":         line.ClientName,
+		"reference":      line.Reference,
+		"due_date":       line.DueDate.Format("2006-01-02"),
+		"balance":        balance,
+		"days_overdue":   strconv.Itoa(max(line.DaysOverdue, 0)),
+		"days_until_due": strconv.Itoa(max(-line.DaysOverdue, 0)),
+	}
+}
@@ -0,0 +1,132 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDunningPolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	if err := DefaultDunningPolicy().Validate(); err != nil {
+		t.Fatalf("default policy: %v", err)
+	}
+
+	p := &DunningPolicy{Steps: []DunningStep{
+		{OffsetDays: 7, Level: DunningLevelOverdue, Subject: "s", Body: "b"},
+		{OffsetDays: -3, Level: DunningLevelReminder, Subject: "s", Body: "b"},
+	}}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Steps[0].OffsetDays != -3 {
+		t.Errorf("steps not sorted: %+v", p.Steps)
+	}
+
+	tests := []struct {
+		name  string
+		steps []DunningStep
+		want  error
+	}{
+		{"no steps", nil, ErrDunningNoSteps},
+		{"duplicate offset", []DunningStep{{OffsetDays: 1, Level: DunningLevelDue, Subject: "s", Body: "b"}, {OffsetDays: 1, Level: DunningLevelDue, Subject: "s", Body: "b"}}, ErrDunningStepOrder},
+		{"bad level", []DunningStep{{OffsetDays: 1, Level: "angry", Subject: "s", Body: "b"}}, ErrDunningStepLevel},
+		{"no body", []DunningStep{{OffsetDays: 1, Level: DunningLevelDue, Subject: "s"}}, ErrDunningStepTemplate},
+	}
+	for _, tt := range tests {
+		if err := (&DunningPolicy{Steps: tt.steps}).Validate(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPlanDunning(t *testing.T) {
+	t.Parallel()
+
+	policy := DefaultDunningPolicy() // -3, 0, 7, 14, 30
+	asOf := day("2026-03-31")
+	report := AgeReceivables([]ReceivableInvoice{
+		{RevenueID: "soon", ClientID: "c1", Reference: "INV-1", Amount: 1000, InvoiceDate: day("2026-03-01"), DueDate: day("2026-04-02")},
+		{RevenueID: "later", ClientID: "c1", Reference: "INV-2", Amount: 1000, InvoiceDate: day("2026-03-01"), DueDate: day("2026-04-10")},
+		{RevenueID: "late", ClientID: "c1", Reference: "INV-3", Amount: 1000, InvoiceDate: day("2026-02-01"), DueDate: day("2026-03-15")},
+		{RevenueID: "sent", ClientID: "c2", Reference: "INV-4", Amount: 1000, InvoiceDate: day("2026-02-01"), DueDate: day("2026-03-20")},
+		{RevenueID: "failed", ClientID: "c2", Reference: "INV-5", Amount: 1000, InvoiceDate: day("2026-02-01"), DueDate: day("2026-03-20")},
+		{RevenueID: "paid", ClientID: "c2", Reference: "INV-6", Amount: 1000, InvoiceDate: day("2026-01-01"), DueDate: day("2026-01-31")},
+		{RevenueID: "quiet", ClientID: "c3", Reference: "INV-7", Amount: 1000, InvoiceDate: day("2026-01-01"), DueDate: day("2026-01-31")},
+	}, []ReceivableEntry{
+		{RevenueID: "paid", Amount: 1000, At: day("2026-02-15")},
+	}, nil, asOf, DefaultAgingLimits)
+
+	sent := []*DunningReminder{
+		{RevenueID: "sent", OffsetDays: 7},
+		{RevenueID: "failed", OffsetDays: 7, Error: "smtp down"},
+	}
+	actions := PlanDunning(policy, report, sent, []string{"c3"}, asOf)
+
+	got := map[string]DunningAction{}
+	for _, a := range actions {
+		got[a.Line.RevenueID] = a
+	}
+	want := map[string]int{
+		"soon":   0, // 2 days before due: the -3 reminder
+		"late":   3, // 16 days overdue: only the latest step, 14 days
+		"failed": 2, // the failed 7-day reminder is retried
+		"quiet":  4, // 59 days overdue: final notice, but opted out
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got actions for %v, want %v", keys(got), want)
+	}
+	for id, step := range want {
+		a, ok := got[id]
+		if !ok || a.Step != step {
+			t.Errorf("%s: got step %d (present %v), want %d", id, a.Step, ok, step)
+		}
+	}
+	if !got["quiet"].OptedOut || got["late"].OptedOut {
+		t.Error("OptedOut should follow the client opt-out list")
+	}
+}
+
+func TestMemoryDunningStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewMemoryDunningStore()
+	if p, err := s.ReadDunningPolicy(ctx); p != nil || err != nil {
+		t.Fatalf("empty store policy = %v, %v", p, err)
+	}
+	p := DefaultDunningPolicy()
+	p.Enabled = true
+	_ = s.SaveDunningPolicy(ctx, p)
+	p.Steps[0].Subject = "changed"
+	if got, _ := s.ReadDunningPolicy(ctx); !got.Enabled || got.Steps[0].Subject == "changed" {
+		t.Errorf("policy not stored as a copy: %+v", got)
+	}
+
+	_ = s.SetDunningOptOut(ctx, "b", true)
+	_ = s.SetDunningOptOut(ctx, "a", true)
+	_ = s.SetDunningOptOut(ctx, "b", false)
+	if ids, _ := s.ListDunningOptOuts(ctx); len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("opt-outs = %v", ids)
+	}
+
+	_ = s.LogDunningReminder(ctx, &DunningReminder{RevenueID: "r1", OffsetDays: -3})
+	_ = s.LogDunningReminder(ctx, &DunningReminder{RevenueID: "r2", OffsetDays: 0})
+	_ = s.LogDunningReminder(ctx, &DunningReminder{RevenueID: "r1", OffsetDays: 0})
+	r1, _ := s.ListDunningReminders(ctx, "r1")
+	if len(r1) != 2 || r1[0].OffsetDays != 0 {
+		t.Errorf("r1 reminders not newest first: %+v", r1)
+	}
+	if all, _ := s.ListDunningReminders(ctx, ""); len(all) != 3 {
+		t.Errorf("all reminders = %d, want 3", len(all))
+	}
+}
+
+func keys(m map[string]DunningAction) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,392 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email purposes. Each has its own workspace template.
+const (
+	EmailPurposeInvoice   = "invoice"
+	EmailPurposeReminder  = "reminder"
+	EmailPurposeStatement = "statement"
+	EmailPurposeReceipt   = "receipt"
+)
+
+// EmailPurposes lists the template purposes in display order.
+func EmailPurposes() []string {
+	return []string{EmailPurposeInvoice, EmailPurposeReminder, EmailPurposeStatement, EmailPurposeReceipt}
+}
+
+// Email send statuses. A scheduled send becomes sent or failed when it is
+// delivered, or cancelled before that. The scheduled run claims a send as
+// sending before it goes out; a send left sending was handed to the provider
+// but its outcome was not logged, and is not delivered again.
+const (
+	EmailStatusScheduled = "scheduled"
+	EmailStatusSending   = "sending"
+	EmailStatusSent      = "sent"
+	EmailStatusFailed    = "failed"
+	EmailStatusCancelled = "cancelled"
+)
+
+// Email errors, returned so views can map them to labels.
+var (
+	ErrEmailPurpose      = errors.New("unknown email purpose")
+	ErrEmailTemplate     = errors.New("an email template needs a subject and a message")
+	ErrEmailNoRecipients = errors.New("an email needs at least one recipient")
+	ErrEmailAddress      = errors.New("invalid email address")
+	ErrEmailNotScheduled = errors.New("only scheduled emails can be cancelled")
+)
+
+// EmailTemplate is a workspace's subject and message for one purpose. The
+// {name} placeholders are the dotted keys of the invoice data (see
+// EmailVars), e.g. {customer.name} or {invoice.reference_number}.
+type EmailTemplate struct {
+	Purpose string
+	Subject string
+	Body    string
+}
+
+var defaultEmailTemplates = map[string]EmailTemplate{
+	EmailPurposeInvoice: {
+		Subject: "{document} {invoice.reference_number}",
+		Body:    "Dear {customer.name},\n\nPlease find attached {document} {invoice.reference_number} for {invoice.total_amount} {currency}.\n\nThank you for your business.",
+	},
+	EmailPurposeReminder: {
+		Subject: "Payment reminder: {invoice.reference_number}",
+		Body:    "Dear {customer.name},\n\nThis is a reminder that {invoice.reference_number} for {invoice.total_amount} {currency} is awaiting payment. Please disregard this message if you have already paid.\n\nThank you.",
+	},
+	EmailPurposeStatement: {
+		Subject: "Statement of account",
+		Body:    "Dear {customer.name},\n\nPlease find attached your statement of account.\n\nThank you for your business.",
+	},
+	EmailPurposeReceipt: {
+		Subject: "Receipt for {invoice.reference_number}",
+		Body:    "Dear {customer.name},\n\nThank you for your payment on {invoice.reference_number}. Please find your receipt attached.",
+	},
+}
+
+// DefaultEmailTemplate returns the template used for purpose until the
+// workspace saves its own, or nil for an unknown purpose.
+func DefaultEmailTemplate(purpose string) *EmailTemplate {
+	t, ok := defaultEmailTemplates[purpose]
+	if !ok {
+		return nil
+	}
+	t.Purpose = purpose
+	return &t
+}
+
+// Validate checks the template's purpose and that it has a subject and a
+// message.
+func (t *EmailTemplate) Validate() error {
+	if _, ok := defaultEmailTemplates[t.Purpose]; !ok {
+		return ErrEmailPurpose
+	}
+	if strings.TrimSpace(t.Subject) == "" || strings.TrimSpace(t.Body) == "" {
+		return ErrEmailTemplate
+	}
+	return nil
+}
+
+// EmailVars flattens template data (the invoice data map) into placeholder
+// values keyed by dotted path: {"invoice": {"reference_number": "INV-1"}}
+// gives "invoice.reference_number". Lists, such as the line items, are left
+// out.
+func EmailVars(data map[string]any) map[string]string {
+	vars := map[string]string{}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			key := prefix + k
+			switch v := v.(type) {
+			case map[string]any:
+				walk(key+".", v)
+			case []any, nil:
+			case string:
+				vars[key] = v
+			default:
+				vars[key] = fmt.Sprint(v)
+			}
+		}
+	}
+	walk("", data)
+	return vars
+}
+
+// ExpandEmail replaces the {name} placeholders of template with vars;
+// unknown placeholders are left as they are.
+func ExpandEmail(template string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// EmailHTML renders a plain-text message as HTML: blank lines separate
+// paragraphs, single newlines become line breaks.
+func EmailHTML(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if para = strings.TrimSpace(para); para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>")
+	}
+	return b.String()
+}
+
+// ParseEmailAddresses reads a list of addresses separated by commas,
+// semicolons or new lines, e.g. "ap@acme.test; Jane <jane@acme.test>". It
+// returns the bare addresses with duplicates removed, or ErrEmailAddress
+// naming the first invalid one.
+func ParseEmailAddresses(s string) ([]string, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '\n' || r == '\r' })
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrEmailAddress, f)
+		}
+		if key := strings.ToLower(addr.Address); !seen[key] {
+			seen[key] = true
+			out = append(out, addr.Address)
+		}
+	}
+	return out, nil
+}
+
+// AttachmentHash returns the hex SHA-256 of an attachment, "" when there is
+// none.
+func AttachmentHash(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// EmailMessage is one message to deliver. CC and BCC are optional; the
+// attachment is omitted when AttachmentData is empty.
+type EmailMessage struct {
+	To             []string
+	CC             []string
+	BCC            []string
+	Subject        string
+	HTMLBody       string
+	TextBody       string
+	AttachmentName string
+	AttachmentData []byte
+}
+
+// EmailSend is the log entry of an email sent, or scheduled, for a revenue.
+// Format is the attachment rendered at send time ("pdf", "docx", "ubl"; ""
+// = none), so a scheduled send attaches the document as it is when it goes
+// out. MessageID is the email provider's ID, when it returns one.
+type EmailSend struct {
+	ID        string
+	RevenueID string
+	Purpose   string
+	To        []string
+	CC        []string
+	BCC       []string
+	Subject   string
+	Body      string
+	Format    string
+
+	AttachmentName   string
+	AttachmentSHA256 string
+	MessageID        string
+
+	Status       string
+	Error        string
+	CreatedBy    string
+	CreatedAt    time.Time
+	ScheduledFor time.Time // zero = sent right away
+	SentAt       time.Time
+}
+
+// Recipients returns every address of the send: To, then CC and BCC.
+func (s *EmailSend) Recipients() []string {
+	out := append([]string(nil), s.To...)
+	out = append(out, s.CC...)
+	return append(out, s.BCC...)
+}
+
+// Cancel marks a scheduled send cancelled.
+func (s *EmailSend) Cancel() error {
+	if s.Status != EmailStatusScheduled {
+		return ErrEmailNotScheduled
+	}
+	s.Status = EmailStatusCancelled
+	return nil
+}
+
+// EmailStore persists the workspace's email templates and the email log. The
+// consumer app scopes every call to the request's workspace; scheduled sends
+// are delivered by a job that runs per workspace.
+type EmailStore interface {
+	// ReadEmailTemplate returns purpose's saved template, or nil when none
+	// was saved (DefaultEmailTemplate applies).
+	ReadEmailTemplate(ctx context.Context, purpose string) (*EmailTemplate, error)
+	SaveEmailTemplate(ctx context.Context, template *EmailTemplate) error
+	// SaveEmailSend creates send when its ID is new, assigning one when
+	// blank, and replaces the stored send otherwise.
+	SaveEmailSend(ctx context.Context, send *EmailSend) error
+	// ReadEmailSend returns the send with id, or nil when there is none.
+	ReadEmailSend(ctx context.Context, id string) (*EmailSend, error)
+	// ListEmailSends returns revenueID's sends, newest first.
+	ListEmailSends(ctx context.Context, revenueID string) ([]*EmailSend, error)
+	// ListDueEmailSends returns the scheduled sends due at asOf, oldest
+	// first.
+	ListDueEmailSends(ctx context.Context, asOf time.Time) ([]*EmailSend, error)
+	// UpdateEmailSendStatusIf sets the status of the send with id to to
+	// only if it is from, in one conditional update, and reports whether it
+	// did. Two scheduled runs, or a run and a cancel, cannot both act on
+	// the same send.
+	UpdateEmailSendStatusIf(ctx context.Context, id, from, to string) (bool, error)
+}
+
+// ReadEmailTemplateOrDefault returns purpose's saved template, or its
+// default when none was saved.
+func ReadEmailTemplateOrDefault(ctx context.Context, store EmailStore, purpose string) (*EmailTemplate, error) {
+	t, err := store.ReadEmailTemplate(ctx, purpose)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s email template: %w", purpose, err)
+	}
+	if t == nil || t.Subject == "" {
+		if t = DefaultEmailTemplate(purpose); t == nil {
+			return nil, ErrEmailPurpose
+		}
+	}
+	return t, nil
+}
+
+// MemoryEmailStore is an in-process EmailStore for mock builds and tests.
+type MemoryEmailStore struct {
+	mu        sync.Mutex
+	templates map[string]EmailTemplate
+	sends     []*EmailSend
+	nextID    int
+}
+
+// NewMemoryEmailStore returns an empty MemoryEmailStore.
+func NewMemoryEmailStore() *MemoryEmailStore {
+	return &MemoryEmailStore{templates: map[string]EmailTemplate{}}
+}
+
+// ReadEmailTemplate returns a copy of purpose's saved template.
+func (m *MemoryEmailStore) ReadEmailTemplate(_ context.Context, purpose string) (*EmailTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.templates[purpose]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// SaveEmailTemplate stores a copy of template.
+func (m *MemoryEmailStore) SaveEmailTemplate(_ context.Context, template *EmailTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.templates[template.Purpose] = *template
+	return nil
+}
+
+// SaveEmailSend stores a copy of send.
+func (m *MemoryEmailStore) SaveEmailSend(_ context.Context, send *EmailSend) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if send.ID == "" {
+		m.nextID++
+		send.ID = "email-" + strconv.Itoa(m.nextID)
+	}
+	cp := *send
+	for i, s := range m.sends {
+		if s.ID == send.ID {
+			m.sends[i] = &cp
+			return nil
+		}
+	}
+	m.sends = append(m.sends, &cp)
+	return nil
+}
+
+// ReadEmailSend returns a copy of the send with id.
+func (m *MemoryEmailStore) ReadEmailSend(_ context.Context, id string) (*EmailSend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sends {
+		if s.ID == id {
+			cp := *s
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListEmailSends returns copies of revenueID's sends, newest first.
+func (m *MemoryEmailStore) ListEmailSends(_ context.Context, revenueID string) ([]*EmailSend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*EmailSend
+	for i := len(m.sends) - 1; i >= 0; i-- {
+		if s := m.sends[i]; s.RevenueID == revenueID {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// ListDueEmailSends returns copies of the scheduled sends due at asOf,
+// oldest first.
+func (m *MemoryEmailStore) ListDueEmailSends(_ context.Context, asOf time.Time) ([]*EmailSend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*EmailSend
+	for _, s := range m.sends {
+		if s.Status == EmailStatusScheduled && !s.ScheduledFor.After(asOf) {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ScheduledFor.Before(out[j].ScheduledFor) })
+	return out, nil
+}
+
+// UpdateEmailSendStatusIf sets the send's status to to if it is from.
+func (m *MemoryEmailStore) UpdateEmailSendStatusIf(_ context.Context, id, from, to string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sends {
+		if s.ID == id {
+			if s.Status != from {
+				return false, nil
+			}
+			s.Status = to
+			return true, nil
+		}
+	}
+	return false, nil
+}
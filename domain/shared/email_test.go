@@ -0,0 +1,125 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEmailTemplateExpand(t *testing.T) {
+	t.Parallel()
+
+	for _, purpose := range EmailPurposes() {
+		if err := DefaultEmailTemplate(purpose).Validate(); err != nil {
+			t.Errorf("%s default: %v", purpose, err)
+		}
+	}
+	if DefaultEmailTemplate("fax") != nil {
+		t.Error("unknown purpose has a default template")
+	}
+	if err := (&EmailTemplate{Purpose: EmailPurposeInvoice, Subject: "s"}).Validate(); !errors.Is(err, ErrEmailTemplate) {
+		t.Errorf("no body: %v", err)
+	}
+	if err := (&EmailTemplate{Purpose: "fax", Subject: "s", Body: "b"}).Validate(); !errors.Is(err, ErrEmailPurpose) {
+		t.Errorf("bad purpose: %v", err)
+	}
+
+	vars := EmailVars(map[string]any{
+		"document": "Invoice",
+		"invoice":  map[string]any{"reference_number": "INV-1", "total_amount": "1,000.00"},
+		"customer": map[string]any{"name": "Globex"},
+		"lines":    []any{map[string]any{"name": "x"}},
+		"count":    3,
+	})
+	if vars["invoice.reference_number"] != "INV-1" || vars["count"] != "3" {
+		t.Errorf("vars = %v", vars)
+	}
+	if _, ok := vars["lines"]; ok {
+		t.Error("lists are not placeholders")
+	}
+	got := ExpandEmail("{document} {invoice.reference_number} for {customer.name} {unknown}", vars)
+	if want := "Invoice INV-1 for Globex {unknown}"; got != want {
+		t.Errorf("ExpandEmail = %q, want %q", got, want)
+	}
+	if got := EmailHTML("Dear <Jane>,\n\nline one\nline two\n\n"); got != "<p>Dear &lt;Jane&gt;,</p><p>line one<br>line two</p>" {
+		t.Errorf("EmailHTML = %q", got)
+	}
+}
+
+func TestParseEmailAddresses(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseEmailAddresses("ap@acme.test; Jane <jane@acme.test>,\nAP@acme.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ap@acme.test", "jane@acme.test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, err := ParseEmailAddresses("  "); err != nil || got != nil {
+		t.Errorf("blank: %v, %v", got, err)
+	}
+	if _, err := ParseEmailAddresses("ap@acme.test, not-an-address"); !errors.Is(err, ErrEmailAddress) {
+		t.Errorf("invalid: %v", err)
+	}
+}
+
+func TestMemoryEmailStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryEmailStore()
+
+	tpl, err := ReadEmailTemplateOrDefault(ctx, store, EmailPurposeReminder)
+	if err != nil || tpl.Subject != DefaultEmailTemplate(EmailPurposeReminder).Subject {
+		t.Fatalf("default template: %+v, %v", tpl, err)
+	}
+	if err := store.SaveEmailTemplate(ctx, &EmailTemplate{Purpose: EmailPurposeReminder, Subject: "Hi", Body: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if tpl, _ := ReadEmailTemplateOrDefault(ctx, store, EmailPurposeReminder); tpl.Subject != "Hi" {
+		t.Errorf("saved template not read: %+v", tpl)
+	}
+
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	sent := &EmailSend{RevenueID: "r1", Status: EmailStatusSent, CreatedAt: at}
+	later := &EmailSend{RevenueID: "r1", Status: EmailStatusScheduled, ScheduledFor: at.Add(48 * time.Hour)}
+	due := &EmailSend{RevenueID: "r1", Status: EmailStatusScheduled, ScheduledFor: at.Add(time.Hour)}
+	for _, s := range []*EmailSend{sent, later, due} {
+		if err := store.SaveEmailSend(ctx, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent.ID == "" || sent.ID == later.ID {
+		t.Fatalf("ids not assigned: %q %q", sent.ID, later.ID)
+	}
+
+	list, _ := store.ListEmailSends(ctx, "r1")
+	if len(list) != 3 || list[0].ID != due.ID {
+		t.Errorf("list = %+v", list)
+	}
+	dueList, _ := store.ListDueEmailSends(ctx, at.Add(24*time.Hour))
+	if len(dueList) != 1 || dueList[0].ID != due.ID {
+		t.Errorf("due = %+v", dueList)
+	}
+
+	if err := sent.Cancel(); !errors.Is(err, ErrEmailNotScheduled) {
+		t.Errorf("cancel sent: %v", err)
+	}
+	if err := due.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.UpdateEmailSendStatusIf(ctx, due.ID, EmailStatusScheduled, EmailStatusCancelled); !ok || err != nil {
+		t.Fatalf("cancel due = %v, %v", ok, err)
+	}
+	if ok, _ := store.UpdateEmailSendStatusIf(ctx, due.ID, EmailStatusScheduled, EmailStatusSending); ok {
+		t.Error("claimed a cancelled send")
+	}
+	if got, _ := store.ReadEmailSend(ctx, due.ID); got.Status != EmailStatusCancelled {
+		t.Errorf("status = %s", got.Status)
+	}
+	if dueList, _ := store.ListDueEmailSends(ctx, at.Add(72*time.Hour)); len(dueList) != 1 || dueList[0].ID != later.ID {
+		t.Errorf("due after cancel = %+v", dueList)
+	}
+}
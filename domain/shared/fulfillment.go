@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Fulfillment errors returned through the closures below, so views can tell a
+// bad request from a failure without importing the checkout service.
+var (
+	ErrOrderNotFound                = errors.New("order not found")
+	ErrOrderNotFulfillable          = errors.New("order cannot be fulfilled")
+	ErrInvalidFulfillmentTransition = errors.New("invalid fulfillment transition")
+)
+
+// Fulfillment types a storefront order is placed with (revenue.fulfillment_type).
+const (
+	FulfillmentStorePickup  = "store_pickup"
+	FulfillmentHomeDelivery = "home_delivery"
+)
+
+// Fulfillment states written to revenue.fulfillment_status for storefront
+// orders. A paid order that has not started fulfillment has no state and is
+// reported as FulfillmentQueued.
+const (
+	FulfillmentQueued         = "queued"
+	FulfillmentPicked         = "picked"
+	FulfillmentPacked         = "packed"
+	FulfillmentReadyForPickup = "ready_for_pickup"
+	FulfillmentShipped        = "shipped"
+	FulfillmentDelivered      = "delivered"
+	FulfillmentFailedDelivery = "failed_delivery"
+)
+
+// NextFulfillmentStates lists the states an order of fulfillmentType may move
+// to from state. Pickup orders go picked → packed → ready_for_pickup →
+// delivered; delivery orders go picked → packed → shipped → delivered. A failed
+// delivery (or a pickup no-show) can be retried. Delivered is final.
+func NextFulfillmentStates(fulfillmentType, state string) []string {
+	handoff := FulfillmentShipped
+	if fulfillmentType != FulfillmentHomeDelivery {
+		handoff = FulfillmentReadyForPickup
+	}
+	switch state {
+	case "", FulfillmentQueued:
+		return []string{FulfillmentPicked}
+	case FulfillmentPicked:
+		return []string{FulfillmentPacked}
+	case FulfillmentPacked:
+		return []string{handoff}
+	case FulfillmentReadyForPickup, FulfillmentShipped:
+		return []string{FulfillmentDelivered, FulfillmentFailedDelivery}
+	case FulfillmentFailedDelivery:
+		return []string{handoff}
+	default:
+		return nil
+	}
+}
+
+// FulfillmentEvent is one recorded fulfillment state change.
+type FulfillmentEvent struct {
+	RevenueID string
+	From      string
+	To        string
+	UserID    string // acting staff user
+	Note      string // tracking number, failure reason, ...
+	At        time.Time
+}
+
+// OrderFulfillment is an order's fulfillment state as shown in the
+// fulfillment queue and returned to storefront status polls.
+type OrderFulfillment struct {
+	RevenueID       string
+	ReferenceNumber string
+	CustomerName    string
+	LocationID      string
+	OrderStatus     string // revenue status
+	FulfillmentType string
+	DeliveryAddress string
+	State           string
+	TotalAmount     int64 // centavos
+	Currency        string
+	OrderedAt       time.Time
+	// NextStates are the states staff may move the order to next.
+	NextStates []string
+	// Events is the state history, oldest first. Empty when no event store
+	// is wired.
+	Events []FulfillmentEvent
+}
+
+// FulfillmentTransition asks for an order to be moved to a new state.
+type FulfillmentTransition struct {
+	ReferenceNumber string
+	To              string
+	UserID          string
+	Note            string
+}
+
+// Fulfillment closures fed at composition time from the consumer app's
+// checkout service.
+type (
+	ListFulfillmentQueue func(ctx context.Context, locationID string) ([]*OrderFulfillment, error)
+	GetOrderFulfillment  func(ctx context.Context, referenceNumber string) (*OrderFulfillment, error)
+	AdvanceFulfillment   func(ctx context.Context, t FulfillmentTransition) (*OrderFulfillment, error)
+)
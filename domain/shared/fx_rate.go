@@ -0,0 +1,482 @@
+package shared
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FXRateScale is the fixed-point scale of exchange rates: a rate is stored in
+// micro units, the number of units of the quote currency per 1 unit of the
+// base currency times 1,000,000 (56_500_000 = 56.5 PHP per USD) — the same
+// scale as Revenue.forex_rate_micro_units and the forex_rate table.
+const FXRateScale int64 = 1_000_000
+
+// FX rate sources.
+const (
+	FXSourceOperator = "operator"
+	FXSourceImport   = "import"
+)
+
+// Documents whose rate is snapshotted when issued.
+const (
+	FXDocumentRevenue      = "revenue"
+	FXDocumentCollection   = "collection"
+	FXDocumentDisbursement = "disbursement"
+)
+
+// FX rate errors, returned so views can map them to labels.
+var (
+	ErrFXRateCurrency  = errors.New("an exchange rate needs two different ISO 4217 currency codes")
+	ErrFXRateValue     = errors.New("an exchange rate must be a positive number")
+	ErrFXRateDate      = errors.New("an exchange rate needs a date")
+	ErrFXRateCSVHeader = errors.New("the rate file needs a date, from, to and rate column")
+	ErrFXRateNotFound  = errors.New("no exchange rate for the currency pair on or before the date")
+)
+
+// FXRate is one day's rate of a currency pair: RateMicroUnits units of To per
+// 1 unit of From (see FXRateScale). A pair has at most one rate per day; the
+// rate applies from Date until the pair's next rate.
+type FXRate struct {
+	From           string
+	To             string
+	Date           time.Time
+	RateMicroUnits int64
+	Source         string
+}
+
+// ID identifies the rate by pair and day, e.g. "USD-PHP-2026-03-01", so
+// saving the same pair and day again replaces the rate.
+func (r *FXRate) ID() string {
+	return r.From + "-" + r.To + "-" + r.Date.Format("2006-01-02")
+}
+
+// Validate normalises the currency codes to upper case, truncates the date to
+// the day and checks the rate.
+func (r *FXRate) Validate() error {
+	r.From = strings.ToUpper(strings.TrimSpace(r.From))
+	r.To = strings.ToUpper(strings.TrimSpace(r.To))
+	if !isCurrencyCode(r.From) || !isCurrencyCode(r.To) || r.From == r.To {
+		return ErrFXRateCurrency
+	}
+	if r.Date.IsZero() {
+		return ErrFXRateDate
+	}
+	r.Date = truncateDay(r.Date)
+	if r.RateMicroUnits <= 0 {
+		return ErrFXRateValue
+	}
+	return nil
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// FXSnapshot is the rate a document was issued at: RateMicroUnits units of
+// Functional per 1 unit of Currency, taken from the pair's rate of RateDate.
+type FXSnapshot struct {
+	Kind           string // FXDocument*
+	DocumentID     string
+	Currency       string
+	Functional     string
+	RateMicroUnits int64
+	RateDate       time.Time
+	Source         string
+	TakenAt        time.Time
+}
+
+// FXRateStore persists the workspace's daily exchange rates and the rates
+// snapshotted on issued documents. The consumer app scopes every call to the
+// request's workspace.
+type FXRateStore interface {
+	// ListFXRates returns every stored rate.
+	ListFXRates(ctx context.Context) ([]*FXRate, error)
+	// SaveFXRates stores rates, replacing those with the same ID.
+	SaveFXRates(ctx context.Context, rates []*FXRate) error
+	DeleteFXRate(ctx context.Context, id string) error
+	// ReadFXSnapshot returns the snapshot of document kind/id, or nil when
+	// none was taken.
+	ReadFXSnapshot(ctx context.Context, kind, id string) (*FXSnapshot, error)
+	SaveFXSnapshot(ctx context.Context, snapshot *FXSnapshot) error
+	// ListFXSnapshots returns kind's snapshots ("" = every kind's).
+	ListFXSnapshots(ctx context.Context, kind string) ([]*FXSnapshot, error)
+}
+
+// MemoryFXRateStore is an in-process FXRateStore for mock builds and tests.
+type MemoryFXRateStore struct {
+	mu        sync.Mutex
+	rates     map[string]*FXRate
+	snapshots map[string]*FXSnapshot // by kind + "/" + document ID
+}
+
+// NewMemoryFXRateStore returns an empty MemoryFXRateStore.
+func NewMemoryFXRateStore() *MemoryFXRateStore {
+	return &MemoryFXRateStore{rates: map[string]*FXRate{}, snapshots: map[string]*FXSnapshot{}}
+}
+
+// ListFXRates returns copies of the stored rates, ordered by pair and date.
+func (m *MemoryFXRateStore) ListFXRates(_ context.Context) ([]*FXRate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*FXRate, 0, len(m.rates))
+	for _, r := range m.rates {
+		cp := *r
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID() < out[j].ID() })
+	return out, nil
+}
+
+// SaveFXRates stores copies of rates.
+func (m *MemoryFXRateStore) SaveFXRates(_ context.Context, rates []*FXRate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range rates {
+		cp := *r
+		m.rates[r.ID()] = &cp
+	}
+	return nil
+}
+
+// DeleteFXRate removes the rate with id.
+func (m *MemoryFXRateStore) DeleteFXRate(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rates, id)
+	return nil
+}
+
+// ReadFXSnapshot returns a copy of the snapshot of kind/id.
+func (m *MemoryFXRateStore) ReadFXSnapshot(_ context.Context, kind, id string) (*FXSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.snapshots[kind+"/"+id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// SaveFXSnapshot stores a copy of snapshot.
+func (m *MemoryFXRateStore) SaveFXSnapshot(_ context.Context, snapshot *FXSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *snapshot
+	m.snapshots[snapshot.Kind+"/"+snapshot.DocumentID] = &cp
+	return nil
+}
+
+// ListFXSnapshots returns copies of kind's snapshots.
+func (m *MemoryFXRateStore) ListFXSnapshots(_ context.Context, kind string) ([]*FXSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*FXSnapshot
+	for _, s := range m.snapshots {
+		if kind == "" || s.Kind == kind {
+			cp := *s
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// ParseFXRate parses a decimal rate such as "56.5" into micro units, rounding
+// past the sixth decimal.
+func ParseFXRate(s string) (int64, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return 0, ErrFXRateValue
+	}
+	r.Mul(r, new(big.Rat).SetInt64(FXRateScale))
+	n := roundRat(r)
+	if !n.IsInt64() || n.Sign() <= 0 {
+		return 0, ErrFXRateValue
+	}
+	return n.Int64(), nil
+}
+
+// FormatFXRate formats a micro-unit rate with four to six decimals, e.g.
+// "56.5000" or "0.017699".
+func FormatFXRate(micro int64) string {
+	s := fmt.Sprintf("%d.%06d", micro/FXRateScale, micro%FXRateScale)
+	for strings.HasSuffix(s, "0") && len(s)-strings.IndexByte(s, '.') > 5 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// ConvertFX converts centavos at a micro-unit rate, rounding half away from
+// zero.
+func ConvertFX(centavos, rateMicroUnits int64) int64 {
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(centavos), big.NewInt(rateMicroUnits)),
+		big.NewInt(FXRateScale),
+	)
+	return roundRat(r).Int64()
+}
+
+// InvertFXRate returns the rate of the reverse pair.
+func InvertFXRate(rateMicroUnits int64) int64 {
+	r := new(big.Rat).SetFrac(big.NewInt(FXRateScale*FXRateScale), big.NewInt(rateMicroUnits))
+	return roundRat(r).Int64()
+}
+
+// RealizedFXGainLoss is the functional-currency difference of settling amount
+// (document currency centavos) at settledRate instead of the bookedRate it was
+// issued at. For a receivable a positive result is a gain; for a payable it
+// is a loss.
+func RealizedFXGainLoss(amount, bookedRate, settledRate int64) int64 {
+	return ConvertFX(amount, settledRate) - ConvertFX(amount, bookedRate)
+}
+
+// roundRat rounds r to the nearest integer, halves away from zero.
+func roundRat(r *big.Rat) *big.Int {
+	num := new(big.Int).Abs(r.Num())
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if m.Mul(m, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+// ParseFXRatesCSV reads rates from CSV with a header row naming the date,
+// from, to and rate columns (in any order, other columns ignored), e.g.
+//
+//	date,from,to,rate
+//	2026-03-02,USD,PHP,56.4150
+//
+// Every rate is validated; errors name the file line.
+func ParseFXRatesCSV(r io.Reader, source string) ([]*FXRate, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, ErrFXRateCSVHeader
+	}
+	cols := map[string]int{}
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, name := range []string{"date", "from", "to", "rate"} {
+		if _, ok := cols[name]; !ok {
+			return nil, ErrFXRateCSVHeader
+		}
+	}
+
+	var rates []*FXRate
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err // *csv.ParseError names the line
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i := cols[name]; i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if strings.Join(rec, "") == "" {
+			continue
+		}
+		rate := &FXRate{From: field("from"), To: field("to"), Source: source}
+		if rate.Date, err = time.Parse("2006-01-02", field("date")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, ErrFXRateDate)
+		}
+		if rate.RateMicroUnits, err = ParseFXRate(field("rate")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := rate.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+}
+
+// FXTranslation converts document amounts into the workspace's functional
+// currency with a loaded set of rates and snapshots, for reports that
+// translate many documents at once. A nil *FXTranslation leaves amounts
+// untranslated.
+type FXTranslation struct {
+	Functional string
+	rates      map[string][]*FXRate // by From + "/" + To, oldest first
+	snapshots  map[string]*FXSnapshot
+}
+
+// NewFXTranslation indexes rates and snapshots for translation into
+// functional.
+func NewFXTranslation(functional string, rates []*FXRate, snapshots []*FXSnapshot) *FXTranslation {
+	t := &FXTranslation{Functional: functional, rates: map[string][]*FXRate{}, snapshots: map[string]*FXSnapshot{}}
+	for _, r := range rates {
+		key := r.From + "/" + r.To
+		t.rates[key] = append(t.rates[key], r)
+	}
+	for _, list := range t.rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+	}
+	for _, s := range snapshots {
+		t.snapshots[s.Kind+"/"+s.DocumentID] = s
+	}
+	return t
+}
+
+// LoadFXTranslation loads store's rates and snapshots for translation into
+// functional. It returns nil when functional is empty.
+func LoadFXTranslation(ctx context.Context, store FXRateStore, functional string) (*FXTranslation, error) {
+	if functional == "" {
+		return nil, nil
+	}
+	rates, err := store.ListFXRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list exchange rates: %w", err)
+	}
+	snapshots, err := store.ListFXSnapshots(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("list exchange rate snapshots: %w", err)
+	}
+	return NewFXTranslation(functional, rates, snapshots), nil
+}
+
+// Rate returns the rate of from into to on day on: the pair's latest rate on
+// or before it, else the inverse of the reverse pair's. The same currency
+// converts at 1.
+func (t *FXTranslation) Rate(from, to string, on time.Time) (*FXRate, bool) {
+	if from == to {
+		return &FXRate{From: from, To: to, Date: truncateDay(on), RateMicroUnits: FXRateScale}, true
+	}
+	if r := latestRate(t.rates[from+"/"+to], on); r != nil {
+		return r, true
+	}
+	if r := latestRate(t.rates[to+"/"+from], on); r != nil {
+		return &FXRate{From: from, To: to, Date: r.Date, RateMicroUnits: InvertFXRate(r.RateMicroUnits), Source: r.Source}, true
+	}
+	return nil, false
+}
+
+func latestRate(rates []*FXRate, on time.Time) *FXRate {
+	day := truncateDay(on)
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 {
+		return nil
+	}
+	return rates[i-1]
+}
+
+// Translate converts amount in currency into the functional currency at the
+// rate of day on. It returns amount unchanged and false when no rate is
+// known; an empty or functional currency, or a nil t, needs none.
+func (t *FXTranslation) Translate(currency string, amount int64, on time.Time) (int64, bool) {
+	if t == nil || currency == "" || currency == t.Functional {
+		return amount, true
+	}
+	r, ok := t.Rate(currency, t.Functional, on)
+	if !ok {
+		return amount, false
+	}
+	return ConvertFX(amount, r.RateMicroUnits), true
+}
+
+// BookedRate returns the rate document kind/id in currency was issued at: its
+// snapshot, or the rate of day on when it has none.
+func (t *FXTranslation) BookedRate(kind, id, currency string, on time.Time) (int64, bool) {
+	if t == nil || currency == "" || currency == t.Functional {
+		return FXRateScale, true
+	}
+	if s := t.snapshots[kind+"/"+id]; s != nil && s.Currency == currency && s.Functional == t.Functional {
+		return s.RateMicroUnits, true
+	}
+	r, ok := t.Rate(currency, t.Functional, on)
+	if !ok {
+		return 0, false
+	}
+	return r.RateMicroUnits, true
+}
+
+// Booked converts amount of document kind/id at the rate it was issued at
+// (see BookedRate), returning amount unchanged and false when none is known.
+func (t *FXTranslation) Booked(kind, id, currency string, amount int64, on time.Time) (int64, bool) {
+	rate, ok := t.BookedRate(kind, id, currency, on)
+	if !ok {
+		return amount, false
+	}
+	return ConvertFX(amount, rate), true
+}
+
+// FXRateLookup returns the rate of from into to on day on, or
+// ErrFXRateNotFound.
+type FXRateLookup func(ctx context.Context, from, to string, on time.Time) (*FXRate, error)
+
+// NewFXRateLookup returns an FXRateLookup over store's rates (see
+// FXTranslation.Rate).
+func NewFXRateLookup(store FXRateStore) FXRateLookup {
+	return func(ctx context.Context, from, to string, on time.Time) (*FXRate, error) {
+		rates, err := store.ListFXRates(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list exchange rates: %w", err)
+		}
+		r, ok := NewFXTranslation(to, rates, nil).Rate(from, to, on)
+		if !ok {
+			return nil, fmt.Errorf("%s/%s on %s: %w", from, to, on.Format("2006-01-02"), ErrFXRateNotFound)
+		}
+		return r, nil
+	}
+}
+
+// SnapshotFXRate records the rate document kind/id in currency is issued at:
+// the rate into functional of day on. A document keeps its first snapshot, so
+// re-issuing it does not move the rate. Documents in the functional currency,
+// or without one, need no snapshot and return nil.
+func SnapshotFXRate(ctx context.Context, store FXRateStore, lookup FXRateLookup, kind, id, currency, functional string, on time.Time) (*FXSnapshot, error) {
+	if currency == "" || functional == "" || currency == functional {
+		return nil, nil
+	}
+	existing, err := store.ReadFXSnapshot(ctx, kind, id)
+	if err != nil {
+		return nil, fmt.Errorf("read exchange rate snapshot: %w", err)
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	r, err := lookup(ctx, currency, functional, on)
+	if err != nil {
+		return nil, err
+	}
+	s := &FXSnapshot{
+		Kind:           kind,
+		DocumentID:     id,
+		Currency:       currency,
+		Functional:     functional,
+		RateMicroUnits: r.RateMicroUnits,
+		RateDate:       r.Date,
+		Source:         r.Source,
+		TakenAt:        time.Now(),
+	}
+	if err := store.SaveFXSnapshot(ctx, s); err != nil {
+		return nil, fmt.Errorf("save exchange rate snapshot: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,150 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFXRate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{"56.5", 56_500_000, false},
+		{" 0.017699 ", 17_699, false},
+		{"56.41234567", 56_412_346, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFXRate(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseFXRate(%q) = %d, %v", tt.in, got, err)
+		}
+	}
+	if s := FormatFXRate(56_500_000); s != "56.5000" {
+		t.Errorf("FormatFXRate = %q", s)
+	}
+	if s := FormatFXRate(17_699); s != "0.017699" {
+		t.Errorf("FormatFXRate = %q", s)
+	}
+}
+
+func TestConvertFX(t *testing.T) {
+	t.Parallel()
+
+	// USD 100.00 at 56.5 = PHP 5,650.00; USD 0.01 at 56.5 rounds 0.565 up.
+	if got := ConvertFX(10000, 56_500_000); got != 565000 {
+		t.Errorf("ConvertFX = %d", got)
+	}
+	if got := ConvertFX(1, 56_500_000); got != 57 {
+		t.Errorf("ConvertFX(1) = %d", got)
+	}
+	if got := ConvertFX(-1, 56_500_000); got != -57 {
+		t.Errorf("ConvertFX(-1) = %d", got)
+	}
+	if got := InvertFXRate(50_000_000); got != 20_000 {
+		t.Errorf("InvertFXRate = %d", got)
+	}
+	// Booked at 56.0, settled at 57.0: a PHP 10.00 gain per USD 10.00.
+	if got := RealizedFXGainLoss(1000, 56_000_000, 57_000_000); got != 1000 {
+		t.Errorf("RealizedFXGainLoss = %d", got)
+	}
+}
+
+func TestParseFXRatesCSV(t *testing.T) {
+	t.Parallel()
+
+	rates, err := ParseFXRatesCSV(strings.NewReader("Rate,Date,From,To,Note\n56.5,2026-03-02,usd,php,BSP\n\n0.6,2026-03-02,EUR,GBP,\n"), FXSourceImport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].ID() != "USD-PHP-2026-03-02" || rates[0].RateMicroUnits != 56_500_000 || rates[1].Source != FXSourceImport {
+		t.Fatalf("rates = %+v", rates)
+	}
+
+	_, err = ParseFXRatesCSV(strings.NewReader("date,from,to,rate\n2026-03-02,USD,PHP,56.5\n2026-03-03,USD,USD,1\n"), FXSourceImport)
+	if !errors.Is(err, ErrFXRateCurrency) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("same-currency error = %v", err)
+	}
+	if _, err := ParseFXRatesCSV(strings.NewReader("day,pair,rate\n"), FXSourceImport); !errors.Is(err, ErrFXRateCSVHeader) {
+		t.Errorf("header error = %v", err)
+	}
+}
+
+func TestFXTranslation(t *testing.T) {
+	t.Parallel()
+
+	tr := NewFXTranslation("PHP", []*FXRate{
+		{From: "USD", To: "PHP", Date: day("2026-03-10"), RateMicroUnits: 57_000_000},
+		{From: "USD", To: "PHP", Date: day("2026-03-01"), RateMicroUnits: 56_000_000},
+		{From: "PHP", To: "JPY", Date: day("2026-03-01"), RateMicroUnits: 2_500_000},
+	}, []*FXSnapshot{
+		{Kind: FXDocumentRevenue, DocumentID: "r1", Currency: "USD", Functional: "PHP", RateMicroUnits: 55_000_000},
+	})
+
+	tests := []struct {
+		currency string
+		on       string
+		want     int64
+		ok       bool
+	}{
+		{"USD", "2026-03-05", 5_600_000, true},
+		{"USD", "2026-03-10", 5_700_000, true},
+		{"USD", "2026-02-28", 100_000, false},
+		{"JPY", "2026-03-05", 40_000, true}, // inverse of PHP/JPY 2.5
+		{"PHP", "2026-03-05", 100_000, true},
+		{"EUR", "2026-03-05", 100_000, false},
+	}
+	for _, tt := range tests {
+		got, ok := tr.Translate(tt.currency, 100_000, day(tt.on))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Translate(%s, %s) = %d, %v; want %d, %v", tt.currency, tt.on, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if got, _ := tr.Booked(FXDocumentRevenue, "r1", "USD", 100_000, day("2026-03-20")); got != 5_500_000 {
+		t.Errorf("Booked with snapshot = %d", got)
+	}
+	if got, _ := tr.Booked(FXDocumentRevenue, "r2", "USD", 100_000, day("2026-03-20")); got != 5_700_000 {
+		t.Errorf("Booked without snapshot = %d", got)
+	}
+	var none *FXTranslation
+	if got, ok := none.Translate("USD", 100, day("2026-03-20")); got != 100 || !ok {
+		t.Errorf("nil Translate = %d, %v", got, ok)
+	}
+}
+
+func TestSnapshotFXRate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryFXRateStore()
+	_ = store.SaveFXRates(ctx, []*FXRate{{From: "USD", To: "PHP", Date: day("2026-03-01"), RateMicroUnits: 56_000_000, Source: FXSourceOperator}})
+	lookup := NewFXRateLookup(store)
+
+	s, err := SnapshotFXRate(ctx, store, lookup, FXDocumentRevenue, "r1", "USD", "PHP", day("2026-03-05"))
+	if err != nil || s == nil || s.RateMicroUnits != 56_000_000 || !s.RateDate.Equal(day("2026-03-01")) {
+		t.Fatalf("snapshot = %+v, %v", s, err)
+	}
+
+	// A later rate does not move the snapshot of an issued document.
+	_ = store.SaveFXRates(ctx, []*FXRate{{From: "USD", To: "PHP", Date: day("2026-03-06"), RateMicroUnits: 58_000_000}})
+	s, _ = SnapshotFXRate(ctx, store, lookup, FXDocumentRevenue, "r1", "USD", "PHP", day("2026-03-07"))
+	if s.RateMicroUnits != 56_000_000 {
+		t.Errorf("re-snapshot rate = %d", s.RateMicroUnits)
+	}
+
+	if s, err := SnapshotFXRate(ctx, store, lookup, FXDocumentCollection, "c1", "PHP", "PHP", day("2026-03-07")); s != nil || err != nil {
+		t.Errorf("functional snapshot = %+v, %v", s, err)
+	}
+	if _, err := SnapshotFXRate(ctx, store, lookup, FXDocumentCollection, "c2", "EUR", "PHP", day("2026-03-07")); !errors.Is(err, ErrFXRateNotFound) {
+		t.Errorf("missing rate error = %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	inventoryitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+)
+
+// ErrInsufficientStock is returned by an AdjustInventoryQuantity call whose
+// delta has RequireAvailable set and would take quantity_available below zero.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInventoryConflict is returned by an OptimisticInventoryAdjuster when the
+// item kept changing under it for maxInventoryAttempts tries.
+var ErrInventoryConflict = errors.New("inventory item changed concurrently")
+
+// maxInventoryAttempts bounds an OptimisticInventoryAdjuster's retries.
+const maxInventoryAttempts = 5
+
+// InventoryDelta is a signed change to one inventory item's quantities. Every
+// stock-mutating caller expresses its change as a delta instead of writing back
+// a value computed from an earlier read, so concurrent callers cannot lose each
+// other's updates.
+type InventoryDelta struct {
+	InventoryItemID string
+	OnHand          float64
+	Available       float64
+	Reserved        float64
+	// RequireAvailable rejects the whole delta with ErrInsufficientStock when
+	// it would take quantity_available below zero (reservations, sales).
+	RequireAvailable bool
+	// FloorOnHand clamps quantity_on_hand at zero instead of letting it go
+	// negative (write-offs entered from the transaction drawer).
+	FloorOnHand bool
+}
+
+// AdjustInventoryQuantity applies an InventoryDelta atomically and returns the
+// item as stored after the change. The backing implementation is a single
+// conditional UPDATE (quantity = quantity + delta, guarded by
+// quantity_available + delta >= 0 when RequireAvailable is set); it is fed at
+// composition time from the inventory use cases.
+type AdjustInventoryQuantity func(ctx context.Context, delta InventoryDelta) (*inventoryitempb.InventoryItem, error)
+
+// UpdateInventoryItemIfUnchanged writes next only while the stored item's
+// date_modified still equals version, reporting false (and writing nothing)
+// when another write got there first. The backing implementation is a single
+// UPDATE ... WHERE id = ? AND date_modified = ?.
+type UpdateInventoryItemIfUnchanged func(ctx context.Context, next *inventoryitempb.InventoryItem, version int64) (bool, error)
+
+// OptimisticInventoryAdjuster applies deltas without an atomic adjust: it
+// reads the item, computes the change and writes it with updateIf against the
+// version it read, retrying from the read when the item changed in between.
+// Unlike SerializedInventoryAdjuster it is safe across processes. Returns nil
+// when read or updateIf is nil.
+func OptimisticInventoryAdjuster(
+	read func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error),
+	updateIf UpdateInventoryItemIfUnchanged,
+) AdjustInventoryQuantity {
+	if read == nil || updateIf == nil {
+		return nil
+	}
+	return func(ctx context.Context, delta InventoryDelta) (*inventoryitempb.InventoryItem, error) {
+		if delta.InventoryItemID == "" {
+			return nil, fmt.Errorf("adjust inventory: missing inventory item id")
+		}
+		for attempt := 1; attempt <= maxInventoryAttempts; attempt++ {
+			current, err := readInventoryItem(ctx, read, delta.InventoryItemID)
+			if err != nil {
+				return nil, err
+			}
+			next, err := ApplyInventoryDelta(current, delta)
+			if err != nil {
+				return nil, err
+			}
+			// The version must move even when two writes land in the same
+			// millisecond, or a stale writer would still match.
+			if version := current.GetDateModified(); next.GetDateModified() <= version {
+				next.DateModified = ptrOf(version + 1)
+			}
+			ok, err := updateIf(ctx, next, current.GetDateModified())
+			if err != nil {
+				return nil, fmt.Errorf("update inventory item %s: %w", delta.InventoryItemID, err)
+			}
+			if ok {
+				return next, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("%w: inventory item %s after %d attempts", ErrInventoryConflict, delta.InventoryItemID, maxInventoryAttempts)
+	}
+}
+
+// InventoryAdjuster returns adjust when the backend provides one, otherwise a
+// SerializedInventoryAdjuster over read + update. It returns nil when neither
+// path is wired, so callers keep their nil-guarded "no stock tracking" branch.
+// Compositions with a conditional update should pass an
+// OptimisticInventoryAdjuster as adjust.
+func InventoryAdjuster(
+	adjust AdjustInventoryQuantity,
+	read func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error),
+	update func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error),
+) AdjustInventoryQuantity {
+	if adjust != nil {
+		return adjust
+	}
+	return SerializedInventoryAdjuster(read, update)
+}
+
+// inventoryLocks holds one mutex per inventory item ID for the serialized
+// fallback. It is process-wide so every module in the binary shares it.
+var inventoryLocks sync.Map
+
+// serializedWarning logs once that the process-local fallback is in use.
+var serializedWarning sync.Once
+
+// SerializedInventoryAdjuster is the fallback used when the backend has no
+// atomic adjust: it re-reads the item and writes the adjusted quantities while
+// holding a per-item lock. That removes lost updates between callers in the
+// same process, but NOT across processes — multi-instance deployments must
+// wire a real AdjustInventoryQuantity. Returns nil when read or update is nil.
+func SerializedInventoryAdjuster(
+	read func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error),
+	update func(ctx context.Context, req *inventoryitempb.UpdateInventoryItemRequest) (*inventoryitempb.UpdateInventoryItemResponse, error),
+) AdjustInventoryQuantity {
+	if read == nil || update == nil {
+		return nil
+	}
+	serializedWarning.Do(func() {
+		log.Printf("inventory: WARNING neither AdjustInventoryQuantity nor UpdateInventoryItemIfUnchanged is wired; " +
+			"stock adjustments are serialized within this process only and can lose updates across instances")
+	})
+	return func(ctx context.Context, delta InventoryDelta) (*inventoryitempb.InventoryItem, error) {
+		if delta.InventoryItemID == "" {
+			return nil, fmt.Errorf("adjust inventory: missing inventory item id")
+		}
+		lock, _ := inventoryLocks.LoadOrStore(delta.InventoryItemID, &sync.Mutex{})
+		mu := lock.(*sync.Mutex)
+		mu.Lock()
+		defer mu.Unlock()
+
+		current, err := readInventoryItem(ctx, read, delta.InventoryItemID)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := ApplyInventoryDelta(current, delta)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := update(ctx, &inventoryitempb.UpdateInventoryItemRequest{Data: next}); err != nil {
+			return nil, fmt.Errorf("update inventory item %s: %w", delta.InventoryItemID, err)
+		}
+		return next, nil
+	}
+}
+
+// readInventoryItem reads the item with id.
+func readInventoryItem(
+	ctx context.Context,
+	read func(ctx context.Context, req *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error),
+	id string,
+) (*inventoryitempb.InventoryItem, error) {
+	resp, err := read(ctx, &inventoryitempb.ReadInventoryItemRequest{
+		Data: &inventoryitempb.InventoryItem{Id: id},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read inventory item %s: %w", id, err)
+	}
+	if len(resp.GetData()) == 0 {
+		return nil, fmt.Errorf("inventory item %s not found", id)
+	}
+	return resp.GetData()[0], nil
+}
+
+// ApplyInventoryDelta computes the item that results from applying delta to
+// current, enforcing RequireAvailable and FloorOnHand. It does not write
+// anything; backends and test doubles use it to share the delta semantics.
+func ApplyInventoryDelta(current *inventoryitempb.InventoryItem, delta InventoryDelta) (*inventoryitempb.InventoryItem, error) {
+	available := current.GetQuantityAvailable() + delta.Available
+	if delta.RequireAvailable && delta.Available < 0 && available < 0 {
+		return nil, fmt.Errorf("%w: inventory item %s has %v available, needs %v",
+			ErrInsufficientStock, current.GetId(), current.GetQuantityAvailable(), -delta.Available)
+	}
+	onHand := current.GetQuantityOnHand() + delta.OnHand
+	if delta.FloorOnHand && onHand < 0 {
+		onHand = 0
+	}
+
+	now := time.Now()
+	return &inventoryitempb.InventoryItem{
+		Id:                 current.GetId(),
+		ProductId:          current.ProductId,
+		LocationId:         current.LocationId,
+		ProductVariantId:   current.ProductVariantId,
+		QuantityOnHand:     onHand,
+		QuantityAvailable:  available,
+		QuantityReserved:   current.GetQuantityReserved() + delta.Reserved,
+		Active:             current.GetActive(),
+		DateModified:       ptrOf(now.UnixMilli()),
+		DateModifiedString: ptrOf(now.Format(time.RFC3339)),
+	}, nil
+}
+
+func ptrOf[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,86 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	inventoryitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	"google.golang.org/protobuf/proto"
+)
+
+// versionedInventory is one inventory item behind a conditional update keyed
+// on date_modified, as a backend would implement it.
+type versionedInventory struct {
+	mu     sync.Mutex
+	item   *inventoryitempb.InventoryItem
+	writes int
+}
+
+func (v *versionedInventory) read(_ context.Context, _ *inventoryitempb.ReadInventoryItemRequest) (*inventoryitempb.ReadInventoryItemResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return &inventoryitempb.ReadInventoryItemResponse{Data: []*inventoryitempb.InventoryItem{proto.Clone(v.item).(*inventoryitempb.InventoryItem)}}, nil
+}
+
+func (v *versionedInventory) updateIf(_ context.Context, next *inventoryitempb.InventoryItem, version int64) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.item.GetDateModified() != version {
+		return false, nil
+	}
+	v.item = proto.Clone(next).(*inventoryitempb.InventoryItem)
+	v.writes++
+	return true, nil
+}
+
+func TestOptimisticInventoryAdjuster(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store := &versionedInventory{item: &inventoryitempb.InventoryItem{
+		Id: "inv-1", QuantityOnHand: 100, QuantityAvailable: 100, DateModified: ptrOf(int64(1)),
+	}}
+	adjust := OptimisticInventoryAdjuster(store.read, store.updateIf)
+
+	// Concurrent reservations retry on conflict instead of losing updates.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				_, err := adjust(ctx, InventoryDelta{InventoryItemID: "inv-1", Available: -1, Reserved: 1, RequireAvailable: true})
+				if !errors.Is(err, ErrInventoryConflict) {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("adjust: %v", err)
+		}
+	}
+	if got := store.item; got.GetQuantityAvailable() != 80 || got.GetQuantityReserved() != 20 || store.writes != 20 {
+		t.Errorf("available/reserved = %v/%v after %d writes, want 80/20 after 20",
+			got.GetQuantityAvailable(), got.GetQuantityReserved(), store.writes)
+	}
+
+	if _, err := adjust(ctx, InventoryDelta{InventoryItemID: "inv-1", Available: -81, RequireAvailable: true}); !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("over-reserve: err = %v, want ErrInsufficientStock", err)
+	}
+
+	// An item that changes on every attempt gives up with ErrInventoryConflict.
+	busy := OptimisticInventoryAdjuster(store.read, func(context.Context, *inventoryitempb.InventoryItem, int64) (bool, error) {
+		return false, nil
+	})
+	if _, err := busy(ctx, InventoryDelta{InventoryItemID: "inv-1", Available: 1}); !errors.Is(err, ErrInventoryConflict) {
+		t.Errorf("always conflicting: err = %v, want ErrInventoryConflict", err)
+	}
+}
@@ -0,0 +1,304 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Payment allocation errors, returned so views can map them to labels.
+var (
+	ErrAllocationEmpty          = errors.New("allocate an amount to at least one invoice")
+	ErrAllocationAmount         = errors.New("allocated amounts cannot be negative")
+	ErrAllocationInvoice        = errors.New("allocations must be to distinct open invoices of the client in the collection's currency")
+	ErrAllocationOverBalance    = errors.New("an allocation cannot exceed the invoice's balance")
+	ErrAllocationOverUnapplied  = errors.New("allocations cannot exceed the collection's unapplied amount")
+	ErrAllocationReversed       = errors.New("the allocation was already reversed")
+	ErrAllocationNotAllocatable = errors.New("only completed collections from a client that are not tied to an invoice can be allocated")
+)
+
+// PaymentAllocation applies part of a treasury collection to one invoice
+// through the revenue payment it created. Reversing an allocation deletes its
+// payment and keeps the allocation, with ReversedAt set, as history.
+type PaymentAllocation struct {
+	ID               string
+	CollectionID     string
+	ClientID         string
+	RevenueID        string
+	Reference        string // the invoice's reference
+	RevenuePaymentID string
+	Currency         string
+	Amount           int64
+	AllocatedAt      time.Time
+	AllocatedBy      string
+	ReversedAt       time.Time
+	ReversedBy       string
+}
+
+// Reversed reports whether the allocation was reversed.
+func (a *PaymentAllocation) Reversed() bool {
+	return !a.ReversedAt.IsZero()
+}
+
+// PaymentAllocationStore persists payment allocations. The consumer app
+// scopes every call to the request's workspace.
+type PaymentAllocationStore interface {
+	// SavePaymentAllocation inserts the allocation, or replaces the one
+	// with the same ID.
+	SavePaymentAllocation(ctx context.Context, allocation *PaymentAllocation) error
+	// InsertPaymentAllocation inserts a new allocation only while its
+	// collection's applied allocations, this one included, stay within
+	// limits.Collection and its invoice's applied allocations, from every
+	// collection, stay within limits.Invoice; otherwise it returns
+	// ErrAllocationOverUnapplied or ErrAllocationOverBalance. The checks and
+	// the insert must be atomic (a transaction or a conditional insert), so
+	// concurrent allocations cannot over-apply a collection or overpay an
+	// invoice.
+	InsertPaymentAllocation(ctx context.Context, allocation *PaymentAllocation, limits AllocationLimits) error
+	// ReadPaymentAllocation returns the allocation, or nil when there is none.
+	ReadPaymentAllocation(ctx context.Context, id string) (*PaymentAllocation, error)
+	// ReadPaymentAllocationByPayment returns the allocation that is applied
+	// through the revenue payment, or nil when the payment is not one
+	// (reversed allocations are ignored).
+	ReadPaymentAllocationByPayment(ctx context.Context, revenuePaymentID string) (*PaymentAllocation, error)
+	// ListPaymentAllocations returns collectionID's allocations ("" = every
+	// collection's), oldest first, reversed ones included.
+	ListPaymentAllocations(ctx context.Context, collectionID string) ([]*PaymentAllocation, error)
+}
+
+// AllocationLimits caps the applied allocations InsertPaymentAllocation may
+// leave behind, in centavos: Collection over the allocation's collection,
+// Invoice over its invoice.
+type AllocationLimits struct {
+	Collection int64
+	Invoice    int64
+}
+
+// InvoiceAllocationLimit returns the cap on the applied allocations to an
+// invoice: its balance as read plus what was applied to it by then. An
+// allocation made after the read counts against the cap, so it leaves less
+// for the next one.
+func InvoiceAllocationLimit(balance int64, revenueID string, allocations []*PaymentAllocation) int64 {
+	for _, a := range allocations {
+		if a.RevenueID == revenueID && !a.Reversed() {
+			balance += a.Amount
+		}
+	}
+	return balance
+}
+
+// MemoryPaymentAllocationStore is an in-process PaymentAllocationStore for
+// mock builds and tests.
+type MemoryPaymentAllocationStore struct {
+	mu          sync.Mutex
+	allocations []*PaymentAllocation
+}
+
+// NewMemoryPaymentAllocationStore returns an empty MemoryPaymentAllocationStore.
+func NewMemoryPaymentAllocationStore() *MemoryPaymentAllocationStore {
+	return &MemoryPaymentAllocationStore{}
+}
+
+// SavePaymentAllocation stores a copy of allocation.
+func (m *MemoryPaymentAllocationStore) SavePaymentAllocation(_ context.Context, allocation *PaymentAllocation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *allocation
+	for i, a := range m.allocations {
+		if a.ID == allocation.ID {
+			m.allocations[i] = &cp
+			return nil
+		}
+	}
+	m.allocations = append(m.allocations, &cp)
+	return nil
+}
+
+// InsertPaymentAllocation stores a copy of allocation when its collection's
+// and its invoice's applied amounts, with it, are within limits.
+func (m *MemoryPaymentAllocationStore) InsertPaymentAllocation(_ context.Context, allocation *PaymentAllocation, limits AllocationLimits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collection, invoice := allocation.Amount, allocation.Amount
+	for _, a := range m.allocations {
+		if a.Reversed() {
+			continue
+		}
+		if a.CollectionID == allocation.CollectionID {
+			collection += a.Amount
+		}
+		if a.RevenueID == allocation.RevenueID {
+			invoice += a.Amount
+		}
+	}
+	if collection > limits.Collection {
+		return ErrAllocationOverUnapplied
+	}
+	if invoice > limits.Invoice {
+		return ErrAllocationOverBalance
+	}
+	cp := *allocation
+	m.allocations = append(m.allocations, &cp)
+	return nil
+}
+
+// ReadPaymentAllocation returns a copy of the allocation with id.
+func (m *MemoryPaymentAllocationStore) ReadPaymentAllocation(_ context.Context, id string) (*PaymentAllocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.allocations {
+		if a.ID == id {
+			cp := *a
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+// ReadPaymentAllocationByPayment returns a copy of the applied allocation
+// made through revenuePaymentID.
+func (m *MemoryPaymentAllocationStore) ReadPaymentAllocationByPayment(_ context.Context, revenuePaymentID string) (*PaymentAllocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range m.allocations {
+		if a.RevenuePaymentID == revenuePaymentID && !a.Reversed() {
+			cp := *a
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListPaymentAllocations returns copies of collectionID's allocations in the
+// order they were made.
+func (m *MemoryPaymentAllocationStore) ListPaymentAllocations(_ context.Context, collectionID string) ([]*PaymentAllocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*PaymentAllocation
+	for _, a := range m.allocations {
+		if collectionID == "" || a.CollectionID == collectionID {
+			cp := *a
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+// AppliedAmount sums the allocations that were not reversed.
+func AppliedAmount(allocations []*PaymentAllocation) int64 {
+	var sum int64
+	for _, a := range allocations {
+		if !a.Reversed() {
+			sum += a.Amount
+		}
+	}
+	return sum
+}
+
+// AppliedByCollection sums each collection's allocations that were not
+// reversed.
+func AppliedByCollection(allocations []*PaymentAllocation) map[string]int64 {
+	sums := map[string]int64{}
+	for _, a := range allocations {
+		if !a.Reversed() {
+			sums[a.CollectionID] += a.Amount
+		}
+	}
+	return sums
+}
+
+// AppliedByCollectionAsOf sums each collection's allocations as they stood
+// just before end: made before it and not reversed by then.
+func AppliedByCollectionAsOf(allocations []*PaymentAllocation, end time.Time) map[string]int64 {
+	sums := map[string]int64{}
+	for _, a := range allocations {
+		if !a.AllocatedAt.Before(end) || (a.Reversed() && a.ReversedAt.Before(end)) {
+			continue
+		}
+		sums[a.CollectionID] += a.Amount
+	}
+	return sums
+}
+
+// AllocationLine is the amount, in centavos, to apply to one invoice.
+type AllocationLine struct {
+	RevenueID string
+	Amount    int64
+}
+
+// OldestFirst returns a copy of open sorted oldest first: by due date, then
+// invoice date, then reference.
+func OldestFirst(open []*AgingLine) []*AgingLine {
+	out := append([]*AgingLine(nil), open...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if !a.DueDate.Equal(b.DueDate) {
+			return a.DueDate.Before(b.DueDate)
+		}
+		if !a.InvoiceDate.Equal(b.InvoiceDate) {
+			return a.InvoiceDate.Before(b.InvoiceDate)
+		}
+		return a.Reference < b.Reference
+	})
+	return out
+}
+
+// AllocateOldestFirst spreads amount over the open invoices oldest first,
+// paying each in full before the next. What is left over once every invoice
+// is paid stays unapplied.
+func AllocateOldestFirst(amount int64, open []*AgingLine) []AllocationLine {
+	var lines []AllocationLine
+	for _, inv := range OldestFirst(open) {
+		if amount <= 0 {
+			break
+		}
+		if inv.Balance <= 0 {
+			continue
+		}
+		apply := min(inv.Balance, amount)
+		lines = append(lines, AllocationLine{RevenueID: inv.RevenueID, Amount: apply})
+		amount -= apply
+	}
+	return lines
+}
+
+// ValidateAllocation checks lines against the open invoices and the
+// collection's unapplied amount, and returns the lines with an amount. Each
+// line must name a distinct open invoice and stay within its balance; the
+// lines together must stay within unapplied.
+func ValidateAllocation(unapplied int64, open []*AgingLine, lines []AllocationLine) ([]AllocationLine, error) {
+	balances := make(map[string]int64, len(open))
+	for _, inv := range open {
+		balances[inv.RevenueID] = inv.Balance
+	}
+	seen := map[string]bool{}
+	var out []AllocationLine
+	var total int64
+	for _, l := range lines {
+		if l.Amount < 0 {
+			return nil, ErrAllocationAmount
+		}
+		if l.Amount == 0 {
+			continue
+		}
+		balance, ok := balances[l.RevenueID]
+		if !ok || seen[l.RevenueID] {
+			return nil, ErrAllocationInvoice
+		}
+		if l.Amount > balance {
+			return nil, ErrAllocationOverBalance
+		}
+		seen[l.RevenueID] = true
+		total += l.Amount
+		out = append(out, l)
+	}
+	if len(out) == 0 {
+		return nil, ErrAllocationEmpty
+	}
+	if total > unapplied {
+		return nil, ErrAllocationOverUnapplied
+	}
+	return out, nil
+}
@@ -0,0 +1,213 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openInvoice(id string, due string, balance int64) *AgingLine {
+	d, _ := time.Parse("2006-01-02", due)
+	return &AgingLine{
+		ReceivableInvoice: ReceivableInvoice{RevenueID: id, Reference: "INV-" + id, InvoiceDate: d.AddDate(0, 0, -30), DueDate: d},
+		Balance:           balance,
+	}
+}
+
+func TestAllocateOldestFirst(t *testing.T) {
+	t.Parallel()
+
+	open := []*AgingLine{
+		openInvoice("c", "2026-03-31", 30000),
+		openInvoice("a", "2026-01-31", 10000),
+		openInvoice("b", "2026-02-28", 20000),
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		want   []AllocationLine
+	}{
+		{"partial", 25000, []AllocationLine{{"a", 10000}, {"b", 15000}}},
+		{"exact", 60000, []AllocationLine{{"a", 10000}, {"b", 20000}, {"c", 30000}}},
+		{"overpaid leaves remainder", 70000, []AllocationLine{{"a", 10000}, {"b", 20000}, {"c", 30000}}},
+		{"nothing", 0, nil},
+	}
+	for _, tt := range tests {
+		if got := AllocateOldestFirst(tt.amount, open); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if open[0].RevenueID != "c" {
+		t.Error("AllocateOldestFirst reordered its input")
+	}
+}
+
+func TestValidateAllocation(t *testing.T) {
+	t.Parallel()
+
+	open := []*AgingLine{openInvoice("a", "2026-01-31", 10000), openInvoice("b", "2026-02-28", 20000)}
+
+	got, err := ValidateAllocation(25000, open, []AllocationLine{{"a", 10000}, {"b", 0}, {"b", 5000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []AllocationLine{{"a", 10000}, {"b", 5000}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		unapplied int64
+		lines     []AllocationLine
+		want      error
+	}{
+		{"empty", 25000, []AllocationLine{{"a", 0}}, ErrAllocationEmpty},
+		{"negative", 25000, []AllocationLine{{"a", -1}}, ErrAllocationAmount},
+		{"unknown invoice", 25000, []AllocationLine{{"z", 100}}, ErrAllocationInvoice},
+		{"duplicate invoice", 25000, []AllocationLine{{"a", 100}, {"a", 100}}, ErrAllocationInvoice},
+		{"over balance", 25000, []AllocationLine{{"a", 10001}}, ErrAllocationOverBalance},
+		{"over unapplied", 25000, []AllocationLine{{"a", 10000}, {"b", 15001}}, ErrAllocationOverUnapplied},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateAllocation(tt.unapplied, open, tt.lines); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestMemoryPaymentAllocationStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store := NewMemoryPaymentAllocationStore()
+	for _, a := range []*PaymentAllocation{
+		{ID: "1", CollectionID: "col-1", RevenueID: "a", Amount: 10000},
+		{ID: "2", CollectionID: "col-1", RevenueID: "b", Amount: 5000},
+		{ID: "3", CollectionID: "col-2", RevenueID: "c", Amount: 700},
+	} {
+		if err := store.SavePaymentAllocation(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := store.ReadPaymentAllocation(ctx, "2")
+	if err != nil || a == nil {
+		t.Fatalf("read: %v, %v", a, err)
+	}
+	a.ReversedAt = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.SavePaymentAllocation(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.ListPaymentAllocations(ctx, "col-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "1" || !list[1].Reversed() {
+		t.Fatalf("unexpected allocations: %+v", list)
+	}
+	if got := AppliedAmount(list); got != 10000 {
+		t.Errorf("applied = %d, want 10000", got)
+	}
+
+	all, _ := store.ListPaymentAllocations(ctx, "")
+	if got := AppliedByCollection(all); !reflect.DeepEqual(got, map[string]int64{"col-1": 10000, "col-2": 700}) {
+		t.Errorf("applied by collection = %v", got)
+	}
+	if missing, _ := store.ReadPaymentAllocation(ctx, "9"); missing != nil {
+		t.Errorf("read missing = %+v, want nil", missing)
+	}
+}
+
+func TestMemoryPaymentAllocationStore_InsertWithinLimit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store := NewMemoryPaymentAllocationStore()
+	// Concurrent allocations of 4000 against a 10000 collection: only two fit.
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			errs <- store.InsertPaymentAllocation(ctx, &PaymentAllocation{
+				ID: string(rune('a' + i)), CollectionID: "col-1", RevenuePaymentID: "pay-" + string(rune('a'+i)), Amount: 4000,
+			}, AllocationLimits{Collection: 10000, Invoice: math.MaxInt64})
+		}(i)
+	}
+	inserted := 0
+	for i := 0; i < 5; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			inserted++
+		case !errors.Is(err, ErrAllocationOverUnapplied):
+			t.Errorf("insert: %v", err)
+		}
+	}
+	list, _ := store.ListPaymentAllocations(ctx, "col-1")
+	if inserted != 2 || AppliedAmount(list) != 8000 {
+		t.Fatalf("inserted %d, applied %d; want 2 and 8000", inserted, AppliedAmount(list))
+	}
+
+	a := list[0]
+	if got, _ := store.ReadPaymentAllocationByPayment(ctx, a.RevenuePaymentID); got == nil || got.ID != a.ID {
+		t.Errorf("by payment = %+v, want %s", got, a.ID)
+	}
+	a.ReversedAt = time.Now()
+	if err := store.SavePaymentAllocation(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.ReadPaymentAllocationByPayment(ctx, a.RevenuePaymentID); got != nil {
+		t.Errorf("by payment after reversal = %+v, want nil", got)
+	}
+	if err := store.InsertPaymentAllocation(ctx, &PaymentAllocation{ID: "z", CollectionID: "col-1", Amount: 6000}, AllocationLimits{Collection: 10000, Invoice: math.MaxInt64}); err != nil {
+		t.Errorf("insert into the reversed amount: %v", err)
+	}
+}
+
+func TestMemoryPaymentAllocationStore_InsertWithinInvoiceLimit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store := NewMemoryPaymentAllocationStore()
+	// Five collections read the same 10000 balance on inv-1 and each try to
+	// pay 4000 of it at once: only two fit.
+	limit := InvoiceAllocationLimit(10000, "inv-1", nil)
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			errs <- store.InsertPaymentAllocation(ctx, &PaymentAllocation{
+				ID: string(rune('a' + i)), CollectionID: "col-" + string(rune('a'+i)), RevenueID: "inv-1", Amount: 4000,
+			}, AllocationLimits{Collection: 4000, Invoice: limit})
+		}(i)
+	}
+	inserted := 0
+	for i := 0; i < 5; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			inserted++
+		case !errors.Is(err, ErrAllocationOverBalance):
+			t.Errorf("insert: %v", err)
+		}
+	}
+	list, _ := store.ListPaymentAllocations(ctx, "")
+	if inserted != 2 || AppliedAmount(list) != 8000 {
+		t.Fatalf("inserted %d, applied %d; want 2 and 8000", inserted, AppliedAmount(list))
+	}
+
+	// A later read sees 2000 left on top of the 8000 applied.
+	limit = InvoiceAllocationLimit(2000, "inv-1", list)
+	if limit != 10000 {
+		t.Fatalf("limit = %d, want 10000", limit)
+	}
+	if err := store.InsertPaymentAllocation(ctx, &PaymentAllocation{ID: "y", CollectionID: "col-y", RevenueID: "inv-1", Amount: 3000}, AllocationLimits{Collection: 3000, Invoice: limit}); !errors.Is(err, ErrAllocationOverBalance) {
+		t.Errorf("insert over the balance = %v, want ErrAllocationOverBalance", err)
+	}
+	if err := store.InsertPaymentAllocation(ctx, &PaymentAllocation{ID: "z", CollectionID: "col-z", RevenueID: "inv-1", Amount: 2000}, AllocationLimits{Collection: 2000, Invoice: limit}); err != nil {
+		t.Errorf("insert of the balance: %v", err)
+	}
+}
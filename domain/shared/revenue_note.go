@@ -0,0 +1,375 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+// Revenue note kinds. A note is its own revenue row, numbered from its own
+// sequence, that corrects a completed invoice without reopening it: a credit
+// note lowers what the customer owes, a debit note raises it.
+const (
+	RevenueNoteCredit = "credit_note"
+	RevenueNoteDebit  = "debit_note"
+)
+
+// Revenue note errors, returned by BuildRevenueNote (and ErrRevenueNoteConflict
+// by the note action) so views can map them to labels.
+var (
+	ErrRevenueNoteNotAllowed  = errors.New("notes can only be issued against a completed invoice")
+	ErrRevenueNoteEmpty       = errors.New("no line items selected for the note")
+	ErrRevenueNoteOverCredit  = errors.New("credited quantity exceeds the quantity left on the invoice")
+	ErrRevenueNoteInvalidKind = errors.New("invalid revenue note kind")
+	ErrRevenueNoteConflict    = errors.New("the invoice changed while the note was being issued")
+)
+
+// RevenueNotePrefix returns the reference prefix of kind's numbering sequence
+// ("CN" or "DN"), or "" for an unknown kind.
+func RevenueNotePrefix(kind string) string {
+	switch kind {
+	case RevenueNoteCredit:
+		return "CN"
+	case RevenueNoteDebit:
+		return "DN"
+	}
+	return ""
+}
+
+// RevenueNoteKindOf returns the note kind a reference number was issued from,
+// or "" when it is not a note reference (an invoice or storefront order).
+func RevenueNoteKindOf(reference string) string {
+	for _, kind := range []string{RevenueNoteCredit, RevenueNoteDebit} {
+		if strings.HasPrefix(reference, RevenueNotePrefix(kind)+"-") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// RevenueNoteLine is one line copied from the original invoice. Quantity and
+// Total carry the note's sign: negative on credit notes, positive on debit
+// notes.
+type RevenueNoteLine struct {
+	SourceLineItemID  string
+	Description       string
+	ProductID         string
+	InventoryItemID   string
+	InventorySerialID string
+	Quantity          float64
+	UnitPrice         int64 // centavos, as on the original line
+	Total             int64 // centavos, signed
+}
+
+// RevenueNote links a note's revenue row to the invoice it corrects. Amounts
+// are signed centavos like the note's lines.
+type RevenueNote struct {
+	RevenueID         string
+	Kind              string
+	ReferenceNumber   string
+	OriginalRevenueID string
+	OriginalReference string
+	Reason            string
+	Lines             []RevenueNoteLine
+	Subtotal          int64
+	Surcharge         int64
+	Withholding       int64
+	// TaxInclusive is copied from the original invoice: line totals already
+	// contain surcharge taxes.
+	TaxInclusive bool
+	// Restock returns credited inventory to stock (credit notes only).
+	Restock  bool
+	IssuedAt time.Time
+}
+
+// Total is the note's signed grand total: subtotal plus surcharge taxes unless
+// the invoice priced tax-inclusive.
+func (n *RevenueNote) Total() int64 {
+	if n.TaxInclusive {
+		return n.Subtotal
+	}
+	return n.Subtotal + n.Surcharge
+}
+
+// CashDelta is the signed change the note makes to the cash the customer is
+// expected to pay on the original invoice (total less withholding).
+func (n *RevenueNote) CashDelta() int64 {
+	return n.Total() - n.Withholding
+}
+
+// Entry is the note as a dated change to the original invoice's balance.
+// Notes never rewrite the original, so this is the only place their
+// adjustment is counted.
+func (n *RevenueNote) Entry() ReceivableEntry {
+	return ReceivableEntry{RevenueID: n.OriginalRevenueID, Reference: n.ReferenceNumber, Amount: n.CashDelta(), At: n.IssuedAt}
+}
+
+// RevenueCashExpected returns the cash an issued revenue asks the customer
+// for: its cash_amount_expected, or its total when taxes were never computed
+// for it. A stored zero is kept — it is an amount, not a missing one.
+func RevenueCashExpected(rv *revenuepb.Revenue) int64 {
+	if rv.CashAmountExpected != nil {
+		return rv.GetCashAmountExpected()
+	}
+	return rv.GetTotalAmount()
+}
+
+// RevenueNoteStore keeps the note → invoice links. esqyma's revenue has no
+// column for the original invoice, so the consumer app persists these next to
+// the revenue rows.
+type RevenueNoteStore interface {
+	SaveRevenueNote(ctx context.Context, note *RevenueNote) error
+	// ListRevenueNotes returns the notes issued against an invoice, oldest
+	// first.
+	ListRevenueNotes(ctx context.Context, originalRevenueID string) ([]*RevenueNote, error)
+	// ReadRevenueNote returns the note whose own revenue row is revenueID, or
+	// nil when revenueID is not a note.
+	ReadRevenueNote(ctx context.Context, revenueID string) (*RevenueNote, error)
+	// DeleteRevenueNote removes the note whose own revenue row is revenueID;
+	// used to undo a note that could not be issued.
+	DeleteRevenueNote(ctx context.Context, revenueID string) error
+}
+
+// UpdateRevenueIfUnchanged writes next only while the stored revenue's
+// date_modified still equals version, reporting false (and writing nothing)
+// when another write got there first. The backing implementation is a single
+// UPDATE ... WHERE id = ? AND date_modified = ?.
+type UpdateRevenueIfUnchanged func(ctx context.Context, next *revenuepb.Revenue, version int64) (bool, error)
+
+// MemoryRevenueNoteStore is an in-process RevenueNoteStore for mock builds and
+// tests.
+type MemoryRevenueNoteStore struct {
+	mu    sync.Mutex
+	notes []*RevenueNote
+}
+
+// NewMemoryRevenueNoteStore returns an empty MemoryRevenueNoteStore.
+func NewMemoryRevenueNoteStore() *MemoryRevenueNoteStore {
+	return &MemoryRevenueNoteStore{}
+}
+
+// SaveRevenueNote stores a copy of note.
+func (m *MemoryRevenueNoteStore) SaveRevenueNote(_ context.Context, note *RevenueNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *note
+	cp.Lines = append([]RevenueNoteLine(nil), note.Lines...)
+	m.notes = append(m.notes, &cp)
+	return nil
+}
+
+// ListRevenueNotes returns the notes issued against originalRevenueID.
+func (m *MemoryRevenueNoteStore) ListRevenueNotes(_ context.Context, originalRevenueID string) ([]*RevenueNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*RevenueNote
+	for _, n := range m.notes {
+		if n.OriginalRevenueID == originalRevenueID {
+			out = append(out, n)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].IssuedAt.Before(out[j].IssuedAt) })
+	return out, nil
+}
+
+// ReadRevenueNote returns the note stored for revenueID, or nil.
+func (m *MemoryRevenueNoteStore) ReadRevenueNote(_ context.Context, revenueID string) (*RevenueNote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, n := range m.notes {
+		if n.RevenueID == revenueID {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteRevenueNote removes the note stored for revenueID.
+func (m *MemoryRevenueNoteStore) DeleteRevenueNote(_ context.Context, revenueID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, n := range m.notes {
+		if n.RevenueID == revenueID {
+			m.notes = append(m.notes[:i], m.notes[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// RevenueNoteRequest is what the operator picked in the note drawer.
+// Quantities are positive amounts per original line item ID.
+type RevenueNoteRequest struct {
+	Kind       string
+	Quantities map[string]float64
+	Reason     string
+	Restock    bool
+}
+
+// NoteableLineItems returns the original invoice lines a note may copy: item
+// lines only, since discounts are already folded into the line totals.
+func NoteableLineItems(items []*revenuelineitempb.RevenueLineItem) []*revenuelineitempb.RevenueLineItem {
+	var out []*revenuelineitempb.RevenueLineItem
+	for _, item := range items {
+		if item.GetLineItemType() == "discount" || item.GetQuantity() <= 0 {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// CreditableQuantities returns, per original line item ID, the quantity not
+// yet credited by earlier credit notes.
+func CreditableQuantities(items []*revenuelineitempb.RevenueLineItem, notes []*RevenueNote) map[string]float64 {
+	left := map[string]float64{}
+	for _, item := range NoteableLineItems(items) {
+		left[item.GetId()] += item.GetQuantity()
+	}
+	for _, n := range notes {
+		if n.Kind != RevenueNoteCredit {
+			continue
+		}
+		for _, l := range n.Lines {
+			left[l.SourceLineItemID] += l.Quantity // negative on credit notes
+		}
+	}
+	return left
+}
+
+// BuildRevenueNote prices a note against original: each selected line is
+// copied at the share of its original total, and each original tax line is
+// prorated over the copied amounts. It returns the note (without RevenueID,
+// ReferenceNumber or IssuedAt) and the tax rows to write; the rows'
+// AppliedToLineItemIds still hold the original line IDs for the caller to map
+// to the note's own line items.
+func BuildRevenueNote(
+	original *revenuepb.Revenue,
+	items []*revenuelineitempb.RevenueLineItem,
+	taxLines []*revenuetaxlinepb.RevenueTaxLine,
+	existing []*RevenueNote,
+	req RevenueNoteRequest,
+) (*RevenueNote, []*revenuetaxlinepb.RevenueTaxLine, error) {
+	sign := int64(1)
+	switch req.Kind {
+	case RevenueNoteCredit:
+		sign = -1
+	case RevenueNoteDebit:
+	default:
+		return nil, nil, ErrRevenueNoteInvalidKind
+	}
+	if original.GetStatus() != "complete" || RevenueNoteKindOf(original.GetReferenceNumber()) != "" {
+		return nil, nil, ErrRevenueNoteNotAllowed
+	}
+
+	note := &RevenueNote{
+		Kind:              req.Kind,
+		OriginalRevenueID: original.GetId(),
+		OriginalReference: original.GetReferenceNumber(),
+		Reason:            req.Reason,
+		TaxInclusive:      original.GetTaxInclusivePricingSnapshot(),
+		Restock:           req.Restock && req.Kind == RevenueNoteCredit,
+	}
+
+	creditable := CreditableQuantities(items, existing)
+	for _, item := range NoteableLineItems(items) {
+		qty := req.Quantities[item.GetId()]
+		if qty <= 0 {
+			continue
+		}
+		if req.Kind == RevenueNoteCredit && qty > creditable[item.GetId()]+1e-9 {
+			return nil, nil, fmt.Errorf("%w: %s", ErrRevenueNoteOverCredit, item.GetDescription())
+		}
+		total := int64(math.Round(float64(item.GetTotalPrice()) * qty / item.GetQuantity()))
+		note.Lines = append(note.Lines, RevenueNoteLine{
+			SourceLineItemID:  item.GetId(),
+			Description:       item.GetDescription(),
+			ProductID:         item.GetProductId(),
+			InventoryItemID:   item.GetInventoryItemId(),
+			InventorySerialID: item.GetInventorySerialId(),
+			Quantity:          float64(sign) * qty,
+			UnitPrice:         item.GetUnitPrice(),
+			Total:             sign * total,
+		})
+		note.Subtotal += sign * total
+	}
+	if len(note.Lines) == 0 {
+		return nil, nil, ErrRevenueNoteEmpty
+	}
+
+	rows := prorateTaxLines(items, taxLines, note.Lines)
+	for _, row := range rows {
+		if row.GetDirection() == revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING {
+			note.Withholding += row.GetTaxAmount()
+		} else {
+			note.Surcharge += row.GetTaxAmount()
+		}
+	}
+	return note, rows, nil
+}
+
+// prorateTaxLines scales each original tax line by the share of its applied
+// lines' totals that the note copies. A tax line with no applied IDs covers
+// every item line. Amounts keep the note lines' sign.
+func prorateTaxLines(items []*revenuelineitempb.RevenueLineItem, taxLines []*revenuetaxlinepb.RevenueTaxLine, lines []RevenueNoteLine) []*revenuetaxlinepb.RevenueTaxLine {
+	originalTotals := map[string]int64{}
+	for _, item := range NoteableLineItems(items) {
+		originalTotals[item.GetId()] = item.GetTotalPrice()
+	}
+	noteTotals := map[string]int64{}
+	for _, l := range lines {
+		noteTotals[l.SourceLineItemID] += l.Total
+	}
+
+	var rows []*revenuetaxlinepb.RevenueTaxLine
+	for _, tl := range taxLines {
+		applied := tl.GetAppliedToLineItemIds()
+		if len(applied) == 0 {
+			for id := range originalTotals {
+				applied = append(applied, id)
+			}
+			sort.Strings(applied)
+		}
+		var base, share int64
+		var copied []string
+		for _, id := range applied {
+			base += originalTotals[id]
+			if t := noteTotals[id]; t != 0 {
+				share += t
+				copied = append(copied, id)
+			}
+		}
+		if base == 0 || share == 0 {
+			continue
+		}
+		rows = append(rows, &revenuetaxlinepb.RevenueTaxLine{
+			Active:                  true,
+			WorkspaceId:             tl.GetWorkspaceId(),
+			TaxRateId:               tl.TaxRateId,
+			AuthorityCodeSnapshot:   tl.GetAuthorityCodeSnapshot(),
+			RegulatorCodeSnapshot:   tl.RegulatorCodeSnapshot,
+			FilingFormCodeSnapshot:  tl.FilingFormCodeSnapshot,
+			TaxKindSnapshot:         tl.GetTaxKindSnapshot(),
+			Direction:               tl.GetDirection(),
+			TaxableBase:             scaleCentavos(tl.GetTaxableBase(), share, base),
+			TaxAmount:               scaleCentavos(tl.GetTaxAmount(), share, base),
+			RateBasisPointsSnapshot: tl.GetRateBasisPointsSnapshot(),
+			AppliedToLineItemIds:    copied,
+		})
+	}
+	return rows
+}
+
+// scaleCentavos returns amount * num / den rounded half away from zero.
+func scaleCentavos(amount, num, den int64) int64 {
+	return int64(math.Round(float64(amount) * float64(num) / float64(den)))
+}
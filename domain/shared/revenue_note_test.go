@@ -0,0 +1,156 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	revenuelineitempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+)
+
+func noteInvoice() (*revenuepb.Revenue, []*revenuelineitempb.RevenueLineItem, []*revenuetaxlinepb.RevenueTaxLine) {
+	ref := "INV-0001"
+	rev := &revenuepb.Revenue{Id: "rev-1", Status: "complete", ReferenceNumber: &ref, TotalAmount: 50000}
+	items := []*revenuelineitempb.RevenueLineItem{
+		{Id: "li-1", RevenueId: "rev-1", Description: "Widget", Quantity: 4, UnitPrice: 10000, TotalPrice: 40000, LineItemType: "item", InventoryItemId: "inv-1"},
+		{Id: "li-2", RevenueId: "rev-1", Description: "Service", Quantity: 1, UnitPrice: 10000, TotalPrice: 10000, LineItemType: "item"},
+		{Id: "li-3", RevenueId: "rev-1", Description: "Promo", Quantity: 1, TotalPrice: -1000, LineItemType: "discount"},
+	}
+	taxes := []*revenuetaxlinepb.RevenueTaxLine{
+		{RevenueId: "rev-1", TaxKindSnapshot: "VAT_STANDARD", TaxableBase: 50000, TaxAmount: 6000, RateBasisPointsSnapshot: 1200,
+			Direction: revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_SURCHARGE},
+		{RevenueId: "rev-1", TaxKindSnapshot: "EWT", TaxableBase: 10000, TaxAmount: 200, RateBasisPointsSnapshot: 200,
+			Direction:            revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING,
+			AppliedToLineItemIds: []string{"li-2"}},
+	}
+	return rev, items, taxes
+}
+
+func TestRevenueNoteKindOf(t *testing.T) {
+	t.Parallel()
+
+	numbering := NewDocumentNumbering(NewMemoryDocumentSequenceStore())
+	ctx := context.Background()
+	for _, kind := range []string{RevenueNoteCredit, RevenueNoteDebit} {
+		if err := numbering.SaveSequence(ctx, &DocumentSequence{DocumentType: kind, Prefix: DocumentTypeMarker(kind) + "{YYYY}-", Padding: 4, Reset: SequenceResetYearly}); err != nil {
+			t.Fatal(err)
+		}
+		num, err := numbering.Allocate(ctx, kind, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if RevenueNoteKindOf(num.Number) != kind {
+			t.Errorf("RevenueNoteKindOf(%q) = %q, want %q", num.Number, RevenueNoteKindOf(num.Number), kind)
+		}
+	}
+	if RevenueNoteKindOf("INV-0001") != "" || RevenueNoteKindOf("ORD-abcd-ef01") != "" {
+		t.Error("RevenueNoteKindOf misclassified a reference")
+	}
+}
+
+func TestBuildRevenueNote(t *testing.T) {
+	t.Parallel()
+
+	t.Run("credit note prorates lines and taxes", func(t *testing.T) {
+		t.Parallel()
+
+		rev, items, taxes := noteInvoice()
+		note, rows, err := BuildRevenueNote(rev, items, taxes, nil, RevenueNoteRequest{
+			Kind: RevenueNoteCredit, Quantities: map[string]float64{"li-1": 1, "li-3": 1}, Restock: true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(note.Lines) != 1 || note.Lines[0].Quantity != -1 || note.Lines[0].Total != -10000 {
+			t.Fatalf("lines = %+v, want one widget at -1 / -10000 (discount lines are not copied)", note.Lines)
+		}
+		if len(rows) != 1 || rows[0].GetTaxableBase() != -10000 || rows[0].GetTaxAmount() != -1200 {
+			t.Fatalf("tax rows = %+v, want VAT prorated to -1200 on -10000 and no EWT", rows)
+		}
+		if rows[0].GetAppliedToLineItemIds()[0] != "li-1" {
+			t.Errorf("applied = %v, want original line id for the caller to map", rows[0].GetAppliedToLineItemIds())
+		}
+		if note.Total() != -11200 || note.CashDelta() != -11200 || !note.Restock {
+			t.Errorf("Total %d, CashDelta %d, Restock %v", note.Total(), note.CashDelta(), note.Restock)
+		}
+	})
+
+	t.Run("credit cannot exceed what is left", func(t *testing.T) {
+		t.Parallel()
+
+		rev, items, taxes := noteInvoice()
+		earlier := []*RevenueNote{{Kind: RevenueNoteCredit, Lines: []RevenueNoteLine{{SourceLineItemID: "li-1", Quantity: -3}}}}
+		_, _, err := BuildRevenueNote(rev, items, taxes, earlier, RevenueNoteRequest{
+			Kind: RevenueNoteCredit, Quantities: map[string]float64{"li-1": 2},
+		})
+		if !errors.Is(err, ErrRevenueNoteOverCredit) {
+			t.Fatalf("expected ErrRevenueNoteOverCredit, got %v", err)
+		}
+		if left := CreditableQuantities(items, earlier); left["li-1"] != 1 || left["li-2"] != 1 {
+			t.Errorf("creditable = %v", left)
+		}
+	})
+
+	t.Run("debit note adds charges and withholding", func(t *testing.T) {
+		t.Parallel()
+
+		rev, items, taxes := noteInvoice()
+		note, rows, err := BuildRevenueNote(rev, items, taxes, nil, RevenueNoteRequest{
+			Kind: RevenueNoteDebit, Quantities: map[string]float64{"li-2": 2}, Restock: true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if note.Subtotal != 20000 || len(rows) != 2 || note.Surcharge != 2400 || note.Withholding != 400 {
+			t.Fatalf("note %+v, rows %d", note, len(rows))
+		}
+		if note.CashDelta() != 22000 || note.Restock {
+			t.Errorf("CashDelta %d, Restock %v; debit notes never restock", note.CashDelta(), note.Restock)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+
+		rev, items, taxes := noteInvoice()
+		if _, _, err := BuildRevenueNote(rev, items, taxes, nil, RevenueNoteRequest{Kind: RevenueNoteCredit}); !errors.Is(err, ErrRevenueNoteEmpty) {
+			t.Errorf("empty: got %v", err)
+		}
+		draft, _, _ := noteInvoice()
+		draft.Status = "draft"
+		if _, _, err := BuildRevenueNote(draft, items, taxes, nil, RevenueNoteRequest{Kind: RevenueNoteCredit, Quantities: map[string]float64{"li-1": 1}}); !errors.Is(err, ErrRevenueNoteNotAllowed) {
+			t.Errorf("draft: got %v", err)
+		}
+		cnRef := "CN-000001"
+		rev.ReferenceNumber = &cnRef
+		if _, _, err := BuildRevenueNote(rev, items, taxes, nil, RevenueNoteRequest{Kind: RevenueNoteDebit, Quantities: map[string]float64{"li-1": 1}}); !errors.Is(err, ErrRevenueNoteNotAllowed) {
+			t.Errorf("note against a note: got %v", err)
+		}
+	})
+}
+
+func TestMemoryRevenueNoteStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewMemoryRevenueNoteStore()
+	_ = store.SaveRevenueNote(ctx, &RevenueNote{RevenueID: "cn-1", OriginalRevenueID: "rev-1"})
+	_ = store.SaveRevenueNote(ctx, &RevenueNote{RevenueID: "dn-1", OriginalRevenueID: "rev-2"})
+
+	notes, _ := store.ListRevenueNotes(ctx, "rev-1")
+	if len(notes) != 1 || notes[0].RevenueID != "cn-1" {
+		t.Errorf("notes = %+v", notes)
+	}
+	if n, _ := store.ReadRevenueNote(ctx, "dn-1"); n == nil || n.OriginalRevenueID != "rev-2" {
+		t.Errorf("ReadRevenueNote = %+v", n)
+	}
+	if n, _ := store.ReadRevenueNote(ctx, "rev-1"); n != nil {
+		t.Errorf("invoice read as note: %+v", n)
+	}
+	_ = store.DeleteRevenueNote(ctx, "cn-1")
+	if notes, _ := store.ListRevenueNotes(ctx, "rev-1"); len(notes) != 0 {
+		t.Errorf("notes after delete = %+v", notes)
+	}
+}
@@ -0,0 +1,230 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+// Revenue statuses registered by NewRevenueStatusMachine. Draft, complete and
+// cancelled are set by staff; pending and paid only by checkout and its
+// payment webhooks, partially_returned and returned only by checkout returns.
+const (
+	RevenueStatusDraft             = "draft"
+	RevenueStatusComplete          = "complete"
+	RevenueStatusCancelled         = "cancelled"
+	RevenueStatusPending           = "pending"
+	RevenueStatusPaid              = "paid"
+	RevenueStatusPartiallyReturned = "partially_returned"
+	RevenueStatusReturned          = "returned"
+)
+
+// AnyRevenueStatus matches every status when registering guards and hooks.
+const AnyRevenueStatus = "*"
+
+// Revenue status machine errors. Guards return their own errors (see
+// ErrRevenueNoLineItems, ErrRevenueHasPayments) which Apply passes through.
+var (
+	ErrUnknownRevenueStatus        = errors.New("unknown revenue status")
+	ErrRevenueTransitionNotAllowed = errors.New("revenue status transition not allowed")
+	ErrRevenueNoLineItems          = errors.New("revenue has no line items")
+	ErrRevenueHasPayments          = errors.New("revenue has payments")
+)
+
+// RevenueTransition is one requested status change. From is empty when the
+// current status could not be read; Revenue is the current record when the
+// caller had it and nil otherwise.
+type RevenueTransition struct {
+	RevenueID string
+	From      string
+	To        string
+	Revenue   *revenuepb.Revenue
+}
+
+// RevenueStatusGuard vetoes a transition by returning an error.
+type RevenueStatusGuard func(ctx context.Context, t RevenueTransition) error
+
+// RevenueStatusHook runs after a transition is stored. Its error is logged;
+// the status change stands.
+type RevenueStatusHook func(ctx context.Context, t RevenueTransition) error
+
+type statusRule[F any] struct {
+	name     string
+	from, to string
+	fn       F
+}
+
+func (r statusRule[F]) matches(from, to string) bool {
+	return (r.from == AnyRevenueStatus || r.from == from) && (r.to == AnyRevenueStatus || r.to == to)
+}
+
+// RevenueStatusMachine declares the revenue statuses, the transitions allowed
+// between them and the guards and hooks that run on each transition. Build one
+// per app with NewRevenueStatusMachine, register workspace states and hooks at
+// startup, then share it between the revenue actions and checkout. Safe for
+// concurrent use.
+type RevenueStatusMachine struct {
+	mu          sync.RWMutex
+	states      map[string]bool // status -> settable by staff
+	transitions map[string]map[string]bool
+	guards      []statusRule[RevenueStatusGuard]
+	hooks       []statusRule[RevenueStatusHook]
+}
+
+// NewRevenueStatusMachine returns a machine with the default statuses:
+//
+//	any → draft | complete | cancelled     (staff)
+//	pending → paid | cancelled, cancelled → paid   (checkout webhooks)
+//	paid | complete | partially_returned → partially_returned | returned
+//	                                       (checkout returns)
+//
+// No guards or hooks are registered; the revenue actions add their business
+// rules on top.
+func NewRevenueStatusMachine() *RevenueStatusMachine {
+	m := &RevenueStatusMachine{
+		states:      map[string]bool{},
+		transitions: map[string]map[string]bool{},
+	}
+	m.AddState(RevenueStatusDraft)
+	m.AddState(RevenueStatusComplete)
+	m.AddState(RevenueStatusCancelled)
+	m.AddSystemState(RevenueStatusPending)
+	m.AddSystemState(RevenueStatusPaid)
+	m.AddSystemState(RevenueStatusPartiallyReturned)
+	m.AddSystemState(RevenueStatusReturned)
+
+	m.AllowTransition(AnyRevenueStatus, RevenueStatusDraft, RevenueStatusComplete, RevenueStatusCancelled)
+	m.AllowTransition(RevenueStatusPending, RevenueStatusPaid, RevenueStatusCancelled)
+	// A payment captured after its reservation expired is still recorded.
+	m.AllowTransition(RevenueStatusCancelled, RevenueStatusPaid)
+	for _, from := range []string{RevenueStatusPaid, RevenueStatusComplete, RevenueStatusPartiallyReturned} {
+		m.AllowTransition(from, RevenueStatusPartiallyReturned, RevenueStatusReturned)
+	}
+	return m
+}
+
+// AddState registers a status staff may set from the revenue actions.
+func (m *RevenueStatusMachine) AddState(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[status] = true
+}
+
+// AddSystemState registers a status only integrations set (checkout,
+// webhooks); the revenue actions reject it as a target.
+func (m *RevenueStatusMachine) AddSystemState(status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.states[status]; !ok {
+		m.states[status] = false
+	}
+}
+
+// AllowTransition allows moving from one status (or AnyRevenueStatus) to each
+// of to. Both ends must be registered states.
+func (m *RevenueStatusMachine) AllowTransition(from string, to ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.transitions[from] == nil {
+		m.transitions[from] = map[string]bool{}
+	}
+	for _, t := range to {
+		m.transitions[from][t] = true
+	}
+}
+
+// AddGuard registers a guard for transitions matching from and to (either may
+// be AnyRevenueStatus). Guards run in registration order; the first error
+// stops the transition. name identifies the guard in logs.
+func (m *RevenueStatusMachine) AddGuard(name, from, to string, guard RevenueStatusGuard) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.guards = append(m.guards, statusRule[RevenueStatusGuard]{name: name, from: from, to: to, fn: guard})
+}
+
+// AddHook registers a hook for transitions matching from and to (either may
+// be AnyRevenueStatus). Hooks run in registration order after the status is
+// stored. name identifies the hook in logs.
+func (m *RevenueStatusMachine) AddHook(name, from, to string, hook RevenueStatusHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, statusRule[RevenueStatusHook]{name: name, from: from, to: to, fn: hook})
+}
+
+// HasState reports whether status is registered.
+func (m *RevenueStatusMachine) HasState(status string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.states[status]
+	return ok
+}
+
+// IsManual reports whether staff may set status.
+func (m *RevenueStatusMachine) IsManual(status string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.states[status]
+}
+
+// CanTransition reports whether from → to is allowed. An empty from (status
+// unknown) allows any registered target.
+func (m *RevenueStatusMachine) CanTransition(from, to string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.states[to]; !ok {
+		return false
+	}
+	return from == "" || m.transitions[from][to] || m.transitions[AnyRevenueStatus][to]
+}
+
+// Check validates t without changing anything: the target must be registered,
+// the transition allowed and every matching guard must pass.
+func (m *RevenueStatusMachine) Check(ctx context.Context, t RevenueTransition) error {
+	if !m.HasState(t.To) {
+		return fmt.Errorf("%w: %q", ErrUnknownRevenueStatus, t.To)
+	}
+	if !m.CanTransition(t.From, t.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrRevenueTransitionNotAllowed, t.From, t.To)
+	}
+
+	m.mu.RLock()
+	guards := append([]statusRule[RevenueStatusGuard](nil), m.guards...)
+	m.mu.RUnlock()
+	for _, g := range guards {
+		if !g.matches(t.From, t.To) {
+			continue
+		}
+		if err := g.fn(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply checks t, stores it through update and then runs the matching hooks.
+// It returns the Check or update error; hook errors are logged only.
+func (m *RevenueStatusMachine) Apply(ctx context.Context, t RevenueTransition, update func(ctx context.Context, t RevenueTransition) error) error {
+	if err := m.Check(ctx, t); err != nil {
+		return err
+	}
+	if err := update(ctx, t); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	hooks := append([]statusRule[RevenueStatusHook](nil), m.hooks...)
+	m.mu.RUnlock()
+	for _, h := range hooks {
+		if !h.matches(t.From, t.To) {
+			continue
+		}
+		if err := h.fn(ctx, t); err != nil {
+			log.Printf("revenue status hook %s failed for %s (%s -> %s): %v", h.name, t.RevenueID, t.From, t.To, err)
+		}
+	}
+	return nil
+}
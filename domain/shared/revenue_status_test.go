@@ -0,0 +1,104 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRevenueStatusMachine(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	noop := func(context.Context, RevenueTransition) error { return nil }
+
+	t.Run("default transitions", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewRevenueStatusMachine()
+		cases := []struct {
+			from, to string
+			want     bool
+		}{
+			{RevenueStatusDraft, RevenueStatusComplete, true},
+			{RevenueStatusComplete, RevenueStatusDraft, true},
+			{RevenueStatusComplete, RevenueStatusCancelled, true},
+			{RevenueStatusCancelled, RevenueStatusDraft, true},
+			{RevenueStatusPaid, RevenueStatusComplete, true},
+			{RevenueStatusPending, RevenueStatusPaid, true},
+			{RevenueStatusPaid, RevenueStatusCancelled, true},
+			{RevenueStatusDraft, RevenueStatusPaid, false},
+			{RevenueStatusPaid, RevenueStatusPartiallyReturned, true},
+			{RevenueStatusPartiallyReturned, RevenueStatusReturned, true},
+			{RevenueStatusReturned, RevenueStatusPartiallyReturned, false},
+			{RevenueStatusDraft, RevenueStatusReturned, false},
+			{"", RevenueStatusCancelled, true}, // unknown current status
+			{"", "bogus", false},
+		}
+		for _, c := range cases {
+			if got := m.CanTransition(c.from, c.to); got != c.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", c.from, c.to, got, c.want)
+			}
+		}
+		if m.IsManual(RevenueStatusPending) || m.IsManual(RevenueStatusReturned) || !m.IsManual(RevenueStatusComplete) {
+			t.Error("pending and returned must be system states and complete a manual one")
+		}
+		err := m.Apply(ctx, RevenueTransition{From: RevenueStatusReturned, To: RevenueStatusPaid}, noop)
+		if !errors.Is(err, ErrRevenueTransitionNotAllowed) {
+			t.Errorf("Apply returned -> paid: got %v", err)
+		}
+	})
+
+	t.Run("guards veto and hooks run after update", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewRevenueStatusMachine()
+		var calls []string
+		m.AddGuard("payments", AnyRevenueStatus, RevenueStatusCancelled, func(_ context.Context, tr RevenueTransition) error {
+			if tr.RevenueID == "paid-invoice" {
+				return ErrRevenueHasPayments
+			}
+			return nil
+		})
+		m.AddHook("release", AnyRevenueStatus, RevenueStatusCancelled, func(context.Context, RevenueTransition) error {
+			calls = append(calls, "hook")
+			return errors.New("logged, not returned")
+		})
+		update := func(context.Context, RevenueTransition) error {
+			calls = append(calls, "update")
+			return nil
+		}
+
+		err := m.Apply(ctx, RevenueTransition{RevenueID: "paid-invoice", From: RevenueStatusDraft, To: RevenueStatusCancelled}, update)
+		if !errors.Is(err, ErrRevenueHasPayments) || len(calls) != 0 {
+			t.Fatalf("guarded Apply: err %v, calls %v", err, calls)
+		}
+		if err := m.Apply(ctx, RevenueTransition{RevenueID: "rev-1", From: RevenueStatusDraft, To: RevenueStatusCancelled}, update); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		if len(calls) != 2 || calls[0] != "update" || calls[1] != "hook" {
+			t.Errorf("calls = %v, want update then hook", calls)
+		}
+	})
+
+	t.Run("workspace states", func(t *testing.T) {
+		t.Parallel()
+
+		m := NewRevenueStatusMachine()
+		m.AddState("sent")
+		m.AllowTransition(RevenueStatusComplete, "sent")
+		m.AllowTransition("sent", RevenueStatusDraft)
+		sent := false
+		m.AddHook("email", RevenueStatusComplete, "sent", func(context.Context, RevenueTransition) error {
+			sent = true
+			return nil
+		})
+
+		if err := m.Apply(ctx, RevenueTransition{From: RevenueStatusComplete, To: "sent"}, noop); err != nil || !sent {
+			t.Errorf("complete -> sent: err %v, hook ran %v", err, sent)
+		}
+		if m.CanTransition(RevenueStatusDraft, "sent") {
+			t.Error("draft -> sent was never allowed")
+		}
+	})
+}
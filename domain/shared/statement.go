@@ -0,0 +1,207 @@
+package shared
+
+import (
+	"sort"
+	"time"
+)
+
+// Statement line kinds, in the order same-day lines are listed.
+const (
+	StatementInvoice = "invoice"
+	StatementDebit   = "debit_note"
+	StatementPayment = "payment"
+	StatementCredit  = "credit_note"
+	StatementReceipt = "receipt"
+)
+
+var statementKindOrder = map[string]int{
+	StatementInvoice: 0,
+	StatementDebit:   1,
+	StatementPayment: 2,
+	StatementCredit:  3,
+	StatementReceipt: 4,
+}
+
+// AccountReceipt is cash received from a client that is not applied to an
+// invoice, such as an advance collected in treasury. It lowers the client's
+// statement balance but not the invoice aging.
+type AccountReceipt struct {
+	ClientID  string
+	Currency  string
+	Reference string
+	Amount    int64
+	At        time.Time
+}
+
+// Receivables is the receivables ledger: every issued invoice with the
+// payments and notes applied to it, and cash received on account. Payments
+// and notes belong to the invoice named by their RevenueID; notes carry their
+// signed CashDelta.
+type Receivables struct {
+	Invoices []ReceivableInvoice
+	Payments []ReceivableEntry
+	Notes    []ReceivableEntry
+	Receipts []AccountReceipt
+}
+
+// Age builds the aging report at asOf (see AgeReceivables). Receipts on
+// account are not applied to any invoice and are left out.
+func (r *Receivables) Age(asOf time.Time, limits AgingBuckets) *AgingReport {
+	return AgeReceivables(r.Invoices, r.Payments, r.Notes, asOf, limits)
+}
+
+// StatementLine is one dated movement on a client's statement. Charge and
+// Credit are in centavos (one of them is zero); Balance is the running
+// balance after the line.
+type StatementLine struct {
+	Kind      string
+	At        time.Time
+	RevenueID string // the invoice the line belongs to; "" for receipts
+	Reference string // the document's own reference
+	AppliesTo string // the invoice reference for payments and notes
+	Charge    int64
+	Credit    int64
+	Balance   int64
+}
+
+// Statement is a client's account in one currency over [From, To]: the
+// balance brought forward, every movement in the period with a running
+// balance, and the aging of the invoices still open at To.
+type Statement struct {
+	ClientID   string
+	ClientName string
+	Currency   string
+	From       time.Time
+	To         time.Time
+	Opening    int64
+	Charges    int64
+	Credits    int64
+	Closing    int64
+	Lines      []*StatementLine
+	// Aging holds the client's open invoices at To, or nil when none are
+	// open.
+	Aging *AgingClient
+}
+
+// FindStatement returns the client's statement in currency, or nil.
+func FindStatement(statements []*Statement, clientID, currency string) *Statement {
+	for _, s := range statements {
+		if s.ClientID == clientID && (currency == "" || s.Currency == currency) {
+			return s
+		}
+	}
+	return nil
+}
+
+// BuildStatements builds one statement per client and currency over the
+// days from..to inclusive. Movements before from make up the opening
+// balance; later ones are ignored. Payments and notes take the client and
+// currency of their invoice and are dropped when it is unknown. Clients with
+// no movement in the period and nothing brought forward are left out.
+// Statements are sorted by client name, then currency.
+func BuildStatements(r *Receivables, from, to time.Time, limits AgingBuckets) []*Statement {
+	fromDay, toDay := truncateDay(from), truncateDay(to)
+	invoices := make(map[string]*ReceivableInvoice, len(r.Invoices))
+	names := map[string]string{}
+	for i := range r.Invoices {
+		inv := &r.Invoices[i]
+		invoices[inv.RevenueID] = inv
+		names[inv.ClientID] = inv.ClientName
+	}
+
+	type movement struct {
+		clientID, currency string
+		line               StatementLine
+	}
+	var movements []movement
+	for _, inv := range r.Invoices {
+		movements = append(movements, movement{inv.ClientID, inv.Currency, StatementLine{
+			Kind: StatementInvoice, At: inv.InvoiceDate, RevenueID: inv.RevenueID, Reference: inv.Reference, Charge: inv.Amount,
+		}})
+	}
+	applied := func(kind string, e ReceivableEntry, charge, credit int64) {
+		inv, ok := invoices[e.RevenueID]
+		if !ok {
+			return
+		}
+		movements = append(movements, movement{inv.ClientID, inv.Currency, StatementLine{
+			Kind: kind, At: e.At, RevenueID: e.RevenueID, Reference: e.Reference, AppliesTo: inv.Reference, Charge: charge, Credit: credit,
+		}})
+	}
+	for _, p := range r.Payments {
+		applied(StatementPayment, p, 0, p.Amount)
+	}
+	for _, n := range r.Notes {
+		if n.Amount >= 0 {
+			applied(StatementDebit, n, n.Amount, 0)
+		} else {
+			applied(StatementCredit, n, 0, -n.Amount)
+		}
+	}
+	for _, rc := range r.Receipts {
+		movements = append(movements, movement{rc.ClientID, rc.Currency, StatementLine{
+			Kind: StatementReceipt, At: rc.At, Reference: rc.Reference, Credit: rc.Amount,
+		}})
+	}
+	sort.SliceStable(movements, func(i, j int) bool {
+		a, b := movements[i].line, movements[j].line
+		if da, db := truncateDay(a.At), truncateDay(b.At); !da.Equal(db) {
+			return da.Before(db)
+		}
+		return statementKindOrder[a.Kind] < statementKindOrder[b.Kind]
+	})
+
+	groups := map[string]*Statement{}
+	var statements []*Statement
+	for _, m := range movements {
+		day := truncateDay(m.line.At)
+		if day.After(toDay) {
+			continue
+		}
+		key := m.clientID + "\x00" + m.currency
+		s, ok := groups[key]
+		if !ok {
+			name := names[m.clientID]
+			if name == "" {
+				name = m.clientID
+			}
+			s = &Statement{ClientID: m.clientID, ClientName: name, Currency: m.currency, From: fromDay, To: toDay}
+			groups[key] = s
+			statements = append(statements, s)
+		}
+		delta := m.line.Charge - m.line.Credit
+		if day.Before(fromDay) {
+			s.Opening += delta
+			s.Closing += delta
+			continue
+		}
+		line := m.line
+		s.Charges += line.Charge
+		s.Credits += line.Credit
+		s.Closing += delta
+		line.Balance = s.Closing
+		s.Lines = append(s.Lines, &line)
+	}
+
+	aging := r.Age(toDay, limits)
+	out := statements[:0]
+	for _, s := range statements {
+		if len(s.Lines) == 0 && s.Opening == 0 {
+			continue
+		}
+		for _, c := range aging.Client(s.ClientID) {
+			if c.Currency == s.Currency {
+				s.Aging = c
+			}
+		}
+		out = append(out, s)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.ClientName != b.ClientName {
+			return a.ClientName < b.ClientName
+		}
+		return a.Currency < b.Currency
+	})
+	return out
+}
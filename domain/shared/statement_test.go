@@ -0,0 +1,77 @@
+package shared
+
+import (
+	"testing"
+)
+
+func TestBuildStatements(t *testing.T) {
+	t.Parallel()
+
+	r := &Receivables{
+		Invoices: []ReceivableInvoice{
+			{RevenueID: "r1", Reference: "INV-1", ClientID: "acme", ClientName: "Acme", Currency: "PHP", Amount: 100000, InvoiceDate: day("2026-02-10"), DueDate: day("2026-03-12")},
+			{RevenueID: "r2", Reference: "INV-2", ClientID: "acme", ClientName: "Acme", Currency: "PHP", Amount: 50000, InvoiceDate: day("2026-03-05"), DueDate: day("2026-04-04")},
+			{RevenueID: "r3", Reference: "INV-3", ClientID: "acme", ClientName: "Acme", Currency: "USD", Amount: 2000, InvoiceDate: day("2026-01-05"), DueDate: day("2026-02-04")},
+			{RevenueID: "r4", Reference: "INV-4", ClientID: "bolt", ClientName: "Bolt", Currency: "PHP", Amount: 30000, InvoiceDate: day("2026-04-02"), DueDate: day("2026-05-02")},
+		},
+		Payments: []ReceivableEntry{
+			{RevenueID: "r1", Reference: "OR-1", Amount: 40000, At: day("2026-03-05")},
+			{RevenueID: "r3", Reference: "OR-2", Amount: 2000, At: day("2026-01-20")},
+			{RevenueID: "gone", Reference: "OR-3", Amount: 999, At: day("2026-03-06")},
+		},
+		Notes: []ReceivableEntry{
+			{RevenueID: "r2", Reference: "CN-1", Amount: -5000, At: day("2026-03-20")},
+		},
+		Receipts: []AccountReceipt{
+			{ClientID: "acme", Currency: "PHP", Reference: "ADV-1", Amount: 10000, At: day("2026-03-25")},
+		},
+	}
+	statements := BuildStatements(r, day("2026-03-01"), day("2026-03-31"), DefaultAgingLimits)
+
+	// Bolt's only invoice is after the period and Acme's USD account was
+	// settled before it, so only Acme PHP is left.
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.ClientID != "acme" || s.Currency != "PHP" {
+		t.Fatalf("statement = %s %s", s.ClientID, s.Currency)
+	}
+	if s.Opening != 100000 || s.Charges != 50000 || s.Credits != 55000 || s.Closing != 95000 {
+		t.Errorf("opening %d, charges %d, credits %d, closing %d", s.Opening, s.Charges, s.Credits, s.Closing)
+	}
+
+	// Same-day lines list the invoice before the payment.
+	want := []struct {
+		kind      string
+		reference string
+		appliesTo string
+		balance   int64
+	}{
+		{StatementInvoice, "INV-2", "", 150000},
+		{StatementPayment, "OR-1", "INV-1", 110000},
+		{StatementCredit, "CN-1", "INV-2", 105000},
+		{StatementReceipt, "ADV-1", "", 95000},
+	}
+	if len(s.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(s.Lines), len(want))
+	}
+	for i, w := range want {
+		got := s.Lines[i]
+		if got.Kind != w.kind || got.Reference != w.reference || got.AppliesTo != w.appliesTo || got.Balance != w.balance {
+			t.Errorf("line %d = %+v, want %+v", i, got, w)
+		}
+	}
+
+	// The aging at the period end ignores the receipt on account.
+	if s.Aging == nil || s.Aging.Total != 105000 {
+		t.Fatalf("aging = %+v", s.Aging)
+	}
+	if s.Aging.Buckets[0] != 45000 || s.Aging.Buckets[1] != 60000 {
+		t.Errorf("aging buckets = %v", s.Aging.Buckets)
+	}
+
+	if FindStatement(statements, "acme", "USD") != nil || FindStatement(statements, "acme", "") != s {
+		t.Error("FindStatement did not match by client and currency")
+	}
+}
@@ -2,12 +2,15 @@ package action
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
 	"strconv"
+	"time"
 
+	centymoshared "github.com/erniealice/centymo-golang/domain/shared"
 	collection "github.com/erniealice/centymo-golang/domain/treasury/collection"
 	shared "github.com/erniealice/centymo-golang/domain/treasury/shared"
 
@@ -31,6 +34,15 @@ type Deps struct {
 	// the advance_kind + advance_proration_policy dropdowns rendered in the
 	// drawer form. Sourced from advance_kind.json via lyngua.
 	AdvanceEnumLabels shared.AdvanceEnumLabels
+
+	// SnapshotFXRate records the exchange rate a new collection in a foreign
+	// currency is issued at (optional — no snapshot is taken when nil).
+	SnapshotFXRate func(ctx context.Context, id, currency string, on time.Time) error
+
+	// Numbering (optional) fills a blank reference number from the
+	// official_receipt sequence; the number is voided if the collection is
+	// not created.
+	Numbering *centymoshared.DocumentNumbering
 }
 
 // parseAmount converts a form string amount (decimal) to int64 centavos.
@@ -68,9 +80,22 @@ func NewAddAction(deps *Deps) view.View {
 
 		r := viewCtx.Request
 
+		referenceNumber := r.FormValue("reference_number")
+		issued := ""
+		if referenceNumber == "" {
+			num, err := deps.Numbering.Allocate(ctx, centymoshared.DocumentOfficialReceipt, "")
+			switch {
+			case err == nil:
+				referenceNumber, issued = num.Number, num.Number
+			case !errors.Is(err, centymoshared.ErrNoDocumentSequence):
+				log.Printf("Failed to number collection: %v", err)
+				return view.HTMXError(err.Error())
+			}
+		}
+
 		resp, err := deps.CreateCollection(ctx, &collectionpb.CreateCollectionRequest{
 			Data: &collectionpb.Collection{
-				ReferenceNumber:    r.FormValue("reference_number"),
+				ReferenceNumber:    referenceNumber,
 				Name:               r.FormValue("customer"),
 				Amount:             parseAmount(r.FormValue("amount")),
 				Currency:           r.FormValue("currency"),
@@ -83,6 +108,11 @@ func NewAddAction(deps *Deps) view.View {
 		})
 		if err != nil {
 			log.Printf("Failed to create collection: %v", err)
+			if issued != "" {
+				if voidErr := deps.Numbering.Void(context.WithoutCancel(ctx), issued, "collection not created"); voidErr != nil {
+					log.Printf("Failed to void %s: %v", issued, voidErr)
+				}
+			}
 			return view.HTMXError(err.Error())
 		}
 
@@ -90,6 +120,11 @@ func NewAddAction(deps *Deps) view.View {
 		if respData := resp.GetData(); len(respData) > 0 {
 			newID = respData[0].GetId()
 		}
+		if newID != "" && deps.SnapshotFXRate != nil {
+			if err := deps.SnapshotFXRate(ctx, newID, r.FormValue("currency"), time.Now()); err != nil {
+				log.Printf("Failed to snapshot FX rate for collection %s: %v", newID, err)
+			}
+		}
 		if newID != "" {
 			return view.ViewResult{
 				StatusCode: http.StatusOK,
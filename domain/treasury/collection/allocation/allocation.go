@@ -0,0 +1,501 @@
+// Package allocation applies one treasury collection to many invoices: the
+// Allocations tab on the collection detail page, the drawer that allocates
+// the unapplied amount oldest-first or by hand, and the action that reverses
+// an allocation. Each allocation creates a revenue payment on its invoice, so
+// invoice balances move with it; whatever is not applied stays on the
+// collection as unapplied credit.
+package allocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	centymoshared "github.com/erniealice/centymo-golang/domain/shared"
+	collection "github.com/erniealice/centymo-golang/domain/treasury/collection"
+
+	"github.com/erniealice/pyeza-golang/route"
+	"github.com/erniealice/pyeza-golang/types"
+	"github.com/erniealice/pyeza-golang/view"
+
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
+	collectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
+)
+
+// tableID is the allocations table, refreshed after allocating or reversing.
+const tableID = "collection-allocations-table"
+
+// Deps holds dependencies for the allocation views. UserID is optional and
+// records who allocated or reversed; NewID is optional when
+// CreateRevenuePayment returns the created payment.
+type Deps struct {
+	Routes      collection.Routes
+	Labels      collection.Labels
+	TableLabels types.TableLabels
+
+	ReadCollection func(ctx context.Context, req *collectionpb.ReadCollectionRequest) (*collectionpb.ReadCollectionResponse, error)
+	Allocations    centymoshared.PaymentAllocationStore
+
+	// ListOpenInvoices returns the client's invoices with a balance due in
+	// currency (aging.LoadOpenInvoices).
+	ListOpenInvoices func(ctx context.Context, clientID, currency string) ([]*centymoshared.AgingLine, error)
+
+	CreateRevenuePayment func(ctx context.Context, req *revenuepaymentpb.CreateRevenuePaymentRequest) (*revenuepaymentpb.CreateRevenuePaymentResponse, error)
+	DeleteRevenuePayment func(ctx context.Context, req *revenuepaymentpb.DeleteRevenuePaymentRequest) (*revenuepaymentpb.DeleteRevenuePaymentResponse, error)
+
+	NewID  func() string
+	UserID func(ctx context.Context) string
+}
+
+// TabData is the Allocations tab of a collection.
+type TabData struct {
+	Currency  string
+	Amount    string
+	Applied   string
+	Unapplied string
+	// Notice explains why the collection cannot be allocated, if it cannot.
+	Notice string
+	Table  *types.TableConfig
+}
+
+// InvoiceRow is one open invoice in the allocation drawer. Balance and
+// Suggested are decimal amounts for the number inputs; Suggested is the
+// oldest-first allocation.
+type InvoiceRow struct {
+	RevenueID      string
+	Reference      string
+	DueDate        string
+	BalanceDisplay string
+	Balance        string
+	Suggested      string
+}
+
+// FormData is the allocation drawer's template data.
+type FormData struct {
+	FormAction   string
+	WorkspaceID  string // injected by ViewAdapter for action_workspace_guard
+	Currency     string
+	Unapplied    string
+	Invoices     []InvoiceRow
+	Labels       collection.AllocationLabels
+	CommonLabels any
+}
+
+// Allocatable reports whether c can be applied to invoices: a completed
+// collection from a client that is not already tied to an invoice.
+func Allocatable(c *collectionpb.Collection) bool {
+	return c.GetStatus() == "completed" && c.GetClientId() != "" && c.GetRevenueId() == ""
+}
+
+// Enabled reports whether the allocation views can run with deps.
+func Enabled(deps *Deps) bool {
+	return deps != nil && deps.Allocations != nil && deps.ListOpenInvoices != nil &&
+		deps.CreateRevenuePayment != nil && deps.DeleteRevenuePayment != nil &&
+		deps.Routes.AllocateURL != ""
+}
+
+// LoadTab builds the Allocations tab of c.
+func LoadTab(ctx context.Context, deps *Deps, c *collectionpb.Collection) (*TabData, error) {
+	allocations, err := deps.Allocations.ListPaymentAllocations(ctx, c.GetId())
+	if err != nil {
+		log.Printf("Failed to list allocations of collection %s: %v", c.GetId(), err)
+		return nil, fmt.Errorf("failed to load allocations: %w", err)
+	}
+	applied := centymoshared.AppliedAmount(allocations)
+	currency := c.GetCurrency()
+	data := &TabData{
+		Currency:  currency,
+		Amount:    types.FormatMoney(c.GetAmount(), currency),
+		Applied:   types.FormatMoney(applied, currency),
+		Unapplied: types.FormatMoney(c.GetAmount()-applied, currency),
+		Table:     buildTable(ctx, deps, c, allocations),
+	}
+	if !Allocatable(c) {
+		data.Notice = deps.Labels.Allocation.NotAllocatable
+	}
+	return data, nil
+}
+
+// NewTableView returns the allocations table partial for HTMX refresh.
+func NewTableView(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("collection", "read") {
+			return view.Forbidden("collection:read")
+		}
+		c, err := readCollection(ctx, deps, viewCtx.Request.PathValue("id"))
+		if err != nil {
+			return view.Error(err)
+		}
+		allocations, err := deps.Allocations.ListPaymentAllocations(ctx, c.GetId())
+		if err != nil {
+			log.Printf("Failed to list allocations of collection %s: %v", c.GetId(), err)
+			return view.Error(fmt.Errorf("failed to load allocations: %w", err))
+		}
+		return view.OK("table-card", buildTable(ctx, deps, c, allocations))
+	})
+}
+
+// NewAllocateAction creates the allocation action (GET = drawer with the
+// client's open invoices prefilled oldest-first, POST = apply). Submitting
+// with mode=auto re-runs the oldest-first allocation on the current balances
+// instead of reading the amounts.
+func NewAllocateAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("collection", "update") || !perms.Can("payment", "create") {
+			return view.HTMXError(l.Errors.PermissionDenied)
+		}
+
+		id := viewCtx.Request.PathValue("id")
+		c, err := readCollection(ctx, deps, id)
+		if err != nil {
+			return view.HTMXError(l.Errors.NotFound)
+		}
+		if !Allocatable(c) {
+			return view.HTMXError(l.Allocation.NotAllocatable)
+		}
+		allocations, err := deps.Allocations.ListPaymentAllocations(ctx, id)
+		if err != nil {
+			log.Printf("Failed to list allocations of collection %s: %v", id, err)
+			return view.HTMXError(err.Error())
+		}
+		unapplied := c.GetAmount() - centymoshared.AppliedAmount(allocations)
+		open, err := deps.ListOpenInvoices(ctx, c.GetClientId(), c.GetCurrency())
+		if err != nil {
+			log.Printf("Failed to list open invoices of client %s: %v", c.GetClientId(), err)
+			return view.HTMXError(err.Error())
+		}
+
+		r := viewCtx.Request
+		if r.Method == http.MethodGet {
+			return view.OK("collection-allocation-drawer-form", &FormData{
+				FormAction:   route.ResolveURL(deps.Routes.AllocateURL, "id", id),
+				Currency:     c.GetCurrency(),
+				Unapplied:    types.FormatMoney(unapplied, c.GetCurrency()),
+				Invoices:     invoiceRows(open, unapplied),
+				Labels:       l.Allocation,
+				CommonLabels: nil, // injected by ViewAdapter
+			})
+		}
+
+		if err := r.ParseForm(); err != nil {
+			return view.HTMXError(l.Errors.InvalidFormData)
+		}
+		var lines []centymoshared.AllocationLine
+		if r.FormValue("mode") == "auto" {
+			lines = centymoshared.AllocateOldestFirst(unapplied, open)
+		} else {
+			for _, inv := range open {
+				s := strings.TrimSpace(r.FormValue("amount_" + inv.RevenueID))
+				if s == "" {
+					continue
+				}
+				amount, err := types.ParseCentavos(s)
+				if err != nil {
+					return view.HTMXError(l.Allocation.ErrorAmount)
+				}
+				lines = append(lines, centymoshared.AllocationLine{RevenueID: inv.RevenueID, Amount: amount})
+			}
+		}
+		lines, err = centymoshared.ValidateAllocation(unapplied, open, lines)
+		if err != nil {
+			return view.HTMXError(errorMessage(l.Allocation, err))
+		}
+
+		all, err := deps.Allocations.ListPaymentAllocations(ctx, "")
+		if err != nil {
+			log.Printf("Failed to list allocations: %v", err)
+			return view.HTMXError(err.Error())
+		}
+		if err := apply(ctx, deps, c, open, all, lines); err != nil {
+			log.Printf("Failed to allocate collection %s: %v", id, err)
+			return view.HTMXError(errorMessage(l.Allocation, err))
+		}
+		return view.HTMXSuccess(tableID)
+	})
+}
+
+// NewReverseAction creates the reverse action (POST ?id=<allocation>). The
+// allocation is marked reversed before its payment is deleted and restored
+// if the delete fails, so an applied allocation always has its payment.
+func NewReverseAction(deps *Deps) view.View {
+	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
+		l := deps.Labels
+		perms := view.GetUserPermissions(ctx)
+		if !perms.Can("collection", "update") || !perms.Can("payment", "delete") {
+			return view.HTMXError(l.Errors.PermissionDenied)
+		}
+
+		id := viewCtx.Request.URL.Query().Get("id")
+		if id == "" {
+			_ = viewCtx.Request.ParseForm()
+			id = viewCtx.Request.FormValue("id")
+		}
+		if id == "" {
+			return view.HTMXError(l.Errors.IDRequired)
+		}
+		a, err := deps.Allocations.ReadPaymentAllocation(ctx, id)
+		if err != nil {
+			log.Printf("Failed to read allocation %s: %v", id, err)
+			return view.HTMXError(err.Error())
+		}
+		if a == nil || a.CollectionID != viewCtx.Request.PathValue("id") {
+			return view.HTMXError(l.Allocation.ErrorNotFound)
+		}
+		if a.Reversed() {
+			return view.HTMXError(l.Allocation.ErrorReversed)
+		}
+
+		applied := *a
+		a.ReversedAt = time.Now()
+		a.ReversedBy = userID(ctx, deps)
+		if err := deps.Allocations.SavePaymentAllocation(ctx, a); err != nil {
+			log.Printf("Failed to reverse allocation %s: %v", id, err)
+			return view.HTMXError(err.Error())
+		}
+		if _, err := deps.DeleteRevenuePayment(ctx, &revenuepaymentpb.DeleteRevenuePaymentRequest{
+			Data: &revenuepaymentpb.RevenuePayment{Id: a.RevenuePaymentID},
+		}); err != nil {
+			log.Printf("Failed to delete payment %s of allocation %s: %v", a.RevenuePaymentID, id, err)
+			if err := deps.Allocations.SavePaymentAllocation(ctx, &applied); err != nil {
+				log.Printf("Failed to restore allocation %s: %v", id, err)
+			}
+			return view.HTMXError(err.Error())
+		}
+		return view.HTMXSuccess(tableID)
+	})
+}
+
+// apply records lines: one completed revenue payment per invoice, then its
+// allocation. The unapplied amount and the balances read by the caller may
+// be stale, so each allocation is inserted only while the collection's
+// applied total stays within its amount and the invoice's stays within the
+// balance read plus what allocations had paid of it by then (allocations,
+// every collection's, as read alongside open); a concurrent allocation that
+// got there first fails the line with ErrAllocationOverUnapplied or
+// ErrAllocationOverBalance. A line whose allocation cannot be saved has its
+// payment deleted; on the first failure the rest of the lines are not
+// applied.
+func apply(ctx context.Context, deps *Deps, c *collectionpb.Collection, open []*centymoshared.AgingLine, allocations []*centymoshared.PaymentAllocation, lines []centymoshared.AllocationLine) error {
+	references := make(map[string]string, len(open))
+	invoiceLimits := make(map[string]int64, len(open))
+	for _, inv := range open {
+		references[inv.RevenueID] = inv.Reference
+		invoiceLimits[inv.RevenueID] = centymoshared.InvoiceAllocationLimit(inv.Balance, inv.RevenueID, allocations)
+	}
+	paymentDate := c.GetPaymentDate()
+	if paymentDate == "" {
+		paymentDate = time.Now().Format("2006-01-02")
+	}
+	by := userID(ctx, deps)
+
+	for _, line := range lines {
+		payment := &revenuepaymentpb.RevenuePayment{
+			RevenueId:          line.RevenueID,
+			Amount:             line.Amount,
+			Currency:           c.GetCurrency(),
+			CollectionMethodId: strPtr(c.GetCollectionMethodId()),
+			ReferenceNumber:    strPtr(c.GetReferenceNumber()),
+			CollectionType:     strPtr("sale"),
+			Status:             strPtr("completed"),
+			PaymentDate:        strPtr(paymentDate),
+			Notes:              strPtr(fmt.Sprintf(deps.Labels.Allocation.PaymentNotes, c.GetReferenceNumber())),
+		}
+		if deps.NewID != nil {
+			payment.Id = deps.NewID()
+		}
+		resp, err := deps.CreateRevenuePayment(ctx, &revenuepaymentpb.CreateRevenuePaymentRequest{Data: payment})
+		if err != nil {
+			return fmt.Errorf("failed to record payment on %s: %w", references[line.RevenueID], err)
+		}
+		paymentID := payment.GetId()
+		if data := resp.GetData(); len(data) > 0 && data[0].GetId() != "" {
+			paymentID = data[0].GetId()
+		}
+		if paymentID == "" {
+			return fmt.Errorf("payment on %s was recorded without an ID", references[line.RevenueID])
+		}
+
+		allocationID := c.GetId() + "-" + paymentID
+		if deps.NewID != nil {
+			allocationID = deps.NewID()
+		}
+		if err := deps.Allocations.InsertPaymentAllocation(ctx, &centymoshared.PaymentAllocation{
+			ID:               allocationID,
+			CollectionID:     c.GetId(),
+			ClientID:         c.GetClientId(),
+			RevenueID:        line.RevenueID,
+			Reference:        references[line.RevenueID],
+			RevenuePaymentID: paymentID,
+			Currency:         c.GetCurrency(),
+			Amount:           line.Amount,
+			AllocatedAt:      time.Now(),
+			AllocatedBy:      by,
+		}, centymoshared.AllocationLimits{Collection: c.GetAmount(), Invoice: invoiceLimits[line.RevenueID]}); err != nil {
+			if _, derr := deps.DeleteRevenuePayment(ctx, &revenuepaymentpb.DeleteRevenuePaymentRequest{
+				Data: &revenuepaymentpb.RevenuePayment{Id: paymentID},
+			}); derr != nil {
+				log.Printf("Failed to delete payment %s of unsaved allocation: %v", paymentID, derr)
+			}
+			return fmt.Errorf("failed to save allocation to %s: %w", references[line.RevenueID], err)
+		}
+	}
+	return nil
+}
+
+// invoiceRows lists open oldest first with the oldest-first suggestion of
+// unapplied.
+func invoiceRows(open []*centymoshared.AgingLine, unapplied int64) []InvoiceRow {
+	suggested := map[string]int64{}
+	for _, line := range centymoshared.AllocateOldestFirst(unapplied, open) {
+		suggested[line.RevenueID] = line.Amount
+	}
+	var rows []InvoiceRow
+	for _, inv := range centymoshared.OldestFirst(open) {
+		row := InvoiceRow{
+			RevenueID:      inv.RevenueID,
+			Reference:      inv.Reference,
+			DueDate:        inv.DueDate.Format("2006-01-02"),
+			BalanceDisplay: types.FormatMoney(inv.Balance, inv.Currency),
+			Balance:        decimal(inv.Balance),
+		}
+		if amount := suggested[inv.RevenueID]; amount > 0 {
+			row.Suggested = decimal(amount)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// buildTable lists c's allocations, newest first, with a Reverse action on
+// the applied ones and the Allocate drawer as the primary action.
+func buildTable(ctx context.Context, deps *Deps, c *collectionpb.Collection, allocations []*centymoshared.PaymentAllocation) *types.TableConfig {
+	l := deps.Labels.Allocation
+	perms := view.GetUserPermissions(ctx)
+	canReverse := perms.Can("collection", "update") && perms.Can("payment", "delete")
+
+	columns := []types.TableColumn{
+		{Key: "invoice", Label: l.Invoice},
+		{Key: "amount", Label: deps.Labels.Detail.Amount, WidthClass: "col-3xl"},
+		{Key: "allocated_at", Label: l.AllocatedAt, WidthClass: "col-4xl"},
+		{Key: "status", Label: l.Status, WidthClass: "col-3xl"},
+	}
+
+	rows := []types.TableRow{}
+	for i := len(allocations) - 1; i >= 0; i-- {
+		a := allocations[i]
+		status, variant := l.StatusApplied, "success"
+		if a.Reversed() {
+			status, variant = l.StatusReversed, "default"
+		}
+		amount := types.FormatMoney(a.Amount, a.Currency)
+		row := types.TableRow{
+			ID: a.ID,
+			Cells: []types.TableCell{
+				{Type: "text", Value: a.Reference},
+				{Type: "text", Value: amount},
+				{Type: "text", Value: a.AllocatedAt.Format(types.DateTimeReadable)},
+				{Type: "badge", Value: status, Variant: variant},
+			},
+		}
+		if !a.Reversed() {
+			row.Actions = []types.TableAction{{
+				Type:            "delete",
+				Label:           l.Reverse,
+				Action:          "reverse",
+				URL:             route.ResolveURL(deps.Routes.AllocationReverseURL, "id", c.GetId()),
+				ItemName:        a.Reference,
+				ConfirmTitle:    l.ReverseConfirm,
+				ConfirmMessage:  fmt.Sprintf(l.ReverseMessage, amount, a.Reference),
+				Disabled:        !canReverse,
+				DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+			}}
+		}
+		rows = append(rows, row)
+	}
+	types.ApplyColumnStyles(columns, rows)
+
+	unapplied := c.GetAmount() - centymoshared.AppliedAmount(allocations)
+	cfg := &types.TableConfig{
+		ID:         tableID,
+		RefreshURL: route.ResolveURL(deps.Routes.AllocationTableURL, "id", c.GetId()),
+		Columns:    columns,
+		Rows:       rows,
+		Labels:     deps.TableLabels,
+		EmptyState: types.TableEmptyState{
+			Title:   l.EmptyTitle,
+			Message: l.EmptyMessage,
+		},
+	}
+	if Allocatable(c) {
+		cfg.PrimaryAction = &types.PrimaryAction{
+			Label:           l.Allocate,
+			ActionURL:       route.ResolveURL(deps.Routes.AllocateURL, "id", c.GetId()),
+			Icon:            "icon-plus",
+			Disabled:        unapplied <= 0 || !perms.Can("collection", "update") || !perms.Can("payment", "create"),
+			DisabledTooltip: deps.Labels.Errors.PermissionDenied,
+		}
+	}
+	types.ApplyTableSettings(cfg)
+	return cfg
+}
+
+// readCollection reads the collection with id.
+func readCollection(ctx context.Context, deps *Deps, id string) (*collectionpb.Collection, error) {
+	resp, err := deps.ReadCollection(ctx, &collectionpb.ReadCollectionRequest{
+		Data: &collectionpb.Collection{Id: id},
+	})
+	if err != nil {
+		log.Printf("Failed to read collection %s: %v", id, err)
+		return nil, fmt.Errorf("failed to load collection: %w", err)
+	}
+	if len(resp.GetData()) == 0 {
+		return nil, fmt.Errorf("collection not found")
+	}
+	return resp.GetData()[0], nil
+}
+
+// errorMessage maps a shared allocation error to its label.
+func errorMessage(l collection.AllocationLabels, err error) string {
+	switch {
+	case errors.Is(err, centymoshared.ErrAllocationEmpty):
+		return l.ErrorEmpty
+	case errors.Is(err, centymoshared.ErrAllocationAmount):
+		return l.ErrorAmount
+	case errors.Is(err, centymoshared.ErrAllocationInvoice):
+		return l.ErrorInvoice
+	case errors.Is(err, centymoshared.ErrAllocationOverBalance):
+		return l.ErrorOverBalance
+	case errors.Is(err, centymoshared.ErrAllocationOverUnapplied):
+		return l.ErrorOverUnapplied
+	}
+	return err.Error()
+}
+
+func userID(ctx context.Context, deps *Deps) string {
+	if deps.UserID == nil {
+		return ""
+	}
+	return deps.UserID(ctx)
+}
+
+// decimal renders centavos as a plain decimal for number inputs, e.g.
+// 150000 → "1500.00".
+func decimal(centavos int64) string {
+	return fmt.Sprintf("%.2f", float64(centavos)/100)
+}
+
+// strPtr returns a pointer to s for optional proto string fields, or nil for
+// the empty string.
+func strPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	collection "github.com/erniealice/centymo-golang/domain/treasury/collection"
+	"github.com/erniealice/centymo-golang/domain/treasury/collection/allocation"
 	shared "github.com/erniealice/centymo-golang/domain/treasury/shared"
 
 	"github.com/erniealice/hybra-golang/views/attachment"
@@ -53,6 +54,10 @@ type DetailViewDeps struct {
 	// used by the per-row Recognize button. Empty means the button is hidden.
 	MilestoneRecognizeURL string
 
+	// Allocation backs the Allocations tab of client collections that are
+	// not tied to an invoice. Nil hides the tab.
+	Allocation *allocation.Deps
+
 	attachment.AttachmentOps
 	auditlog.AuditOps
 }
@@ -95,6 +100,8 @@ type PageData struct {
 	// 20260517-advance-cash-events Plan B Phase 7 — MILESTONE links rendered
 	// on the Advance Schedule tab when advance_kind == MILESTONE.
 	MilestoneLinks []MilestoneLinkRow
+	// Allocations tab
+	Allocation *allocation.TabData
 }
 
 // collectionToMap converts a Collection protobuf to a map[string]any for template use.
@@ -249,6 +256,9 @@ func NewView(deps *DetailViewDeps) view.View {
 		}
 		isAdvance, _ := collection["is_advance"].(bool)
 		tabItems := buildTabItemsWithAdvance(l, id, deps.Routes, isAdvance, deps.AdvanceLabels.Tab)
+		if showAllocations(deps, data[0]) {
+			tabItems = withAllocationsTab(tabItems, l, id, deps.Routes)
+		}
 
 		pageData := &PageData{
 			PageData: types.PageData{
@@ -295,6 +305,14 @@ func NewView(deps *DetailViewDeps) view.View {
 				}
 				pageData.AttachmentTable = attachment.BuildTable(items, cfg, id)
 			}
+		case "allocations":
+			if showAllocations(deps, data[0]) {
+				tab, err := allocation.LoadTab(ctx, deps.Allocation, data[0])
+				if err != nil {
+					return view.Error(err)
+				}
+				pageData.Allocation = tab
+			}
 		case "audit":
 			pageData.AuditTable = buildAuditTable(l, deps.TableLabels)
 		case "audit-history":
@@ -354,6 +372,25 @@ func buildTabItemsWithAdvance(l collection.Labels, id string, routes collection.
 	return items
 }
 
+// showAllocations reports whether c gets the Allocations tab: a client
+// collection not tied to an invoice, with the allocation views wired.
+func showAllocations(deps *DetailViewDeps, c *collectionpb.Collection) bool {
+	return allocation.Enabled(deps.Allocation) && c.GetClientId() != "" && c.GetRevenueId() == ""
+}
+
+// withAllocationsTab inserts the Allocations tab before Attachments.
+func withAllocationsTab(items []pyeza.TabItem, l collection.Labels, id string, routes collection.Routes) []pyeza.TabItem {
+	base := route.ResolveURL(routes.DetailURL, "id", id)
+	action := route.ResolveURL(routes.TabActionURL, "id", id, "tab", "")
+	tab := pyeza.TabItem{Key: "allocations", Label: l.Allocation.Tab, Href: base + "?tab=allocations", HxGet: action + "allocations", Icon: "icon-link"}
+	for i, item := range items {
+		if item.Key == "attachments" {
+			return append(items[:i], append([]pyeza.TabItem{tab}, items[i:]...)...)
+		}
+	}
+	return append(items, tab)
+}
+
 // NewTabAction creates the tab action view (partial — returns only the tab content).
 func NewTabAction(deps *DetailViewDeps) view.View {
 	return view.ViewFunc(func(ctx context.Context, viewCtx *view.ViewContext) view.ViewResult {
@@ -396,6 +433,9 @@ func NewTabAction(deps *DetailViewDeps) view.View {
 			ActiveTab:     tab,
 			TabItems:      buildTabItemsWithAdvance(l, id, deps.Routes, isAdvance, deps.AdvanceLabels.Tab),
 		}
+		if showAllocations(deps, data[0]) {
+			pageData.TabItems = withAllocationsTab(pageData.TabItems, l, id, deps.Routes)
+		}
 		// 20260517-advance-cash-events Plan B Phase 7 — preload milestone
 		// links when the active tab is advance-schedule and kind=MILESTONE.
 		if tab == "advance-schedule" {
@@ -420,6 +460,14 @@ func NewTabAction(deps *DetailViewDeps) view.View {
 				}
 				pageData.AttachmentTable = attachment.BuildTable(items, cfg, id)
 			}
+		case "allocations":
+			if showAllocations(deps, data[0]) {
+				tab, err := allocation.LoadTab(ctx, deps.Allocation, data[0])
+				if err != nil {
+					return view.Error(err)
+				}
+				pageData.Allocation = tab
+			}
 		case "audit":
 			pageData.AuditTable = buildAuditTable(l, deps.TableLabels)
 		case "audit-history":
@@ -25,6 +25,46 @@ type Labels struct {
 	Confirm   ConfirmLabels       `json:"confirm"`
 	Errors    ErrorLabels         `json:"errors"`
 	Dashboard CashDashboardLabels `json:"dashboard"`
+
+	// Allocation holds the strings of the Allocations tab and drawer that
+	// apply a collection to the client's open invoices.
+	Allocation AllocationLabels `json:"allocation"`
+}
+
+// AllocationLabels holds translatable strings for applying one collection to
+// many invoices: the Allocations tab, the allocation drawer and its errors.
+type AllocationLabels struct {
+	Tab            string `json:"tab"`
+	Amount         string `json:"amount"`
+	Applied        string `json:"applied"`
+	Unapplied      string `json:"unapplied"`
+	Allocate       string `json:"allocate"`
+	AutoAllocate   string `json:"autoAllocate"`
+	Invoice        string `json:"invoice"`
+	DueDate        string `json:"dueDate"`
+	Balance        string `json:"balance"`
+	AmountToApply  string `json:"amountToApply"`
+	AmountInfo     string `json:"amountInfo"`
+	AllocatedAt    string `json:"allocatedAt"`
+	Status         string `json:"status"`
+	StatusApplied  string `json:"statusApplied"`
+	StatusReversed string `json:"statusReversed"`
+	Reverse        string `json:"reverse"`
+	ReverseConfirm string `json:"reverseConfirm"`
+	ReverseMessage string `json:"reverseMessage"` // %s amount, %s invoice
+	EmptyTitle     string `json:"emptyTitle"`
+	EmptyMessage   string `json:"emptyMessage"`
+	NoOpenInvoices string `json:"noOpenInvoices"`
+	NotAllocatable string `json:"notAllocatable"`
+	PaymentNotes   string `json:"paymentNotes"` // %s collection reference
+
+	ErrorEmpty         string `json:"errorEmpty"`
+	ErrorAmount        string `json:"errorAmount"`
+	ErrorInvoice       string `json:"errorInvoice"`
+	ErrorOverBalance   string `json:"errorOverBalance"`
+	ErrorOverUnapplied string `json:"errorOverUnapplied"`
+	ErrorReversed      string `json:"errorReversed"`
+	ErrorNotFound      string `json:"errorNotFound"`
 }
 
 // CashDashboardLabels holds translatable strings for the cash (collection)
@@ -348,5 +388,37 @@ func DefaultLabels() Labels {
 			NewCollection:      "New collection",
 			CollectionUpdated:  "Collection updated",
 		},
+		Allocation: AllocationLabels{
+			Tab:                "Allocations",
+			Amount:             "Collection Amount",
+			Applied:            "Applied",
+			Unapplied:          "Unapplied Credit",
+			Allocate:           "Allocate to Invoices",
+			AutoAllocate:       "Allocate Oldest First",
+			Invoice:            "Invoice",
+			DueDate:            "Due Date",
+			Balance:            "Balance",
+			AmountToApply:      "Amount to Apply",
+			AmountInfo:         "Amounts are prefilled oldest invoice first. Clear an amount to skip the invoice; anything not applied stays as unapplied credit.",
+			AllocatedAt:        "Applied On",
+			Status:             "Status",
+			StatusApplied:      "Applied",
+			StatusReversed:     "Reversed",
+			Reverse:            "Reverse",
+			ReverseConfirm:     "Reverse Allocation",
+			ReverseMessage:     "Reverse the %s applied to %s? The invoice's payment is removed and the amount returns to unapplied credit.",
+			EmptyTitle:         "No allocations",
+			EmptyMessage:       "Amounts applied from this collection to invoices appear here.",
+			NoOpenInvoices:     "The client has no open invoices in this currency.",
+			NotAllocatable:     "Only completed collections from a client that are not tied to an invoice can be allocated.",
+			PaymentNotes:       "Applied from collection %s",
+			ErrorEmpty:         "Enter an amount for at least one invoice.",
+			ErrorAmount:        "Enter amounts as positive numbers.",
+			ErrorInvoice:       "An invoice is no longer open. Reload and try again.",
+			ErrorOverBalance:   "An amount is more than the invoice's balance.",
+			ErrorOverUnapplied: "The amounts add up to more than the unapplied credit.",
+			ErrorReversed:      "This allocation was already reversed.",
+			ErrorNotFound:      "Allocation not found",
+		},
 	}
 }
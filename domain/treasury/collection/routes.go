@@ -35,6 +35,12 @@ const (
 	TreasuryCollectionSettleURL = "/action/collection/settle/{id}"
 	TreasuryCollectionRefundURL = "/action/collection/refund/{id}"
 	TreasuryCollectionCancelURL = "/action/collection/cancel/{id}"
+
+	// Payment allocation — apply one collection to many invoices. The
+	// reverse action takes the allocation as ?id=.
+	AllocateURL          = "/action/collection/allocate/{id}"
+	AllocationReverseURL = "/action/collection/unallocate/{id}"
+	AllocationTableURL   = "/action/collection/detail/{id}/allocations/table"
 )
 
 // Routes holds all route paths for collection (money IN) views and actions.
@@ -61,6 +67,11 @@ type Routes struct {
 	SettleURL             string `json:"settle_url"`
 	RefundURL             string `json:"refund_url"`
 	CancelURL             string `json:"cancel_url"`
+
+	// Payment allocation routes. Empty hides the Allocations tab.
+	AllocateURL          string `json:"allocate_url"`
+	AllocationReverseURL string `json:"allocation_reverse_url"`
+	AllocationTableURL   string `json:"allocation_table_url"`
 }
 
 // DefaultRoutes returns a Routes populated from the package-level route
@@ -86,6 +97,10 @@ func DefaultRoutes() Routes {
 		SettleURL:             TreasuryCollectionSettleURL,
 		RefundURL:             TreasuryCollectionRefundURL,
 		CancelURL:             TreasuryCollectionCancelURL,
+
+		AllocateURL:          AllocateURL,
+		AllocationReverseURL: AllocationReverseURL,
+		AllocationTableURL:   AllocationTableURL,
 	}
 }
 
@@ -112,5 +127,9 @@ func (r Routes) RouteMap() map[string]string {
 		"collection.settle":               r.SettleURL,
 		"collection.refund":               r.RefundURL,
 		"collection.cancel":               r.CancelURL,
+
+		"collection.allocate":           r.AllocateURL,
+		"collection.allocation.reverse": r.AllocationReverseURL,
+		"collection.allocation.table":   r.AllocationTableURL,
 	}
 }
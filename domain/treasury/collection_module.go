@@ -2,17 +2,21 @@ package treasury
 
 import (
 	"context"
+	"time"
 
+	centymoshared "github.com/erniealice/centymo-golang/domain/shared"
 	shared "github.com/erniealice/centymo-golang/domain/treasury/shared"
 	pyeza "github.com/erniealice/pyeza-golang"
 	"github.com/erniealice/pyeza-golang/types"
 	"github.com/erniealice/pyeza-golang/view"
 
 	attachmentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/document/attachment"
+	revenuepaymentpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_payment"
 	collectionpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/treasury/collection"
 
 	epkg "github.com/erniealice/centymo-golang/domain/treasury/collection"
 	collectionaction "github.com/erniealice/centymo-golang/domain/treasury/collection/action"
+	collectionallocation "github.com/erniealice/centymo-golang/domain/treasury/collection/allocation"
 	collectiondashboard "github.com/erniealice/centymo-golang/domain/treasury/collection/dashboard"
 	collectiondetail "github.com/erniealice/centymo-golang/domain/treasury/collection/detail"
 	collectionlist "github.com/erniealice/centymo-golang/domain/treasury/collection/list"
@@ -58,6 +62,24 @@ type CollectionModuleDeps struct {
 	SettleUnscheduledAdvance func(ctx context.Context, in shared.AdvanceSettleViewInput) (*shared.AdvanceSettleViewOutput, error)
 	RefundUnscheduledAdvance func(ctx context.Context, in shared.AdvanceRefundViewInput) (*shared.AdvanceRefundViewOutput, error)
 	CancelAdvance            func(ctx context.Context, in shared.AdvanceCancelViewInput) (*shared.AdvanceCancelViewOutput, error)
+
+	// SnapshotFXRate records the exchange rate of a new foreign-currency
+	// collection (optional). The orchestrator binds the FX rate store and the
+	// workspace's functional currency.
+	SnapshotFXRate func(ctx context.Context, id, currency string, on time.Time) error
+
+	// DocumentNumbering fills a blank reference number from the workspace's
+	// official_receipt sequence. Optional — the typed number is kept when nil.
+	DocumentNumbering *centymoshared.DocumentNumbering
+
+	// Payment allocation (optional). Applies a client collection to the
+	// client's open invoices through revenue payments; the Allocations tab
+	// is hidden unless the store and every function are set.
+	PaymentAllocations   centymoshared.PaymentAllocationStore
+	ListOpenInvoices     func(ctx context.Context, clientID, currency string) ([]*centymoshared.AgingLine, error)
+	CreateRevenuePayment func(ctx context.Context, req *revenuepaymentpb.CreateRevenuePaymentRequest) (*revenuepaymentpb.CreateRevenuePaymentResponse, error)
+	DeleteRevenuePayment func(ctx context.Context, req *revenuepaymentpb.DeleteRevenuePaymentRequest) (*revenuepaymentpb.DeleteRevenuePaymentResponse, error)
+	ExtractUserID        func(ctx context.Context) string
 }
 
 // CollectionModule holds all constructed collection views.
@@ -79,6 +101,10 @@ type CollectionModule struct {
 	AdvanceSettle view.View
 	AdvanceRefund view.View
 	AdvanceCancel view.View
+	// Payment allocation (nil when not wired).
+	Allocate          view.View
+	AllocationReverse view.View
+	AllocationTable   view.View
 }
 
 // NewCollectionModule creates the collection module with all views wired.
@@ -91,6 +117,8 @@ func NewCollectionModule(deps *CollectionModuleDeps) *CollectionModule {
 		UpdateCollection:  deps.UpdateCollection,
 		DeleteCollection:  deps.DeleteCollection,
 		AdvanceEnumLabels: deps.AdvanceEnumLabels,
+		SnapshotFXRate:    deps.SnapshotFXRate,
+		Numbering:         deps.DocumentNumbering,
 	}
 
 	detailDeps := &collectiondetail.DetailViewDeps{
@@ -108,6 +136,22 @@ func NewCollectionModule(deps *CollectionModuleDeps) *CollectionModule {
 	detailDeps.DeleteAttachment = deps.DeleteAttachment
 	detailDeps.NewAttachmentID = deps.NewID
 
+	allocationDeps := &collectionallocation.Deps{
+		Routes:               deps.Routes,
+		Labels:               deps.Labels,
+		TableLabels:          deps.TableLabels,
+		ReadCollection:       deps.ReadCollection,
+		Allocations:          deps.PaymentAllocations,
+		ListOpenInvoices:     deps.ListOpenInvoices,
+		CreateRevenuePayment: deps.CreateRevenuePayment,
+		DeleteRevenuePayment: deps.DeleteRevenuePayment,
+		NewID:                deps.NewID,
+		UserID:               deps.ExtractUserID,
+	}
+	if collectionallocation.Enabled(allocationDeps) {
+		detailDeps.Allocation = allocationDeps
+	}
+
 	listView := collectionlist.NewView(&collectionlist.ListViewDeps{
 		Routes:          deps.Routes,
 		ListCollections: deps.ListCollections,
@@ -137,7 +181,7 @@ func NewCollectionModule(deps *CollectionModuleDeps) *CollectionModule {
 		Cancel:            deps.CancelAdvance,
 	}
 
-	return &CollectionModule{
+	m := &CollectionModule{
 		routes:           deps.Routes,
 		Dashboard:        dashboardView,
 		List:             listView,
@@ -155,6 +199,12 @@ func NewCollectionModule(deps *CollectionModuleDeps) *CollectionModule {
 		AdvanceRefund:    collectionaction.NewRefundAction(advanceActionDeps),
 		AdvanceCancel:    collectionaction.NewCancelAction(advanceActionDeps),
 	}
+	if detailDeps.Allocation != nil {
+		m.Allocate = collectionallocation.NewAllocateAction(allocationDeps)
+		m.AllocationReverse = collectionallocation.NewReverseAction(allocationDeps)
+		m.AllocationTable = collectionallocation.NewTableView(allocationDeps)
+	}
+	return m
 }
 
 // RegisterRoutes registers all collection routes.
@@ -190,4 +240,11 @@ func (m *CollectionModule) RegisterRoutes(r view.RouteRegistrar) {
 		r.GET(m.routes.CancelURL, m.AdvanceCancel)
 		r.POST(m.routes.CancelURL, m.AdvanceCancel)
 	}
+	// Payment allocation
+	if m.Allocate != nil {
+		r.GET(m.routes.AllocateURL, m.Allocate)
+		r.POST(m.routes.AllocateURL, m.Allocate)
+		r.POST(m.routes.AllocationReverseURL, m.AllocationReverse)
+		r.GET(m.routes.AllocationTableURL, m.AllocationTable)
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	disbursement "github.com/erniealice/centymo-golang/domain/treasury/disbursement"
 
@@ -30,6 +31,10 @@ type Deps struct {
 
 	// Expenditure (bill) listing (optional — gracefully degrades to empty list if nil)
 	ListExpenditures func(ctx context.Context, req *expenditurepb.ListExpendituresRequest) (*expenditurepb.ListExpendituresResponse, error)
+
+	// SnapshotFXRate records the exchange rate a new disbursement in a foreign
+	// currency is issued at (optional — no snapshot is taken when nil).
+	SnapshotFXRate func(ctx context.Context, id, currency string, on time.Time) error
 }
 
 // loadExpenditureOptions loads unpaid expenditures (bills) for the dropdown.
@@ -119,6 +124,11 @@ func NewAddAction(deps *Deps) view.View {
 		if respData := resp.GetData(); len(respData) > 0 {
 			newID = respData[0].GetId()
 		}
+		if newID != "" && deps.SnapshotFXRate != nil {
+			if err := deps.SnapshotFXRate(ctx, newID, r.FormValue("currency"), time.Now()); err != nil {
+				log.Printf("Failed to snapshot FX rate for disbursement %s: %v", newID, err)
+			}
+		}
 		if newID != "" {
 			return view.ViewResult{
 				StatusCode: http.StatusOK,
@@ -2,6 +2,7 @@ package treasury
 
 import (
 	"context"
+	"time"
 
 	shared "github.com/erniealice/centymo-golang/domain/treasury/shared"
 	pyeza "github.com/erniealice/pyeza-golang"
@@ -48,6 +49,11 @@ type DisbursementModuleDeps struct {
 	SettleUnscheduledAdvance func(ctx context.Context, in shared.AdvanceSettleViewInput) (*shared.AdvanceSettleViewOutput, error)
 	RefundUnscheduledAdvance func(ctx context.Context, in shared.AdvanceRefundViewInput) (*shared.AdvanceRefundViewOutput, error)
 	CancelAdvance            func(ctx context.Context, in shared.AdvanceCancelViewInput) (*shared.AdvanceCancelViewOutput, error)
+
+	// SnapshotFXRate records the exchange rate of a new foreign-currency
+	// disbursement (optional). The orchestrator binds the FX rate store and the
+	// workspace's functional currency.
+	SnapshotFXRate func(ctx context.Context, id, currency string, on time.Time) error
 }
 
 // DisbursementModule holds all constructed disbursement views.
@@ -105,6 +111,7 @@ func NewDisbursementModule(deps *DisbursementModuleDeps) *DisbursementModule {
 		UpdateDisbursement: deps.UpdateDisbursement,
 		DeleteDisbursement: deps.DeleteDisbursement,
 		ListExpenditures:   deps.ListExpenditures,
+		SnapshotFXRate:     deps.SnapshotFXRate,
 	}
 
 	// 20260517-advance-cash-events Plan B Phase 4 — UNSCHEDULED workflow.
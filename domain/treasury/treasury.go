@@ -57,6 +57,7 @@ type (
 	AdvancesDashboardTableLabels   = advancesdashboardpkg.TableLabels
 	CashDashboardLabels            = collectionpkg.CashDashboardLabels
 	CollectionActionLabels         = collectionpkg.ActionLabels
+	CollectionAllocationLabels     = collectionpkg.AllocationLabels
 	CollectionBulkLabels           = collectionpkg.BulkLabels
 	CollectionButtonLabels         = collectionpkg.ButtonLabels
 	CollectionColumnLabels         = collectionpkg.ColumnLabels
@@ -91,6 +92,9 @@ const (
 	AdvanceDisbursementListURL                = advancesdashboardpkg.AdvanceDisbursementListURL
 	AdvancesDashboardURL                      = advancesdashboardpkg.AdvancesDashboardURL
 	CollectionAddURL                          = collectionpkg.AddURL
+	CollectionAllocateURL                     = collectionpkg.AllocateURL
+	CollectionAllocationReverseURL            = collectionpkg.AllocationReverseURL
+	CollectionAllocationTableURL              = collectionpkg.AllocationTableURL
 	CollectionAttachmentDeleteURL             = collectionpkg.AttachmentDeleteURL
 	CollectionAttachmentUploadURL             = collectionpkg.AttachmentUploadURL
 	CollectionBulkDeleteURL                   = collectionpkg.BulkDeleteURL
@@ -0,0 +1,315 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+)
+
+// StockLevel is the available quantity of one inventory item (one product or
+// variant at one location).
+type StockLevel struct {
+	InventoryItemID string
+	LocationID      string
+	Available       float64
+}
+
+// Allocation reserves Quantity units of an item at one inventory item.
+type Allocation struct {
+	InventoryItemID string
+	LocationID      string
+	Quantity        int
+}
+
+// ItemAllocation is the plan for one CheckoutItem. Tracked is false when the
+// product has no inventory records at all; such items are sold without a
+// reservation, at the requested location.
+type ItemAllocation struct {
+	ItemIndex   int
+	ProductID   string
+	Tracked     bool
+	Allocations []Allocation
+}
+
+// AllocationPlan holds one ItemAllocation per CheckoutRequest item, in order.
+type AllocationPlan struct {
+	Items []ItemAllocation
+}
+
+// InsufficientStockError is returned when the allocation strategy cannot
+// cover an item's quantity from the stock it is allowed to use.
+type InsufficientStockError struct {
+	ProductID  string
+	LocationID string // requested location
+	Requested  int
+	Available  int // what the strategy could have reserved
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %s at %s: requested %d, available %d",
+		e.ProductID, e.LocationID, e.Requested, e.Available)
+}
+
+// Is lets callers match both a failed plan and a reservation that lost a race
+// with errors.Is(err, shared.ErrInsufficientStock).
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == shared.ErrInsufficientStock
+}
+
+// AllocationStrategy decides where an item's quantity is reserved. stock lists
+// every inventory record for the item's product (and variant) with available
+// quantity; the strategy returns allocations summing to item.Quantity or an
+// *InsufficientStockError.
+type AllocationStrategy interface {
+	Allocate(item CheckoutItem, stock []StockLevel) ([]Allocation, error)
+}
+
+// LocationRanker orders candidate locations from most to least preferred for
+// an order placed at origin — typically by distance. Locations it leaves out
+// are never used. A nil ranker puts origin first, then the rest by most stock.
+type LocationRanker func(origin string, candidates []string) []string
+
+// StrictAllocation reserves the whole quantity at the item's own location and
+// fails if that location is short. This is the default.
+type StrictAllocation struct{}
+
+func (StrictAllocation) Allocate(item CheckoutItem, stock []StockLevel) ([]Allocation, error) {
+	for _, lvl := range stock {
+		if lvl.LocationID == item.LocationID && wholeUnits(lvl.Available) >= item.Quantity {
+			return []Allocation{{InventoryItemID: lvl.InventoryItemID, LocationID: lvl.LocationID, Quantity: item.Quantity}}, nil
+		}
+	}
+	return nil, &InsufficientStockError{
+		ProductID:  item.ProductID,
+		LocationID: item.LocationID,
+		Requested:  item.Quantity,
+		Available:  bestSingle(stock, func(l StockLevel) bool { return l.LocationID == item.LocationID }),
+	}
+}
+
+// FallbackAllocation reserves the whole quantity at a single location: the
+// item's own location if it can cover it, otherwise the first location in
+// Rank order that can. Use it for "nearest branch" or preferred-location
+// fulfilment where one order line must ship from one place.
+type FallbackAllocation struct {
+	Rank LocationRanker
+}
+
+func (f FallbackAllocation) Allocate(item CheckoutItem, stock []StockLevel) ([]Allocation, error) {
+	for _, lvl := range rankStock(item.LocationID, stock, f.Rank) {
+		if wholeUnits(lvl.Available) >= item.Quantity {
+			return []Allocation{{InventoryItemID: lvl.InventoryItemID, LocationID: lvl.LocationID, Quantity: item.Quantity}}, nil
+		}
+	}
+	return nil, &InsufficientStockError{
+		ProductID:  item.ProductID,
+		LocationID: item.LocationID,
+		Requested:  item.Quantity,
+		Available:  bestSingle(rankStock(item.LocationID, stock, f.Rank), func(StockLevel) bool { return true }),
+	}
+}
+
+// SplitAllocation fills the quantity across as many locations as needed, in
+// Rank order starting with the item's own location.
+type SplitAllocation struct {
+	Rank LocationRanker
+}
+
+func (sp SplitAllocation) Allocate(item CheckoutItem, stock []StockLevel) ([]Allocation, error) {
+	remaining := item.Quantity
+	var allocations []Allocation
+	for _, lvl := range rankStock(item.LocationID, stock, sp.Rank) {
+		if remaining == 0 {
+			break
+		}
+		take := min(wholeUnits(lvl.Available), remaining)
+		if take <= 0 {
+			continue
+		}
+		allocations = append(allocations, Allocation{InventoryItemID: lvl.InventoryItemID, LocationID: lvl.LocationID, Quantity: take})
+		remaining -= take
+	}
+	if remaining > 0 {
+		return nil, &InsufficientStockError{
+			ProductID:  item.ProductID,
+			LocationID: item.LocationID,
+			Requested:  item.Quantity,
+			Available:  item.Quantity - remaining,
+		}
+	}
+	return allocations, nil
+}
+
+// rankStock orders stock by location preference. Without a ranker the origin
+// comes first, then other locations by most available, then by ID so the
+// result is deterministic.
+func rankStock(origin string, stock []StockLevel, rank LocationRanker) []StockLevel {
+	if rank == nil {
+		ranked := append([]StockLevel(nil), stock...)
+		sort.SliceStable(ranked, func(i, j int) bool {
+			a, b := ranked[i], ranked[j]
+			if (a.LocationID == origin) != (b.LocationID == origin) {
+				return a.LocationID == origin
+			}
+			if a.Available != b.Available {
+				return a.Available > b.Available
+			}
+			return a.LocationID < b.LocationID
+		})
+		return ranked
+	}
+
+	byLocation := map[string][]StockLevel{}
+	var locations []string
+	for _, lvl := range stock {
+		if _, seen := byLocation[lvl.LocationID]; !seen {
+			locations = append(locations, lvl.LocationID)
+		}
+		byLocation[lvl.LocationID] = append(byLocation[lvl.LocationID], lvl)
+	}
+	var ranked []StockLevel
+	for _, loc := range rank(origin, locations) {
+		ranked = append(ranked, byLocation[loc]...)
+		delete(byLocation, loc) // a ranker repeating a location must not double-count it
+	}
+	return ranked
+}
+
+// wholeUnits floors a stock quantity to whole sellable units.
+func wholeUnits(q float64) int {
+	if q <= 0 {
+		return 0
+	}
+	return int(math.Floor(q))
+}
+
+// bestSingle is the largest whole quantity any single matching stock level holds.
+func bestSingle(stock []StockLevel, match func(StockLevel) bool) int {
+	best := 0
+	for _, lvl := range stock {
+		if match(lvl) {
+			best = max(best, wholeUnits(lvl.Available))
+		}
+	}
+	return best
+}
+
+// allocationStrategy returns the configured strategy, defaulting to strict.
+func (s *Service) allocationStrategy() AllocationStrategy {
+	if s.deps.Allocation != nil {
+		return s.deps.Allocation
+	}
+	return StrictAllocation{}
+}
+
+// PlanAllocation builds the allocation plan for items without reserving
+// anything. PlaceOrder uses it before writing the revenue; storefronts can
+// call it to show where each item will be fulfilled from. Items are planned
+// in order, and what earlier items take is no longer available to later ones,
+// so two lines for the same product cannot both count the same stock.
+func (s *Service) PlanAllocation(ctx context.Context, items []CheckoutItem) (*AllocationPlan, error) {
+	plan := &AllocationPlan{Items: make([]ItemAllocation, len(items))}
+	strategy := s.allocationStrategy()
+	planned := map[string]int{} // inventory item ID → quantity taken by earlier items
+
+	for i, item := range items {
+		plan.Items[i] = ItemAllocation{ItemIndex: i, ProductID: item.ProductID}
+		if s.deps.ListInventoryItems == nil || s.deps.UpdateInventoryItem == nil || item.Quantity <= 0 {
+			continue
+		}
+
+		stock, err := s.stockLevels(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		if len(stock) == 0 {
+			continue // not stock-tracked
+		}
+		for j := range stock {
+			stock[j].Available -= float64(planned[stock[j].InventoryItemID])
+		}
+
+		allocations, err := strategy.Allocate(item, stock)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range allocations {
+			planned[a.InventoryItemID] += a.Quantity
+		}
+		plan.Items[i].Tracked = true
+		plan.Items[i].Allocations = allocations
+	}
+	return plan, nil
+}
+
+// stockLevels lists every active inventory record for the item's product (and
+// variant, when both sides carry one) across all locations.
+func (s *Service) stockLevels(ctx context.Context, item CheckoutItem) ([]StockLevel, error) {
+	listResp, err := s.deps.ListInventoryItems(ctx, &inventoryItempb.ListInventoryItemsRequest{
+		ProductId: &item.ProductID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list inventory for product %s: %w", item.ProductID, err)
+	}
+
+	var stock []StockLevel
+	for _, inv := range listResp.GetData() {
+		if !inv.GetActive() {
+			continue
+		}
+		if inv.GetProductId() != "" && inv.GetProductId() != item.ProductID {
+			continue
+		}
+		if item.VariantID != "" && inv.GetProductVariantId() != "" && inv.GetProductVariantId() != item.VariantID {
+			continue
+		}
+		stock = append(stock, StockLevel{
+			InventoryItemID: inv.GetId(),
+			LocationID:      inv.GetLocationId(),
+			Available:       inv.GetQuantityAvailable(),
+		})
+	}
+	return stock, nil
+}
+
+// applyAllocation expands items into one CheckoutItem per allocation, each
+// carrying its chosen location and inventory item. Untracked items pass
+// through unchanged. Split lines are priced at UnitPrice × Quantity, with the
+// last line absorbing any difference so the item total is preserved.
+func applyAllocation(items []CheckoutItem, plan *AllocationPlan) []CheckoutItem {
+	var lines []CheckoutItem
+	for i, item := range items {
+		ia := plan.Items[i]
+		if !ia.Tracked || len(ia.Allocations) == 0 {
+			lines = append(lines, item)
+			continue
+		}
+		if len(ia.Allocations) == 1 {
+			a := ia.Allocations[0]
+			item.LocationID = a.LocationID
+			item.inventoryItemID = a.InventoryItemID
+			lines = append(lines, item)
+			continue
+		}
+
+		allocatedTotal := 0
+		for j, a := range ia.Allocations {
+			line := item
+			line.LocationID = a.LocationID
+			line.inventoryItemID = a.InventoryItemID
+			line.Quantity = a.Quantity
+			line.TotalPrice = item.UnitPrice * a.Quantity
+			if j == len(ia.Allocations)-1 {
+				line.TotalPrice = item.TotalPrice - allocatedTotal
+			}
+			allocatedTotal += line.TotalPrice
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,446 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// branchStock is an in-memory inventory spread over several locations. It
+// honours the product_id / location_id filters of ListInventoryItems and
+// records the line items PlaceOrder writes.
+type branchStock struct {
+	mu        sync.Mutex
+	items     map[string]*inventoryItempb.InventoryItem // by inventory item ID
+	lineItems []*lineItempb.RevenueLineItem
+	// latency is slept before every inventory read returns, widening the
+	// read→write window the way a remote database would.
+	latency time.Duration
+}
+
+func newBranchStock(availableByLocation map[string]float64) *branchStock {
+	b := &branchStock{items: map[string]*inventoryItempb.InventoryItem{}}
+	for loc, qty := range availableByLocation {
+		id := "inv-" + loc
+		b.items[id] = &inventoryItempb.InventoryItem{
+			Id:                id,
+			ProductId:         ptr("prod-001"),
+			LocationId:        ptr(loc),
+			QuantityAvailable: qty,
+			QuantityOnHand:    qty,
+			Active:            true,
+		}
+	}
+	return b
+}
+
+func (b *branchStock) deps() CheckoutDeps {
+	deps := mockDeps()
+	deps.ListInventoryItems = func(_ context.Context, req *inventoryItempb.ListInventoryItemsRequest) (*inventoryItempb.ListInventoryItemsResponse, error) {
+		defer time.Sleep(b.latency)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		var data []*inventoryItempb.InventoryItem
+		for _, inv := range b.items {
+			if req.ProductId != nil && inv.GetProductId() != req.GetProductId() {
+				continue
+			}
+			if req.LocationId != nil && inv.GetLocationId() != req.GetLocationId() {
+				continue
+			}
+			data = append(data, proto.Clone(inv).(*inventoryItempb.InventoryItem))
+		}
+		sort.Slice(data, func(i, j int) bool { return data[i].GetId() < data[j].GetId() })
+		return &inventoryItempb.ListInventoryItemsResponse{Success: true, Data: data}, nil
+	}
+	deps.UpdateInventoryItem = func(_ context.Context, req *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		inv, ok := b.items[req.GetData().GetId()]
+		if !ok {
+			return nil, fmt.Errorf("inventory item %s not found", req.GetData().GetId())
+		}
+		inv.QuantityAvailable = req.GetData().GetQuantityAvailable()
+		inv.QuantityReserved = req.GetData().GetQuantityReserved()
+		return &inventoryItempb.UpdateInventoryItemResponse{Success: true}, nil
+	}
+	deps.ReadInventoryItem = func(_ context.Context, req *inventoryItempb.ReadInventoryItemRequest) (*inventoryItempb.ReadInventoryItemResponse, error) {
+		defer time.Sleep(b.latency)
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		inv, ok := b.items[req.GetData().GetId()]
+		if !ok {
+			return &inventoryItempb.ReadInventoryItemResponse{Success: true}, nil
+		}
+		return &inventoryItempb.ReadInventoryItemResponse{Success: true, Data: []*inventoryItempb.InventoryItem{proto.Clone(inv).(*inventoryItempb.InventoryItem)}}, nil
+	}
+	deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.lineItems = append(b.lineItems, req.GetData())
+		return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+	}
+	deps.ListSerials = nil
+	return deps
+}
+
+// adjust is an atomic AdjustInventoryQuantity over the in-memory store, as a
+// backend with a conditional UPDATE would provide.
+func (b *branchStock) adjust(_ context.Context, delta shared.InventoryDelta) (*inventoryItempb.InventoryItem, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	inv, ok := b.items[delta.InventoryItemID]
+	if !ok {
+		return nil, fmt.Errorf("inventory item %s not found", delta.InventoryItemID)
+	}
+	next, err := shared.ApplyInventoryDelta(inv, delta)
+	if err != nil {
+		return nil, err
+	}
+	inv.QuantityAvailable = next.GetQuantityAvailable()
+	inv.QuantityReserved = next.GetQuantityReserved()
+	inv.QuantityOnHand = next.GetQuantityOnHand()
+	return next, nil
+}
+
+func (b *branchStock) available(loc string) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.items["inv-"+loc].GetQuantityAvailable()
+}
+
+func (b *branchStock) reserved(loc string) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.items["inv-"+loc].GetQuantityReserved()
+}
+
+// orderOf returns sampleRequest for qty units of prod-001 at loc-001.
+func orderOf(qty int) CheckoutRequest {
+	req := sampleRequest()
+	req.Items[0].Quantity = qty
+	req.Items[0].TotalPrice = 10000 * qty
+	req.TotalAmount = 10000 * qty
+	return req
+}
+
+// ---------------------------------------------------------------------------
+// AllocationStrategy
+// ---------------------------------------------------------------------------
+
+func TestAllocationStrategies(t *testing.T) {
+	t.Parallel()
+
+	stock := []StockLevel{
+		{InventoryItemID: "inv-a", LocationID: "loc-a", Available: 1},
+		{InventoryItemID: "inv-b", LocationID: "loc-b", Available: 3},
+		{InventoryItemID: "inv-c", LocationID: "loc-c", Available: 2.5},
+	}
+	item := CheckoutItem{ProductID: "prod-001", LocationID: "loc-a", Quantity: 3}
+	// nearest-first for loc-a is loc-c, then loc-b
+	nearest := func(origin string, _ []string) []string { return []string{origin, "loc-c", "loc-b"} }
+
+	tests := []struct {
+		name     string
+		strategy AllocationStrategy
+		qty      int
+		want     string
+		short    int // expected InsufficientStockError.Available, -1 for success
+	}{
+		{"strict fits", StrictAllocation{}, 1, "[{inv-a loc-a 1}]", -1},
+		{"strict short", StrictAllocation{}, 3, "", 1},
+		{"fallback by stock", FallbackAllocation{}, 3, "[{inv-b loc-b 3}]", -1},
+		{"fallback ranked skips short branch", FallbackAllocation{Rank: nearest}, 3, "[{inv-b loc-b 3}]", -1},
+		{"fallback ranked nearest", FallbackAllocation{Rank: nearest}, 2, "[{inv-c loc-c 2}]", -1},
+		{"fallback short", FallbackAllocation{}, 4, "", 3},
+		{"split ranked", SplitAllocation{Rank: nearest}, 4, "[{inv-a loc-a 1} {inv-c loc-c 2} {inv-b loc-b 1}]", -1},
+		{"split by stock", SplitAllocation{}, 4, "[{inv-a loc-a 1} {inv-b loc-b 3}]", -1},
+		{"split floors fractional stock", SplitAllocation{}, 6, "[{inv-a loc-a 1} {inv-b loc-b 3} {inv-c loc-c 2}]", -1},
+		{"split short", SplitAllocation{}, 7, "", 6},
+		{"ranker excludes locations", SplitAllocation{Rank: func(o string, _ []string) []string { return []string{o} }}, 2, "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			it := item
+			it.Quantity = tt.qty
+			got, err := tt.strategy.Allocate(it, stock)
+			if tt.short >= 0 {
+				var insufficient *InsufficientStockError
+				if !errors.As(err, &insufficient) {
+					t.Fatalf("expected *InsufficientStockError, got %v (%v)", err, got)
+				}
+				if insufficient.Requested != tt.qty || insufficient.Available != tt.short {
+					t.Errorf("error = %+v, want requested %d available %d", insufficient, tt.qty, tt.short)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("allocations = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PlanAllocation
+// ---------------------------------------------------------------------------
+
+func TestPlanAllocation_SameProductLines(t *testing.T) {
+	t.Parallel()
+
+	line := CheckoutItem{ProductID: "prod-001", LocationID: "loc-001", Quantity: 2}
+
+	t.Run("later line plans against what is left", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 3, "loc-002": 5})
+		deps := b.deps()
+		deps.Allocation = SplitAllocation{}
+
+		plan, err := NewService(deps).PlanAllocation(context.Background(), []CheckoutItem{line, line})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := fmt.Sprint(plan.Items[0].Allocations, plan.Items[1].Allocations)
+		want := "[{inv-loc-001 loc-001 2}] [{inv-loc-001 loc-001 1} {inv-loc-002 loc-002 1}]"
+		if got != want {
+			t.Errorf("allocations = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("strict rejects lines that only fit one at a time", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 3})
+
+		_, err := NewService(b.deps()).PlanAllocation(context.Background(), []CheckoutItem{line, line})
+		var insufficient *InsufficientStockError
+		if !errors.As(err, &insufficient) || insufficient.Available != 1 {
+			t.Fatalf("expected *InsufficientStockError with 1 available, got %v", err)
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — allocation
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_Allocation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("split order writes one line item per location", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 2, "loc-002": 5})
+		deps := b.deps()
+		deps.Allocation = SplitAllocation{}
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), orderOf(4))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(b.lineItems) != 2 {
+			t.Fatalf("expected 2 line items, got %d", len(b.lineItems))
+		}
+		for i, want := range []struct {
+			loc, inv string
+			qty      float64
+			total    int64
+		}{{"loc-001", "inv-loc-001", 2, 20000}, {"loc-002", "inv-loc-002", 2, 20000}} {
+			li := b.lineItems[i]
+			if li.GetLocationId() != want.loc || li.GetInventoryItemId() != want.inv || li.GetQuantity() != want.qty || li.GetTotalPrice() != want.total {
+				t.Errorf("line %d = %s/%s qty %v total %d, want %s/%s qty %v total %d", i,
+					li.GetLocationId(), li.GetInventoryItemId(), li.GetQuantity(), li.GetTotalPrice(),
+					want.loc, want.inv, want.qty, want.total)
+			}
+		}
+		if b.reserved("loc-001") != 2 || b.reserved("loc-002") != 2 {
+			t.Errorf("reserved = %v/%v, want 2/2", b.reserved("loc-001"), b.reserved("loc-002"))
+		}
+		if len(result.Allocation.Items) != 1 || len(result.Allocation.Items[0].Allocations) != 2 {
+			t.Errorf("result allocation = %+v, want one item split in two", result.Allocation)
+		}
+	})
+
+	t.Run("fallback records the chosen location", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 1, "loc-002": 5})
+		deps := b.deps()
+		deps.Allocation = FallbackAllocation{}
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), orderOf(3)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(b.lineItems) != 1 || b.lineItems[0].GetLocationId() != "loc-002" {
+			t.Fatalf("line items = %v, want one at loc-002", b.lineItems)
+		}
+		if b.reserved("loc-001") != 0 || b.reserved("loc-002") != 3 {
+			t.Errorf("reserved = %v/%v, want 0/3", b.reserved("loc-001"), b.reserved("loc-002"))
+		}
+	})
+
+	t.Run("insufficient stock fails before any write", func(t *testing.T) {
+		t.Parallel()
+
+		revenueCreated := false
+		b := newBranchStock(map[string]float64{"loc-001": 1, "loc-002": 1})
+		deps := b.deps()
+		deps.Allocation = SplitAllocation{}
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenueCreated = true
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), orderOf(3))
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepAllocateStock {
+			t.Fatalf("expected StepError for %s, got %v", StepAllocateStock, err)
+		}
+		var insufficient *InsufficientStockError
+		if !errors.As(err, &insufficient) || insufficient.Available != 2 {
+			t.Errorf("expected *InsufficientStockError with 2 available, got %v", err)
+		}
+		if revenueCreated {
+			t.Error("CreateRevenue should not be called when stock is insufficient")
+		}
+		if b.reserved("loc-001") != 0 || b.reserved("loc-002") != 0 {
+			t.Error("nothing should be reserved")
+		}
+	})
+
+	t.Run("strict default rejects a short branch", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 1, "loc-002": 5})
+
+		_, err := NewService(b.deps()).PlaceOrder(context.Background(), orderOf(2))
+		var insufficient *InsufficientStockError
+		if !errors.As(err, &insufficient) {
+			t.Fatalf("expected *InsufficientStockError, got %v", err)
+		}
+	})
+
+	t.Run("untracked product is sold without reservation", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(nil)
+
+		result, err := NewService(b.deps()).PlaceOrder(context.Background(), orderOf(2))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(b.lineItems) != 1 || b.lineItems[0].GetLocationId() != "loc-001" || b.lineItems[0].GetInventoryItemId() != "" {
+			t.Errorf("line items = %v, want one untracked line at loc-001", b.lineItems)
+		}
+		if result.Allocation.Items[0].Tracked {
+			t.Error("untracked product should not be marked tracked")
+		}
+	})
+
+	t.Run("split line items release back to their own location", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBranchStock(map[string]float64{"loc-001": 2, "loc-002": 5})
+		deps := b.deps()
+		deps.Allocation = SplitAllocation{}
+		deps.ListLineItems = func(_ context.Context, _ *lineItempb.ListRevenueLineItemsRequest) (*lineItempb.ListRevenueLineItemsResponse, error) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return &lineItempb.ListRevenueLineItemsResponse{Success: true, Data: b.lineItems}, nil
+		}
+		svc := NewService(deps)
+
+		if _, err := svc.PlaceOrder(context.Background(), orderOf(4)); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		if err := svc.releaseReservations(context.Background(), "rev-001", "test"); err != nil {
+			t.Fatalf("releaseReservations: %v", err)
+		}
+		if b.reserved("loc-001") != 0 || b.reserved("loc-002") != 0 {
+			t.Errorf("reserved = %v/%v, want 0/0", b.reserved("loc-001"), b.reserved("loc-002"))
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — concurrent reservations
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_ParallelOrdersDoNotOversell(t *testing.T) {
+	t.Parallel()
+
+	const stock, orders = 5, 20
+
+	for _, tc := range []struct {
+		name   string
+		atomic bool
+	}{
+		{"atomic adjust", true},
+		{"serialized fallback", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := newBranchStock(map[string]float64{"loc-001": stock})
+			b.latency = time.Millisecond
+			deps := b.deps()
+			if tc.atomic {
+				deps.AdjustInventoryQuantity = b.adjust
+			}
+			svc := NewService(deps)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, orders)
+			for range orders {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := svc.PlaceOrder(context.Background(), orderOf(1))
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			placed := 0
+			for err := range errs {
+				switch {
+				case err == nil:
+					placed++
+				case errors.Is(err, shared.ErrInsufficientStock):
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if placed != stock {
+				t.Errorf("placed %d orders, want %d", placed, stock)
+			}
+			if b.available("loc-001") != 0 || b.reserved("loc-001") != stock {
+				t.Errorf("available/reserved = %v/%v, want 0/%d", b.available("loc-001"), b.reserved("loc-001"), stock)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	commonpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
+	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+)
+
+// Fulfillment errors returned by GetOrderFulfillment and AdvanceFulfillment.
+var (
+	ErrOrderNotFulfillable          = shared.ErrOrderNotFulfillable
+	ErrInvalidFulfillmentTransition = shared.ErrInvalidFulfillmentTransition
+)
+
+// serialReferenceSale is the serial_history reference type written when a
+// reserved serial is handed over to the customer.
+const serialReferenceSale = "sale"
+
+// FulfillmentEventStore keeps the fulfillment history of storefront orders.
+// Consumer apps back it with a table; MemoryFulfillmentEventStore serves tests
+// and single-process deployments.
+type FulfillmentEventStore interface {
+	// AppendFulfillmentEvent records one state change.
+	AppendFulfillmentEvent(ctx context.Context, e shared.FulfillmentEvent) error
+	// ListFulfillmentEvents returns revenueID's events, oldest first.
+	ListFulfillmentEvents(ctx context.Context, revenueID string) ([]shared.FulfillmentEvent, error)
+}
+
+// MemoryFulfillmentEventStore is an in-process FulfillmentEventStore.
+type MemoryFulfillmentEventStore struct {
+	mu     sync.Mutex
+	events map[string][]shared.FulfillmentEvent
+}
+
+// NewMemoryFulfillmentEventStore creates an empty MemoryFulfillmentEventStore.
+func NewMemoryFulfillmentEventStore() *MemoryFulfillmentEventStore {
+	return &MemoryFulfillmentEventStore{events: map[string][]shared.FulfillmentEvent{}}
+}
+
+func (m *MemoryFulfillmentEventStore) AppendFulfillmentEvent(_ context.Context, e shared.FulfillmentEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[e.RevenueID] = append(m.events[e.RevenueID], e)
+	return nil
+}
+
+func (m *MemoryFulfillmentEventStore) ListFulfillmentEvents(_ context.Context, revenueID string) ([]shared.FulfillmentEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events[revenueID]), nil
+}
+
+// fulfillable reports whether rev is a paid storefront order that still has
+// goods to hand over.
+func fulfillable(rev *revenuepb.Revenue) bool {
+	if rev.GetFulfillmentType() == "" || !strings.HasPrefix(rev.GetReferenceNumber(), "ORD-") {
+		return false
+	}
+	switch rev.GetStatus() {
+	case "paid", OrderStatusPartiallyReturned:
+		return true
+	default:
+		return false
+	}
+}
+
+// fulfillmentState is the order's current state; an order that has not
+// started fulfillment is queued.
+func fulfillmentState(rev *revenuepb.Revenue) string {
+	if st := rev.GetFulfillmentStatus(); st != "" {
+		return st
+	}
+	return shared.FulfillmentQueued
+}
+
+// orderFulfillment builds the fulfillment view of rev without its history.
+func orderFulfillment(rev *revenuepb.Revenue) *shared.OrderFulfillment {
+	state := fulfillmentState(rev)
+	of := &shared.OrderFulfillment{
+		RevenueID:       rev.GetId(),
+		ReferenceNumber: rev.GetReferenceNumber(),
+		CustomerName:    rev.GetClient().GetName(),
+		LocationID:      rev.GetLocationId(),
+		OrderStatus:     rev.GetStatus(),
+		FulfillmentType: rev.GetFulfillmentType(),
+		DeliveryAddress: rev.GetDeliveryAddress(),
+		State:           state,
+		TotalAmount:     rev.GetTotalAmount(),
+		Currency:        rev.GetCurrency(),
+	}
+	if ms := rev.GetDateCreated(); ms > 0 {
+		of.OrderedAt = time.UnixMilli(ms)
+	}
+	if fulfillable(rev) {
+		of.NextStates = shared.NextFulfillmentStates(of.FulfillmentType, state)
+	}
+	return of
+}
+
+// ListFulfillmentQueue returns the paid storefront orders at locationID (every
+// location when empty) that are not yet delivered, oldest first. Queue entries
+// carry no event history; use GetOrderFulfillment for that.
+func (s *Service) ListFulfillmentQueue(ctx context.Context, locationID string) ([]*shared.OrderFulfillment, error) {
+	if s.deps.ListRevenues == nil {
+		return nil, fmt.Errorf("checkout: fulfillment queue: ListRevenues not configured")
+	}
+	filters := []*commonpb.TypedFilter{
+		{
+			Field: "status",
+			FilterType: &commonpb.TypedFilter_ListFilter{
+				ListFilter: &commonpb.ListFilter{
+					Values:   []string{"paid", OrderStatusPartiallyReturned},
+					Operator: commonpb.ListOperator_LIST_IN,
+				},
+			},
+		},
+		{
+			Field: "reference_number",
+			FilterType: &commonpb.TypedFilter_StringFilter{
+				StringFilter: &commonpb.StringFilter{
+					Value:    "ORD-",
+					Operator: commonpb.StringOperator_STRING_STARTS_WITH,
+				},
+			},
+		},
+	}
+	if locationID != "" {
+		filters = append(filters, &commonpb.TypedFilter{
+			Field: "location_id",
+			FilterType: &commonpb.TypedFilter_StringFilter{
+				StringFilter: &commonpb.StringFilter{
+					Value:    locationID,
+					Operator: commonpb.StringOperator_STRING_EQUALS,
+				},
+			},
+		})
+	}
+	listResp, err := s.deps.ListRevenues(ctx, &revenuepb.ListRevenuesRequest{
+		Filters: &commonpb.FilterRequest{Logic: commonpb.FilterLogic_AND, Filters: filters},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkout: fulfillment queue: list orders: %w", err)
+	}
+
+	var queue []*shared.OrderFulfillment
+	for _, rev := range listResp.GetData() {
+		// Re-check in memory: the filters are a hint to the backend.
+		if !fulfillable(rev) || fulfillmentState(rev) == shared.FulfillmentDelivered {
+			continue
+		}
+		if locationID != "" && rev.GetLocationId() != locationID {
+			continue
+		}
+		queue = append(queue, orderFulfillment(rev))
+	}
+	sort.SliceStable(queue, func(a, b int) bool { return queue[a].OrderedAt.Before(queue[b].OrderedAt) })
+	return queue, nil
+}
+
+// GetOrderFulfillment returns the fulfillment state and history of the order
+// with the given ORD-xxxx-xxxx reference. Orders placed without a fulfillment
+// type return ErrOrderNotFulfillable.
+func (s *Service) GetOrderFulfillment(ctx context.Context, referenceNumber string) (*shared.OrderFulfillment, error) {
+	rev, err := s.findOrder(ctx, referenceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: fulfillment: %w", err)
+	}
+	if rev.GetFulfillmentType() == "" {
+		return nil, fmt.Errorf("checkout: fulfillment %s: %w (no fulfillment type)", referenceNumber, ErrOrderNotFulfillable)
+	}
+	of := orderFulfillment(rev)
+	if s.deps.FulfillmentEvents != nil {
+		events, err := s.deps.FulfillmentEvents.ListFulfillmentEvents(ctx, rev.GetId())
+		if err != nil {
+			return nil, fmt.Errorf("checkout: fulfillment %s: list events: %w", referenceNumber, err)
+		}
+		of.Events = events
+	}
+	return of, nil
+}
+
+// AdvanceFulfillment moves a paid storefront order to t.To, which must be one
+// of shared.NextFulfillmentStates for its current state, and records the
+// change with the acting user and time.
+//
+// Delivery is the handover: the order's reserved stock leaves the shelf
+// (reserved and on hand both drop) and its reserved serials move to sold. If
+// the state cannot be saved afterwards, the handover is undone and a
+// *StepError is returned.
+func (s *Service) AdvanceFulfillment(ctx context.Context, t shared.FulfillmentTransition) (*shared.OrderFulfillment, error) {
+	if s.deps.UpdateRevenue == nil {
+		return nil, fmt.Errorf("checkout: fulfillment: UpdateRevenue not configured")
+	}
+	if t.UserID == "" {
+		return nil, fmt.Errorf("checkout: fulfillment %s: %w: acting user required", t.ReferenceNumber, ErrInvalidFulfillmentTransition)
+	}
+	rev, err := s.findOrder(ctx, t.ReferenceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: fulfillment: %w", err)
+	}
+	unlock := lockOrder(rev.GetId())
+	defer unlock()
+	if rev, err = s.rereadOrder(ctx, rev); err != nil {
+		return nil, fmt.Errorf("checkout: fulfillment: %w", err)
+	}
+	if !fulfillable(rev) {
+		return nil, fmt.Errorf("checkout: fulfillment %s: %w (status %q)", t.ReferenceNumber, ErrOrderNotFulfillable, rev.GetStatus())
+	}
+	from := fulfillmentState(rev)
+	if !slices.Contains(shared.NextFulfillmentStates(rev.GetFulfillmentType(), from), t.To) {
+		return nil, fmt.Errorf("checkout: fulfillment %s: %w: %s -> %s", t.ReferenceNumber, ErrInvalidFulfillmentTransition, from, t.To)
+	}
+
+	revenueID := rev.GetId()
+	sg := &saga{}
+	if t.To == shared.FulfillmentDelivered {
+		if err := s.handOverStock(ctx, revenueID, sg); err != nil {
+			return nil, sg.fail(ctx, StepHandOverStock, err)
+		}
+		if err := s.sellSerials(ctx, revenueID, sg); err != nil {
+			return nil, sg.fail(ctx, StepSellSerials, err)
+		}
+	}
+
+	now := time.Now()
+	nowMillis := now.UnixMilli()
+	nowStr := now.Format(time.RFC3339)
+	if _, err := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+		Data: &revenuepb.Revenue{
+			Id:                 revenueID,
+			FulfillmentStatus:  &t.To,
+			DateModified:       &nowMillis,
+			DateModifiedString: &nowStr,
+		},
+	}); err != nil {
+		return nil, sg.fail(ctx, StepUpdateFulfillment, err)
+	}
+
+	event := shared.FulfillmentEvent{
+		RevenueID: revenueID,
+		From:      from,
+		To:        t.To,
+		UserID:    t.UserID,
+		Note:      t.Note,
+		At:        now,
+	}
+	if s.deps.FulfillmentEvents != nil {
+		// The state is already saved; a lost history entry is not worth
+		// undoing a handover for.
+		if err := s.deps.FulfillmentEvents.AppendFulfillmentEvent(ctx, event); err != nil {
+			log.Printf("checkout: fulfillment %s: record event %s -> %s: %v", revenueID, from, t.To, err)
+		}
+	}
+
+	rev.FulfillmentStatus = &t.To
+	of := orderFulfillment(rev)
+	if s.deps.FulfillmentEvents != nil {
+		if events, err := s.deps.FulfillmentEvents.ListFulfillmentEvents(ctx, revenueID); err == nil {
+			of.Events = events
+		}
+	} else {
+		of.Events = []shared.FulfillmentEvent{event}
+	}
+	return of, nil
+}
+
+// handOverStock takes the order's still-reserved quantities off the shelf:
+// quantity_reserved and quantity_on_hand both drop by what is left on each
+// item line after returns. Lines without an inventory record are skipped.
+func (s *Service) handOverStock(ctx context.Context, revenueID string, sg *saga) error {
+	if s.deps.ListLineItems == nil || s.deps.ListInventoryItems == nil {
+		return nil
+	}
+	adjust := shared.InventoryAdjuster(s.deps.AdjustInventoryQuantity, s.deps.ReadInventoryItem, s.deps.UpdateInventoryItem)
+	if adjust == nil {
+		return nil
+	}
+	lineResp, err := s.deps.ListLineItems(ctx, &lineItempb.ListRevenueLineItemsRequest{RevenueId: &revenueID})
+	if err != nil {
+		return fmt.Errorf("list line items: %w", err)
+	}
+	book := newReturnBook(lineResp.GetData())
+
+	for _, id := range book.order {
+		li := book.items[id]
+		qty := int(math.Round(li.GetQuantity())) - book.returned[id]
+		if qty <= 0 {
+			continue
+		}
+		invItem, err := s.findInventoryItem(ctx, CheckoutItem{
+			ProductID:       li.GetProductId(),
+			LocationID:      li.GetLocationId(),
+			inventoryItemID: li.GetInventoryItemId(),
+		})
+		if err != nil {
+			return err
+		}
+		if invItem == nil {
+			continue
+		}
+		delta := shared.InventoryDelta{InventoryItemID: invItem.GetId(), OnHand: -float64(qty), Reserved: -float64(qty)}
+		if _, err := adjust(ctx, delta); err != nil {
+			return fmt.Errorf("hand over product %s: %w", li.GetProductId(), err)
+		}
+		sg.record(StepHandOverStock, func(ctx context.Context) error {
+			_, err := adjust(ctx, shared.InventoryDelta{
+				InventoryItemID: delta.InventoryItemID,
+				OnHand:          -delta.OnHand,
+				Reserved:        -delta.Reserved,
+			})
+			return err
+		})
+	}
+	return nil
+}
+
+// sellSerials moves every serial the order still holds reserved to sold. Needs
+// ListSerialHistory to find the order's serials; without it serials are left
+// reserved and logged.
+func (s *Service) sellSerials(ctx context.Context, revenueID string, sg *saga) error {
+	if s.deps.ListSerials == nil || s.deps.UpdateSerial == nil {
+		return nil
+	}
+	if s.deps.ListSerialHistory == nil {
+		log.Printf("checkout: hand over %s: ListSerialHistory not configured, serials left reserved", revenueID)
+		return nil
+	}
+	owned, err := s.orderSerials(ctx, revenueID)
+	if err != nil {
+		return err
+	}
+	byItem := map[string][]string{}
+	for serialID, invItemID := range owned {
+		byItem[invItemID] = append(byItem[invItemID], serialID)
+	}
+
+	for invItemID, serialIDs := range byItem {
+		serialResp, err := s.deps.ListSerials(ctx, &serialpb.ListInventorySerialsRequest{InventoryItemId: &invItemID})
+		if err != nil {
+			return fmt.Errorf("list serials for %s: %w", invItemID, err)
+		}
+		current := map[string]*serialpb.InventorySerial{}
+		for _, serial := range serialResp.GetData() {
+			current[serial.GetId()] = serial
+		}
+		sort.Strings(serialIDs)
+		for _, serialID := range serialIDs {
+			serial, ok := current[serialID]
+			// Serials already returned or released are no longer the order's.
+			if !ok || serial.GetStatus() != "reserved" {
+				continue
+			}
+			if err := s.transitionSerial(ctx, serial, "reserved", "sold", serialReferenceSale, revenueID,
+				fmt.Sprintf("Handed over with order %s", revenueID)); err != nil {
+				return err
+			}
+			sg.record(StepSellSerials, func(ctx context.Context) error {
+				return s.transitionSerial(ctx, serial, "sold", "reserved", serialReferenceSale, revenueID,
+					fmt.Sprintf("Handover of order %s rolled back", revenueID))
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+)
+
+// advance moves the order through each state in turn as user-1.
+func advance(t *testing.T, svc *Service, states ...string) *shared.OrderFulfillment {
+	t.Helper()
+	var of *shared.OrderFulfillment
+	for _, to := range states {
+		var err error
+		of, err = svc.AdvanceFulfillment(context.Background(), shared.FulfillmentTransition{
+			ReferenceNumber: "ORD-test-0001", To: to, UserID: "user-1",
+		})
+		if err != nil {
+			t.Fatalf("advance to %s: %v", to, err)
+		}
+	}
+	return of
+}
+
+func TestNextFulfillmentStates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fulfillmentType, state string
+		want                   []string
+	}{
+		{shared.FulfillmentStorePickup, "", []string{shared.FulfillmentPicked}},
+		{shared.FulfillmentStorePickup, shared.FulfillmentPacked, []string{shared.FulfillmentReadyForPickup}},
+		{shared.FulfillmentHomeDelivery, shared.FulfillmentPacked, []string{shared.FulfillmentShipped}},
+		{shared.FulfillmentHomeDelivery, shared.FulfillmentShipped, []string{shared.FulfillmentDelivered, shared.FulfillmentFailedDelivery}},
+		{shared.FulfillmentHomeDelivery, shared.FulfillmentFailedDelivery, []string{shared.FulfillmentShipped}},
+		{shared.FulfillmentStorePickup, shared.FulfillmentDelivered, nil},
+	}
+	for _, tt := range tests {
+		got := shared.NextFulfillmentStates(tt.fulfillmentType, tt.state)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s/%s: got %v, want %v", tt.fulfillmentType, tt.state, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s/%s: got %v, want %v", tt.fulfillmentType, tt.state, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAdvanceFulfillment(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pickup order is handed over on delivery", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		st.fulfillmentType = shared.FulfillmentStorePickup
+		deps := st.deps()
+		deps.FulfillmentEvents = NewMemoryFulfillmentEventStore()
+		svc := NewService(deps)
+
+		of := advance(t, svc, shared.FulfillmentPicked, shared.FulfillmentPacked, shared.FulfillmentReadyForPickup)
+		if _, stock, serials := st.snapshot(); stock["inv-001"] != [3]float64{10, 8, 2} || serials["s-1"] != "reserved" {
+			t.Fatalf("stock %v, serials %v; want still reserved before handover", stock, serials)
+		}
+		if of.State != shared.FulfillmentReadyForPickup || len(of.NextStates) != 2 {
+			t.Errorf("State %q, NextStates %v", of.State, of.NextStates)
+		}
+
+		of = advance(t, svc, shared.FulfillmentDelivered)
+		_, stock, serials := st.snapshot()
+		if stock["inv-001"] != [3]float64{8, 8, 0} || stock["inv-002"] != [3]float64{4, 4, 0} {
+			t.Errorf("stock = %v, want reserved quantities taken off hand", stock)
+		}
+		if serials["s-1"] != "sold" || serials["s-2"] != "sold" {
+			t.Errorf("serials = %v, want sold", serials)
+		}
+		if len(of.NextStates) != 0 {
+			t.Errorf("NextStates = %v, want none after delivery", of.NextStates)
+		}
+		if len(of.Events) != 4 || of.Events[0].From != shared.FulfillmentQueued || of.Events[3].To != shared.FulfillmentDelivered {
+			t.Fatalf("Events = %+v", of.Events)
+		}
+		for _, e := range of.Events {
+			if e.UserID != "user-1" || e.At.IsZero() {
+				t.Errorf("event %+v missing user or time", e)
+			}
+		}
+	})
+
+	t.Run("delivery order cannot skip shipping", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		st.fulfillmentType = shared.FulfillmentHomeDelivery
+		svc := NewService(st.deps())
+		advance(t, svc, shared.FulfillmentPicked, shared.FulfillmentPacked)
+
+		_, err := svc.AdvanceFulfillment(context.Background(), shared.FulfillmentTransition{
+			ReferenceNumber: "ORD-test-0001", To: shared.FulfillmentReadyForPickup, UserID: "user-1",
+		})
+		if !errors.Is(err, ErrInvalidFulfillmentTransition) {
+			t.Fatalf("expected ErrInvalidFulfillmentTransition, got %v", err)
+		}
+		advance(t, svc, shared.FulfillmentShipped, shared.FulfillmentFailedDelivery, shared.FulfillmentShipped)
+		if st.fulfillment != shared.FulfillmentShipped {
+			t.Errorf("fulfillment = %q, want shipped", st.fulfillment)
+		}
+	})
+
+	t.Run("failed save undoes the handover", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		st.fulfillmentType = shared.FulfillmentStorePickup
+		st.fulfillment = shared.FulfillmentReadyForPickup
+		st.updateErr = errors.New("revenue store down")
+
+		_, err := NewService(st.deps()).AdvanceFulfillment(context.Background(), shared.FulfillmentTransition{
+			ReferenceNumber: "ORD-test-0001", To: shared.FulfillmentDelivered, UserID: "user-1",
+		})
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepUpdateFulfillment {
+			t.Fatalf("expected StepError for %s, got %v", StepUpdateFulfillment, err)
+		}
+		_, stock, serials := st.snapshot()
+		if stock["inv-001"] != [3]float64{10, 8, 2} || serials["s-1"] != "reserved" {
+			t.Errorf("stock %v, serials %v; want handover undone", stock, serials)
+		}
+	})
+
+	t.Run("rejected orders", func(t *testing.T) {
+		t.Parallel()
+
+		pending := newOrderStore(t, "pending", false)
+		pending.fulfillmentType = shared.FulfillmentStorePickup
+		noType := newOrderStore(t, "paid", false)
+
+		for name, st := range map[string]*orderStore{"pending": pending, "no fulfillment type": noType} {
+			_, err := NewService(st.deps()).AdvanceFulfillment(context.Background(), shared.FulfillmentTransition{
+				ReferenceNumber: "ORD-test-0001", To: shared.FulfillmentPicked, UserID: "user-1",
+			})
+			if !errors.Is(err, ErrOrderNotFulfillable) {
+				t.Errorf("%s: expected ErrOrderNotFulfillable, got %v", name, err)
+			}
+		}
+
+		st := newOrderStore(t, "paid", false)
+		st.fulfillmentType = shared.FulfillmentStorePickup
+		_, err := NewService(st.deps()).AdvanceFulfillment(context.Background(), shared.FulfillmentTransition{
+			ReferenceNumber: "ORD-test-0001", To: shared.FulfillmentPicked,
+		})
+		if !errors.Is(err, ErrInvalidFulfillmentTransition) {
+			t.Errorf("missing user: expected ErrInvalidFulfillmentTransition, got %v", err)
+		}
+	})
+}
+
+func TestListFulfillmentQueue(t *testing.T) {
+	t.Parallel()
+
+	revs := []*revenuepb.Revenue{
+		{Id: "r-new", ReferenceNumber: ptr("ORD-0000-0002"), Status: "paid", LocationId: "loc-001",
+			FulfillmentType: ptr(shared.FulfillmentHomeDelivery), DateCreated: ptr(int64(2000))},
+		{Id: "r-old", ReferenceNumber: ptr("ORD-0000-0001"), Status: "paid", LocationId: "loc-001",
+			FulfillmentType: ptr(shared.FulfillmentStorePickup), FulfillmentStatus: ptr(shared.FulfillmentPacked), DateCreated: ptr(int64(1000))},
+		{Id: "r-done", ReferenceNumber: ptr("ORD-0000-0003"), Status: "paid", LocationId: "loc-001",
+			FulfillmentType: ptr(shared.FulfillmentStorePickup), FulfillmentStatus: ptr(shared.FulfillmentDelivered)},
+		{Id: "r-pending", ReferenceNumber: ptr("ORD-0000-0004"), Status: "pending", LocationId: "loc-001",
+			FulfillmentType: ptr(shared.FulfillmentStorePickup)},
+		{Id: "r-other", ReferenceNumber: ptr("ORD-0000-0005"), Status: "paid", LocationId: "loc-002",
+			FulfillmentType: ptr(shared.FulfillmentStorePickup)},
+		{Id: "r-manual", ReferenceNumber: ptr("INV-0001"), Status: "paid", LocationId: "loc-001"},
+	}
+	svc := NewService(CheckoutDeps{
+		ListRevenues: func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			return &revenuepb.ListRevenuesResponse{Success: true, Data: revs}, nil
+		},
+	})
+
+	queue, err := svc.ListFulfillmentQueue(context.Background(), "loc-001")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(queue) != 2 || queue[0].RevenueID != "r-old" || queue[1].RevenueID != "r-new" {
+		t.Fatalf("queue = %+v, want r-old then r-new", queue)
+	}
+	if queue[1].State != shared.FulfillmentQueued || queue[0].NextStates[0] != shared.FulfillmentReadyForPickup {
+		t.Errorf("states: %+v / %+v", queue[1], queue[0])
+	}
+
+	all, err := svc.ListFulfillmentQueue(context.Background(), "")
+	if err != nil || len(all) != 3 {
+		t.Errorf("all locations: %d entries, err %v; want 3", len(all), err)
+	}
+}
@@ -0,0 +1,120 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrOrderInProgress is returned by PlaceOrder when another request holding
+// the same idempotency key has not finished yet. Callers should retry later;
+// the replay then returns the original CheckoutResult.
+var ErrOrderInProgress = errors.New("checkout: order with this idempotency key is still being placed")
+
+// ErrWebhookInProgress is returned by HandlePaymentWebhook when another
+// delivery of the same event is being applied. Providers retry on an error
+// response, and the retry is then ignored or applied as the first one ends.
+var ErrWebhookInProgress = errors.New("checkout: webhook event is already being applied")
+
+// IdempotencyStore deduplicates PlaceOrder retries and payment webhook
+// redeliveries. Consumer apps back it with a shared store (database, Redis)
+// so every instance sees the same keys; MemoryIdempotencyStore covers tests
+// and single-process setups.
+type IdempotencyStore interface {
+	// ClaimOrder claims key for a new order. It returns the stored result when
+	// the key already completed, ErrOrderInProgress when another request holds
+	// the claim, and (nil, nil) when the caller now owns the key.
+	ClaimOrder(ctx context.Context, key string) (*CheckoutResult, error)
+	// CompleteOrder stores the result for a claimed key.
+	CompleteOrder(ctx context.Context, key string, result *CheckoutResult) error
+	// ReleaseOrder drops a claim whose order failed so the key can be retried.
+	ReleaseOrder(ctx context.Context, key string) error
+	// ClaimWebhookEvent atomically claims eventKey for one delivery. It
+	// returns (true, nil) when the caller now owns the event, (false, nil)
+	// when the event was already applied, and ErrWebhookInProgress when
+	// another delivery holds the claim.
+	ClaimWebhookEvent(ctx context.Context, eventKey string) (bool, error)
+	// CompleteWebhookEvent records a claimed eventKey as applied.
+	CompleteWebhookEvent(ctx context.Context, eventKey string) error
+	// ReleaseWebhookEvent drops a claim whose event was not applied, so a
+	// redelivery is processed again rather than dropped.
+	ReleaseWebhookEvent(ctx context.Context, eventKey string) error
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore. State is lost on
+// restart and is not shared between instances.
+type MemoryIdempotencyStore struct {
+	mu     sync.Mutex
+	orders map[string]*CheckoutResult // nil value = claimed, not yet complete
+	events map[string]bool            // false = claimed, true = applied
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		orders: map[string]*CheckoutResult{},
+		events: map[string]bool{},
+	}
+}
+
+func (m *MemoryIdempotencyStore) ClaimOrder(_ context.Context, key string) (*CheckoutResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result, ok := m.orders[key]
+	if !ok {
+		m.orders[key] = nil
+		return nil, nil
+	}
+	if result == nil {
+		return nil, ErrOrderInProgress
+	}
+	replay := *result
+	return &replay, nil
+}
+
+func (m *MemoryIdempotencyStore) CompleteOrder(_ context.Context, key string, result *CheckoutResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *result
+	m.orders[key] = &stored
+	return nil
+}
+
+func (m *MemoryIdempotencyStore) ReleaseOrder(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.orders[key] == nil {
+		delete(m.orders, key)
+	}
+	return nil
+}
+
+func (m *MemoryIdempotencyStore) ClaimWebhookEvent(_ context.Context, eventKey string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	applied, ok := m.events[eventKey]
+	if !ok {
+		m.events[eventKey] = false
+		return true, nil
+	}
+	if !applied {
+		return false, ErrWebhookInProgress
+	}
+	return false, nil
+}
+
+func (m *MemoryIdempotencyStore) CompleteWebhookEvent(_ context.Context, eventKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[eventKey] = true
+	return nil
+}
+
+func (m *MemoryIdempotencyStore) ReleaseWebhookEvent(_ context.Context, eventKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.events[eventKey] {
+		delete(m.events, eventKey)
+	}
+	return nil
+}
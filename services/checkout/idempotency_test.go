@@ -0,0 +1,359 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — idempotency keys
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	t.Run("replay returns original result without a second revenue", func(t *testing.T) {
+		t.Parallel()
+
+		var creates int32
+		deps := mockDeps()
+		deps.Idempotency = NewMemoryIdempotencyStore()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			n := atomic.AddInt32(&creates, 1)
+			return &revenuepb.CreateRevenueResponse{
+				Success: true,
+				Data:    []*revenuepb.Revenue{{Id: fmt.Sprintf("rev-%03d", n)}},
+			}, nil
+		}
+		svc := NewService(deps)
+		req := sampleRequest()
+		req.IdempotencyKey = "cart-42"
+
+		first, err := svc.PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := svc.PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error on replay: %v", err)
+		}
+
+		if creates != 1 {
+			t.Errorf("CreateRevenue called %d times, want 1", creates)
+		}
+		if !reflect.DeepEqual(second, first) {
+			t.Errorf("replay = %+v, want %+v", second, first)
+		}
+	})
+
+	t.Run("different keys place different orders", func(t *testing.T) {
+		t.Parallel()
+
+		var creates int32
+		deps := mockDeps()
+		deps.Idempotency = NewMemoryIdempotencyStore()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			atomic.AddInt32(&creates, 1)
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		svc := NewService(deps)
+
+		for _, key := range []string{"cart-1", "cart-2"} {
+			req := sampleRequest()
+			req.IdempotencyKey = key
+			if _, err := svc.PlaceOrder(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if creates != 2 {
+			t.Errorf("CreateRevenue called %d times, want 2", creates)
+		}
+	})
+
+	t.Run("failed order releases the key for retry", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts int32
+		deps := mockDeps()
+		deps.Idempotency = NewMemoryIdempotencyStore()
+		deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return nil, fmt.Errorf("line item write failed")
+			}
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+		}
+		svc := NewService(deps)
+		req := sampleRequest()
+		req.IdempotencyKey = "cart-42"
+
+		if _, err := svc.PlaceOrder(context.Background(), req); err == nil {
+			t.Fatal("expected first attempt to fail")
+		}
+		if _, err := svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("retry after failure should succeed: %v", err)
+		}
+	})
+
+	t.Run("concurrent replay gets ErrOrderInProgress", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryIdempotencyStore()
+		if _, err := store.ClaimOrder(context.Background(), "cart-42"); err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		deps := mockDeps()
+		deps.Idempotency = store
+		req := sampleRequest()
+		req.IdempotencyKey = "cart-42"
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if !errors.Is(err, ErrOrderInProgress) {
+			t.Fatalf("expected ErrOrderInProgress, got %v", err)
+		}
+	})
+
+	t.Run("no key bypasses the store", func(t *testing.T) {
+		t.Parallel()
+
+		var creates int32
+		deps := mockDeps()
+		deps.Idempotency = NewMemoryIdempotencyStore()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			atomic.AddInt32(&creates, 1)
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		svc := NewService(deps)
+
+		for i := 0; i < 2; i++ {
+			if _, err := svc.PlaceOrder(context.Background(), sampleRequest()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if creates != 2 {
+			t.Errorf("CreateRevenue called %d times, want 2", creates)
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// HandlePaymentWebhook — duplicates and ordering
+// ---------------------------------------------------------------------------
+
+// webhookDeps wires a revenue whose status is tracked in *status, and a
+// ProcessWebhook stub that returns the next event from events.
+func webhookDeps(status *string, events ...paymentpb.PaymentStatus) (CheckoutDeps, *int32) {
+	var updates int32
+	var next int32
+	deps := mockDeps()
+	deps.Idempotency = NewMemoryIdempotencyStore()
+	deps.ReadRevenue = func(_ context.Context, _ *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+		return &revenuepb.ReadRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001", Status: *status}}}, nil
+	}
+	deps.UpdateRevenue = func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+		atomic.AddInt32(&updates, 1)
+		*status = req.GetData().GetStatus()
+		return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+	}
+	deps.ProcessWebhook = func(_ context.Context, _ *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+		ev := events[atomic.AddInt32(&next, 1)-1]
+		return &paymentpb.ProcessWebhookResponse{
+			Success: true,
+			Data: []*paymentpb.WebhookResult{{
+				PaymentId:   "rev-001",
+				Status:      ev,
+				Transaction: &paymentpb.PaymentTransaction{Id: "tx-001"},
+			}},
+		}, nil
+	}
+	return deps, &updates
+}
+
+func TestHandlePaymentWebhook_Idempotency(t *testing.T) {
+	t.Parallel()
+
+	req := &paymentpb.ProcessWebhookRequest{Data: &paymentpb.WebhookData{ProviderId: "maya"}}
+
+	t.Run("redelivered event is ignored", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, updates := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		deps.ReadRevenue = nil // exercise the store alone
+		svc := NewService(deps)
+
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result, err := svc.HandlePaymentWebhook(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Ignored {
+			t.Error("expected redelivered event to be ignored")
+		}
+		if *updates != 1 {
+			t.Errorf("UpdateRevenue called %d times, want 1", *updates)
+		}
+	})
+
+	t.Run("late expiry does not cancel a paid revenue", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, _ := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED)
+		svc := NewService(deps)
+
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result, err := svc.HandlePaymentWebhook(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Ignored {
+			t.Error("expected late expiry to be ignored")
+		}
+		if status != "paid" {
+			t.Errorf("revenue status = %q, want paid", status)
+		}
+	})
+
+	t.Run("success after cancellation still records payment", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, _ := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		svc := NewService(deps)
+
+		for i := 0; i < 2; i++ {
+			if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if status != "paid" {
+			t.Errorf("revenue status = %q, want paid", status)
+		}
+	})
+
+	t.Run("webhook never overrides a staff-managed status", func(t *testing.T) {
+		t.Parallel()
+
+		status := "complete"
+		deps, updates := webhookDeps(&status, paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		result, err := NewService(deps).HandlePaymentWebhook(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Ignored || *updates != 0 {
+			t.Errorf("Ignored = %v, updates = %d; want ignored with no update", result.Ignored, *updates)
+		}
+	})
+
+	t.Run("failed update is retried on redelivery", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, _ := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		inner := deps.UpdateRevenue
+		var calls int32
+		deps.UpdateRevenue = func(ctx context.Context, r *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return nil, fmt.Errorf("revenue store down")
+			}
+			return inner(ctx, r)
+		}
+		svc := NewService(deps)
+
+		// The failure is returned so the provider redelivers.
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err == nil {
+			t.Fatal("expected the failed update to be returned")
+		}
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status != "paid" {
+			t.Errorf("revenue status = %q, want paid after redelivery", status)
+		}
+	})
+
+	t.Run("concurrent delivery waits for the claim", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, updates := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		svc := NewService(deps)
+
+		// The first delivery is still applying when the second arrives.
+		inner := deps.UpdateRevenue
+		var second error
+		svc.deps.UpdateRevenue = func(ctx context.Context, r *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			_, second = svc.HandlePaymentWebhook(context.Background(), req)
+			return inner(ctx, r)
+		}
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !errors.Is(second, ErrWebhookInProgress) {
+			t.Errorf("concurrent delivery: err = %v, want ErrWebhookInProgress", second)
+		}
+		result, err := svc.HandlePaymentWebhook(context.Background(), req)
+		if err != nil || !result.Ignored || *updates != 1 {
+			t.Errorf("redelivery after apply = %+v, %v, updates %d; want ignored with one update", result, err, *updates)
+		}
+	})
+
+	t.Run("status machine guards and hooks apply", func(t *testing.T) {
+		t.Parallel()
+
+		status := "pending"
+		deps, updates := webhookDeps(&status,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS)
+		m := shared.NewRevenueStatusMachine()
+		m.AddGuard("keep open", shared.AnyRevenueStatus, shared.RevenueStatusCancelled, func(context.Context, shared.RevenueTransition) error {
+			return errors.New("workspace keeps expired orders open")
+		})
+		var exported []string
+		m.AddHook("accounting export", shared.AnyRevenueStatus, shared.RevenueStatusPaid, func(_ context.Context, tr shared.RevenueTransition) error {
+			exported = append(exported, tr.From+"->"+tr.To)
+			return nil
+		})
+		deps.StatusMachine = m
+		svc := NewService(deps)
+
+		result, err := svc.HandlePaymentWebhook(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Ignored || *updates != 0 {
+			t.Errorf("guarded expiry: Ignored = %v, updates = %d; want ignored with no update", result.Ignored, *updates)
+		}
+		if _, err := svc.HandlePaymentWebhook(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status != "paid" || !reflect.DeepEqual(exported, []string{"pending->paid"}) {
+			t.Errorf("status = %q, exported = %v; want paid with one export", status, exported)
+		}
+	})
+}
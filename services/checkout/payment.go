@@ -0,0 +1,179 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ErrUnknownPaymentProvider is returned when CheckoutRequest.PaymentProvider
+// or a webhook names a provider that is not registered.
+var ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+
+// ErrPaymentNotSupported is returned by providers for operations they cannot
+// perform (e.g. refunding cash on delivery through the provider).
+var ErrPaymentNotSupported = errors.New("operation not supported by payment provider")
+
+// PaymentProvider is one way a customer can pay. Requests and responses are
+// the esqyma payment integration messages, so an espyna payment adapter can be
+// registered as-is.
+type PaymentProvider interface {
+	// CreateSession opens a checkout session for an order. Providers that
+	// settle outside the storefront (cash on delivery, bank transfer) return a
+	// session without a checkout URL.
+	CreateSession(ctx context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error)
+	// VerifyWebhook authenticates a provider callback and decodes it.
+	VerifyWebhook(ctx context.Context, req *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error)
+	// Refund returns all or part of a captured payment.
+	Refund(ctx context.Context, req *paymentpb.RefundPaymentRequest) (*paymentpb.RefundPaymentResponse, error)
+	// Status asks the provider for the current state of a payment.
+	Status(ctx context.Context, req *paymentpb.GetPaymentStatusRequest) (*paymentpb.GetPaymentStatusResponse, error)
+}
+
+// PaymentVoider is implemented by providers that can cancel an open checkout
+// session. PlaceOrder uses it to roll back a session when a later step fails.
+type PaymentVoider interface {
+	Void(ctx context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.VoidPaymentResponse, error)
+}
+
+// PaymentRegistry holds the payment providers a workspace offers, keyed by
+// name (the value of CheckoutRequest.PaymentProvider and
+// WebhookData.ProviderId). Names are case-insensitive. It is safe for
+// concurrent use.
+type PaymentRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]PaymentProvider
+}
+
+// NewPaymentRegistry creates an empty registry.
+func NewPaymentRegistry() *PaymentRegistry {
+	return &PaymentRegistry{providers: map[string]PaymentProvider{}}
+}
+
+// Register adds or replaces the provider for name.
+func (r *PaymentRegistry) Register(name string, p PaymentProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[strings.ToLower(name)] = p
+}
+
+// Provider returns the provider registered for name.
+func (r *PaymentRegistry) Provider(name string) (PaymentProvider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.providers[strings.ToLower(name)]
+	return p, ok
+}
+
+// Names lists the registered provider names in sorted order, for rendering
+// the storefront's payment method picker.
+func (r *PaymentRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// paymentProvider resolves the provider for name. With a registry wired the
+// name must be registered. Without one, the legacy CreateCheckoutSession /
+// ProcessWebhook / VoidPayment closures act as the provider for every name.
+// Returns nil when neither is configured.
+func (s *Service) paymentProvider(name string) (PaymentProvider, error) {
+	if s.deps.Payments != nil {
+		p, ok := s.deps.Payments.Provider(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownPaymentProvider, name)
+		}
+		return p, nil
+	}
+	if s.deps.CreateCheckoutSession == nil && s.deps.ProcessWebhook == nil {
+		return nil, nil
+	}
+	return depsProvider{deps: &s.deps}, nil
+}
+
+// depsProvider adapts the single-provider closures on CheckoutDeps to
+// PaymentProvider, so deployments that predate the registry keep working.
+type depsProvider struct {
+	deps *CheckoutDeps
+}
+
+// CreateSession returns an empty response (no session, order stays pending)
+// when CreateCheckoutSession is not wired, as PlaceOrder always has.
+func (p depsProvider) CreateSession(ctx context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+	if p.deps.CreateCheckoutSession == nil {
+		return &paymentpb.CreateCheckoutSessionResponse{}, nil
+	}
+	return p.deps.CreateCheckoutSession(ctx, req)
+}
+
+func (p depsProvider) VerifyWebhook(ctx context.Context, req *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+	if p.deps.ProcessWebhook == nil {
+		return nil, ErrPaymentNotSupported
+	}
+	return p.deps.ProcessWebhook(ctx, req)
+}
+
+func (p depsProvider) Refund(context.Context, *paymentpb.RefundPaymentRequest) (*paymentpb.RefundPaymentResponse, error) {
+	return nil, ErrPaymentNotSupported
+}
+
+func (p depsProvider) Status(context.Context, *paymentpb.GetPaymentStatusRequest) (*paymentpb.GetPaymentStatusResponse, error) {
+	return nil, ErrPaymentNotSupported
+}
+
+func (p depsProvider) Void(ctx context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.VoidPaymentResponse, error) {
+	if p.deps.VoidPayment == nil {
+		return &paymentpb.VoidPaymentResponse{Success: true}, nil
+	}
+	return p.deps.VoidPayment(ctx, req)
+}
+
+// OfflinePaymentProvider is a provider settled outside the storefront —
+// cash on delivery, bank transfer, over-the-counter. Sessions carry no
+// checkout URL and no webhooks arrive; staff record the payment on the
+// revenue once the money is in. The reservation sweeper still applies, so
+// ReservationTTL must cover the settlement window.
+type OfflinePaymentProvider struct {
+	// Type is reported on created sessions (e.g. BANK_TRANSFER).
+	Type paymentpb.PaymentProviderType
+}
+
+func (p OfflinePaymentProvider) CreateSession(_ context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+	data := req.GetData()
+	return &paymentpb.CreateCheckoutSessionResponse{
+		Success: true,
+		Data: []*paymentpb.CheckoutSession{{
+			Id:           data.GetOrderRef(),
+			ProviderId:   data.GetProviderId(),
+			ProviderType: p.Type,
+			Amount:       data.GetAmount(),
+			Currency:     data.GetCurrency(),
+			Status:       paymentpb.PaymentStatus_PAYMENT_STATUS_PENDING,
+		}},
+	}, nil
+}
+
+func (p OfflinePaymentProvider) VerifyWebhook(context.Context, *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+	return nil, ErrPaymentNotSupported
+}
+
+func (p OfflinePaymentProvider) Refund(context.Context, *paymentpb.RefundPaymentRequest) (*paymentpb.RefundPaymentResponse, error) {
+	return nil, ErrPaymentNotSupported
+}
+
+func (p OfflinePaymentProvider) Status(context.Context, *paymentpb.GetPaymentStatusRequest) (*paymentpb.GetPaymentStatusResponse, error) {
+	return &paymentpb.GetPaymentStatusResponse{
+		Success: true,
+		Data:    []*paymentpb.PaymentStatusData{{Status: paymentpb.PaymentStatus_PAYMENT_STATUS_PENDING}},
+	}, nil
+}
@@ -0,0 +1,343 @@
+package checkout
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// fakeSignatureHeader carries the HMAC of a fake webhook payload.
+const fakeSignatureHeader = "X-Fake-Signature"
+
+// FakePaymentProvider is an in-process PaymentProvider for tests and local
+// development. Sessions live in memory; Pay, Fail and Expire settle one and
+// send the resulting signed webhook to Deliver, so the whole checkout →
+// webhook → revenue status flow runs offline.
+//
+//	fake := checkout.NewFakePaymentProvider("fake")
+//	registry.Register("fake", fake)
+//	svc := checkout.NewService(deps) // deps.Payments = registry
+//	fake.Deliver = svc.HandlePaymentWebhook
+type FakePaymentProvider struct {
+	// Name is stamped on sessions and webhooks as the provider ID. It must
+	// match the name the provider is registered under.
+	Name string
+	// Outcome, when set, settles every new session automatically with that
+	// status (asynchronously, after Delay).
+	Outcome paymentpb.PaymentStatus
+	// Delay holds webhooks back. With a non-zero Delay, delivery happens on a
+	// goroutine; call Wait to block until it has run.
+	Delay time.Duration
+	// Deliver receives every webhook the provider sends — normally the
+	// checkout service's HandlePaymentWebhook. Nil drops webhooks.
+	Deliver func(ctx context.Context, req *paymentpb.ProcessWebhookRequest) (*WebhookResult, error)
+
+	secret []byte
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	seq      int
+	sessions map[string]*fakeSession
+	errs     []error
+}
+
+type fakeSession struct {
+	id        string
+	paymentID string
+	orderRef  string
+	amount    int64
+	refunded  int64
+	currency  string
+	status    paymentpb.PaymentStatus
+}
+
+// fakeWebhookPayload is the JSON body of a fake webhook.
+type fakeWebhookPayload struct {
+	SessionID string `json:"session_id"`
+	PaymentID string `json:"payment_id"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+}
+
+// NewFakePaymentProvider creates a fake provider registered as name.
+func NewFakePaymentProvider(name string) *FakePaymentProvider {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("fake payment provider: %v", err))
+	}
+	return &FakePaymentProvider{Name: name, secret: secret, sessions: map[string]*fakeSession{}}
+}
+
+func (f *FakePaymentProvider) CreateSession(_ context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+	data := req.GetData()
+	f.mu.Lock()
+	f.seq++
+	sess := &fakeSession{
+		id:        fmt.Sprintf("fake_sess_%04d", f.seq),
+		paymentID: data.GetPaymentId(),
+		orderRef:  data.GetOrderRef(),
+		amount:    data.GetAmount(),
+		currency:  data.GetCurrency(),
+		status:    paymentpb.PaymentStatus_PAYMENT_STATUS_PENDING,
+	}
+	f.sessions[sess.id] = sess
+	resp := &paymentpb.CreateCheckoutSessionResponse{
+		Success: true,
+		Data: []*paymentpb.CheckoutSession{{
+			Id:                sess.id,
+			ProviderSessionId: sess.id,
+			ProviderId:        f.Name,
+			ProviderType:      paymentpb.PaymentProviderType_PAYMENT_PROVIDER_TYPE_MOCK,
+			Amount:            sess.amount,
+			Currency:          sess.currency,
+			Status:            sess.status,
+			CheckoutUrl:       "https://fake-pay.local/checkout/" + sess.id,
+		}},
+	}
+	f.mu.Unlock()
+
+	if f.Outcome != paymentpb.PaymentStatus_PAYMENT_STATUS_UNSPECIFIED {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			time.Sleep(f.Delay)
+			if _, err := f.settle(context.Background(), sess.id, f.Outcome, false); err != nil {
+				f.recordErr(err)
+			}
+		}()
+	}
+	return resp, nil
+}
+
+// Pay settles sessionID as paid and sends the webhook.
+func (f *FakePaymentProvider) Pay(ctx context.Context, sessionID string) (*WebhookResult, error) {
+	return f.settle(ctx, sessionID, paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS, true)
+}
+
+// Fail settles sessionID as failed and sends the webhook.
+func (f *FakePaymentProvider) Fail(ctx context.Context, sessionID string) (*WebhookResult, error) {
+	return f.settle(ctx, sessionID, paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED, true)
+}
+
+// Expire settles sessionID as expired and sends the webhook.
+func (f *FakePaymentProvider) Expire(ctx context.Context, sessionID string) (*WebhookResult, error) {
+	return f.settle(ctx, sessionID, paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED, true)
+}
+
+// settle moves the session to status and delivers its webhook. When async
+// is set and Delay is non-zero, delivery happens later and the result is nil.
+func (f *FakePaymentProvider) settle(ctx context.Context, sessionID string, status paymentpb.PaymentStatus, async bool) (*WebhookResult, error) {
+	f.mu.Lock()
+	sess, ok := f.sessions[sessionID]
+	if ok {
+		sess.status = status
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake payment: session %s not found", sessionID)
+	}
+
+	webhook, err := f.Webhook(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if f.Deliver == nil {
+		return nil, nil
+	}
+	if async && f.Delay > 0 {
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			time.Sleep(f.Delay)
+			if _, err := f.Deliver(context.WithoutCancel(ctx), webhook); err != nil {
+				f.recordErr(err)
+			}
+		}()
+		return nil, nil
+	}
+	return f.Deliver(ctx, webhook)
+}
+
+// Webhook builds the signed webhook for the session's current status, as the
+// provider would send it. Tests can redeliver or tamper with it.
+func (f *FakePaymentProvider) Webhook(sessionID string) (*paymentpb.ProcessWebhookRequest, error) {
+	f.mu.Lock()
+	sess, ok := f.sessions[sessionID]
+	var payload fakeWebhookPayload
+	if ok {
+		payload = fakeWebhookPayload{SessionID: sess.id, PaymentID: sess.paymentID, Status: sess.status.String(), Amount: sess.amount}
+	}
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fake payment: session %s not found", sessionID)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("fake payment: encode webhook: %w", err)
+	}
+	return &paymentpb.ProcessWebhookRequest{
+		Data: &paymentpb.WebhookData{
+			ProviderId:  f.Name,
+			Payload:     body,
+			ContentType: "application/json",
+			Method:      "POST",
+			Headers:     map[string]string{fakeSignatureHeader: f.sign(body)},
+		},
+	}, nil
+}
+
+func (f *FakePaymentProvider) VerifyWebhook(_ context.Context, req *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+	data := req.GetData()
+	if !hmac.Equal([]byte(data.GetHeaders()[fakeSignatureHeader]), []byte(f.sign(data.GetPayload()))) {
+		return nil, fmt.Errorf("fake payment: invalid webhook signature")
+	}
+	var payload fakeWebhookPayload
+	if err := json.Unmarshal(data.GetPayload(), &payload); err != nil {
+		return nil, fmt.Errorf("fake payment: decode webhook: %w", err)
+	}
+	status := paymentpb.PaymentStatus(paymentpb.PaymentStatus_value[payload.Status])
+
+	return &paymentpb.ProcessWebhookResponse{
+		Success: true,
+		Data: []*paymentpb.WebhookResult{{
+			Transaction: &paymentpb.PaymentTransaction{
+				Id:         payload.SessionID,
+				SessionId:  payload.SessionID,
+				ProviderId: f.Name,
+				Status:     status,
+				Amount:     payload.Amount,
+			},
+			Status:    status,
+			Action:    "payment." + strings.ToLower(strings.TrimPrefix(payload.Status, "PAYMENT_STATUS_")),
+			PaymentId: payload.PaymentID,
+		}},
+	}, nil
+}
+
+func (f *FakePaymentProvider) Refund(_ context.Context, req *paymentpb.RefundPaymentRequest) (*paymentpb.RefundPaymentResponse, error) {
+	data := req.GetData()
+	id := data.GetTransactionId()
+	if id == "" {
+		id = data.GetProviderRef()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sess, ok := f.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("fake payment: session %s not found", id)
+	}
+	if sess.status != paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS && sess.status != paymentpb.PaymentStatus_PAYMENT_STATUS_PARTIAL_REFUND {
+		return nil, fmt.Errorf("fake payment: session %s is %s, not refundable", sess.id, sess.status)
+	}
+	amount := data.GetAmount()
+	if amount <= 0 {
+		amount = sess.amount - sess.refunded
+	}
+	if sess.refunded+amount > sess.amount {
+		return nil, fmt.Errorf("fake payment: refund %d exceeds remaining %d", amount, sess.amount-sess.refunded)
+	}
+	sess.refunded += amount
+	sess.status = paymentpb.PaymentStatus_PAYMENT_STATUS_PARTIAL_REFUND
+	if sess.refunded == sess.amount {
+		sess.status = paymentpb.PaymentStatus_PAYMENT_STATUS_REFUNDED
+	}
+
+	return &paymentpb.RefundPaymentResponse{
+		Success: true,
+		Data: []*paymentpb.RefundResponse{{
+			Success:     true,
+			RefundId:    fmt.Sprintf("%s_refund_%d", sess.id, sess.refunded),
+			Status:      sess.status,
+			Amount:      amount,
+			ProviderRef: sess.id,
+		}},
+	}, nil
+}
+
+// Status looks the session up by PaymentId (the revenue ID) or ProviderRef
+// (the session ID).
+func (f *FakePaymentProvider) Status(_ context.Context, req *paymentpb.GetPaymentStatusRequest) (*paymentpb.GetPaymentStatusResponse, error) {
+	lookup := req.GetData()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, sess := range f.sessions {
+		if sess.id == lookup.GetProviderRef() || (lookup.GetPaymentId() != "" && sess.paymentID == lookup.GetPaymentId()) {
+			return &paymentpb.GetPaymentStatusResponse{
+				Success: true,
+				Data: []*paymentpb.PaymentStatusData{{
+					Status: sess.status,
+					Transaction: &paymentpb.PaymentTransaction{
+						Id:         sess.id,
+						SessionId:  sess.id,
+						ProviderId: f.Name,
+						Status:     sess.status,
+						Amount:     sess.amount,
+						Currency:   sess.currency,
+					},
+				}},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("fake payment: no session for payment %q", lookup.GetPaymentId())
+}
+
+func (f *FakePaymentProvider) Void(_ context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.VoidPaymentResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sess, ok := f.sessions[req.GetData().GetTransactionId()]
+	if !ok {
+		return nil, fmt.Errorf("fake payment: session %s not found", req.GetData().GetTransactionId())
+	}
+	if sess.status == paymentpb.PaymentStatus_PAYMENT_STATUS_PENDING {
+		sess.status = paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED
+	}
+	return &paymentpb.VoidPaymentResponse{Success: true, Data: []paymentpb.PaymentStatus{sess.status}}, nil
+}
+
+// SessionFor returns the session ID opened for paymentID (the revenue ID).
+func (f *FakePaymentProvider) SessionFor(paymentID string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, sess := range f.sessions {
+		if sess.paymentID == paymentID {
+			return sess.id, true
+		}
+	}
+	return "", false
+}
+
+// Wait blocks until every delayed or automatic webhook has been delivered.
+func (f *FakePaymentProvider) Wait() {
+	f.wg.Wait()
+}
+
+// Errors returns the errors from webhooks delivered in the background.
+func (f *FakePaymentProvider) Errors() []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]error(nil), f.errs...)
+}
+
+func (f *FakePaymentProvider) recordErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs = append(f.errs, err)
+}
+
+func (f *FakePaymentProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
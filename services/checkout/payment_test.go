@@ -0,0 +1,324 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// revenueStatuses stands in for the revenue table's status column so the
+// webhook ordering checks see what earlier webhooks wrote.
+type revenueStatuses struct {
+	mu     sync.Mutex
+	status map[string]string
+}
+
+func (r *revenueStatuses) get(id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status[id]
+}
+
+// fakePaymentDeps wires a registry holding a fake provider under "fake" and
+// returns the service with the fake delivering to it.
+func fakePaymentDeps(t *testing.T) (*Service, *FakePaymentProvider, *revenueStatuses) {
+	t.Helper()
+
+	statuses := &revenueStatuses{status: map[string]string{}}
+	fake := NewFakePaymentProvider("fake")
+	registry := NewPaymentRegistry()
+	registry.Register("fake", fake)
+	registry.Register("cod", OfflinePaymentProvider{})
+
+	deps := mockDeps()
+	deps.Payments = registry
+	deps.UpdateRevenue = func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+		if st := req.GetData().GetStatus(); st != "" {
+			statuses.mu.Lock()
+			statuses.status[req.GetData().GetId()] = st
+			statuses.mu.Unlock()
+		}
+		return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+	}
+	deps.ReadRevenue = func(_ context.Context, req *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+		st := statuses.get(req.GetData().GetId())
+		if st == "" {
+			st = "pending"
+		}
+		return &revenuepb.ReadRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: req.GetData().GetId(), Status: st}}}, nil
+	}
+
+	svc := NewService(deps)
+	fake.Deliver = svc.HandlePaymentWebhook
+	return svc, fake, statuses
+}
+
+func fakeOrder(t *testing.T, svc *Service, provider string) *CheckoutResult {
+	t.Helper()
+	req := sampleRequest()
+	req.PaymentProvider = provider
+	result, err := svc.PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	return result
+}
+
+// ---------------------------------------------------------------------------
+// PaymentRegistry
+// ---------------------------------------------------------------------------
+
+func TestPaymentRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := NewPaymentRegistry()
+	registry.Register("Maya", NewFakePaymentProvider("maya"))
+	registry.Register("cod", OfflinePaymentProvider{})
+	registry.Register("bank_transfer", OfflinePaymentProvider{})
+
+	if got := fmt.Sprint(registry.Names()); got != "[bank_transfer cod maya]" {
+		t.Errorf("Names() = %s", got)
+	}
+	if _, ok := registry.Provider("MAYA"); !ok {
+		t.Error("lookup should be case-insensitive")
+	}
+	if _, ok := registry.Provider("stripe"); ok {
+		t.Error("unregistered provider should not be found")
+	}
+}
+
+func TestPlaceOrder_PaymentProviders(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown provider fails before any write", func(t *testing.T) {
+		t.Parallel()
+
+		revenueCreated := false
+		deps := mockDeps()
+		deps.Payments = NewPaymentRegistry()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenueCreated = true
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "stripe"
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if !errors.Is(err, ErrUnknownPaymentProvider) {
+			t.Fatalf("expected ErrUnknownPaymentProvider, got %v", err)
+		}
+		if revenueCreated {
+			t.Error("CreateRevenue should not be called for an unknown provider")
+		}
+	})
+
+	t.Run("offline provider opens a session without a checkout URL", func(t *testing.T) {
+		t.Parallel()
+
+		svc, _, _ := fakePaymentDeps(t)
+		result := fakeOrder(t, svc, "cod")
+		if result.CheckoutURL != "" || result.CheckoutID != result.ReferenceNumber {
+			t.Errorf("CheckoutURL = %q, CheckoutID = %q; want no URL and the order reference", result.CheckoutURL, result.CheckoutID)
+		}
+	})
+
+	t.Run("legacy closures still work without a registry", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.CreateCheckoutSession = func(_ context.Context, _ *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+			return &paymentpb.CreateCheckoutSessionResponse{Success: true, Data: []*paymentpb.CheckoutSession{{Id: "sess-1", CheckoutUrl: "https://pay.example/sess-1"}}}, nil
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "maya"
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.CheckoutID != "sess-1" {
+			t.Errorf("CheckoutID = %q, want sess-1", result.CheckoutID)
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// FakePaymentProvider — checkout → webhook → revenue status
+// ---------------------------------------------------------------------------
+
+func TestFakePaymentProvider_Flow(t *testing.T) {
+	t.Parallel()
+
+	t.Run("paid", func(t *testing.T) {
+		t.Parallel()
+
+		svc, fake, statuses := fakePaymentDeps(t)
+		result := fakeOrder(t, svc, "fake")
+		if result.CheckoutURL == "" || result.CheckoutID == "" {
+			t.Fatalf("expected a fake checkout session, got %+v", result)
+		}
+
+		wh, err := fake.Pay(context.Background(), result.CheckoutID)
+		if err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+		if wh.Status != "paid" || statuses.get(result.RevenueID) != "paid" {
+			t.Errorf("webhook status %q, revenue status %q; want paid", wh.Status, statuses.get(result.RevenueID))
+		}
+
+		status, err := fake.Status(context.Background(), &paymentpb.GetPaymentStatusRequest{Data: &paymentpb.PaymentStatusLookup{PaymentId: result.RevenueID}})
+		if err != nil || status.GetData()[0].GetStatus() != paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS {
+			t.Errorf("Status = %v, %v; want SUCCESS", status, err)
+		}
+	})
+
+	for name, settle := range map[string]func(*FakePaymentProvider, context.Context, string) (*WebhookResult, error){
+		"failed":  (*FakePaymentProvider).Fail,
+		"expired": (*FakePaymentProvider).Expire,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			svc, fake, statuses := fakePaymentDeps(t)
+			result := fakeOrder(t, svc, "fake")
+			if _, err := settle(fake, context.Background(), result.CheckoutID); err != nil {
+				t.Fatalf("settle: %v", err)
+			}
+			if got := statuses.get(result.RevenueID); got != "cancelled" {
+				t.Errorf("revenue status = %q, want cancelled", got)
+			}
+		})
+	}
+
+	t.Run("delayed webhook arrives after the call returns", func(t *testing.T) {
+		t.Parallel()
+
+		svc, fake, statuses := fakePaymentDeps(t)
+		fake.Delay = 20 * time.Millisecond
+		result := fakeOrder(t, svc, "fake")
+
+		wh, err := fake.Pay(context.Background(), result.CheckoutID)
+		if err != nil || wh != nil {
+			t.Fatalf("Pay = %v, %v; want nil result for a delayed webhook", wh, err)
+		}
+		if got := statuses.get(result.RevenueID); got != "" {
+			t.Errorf("revenue status before delivery = %q, want unset", got)
+		}
+		fake.Wait()
+		if got := statuses.get(result.RevenueID); got != "paid" {
+			t.Errorf("revenue status after delivery = %q, want paid", got)
+		}
+	})
+
+	t.Run("automatic outcome settles every session", func(t *testing.T) {
+		t.Parallel()
+
+		svc, fake, statuses := fakePaymentDeps(t)
+		fake.Outcome = paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS
+		result := fakeOrder(t, svc, "fake")
+		fake.Wait()
+		if errs := fake.Errors(); len(errs) > 0 {
+			t.Fatalf("delivery errors: %v", errs)
+		}
+		if got := statuses.get(result.RevenueID); got != "paid" {
+			t.Errorf("revenue status = %q, want paid", got)
+		}
+	})
+
+	t.Run("late expiry does not cancel a paid order", func(t *testing.T) {
+		t.Parallel()
+
+		svc, fake, statuses := fakePaymentDeps(t)
+		result := fakeOrder(t, svc, "fake")
+		if _, err := fake.Pay(context.Background(), result.CheckoutID); err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+		wh, err := fake.Expire(context.Background(), result.CheckoutID)
+		if err != nil {
+			t.Fatalf("Expire: %v", err)
+		}
+		if !wh.Ignored || statuses.get(result.RevenueID) != "paid" {
+			t.Errorf("Ignored = %v, revenue status %q; want ignored and paid", wh.Ignored, statuses.get(result.RevenueID))
+		}
+	})
+
+	t.Run("tampered webhook is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		svc, fake, statuses := fakePaymentDeps(t)
+		result := fakeOrder(t, svc, "fake")
+		if _, err := fake.Fail(context.Background(), result.CheckoutID); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		statuses.mu.Lock()
+		delete(statuses.status, result.RevenueID)
+		statuses.mu.Unlock()
+
+		webhook, err := fake.Webhook(result.CheckoutID)
+		if err != nil {
+			t.Fatalf("Webhook: %v", err)
+		}
+		webhook.Data.Payload = []byte(`{"session_id":"` + result.CheckoutID + `","payment_id":"` + result.RevenueID + `","status":"PAYMENT_STATUS_SUCCESS"}`)
+		if _, err := svc.HandlePaymentWebhook(context.Background(), webhook); err == nil {
+			t.Fatal("expected a signature error")
+		}
+		if got := statuses.get(result.RevenueID); got != "" {
+			t.Errorf("revenue status = %q, want unchanged", got)
+		}
+	})
+
+	t.Run("webhook from an unregistered provider is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		svc, _, _ := fakePaymentDeps(t)
+		other := NewFakePaymentProvider("other")
+		other.Deliver = svc.HandlePaymentWebhook
+		if _, err := other.CreateSession(context.Background(), &paymentpb.CreateCheckoutSessionRequest{Data: &paymentpb.CheckoutSessionData{PaymentId: "rev-001"}}); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if _, err := other.Pay(context.Background(), "fake_sess_0001"); !errors.Is(err, ErrUnknownPaymentProvider) {
+			t.Errorf("expected ErrUnknownPaymentProvider, got %v", err)
+		}
+	})
+}
+
+func TestFakePaymentProvider_Refund(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	fake := NewFakePaymentProvider("fake")
+	sess, err := fake.CreateSession(ctx, &paymentpb.CreateCheckoutSessionRequest{Data: &paymentpb.CheckoutSessionData{PaymentId: "rev-001", Amount: 20000}})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	id := sess.GetData()[0].GetId()
+	refund := func(amount int64) (*paymentpb.RefundPaymentResponse, error) {
+		return fake.Refund(ctx, &paymentpb.RefundPaymentRequest{Data: &paymentpb.RefundData{TransactionId: id, Amount: amount}})
+	}
+
+	if _, err := refund(5000); err == nil {
+		t.Error("refunding an unpaid session should fail")
+	}
+	if _, err := fake.Pay(ctx, id); err != nil {
+		t.Fatalf("Pay: %v", err)
+	}
+	if resp, err := refund(5000); err != nil || resp.GetData()[0].GetStatus() != paymentpb.PaymentStatus_PAYMENT_STATUS_PARTIAL_REFUND {
+		t.Errorf("partial refund = %v, %v", resp, err)
+	}
+	if _, err := refund(20000); err == nil {
+		t.Error("refund beyond the remaining amount should fail")
+	}
+	if resp, err := refund(0); err != nil || resp.GetData()[0].GetAmount() != 15000 || resp.GetData()[0].GetStatus() != paymentpb.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+		t.Errorf("full refund of remainder = %v, %v", resp, err)
+	}
+}
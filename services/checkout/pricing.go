@@ -0,0 +1,193 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pricelistpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_list"
+	priceproductpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_product"
+)
+
+// ErrNoApplicablePrice is wrapped when an item has no price in the price list
+// that applies to its location today.
+var ErrNoApplicablePrice = errors.New("no applicable price")
+
+// ErrInvalidQuantity is wrapped when an item's quantity is not positive.
+var ErrInvalidQuantity = errors.New("item quantity must be positive")
+
+// ErrInvalidShippingFee is wrapped when the order's shipping fee, from the
+// client or the ShippingFeeResolver, is negative.
+var ErrInvalidShippingFee = errors.New("shipping fee cannot be negative")
+
+// ShippingFeeResolver returns the shipping fee, in centavos, the server
+// charges for req: its fulfillment type, location, delivery address and
+// items. The consumer app applies its delivery rates; 0 means no fee.
+type ShippingFeeResolver func(ctx context.Context, req CheckoutRequest) (int64, error)
+
+// PriceMismatch is one client figure that disagrees with the server price.
+// Index is the item position in CheckoutRequest.Items, or -1 for an order-level
+// field.
+type PriceMismatch struct {
+	Index     int
+	ProductID string
+	Field     string // "unit_price", "total_price", "shipping_fee" or "total_amount"
+	Client    int64  // centavos
+	Server    int64  // centavos
+}
+
+// PriceMismatchError is returned (wrapped in a StepError for StepVerifyPrices)
+// when the client's prices or totals differ from the server's, so the
+// storefront can show the corrected figures and ask the customer to confirm.
+type PriceMismatchError struct {
+	Mismatches []PriceMismatch
+}
+
+func (e *PriceMismatchError) Error() string {
+	parts := make([]string, 0, len(e.Mismatches))
+	for _, m := range e.Mismatches {
+		if m.Index < 0 {
+			parts = append(parts, fmt.Sprintf("%s: client %d, server %d", m.Field, m.Client, m.Server))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("item %d (%s) %s: client %d, server %d", m.Index, m.ProductID, m.Field, m.Client, m.Server))
+	}
+	return "price mismatch: " + strings.Join(parts, "; ")
+}
+
+// serverPrice is the price the server resolved for one product at one location.
+type serverPrice struct {
+	amount      int64 // centavos
+	currency    string
+	priceListID string
+}
+
+// verifyPrices re-prices every item through FindApplicablePriceList +
+// ListPriceProducts (the same path as the revenue price lookup endpoint) for
+// the item's location and today's date, recomputes line and order totals in
+// centavos (the order total includes ShippingFee, taken from
+// ResolveShippingFee when wired), and compares them with the client's
+// figures. On success it returns a copy of req carrying the server prices,
+// fee and totals. When TrustClientPrices is set the request is returned
+// unchanged. An item with a quantity below 1 or a negative shipping fee is
+// rejected either way, before anything is priced.
+func (s *Service) verifyPrices(ctx context.Context, req CheckoutRequest) (CheckoutRequest, error) {
+	for i, item := range req.Items {
+		if item.Quantity <= 0 {
+			return req, fmt.Errorf("%w: item %d (%s) has quantity %d", ErrInvalidQuantity, i, item.ProductID, item.Quantity)
+		}
+	}
+	if req.ShippingFee < 0 {
+		return req, fmt.Errorf("%w: %d", ErrInvalidShippingFee, req.ShippingFee)
+	}
+	if s.deps.TrustClientPrices {
+		return req, nil
+	}
+	if s.deps.FindApplicablePriceList == nil || s.deps.ListPriceProducts == nil {
+		return req, fmt.Errorf("price lookup not configured (set TrustClientPrices to skip verification)")
+	}
+
+	today := time.Now().Format("2006-01-02")
+	priceListByLocation := map[string]string{}
+	var priceProducts []*priceproductpb.PriceProduct
+	productsLoaded := false
+
+	lookup := func(productID, locationID string) (*serverPrice, error) {
+		priceListID, ok := priceListByLocation[locationID]
+		if !ok {
+			plResp, err := s.deps.FindApplicablePriceList(ctx, &pricelistpb.FindApplicablePriceListRequest{
+				LocationId: locationID,
+				Date:       today,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("find price list for location %s: %w", locationID, err)
+			}
+			if plResp.GetFound() && plResp.GetPriceList() != nil {
+				priceListID = plResp.GetPriceList().GetId()
+			}
+			priceListByLocation[locationID] = priceListID
+		}
+		if priceListID == "" {
+			return nil, fmt.Errorf("%w: no price list for location %s", ErrNoApplicablePrice, locationID)
+		}
+
+		if !productsLoaded {
+			ppResp, err := s.deps.ListPriceProducts(ctx, &priceproductpb.ListPriceProductsRequest{})
+			if err != nil {
+				return nil, fmt.Errorf("list price products: %w", err)
+			}
+			priceProducts = ppResp.GetData()
+			productsLoaded = true
+		}
+		for _, pp := range priceProducts {
+			if pp.GetPriceListId() == priceListID && pp.GetProductId() == productID {
+				return &serverPrice{amount: pp.GetAmount(), currency: pp.GetCurrency(), priceListID: priceListID}, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: product %s at location %s", ErrNoApplicablePrice, productID, locationID)
+	}
+
+	verified := req
+	verified.Items = make([]CheckoutItem, len(req.Items))
+	var mismatches []PriceMismatch
+	shippingFee := int64(req.ShippingFee)
+	if s.deps.ResolveShippingFee != nil {
+		fee, err := s.deps.ResolveShippingFee(ctx, req)
+		if err != nil {
+			return req, fmt.Errorf("resolve shipping fee: %w", err)
+		}
+		if fee < 0 {
+			return req, fmt.Errorf("%w: resolved %d", ErrInvalidShippingFee, fee)
+		}
+		if fee != shippingFee {
+			mismatches = append(mismatches, PriceMismatch{Index: -1, Field: "shipping_fee",
+				Client: shippingFee, Server: fee})
+		}
+		shippingFee = fee
+	}
+	verified.ShippingFee = int(shippingFee)
+	orderTotal := shippingFee
+
+	for i, item := range req.Items {
+		locationID := item.LocationID
+		if locationID == "" {
+			locationID = req.LocationID
+		}
+		price, err := lookup(item.ProductID, locationID)
+		if err != nil {
+			return req, err
+		}
+
+		lineTotal := price.amount * int64(item.Quantity)
+		if price.currency != "" && req.Currency != "" && !strings.EqualFold(price.currency, req.Currency) {
+			return req, fmt.Errorf("product %s is priced in %s, order currency is %s", item.ProductID, price.currency, req.Currency)
+		}
+		if int64(item.UnitPrice) != price.amount {
+			mismatches = append(mismatches, PriceMismatch{Index: i, ProductID: item.ProductID, Field: "unit_price",
+				Client: int64(item.UnitPrice), Server: price.amount})
+		}
+		if int64(item.TotalPrice) != lineTotal {
+			mismatches = append(mismatches, PriceMismatch{Index: i, ProductID: item.ProductID, Field: "total_price",
+				Client: int64(item.TotalPrice), Server: lineTotal})
+		}
+
+		item.UnitPrice = int(price.amount)
+		item.TotalPrice = int(lineTotal)
+		item.PriceListID = price.priceListID
+		verified.Items[i] = item
+		orderTotal += lineTotal
+	}
+
+	if int64(req.TotalAmount) != orderTotal {
+		mismatches = append(mismatches, PriceMismatch{Index: -1, Field: "total_amount",
+			Client: int64(req.TotalAmount), Server: orderTotal})
+	}
+	if len(mismatches) > 0 {
+		return req, &PriceMismatchError{Mismatches: mismatches}
+	}
+
+	verified.TotalAmount = int(orderTotal)
+	return verified, nil
+}
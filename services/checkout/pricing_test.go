@@ -0,0 +1,291 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	pricelistpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_list"
+	priceproductpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_product"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+)
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — server-side price verification
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_VerifyPrices(t *testing.T) {
+	t.Parallel()
+
+	t.Run("tampered unit price is rejected before any write", func(t *testing.T) {
+		t.Parallel()
+
+		revenueCreated := false
+		deps := mockDeps()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenueCreated = true
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		req := sampleRequest()
+		req.Items[0].UnitPrice = 100
+		req.Items[0].TotalPrice = 200
+		req.TotalAmount = 200
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), req)
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepVerifyPrices {
+			t.Fatalf("expected StepError for %s, got %v", StepVerifyPrices, err)
+		}
+		var mismatch *PriceMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected *PriceMismatchError, got %T", stepErr.Err)
+		}
+		want := []PriceMismatch{
+			{Index: 0, ProductID: "prod-001", Field: "unit_price", Client: 100, Server: 10000},
+			{Index: 0, ProductID: "prod-001", Field: "total_price", Client: 200, Server: 20000},
+			{Index: -1, Field: "total_amount", Client: 200, Server: 20000},
+		}
+		if len(mismatch.Mismatches) != len(want) {
+			t.Fatalf("mismatches = %+v, want %+v", mismatch.Mismatches, want)
+		}
+		for i := range want {
+			if mismatch.Mismatches[i] != want[i] {
+				t.Errorf("mismatch[%d] = %+v, want %+v", i, mismatch.Mismatches[i], want[i])
+			}
+		}
+		if revenueCreated {
+			t.Error("CreateRevenue should not be called when prices do not match")
+		}
+	})
+
+	t.Run("tampered order total is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		req := sampleRequest()
+		req.TotalAmount = 1
+
+		_, err := NewService(mockDeps()).PlaceOrder(context.Background(), req)
+		var mismatch *PriceMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected *PriceMismatchError, got %v", err)
+		}
+		if len(mismatch.Mismatches) != 1 || mismatch.Mismatches[0].Field != "total_amount" {
+			t.Errorf("mismatches = %+v, want only total_amount", mismatch.Mismatches)
+		}
+	})
+
+	t.Run("server price list is written to the line item", func(t *testing.T) {
+		t.Parallel()
+
+		var captured *lineItempb.RevenueLineItem
+		deps := mockDeps()
+		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			captured = req.GetData()
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+		}
+		req := sampleRequest()
+		req.Items[0].PriceListID = "pl-client"
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if captured.GetPriceListId() != "pl-001" {
+			t.Errorf("PriceListId = %q, want server price list pl-001", captured.GetPriceListId())
+		}
+	})
+
+	t.Run("looks up each item location for today", func(t *testing.T) {
+		t.Parallel()
+
+		var locations []string
+		dateRe := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+		deps := mockDeps()
+		deps.FindApplicablePriceList = func(_ context.Context, req *pricelistpb.FindApplicablePriceListRequest) (*pricelistpb.FindApplicablePriceListResponse, error) {
+			locations = append(locations, req.GetLocationId())
+			if !dateRe.MatchString(req.GetDate()) {
+				t.Errorf("Date = %q, want YYYY-MM-DD", req.GetDate())
+			}
+			return &pricelistpb.FindApplicablePriceListResponse{Success: true, Found: true, PriceList: &pricelistpb.PriceList{Id: "pl-001"}}, nil
+		}
+		deps.ListInventoryItems = nil // loc-002 holds no stock; pricing is what is under test
+		req := sampleRequest()
+		second := req.Items[0]
+		second.LocationID = "loc-002"
+		third := req.Items[0]
+		third.LocationID = "" // falls back to the order location
+		req.Items = append(req.Items, second, third)
+		req.TotalAmount = 60000
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fmt.Sprint(locations) != "[loc-001 loc-002]" {
+			t.Errorf("looked up locations %v, want [loc-001 loc-002] (cached per location)", locations)
+		}
+	})
+
+	t.Run("product without a price is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		req := sampleRequest()
+		req.Items[0].ProductID = "prod-unpriced"
+
+		_, err := NewService(mockDeps()).PlaceOrder(context.Background(), req)
+		if !errors.Is(err, ErrNoApplicablePrice) {
+			t.Fatalf("expected ErrNoApplicablePrice, got %v", err)
+		}
+	})
+
+	t.Run("location without a price list is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.FindApplicablePriceList = func(_ context.Context, _ *pricelistpb.FindApplicablePriceListRequest) (*pricelistpb.FindApplicablePriceListResponse, error) {
+			return &pricelistpb.FindApplicablePriceListResponse{Success: true, Found: false}, nil
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		if !errors.Is(err, ErrNoApplicablePrice) {
+			t.Fatalf("expected ErrNoApplicablePrice, got %v", err)
+		}
+	})
+
+	t.Run("non-positive quantity is rejected before pricing", func(t *testing.T) {
+		t.Parallel()
+
+		for _, qty := range []int{0, -3} {
+			deps := mockDeps()
+			deps.ListPriceProducts = func(_ context.Context, _ *priceproductpb.ListPriceProductsRequest) (*priceproductpb.ListPriceProductsResponse, error) {
+				t.Error("ListPriceProducts should not be called for an invalid quantity")
+				return nil, nil
+			}
+			req := sampleRequest()
+			req.Items[0].Quantity = qty
+			req.Items[0].TotalPrice = qty * req.Items[0].UnitPrice
+			req.TotalAmount = req.Items[0].TotalPrice
+
+			_, err := NewService(deps).PlaceOrder(context.Background(), req)
+			var stepErr *StepError
+			if !errors.As(err, &stepErr) || stepErr.Step != StepVerifyPrices {
+				t.Fatalf("quantity %d: expected StepError for %s, got %v", qty, StepVerifyPrices, err)
+			}
+			if !errors.Is(err, ErrInvalidQuantity) {
+				t.Errorf("quantity %d: expected ErrInvalidQuantity, got %v", qty, err)
+			}
+		}
+	})
+
+	t.Run("negative shipping fee is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		for _, trust := range []bool{false, true} {
+			deps := mockDeps()
+			deps.TrustClientPrices = trust
+			req := sampleRequest()
+			req.FulfillmentType = "home_delivery"
+			req.ShippingFee = -5000
+			req.TotalAmount = 15000
+
+			_, err := NewService(deps).PlaceOrder(context.Background(), req)
+			if !errors.Is(err, ErrInvalidShippingFee) {
+				t.Errorf("trust %v: expected ErrInvalidShippingFee, got %v", trust, err)
+			}
+		}
+	})
+
+	t.Run("shipping fee comes from the resolver", func(t *testing.T) {
+		t.Parallel()
+
+		var shipping *lineItempb.RevenueLineItem
+		deps := mockDeps()
+		deps.ResolveShippingFee = func(_ context.Context, req CheckoutRequest) (int64, error) {
+			if req.FulfillmentType != "home_delivery" {
+				return 0, nil
+			}
+			return 15000, nil
+		}
+		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			if req.GetData().GetDescription() == "Shipping" {
+				shipping = req.GetData()
+			}
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+		}
+		req := sampleRequest()
+		req.FulfillmentType = "home_delivery"
+		req.ShippingFee = 100
+		req.TotalAmount = 20100
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), req)
+		var mismatch *PriceMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected *PriceMismatchError, got %v", err)
+		}
+		want := []PriceMismatch{
+			{Index: -1, Field: "shipping_fee", Client: 100, Server: 15000},
+			{Index: -1, Field: "total_amount", Client: 20100, Server: 35000},
+		}
+		if fmt.Sprint(mismatch.Mismatches) != fmt.Sprint(want) {
+			t.Errorf("mismatches = %+v, want %+v", mismatch.Mismatches, want)
+		}
+
+		req.ShippingFee = 15000
+		req.TotalAmount = 35000
+		if _, err := NewService(deps).PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if shipping.GetTotalPrice() != 15000 {
+			t.Errorf("shipping line = %d, want 15000", shipping.GetTotalPrice())
+		}
+	})
+
+	t.Run("currency mismatch is rejected", func(t *testing.T) {
+		t.Parallel()
+
+		req := sampleRequest()
+		req.Currency = "USD"
+
+		if _, err := NewService(mockDeps()).PlaceOrder(context.Background(), req); err == nil {
+			t.Fatal("expected error when the price list currency differs from the order")
+		}
+	})
+
+	t.Run("unwired pricing fails closed", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.ListPriceProducts = nil
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err == nil {
+			t.Fatal("expected error when price lookup is not wired")
+		}
+	})
+
+	t.Run("trust client mode skips verification", func(t *testing.T) {
+		t.Parallel()
+
+		var captured *revenuepb.Revenue
+		deps := mockDeps()
+		deps.TrustClientPrices = true
+		deps.FindApplicablePriceList = nil
+		deps.ListPriceProducts = func(_ context.Context, _ *priceproductpb.ListPriceProductsRequest) (*priceproductpb.ListPriceProductsResponse, error) {
+			t.Error("ListPriceProducts should not be called in trust mode")
+			return nil, nil
+		}
+		deps.CreateRevenue = func(_ context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			captured = req.GetData()
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		req := sampleRequest()
+		req.TotalAmount = 12345
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if captured.GetTotalAmount() != 12345 {
+			t.Errorf("TotalAmount = %d, want client figure 12345", captured.GetTotalAmount())
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PromotionKind selects how a promotion computes its discount.
+type PromotionKind string
+
+const (
+	PromotionPercentage   PromotionKind = "percentage"    // PercentBasisPoints off eligible items
+	PromotionFixed        PromotionKind = "fixed"         // Amount off eligible items
+	PromotionBuyXGetY     PromotionKind = "buy_x_get_y"   // every BuyQuantity+GetQuantity units, GetQuantity are free
+	PromotionFreeShipping PromotionKind = "free_shipping" // ShippingFee waived on home_delivery orders
+)
+
+// Promotion is a redeemable discount code. Money is in centavos.
+type Promotion struct {
+	ID   string
+	Code string // matched case-insensitively
+	Name string
+	Kind PromotionKind
+
+	PercentBasisPoints int // PromotionPercentage: 1000 = 10%
+	Amount             int // PromotionFixed: centavos
+	BuyQuantity        int // PromotionBuyXGetY
+	GetQuantity        int // PromotionBuyXGetY
+
+	// ProductIDs limits percentage, fixed and buy-X-get-Y discounts to these
+	// products. Empty means every item.
+	ProductIDs []string
+	// MinSpend is the item subtotal (before discounts) the order must reach.
+	MinSpend int
+
+	// StartsAt/EndsAt bound the validity window; zero values leave that side open.
+	StartsAt time.Time
+	EndsAt   time.Time
+
+	// UsageLimit caps redemptions across all customers; PerClientLimit caps
+	// them per customer. Zero means unlimited.
+	UsageLimit     int
+	PerClientLimit int
+}
+
+// AppliedDiscount is one promotion applied to an order. It is written as a
+// "discount" line item and returned on CheckoutResult.Discounts.
+type AppliedDiscount struct {
+	PromotionID string
+	Code        string
+	Description string
+	Amount      int // centavos, positive
+}
+
+// Promotion rejection reasons carried by PromotionError.
+var (
+	ErrPromotionNotFound      = errors.New("promotion code not found")
+	ErrPromotionNotActive     = errors.New("promotion is not active")
+	ErrPromotionMinSpend      = errors.New("order is below the promotion minimum spend")
+	ErrPromotionNotApplicable = errors.New("promotion does not apply to this order")
+	ErrPromotionLimitReached  = errors.New("promotion usage limit reached")
+	ErrPromotionDuplicate     = errors.New("promotion code entered more than once")
+)
+
+// PromotionError is returned (wrapped in a StepError) when a code cannot be
+// applied, so the storefront can point at the offending code.
+type PromotionError struct {
+	Code string
+	Err  error
+}
+
+func (e *PromotionError) Error() string {
+	return fmt.Sprintf("promotion %q: %v", e.Code, e.Err)
+}
+
+func (e *PromotionError) Unwrap() error {
+	return e.Err
+}
+
+// PromotionStore looks up promotions and tracks redemptions. Redeem must be
+// atomic across instances (a conditional insert or counter update) — it is
+// what keeps usage limits intact under concurrent checkouts.
+// MemoryPromotionStore covers tests and single-process setups.
+type PromotionStore interface {
+	// FindPromotion returns the promotion for code, or (nil, nil) when there
+	// is none.
+	FindPromotion(ctx context.Context, code string) (*Promotion, error)
+	// Redeem records one use of promo by clientKey on revenueID, or returns
+	// ErrPromotionLimitReached when that would exceed UsageLimit or
+	// PerClientLimit.
+	Redeem(ctx context.Context, promo *Promotion, clientKey, revenueID string) error
+	// ReleaseRevenue gives back every redemption recorded for revenueID. It is
+	// called when the order is rolled back or its payment fails.
+	ReleaseRevenue(ctx context.Context, revenueID string) error
+}
+
+// applyPromotions validates req.PromotionCodes and computes the discount each
+// one gives. Codes are applied in order and the combined discount never
+// exceeds the order total. Nothing is redeemed here; see redeemPromotions.
+func (s *Service) applyPromotions(ctx context.Context, req CheckoutRequest, now time.Time) ([]AppliedDiscount, []*Promotion, error) {
+	if len(req.PromotionCodes) == 0 {
+		return nil, nil, nil
+	}
+	if s.deps.Promotions == nil {
+		return nil, nil, fmt.Errorf("promotions not configured")
+	}
+
+	subtotal := 0
+	for _, item := range req.Items {
+		subtotal += item.TotalPrice
+	}
+	remaining := subtotal + req.ShippingFee
+
+	seen := map[string]bool{}
+	var discounts []AppliedDiscount
+	var promos []*Promotion
+	for _, code := range req.PromotionCodes {
+		key := strings.ToUpper(strings.TrimSpace(code))
+		if seen[key] {
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionDuplicate}
+		}
+		seen[key] = true
+
+		promo, err := s.deps.Promotions.FindPromotion(ctx, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("find promotion %q: %w", code, err)
+		}
+		if promo == nil {
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionNotFound}
+		}
+		if (!promo.StartsAt.IsZero() && now.Before(promo.StartsAt)) || (!promo.EndsAt.IsZero() && !now.Before(promo.EndsAt)) {
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionNotActive}
+		}
+		if subtotal < promo.MinSpend {
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionMinSpend}
+		}
+		if promo.PerClientLimit > 0 && promotionClientKey(req) == "" {
+			// An order with no customer to count against cannot be held to
+			// the per-client limit.
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionNotApplicable}
+		}
+
+		amount := min(promotionDiscount(promo, req), remaining)
+		if amount <= 0 {
+			return nil, nil, &PromotionError{Code: code, Err: ErrPromotionNotApplicable}
+		}
+		remaining -= amount
+
+		name := promo.Name
+		if name == "" {
+			name = promo.Code
+		}
+		discounts = append(discounts, AppliedDiscount{
+			PromotionID: promo.ID,
+			Code:        promo.Code,
+			Description: fmt.Sprintf("Promo %s: %s", promo.Code, name),
+			Amount:      amount,
+		})
+		promos = append(promos, promo)
+	}
+	return discounts, promos, nil
+}
+
+// promotionDiscount is the undiscounted-order discount promo gives on req.
+func promotionDiscount(promo *Promotion, req CheckoutRequest) int {
+	switch promo.Kind {
+	case PromotionPercentage:
+		base := 0
+		for _, item := range req.Items {
+			if promo.appliesTo(item.ProductID) {
+				base += item.TotalPrice
+			}
+		}
+		return int(math.Round(float64(base) * float64(promo.PercentBasisPoints) / 10000))
+	case PromotionFixed:
+		base := 0
+		for _, item := range req.Items {
+			if promo.appliesTo(item.ProductID) {
+				base += item.TotalPrice
+			}
+		}
+		return min(promo.Amount, base)
+	case PromotionBuyXGetY:
+		if promo.BuyQuantity <= 0 || promo.GetQuantity <= 0 {
+			return 0
+		}
+		discount := 0
+		for _, item := range req.Items {
+			if promo.appliesTo(item.ProductID) {
+				free := item.Quantity / (promo.BuyQuantity + promo.GetQuantity) * promo.GetQuantity
+				discount += free * item.UnitPrice
+			}
+		}
+		return discount
+	case PromotionFreeShipping:
+		if req.FulfillmentType != "home_delivery" {
+			return 0
+		}
+		return req.ShippingFee
+	default:
+		return 0
+	}
+}
+
+// appliesTo reports whether promo's item discounts cover productID.
+func (p *Promotion) appliesTo(productID string) bool {
+	if len(p.ProductIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// promotionClientKey identifies the customer for per-client limits. Guest
+// checkouts without a client ID are keyed by email; an order with neither
+// has no key, and promotions with a per-client limit do not apply to it.
+func promotionClientKey(req CheckoutRequest) string {
+	if req.ClientID != "" {
+		return req.ClientID
+	}
+	return strings.ToLower(strings.TrimSpace(req.CustomerEmail))
+}
+
+// redeemPromotions records a redemption for every applied promotion and, once
+// any succeed, an undo on sg that gives them back.
+func (s *Service) redeemPromotions(ctx context.Context, promos []*Promotion, clientKey, revenueID string, sg *saga) error {
+	if len(promos) == 0 {
+		return nil
+	}
+	sg.record(StepRedeemPromotions, func(ctx context.Context) error {
+		return s.deps.Promotions.ReleaseRevenue(ctx, revenueID)
+	})
+	for _, promo := range promos {
+		if err := s.deps.Promotions.Redeem(ctx, promo, clientKey, revenueID); err != nil {
+			if errors.Is(err, ErrPromotionLimitReached) {
+				return &PromotionError{Code: promo.Code, Err: err}
+			}
+			return fmt.Errorf("redeem promotion %s: %w", promo.Code, err)
+		}
+	}
+	return nil
+}
+
+// promotionNote is written to a discount line item's notes so the line can be
+// traced back to its promotion.
+func promotionNote(d AppliedDiscount) string {
+	return fmt.Sprintf("promotion_id=%s; code=%s", d.PromotionID, d.Code)
+}
+
+// MemoryPromotionStore is an in-process PromotionStore. Redemptions are lost
+// on restart and are not shared between instances.
+type MemoryPromotionStore struct {
+	mu          sync.Mutex
+	promotions  map[string]*Promotion // by upper-case code
+	redemptions []promotionRedemption
+}
+
+type promotionRedemption struct {
+	promotionID string
+	clientKey   string
+	revenueID   string
+}
+
+// NewMemoryPromotionStore creates a store holding promos.
+func NewMemoryPromotionStore(promos ...Promotion) *MemoryPromotionStore {
+	m := &MemoryPromotionStore{promotions: map[string]*Promotion{}}
+	for _, p := range promos {
+		m.promotions[strings.ToUpper(p.Code)] = &p
+	}
+	return m
+}
+
+func (m *MemoryPromotionStore) FindPromotion(_ context.Context, code string) (*Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.promotions[strings.ToUpper(code)]
+	if !ok {
+		return nil, nil
+	}
+	found := *p
+	return &found, nil
+}
+
+func (m *MemoryPromotionStore) Redeem(_ context.Context, promo *Promotion, clientKey, revenueID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total, byClient := 0, 0
+	for _, r := range m.redemptions {
+		if r.promotionID != promo.ID {
+			continue
+		}
+		total++
+		if r.clientKey == clientKey {
+			byClient++
+		}
+	}
+	if (promo.UsageLimit > 0 && total >= promo.UsageLimit) || (promo.PerClientLimit > 0 && byClient >= promo.PerClientLimit) {
+		return ErrPromotionLimitReached
+	}
+	m.redemptions = append(m.redemptions, promotionRedemption{promotionID: promo.ID, clientKey: clientKey, revenueID: revenueID})
+	return nil
+}
+
+func (m *MemoryPromotionStore) ReleaseRevenue(_ context.Context, revenueID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.redemptions[:0]
+	for _, r := range m.redemptions {
+		if r.revenueID != revenueID {
+			kept = append(kept, r)
+		}
+	}
+	m.redemptions = kept
+	return nil
+}
+
+// Redemptions returns how many times promotionID has been redeemed.
+func (m *MemoryPromotionStore) Redemptions(promotionID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, r := range m.redemptions {
+		if r.promotionID == promotionID {
+			n++
+		}
+	}
+	return n
+}
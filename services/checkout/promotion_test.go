@@ -0,0 +1,310 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// applyPromotions — discount rules
+// ---------------------------------------------------------------------------
+
+func TestApplyPromotions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryPromotionStore(
+		Promotion{ID: "p-pct", Code: "TEN", Name: "10% off", Kind: PromotionPercentage, PercentBasisPoints: 1000},
+		Promotion{ID: "p-fix", Code: "LESS50", Kind: PromotionFixed, Amount: 5000},
+		Promotion{ID: "p-big", Code: "HUGE", Kind: PromotionFixed, Amount: 1_000_000},
+		Promotion{ID: "p-b2g1", Code: "B2G1", Kind: PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1},
+		Promotion{ID: "p-ship", Code: "FREESHIP", Kind: PromotionFreeShipping},
+		Promotion{ID: "p-gadget", Code: "GADGET", Kind: PromotionPercentage, PercentBasisPoints: 5000, ProductIDs: []string{"prod-gadget"}},
+		Promotion{ID: "p-min", Code: "MIN500", Kind: PromotionFixed, Amount: 1000, MinSpend: 50000},
+		Promotion{ID: "p-future", Code: "SOON", Kind: PromotionFixed, Amount: 1000, StartsAt: now.Add(time.Hour)},
+		Promotion{ID: "p-past", Code: "GONE", Kind: PromotionFixed, Amount: 1000, EndsAt: now},
+	)
+	svc := NewService(CheckoutDeps{Promotions: store})
+
+	order := func(qty int) CheckoutRequest {
+		req := sampleRequest()
+		req.Items[0].Quantity = qty
+		req.Items[0].TotalPrice = qty * 10000
+		return req
+	}
+	delivery := func(fee int) CheckoutRequest {
+		req := sampleRequest()
+		req.FulfillmentType = "home_delivery"
+		req.ShippingFee = fee
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     CheckoutRequest
+		codes   []string
+		want    []int
+		wantErr error
+	}{
+		{name: "percentage of subtotal", req: order(2), codes: []string{"TEN"}, want: []int{2000}},
+		{name: "codes are case-insensitive", req: order(2), codes: []string{" ten "}, want: []int{2000}},
+		{name: "fixed amount", req: order(2), codes: []string{"LESS50"}, want: []int{5000}},
+		{name: "fixed is capped at the order total", req: order(2), codes: []string{"HUGE"}, want: []int{20000}},
+		{name: "stacked codes never exceed the total", req: order(2), codes: []string{"TEN", "HUGE"}, want: []int{2000, 18000}},
+		{name: "buy two get one", req: order(7), codes: []string{"B2G1"}, want: []int{20000}},
+		{name: "buy two get one below threshold", req: order(2), codes: []string{"B2G1"}, wantErr: ErrPromotionNotApplicable},
+		{name: "free shipping on home delivery", req: delivery(15000), codes: []string{"FREESHIP"}, want: []int{15000}},
+		{name: "free shipping on pickup", req: order(2), codes: []string{"FREESHIP"}, wantErr: ErrPromotionNotApplicable},
+		{name: "product restriction", req: order(2), codes: []string{"GADGET"}, wantErr: ErrPromotionNotApplicable},
+		{name: "minimum spend not met", req: order(2), codes: []string{"MIN500"}, wantErr: ErrPromotionMinSpend},
+		{name: "minimum spend met", req: order(5), codes: []string{"MIN500"}, want: []int{1000}},
+		{name: "not started", req: order(2), codes: []string{"SOON"}, wantErr: ErrPromotionNotActive},
+		{name: "ended", req: order(2), codes: []string{"GONE"}, wantErr: ErrPromotionNotActive},
+		{name: "unknown code", req: order(2), codes: []string{"NOPE"}, wantErr: ErrPromotionNotFound},
+		{name: "duplicate code", req: order(2), codes: []string{"TEN", "ten"}, wantErr: ErrPromotionDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.req.PromotionCodes = tt.codes
+			discounts, _, err := svc.applyPromotions(context.Background(), tt.req, now)
+			if tt.wantErr != nil {
+				var promoErr *PromotionError
+				if !errors.Is(err, tt.wantErr) || !errors.As(err, &promoErr) {
+					t.Fatalf("err = %v, want *PromotionError wrapping %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int
+			for _, d := range discounts {
+				got = append(got, d.Amount)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("discounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — promotion codes
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_Promotions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("discount line references the promotion and the total is net", func(t *testing.T) {
+		t.Parallel()
+
+		var mu sync.Mutex
+		var lines []*lineItempb.RevenueLineItem
+		var revenueTotal int64
+		var sessionAmount int64
+		deps := mockDeps()
+		deps.Promotions = NewMemoryPromotionStore(Promotion{ID: "p-pct", Code: "TEN", Name: "10% off", Kind: PromotionPercentage, PercentBasisPoints: 1000})
+		deps.CreateRevenue = func(_ context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenueTotal = req.GetData().GetTotalAmount()
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			lines = append(lines, req.GetData())
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+		}
+		deps.CreateCheckoutSession = func(_ context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+			sessionAmount = req.GetData().GetAmount()
+			return &paymentpb.CreateCheckoutSessionResponse{Success: true}, nil
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "maya"
+		req.PromotionCodes = []string{"ten"}
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.TotalAmount != 18000 || revenueTotal != 18000 || sessionAmount != 18000 {
+			t.Errorf("totals: result %d, revenue %d, session %d; want 18000", result.TotalAmount, revenueTotal, sessionAmount)
+		}
+		if len(result.Discounts) != 1 || result.Discounts[0].PromotionID != "p-pct" || result.Discounts[0].Amount != 2000 {
+			t.Errorf("Discounts = %+v", result.Discounts)
+		}
+		if len(lines) != 2 {
+			t.Fatalf("created %d line items, want item + discount", len(lines))
+		}
+		discount := lines[1]
+		if discount.GetLineItemType() != "discount" || discount.GetTotalPrice() != -2000 || discount.GetQuantity() != 1 {
+			t.Errorf("discount line = %+v", discount)
+		}
+		if discount.GetNotes() != "promotion_id=p-pct; code=TEN" {
+			t.Errorf("Notes = %q", discount.GetNotes())
+		}
+	})
+
+	t.Run("shipping fee is verified and waived by free shipping", func(t *testing.T) {
+		t.Parallel()
+
+		var lines []*lineItempb.RevenueLineItem
+		deps := mockDeps()
+		deps.Promotions = NewMemoryPromotionStore(Promotion{ID: "p-ship", Code: "FREESHIP", Kind: PromotionFreeShipping})
+		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			lines = append(lines, req.GetData())
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
+		}
+		req := sampleRequest()
+		req.FulfillmentType = "home_delivery"
+		req.ShippingFee = 15000
+		req.TotalAmount = 35000
+		req.PromotionCodes = []string{"FREESHIP"}
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.TotalAmount != 20000 {
+			t.Errorf("TotalAmount = %d, want 20000", result.TotalAmount)
+		}
+		if len(lines) != 3 || lines[1].GetTotalPrice() != 15000 || lines[2].GetTotalPrice() != -15000 {
+			t.Errorf("lines = %+v, want item, shipping 15000, discount -15000", lines)
+		}
+	})
+
+	t.Run("codes without a promotion store are rejected", func(t *testing.T) {
+		t.Parallel()
+
+		req := sampleRequest()
+		req.PromotionCodes = []string{"TEN"}
+
+		_, err := NewService(mockDeps()).PlaceOrder(context.Background(), req)
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepApplyPromotions {
+			t.Fatalf("expected StepError for %s, got %v", StepApplyPromotions, err)
+		}
+	})
+
+	t.Run("per-client limit", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryPromotionStore(Promotion{ID: "p-once", Code: "ONCE", Kind: PromotionFixed, Amount: 1000, PerClientLimit: 1})
+		var revenueSeq int32
+		deps := mockDeps()
+		deps.Promotions = store
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			id := fmt.Sprintf("rev-%03d", atomic.AddInt32(&revenueSeq, 1))
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: id}}}, nil
+		}
+		svc := NewService(deps)
+		req := sampleRequest()
+		req.PromotionCodes = []string{"ONCE"}
+
+		if _, err := svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("first order: %v", err)
+		}
+		_, err := svc.PlaceOrder(context.Background(), req)
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepRedeemPromotions || !errors.Is(err, ErrPromotionLimitReached) {
+			t.Fatalf("second order: expected %s limit error, got %v", StepRedeemPromotions, err)
+		}
+
+		other := sampleRequest()
+		other.ClientID = "client-002"
+		other.PromotionCodes = []string{"ONCE"}
+		if _, err := svc.PlaceOrder(context.Background(), other); err != nil {
+			t.Fatalf("other client: %v", err)
+		}
+		if n := store.Redemptions("p-once"); n != 2 {
+			t.Errorf("Redemptions = %d, want 2", n)
+		}
+
+		anonymous := sampleRequest()
+		anonymous.ClientID, anonymous.CustomerEmail = "", " "
+		anonymous.PromotionCodes = []string{"ONCE"}
+		_, err = svc.PlaceOrder(context.Background(), anonymous)
+		if !errors.As(err, &stepErr) || stepErr.Step != StepApplyPromotions || !errors.Is(err, ErrPromotionNotApplicable) {
+			t.Fatalf("no client key: expected %s not-applicable error, got %v", StepApplyPromotions, err)
+		}
+		if n := store.Redemptions("p-once"); n != 2 {
+			t.Errorf("Redemptions after anonymous order = %d, want 2", n)
+		}
+	})
+
+	t.Run("failed payment gives the redemption back", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryPromotionStore(Promotion{ID: "p-fix", Code: "LESS10", Kind: PromotionFixed, Amount: 1000, UsageLimit: 1})
+		deps := mockDeps()
+		deps.Promotions = store
+		svc := NewService(deps)
+		req := sampleRequest()
+		req.PromotionCodes = []string{"LESS10"}
+
+		if _, err := svc.PlaceOrder(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := svc.releaseReservations(context.Background(), "rev-001", "payment failed"); err != nil {
+			t.Fatalf("releaseReservations: %v", err)
+		}
+		if n := store.Redemptions("p-fix"); n != 0 {
+			t.Errorf("Redemptions = %d after release, want 0", n)
+		}
+	})
+}
+
+func TestPlaceOrder_PromotionUsageLimitUnderConcurrency(t *testing.T) {
+	t.Parallel()
+
+	const orders, limit = 20, 5
+	store := NewMemoryPromotionStore(Promotion{ID: "p-flash", Code: "FLASH", Kind: PromotionFixed, Amount: 1000, UsageLimit: limit})
+	var revenueSeq int32
+	deps := mockDeps()
+	deps.Promotions = store
+	deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+		id := fmt.Sprintf("rev-%03d", atomic.AddInt32(&revenueSeq, 1))
+		return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: id}}}, nil
+	}
+	svc := NewService(deps)
+
+	var wg sync.WaitGroup
+	var placed, limited int32
+	for i := range orders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := sampleRequest()
+			req.ClientID = fmt.Sprintf("client-%02d", i)
+			req.PromotionCodes = []string{"FLASH"}
+			_, err := svc.PlaceOrder(context.Background(), req)
+			switch {
+			case err == nil:
+				atomic.AddInt32(&placed, 1)
+			case errors.Is(err, ErrPromotionLimitReached):
+				atomic.AddInt32(&limited, 1)
+			default:
+				t.Errorf("order %d: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if placed != limit || limited != orders-limit {
+		t.Errorf("placed %d, limited %d; want %d and %d", placed, limited, limit, orders-limit)
+	}
+	if n := store.Redemptions("p-flash"); n != limit {
+		t.Errorf("Redemptions = %d, want %d", n, limit)
+	}
+}
@@ -0,0 +1,329 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	commonpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
+	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+)
+
+// DefaultReservationTTL is how long a pending order holds its stock when
+// CheckoutDeps.ReservationTTL is not set.
+const DefaultReservationTTL = 30 * time.Minute
+
+// serialReferenceRelease is the serial_history reference type written when a
+// reserved serial goes back to available without being sold.
+const serialReferenceRelease = "released"
+
+// unreservedNote starts the note added to an order paid after its
+// reservation was released when its stock could not be reserved again.
+const unreservedNote = "STOCK NOT RESERVED: paid after the reservation was released; reserve stock before fulfilling."
+
+// SweepResult reports what ReleaseExpiredReservations did.
+type SweepResult struct {
+	Released []string // revenue IDs cancelled and released
+	Failed   []string // revenue IDs that could not be fully released; details are logged
+}
+
+// reservationTTL returns the configured reservation window.
+func (s *Service) reservationTTL() time.Duration {
+	if s.deps.ReservationTTL > 0 {
+		return s.deps.ReservationTTL
+	}
+	return DefaultReservationTTL
+}
+
+// ReleaseExpiredReservations cancels pending checkout orders created more than
+// olderThan ago and gives their stock and serials back. A zero olderThan uses
+// the service's reservation TTL. It is safe to call repeatedly — orders are
+// picked up only while still pending — and is meant to be driven by a cron job
+// in the consumer app.
+func (s *Service) ReleaseExpiredReservations(ctx context.Context, olderThan time.Duration) (*SweepResult, error) {
+	if s.deps.ListRevenues == nil {
+		return nil, fmt.Errorf("checkout: sweep: ListRevenues not configured")
+	}
+	if olderThan <= 0 {
+		olderThan = s.reservationTTL()
+	}
+	cutoff := time.Now().Add(-olderThan).UnixMilli()
+
+	listResp, err := s.deps.ListRevenues(ctx, &revenuepb.ListRevenuesRequest{
+		Filters: &commonpb.FilterRequest{
+			Logic: commonpb.FilterLogic_AND,
+			Filters: []*commonpb.TypedFilter{
+				{
+					Field: "status",
+					FilterType: &commonpb.TypedFilter_StringFilter{
+						StringFilter: &commonpb.StringFilter{
+							Value:    "pending",
+							Operator: commonpb.StringOperator_STRING_EQUALS,
+						},
+					},
+				},
+				{
+					Field: "reference_number",
+					FilterType: &commonpb.TypedFilter_StringFilter{
+						StringFilter: &commonpb.StringFilter{
+							Value:    "ORD-",
+							Operator: commonpb.StringOperator_STRING_STARTS_WITH,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checkout: sweep: list pending orders: %w", err)
+	}
+
+	result := &SweepResult{}
+	for _, rev := range listResp.GetData() {
+		// Re-check in memory: the filters are a hint to the backend, not a
+		// guarantee, and the age cut-off is not pushed down at all.
+		if rev.GetStatus() != "pending" || !strings.HasPrefix(rev.GetReferenceNumber(), "ORD-") {
+			continue
+		}
+		if rev.GetDateCreated() == 0 || rev.GetDateCreated() > cutoff {
+			continue
+		}
+
+		if err := s.expireOrder(ctx, rev); err != nil {
+			log.Printf("checkout: sweep: release %s: %v", rev.GetId(), err)
+			result.Failed = append(result.Failed, rev.GetId())
+			continue
+		}
+		result.Released = append(result.Released, rev.GetId())
+	}
+	return result, nil
+}
+
+// expireOrder voids the provider session, cancels the revenue and releases
+// its reservations.
+func (s *Service) expireOrder(ctx context.Context, rev *revenuepb.Revenue) error {
+	return s.cancelPendingOrder(ctx, rev, "reservation expired")
+}
+
+// cancelPendingOrder voids the provider session of an unpaid order, cancels
+// the revenue and releases its reservations. The revenue is cancelled first:
+// if the release then fails the stock stays reserved (and is reported)
+// instead of being released twice by the next sweep.
+func (s *Service) cancelPendingOrder(ctx context.Context, rev *revenuepb.Revenue, reason string) error {
+	if err := s.voidCheckoutSession(ctx, rev.GetPaymentProvider(), rev.GetCheckoutSessionId(), rev.GetReferenceNumber()); err != nil {
+		log.Printf("checkout: cancel %s: %v", rev.GetId(), err)
+	}
+	if err := s.cancelRevenue(ctx, rev.GetId()); err != nil {
+		return err
+	}
+	return s.releaseReservations(ctx, rev.GetId(), reason)
+}
+
+// releaseReservations gives back the stock and serials held by a checkout
+// order: quantity_reserved moves back to quantity_available for every item
+// line, every serial this order reserved returns to available with a
+// "released" serial_history entry, and its promotion redemptions are given
+// back. reason is recorded in the history notes.
+func (s *Service) releaseReservations(ctx context.Context, revenueID, reason string) error {
+	if s.deps.ListLineItems == nil {
+		return fmt.Errorf("release %s: ListLineItems not configured", revenueID)
+	}
+
+	lineResp, err := s.deps.ListLineItems(ctx, &lineItempb.ListRevenueLineItemsRequest{
+		RevenueId: &revenueID,
+	})
+	if err != nil {
+		return fmt.Errorf("release %s: list line items: %w", revenueID, err)
+	}
+
+	var errs []string
+	if s.deps.ListInventoryItems != nil && s.deps.UpdateInventoryItem != nil {
+		for _, item := range stockItems(lineResp.GetData()) {
+			if err := s.releaseStock(ctx, item); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if err := s.releaseSerials(ctx, revenueID, reason); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if s.deps.Promotions != nil {
+		if err := s.deps.Promotions.ReleaseRevenue(ctx, revenueID); err != nil {
+			errs = append(errs, fmt.Sprintf("release promotions: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("release %s: %s", revenueID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// stockItems returns the order's stock-tracked lines as checkout items, with
+// the inventory record each was reserved at.
+func stockItems(lines []*lineItempb.RevenueLineItem) []CheckoutItem {
+	var items []CheckoutItem
+	for _, li := range lines {
+		if li.GetLineItemType() != "item" || li.GetProductId() == "" {
+			continue
+		}
+		items = append(items, CheckoutItem{
+			ProductID:       li.GetProductId(),
+			LocationID:      li.GetLocationId(),
+			Quantity:        int(math.Round(li.GetQuantity())),
+			inventoryItemID: li.GetInventoryItemId(),
+		})
+	}
+	return items
+}
+
+// rereserveOrder reserves the stock and serials of an order again after its
+// reservation was released, for a payment that landed after the order was
+// cancelled. It is all or nothing: when any line cannot be reserved, the
+// lines already reserved are given back and the error is returned.
+// Promotion redemptions stay released.
+func (s *Service) rereserveOrder(ctx context.Context, revenueID string) error {
+	if s.deps.ListLineItems == nil {
+		return fmt.Errorf("re-reserve %s: ListLineItems not configured", revenueID)
+	}
+	lineResp, err := s.deps.ListLineItems(ctx, &lineItempb.ListRevenueLineItemsRequest{
+		RevenueId: &revenueID,
+	})
+	if err != nil {
+		return fmt.Errorf("re-reserve %s: list line items: %w", revenueID, err)
+	}
+	items := stockItems(lineResp.GetData())
+
+	sg := &saga{}
+	if err := s.reserveStock(ctx, items, sg); err != nil {
+		sg.unwind(ctx)
+		return fmt.Errorf("re-reserve %s: %w", revenueID, err)
+	}
+	if err := s.reserveSerials(ctx, revenueID, items, sg); err != nil {
+		sg.unwind(ctx)
+		return fmt.Errorf("re-reserve %s: %w", revenueID, err)
+	}
+	return nil
+}
+
+// flagUnreserved records on a paid revenue, in its notes, that its stock is
+// not reserved, so staff see it on the order before fulfilling it.
+func (s *Service) flagUnreserved(ctx context.Context, revenueID string, cause error) error {
+	note := fmt.Sprintf("%s %s", unreservedNote, cause)
+	if s.deps.ReadRevenue != nil {
+		resp, err := s.deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: revenueID}})
+		if err == nil && len(resp.GetData()) > 0 {
+			if existing := resp.GetData()[0].GetNotes(); existing != "" {
+				note = existing + "\n" + note
+			}
+		}
+	}
+	_, err := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+		Data: &revenuepb.Revenue{
+			Id:                 revenueID,
+			Notes:              &note,
+			DateModified:       ptr(time.Now().UnixMilli()),
+			DateModifiedString: ptr(time.Now().Format(time.RFC3339)),
+		},
+	})
+	return err
+}
+
+// releaseSerials returns every serial still reserved for revenueID to
+// available. Reservations are found through serial_history, since the serial
+// record itself does not point back to the order. Needs ListSerialHistory;
+// without it serial release is skipped and logged.
+func (s *Service) releaseSerials(ctx context.Context, revenueID, reason string) error {
+	if s.deps.ListSerials == nil || s.deps.UpdateSerial == nil {
+		return nil
+	}
+	if s.deps.ListSerialHistory == nil {
+		log.Printf("checkout: release serials for %s: ListSerialHistory not configured, serials left reserved", revenueID)
+		return nil
+	}
+
+	reserved, err := s.orderSerials(ctx, revenueID)
+	if err != nil {
+		return err
+	}
+
+	// Group by inventory item so each item's serials are listed once.
+	byItem := map[string][]string{}
+	for serialID, invItemID := range reserved {
+		byItem[invItemID] = append(byItem[invItemID], serialID)
+	}
+
+	var errs []string
+	for invItemID, serialIDs := range byItem {
+		serialResp, err := s.deps.ListSerials(ctx, &serialpb.ListInventorySerialsRequest{
+			InventoryItemId: &invItemID,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("list serials for %s: %v", invItemID, err))
+			continue
+		}
+		current := map[string]*serialpb.InventorySerial{}
+		for _, serial := range serialResp.GetData() {
+			current[serial.GetId()] = serial
+		}
+		for _, serialID := range serialIDs {
+			serial, ok := current[serialID]
+			// Only serials still held by this order — one already sold or
+			// released by staff is left alone.
+			if !ok || serial.GetStatus() != "reserved" {
+				continue
+			}
+			if err := s.transitionSerial(ctx, serial, "reserved", "available", serialReferenceRelease, revenueID,
+				fmt.Sprintf("Released from order %s: %s", revenueID, reason)); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("release serials: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// orderSerials returns serial ID → inventory item ID for every serial the
+// order reserved at checkout, found through serial_history since the serial
+// record itself does not point back to the order. The serials' current
+// status is not checked.
+func (s *Service) orderSerials(ctx context.Context, revenueID string) (map[string]string, error) {
+	histResp, err := s.deps.ListSerialHistory(ctx, &serialHistorypb.ListInventorySerialHistoryRequest{
+		Filters: &commonpb.FilterRequest{
+			Logic: commonpb.FilterLogic_AND,
+			Filters: []*commonpb.TypedFilter{
+				{
+					Field: "reference_id",
+					FilterType: &commonpb.TypedFilter_StringFilter{
+						StringFilter: &commonpb.StringFilter{
+							Value:    revenueID,
+							Operator: commonpb.StringOperator_STRING_EQUALS,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list serial history: %w", err)
+	}
+
+	reserved := map[string]string{}
+	for _, h := range histResp.GetData() {
+		if h.GetReferenceId() == revenueID && h.GetToStatus() == "reserved" {
+			reserved[h.GetInventorySerialId()] = h.GetInventoryItemId()
+		}
+	}
+	return reserved, nil
+}
@@ -0,0 +1,416 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// reservationDeps extends the saga recorder with line item and serial history
+// reads so a placed order can be released again.
+func reservationDeps(rec *sagaRecorder) CheckoutDeps {
+	deps := rec.deps()
+	deps.ListLineItems = func(_ context.Context, _ *lineItempb.ListRevenueLineItemsRequest) (*lineItempb.ListRevenueLineItemsResponse, error) {
+		pid, loc := "prod-001", "loc-001"
+		return &lineItempb.ListRevenueLineItemsResponse{
+			Success: true,
+			Data: []*lineItempb.RevenueLineItem{{
+				Id:           "li-001",
+				RevenueId:    "rev-001",
+				ProductId:    &pid,
+				LocationId:   &loc,
+				Quantity:     2,
+				LineItemType: "item",
+			}},
+		}, nil
+	}
+	deps.ListSerialHistory = func(_ context.Context, _ *serialHistorypb.ListInventorySerialHistoryRequest) (*serialHistorypb.ListInventorySerialHistoryResponse, error) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return &serialHistorypb.ListInventorySerialHistoryResponse{Success: true, Data: append([]*serialHistorypb.InventorySerialHistory(nil), rec.history...)}, nil
+	}
+	return deps
+}
+
+// ---------------------------------------------------------------------------
+// HandlePaymentWebhook — reservation release
+// ---------------------------------------------------------------------------
+
+func TestHandlePaymentWebhook_ReleasesReservations(t *testing.T) {
+	t.Parallel()
+
+	for _, st := range []paymentpb.PaymentStatus{
+		paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED,
+		paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED,
+		paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+	} {
+		t.Run(st.String(), func(t *testing.T) {
+			t.Parallel()
+
+			rec := newSagaRecorder()
+			deps := reservationDeps(rec)
+			deps.Idempotency = NewMemoryIdempotencyStore()
+			svc := NewService(deps)
+			if _, err := svc.PlaceOrder(context.Background(), sampleRequest()); err != nil {
+				t.Fatalf("PlaceOrder: %v", err)
+			}
+			if rec.reserved != 2 || rec.serialStatus["s-1"] != "reserved" {
+				t.Fatalf("precondition: reserved = %v, s-1 = %q", rec.reserved, rec.serialStatus["s-1"])
+			}
+
+			deps.ProcessWebhook = func(_ context.Context, _ *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+				return &paymentpb.ProcessWebhookResponse{
+					Success: true,
+					Data:    []*paymentpb.WebhookResult{{PaymentId: "rev-001", Status: st}},
+				}, nil
+			}
+			result, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status != "cancelled" {
+				t.Errorf("Status = %q, want cancelled", result.Status)
+			}
+			if rec.available != 10 || rec.reserved != 0 {
+				t.Errorf("available/reserved = %v/%v, want 10/0", rec.available, rec.reserved)
+			}
+			for id, status := range rec.serialStatus {
+				if status != "available" {
+					t.Errorf("serial %s = %q, want available", id, status)
+				}
+			}
+
+			var released int
+			for _, h := range rec.history {
+				if h.GetReferenceType() == serialReferenceRelease {
+					released++
+					if h.GetFromStatus() != "reserved" || h.GetToStatus() != "available" {
+						t.Errorf("release history = %s→%s, want reserved→available", h.GetFromStatus(), h.GetToStatus())
+					}
+				}
+			}
+			if released != 2 {
+				t.Errorf("expected 2 released history entries, got %d", released)
+			}
+		})
+	}
+
+	t.Run("paid webhook keeps the reservation", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		if _, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rec.reserved != 2 {
+			t.Errorf("reserved = %v, want 2", rec.reserved)
+		}
+	})
+
+	t.Run("cancel events of one payment release once", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		deps.Idempotency = NewMemoryIdempotencyStore()
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		// A second order's reservation must survive the duplicate release.
+		rec.available, rec.reserved = rec.available-2, rec.reserved+2
+
+		for _, st := range []paymentpb.PaymentStatus{
+			paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED,
+			paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED,
+		} {
+			deps.ProcessWebhook = func(_ context.Context, _ *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+				return &paymentpb.ProcessWebhookResponse{
+					Success: true,
+					Data:    []*paymentpb.WebhookResult{{PaymentId: "rev-001", Status: st}},
+				}, nil
+			}
+			if _, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{}); err != nil {
+				t.Fatalf("%s: unexpected error: %v", st, err)
+			}
+		}
+		if rec.available != 8 || rec.reserved != 2 {
+			t.Errorf("available/reserved = %v/%v, want 8/2", rec.available, rec.reserved)
+		}
+	})
+
+	t.Run("without ReadRevenue or Idempotency the stock stays reserved", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		deps.ProcessWebhook = func(_ context.Context, _ *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+			return &paymentpb.ProcessWebhookResponse{
+				Success: true,
+				Data:    []*paymentpb.WebhookResult{{PaymentId: "rev-001", Status: paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED}},
+			}, nil
+		}
+		result, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != "cancelled" {
+			t.Errorf("Status = %q, want cancelled", result.Status)
+		}
+		if rec.reserved != 2 {
+			t.Errorf("reserved = %v, want 2", rec.reserved)
+		}
+	})
+
+	t.Run("serial sold in the meantime is not released", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		rec.serialStatus["s-1"] = "sold"
+
+		if err := NewService(deps).releaseReservations(context.Background(), "rev-001", "test"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rec.serialStatus["s-1"] != "sold" {
+			t.Errorf("s-1 = %q, want sold", rec.serialStatus["s-1"])
+		}
+		if rec.serialStatus["s-2"] != "available" {
+			t.Errorf("s-2 = %q, want available", rec.serialStatus["s-2"])
+		}
+	})
+}
+
+func TestHandlePaymentWebhook_PaidAfterRelease(t *testing.T) {
+	t.Parallel()
+
+	// expireThenPay places an order, expires its payment (releasing the
+	// stock) and returns deps whose next webhook reports it paid.
+	expireThenPay := func(t *testing.T, rec *sagaRecorder) CheckoutDeps {
+		t.Helper()
+		deps := reservationDeps(rec)
+		deps.ReadRevenue = func(_ context.Context, _ *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			return &revenuepb.ReadRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001", Status: rec.revenueStatus}}}, nil
+		}
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+		status := paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED
+		deps.ProcessWebhook = func(_ context.Context, _ *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error) {
+			return &paymentpb.ProcessWebhookResponse{
+				Success: true,
+				Data:    []*paymentpb.WebhookResult{{PaymentId: "rev-001", Status: status}},
+			}, nil
+		}
+		if _, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{}); err != nil {
+			t.Fatalf("expiry: %v", err)
+		}
+		if rec.reserved != 0 {
+			t.Fatalf("precondition: reserved = %v after expiry, want 0", rec.reserved)
+		}
+		status = paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS
+		return deps
+	}
+
+	t.Run("stock is reserved again", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := expireThenPay(t, rec)
+		result, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != "paid" || result.Unreserved {
+			t.Errorf("result = %+v, want paid and reserved", result)
+		}
+		if rec.available != 8 || rec.reserved != 2 {
+			t.Errorf("available/reserved = %v/%v, want 8/2", rec.available, rec.reserved)
+		}
+		if rec.serialStatus["s-1"] != "reserved" || rec.serialStatus["s-2"] != "reserved" {
+			t.Errorf("serials = %v, want both reserved", rec.serialStatus)
+		}
+	})
+
+	t.Run("order is flagged when the stock is gone", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := expireThenPay(t, rec)
+		deps.UpdateInventoryItem = func(context.Context, *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+			return nil, shared.ErrInsufficientStock
+		}
+		inner := deps.UpdateRevenue
+		var notes string
+		deps.UpdateRevenue = func(ctx context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			if req.GetData().Notes != nil {
+				notes = req.GetData().GetNotes()
+			}
+			return inner(ctx, req)
+		}
+
+		result, err := NewService(deps).HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Status != "paid" || !result.Unreserved || rec.revenueStatus != "paid" {
+			t.Errorf("result = %+v, revenue %q; want paid and unreserved", result, rec.revenueStatus)
+		}
+		if !strings.HasPrefix(notes, unreservedNote) {
+			t.Errorf("notes = %q, want the unreserved note", notes)
+		}
+		if rec.reserved != 0 {
+			t.Errorf("reserved = %v, want 0", rec.reserved)
+		}
+	})
+}
+
+// ---------------------------------------------------------------------------
+// ReleaseExpiredReservations
+// ---------------------------------------------------------------------------
+
+func TestReleaseExpiredReservations(t *testing.T) {
+	t.Parallel()
+
+	pendingOrder := func(id string, age time.Duration) *revenuepb.Revenue {
+		ref := "ORD-" + id
+		created := time.Now().Add(-age).UnixMilli()
+		return &revenuepb.Revenue{Id: id, Status: "pending", ReferenceNumber: &ref, DateCreated: &created}
+	}
+
+	t.Run("releases only pending orders older than the window", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err != nil {
+			t.Fatalf("PlaceOrder: %v", err)
+		}
+
+		manualRef := "INV-0001"
+		old := time.Now().Add(-2 * time.Hour).UnixMilli()
+		deps.ListRevenues = func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			return &revenuepb.ListRevenuesResponse{
+				Success: true,
+				Data: []*revenuepb.Revenue{
+					pendingOrder("rev-001", time.Hour),
+					pendingOrder("rev-fresh", time.Minute),
+					{Id: "rev-manual", Status: "pending", ReferenceNumber: &manualRef, DateCreated: &old},
+				},
+			}, nil
+		}
+
+		result, err := NewService(deps).ReleaseExpiredReservations(context.Background(), 30*time.Minute)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Released) != 1 || result.Released[0] != "rev-001" {
+			t.Errorf("Released = %v, want [rev-001]", result.Released)
+		}
+		if rec.revenueStatus != "cancelled" {
+			t.Errorf("revenue status = %q, want cancelled", rec.revenueStatus)
+		}
+		if rec.available != 10 || rec.reserved != 0 {
+			t.Errorf("available/reserved = %v/%v, want 10/0", rec.available, rec.reserved)
+		}
+	})
+
+	t.Run("zero window uses the configured TTL", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		deps.ReservationTTL = 2 * time.Hour
+		deps.ListRevenues = func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			return &revenuepb.ListRevenuesResponse{Success: true, Data: []*revenuepb.Revenue{pendingOrder("rev-001", time.Hour)}}, nil
+		}
+
+		result, err := NewService(deps).ReleaseExpiredReservations(context.Background(), 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Released) != 0 {
+			t.Errorf("Released = %v, want none inside the 2h TTL", result.Released)
+		}
+	})
+
+	t.Run("voids the open checkout session", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		deps.ListRevenues = func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			rev := pendingOrder("rev-001", time.Hour)
+			session, provider := "session-001", "maya"
+			rev.CheckoutSessionId = &session
+			rev.PaymentProvider = &provider
+			return &revenuepb.ListRevenuesResponse{Success: true, Data: []*revenuepb.Revenue{rev}}, nil
+		}
+
+		if _, err := NewService(deps).ReleaseExpiredReservations(context.Background(), time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rec.voidedSessions) != 1 || rec.voidedSessions[0] != "session-001" {
+			t.Errorf("voided sessions = %v, want [session-001]", rec.voidedSessions)
+		}
+	})
+
+	t.Run("failed release is reported", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := reservationDeps(rec)
+		deps.ListRevenues = func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			return &revenuepb.ListRevenuesResponse{Success: true, Data: []*revenuepb.Revenue{pendingOrder("rev-001", time.Hour)}}, nil
+		}
+		deps.ListLineItems = func(_ context.Context, _ *lineItempb.ListRevenueLineItemsRequest) (*lineItempb.ListRevenueLineItemsResponse, error) {
+			return nil, fmt.Errorf("line item store down")
+		}
+
+		result, err := NewService(deps).ReleaseExpiredReservations(context.Background(), time.Minute)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(result.Failed) != 1 || result.Failed[0] != "rev-001" {
+			t.Errorf("Failed = %v, want [rev-001]", result.Failed)
+		}
+	})
+
+	t.Run("list error propagates", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.ListRevenues = func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			return nil, fmt.Errorf("db down")
+		}
+		if _, err := NewService(deps).ReleaseExpiredReservations(context.Background(), time.Minute); err == nil {
+			t.Fatal("expected error when ListRevenues fails")
+		}
+	})
+}
@@ -0,0 +1,632 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// Order lifecycle errors returned by GetOrder, CancelOrder and ReturnOrder.
+var (
+	ErrOrderNotFound       = shared.ErrOrderNotFound
+	ErrOrderNotCancellable = errors.New("order cannot be cancelled")
+	ErrOrderNotReturnable  = errors.New("order cannot be returned")
+	ErrInvalidReturn       = errors.New("invalid return")
+)
+
+// Revenue statuses set by ReturnOrder.
+const (
+	OrderStatusPartiallyReturned = shared.RevenueStatusPartiallyReturned
+	OrderStatusReturned          = shared.RevenueStatusReturned
+)
+
+// returnLineItemType marks the negative line items ReturnOrder writes. Their
+// Notes carry "return_of=<line item ID>; reason=<reason>".
+const returnLineItemType = "return"
+
+// serialReferenceReturn is the serial_history reference type written when a
+// serial comes back from a customer.
+const serialReferenceReturn = "return"
+
+// ReturnLine is one item line being returned.
+type ReturnLine struct {
+	LineItemID string // the order's original "item" line
+	Quantity   int
+	// SerialIDs names the serials coming back. When empty, the order's
+	// serials for the line are taken in ID order.
+	SerialIDs []string
+	// Inspect holds the goods for inspection instead of putting them back on
+	// sale: serials move to "returned" and the quantity is counted on hand but
+	// not available.
+	Inspect bool
+}
+
+// RefundResult reports the money given back for a cancellation or return.
+type RefundResult struct {
+	Amount   int // centavos
+	RefundID string
+	Status   paymentpb.PaymentStatus
+	// Manual is true when the order's provider cannot refund (cash on
+	// delivery, bank transfer, no provider). Staff must pay Amount back
+	// outside the system.
+	Manual bool
+}
+
+// CancelResult holds the result of CancelOrder.
+type CancelResult struct {
+	RevenueID       string
+	ReferenceNumber string
+	Status          string        // "cancelled"
+	Refund          *RefundResult // nil when the order was not paid
+}
+
+// ReturnResult holds the result of ReturnOrder.
+type ReturnResult struct {
+	RevenueID       string
+	ReferenceNumber string
+	Status          string // OrderStatusPartiallyReturned or OrderStatusReturned
+	// ReturnLineItemIDs are the negative adjustment lines written, in the
+	// order of the request.
+	ReturnLineItemIDs []string
+	Refund            *RefundResult
+}
+
+// orderLocks serializes CancelOrder and ReturnOrder per revenue within this
+// process, so two returns of the same order cannot both pass the
+// quantity check.
+var orderLocks sync.Map
+
+func lockOrder(revenueID string) func() {
+	lock, _ := orderLocks.LoadOrStore(revenueID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// stockHandedOver reports whether the order's goods have left the store. Until
+// then its stock is still reserved and a return simply releases it.
+func stockHandedOver(rev *revenuepb.Revenue) bool {
+	return rev.GetStatus() == "complete" || rev.GetFulfillmentStatus() == shared.FulfillmentDelivered
+}
+
+// CancelOrder cancels an order before its goods are handed over.
+//
+// A pending order has its checkout session voided. A paid order is refunded
+// in full through its payment provider first; if the refund fails nothing is
+// changed. Either way the revenue is cancelled and its reserved stock,
+// serials and promotion redemptions are released. Orders that were handed
+// over, partially returned or already cancelled return ErrOrderNotCancellable
+// — use ReturnOrder for goods the customer has.
+func (s *Service) CancelOrder(ctx context.Context, referenceNumber, reason string) (*CancelResult, error) {
+	rev, err := s.findOrder(ctx, referenceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: cancel: %w", err)
+	}
+	unlock := lockOrder(rev.GetId())
+	defer unlock()
+	if rev, err = s.rereadOrder(ctx, rev); err != nil {
+		return nil, fmt.Errorf("checkout: cancel: %w", err)
+	}
+	if reason == "" {
+		reason = "cancelled by customer"
+	}
+
+	result := &CancelResult{
+		RevenueID:       rev.GetId(),
+		ReferenceNumber: rev.GetReferenceNumber(),
+		Status:          "cancelled",
+	}
+
+	switch {
+	case rev.GetStatus() == "pending":
+		if err := s.cancelPendingOrder(ctx, rev, reason); err != nil {
+			return nil, fmt.Errorf("checkout: cancel %s: %w", referenceNumber, err)
+		}
+		return result, nil
+
+	case rev.GetStatus() == "paid" && !stockHandedOver(rev):
+		refund, err := s.refundPayment(ctx, rev, amountPaid(rev), reason)
+		if err != nil {
+			return nil, &StepError{Step: StepRefundPayment, Err: err}
+		}
+		result.Refund = refund
+		if err := s.cancelRevenue(ctx, rev.GetId()); err != nil {
+			return result, fmt.Errorf("checkout: cancel %s after refund: %w", referenceNumber, err)
+		}
+		if err := s.releaseReservations(ctx, rev.GetId(), reason); err != nil {
+			return result, fmt.Errorf("checkout: cancel %s after refund: %w", referenceNumber, err)
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("checkout: cancel %s: %w (status %q)", referenceNumber, ErrOrderNotCancellable, rev.GetStatus())
+	}
+}
+
+// ReturnOrder takes back some or all of a paid order's item quantities.
+//
+// Each returned quantity is written as a negative "return" line item against
+// the original line (the revenue adjustment), its stock goes back — released
+// from the reservation when the order was not yet handed over, restocked
+// otherwise — and its serials move to available, or to "returned" when
+// ReturnLine.Inspect is set. The refund is the returned lines' share of the
+// amount paid, net of discounts; the return that brings every line back also
+// refunds the remainder (shipping, rounding). The refund runs last: when the
+// provider rejects it, every earlier step is compensated and a *StepError is
+// returned.
+//
+// The revenue ends in OrderStatusPartiallyReturned, or OrderStatusReturned
+// once every item quantity is back (its promotion redemptions are then given
+// back too). The status change goes through the revenue status machine: it
+// is checked before anything is written, so a workspace guard can refuse the
+// return, and stored through Apply so the workspace hooks run.
+func (s *Service) ReturnOrder(ctx context.Context, referenceNumber string, lines []ReturnLine, reason string) (*ReturnResult, error) {
+	if s.deps.ListLineItems == nil || s.deps.CreateLineItem == nil {
+		return nil, fmt.Errorf("checkout: return: line items not configured")
+	}
+	rev, err := s.findOrder(ctx, referenceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("checkout: return: %w", err)
+	}
+	unlock := lockOrder(rev.GetId())
+	defer unlock()
+	if rev, err = s.rereadOrder(ctx, rev); err != nil {
+		return nil, fmt.Errorf("checkout: return: %w", err)
+	}
+	switch rev.GetStatus() {
+	case "paid", "complete", OrderStatusPartiallyReturned:
+	default:
+		return nil, fmt.Errorf("checkout: return %s: %w (status %q)", referenceNumber, ErrOrderNotReturnable, rev.GetStatus())
+	}
+	if reason == "" {
+		reason = "returned by customer"
+	}
+
+	revenueID := rev.GetId()
+	lineResp, err := s.deps.ListLineItems(ctx, &lineItempb.ListRevenueLineItemsRequest{RevenueId: &revenueID})
+	if err != nil {
+		return nil, fmt.Errorf("checkout: return %s: list line items: %w", referenceNumber, err)
+	}
+	book := newReturnBook(lineResp.GetData())
+	if err := book.validate(lines); err != nil {
+		return nil, fmt.Errorf("checkout: return %s: %w", referenceNumber, err)
+	}
+	refunds := book.refunds(lines, amountPaid(rev))
+
+	transition := shared.RevenueTransition{RevenueID: revenueID, From: rev.GetStatus(), To: OrderStatusPartiallyReturned, Revenue: rev}
+	if book.fullyReturned(lines) {
+		transition.To = OrderStatusReturned
+	}
+	if s.deps.UpdateRevenue != nil {
+		if err := s.statusMachine().Check(ctx, transition); err != nil {
+			return nil, fmt.Errorf("checkout: return %s: %w", referenceNumber, err)
+		}
+	}
+
+	sg := &saga{}
+	now := time.Now()
+	nowMillis := now.UnixMilli()
+	nowStr := now.Format(time.RFC3339)
+	handedOver := stockHandedOver(rev)
+	result := &ReturnResult{RevenueID: revenueID, ReferenceNumber: rev.GetReferenceNumber()}
+
+	// 1. Write the negative adjustment lines
+	for i, rl := range lines {
+		orig := book.items[rl.LineItemID]
+		resp, err := s.deps.CreateLineItem(ctx, &lineItempb.CreateRevenueLineItemRequest{
+			Data: &lineItempb.RevenueLineItem{
+				Active:             true,
+				RevenueId:          revenueID,
+				ProductId:          orig.ProductId,
+				Description:        "Return: " + orig.GetDescription(),
+				Quantity:           -float64(rl.Quantity),
+				UnitPrice:          orig.GetUnitPrice(),
+				TotalPrice:         -int64(refunds[i]),
+				LineItemType:       returnLineItemType,
+				InventoryItemId:    orig.GetInventoryItemId(),
+				LocationId:         orig.LocationId,
+				VariantId:          orig.VariantId,
+				VariantLabel:       orig.VariantLabel,
+				Notes:              ptr(fmt.Sprintf("return_of=%s; reason=%s", rl.LineItemID, reason)),
+				DateCreated:        &nowMillis,
+				DateCreatedString:  &nowStr,
+				DateModified:       &nowMillis,
+				DateModifiedString: &nowStr,
+			},
+		})
+		if err != nil {
+			return nil, sg.fail(ctx, StepCreateReturnLines, fmt.Errorf("line %s: %w", rl.LineItemID, err))
+		}
+		if !resp.GetSuccess() {
+			return nil, sg.fail(ctx, StepCreateReturnLines, fmt.Errorf("line %s: unsuccessful response", rl.LineItemID))
+		}
+		var id string
+		if len(resp.GetData()) > 0 {
+			id = resp.GetData()[0].GetId()
+			sg.record(StepCreateReturnLines, func(ctx context.Context) error {
+				return s.deleteLineItem(ctx, id)
+			})
+		}
+		result.ReturnLineItemIDs = append(result.ReturnLineItemIDs, id)
+	}
+
+	// 2. Put the stock back
+	for _, rl := range lines {
+		if err := s.returnStock(ctx, book.items[rl.LineItemID], rl, handedOver, sg); err != nil {
+			return nil, sg.fail(ctx, StepRestockInventory, err)
+		}
+	}
+
+	// 3. Move the serials back
+	if err := s.returnSerials(ctx, revenueID, book, lines, handedOver, reason, sg); err != nil {
+		return nil, sg.fail(ctx, StepReturnSerials, err)
+	}
+
+	// 4. Refund through the payment provider
+	total := 0
+	for _, r := range refunds {
+		total += r
+	}
+	refund, err := s.refundPayment(ctx, rev, total, reason)
+	if err != nil {
+		return nil, sg.fail(ctx, StepRefundPayment, err)
+	}
+	result.Refund = refund
+
+	// 5. Reflect the return on the revenue status
+	result.Status = transition.To
+	if result.Status == OrderStatusReturned && s.deps.Promotions != nil {
+		if err := s.deps.Promotions.ReleaseRevenue(ctx, revenueID); err != nil {
+			log.Printf("checkout: return %s: release promotions: %v", revenueID, err)
+		}
+	}
+	if s.deps.UpdateRevenue != nil {
+		err := s.statusMachine().Apply(ctx, transition, func(ctx context.Context, t shared.RevenueTransition) error {
+			_, err := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+				Data: &revenuepb.Revenue{
+					Id:                 t.RevenueID,
+					Status:             t.To,
+					DateModified:       &nowMillis,
+					DateModifiedString: &nowStr,
+				},
+			})
+			return err
+		})
+		if err != nil {
+			log.Printf("checkout: return %s: update revenue status: %v", revenueID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// rereadOrder refreshes rev after the order lock is taken, so the status
+// checked is not one a concurrent cancel or return has since changed. Without
+// ReadRevenue the listed record is used as is.
+func (s *Service) rereadOrder(ctx context.Context, rev *revenuepb.Revenue) (*revenuepb.Revenue, error) {
+	if s.deps.ReadRevenue == nil {
+		return rev, nil
+	}
+	resp, err := s.deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{Data: &revenuepb.Revenue{Id: rev.GetId()}})
+	if err != nil {
+		return nil, fmt.Errorf("read revenue %s: %w", rev.GetId(), err)
+	}
+	if !resp.GetSuccess() || len(resp.GetData()) == 0 {
+		return rev, nil
+	}
+	return resp.GetData()[0], nil
+}
+
+// amountPaid is what the customer was charged: the cash amount expected when
+// tax was computed (total less withholding), the revenue total otherwise.
+func amountPaid(rev *revenuepb.Revenue) int {
+	if rev.CashAmountExpected != nil {
+		return int(rev.GetCashAmountExpected())
+	}
+	return int(rev.GetTotalAmount())
+}
+
+// refundPayment asks the order's payment provider to refund amount. Providers
+// that cannot refund — and orders without a provider — yield a manual refund
+// instead of an error.
+func (s *Service) refundPayment(ctx context.Context, rev *revenuepb.Revenue, amount int, reason string) (*RefundResult, error) {
+	result := &RefundResult{Amount: amount}
+	if amount <= 0 {
+		return result, nil
+	}
+	var provider PaymentProvider
+	if rev.GetPaymentProvider() != "" {
+		p, err := s.paymentProvider(rev.GetPaymentProvider())
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	}
+	if provider == nil {
+		result.Manual = true
+		return result, nil
+	}
+
+	resp, err := provider.Refund(ctx, &paymentpb.RefundPaymentRequest{
+		Data: &paymentpb.RefundData{
+			ProviderId:    rev.GetPaymentProvider(),
+			TransactionId: rev.GetCheckoutSessionId(),
+			ProviderRef:   rev.GetReferenceNumber(),
+			Amount:        int64(amount),
+			Reason:        reason,
+		},
+	})
+	if errors.Is(err, ErrPaymentNotSupported) {
+		result.Manual = true
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("refund %s: %w", rev.GetReferenceNumber(), err)
+	}
+	if !resp.GetSuccess() || len(resp.GetData()) == 0 {
+		errMsg := "unknown error"
+		if resp.GetError() != nil {
+			errMsg = resp.GetError().GetMessage()
+		}
+		return nil, fmt.Errorf("refund %s: %s", rev.GetReferenceNumber(), errMsg)
+	}
+	data := resp.GetData()[0]
+	result.RefundID = data.GetRefundId()
+	result.Status = data.GetStatus()
+	return result, nil
+}
+
+// returnStock puts a returned quantity back in inventory. Before handover the
+// quantity is still reserved and is released to available; after handover it
+// is restocked on hand (and available unless held for inspection). Lines
+// without an inventory record are not stock-tracked and are skipped.
+func (s *Service) returnStock(ctx context.Context, orig *lineItempb.RevenueLineItem, rl ReturnLine, handedOver bool, sg *saga) error {
+	if s.deps.ListInventoryItems == nil || s.deps.UpdateInventoryItem == nil {
+		return nil
+	}
+	item := CheckoutItem{
+		ProductID:       orig.GetProductId(),
+		LocationID:      orig.GetLocationId(),
+		Quantity:        rl.Quantity,
+		inventoryItemID: orig.GetInventoryItemId(),
+	}
+	invItem, err := s.findInventoryItem(ctx, item)
+	if err != nil {
+		return err
+	}
+	if invItem == nil {
+		return nil
+	}
+
+	if !handedOver {
+		if err := s.adjustReservation(ctx, invItem, -float64(rl.Quantity)); err != nil {
+			return fmt.Errorf("release inventory for product %s: %w", item.ProductID, err)
+		}
+		sg.record(StepRestockInventory, func(ctx context.Context) error {
+			return s.adjustReservation(ctx, invItem, float64(rl.Quantity))
+		})
+		return nil
+	}
+
+	adjust := shared.InventoryAdjuster(s.deps.AdjustInventoryQuantity, s.deps.ReadInventoryItem, s.deps.UpdateInventoryItem)
+	if adjust == nil {
+		return fmt.Errorf("restock product %s: inventory adjustment not configured", item.ProductID)
+	}
+	delta := shared.InventoryDelta{InventoryItemID: invItem.GetId(), OnHand: float64(rl.Quantity)}
+	if !rl.Inspect {
+		delta.Available = float64(rl.Quantity)
+	}
+	if _, err := adjust(ctx, delta); err != nil {
+		return fmt.Errorf("restock product %s: %w", item.ProductID, err)
+	}
+	sg.record(StepRestockInventory, func(ctx context.Context) error {
+		_, err := adjust(ctx, shared.InventoryDelta{
+			InventoryItemID: delta.InventoryItemID,
+			OnHand:          -delta.OnHand,
+			Available:       -delta.Available,
+		})
+		return err
+	})
+	return nil
+}
+
+// returnSerials moves the serials of the returned lines back: reserved
+// serials (not yet handed over) and sold ones go to available, or to
+// "returned" for lines held for inspection. Needs ListSerialHistory to find
+// the order's serials; without it serials are left as they are.
+func (s *Service) returnSerials(ctx context.Context, revenueID string, book *returnBook, lines []ReturnLine, handedOver bool, reason string, sg *saga) error {
+	if s.deps.ListSerials == nil || s.deps.UpdateSerial == nil || s.deps.ListSerialHistory == nil {
+		return nil
+	}
+	owned, err := s.orderSerials(ctx, revenueID)
+	if err != nil {
+		return err
+	}
+	from := "reserved"
+	if handedOver {
+		from = "sold"
+	}
+
+	for _, rl := range lines {
+		invItemID := book.items[rl.LineItemID].GetInventoryItemId()
+		if invItemID == "" {
+			continue
+		}
+		serialResp, err := s.deps.ListSerials(ctx, &serialpb.ListInventorySerialsRequest{InventoryItemId: &invItemID})
+		if err != nil {
+			return fmt.Errorf("list serials for %s: %w", invItemID, err)
+		}
+		var candidates []*serialpb.InventorySerial
+		for _, serial := range serialResp.GetData() {
+			if owned[serial.GetId()] == invItemID && serial.GetStatus() == from {
+				candidates = append(candidates, serial)
+			}
+		}
+		sort.Slice(candidates, func(a, b int) bool { return candidates[a].GetId() < candidates[b].GetId() })
+
+		chosen := candidates
+		if len(rl.SerialIDs) > 0 {
+			byID := map[string]*serialpb.InventorySerial{}
+			for _, serial := range candidates {
+				byID[serial.GetId()] = serial
+			}
+			chosen = nil
+			for _, id := range rl.SerialIDs {
+				serial, ok := byID[id]
+				if !ok {
+					return fmt.Errorf("%w: serial %s is not a %s serial of line %s", ErrInvalidReturn, id, from, rl.LineItemID)
+				}
+				chosen = append(chosen, serial)
+			}
+		}
+		if len(chosen) > rl.Quantity {
+			chosen = chosen[:rl.Quantity]
+		}
+
+		to := "available"
+		if rl.Inspect {
+			to = "returned"
+		}
+		for _, serial := range chosen {
+			if err := s.transitionSerial(ctx, serial, from, to, serialReferenceReturn, revenueID,
+				fmt.Sprintf("Returned from order %s: %s", revenueID, reason)); err != nil {
+				return err
+			}
+			sg.record(StepReturnSerials, func(ctx context.Context) error {
+				return s.transitionSerial(ctx, serial, to, from, serialReferenceReturn, revenueID,
+					fmt.Sprintf("Return to order %s rolled back", revenueID))
+			})
+		}
+	}
+	return nil
+}
+
+// returnBook indexes an order's line items for a return: the returnable item
+// lines, what has already come back per line, and the refund basis.
+type returnBook struct {
+	items    map[string]*lineItempb.RevenueLineItem
+	order    []string       // item line IDs in listing order
+	returned map[string]int // item line ID → quantity already returned
+	// itemsGross is the sum of the item lines; refunded the sum already given
+	// back by earlier returns.
+	itemsGross int
+	refunded   int
+	shipping   int
+}
+
+func newReturnBook(lineItems []*lineItempb.RevenueLineItem) *returnBook {
+	b := &returnBook{items: map[string]*lineItempb.RevenueLineItem{}, returned: map[string]int{}}
+	for _, li := range lineItems {
+		switch {
+		case li.GetLineItemType() == returnLineItemType:
+			if of := noteField(li.GetNotes(), "return_of"); of != "" {
+				b.returned[of] += int(math.Round(-li.GetQuantity()))
+			}
+			b.refunded -= int(li.GetTotalPrice())
+		case li.GetLineItemType() == "item" && li.GetProductId() != "":
+			b.items[li.GetId()] = li
+			b.order = append(b.order, li.GetId())
+			b.itemsGross += int(li.GetTotalPrice())
+		case li.GetLineItemType() == "item":
+			b.shipping += int(li.GetTotalPrice())
+		}
+	}
+	return b
+}
+
+// validate checks every line names an item line of the order and does not
+// take back more than is left on it, counting repeats within the request.
+func (b *returnBook) validate(lines []ReturnLine) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("%w: no lines", ErrInvalidReturn)
+	}
+	requested := map[string]int{}
+	for _, rl := range lines {
+		li, ok := b.items[rl.LineItemID]
+		if !ok {
+			return fmt.Errorf("%w: line %q is not an item line of this order", ErrInvalidReturn, rl.LineItemID)
+		}
+		if rl.Quantity <= 0 {
+			return fmt.Errorf("%w: line %s: quantity must be positive", ErrInvalidReturn, rl.LineItemID)
+		}
+		if len(rl.SerialIDs) > rl.Quantity {
+			return fmt.Errorf("%w: line %s: %d serials for quantity %d", ErrInvalidReturn, rl.LineItemID, len(rl.SerialIDs), rl.Quantity)
+		}
+		requested[rl.LineItemID] += rl.Quantity
+		remaining := int(math.Round(li.GetQuantity())) - b.returned[rl.LineItemID]
+		if requested[rl.LineItemID] > remaining {
+			return fmt.Errorf("%w: line %s: returning %d, only %d left", ErrInvalidReturn, rl.LineItemID, requested[rl.LineItemID], remaining)
+		}
+	}
+	return nil
+}
+
+// fullyReturned reports whether every item quantity is back once lines are
+// applied.
+func (b *returnBook) fullyReturned(lines []ReturnLine) bool {
+	requested := map[string]int{}
+	for _, rl := range lines {
+		requested[rl.LineItemID] += rl.Quantity
+	}
+	for _, id := range b.order {
+		if b.returned[id]+requested[id] < int(math.Round(b.items[id].GetQuantity())) {
+			return false
+		}
+	}
+	return true
+}
+
+// refunds returns the refund for each line in centavos: the line's share of
+// its total, scaled by what the items actually cost after discounts and tax
+// (paid less shipping over the item gross). When lines complete the return,
+// the last line also carries whatever is left of paid.
+func (b *returnBook) refunds(lines []ReturnLine, paid int) []int {
+	out := make([]int, len(lines))
+	itemsPaid := max(paid-b.shipping, 0)
+	total := 0
+	for i, rl := range lines {
+		li := b.items[rl.LineItemID]
+		share := roundDiv(int(li.GetTotalPrice())*rl.Quantity, max(int(math.Round(li.GetQuantity())), 1))
+		if b.itemsGross > 0 {
+			out[i] = roundDiv(share*itemsPaid, b.itemsGross)
+		}
+		total += out[i]
+	}
+	if len(lines) == 0 {
+		return out
+	}
+	last := len(lines) - 1
+	remaining := max(paid-b.refunded, 0)
+	switch {
+	case b.fullyReturned(lines):
+		out[last] += remaining - total
+	case total > remaining:
+		// Earlier rounding already gave back a centavo or two.
+		out[last] = max(out[last]-(total-remaining), 0)
+	}
+	return out
+}
+
+// noteField reads key from a "key=value; key=value" line item note.
+func noteField(notes, key string) string {
+	for _, part := range strings.Split(notes, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,522 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// orderStore is an in-memory backend holding one placed order: 2 × prod-001
+// (serials s-1, s-2) and 1 × prod-002, a 2500 discount and 500 shipping, paid
+// 23000 through the fake provider.
+type orderStore struct {
+	mu      sync.Mutex
+	status  string
+	lines   []*lineItempb.RevenueLineItem
+	deleted []string
+	// inventory per item ID: on hand, available, reserved
+	stock   map[string]*[3]float64
+	serials map[string]string
+	seq     int
+
+	fake    *FakePaymentProvider
+	session string
+
+	// fulfillment fields; updateErr fails every UpdateRevenue call.
+	fulfillmentType string
+	fulfillment     string
+	updateErr       error
+}
+
+func newOrderStore(t *testing.T, status string, handedOver bool) *orderStore {
+	t.Helper()
+
+	st := &orderStore{
+		status: status,
+		lines: []*lineItempb.RevenueLineItem{
+			{Id: "li-001", RevenueId: "rev-001", ProductId: ptr("prod-001"), LocationId: ptr("loc-001"), InventoryItemId: "inv-001",
+				Description: "Widget", Quantity: 2, UnitPrice: 10000, TotalPrice: 20000, LineItemType: "item"},
+			{Id: "li-002", RevenueId: "rev-001", ProductId: ptr("prod-002"), LocationId: ptr("loc-001"), InventoryItemId: "inv-002",
+				Description: "Gadget", Quantity: 1, UnitPrice: 5000, TotalPrice: 5000, LineItemType: "item"},
+			{Id: "li-ship", RevenueId: "rev-001", Description: "Shipping", Quantity: 1, UnitPrice: 500, TotalPrice: 500, LineItemType: "item"},
+			{Id: "li-disc", RevenueId: "rev-001", Description: "10% off", Quantity: 1, TotalPrice: -2500, LineItemType: "discount"},
+		},
+		stock:   map[string]*[3]float64{},
+		serials: map[string]string{},
+	}
+	if handedOver {
+		st.stock["inv-001"] = &[3]float64{8, 8, 0}
+		st.stock["inv-002"] = &[3]float64{4, 4, 0}
+		st.serials["s-1"], st.serials["s-2"] = "sold", "sold"
+	} else {
+		st.stock["inv-001"] = &[3]float64{10, 8, 2}
+		st.stock["inv-002"] = &[3]float64{5, 4, 1}
+		st.serials["s-1"], st.serials["s-2"] = "reserved", "reserved"
+	}
+
+	st.fake = NewFakePaymentProvider("fake")
+	resp, err := st.fake.CreateSession(context.Background(), &paymentpb.CreateCheckoutSessionRequest{
+		Data: &paymentpb.CheckoutSessionData{PaymentId: "rev-001", OrderRef: "ORD-test-0001", Amount: 23000},
+	})
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	st.session = resp.GetData()[0].GetId()
+	if status != "pending" {
+		if _, err := st.fake.Pay(context.Background(), st.session); err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+	}
+	return st
+}
+
+func (st *orderStore) revenue() *revenuepb.Revenue {
+	rev := &revenuepb.Revenue{
+		Id:                "rev-001",
+		ReferenceNumber:   ptr("ORD-test-0001"),
+		Status:            st.status,
+		TotalAmount:       23000,
+		PaymentProvider:   ptr("fake"),
+		CheckoutSessionId: ptr(st.session),
+	}
+	if st.fulfillmentType != "" {
+		rev.FulfillmentType = ptr(st.fulfillmentType)
+	}
+	if st.fulfillment != "" {
+		rev.FulfillmentStatus = ptr(st.fulfillment)
+	}
+	return rev
+}
+
+func (st *orderStore) deps() CheckoutDeps {
+	registry := NewPaymentRegistry()
+	registry.Register("fake", st.fake)
+	registry.Register("cod", OfflinePaymentProvider{})
+
+	return CheckoutDeps{
+		Payments: registry,
+		ListRevenues: func(_ context.Context, _ *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			return &revenuepb.ListRevenuesResponse{Success: true, Data: []*revenuepb.Revenue{st.revenue()}}, nil
+		},
+		ReadRevenue: func(_ context.Context, _ *revenuepb.ReadRevenueRequest) (*revenuepb.ReadRevenueResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			return &revenuepb.ReadRevenueResponse{Success: true, Data: []*revenuepb.Revenue{st.revenue()}}, nil
+		},
+		UpdateRevenue: func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			if st.updateErr != nil {
+				return nil, st.updateErr
+			}
+			if s := req.GetData().GetStatus(); s != "" {
+				st.status = s
+			}
+			if f := req.GetData().GetFulfillmentStatus(); f != "" {
+				st.fulfillment = f
+			}
+			return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+		},
+		ListLineItems: func(_ context.Context, _ *lineItempb.ListRevenueLineItemsRequest) (*lineItempb.ListRevenueLineItemsResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			return &lineItempb.ListRevenueLineItemsResponse{Success: true, Data: append([]*lineItempb.RevenueLineItem(nil), st.lines...)}, nil
+		},
+		CreateLineItem: func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			st.seq++
+			li := req.GetData()
+			li.Id = fmt.Sprintf("ret-%d", st.seq)
+			st.lines = append(st.lines, li)
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true, Data: []*lineItempb.RevenueLineItem{li}}, nil
+		},
+		DeleteLineItem: func(_ context.Context, req *lineItempb.DeleteRevenueLineItemRequest) (*lineItempb.DeleteRevenueLineItemResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			st.deleted = append(st.deleted, req.GetData().GetId())
+			for i, li := range st.lines {
+				if li.GetId() == req.GetData().GetId() {
+					st.lines = append(st.lines[:i], st.lines[i+1:]...)
+					break
+				}
+			}
+			return &lineItempb.DeleteRevenueLineItemResponse{Success: true}, nil
+		},
+		ListInventoryItems: func(_ context.Context, req *inventoryItempb.ListInventoryItemsRequest) (*inventoryItempb.ListInventoryItemsResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			id := map[string]string{"prod-001": "inv-001", "prod-002": "inv-002"}[req.GetProductId()]
+			q := st.stock[id]
+			if q == nil {
+				return &inventoryItempb.ListInventoryItemsResponse{Success: true}, nil
+			}
+			return &inventoryItempb.ListInventoryItemsResponse{Success: true, Data: []*inventoryItempb.InventoryItem{{
+				Id: id, ProductId: ptr(req.GetProductId()), LocationId: ptr("loc-001"),
+				QuantityOnHand: q[0], QuantityAvailable: q[1], QuantityReserved: q[2], Active: true,
+			}}}, nil
+		},
+		UpdateInventoryItem: func(_ context.Context, _ *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+			return nil, errors.New("use AdjustInventoryQuantity")
+		},
+		AdjustInventoryQuantity: func(_ context.Context, delta shared.InventoryDelta) (*inventoryItempb.InventoryItem, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			q := st.stock[delta.InventoryItemID]
+			q[0] += delta.OnHand
+			q[1] += delta.Available
+			q[2] += delta.Reserved
+			return &inventoryItempb.InventoryItem{Id: delta.InventoryItemID}, nil
+		},
+		ListSerials: func(_ context.Context, req *serialpb.ListInventorySerialsRequest) (*serialpb.ListInventorySerialsResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			if req.GetInventoryItemId() != "inv-001" {
+				return &serialpb.ListInventorySerialsResponse{Success: true}, nil
+			}
+			var data []*serialpb.InventorySerial
+			for _, id := range []string{"s-1", "s-2"} {
+				data = append(data, &serialpb.InventorySerial{Id: id, InventoryItemId: "inv-001", Status: st.serials[id], Active: true})
+			}
+			return &serialpb.ListInventorySerialsResponse{Success: true, Data: data}, nil
+		},
+		UpdateSerial: func(_ context.Context, req *serialpb.UpdateInventorySerialRequest) (*serialpb.UpdateInventorySerialResponse, error) {
+			st.mu.Lock()
+			defer st.mu.Unlock()
+			st.serials[req.GetData().GetId()] = req.GetData().GetStatus()
+			return &serialpb.UpdateInventorySerialResponse{Success: true}, nil
+		},
+		ListSerialHistory: func(_ context.Context, _ *serialHistorypb.ListInventorySerialHistoryRequest) (*serialHistorypb.ListInventorySerialHistoryResponse, error) {
+			return &serialHistorypb.ListInventorySerialHistoryResponse{Success: true, Data: []*serialHistorypb.InventorySerialHistory{
+				{InventorySerialId: "s-1", InventoryItemId: "inv-001", ToStatus: "reserved", ReferenceId: "rev-001"},
+				{InventorySerialId: "s-2", InventoryItemId: "inv-001", ToStatus: "reserved", ReferenceId: "rev-001"},
+			}}, nil
+		},
+	}
+}
+
+func (st *orderStore) snapshot() (status string, stock map[string][3]float64, serials map[string]string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stock = map[string][3]float64{}
+	for id, q := range st.stock {
+		stock[id] = *q
+	}
+	serials = map[string]string{}
+	for id, s := range st.serials {
+		serials[id] = s
+	}
+	return st.status, stock, serials
+}
+
+func (st *orderStore) fakeStatus(t *testing.T) paymentpb.PaymentStatus {
+	t.Helper()
+	resp, err := st.fake.Status(context.Background(), &paymentpb.GetPaymentStatusRequest{Data: &paymentpb.PaymentStatusLookup{ProviderRef: st.session}})
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	return resp.GetData()[0].GetStatus()
+}
+
+// ---------------------------------------------------------------------------
+// CancelOrder
+// ---------------------------------------------------------------------------
+
+func TestCancelOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("pending order voids the session and releases stock", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "pending", false)
+		result, err := NewService(st.deps()).CancelOrder(context.Background(), "ORD-test-0001", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Refund != nil {
+			t.Errorf("Refund = %+v, want nil for an unpaid order", result.Refund)
+		}
+		status, stock, serials := st.snapshot()
+		if status != "cancelled" || stock["inv-001"] != [3]float64{10, 10, 0} || stock["inv-002"] != [3]float64{5, 5, 0} {
+			t.Errorf("status %q, stock %v; want cancelled and reservations released", status, stock)
+		}
+		if serials["s-1"] != "available" || serials["s-2"] != "available" {
+			t.Errorf("serials = %v, want available", serials)
+		}
+		if got := st.fakeStatus(t); got != paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED {
+			t.Errorf("session status = %v, want CANCELLED", got)
+		}
+	})
+
+	t.Run("paid order is refunded in full", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		result, err := NewService(st.deps()).CancelOrder(context.Background(), "ORD-test-0001", "changed my mind")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Refund == nil || result.Refund.Amount != 23000 || result.Refund.Manual || result.Refund.RefundID == "" {
+			t.Errorf("Refund = %+v, want 23000 through the provider", result.Refund)
+		}
+		status, stock, _ := st.snapshot()
+		if status != "cancelled" || stock["inv-001"] != [3]float64{10, 10, 0} {
+			t.Errorf("status %q, stock %v", status, stock)
+		}
+		if got := st.fakeStatus(t); got != paymentpb.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+			t.Errorf("payment status = %v, want REFUNDED", got)
+		}
+	})
+
+	t.Run("failed refund leaves the order untouched", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		st.session = "unknown-session"
+		_, err := NewService(st.deps()).CancelOrder(context.Background(), "ORD-test-0001", "")
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepRefundPayment {
+			t.Fatalf("expected StepError for %s, got %v", StepRefundPayment, err)
+		}
+		status, stock, _ := st.snapshot()
+		if status != "paid" || stock["inv-001"] != [3]float64{10, 8, 2} {
+			t.Errorf("status %q, stock %v; want unchanged", status, stock)
+		}
+	})
+
+	for _, status := range []string{"complete", "cancelled", OrderStatusPartiallyReturned} {
+		t.Run(status+" order cannot be cancelled", func(t *testing.T) {
+			t.Parallel()
+
+			st := newOrderStore(t, status, status == "complete")
+			if _, err := NewService(st.deps()).CancelOrder(context.Background(), "ORD-test-0001", ""); !errors.Is(err, ErrOrderNotCancellable) {
+				t.Errorf("expected ErrOrderNotCancellable, got %v", err)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ReturnOrder
+// ---------------------------------------------------------------------------
+
+func TestReturnOrder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("partial then full return after handover", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "complete", true)
+		svc := NewService(st.deps())
+
+		first, err := svc.ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-001", Quantity: 1}}, "defective")
+		if err != nil {
+			t.Fatalf("first return: %v", err)
+		}
+		// 10000 of 25000 item gross, scaled to the 22500 paid for items.
+		if first.Status != OrderStatusPartiallyReturned || first.Refund.Amount != 9000 {
+			t.Errorf("first return = %s / %d, want partially_returned / 9000", first.Status, first.Refund.Amount)
+		}
+		status, stock, serials := st.snapshot()
+		if status != OrderStatusPartiallyReturned || stock["inv-001"] != [3]float64{9, 9, 0} {
+			t.Errorf("status %q, stock %v", status, stock)
+		}
+		if serials["s-1"] != "available" || serials["s-2"] != "sold" {
+			t.Errorf("serials = %v, want s-1 back on sale", serials)
+		}
+
+		second, err := svc.ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{
+			{LineItemID: "li-001", Quantity: 1},
+			{LineItemID: "li-002", Quantity: 1},
+		}, "")
+		if err != nil {
+			t.Fatalf("second return: %v", err)
+		}
+		if second.Status != OrderStatusReturned || first.Refund.Amount+second.Refund.Amount != 23000 {
+			t.Errorf("second return = %s / %d; want returned and 23000 refunded in total", second.Status, second.Refund.Amount)
+		}
+		if got := st.fakeStatus(t); got != paymentpb.PaymentStatus_PAYMENT_STATUS_REFUNDED {
+			t.Errorf("payment status = %v, want REFUNDED", got)
+		}
+
+		st.mu.Lock()
+		var refunded int64
+		for _, li := range st.lines {
+			if li.GetLineItemType() == returnLineItemType {
+				refunded -= li.GetTotalPrice()
+				if li.GetQuantity() >= 0 || noteField(li.GetNotes(), "return_of") == "" {
+					t.Errorf("return line %+v", li)
+				}
+			}
+		}
+		st.mu.Unlock()
+		if refunded != 23000 {
+			t.Errorf("return lines total %d, want -23000", -refunded)
+		}
+	})
+
+	t.Run("inspection holds stock and serials back from sale", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "complete", true)
+		_, err := NewService(st.deps()).ReturnOrder(context.Background(), "ORD-test-0001",
+			[]ReturnLine{{LineItemID: "li-001", Quantity: 1, SerialIDs: []string{"s-2"}, Inspect: true}}, "damaged box")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, stock, serials := st.snapshot()
+		if stock["inv-001"] != [3]float64{9, 8, 0} {
+			t.Errorf("stock = %v, want on hand +1 and available unchanged", stock["inv-001"])
+		}
+		if serials["s-1"] != "sold" || serials["s-2"] != "returned" {
+			t.Errorf("serials = %v, want s-2 returned", serials)
+		}
+	})
+
+	t.Run("return before handover releases the reservation", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "paid", false)
+		if _, err := NewService(st.deps()).ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-001", Quantity: 1}}, ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, stock, serials := st.snapshot()
+		if stock["inv-001"] != [3]float64{10, 9, 1} {
+			t.Errorf("stock = %v, want one unit released", stock["inv-001"])
+		}
+		if serials["s-1"] != "available" || serials["s-2"] != "reserved" {
+			t.Errorf("serials = %v", serials)
+		}
+	})
+
+	t.Run("offline payment is refunded manually", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "complete", true)
+		deps := st.deps()
+		listRevenues := deps.ListRevenues
+		deps.ListRevenues = func(ctx context.Context, req *revenuepb.ListRevenuesRequest) (*revenuepb.ListRevenuesResponse, error) {
+			resp, err := listRevenues(ctx, req)
+			resp.GetData()[0].PaymentProvider = ptr("cod")
+			return resp, err
+		}
+		deps.ReadRevenue = nil
+
+		result, err := NewService(deps).ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-002", Quantity: 1}}, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Refund.Manual || result.Refund.Amount != 4500 {
+			t.Errorf("Refund = %+v, want manual 4500", result.Refund)
+		}
+	})
+
+	t.Run("invalid returns are rejected before any write", func(t *testing.T) {
+		t.Parallel()
+
+		for name, lines := range map[string][]ReturnLine{
+			"too many":          {{LineItemID: "li-001", Quantity: 3}},
+			"repeated line":     {{LineItemID: "li-001", Quantity: 2}, {LineItemID: "li-001", Quantity: 1}},
+			"shipping line":     {{LineItemID: "li-ship", Quantity: 1}},
+			"unknown line":      {{LineItemID: "li-999", Quantity: 1}},
+			"zero quantity":     {{LineItemID: "li-001"}},
+			"more serials":      {{LineItemID: "li-001", Quantity: 1, SerialIDs: []string{"s-1", "s-2"}}},
+			"nothing to return": nil,
+		} {
+			st := newOrderStore(t, "complete", true)
+			_, err := NewService(st.deps()).ReturnOrder(context.Background(), "ORD-test-0001", lines, "")
+			if !errors.Is(err, ErrInvalidReturn) {
+				t.Errorf("%s: expected ErrInvalidReturn, got %v", name, err)
+			}
+			if status, _, _ := st.snapshot(); status != "complete" || len(st.lines) != 4 {
+				t.Errorf("%s: order changed", name)
+			}
+		}
+	})
+
+	t.Run("pending order cannot be returned", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "pending", false)
+		if _, err := NewService(st.deps()).ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-001", Quantity: 1}}, ""); !errors.Is(err, ErrOrderNotReturnable) {
+			t.Errorf("expected ErrOrderNotReturnable, got %v", err)
+		}
+	})
+
+	t.Run("status goes through the machine's guards and hooks", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "complete", true)
+		machine := shared.NewRevenueStatusMachine()
+		var hooked []string
+		machine.AddHook("audit", shared.AnyRevenueStatus, shared.AnyRevenueStatus, func(_ context.Context, tr shared.RevenueTransition) error {
+			hooked = append(hooked, tr.From+" -> "+tr.To)
+			return nil
+		})
+		veto := errors.New("returns are closed")
+		machine.AddGuard("closed", shared.AnyRevenueStatus, shared.RevenueStatusReturned, func(context.Context, shared.RevenueTransition) error {
+			return veto
+		})
+		deps := st.deps()
+		deps.StatusMachine = machine
+		svc := NewService(deps)
+
+		if _, err := svc.ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-001", Quantity: 1}}, ""); err != nil {
+			t.Fatalf("partial return: %v", err)
+		}
+		if fmt.Sprint(hooked) != "[complete -> partially_returned]" {
+			t.Errorf("hooks ran for %v, want the partial return", hooked)
+		}
+
+		_, err := svc.ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{
+			{LineItemID: "li-001", Quantity: 1},
+			{LineItemID: "li-002", Quantity: 1},
+		}, "")
+		if !errors.Is(err, veto) {
+			t.Fatalf("full return: expected the guard's error, got %v", err)
+		}
+		status, stock, _ := st.snapshot()
+		if status != OrderStatusPartiallyReturned || stock["inv-002"] != [3]float64{4, 4, 0} || len(st.lines) != 5 {
+			t.Errorf("status %q, stock %v, %d lines; want the vetoed return unwritten", status, stock, len(st.lines))
+		}
+	})
+
+	t.Run("refund failure rolls the return back", func(t *testing.T) {
+		t.Parallel()
+
+		st := newOrderStore(t, "complete", true)
+		st.session = "unknown-session"
+		_, err := NewService(st.deps()).ReturnOrder(context.Background(), "ORD-test-0001", []ReturnLine{{LineItemID: "li-001", Quantity: 1}}, "")
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepRefundPayment {
+			t.Fatalf("expected StepError for %s, got %v", StepRefundPayment, err)
+		}
+		if len(stepErr.CompensationErrors) > 0 {
+			t.Errorf("compensation errors: %v", stepErr.CompensationErrors)
+		}
+		status, stock, serials := st.snapshot()
+		if status != "complete" || stock["inv-001"] != [3]float64{8, 8, 0} || serials["s-1"] != "sold" {
+			t.Errorf("status %q, stock %v, serials %v; want unchanged", status, stock, serials)
+		}
+		if len(st.deleted) != 1 || len(st.lines) != 4 {
+			t.Errorf("deleted %v, %d lines left; want the return line removed", st.deleted, len(st.lines))
+		}
+	})
+}
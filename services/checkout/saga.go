@@ -0,0 +1,112 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// PlaceOrder saga steps. StepError.Step carries one of these so callers can
+// tell which part of the checkout failed.
+const (
+	StepVerifyPrices          = "verify_prices"
+	StepAllocateStock         = "allocate_stock"
+	StepApplyPromotions       = "apply_promotions"
+	StepComputeTax            = "compute_tax"
+	StepNumberOrder           = "number_order"
+	StepCreateRevenue         = "create_revenue"
+	StepRedeemPromotions      = "redeem_promotions"
+	StepCreateLineItem        = "create_line_item"
+	StepCreateTaxLines        = "create_tax_lines"
+	StepReserveStock          = "reserve_stock"
+	StepReserveSerials        = "reserve_serials"
+	StepCreateCheckoutSession = "create_checkout_session"
+)
+
+// CancelOrder and ReturnOrder steps.
+const (
+	StepCreateReturnLines = "create_return_lines"
+	StepRestockInventory  = "restock_inventory"
+	StepReturnSerials     = "return_serials"
+	StepRefundPayment     = "refund_payment"
+)
+
+// AdvanceFulfillment steps.
+const (
+	StepHandOverStock     = "hand_over_stock"
+	StepSellSerials       = "sell_serials"
+	StepUpdateFulfillment = "update_fulfillment"
+)
+
+// StepError is returned by PlaceOrder when a saga step fails. By the time the
+// caller sees it, every step that completed before Step has been compensated.
+// Compensation failures are logged and collected in CompensationErrors — a
+// non-empty slice means the rollback was partial and needs manual attention.
+type StepError struct {
+	Step               string
+	Err                error
+	CompensationErrors []error
+}
+
+func (e *StepError) Error() string {
+	if len(e.CompensationErrors) > 0 {
+		return fmt.Sprintf("checkout: %s: %v (%d compensation(s) failed)", e.Step, e.Err, len(e.CompensationErrors))
+	}
+	return fmt.Sprintf("checkout: %s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// compensation is a single undo action recorded by a completed saga step.
+type compensation struct {
+	step string
+	undo func(ctx context.Context) error
+}
+
+// saga records undo actions as PlaceOrder steps complete and replays them in
+// reverse order when a later step fails. A nil *saga is valid and records
+// nothing, which lets the step helpers run outside of PlaceOrder.
+type saga struct {
+	compensations []compensation
+}
+
+// record registers the undo action for a completed step.
+func (sg *saga) record(step string, undo func(ctx context.Context) error) {
+	if sg == nil {
+		return
+	}
+	sg.compensations = append(sg.compensations, compensation{step: step, undo: undo})
+}
+
+// fail unwinds every recorded step and wraps err in a StepError for step.
+func (sg *saga) fail(ctx context.Context, step string, err error) *StepError {
+	return &StepError{
+		Step:               step,
+		Err:                err,
+		CompensationErrors: sg.unwind(ctx),
+	}
+}
+
+// unwind runs the recorded compensations last-in first-out. It keeps going
+// past failures so one stuck undo does not strand the rest of the rollback.
+// The request context may already be cancelled when a step fails, so undo
+// actions run on a context that keeps its values but drops the cancellation.
+func (sg *saga) unwind(ctx context.Context) []error {
+	if sg == nil {
+		return nil
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for i := len(sg.compensations) - 1; i >= 0; i-- {
+		c := sg.compensations[i]
+		if err := c.undo(ctx); err != nil {
+			log.Printf("checkout: compensate %s: %v", c.step, err)
+			errs = append(errs, fmt.Errorf("compensate %s: %w", c.step, err))
+		}
+	}
+	sg.compensations = nil
+	return errs
+}
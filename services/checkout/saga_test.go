@@ -0,0 +1,355 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
+	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
+	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
+	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+// ---------------------------------------------------------------------------
+// helpers
+// ---------------------------------------------------------------------------
+
+// sagaRecorder is an in-memory inventory + revenue backend that records every
+// write so tests can assert on what a rolled-back PlaceOrder left behind.
+type sagaRecorder struct {
+	mu             sync.Mutex
+	available      float64
+	reserved       float64
+	revenueStatus  string
+	deletedLines   []string
+	serialStatus   map[string]string
+	history        []*serialHistorypb.InventorySerialHistory
+	voidedSessions []string
+}
+
+func newSagaRecorder() *sagaRecorder {
+	return &sagaRecorder{
+		available:     10,
+		revenueStatus: "pending",
+		serialStatus:  map[string]string{"s-1": "available", "s-2": "available"},
+	}
+}
+
+func (r *sagaRecorder) deps() CheckoutDeps {
+	deps := mockDeps()
+	deps.UpdateRevenue = func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if st := req.GetData().GetStatus(); st != "" {
+			r.revenueStatus = st
+		}
+		return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+	}
+	deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+		return &lineItempb.CreateRevenueLineItemResponse{
+			Success: true,
+			Data:    []*lineItempb.RevenueLineItem{{Id: "li-001"}},
+		}, nil
+	}
+	deps.DeleteLineItem = func(_ context.Context, req *lineItempb.DeleteRevenueLineItemRequest) (*lineItempb.DeleteRevenueLineItemResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.deletedLines = append(r.deletedLines, req.GetData().GetId())
+		return &lineItempb.DeleteRevenueLineItemResponse{Success: true}, nil
+	}
+	deps.ListInventoryItems = func(_ context.Context, _ *inventoryItempb.ListInventoryItemsRequest) (*inventoryItempb.ListInventoryItemsResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return &inventoryItempb.ListInventoryItemsResponse{
+			Success: true,
+			Data: []*inventoryItempb.InventoryItem{{
+				Id:                "inv-001",
+				ProductId:         ptr("prod-001"),
+				LocationId:        ptr("loc-001"),
+				QuantityAvailable: r.available,
+				QuantityReserved:  r.reserved,
+				QuantityOnHand:    10,
+				Active:            true,
+			}},
+		}, nil
+	}
+	deps.UpdateInventoryItem = func(_ context.Context, req *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.available = req.GetData().GetQuantityAvailable()
+		r.reserved = req.GetData().GetQuantityReserved()
+		return &inventoryItempb.UpdateInventoryItemResponse{Success: true}, nil
+	}
+	deps.ListSerials = func(_ context.Context, _ *serialpb.ListInventorySerialsRequest) (*serialpb.ListInventorySerialsResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		var data []*serialpb.InventorySerial
+		for _, id := range []string{"s-1", "s-2"} {
+			data = append(data, &serialpb.InventorySerial{Id: id, InventoryItemId: "inv-001", SerialNumber: "SN-" + id, Status: r.serialStatus[id], Active: true})
+		}
+		return &serialpb.ListInventorySerialsResponse{Success: true, Data: data}, nil
+	}
+	deps.UpdateSerial = func(_ context.Context, req *serialpb.UpdateInventorySerialRequest) (*serialpb.UpdateInventorySerialResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.serialStatus[req.GetData().GetId()] = req.GetData().GetStatus()
+		return &serialpb.UpdateInventorySerialResponse{Success: true}, nil
+	}
+	deps.CreateSerialHistory = func(_ context.Context, req *serialHistorypb.CreateInventorySerialHistoryRequest) (*serialHistorypb.CreateInventorySerialHistoryResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.history = append(r.history, req.GetData())
+		return &serialHistorypb.CreateInventorySerialHistoryResponse{Success: true}, nil
+	}
+	deps.VoidPayment = func(_ context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.VoidPaymentResponse, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.voidedSessions = append(r.voidedSessions, req.GetData().GetTransactionId())
+		return &paymentpb.VoidPaymentResponse{Success: true}, nil
+	}
+	return deps
+}
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — saga compensation
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_Saga(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stock update failure cancels revenue and deletes line items", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		deps.UpdateInventoryItem = func(_ context.Context, _ *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+			return nil, fmt.Errorf("inventory write failed")
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %v", err)
+		}
+		if stepErr.Step != StepReserveStock {
+			t.Errorf("Step = %q, want %q", stepErr.Step, StepReserveStock)
+		}
+		if rec.revenueStatus != "cancelled" {
+			t.Errorf("revenue status = %q, want cancelled", rec.revenueStatus)
+		}
+		if len(rec.deletedLines) != 1 || rec.deletedLines[0] != "li-001" {
+			t.Errorf("deleted line items = %v, want [li-001]", rec.deletedLines)
+		}
+	})
+
+	t.Run("serial failure restores stock and releases reserved serials", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		inner := deps.UpdateSerial
+		deps.UpdateSerial = func(ctx context.Context, req *serialpb.UpdateInventorySerialRequest) (*serialpb.UpdateInventorySerialResponse, error) {
+			if req.GetData().GetId() == "s-2" && req.GetData().GetStatus() == "reserved" {
+				return nil, fmt.Errorf("serial locked")
+			}
+			return inner(ctx, req)
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %v", err)
+		}
+		if stepErr.Step != StepReserveSerials {
+			t.Errorf("Step = %q, want %q", stepErr.Step, StepReserveSerials)
+		}
+		if len(stepErr.CompensationErrors) != 0 {
+			t.Errorf("unexpected compensation errors: %v", stepErr.CompensationErrors)
+		}
+		if rec.available != 10 || rec.reserved != 0 {
+			t.Errorf("available/reserved = %v/%v, want 10/0", rec.available, rec.reserved)
+		}
+		if rec.serialStatus["s-1"] != "available" {
+			t.Errorf("serial s-1 status = %q, want available", rec.serialStatus["s-1"])
+		}
+		if rec.revenueStatus != "cancelled" {
+			t.Errorf("revenue status = %q, want cancelled", rec.revenueStatus)
+		}
+
+		// s-1: available→reserved, then reserved→available on rollback.
+		if len(rec.history) != 2 {
+			t.Fatalf("expected 2 history entries, got %d", len(rec.history))
+		}
+		release := rec.history[1]
+		if release.GetFromStatus() != "reserved" || release.GetToStatus() != "available" {
+			t.Errorf("release history = %s→%s, want reserved→available", release.GetFromStatus(), release.GetToStatus())
+		}
+		if release.GetReferenceId() != "rev-001" {
+			t.Errorf("release ReferenceId = %q, want rev-001", release.GetReferenceId())
+		}
+	})
+
+	t.Run("payment session failure unwinds every earlier step", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		deps.CreateCheckoutSession = func(_ context.Context, _ *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+			return nil, fmt.Errorf("payment gateway down")
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "maya"
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), req)
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %v", err)
+		}
+		if stepErr.Step != StepCreateCheckoutSession {
+			t.Errorf("Step = %q, want %q", stepErr.Step, StepCreateCheckoutSession)
+		}
+		if rec.available != 10 || rec.reserved != 0 {
+			t.Errorf("available/reserved = %v/%v, want 10/0", rec.available, rec.reserved)
+		}
+		for id, st := range rec.serialStatus {
+			if st != "available" {
+				t.Errorf("serial %s status = %q, want available", id, st)
+			}
+		}
+		if rec.revenueStatus != "cancelled" {
+			t.Errorf("revenue status = %q, want cancelled", rec.revenueStatus)
+		}
+		if len(rec.voidedSessions) != 0 {
+			t.Errorf("no session was created, but voided %v", rec.voidedSessions)
+		}
+	})
+
+	t.Run("create revenue failure has nothing to compensate", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			return nil, fmt.Errorf("db connection lost")
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %v", err)
+		}
+		if stepErr.Step != StepCreateRevenue {
+			t.Errorf("Step = %q, want %q", stepErr.Step, StepCreateRevenue)
+		}
+		if rec.revenueStatus != "pending" {
+			t.Errorf("revenue status = %q, want untouched", rec.revenueStatus)
+		}
+	})
+
+	t.Run("compensation failure is reported on the step error", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			return nil, fmt.Errorf("line item write failed")
+		}
+		deps.UpdateRevenue = func(_ context.Context, _ *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			return nil, fmt.Errorf("revenue store down")
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %v", err)
+		}
+		if len(stepErr.CompensationErrors) != 1 {
+			t.Errorf("expected 1 compensation error, got %v", stepErr.CompensationErrors)
+		}
+	})
+
+	t.Run("compensations run even when the request context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		rec := newSagaRecorder()
+		deps := rec.deps()
+		ctx, cancel := context.WithCancel(context.Background())
+		deps.ListSerials = func(_ context.Context, _ *serialpb.ListInventorySerialsRequest) (*serialpb.ListInventorySerialsResponse, error) {
+			return &serialpb.ListInventorySerialsResponse{Success: true}, nil
+		}
+		deps.CreateCheckoutSession = func(_ context.Context, _ *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+			cancel()
+			return nil, context.Canceled
+		}
+		inner := deps.UpdateInventoryItem
+		deps.UpdateInventoryItem = func(ctx context.Context, req *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return inner(ctx, req)
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "maya"
+
+		_, err := NewService(deps).PlaceOrder(ctx, req)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected wrapped context.Canceled, got %v", err)
+		}
+		if rec.available != 10 || rec.reserved != 0 {
+			t.Errorf("available/reserved = %v/%v, want 10/0", rec.available, rec.reserved)
+		}
+	})
+}
+
+func TestPlaceOrder_Numbering(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	numbering := shared.NewDocumentNumbering(shared.NewMemoryDocumentSequenceStore())
+	seq := &shared.DocumentSequence{DocumentType: shared.DocumentOrder, LocationID: "loc-001", Prefix: "ORD-M1-", Padding: 5}
+	if err := numbering.SaveSequence(ctx, seq); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := newSagaRecorder()
+	deps := rec.deps()
+	deps.Numbering = numbering
+	result, err := NewService(deps).PlaceOrder(ctx, sampleRequest())
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if result.ReferenceNumber != "ORD-M1-00001" {
+		t.Errorf("ReferenceNumber = %q, want the location series", result.ReferenceNumber)
+	}
+
+	deps.UpdateInventoryItem = func(_ context.Context, _ *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error) {
+		return nil, fmt.Errorf("inventory write failed")
+	}
+	if _, err := NewService(deps).PlaceOrder(ctx, sampleRequest()); err == nil {
+		t.Fatal("expected the order to fail")
+	}
+	register, _ := numbering.Numbers(ctx, seq.ID)
+	if len(register) != 2 || register[0].Number != "ORD-M1-00002" || register[0].Status != shared.DocumentNumberVoided {
+		t.Errorf("rolled-back number = %+v, want ORD-M1-00002 voided", register[0])
+	}
+
+	req := sampleRequest()
+	req.LocationID = "loc-002"
+	deps = rec.deps()
+	deps.Numbering = numbering
+	result, err = NewService(deps).PlaceOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if !regexp.MustCompile(`^ORD-[0-9a-f]{4}-[0-9a-f]{4}$`).MatchString(result.ReferenceNumber) {
+		t.Errorf("unsequenced location ReferenceNumber = %q, want the random fallback", result.ReferenceNumber)
+	}
+}
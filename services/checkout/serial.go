@@ -6,48 +6,42 @@ import (
 	"log"
 	"time"
 
-	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
 	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
 	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
 )
 
-// reserveSerials attempts to reserve inventory serials for the given items.
-// This is a best-effort operation -- if serial tracking is not available for a product,
-// it falls back to quantity-only reservation (handled by reserveStock).
-func (s *Service) reserveSerials(ctx context.Context, revenueID string, items []CheckoutItem) error {
+// reserveSerials reserves inventory serials for the given items. Lookups are
+// best-effort -- if serial tracking is not available for a product, it falls
+// back to quantity-only reservation (handled by reserveStock). A failed serial
+// update is returned so PlaceOrder can unwind. Each reserved serial records an
+// undo on sg that returns it to available; sg may be nil.
+func (s *Service) reserveSerials(ctx context.Context, revenueID string, items []CheckoutItem, sg *saga) error {
 	if s.deps.ListSerials == nil || s.deps.UpdateSerial == nil {
 		return nil
 	}
 
 	for _, item := range items {
-		if err := s.reserveSerialsForItem(ctx, revenueID, item); err != nil {
-			log.Printf("checkout: reserve serials for product %s: %v", item.ProductID, err)
-			// Continue with other items -- serial reservation failures don't block checkout
+		if err := s.reserveSerialsForItem(ctx, revenueID, item, sg); err != nil {
+			return fmt.Errorf("reserve serials for product %s: %w", item.ProductID, err)
 		}
 	}
 	return nil
 }
 
 // reserveSerialsForItem reserves serials for a single checkout item.
-func (s *Service) reserveSerialsForItem(ctx context.Context, revenueID string, item CheckoutItem) error {
-	// List available serials for this product at the given location.
-	// We use the inventory_item_id filter indirectly -- first we need serials
-	// that belong to inventory items for this product+location.
-	// The ListSerials endpoint filters by inventory_item_id, so we first
-	// need to find the inventory item ID.
+func (s *Service) reserveSerialsForItem(ctx context.Context, revenueID string, item CheckoutItem, sg *saga) error {
+	// ListSerials filters by inventory_item_id, so resolve the inventory item
+	// the allocation chose for this product+location first.
 	if s.deps.ListInventoryItems == nil {
 		return nil
 	}
 
-	invResp, err := s.deps.ListInventoryItems(ctx, &inventoryItempb.ListInventoryItemsRequest{
-		ProductId:  &item.ProductID,
-		LocationId: &item.LocationID,
-	})
-	if err != nil || !invResp.GetSuccess() || len(invResp.GetData()) == 0 {
+	invItem, err := s.findInventoryItem(ctx, item)
+	if err != nil || invItem == nil {
 		return nil // No inventory item found -- product may not have inventory tracking
 	}
 
-	invItemID := invResp.GetData()[0].GetId()
+	invItemID := invItem.GetId()
 
 	// List available serials for this inventory item
 	serialResp, err := s.deps.ListSerials(ctx, &serialpb.ListInventorySerialsRequest{
@@ -68,60 +62,70 @@ func (s *Service) reserveSerialsForItem(ctx context.Context, revenueID string, i
 		}
 	}
 
-	if len(availableSerials) == 0 {
-		return nil
+	// Reserve each selected serial
+	for _, serial := range availableSerials {
+		if err := s.transitionSerial(ctx, serial, "available", "reserved", "sale", revenueID,
+			fmt.Sprintf("Reserved for order %s", revenueID)); err != nil {
+			return err
+		}
+		sg.record(StepReserveSerials, func(ctx context.Context) error {
+			return s.transitionSerial(ctx, serial, "reserved", "available", serialReferenceRelease, revenueID,
+				fmt.Sprintf("Released from order %s: checkout rolled back", revenueID))
+		})
 	}
 
-	// Reserve each selected serial
+	return nil
+}
+
+// transitionSerial moves a serial from one status to another and writes the
+// matching serial_history entry under refType/revenueID. Only the status update is fatal; history is
+// an audit trail and failures there are logged.
+func (s *Service) transitionSerial(ctx context.Context, serial *serialpb.InventorySerial, from, to, refType, revenueID, notes string) error {
 	now := time.Now()
 	nowMillis := now.UnixMilli()
 	nowStr := now.Format(time.RFC3339)
 
-	for _, serial := range availableSerials {
-		// Update serial status to "reserved"
-		_, err := s.deps.UpdateSerial(ctx, &serialpb.UpdateInventorySerialRequest{
-			Data: &serialpb.InventorySerial{
-				Id:                 serial.GetId(),
-				InventoryItemId:    serial.GetInventoryItemId(),
-				SerialNumber:       serial.GetSerialNumber(),
-				Status:             "reserved",
-				Active:             true,
-				DateModified:       &nowMillis,
-				DateModifiedString: &nowStr,
-			},
-		})
-		if err != nil {
-			log.Printf("checkout: update serial %s to reserved: %v", serial.GetId(), err)
-			continue
-		}
+	_, err := s.deps.UpdateSerial(ctx, &serialpb.UpdateInventorySerialRequest{
+		Data: &serialpb.InventorySerial{
+			Id:                 serial.GetId(),
+			InventoryItemId:    serial.GetInventoryItemId(),
+			SerialNumber:       serial.GetSerialNumber(),
+			Status:             to,
+			Active:             true,
+			DateModified:       &nowMillis,
+			DateModifiedString: &nowStr,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("update serial %s to %s: %w", serial.GetId(), to, err)
+	}
 
-		// Create serial history entry
-		if s.deps.CreateSerialHistory != nil {
-			historyID, idErr := generateID()
-			if idErr != nil {
-				log.Printf("checkout: generate history id: %v", idErr)
-				continue
-			}
+	if s.deps.CreateSerialHistory == nil {
+		return nil
+	}
 
-			_, err = s.deps.CreateSerialHistory(ctx, &serialHistorypb.CreateInventorySerialHistoryRequest{
-				Data: &serialHistorypb.InventorySerialHistory{
-					Id:                historyID,
-					InventorySerialId: serial.GetId(),
-					InventoryItemId:   serial.GetInventoryItemId(),
-					FromStatus:        "available",
-					ToStatus:          "reserved",
-					ReferenceType:     "sale",
-					ReferenceId:       revenueID,
-					Notes:             fmt.Sprintf("Reserved for order %s", revenueID),
-					DateCreated:       &nowMillis,
-					DateCreatedString: &nowStr,
-				},
-			})
-			if err != nil {
-				log.Printf("checkout: create serial history for %s: %v", serial.GetId(), err)
-			}
-		}
+	historyID, err := generateID()
+	if err != nil {
+		log.Printf("checkout: generate history id: %v", err)
+		return nil
 	}
 
+	_, err = s.deps.CreateSerialHistory(ctx, &serialHistorypb.CreateInventorySerialHistoryRequest{
+		Data: &serialHistorypb.InventorySerialHistory{
+			Id:                historyID,
+			InventorySerialId: serial.GetId(),
+			InventoryItemId:   serial.GetInventoryItemId(),
+			FromStatus:        from,
+			ToStatus:          to,
+			ReferenceType:     refType,
+			ReferenceId:       revenueID,
+			Notes:             notes,
+			DateCreated:       &nowMillis,
+			DateCreatedString: &nowStr,
+		},
+	})
+	if err != nil {
+		log.Printf("checkout: create serial history for %s: %v", serial.GetId(), err)
+	}
 	return nil
 }
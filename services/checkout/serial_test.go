@@ -23,7 +23,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
@@ -42,7 +42,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   2,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
@@ -79,7 +79,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   2,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -122,7 +122,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   5, // want 5 but only 1 available
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -150,7 +150,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -184,7 +184,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   2,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -233,7 +233,7 @@ func TestReserveSerials(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -272,7 +272,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   2,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
@@ -307,7 +307,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -339,7 +339,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -361,13 +361,13 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("expected nil error (best-effort), got %v", err)
 		}
 	})
 
-	t.Run("UpdateSerial failure continues with next serial", func(t *testing.T) {
+	t.Run("UpdateSerial failure stops and returns error", func(t *testing.T) {
 		t.Parallel()
 
 		var historyCount int
@@ -399,16 +399,15 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   2,
-		}})
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
+		}}, nil)
+		if err == nil {
+			t.Fatal("expected error when UpdateSerial fails")
 		}
-		if callCount != 2 {
-			t.Errorf("expected 2 update attempts, got %d", callCount)
+		if callCount != 1 {
+			t.Errorf("expected 1 update attempt, got %d", callCount)
 		}
-		// Only 1 history should be created (second serial succeeded)
-		if historyCount != 1 {
-			t.Errorf("expected 1 history entry (skipping failed), got %d", historyCount)
+		if historyCount != 0 {
+			t.Errorf("expected no history entry for a failed update, got %d", historyCount)
 		}
 	})
 
@@ -423,7 +422,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 		}
 
 		svc := NewService(deps)
-		err := svc.reserveSerials(context.Background(), "rev-001", nil)
+		err := svc.reserveSerials(context.Background(), "rev-001", nil, nil)
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
@@ -451,7 +450,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("expected nil error, got %v", err)
 		}
@@ -484,7 +483,7 @@ func TestReserveSerials_Defensive(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
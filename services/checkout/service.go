@@ -4,11 +4,15 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"strings"
 	"time"
 
+	shared "github.com/erniealice/centymo-golang/domain/shared"
+
 	commonpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/common"
 	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
 	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
@@ -36,6 +40,24 @@ func generateRefNumber() (string, error) {
 	return fmt.Sprintf("ORD-%s-%s", h[:4], h[4:]), nil
 }
 
+// orderReference allocates the order's reference from the "order" sequence
+// and records a void of it on sg, so a rolled-back order leaves its number in
+// the register instead of a gap. Without a configured sequence the reference
+// is random.
+func (s *Service) orderReference(ctx context.Context, locationID string, sg *saga) (string, error) {
+	num, err := s.deps.Numbering.Allocate(ctx, shared.DocumentOrder, locationID)
+	if errors.Is(err, shared.ErrNoDocumentSequence) {
+		return generateRefNumber()
+	}
+	if err != nil {
+		return "", err
+	}
+	sg.record(StepNumberOrder, func(ctx context.Context) error {
+		return s.deps.Numbering.Void(ctx, num.Number, "order not placed")
+	})
+	return num.Number, nil
+}
+
 // generateID generates a random ID string using crypto/rand.
 func generateID() (string, error) {
 	b := make([]byte, 16)
@@ -52,58 +74,165 @@ func ptr[T any](v T) *T {
 	return &v
 }
 
-// PlaceOrder orchestrates the full checkout flow:
-// 1. Generate reference number
-// 2. Create Revenue record
-// 3. Create RevenueLineItems
-// 4. Reserve inventory stock
-// 5. Reserve serials (best-effort)
-// 6. Create payment session (if payment provider set)
-// 7. Return checkout result
+// PlaceOrder orchestrates the full checkout flow as a compensating saga:
+// 0. Verify item prices and totals against the applicable price list
+// 1. Allocate each item to inventory (see AllocationStrategy)
+// 2. Validate promotion codes, compute their discounts and the order's tax
+// 3. Number the order from its sequence (or a random reference)
+// 4. Create Revenue record (total net of discounts, with tax)
+// 5. Redeem promotions
+// 6. Create RevenueLineItems (items, shipping, discounts) and RevenueTaxLines
+// 7. Reserve inventory stock
+// 8. Reserve serials
+// 9. Create payment session (if payment provider set) and return the result
+//
+// Each completed step records an undo action. If a later step fails, the
+// recorded actions run in reverse order (serials back to available, stock
+// quantities restored, line items removed, redemptions given back, revenue
+// cancelled, order number voided) and the caller
+// receives a *StepError naming the step that failed.
+//
+// When req.IdempotencyKey is set and an IdempotencyStore is wired, a replay of
+// a completed order returns the original result without touching revenue or
+// stock, and a replay racing the first attempt gets ErrOrderInProgress.
 func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*CheckoutResult, error) {
-	// 1. Generate reference number
-	refNum, err := generateRefNumber()
+	store := s.deps.Idempotency
+	if store == nil || req.IdempotencyKey == "" {
+		return s.placeOrder(ctx, req)
+	}
+
+	prior, err := store.ClaimOrder(ctx, req.IdempotencyKey)
 	if err != nil {
-		return nil, fmt.Errorf("checkout: %w", err)
+		return nil, err
+	}
+	if prior != nil {
+		return prior, nil
+	}
+
+	result, err := s.placeOrder(ctx, req)
+	if err != nil {
+		if releaseErr := store.ReleaseOrder(context.WithoutCancel(ctx), req.IdempotencyKey); releaseErr != nil {
+			log.Printf("checkout: release idempotency key %s: %v", req.IdempotencyKey, releaseErr)
+		}
+		return nil, err
+	}
+	if err := store.CompleteOrder(context.WithoutCancel(ctx), req.IdempotencyKey, result); err != nil {
+		// The order exists; a failed store write only weakens dedupe for the
+		// next retry, so the caller still gets its result.
+		log.Printf("checkout: store idempotency result for %s: %v", req.IdempotencyKey, err)
+	}
+	return result, nil
+}
+
+// placeOrder runs the PlaceOrder saga for a single, non-replayed attempt.
+func (s *Service) placeOrder(ctx context.Context, req CheckoutRequest) (*CheckoutResult, error) {
+	sg := &saga{}
+
+	// Resolve the payment provider up front so an unknown name fails before
+	// anything is written.
+	var provider PaymentProvider
+	if req.PaymentProvider != "" {
+		p, err := s.paymentProvider(req.PaymentProvider)
+		if err != nil {
+			return nil, sg.fail(ctx, StepCreateCheckoutSession, err)
+		}
+		provider = p
+	}
+
+	// 0. Re-price items server-side; the client's figures are only a claim.
+	req, err := s.verifyPrices(ctx, req)
+	if err != nil {
+		return nil, sg.fail(ctx, StepVerifyPrices, err)
+	}
+
+	// 1. Decide where each item is fulfilled from before anything is written,
+	// so a short branch fails the order cleanly.
+	plan, err := s.PlanAllocation(ctx, req.Items)
+	if err != nil {
+		return nil, sg.fail(ctx, StepAllocateStock, err)
 	}
+	lines := applyAllocation(req.Items, plan)
 
 	now := time.Now()
 	nowMillis := now.UnixMilli()
 	nowStr := now.Format(time.RFC3339)
 
-	// 2. Create Revenue record
-	createRevenueResp, err := s.deps.CreateRevenue(ctx, &revenuepb.CreateRevenueRequest{
-		Data: &revenuepb.Revenue{
-			Active:             true,
-			Name:               "Order " + refNum,
-			ClientId:           req.ClientID,
-			RevenueDate:        &nowStr,
-			DateCreated:        &nowMillis,
-			DateCreatedString:  &nowStr,
-			DateModified:       &nowMillis,
-			DateModifiedString: &nowStr,
-			TotalAmount:        int64(req.TotalAmount),
-			Currency:           req.Currency,
-			Status:             "pending",
-			ReferenceNumber:    &refNum,
-			LocationId:         req.LocationID,
-			PaymentProvider:    &req.PaymentProvider,
-			FulfillmentType:    &req.FulfillmentType,
-			DeliveryAddress:    &req.DeliveryAddress,
-		},
-	})
+	// 2. Price the promotions; redemption waits until the revenue exists.
+	discounts, promos, err := s.applyPromotions(ctx, req, now)
+	if err != nil {
+		return nil, sg.fail(ctx, StepApplyPromotions, err)
+	}
+	for _, d := range discounts {
+		req.TotalAmount = max(req.TotalAmount-d.Amount, 0)
+	}
+
+	// Tax is computed on the discounted lines; exclusive surcharges are added
+	// to the invoice total, withholding only reduces what the customer pays.
+	tax, err := s.computeTaxes(ctx, req, lines, discounts, promos, now)
 	if err != nil {
-		return nil, fmt.Errorf("checkout: create revenue: %w", err)
+		return nil, sg.fail(ctx, StepComputeTax, err)
+	}
+	amountDue := req.TotalAmount
+	if tax != nil {
+		if !tax.Inclusive {
+			req.TotalAmount += tax.Surcharge
+		}
+		amountDue = req.TotalAmount - tax.Withholding
+	}
+
+	// 3. Number the order
+	refNum, err := s.orderReference(ctx, req.LocationID, sg)
+	if err != nil {
+		return nil, sg.fail(ctx, StepNumberOrder, err)
+	}
+
+	// 4. Create Revenue record
+	revenue := &revenuepb.Revenue{
+		Active:             true,
+		Name:               "Order " + refNum,
+		ClientId:           req.ClientID,
+		RevenueDate:        &nowStr,
+		DateCreated:        &nowMillis,
+		DateCreatedString:  &nowStr,
+		DateModified:       &nowMillis,
+		DateModifiedString: &nowStr,
+		TotalAmount:        int64(req.TotalAmount),
+		Currency:           req.Currency,
+		Status:             "pending",
+		ReferenceNumber:    &refNum,
+		LocationId:         req.LocationID,
+		PaymentProvider:    &req.PaymentProvider,
+		FulfillmentType:    &req.FulfillmentType,
+		DeliveryAddress:    &req.DeliveryAddress,
+	}
+	if tax != nil {
+		revenue.CashAmountExpected = ptr(int64(amountDue))
+		revenue.WhtAmountExpected = ptr(int64(tax.Withholding))
+		revenue.TaxInclusivePricingSnapshot = ptr(tax.Inclusive)
+		revenue.TaxComputationEnabledSnapshot = ptr(true)
+	}
+	createRevenueResp, err := s.deps.CreateRevenue(ctx, &revenuepb.CreateRevenueRequest{Data: revenue})
+	if err != nil {
+		return nil, sg.fail(ctx, StepCreateRevenue, err)
 	}
 	if !createRevenueResp.GetSuccess() || len(createRevenueResp.GetData()) == 0 {
-		return nil, fmt.Errorf("checkout: create revenue: unexpected empty response")
+		return nil, sg.fail(ctx, StepCreateRevenue, fmt.Errorf("unexpected empty response"))
 	}
 
 	revenueID := createRevenueResp.GetData()[0].GetId()
+	sg.record(StepCreateRevenue, func(ctx context.Context) error {
+		return s.cancelRevenue(ctx, revenueID)
+	})
 
-	// 3. Create RevenueLineItem for each CheckoutItem
-	for _, item := range req.Items {
-		_, err := s.deps.CreateLineItem(ctx, &lineItempb.CreateRevenueLineItemRequest{
+	// 5. Redeem promotions against their usage limits
+	if err := s.redeemPromotions(ctx, promos, promotionClientKey(req), revenueID, sg); err != nil {
+		return nil, sg.fail(ctx, StepRedeemPromotions, err)
+	}
+
+	// 6. Create a RevenueLineItem for each allocated line
+	lineItemIDs := make([]string, len(lines))
+	for i, item := range lines {
+		lineItemResp, err := s.deps.CreateLineItem(ctx, &lineItempb.CreateRevenueLineItemRequest{
 			Data: &lineItempb.RevenueLineItem{
 				Active:             true,
 				RevenueId:          revenueID,
@@ -117,6 +246,7 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 				VariantId:          &item.VariantID,
 				VariantLabel:       &item.VariantLabel,
 				LocationId:         &item.LocationID,
+				InventoryItemId:    item.inventoryItemID,
 				CostPrice:          ptr(int64(math.Round(item.CostPrice * 100))),
 				DateCreated:        &nowMillis,
 				DateCreatedString:  &nowStr,
@@ -125,31 +255,96 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 			},
 		})
 		if err != nil {
-			log.Printf("checkout: create line item for product %s: %v", item.ProductID, err)
-			// Continue with other items — line item creation failures are logged but don't block checkout
+			return nil, sg.fail(ctx, StepCreateLineItem, fmt.Errorf("product %s: %w", item.ProductID, err))
+		}
+		if !lineItemResp.GetSuccess() {
+			return nil, sg.fail(ctx, StepCreateLineItem, fmt.Errorf("product %s: unsuccessful response", item.ProductID))
+		}
+		if len(lineItemResp.GetData()) > 0 {
+			lineItemID := lineItemResp.GetData()[0].GetId()
+			lineItemIDs[i] = lineItemID
+			sg.record(StepCreateLineItem, func(ctx context.Context) error {
+				return s.deleteLineItem(ctx, lineItemID)
+			})
+		}
+	}
+
+	// Shipping and discounts follow the item lines, in the shape the revenue
+	// detail drawer writes discounts: quantity 1, negative total.
+	var extras []*lineItempb.RevenueLineItem
+	if req.ShippingFee > 0 {
+		extras = append(extras, &lineItempb.RevenueLineItem{
+			Description:  "Shipping",
+			Quantity:     1,
+			UnitPrice:    int64(req.ShippingFee),
+			TotalPrice:   int64(req.ShippingFee),
+			LineItemType: "item",
+		})
+	}
+	for _, d := range discounts {
+		extras = append(extras, &lineItempb.RevenueLineItem{
+			Description:  d.Description,
+			Quantity:     1,
+			UnitPrice:    0,
+			TotalPrice:   -int64(d.Amount),
+			LineItemType: "discount",
+			Notes:        ptr(promotionNote(d)),
+		})
+	}
+	for _, li := range extras {
+		li.Active = true
+		li.RevenueId = revenueID
+		li.DateCreated = &nowMillis
+		li.DateCreatedString = &nowStr
+		li.DateModified = &nowMillis
+		li.DateModifiedString = &nowStr
+		lineItemResp, err := s.deps.CreateLineItem(ctx, &lineItempb.CreateRevenueLineItemRequest{Data: li})
+		if err != nil {
+			return nil, sg.fail(ctx, StepCreateLineItem, fmt.Errorf("%s: %w", li.Description, err))
+		}
+		if !lineItemResp.GetSuccess() {
+			return nil, sg.fail(ctx, StepCreateLineItem, fmt.Errorf("%s: unsuccessful response", li.Description))
+		}
+		if len(lineItemResp.GetData()) > 0 {
+			lineItemID := lineItemResp.GetData()[0].GetId()
+			sg.record(StepCreateLineItem, func(ctx context.Context) error {
+				return s.deleteLineItem(ctx, lineItemID)
+			})
 		}
 	}
 
-	// 4. Reserve stock via UpdateInventoryItem
-	s.reserveStock(ctx, req.Items)
+	if err := s.createTaxLines(ctx, revenueID, tax, lineItemIDs, now); err != nil {
+		return nil, sg.fail(ctx, StepCreateTaxLines, err)
+	}
+
+	// 7. Reserve stock via UpdateInventoryItem
+	if err := s.reserveStock(ctx, lines, sg); err != nil {
+		return nil, sg.fail(ctx, StepReserveStock, err)
+	}
 
-	// 5. Reserve serials (best-effort)
-	if err := s.reserveSerials(ctx, revenueID, req.Items); err != nil {
-		log.Printf("checkout: reserve serials: %v", err)
+	// 8. Reserve serials
+	if err := s.reserveSerials(ctx, revenueID, lines, sg); err != nil {
+		return nil, sg.fail(ctx, StepReserveSerials, err)
 	}
 
 	result := &CheckoutResult{
 		RevenueID:       revenueID,
 		ReferenceNumber: refNum,
 		TotalAmount:     req.TotalAmount,
+		AmountDue:       amountDue,
 		Status:          "pending",
+		ExpiresAt:       now.Add(s.reservationTTL()).UnixMilli(),
+		Allocation:      plan,
+		Discounts:       discounts,
+		Tax:             tax,
 	}
 
-	// 6. Create payment session if provider is set
-	if s.deps.CreateCheckoutSession != nil && req.PaymentProvider != "" {
-		sessionResp, err := s.deps.CreateCheckoutSession(ctx, &paymentpb.CreateCheckoutSessionRequest{
+	// 9. Create payment session if provider is set
+	if provider != nil {
+		sessionResp, err := provider.CreateSession(ctx, &paymentpb.CreateCheckoutSessionRequest{
 			Data: &paymentpb.CheckoutSessionData{
-				Amount:      int64(req.TotalAmount),
+				ProviderId:  req.PaymentProvider,
+				Amount:      int64(amountDue),
 				Currency:    req.Currency,
 				Description: "Order " + refNum,
 				PaymentId:   revenueID,
@@ -157,6 +352,9 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 				SuccessUrl:  req.SuccessURL,
 				FailureUrl:  req.FailureURL,
 				CancelUrl:   req.CancelURL,
+				// Expire the provider session with the reservation so a
+				// payment cannot land after the stock was handed back.
+				ExpiresInMinutes: int32(s.reservationTTL() / time.Minute),
 				Customer: &paymentpb.CustomerInfo{
 					Email: req.CustomerEmail,
 					Name:  req.CustomerName,
@@ -165,7 +363,7 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 			},
 		})
 		if err != nil {
-			return nil, fmt.Errorf("checkout: create payment session: %w", err)
+			return nil, sg.fail(ctx, StepCreateCheckoutSession, err)
 		}
 
 		if sessionResp.GetSuccess() && len(sessionResp.GetData()) > 0 {
@@ -173,8 +371,12 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 			result.CheckoutURL = session.GetCheckoutUrl()
 			result.CheckoutID = session.GetId()
 
-			// Update revenue with checkout session ID
 			checkoutSessionID := session.GetId()
+			sg.record(StepCreateCheckoutSession, func(ctx context.Context) error {
+				return s.voidCheckoutSession(ctx, req.PaymentProvider, checkoutSessionID, refNum)
+			})
+
+			// Update revenue with checkout session ID
 			_, updateErr := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
 				Data: &revenuepb.Revenue{
 					Id:                 revenueID,
@@ -192,55 +394,202 @@ func (s *Service) PlaceOrder(ctx context.Context, req CheckoutRequest) (*Checkou
 	return result, nil
 }
 
+// cancelRevenue is the compensation for CreateRevenue. The revenue is kept
+// (its reference number has already been issued) and marked cancelled.
+func (s *Service) cancelRevenue(ctx context.Context, revenueID string) error {
+	if s.deps.UpdateRevenue == nil {
+		return fmt.Errorf("cancel revenue %s: UpdateRevenue not configured", revenueID)
+	}
+	_, err := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+		Data: &revenuepb.Revenue{
+			Id:                 revenueID,
+			Status:             "cancelled",
+			DateModified:       ptr(time.Now().UnixMilli()),
+			DateModifiedString: ptr(time.Now().Format(time.RFC3339)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cancel revenue %s: %w", revenueID, err)
+	}
+	return nil
+}
+
+// deleteLineItem is the compensation for CreateLineItem. It is a no-op when
+// DeleteLineItem is not wired — the line item then stays on the cancelled
+// revenue, which keeps it out of every open-order view.
+func (s *Service) deleteLineItem(ctx context.Context, lineItemID string) error {
+	if s.deps.DeleteLineItem == nil || lineItemID == "" {
+		return nil
+	}
+	_, err := s.deps.DeleteLineItem(ctx, &lineItempb.DeleteRevenueLineItemRequest{
+		Data: &lineItempb.RevenueLineItem{Id: lineItemID},
+	})
+	if err != nil {
+		return fmt.Errorf("delete line item %s: %w", lineItemID, err)
+	}
+	return nil
+}
+
+// voidCheckoutSession is the compensation for CreateCheckoutSession. It is a
+// no-op when the provider cannot void (see PaymentVoider); the provider
+// session then expires on its own and the expiry webhook finds the revenue
+// already cancelled.
+func (s *Service) voidCheckoutSession(ctx context.Context, provider, sessionID, refNum string) error {
+	if sessionID == "" {
+		return nil
+	}
+	p, err := s.paymentProvider(provider)
+	if err != nil {
+		return fmt.Errorf("void checkout session %s: %w", sessionID, err)
+	}
+	voider, ok := p.(PaymentVoider)
+	if !ok {
+		return nil
+	}
+	_, err = voider.Void(ctx, &paymentpb.VoidPaymentRequest{
+		Data: &paymentpb.VoidData{
+			ProviderId:    provider,
+			TransactionId: sessionID,
+			ProviderRef:   refNum,
+			Reason:        "checkout rolled back",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("void checkout session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
 // reserveStock decrements quantity_available and increments quantity_reserved
-// for each checkout item's inventory.
-func (s *Service) reserveStock(ctx context.Context, items []CheckoutItem) {
+// for each checkout item's inventory. Products without an inventory record are
+// not stock-tracked and are skipped. Each successful update records an undo on
+// sg that gives the quantity back; sg may be nil.
+func (s *Service) reserveStock(ctx context.Context, items []CheckoutItem, sg *saga) error {
 	if s.deps.ListInventoryItems == nil || s.deps.UpdateInventoryItem == nil {
-		return
+		return nil
 	}
 
 	for _, item := range items {
-		// List inventory items by product_id + location_id
-		listResp, err := s.deps.ListInventoryItems(ctx, &inventoryItempb.ListInventoryItemsRequest{
-			ProductId:  &item.ProductID,
-			LocationId: &item.LocationID,
-		})
+		invItem, err := s.findInventoryItem(ctx, item)
 		if err != nil {
-			log.Printf("checkout: list inventory for product %s at location %s: %v", item.ProductID, item.LocationID, err)
-			continue
+			return err
 		}
-		if !listResp.GetSuccess() || len(listResp.GetData()) == 0 {
+		if invItem == nil {
 			continue
 		}
 
-		invItem := listResp.GetData()[0]
-		newAvailable := invItem.GetQuantityAvailable() - float64(item.Quantity)
-		newReserved := invItem.GetQuantityReserved() + float64(item.Quantity)
-
-		_, err = s.deps.UpdateInventoryItem(ctx, &inventoryItempb.UpdateInventoryItemRequest{
-			Data: &inventoryItempb.InventoryItem{
-				Id:                 invItem.GetId(),
-				QuantityAvailable:  newAvailable,
-				QuantityReserved:   newReserved,
-				QuantityOnHand:     invItem.GetQuantityOnHand(),
-				Active:             invItem.GetActive(),
-				DateModified:       ptr(time.Now().UnixMilli()),
-				DateModifiedString: ptr(time.Now().Format(time.RFC3339)),
-			},
+		if err := s.adjustReservation(ctx, invItem, float64(item.Quantity)); err != nil {
+			return fmt.Errorf("update inventory for product %s: %w", item.ProductID, err)
+		}
+
+		sg.record(StepReserveStock, func(ctx context.Context) error {
+			return s.releaseStock(ctx, item)
 		})
-		if err != nil {
-			log.Printf("checkout: update inventory for product %s: %v", item.ProductID, err)
+	}
+	return nil
+}
+
+// releaseStock is the compensation for reserveStock. It re-reads the inventory
+// item so that movements made since the reservation are not overwritten.
+func (s *Service) releaseStock(ctx context.Context, item CheckoutItem) error {
+	invItem, err := s.findInventoryItem(ctx, item)
+	if err != nil {
+		return err
+	}
+	if invItem == nil {
+		return fmt.Errorf("inventory for product %s at location %s not found", item.ProductID, item.LocationID)
+	}
+	if err := s.adjustReservation(ctx, invItem, -float64(item.Quantity)); err != nil {
+		return fmt.Errorf("release inventory for product %s: %w", item.ProductID, err)
+	}
+	return nil
+}
+
+// findInventoryItem lists the inventory for the item's product and location
+// and returns the record the allocation chose, or the first record when the
+// item was not allocated. It returns nil when the product is not stock-tracked
+// there.
+func (s *Service) findInventoryItem(ctx context.Context, item CheckoutItem) (*inventoryItempb.InventoryItem, error) {
+	listResp, err := s.deps.ListInventoryItems(ctx, &inventoryItempb.ListInventoryItemsRequest{
+		ProductId:  &item.ProductID,
+		LocationId: &item.LocationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list inventory for product %s at location %s: %w", item.ProductID, item.LocationID, err)
+	}
+	if !listResp.GetSuccess() || len(listResp.GetData()) == 0 {
+		return nil, nil
+	}
+	if item.inventoryItemID == "" {
+		return listResp.GetData()[0], nil
+	}
+	for _, inv := range listResp.GetData() {
+		if inv.GetId() == item.inventoryItemID {
+			return inv, nil
 		}
 	}
+	return nil, nil
+}
+
+// adjustReservation moves qty from quantity_available to quantity_reserved.
+// A negative qty moves it back. Reservations go through the atomic adjuster
+// and fail with shared.ErrInsufficientStock rather than take available below
+// zero; the snapshot write below is only used when no adjuster can be built.
+func (s *Service) adjustReservation(ctx context.Context, invItem *inventoryItempb.InventoryItem, qty float64) error {
+	if adjust := shared.InventoryAdjuster(s.deps.AdjustInventoryQuantity, s.deps.ReadInventoryItem, s.deps.UpdateInventoryItem); adjust != nil {
+		_, err := adjust(ctx, shared.InventoryDelta{
+			InventoryItemID:  invItem.GetId(),
+			Available:        -qty,
+			Reserved:         qty,
+			RequireAvailable: qty > 0,
+		})
+		return err
+	}
+
+	_, err := s.deps.UpdateInventoryItem(ctx, &inventoryItempb.UpdateInventoryItemRequest{
+		Data: &inventoryItempb.InventoryItem{
+			Id:                 invItem.GetId(),
+			QuantityAvailable:  invItem.GetQuantityAvailable() - qty,
+			QuantityReserved:   invItem.GetQuantityReserved() + qty,
+			QuantityOnHand:     invItem.GetQuantityOnHand(),
+			Active:             invItem.GetActive(),
+			DateModified:       ptr(time.Now().UnixMilli()),
+			DateModifiedString: ptr(time.Now().Format(time.RFC3339)),
+		},
+	})
+	return err
 }
 
 // HandlePaymentWebhook processes a payment webhook and updates the revenue status.
+// The webhook is verified by the provider named in its ProviderId.
+//
+// Providers redeliver webhooks and do not guarantee ordering, so events are
+// filtered before the revenue is touched: each event is claimed in the
+// IdempotencyStore first, so an event already applied is ignored and a
+// concurrent delivery of it fails with ErrWebhookInProgress. The claim is
+// released when the event is not applied. Any event that would move the
+// revenue backwards (see webhookStatusRank) — a late "expired" never turns a
+// paid order into a cancelled one. The change then goes through the revenue
+// status machine (CheckoutDeps.StatusMachine), so workspace guards can veto it
+// and workspace hooks run once it is stored. Ignored events return
+// Ignored = true.
+//
+// When a payment fails, expires or is cancelled, the order's reserved stock
+// and serials are released (see releaseReservations), at most once per order
+// (see claimRelease). A payment that lands after that reserves them again
+// (see rereserveOrder); when it cannot, the order is paid with a note for
+// staff and the result has Unreserved set. Events for one order are handled
+// one at a time within the process.
 func (s *Service) HandlePaymentWebhook(ctx context.Context, webhookReq *paymentpb.ProcessWebhookRequest) (*WebhookResult, error) {
-	if s.deps.ProcessWebhook == nil {
+	provider, err := s.paymentProvider(webhookReq.GetData().GetProviderId())
+	if err != nil {
+		return nil, fmt.Errorf("checkout: webhook: %w", err)
+	}
+	if provider == nil {
 		return nil, fmt.Errorf("checkout: webhook processing not configured")
 	}
 
-	resp, err := s.deps.ProcessWebhook(ctx, webhookReq)
+	resp, err := provider.VerifyWebhook(ctx, webhookReq)
 	if err != nil {
 		return nil, fmt.Errorf("checkout: process webhook: %w", err)
 	}
@@ -255,72 +604,226 @@ func (s *Service) HandlePaymentWebhook(ctx context.Context, webhookReq *paymentp
 
 	webhookData := resp.GetData()[0]
 	revenueID := webhookData.GetPaymentId()
+	revenueStatus := revenueStatusForPayment(webhookData.GetStatus())
 
-	// Map payment status to revenue status
-	var revenueStatus string
-	switch webhookData.GetStatus() {
-	case paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS:
-		revenueStatus = "paid"
-	case paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED:
-		revenueStatus = "cancelled"
-	case paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED:
-		revenueStatus = "cancelled"
-	case paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED:
-		revenueStatus = "cancelled"
-	default:
-		revenueStatus = "pending"
+	result := &WebhookResult{
+		RevenueID: revenueID,
+		Status:    revenueStatus,
+		PaymentID: webhookData.GetPaymentId(),
+		Action:    webhookData.GetAction(),
+	}
+
+	if revenueID == "" || revenueStatus == "pending" {
+		return result, nil
+	}
+
+	eventKey := webhookEventKey(webhookReq, webhookData)
+	store := s.deps.Idempotency
+	applied := false
+	if store != nil {
+		claimed, err := store.ClaimWebhookEvent(ctx, eventKey)
+		if errors.Is(err, ErrWebhookInProgress) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checkout: claim webhook event %s: %w", eventKey, err)
+		}
+		if !claimed {
+			log.Printf("checkout: ignoring duplicate webhook event %s", eventKey)
+			result.Ignored = true
+			return result, nil
+		}
+		defer func() {
+			ctx := context.WithoutCancel(ctx)
+			settle := store.ReleaseWebhookEvent
+			if applied {
+				settle = store.CompleteWebhookEvent
+			}
+			if err := settle(ctx, eventKey); err != nil {
+				log.Printf("checkout: settle webhook event %s: %v", eventKey, err)
+			}
+		}()
+	}
+
+	unlock := lockOrder(revenueID)
+	defer unlock()
+
+	current, known := s.currentRevenueStatus(ctx, revenueID)
+	if known && webhookStatusRank(revenueStatus) <= webhookStatusRank(current) {
+		log.Printf("checkout: ignoring out-of-order webhook for %s: %s -> %s", revenueID, current, revenueStatus)
+		result.Ignored = true
+		return result, nil
 	}
 
-	// Update revenue status
-	if revenueID != "" && revenueStatus != "pending" {
-		_, err := s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
+	transition := shared.RevenueTransition{RevenueID: revenueID, To: revenueStatus}
+	if known {
+		transition.From = current
+	}
+	var updateErr error
+	err = s.statusMachine().Apply(ctx, transition, func(ctx context.Context, t shared.RevenueTransition) error {
+		_, updateErr = s.deps.UpdateRevenue(ctx, &revenuepb.UpdateRevenueRequest{
 			Data: &revenuepb.Revenue{
-				Id:                 revenueID,
-				Status:             revenueStatus,
+				Id:                 t.RevenueID,
+				Status:             t.To,
 				DateModified:       ptr(time.Now().UnixMilli()),
 				DateModifiedString: ptr(time.Now().Format(time.RFC3339)),
 			},
 		})
+		return updateErr
+	})
+	if updateErr != nil {
+		return nil, fmt.Errorf("checkout: update revenue status for %s: %w", revenueID, updateErr)
+	}
+	if err != nil {
+		log.Printf("checkout: ignoring webhook for %s: %v", revenueID, err)
+		result.Ignored = true
+		return result, nil
+	}
+
+	switch revenueStatus {
+	case "cancelled":
+		// Expired, failed or cancelled payment — hand the stock back.
+		if !s.claimRelease(ctx, revenueID, known) {
+			break
+		}
+		reason := strings.ToLower(strings.TrimPrefix(webhookData.GetStatus().String(), "PAYMENT_STATUS_"))
+		if err := s.releaseReservations(ctx, revenueID, "payment "+reason); err != nil {
+			log.Printf("checkout: release reservations for %s: %v", revenueID, err)
+		}
+	case "paid":
+		if known && current == "cancelled" {
+			// The cancellation released the stock; take it back for the
+			// paid order, or flag the order when it is gone.
+			if err := s.rereserveOrder(ctx, revenueID); err != nil {
+				log.Printf("checkout: revenue %s paid after its reservation was released and could not be re-reserved: %v", revenueID, err)
+				result.Unreserved = true
+				if err := s.flagUnreserved(ctx, revenueID, err); err != nil {
+					log.Printf("checkout: flag revenue %s as unreserved: %v", revenueID, err)
+				}
+			}
+		}
+	}
+
+	applied = true
+	return result, nil
+}
+
+// revenueStatusForPayment maps a provider payment status to a revenue status.
+func revenueStatusForPayment(status paymentpb.PaymentStatus) string {
+	switch status {
+	case paymentpb.PaymentStatus_PAYMENT_STATUS_SUCCESS:
+		return "paid"
+	case paymentpb.PaymentStatus_PAYMENT_STATUS_FAILED,
+		paymentpb.PaymentStatus_PAYMENT_STATUS_CANCELLED,
+		paymentpb.PaymentStatus_PAYMENT_STATUS_EXPIRED:
+		return "cancelled"
+	default:
+		return "pending"
+	}
+}
+
+// webhookStatusRank orders the revenue statuses a webhook can set. A webhook
+// is applied only when it moves the revenue to a higher rank. "paid" outranks
+// "cancelled": a captured payment that lands after an expiry must still be
+// recorded, but nothing may un-pay a paid order.
+func webhookStatusRank(status string) int {
+	switch status {
+	case "pending":
+		return 0
+	case "cancelled":
+		return 1
+	case "paid":
+		return 2
+	default:
+		// complete, draft and any later manual status are owned by staff
+		// workflows; webhooks never override them.
+		return 3
+	}
+}
+
+// statusMachine returns the configured revenue status machine, or the default
+// one when CheckoutDeps.StatusMachine is nil.
+func (s *Service) statusMachine() *shared.RevenueStatusMachine {
+	if s.deps.StatusMachine != nil {
+		return s.deps.StatusMachine
+	}
+	return shared.NewRevenueStatusMachine()
+}
+
+// releaseEventPrefix prefixes the IdempotencyStore event key that marks an
+// order's reservation as released by a webhook.
+const releaseEventPrefix = "release:"
+
+// claimRelease reports whether a cancelling webhook may release revenueID's
+// reservation. When the stored status was read (known), the webhook ordering
+// check has already shown the order was not cancelled before. Otherwise the
+// release is claimed once per revenue in the IdempotencyStore, since the
+// failed, expired and cancelled events of one payment carry different event
+// keys. With neither ReadRevenue nor Idempotency a redelivery cannot be told
+// apart, so the stock is left reserved and logged rather than released twice.
+func (s *Service) claimRelease(ctx context.Context, revenueID string, known bool) bool {
+	if known {
+		return true
+	}
+	store := s.deps.Idempotency
+	if store == nil {
+		log.Printf("checkout: revenue %s cancelled by webhook; stock left reserved: neither ReadRevenue nor Idempotency is configured", revenueID)
+		return false
+	}
+	key := releaseEventPrefix + revenueID
+	claimed, err := store.ClaimWebhookEvent(ctx, key)
+	if err != nil {
+		log.Printf("checkout: claim release of %s: %v", revenueID, err)
+		return false
+	}
+	if !claimed {
+		log.Printf("checkout: reservation of %s already released", revenueID)
+		return false
+	}
+	// Completed before releasing: a release that fails half-way is reported,
+	// not retried by the next event.
+	if err := store.CompleteWebhookEvent(context.WithoutCancel(ctx), key); err != nil {
+		log.Printf("checkout: settle release of %s: %v", revenueID, err)
+	}
+	return true
+}
+
+// currentRevenueStatus reads the revenue's stored status. ok is false when
+// ReadRevenue is not wired or the read fails, in which case ordering cannot
+// be checked and the event is applied.
+func (s *Service) currentRevenueStatus(ctx context.Context, revenueID string) (string, bool) {
+	if s.deps.ReadRevenue == nil {
+		return "", false
+	}
+	resp, err := s.deps.ReadRevenue(ctx, &revenuepb.ReadRevenueRequest{
+		Data: &revenuepb.Revenue{Id: revenueID},
+	})
+	if err != nil || !resp.GetSuccess() || len(resp.GetData()) == 0 {
 		if err != nil {
-			log.Printf("checkout: update revenue status for %s: %v", revenueID, err)
+			log.Printf("checkout: read revenue %s for webhook ordering: %v", revenueID, err)
 		}
+		return "", false
 	}
+	return resp.GetData()[0].GetStatus(), true
+}
 
-	return &WebhookResult{
-		RevenueID: revenueID,
-		Status:    revenueStatus,
-		PaymentID: webhookData.GetPaymentId(),
-		Action:    webhookData.GetAction(),
-	}, nil
+// webhookEventKey identifies a webhook event for deduplication. Providers
+// reuse the transaction ID across status changes, so the status is part of
+// the key.
+func webhookEventKey(req *paymentpb.ProcessWebhookRequest, data *paymentpb.WebhookResult) string {
+	txID := data.GetTransaction().GetId()
+	if txID == "" {
+		txID = data.GetPaymentId()
+	}
+	return fmt.Sprintf("%s:%s:%s", req.GetData().GetProviderId(), txID, data.GetStatus())
 }
 
 // GetOrder retrieves a full order by reference number.
 func (s *Service) GetOrder(ctx context.Context, referenceNumber string) (*OrderData, error) {
-	// List revenues filtered by reference_number
-	listResp, err := s.deps.ListRevenues(ctx, &revenuepb.ListRevenuesRequest{
-		Filters: &commonpb.FilterRequest{
-			Logic: commonpb.FilterLogic_AND,
-			Filters: []*commonpb.TypedFilter{
-				{
-					Field: "reference_number",
-					FilterType: &commonpb.TypedFilter_StringFilter{
-						StringFilter: &commonpb.StringFilter{
-							Value:    referenceNumber,
-							Operator: commonpb.StringOperator_STRING_EQUALS,
-						},
-					},
-				},
-			},
-		},
-	})
+	revenue, err := s.findOrder(ctx, referenceNumber)
 	if err != nil {
-		return nil, fmt.Errorf("checkout: list revenues: %w", err)
-	}
-	if !listResp.GetSuccess() || len(listResp.GetData()) == 0 {
-		return nil, fmt.Errorf("checkout: order not found: %s", referenceNumber)
+		return nil, fmt.Errorf("checkout: %w", err)
 	}
-
-	revenue := listResp.GetData()[0]
 	revenueID := revenue.GetId()
 
 	// List line items for this revenue
@@ -352,3 +855,30 @@ func (s *Service) GetOrder(ctx context.Context, referenceNumber string) (*OrderD
 		Items:           items,
 	}, nil
 }
+
+// findOrder looks up the revenue for an ORD-xxxx-xxxx reference number.
+func (s *Service) findOrder(ctx context.Context, referenceNumber string) (*revenuepb.Revenue, error) {
+	listResp, err := s.deps.ListRevenues(ctx, &revenuepb.ListRevenuesRequest{
+		Filters: &commonpb.FilterRequest{
+			Logic: commonpb.FilterLogic_AND,
+			Filters: []*commonpb.TypedFilter{
+				{
+					Field: "reference_number",
+					FilterType: &commonpb.TypedFilter_StringFilter{
+						StringFilter: &commonpb.StringFilter{
+							Value:    referenceNumber,
+							Operator: commonpb.StringOperator_STRING_EQUALS,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list revenues: %w", err)
+	}
+	if !listResp.GetSuccess() || len(listResp.GetData()) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, referenceNumber)
+	}
+	return listResp.GetData()[0], nil
+}
@@ -2,6 +2,7 @@ package checkout
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"testing"
@@ -9,6 +10,8 @@ import (
 	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
 	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
 	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	pricelistpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_list"
+	priceproductpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_product"
 	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
 	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
 	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
@@ -59,11 +62,33 @@ func mockDeps() CheckoutDeps {
 				}},
 			}, nil
 		},
+		FindApplicablePriceList: func(_ context.Context, _ *pricelistpb.FindApplicablePriceListRequest) (*pricelistpb.FindApplicablePriceListResponse, error) {
+			return &pricelistpb.FindApplicablePriceListResponse{
+				Success:   true,
+				Found:     true,
+				PriceList: &pricelistpb.PriceList{Id: "pl-001"},
+			}, nil
+		},
+		ListPriceProducts: func(_ context.Context, _ *priceproductpb.ListPriceProductsRequest) (*priceproductpb.ListPriceProductsResponse, error) {
+			plID := "pl-001"
+			return &priceproductpb.ListPriceProductsResponse{
+				Success: true,
+				Data: []*priceproductpb.PriceProduct{{
+					Id:          "pp-001",
+					ProductId:   "prod-001",
+					PriceListId: &plID,
+					Amount:      10000, // centavos (100.00)
+					Currency:    "PHP",
+				}},
+			}, nil
+		},
 		ListInventoryItems: func(_ context.Context, _ *inventoryItempb.ListInventoryItemsRequest) (*inventoryItempb.ListInventoryItemsResponse, error) {
 			return &inventoryItempb.ListInventoryItemsResponse{
 				Success: true,
 				Data: []*inventoryItempb.InventoryItem{{
 					Id:                "inv-001",
+					ProductId:         ptr("prod-001"),
+					LocationId:        ptr("loc-001"),
 					QuantityAvailable: 10,
 					QuantityReserved:  0,
 					QuantityOnHand:    10,
@@ -270,21 +295,38 @@ func TestPlaceOrder(t *testing.T) {
 		}
 	})
 
-	t.Run("line item creation failure does not block checkout", func(t *testing.T) {
+	t.Run("line item creation failure cancels revenue", func(t *testing.T) {
 		t.Parallel()
 
+		var cancelledID string
 		deps := mockDeps()
 		deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
 			return nil, fmt.Errorf("line item write failed")
 		}
+		deps.UpdateRevenue = func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			if req.GetData().GetStatus() == "cancelled" {
+				cancelledID = req.GetData().GetId()
+			}
+			return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+		}
 		svc := NewService(deps)
 
 		result, err := svc.PlaceOrder(context.Background(), sampleRequest())
-		if err != nil {
-			t.Fatalf("line item failure should not block checkout: %v", err)
+		if err == nil {
+			t.Fatal("expected error when CreateLineItem fails")
 		}
-		if result.RevenueID == "" {
-			t.Error("expected non-empty RevenueID despite line item failure")
+		if result != nil {
+			t.Errorf("expected nil result, got %+v", result)
+		}
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) {
+			t.Fatalf("expected *StepError, got %T", err)
+		}
+		if stepErr.Step != StepCreateLineItem {
+			t.Errorf("Step = %q, want %q", stepErr.Step, StepCreateLineItem)
+		}
+		if cancelledID != "rev-001" {
+			t.Errorf("cancelled revenue = %q, want %q", cancelledID, "rev-001")
 		}
 	})
 }
@@ -325,7 +367,9 @@ func TestReserveStock(t *testing.T) {
 			LocationID: "loc-001",
 			Quantity:   3,
 		}}
-		svc.reserveStock(context.Background(), items)
+		if err := svc.reserveStock(context.Background(), items, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		if capturedUpdate == nil {
 			t.Fatal("UpdateInventoryItem was not called")
@@ -352,7 +396,7 @@ func TestReserveStock(t *testing.T) {
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
 	})
 
 	t.Run("no inventory found continues silently", func(t *testing.T) {
@@ -370,11 +414,14 @@ func TestReserveStock(t *testing.T) {
 		}
 
 		svc := NewService(deps)
-		svc.reserveStock(context.Background(), []CheckoutItem{{
+		err := svc.reserveStock(context.Background(), []CheckoutItem{{
 			ProductID:  "prod-001",
 			LocationID: "loc-001",
 			Quantity:   1,
-		}})
+		}}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		if updateCalled {
 			t.Error("UpdateInventoryItem should not be called when no inventory items found")
@@ -491,6 +538,7 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 		svc := NewService(deps)
 		req := sampleRequest()
 		req.Items = nil
+		req.TotalAmount = 0
 
 		result, err := svc.PlaceOrder(context.Background(), req)
 		if err != nil {
@@ -501,16 +549,13 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 		}
 	})
 
-	t.Run("zero quantity items still creates line items", func(t *testing.T) {
+	t.Run("zero quantity items are rejected before any write", func(t *testing.T) {
 		t.Parallel()
 
 		var lineItemCount int
 		deps := mockDeps()
-		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+		deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
 			lineItemCount++
-			if req.GetData().GetQuantity() != 0 {
-				t.Errorf("expected Quantity = 0, got %v", req.GetData().GetQuantity())
-			}
 			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
 		}
 		svc := NewService(deps)
@@ -522,16 +567,14 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 			UnitPrice:  10000,
 			TotalPrice: 0,
 		}}
+		req.TotalAmount = 0
 
-		result, err := svc.PlaceOrder(context.Background(), req)
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
-		}
-		if result.RevenueID == "" {
-			t.Error("expected non-empty RevenueID")
+		_, err := svc.PlaceOrder(context.Background(), req)
+		if !errors.Is(err, ErrInvalidQuantity) {
+			t.Fatalf("expected ErrInvalidQuantity, got %v", err)
 		}
-		if lineItemCount != 1 {
-			t.Errorf("expected 1 line item created, got %d", lineItemCount)
+		if lineItemCount != 0 {
+			t.Errorf("expected no line items created, got %d", lineItemCount)
 		}
 	})
 
@@ -540,6 +583,7 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 
 		var capturedRevenue *revenuepb.Revenue
 		deps := mockDeps()
+		deps.TrustClientPrices = true // pass-through is only possible in trust mode
 		deps.CreateRevenue = func(_ context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
 			capturedRevenue = req.GetData()
 			return &revenuepb.CreateRevenueResponse{
@@ -568,6 +612,7 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 
 		var capturedLineItem *lineItempb.RevenueLineItem
 		deps := mockDeps()
+		deps.TrustClientPrices = true // pass-through is only possible in trust mode
 		deps.CreateLineItem = func(_ context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
 			capturedLineItem = req.GetData()
 			return &lineItempb.CreateRevenueLineItemResponse{Success: true}, nil
@@ -600,12 +645,13 @@ func TestPlaceOrder_Defensive(t *testing.T) {
 		deps := mockDeps()
 		svc := NewService(deps)
 		req := CheckoutRequest{
+			TotalAmount: 10000,
 			Items: []CheckoutItem{{
 				ProductID:  "prod-001",
 				LocationID: "loc-001",
 				Quantity:   1,
-				UnitPrice:  100,
-				TotalPrice: 100,
+				UnitPrice:  10000,
+				TotalPrice: 10000,
 			}},
 		}
 
@@ -724,7 +770,7 @@ func TestHandlePaymentWebhook_Defensive(t *testing.T) {
 		}
 	})
 
-	t.Run("update revenue failure is non-fatal", func(t *testing.T) {
+	t.Run("update revenue failure is returned for redelivery", func(t *testing.T) {
 		t.Parallel()
 
 		deps := mockDeps()
@@ -743,12 +789,8 @@ func TestHandlePaymentWebhook_Defensive(t *testing.T) {
 		}
 
 		svc := NewService(deps)
-		result, err := svc.HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{})
-		if err != nil {
-			t.Fatalf("update revenue failure should not block webhook result: %v", err)
-		}
-		if result.Status != "paid" {
-			t.Errorf("Status = %q, want %q", result.Status, "paid")
+		if _, err := svc.HandlePaymentWebhook(context.Background(), &paymentpb.ProcessWebhookRequest{}); err == nil {
+			t.Fatal("expected the update failure to be returned so the provider redelivers")
 		}
 	})
 
@@ -0,0 +1,319 @@
+package checkout
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+	taxratepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/tax/tax_rate"
+)
+
+// Tax line directions, as written to RevenueTaxLine and TaxLine.Direction.
+const (
+	TaxDirectionSurcharge   = "SURCHARGE"
+	TaxDirectionWithholding = "WITHHOLDING"
+)
+
+// TaxResolver returns the tax rates that apply to productID sold at
+// locationID on asOf. The consumer app resolves the product's tax treatment
+// and the location's jurisdiction to tax_rate rows; an empty result means the
+// product is not taxed there.
+type TaxResolver func(ctx context.Context, productID, locationID string, asOf time.Time) ([]*taxratepb.TaxRate, error)
+
+// TaxLine is one computed tax, grouped per tax rate across the order's item
+// lines. It mirrors the RevenueTaxLine row written for it.
+type TaxLine struct {
+	TaxRateID       string
+	Kind            string // tax_rate.kind, e.g. "VAT_STANDARD"
+	AuthorityCode   string
+	Direction       string // TaxDirectionSurcharge or TaxDirectionWithholding
+	RateBasisPoints int
+	TaxableBase     int // centavos, net of tax and discounts
+	TaxAmount       int // centavos
+
+	// lineIndexes are the checkout lines that contributed to this tax.
+	lineIndexes []int
+	rate        *taxratepb.TaxRate
+}
+
+// TaxBreakdown is the tax computed for an order, in the same numbers the
+// invoice shows.
+type TaxBreakdown struct {
+	// Inclusive is true when item prices already contain surcharge taxes.
+	Inclusive bool
+	Lines     []TaxLine
+	// Surcharge is the total of surcharge lines. It is inside TotalAmount when
+	// Inclusive and added on top of it otherwise.
+	Surcharge int
+	// Withholding is the total of withholding lines, deducted from the amount
+	// the customer pays but not from the invoice total.
+	Withholding int
+}
+
+// computeTaxes resolves the tax rates for every allocated line and computes
+// the order's tax lines. Discounts reduce the taxable base of the lines they
+// apply to (pro rata by line total); shipping is not taxed. Returns nil when
+// no TaxResolver is wired.
+//
+// Rounding is deterministic half-up in centavos:
+//   - exclusive: tax = round(base × bp / 10000), per rate across its lines
+//   - inclusive: each line's net = round(gross × 10000 / (10000 + Σbp)); the
+//     extracted tax (gross − net) is split across the line's surcharge rates
+//     by basis points, largest remainder first, so net + tax == gross exactly
+//   - withholding is always computed on the net base
+func (s *Service) computeTaxes(ctx context.Context, req CheckoutRequest, lines []CheckoutItem, discounts []AppliedDiscount, promos []*Promotion, now time.Time) (*TaxBreakdown, error) {
+	if s.deps.ResolveTaxRates == nil {
+		return nil, nil
+	}
+	if s.deps.CreateRevenueTaxLine == nil {
+		return nil, fmt.Errorf("tax lines not configured: CreateRevenueTaxLine is nil")
+	}
+
+	bases := discountedBases(lines, discounts, promos)
+	today := now.Format("2006-01-02")
+	cache := map[string][]*taxratepb.TaxRate{}
+	breakdown := &TaxBreakdown{Inclusive: s.deps.TaxInclusivePricing}
+	byRate := map[string]*TaxLine{}
+	var order []string
+
+	group := func(rate *taxratepb.TaxRate, direction string) *TaxLine {
+		key := rate.GetId() + "|" + direction
+		if rate.GetId() == "" {
+			key = rate.GetKind() + "|" + direction
+		}
+		tl, ok := byRate[key]
+		if !ok {
+			tl = &TaxLine{
+				TaxRateID:       rate.GetId(),
+				Kind:            rate.GetKind(),
+				AuthorityCode:   rate.GetAuthorityCode(),
+				Direction:       direction,
+				RateBasisPoints: int(rate.GetRateBasisPoints()),
+				rate:            rate,
+			}
+			byRate[key] = tl
+			order = append(order, key)
+		}
+		return tl
+	}
+
+	for i, line := range lines {
+		locationID := line.LocationID
+		if locationID == "" {
+			locationID = req.LocationID
+		}
+		cacheKey := line.ProductID + "|" + locationID
+		rates, ok := cache[cacheKey]
+		if !ok {
+			resolved, err := s.deps.ResolveTaxRates(ctx, line.ProductID, locationID, now)
+			if err != nil {
+				return nil, fmt.Errorf("resolve tax for product %s at location %s: %w", line.ProductID, locationID, err)
+			}
+			for _, r := range resolved {
+				if taxRateEffective(r, today) {
+					rates = append(rates, r)
+				}
+			}
+			cache[cacheKey] = rates
+		}
+		if len(rates) == 0 || bases[i] <= 0 {
+			continue
+		}
+
+		var surcharges, withholdings []*taxratepb.TaxRate
+		for _, r := range rates {
+			if r.GetDirection() == taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_WITHHOLDING {
+				withholdings = append(withholdings, r)
+			} else {
+				surcharges = append(surcharges, r)
+			}
+		}
+
+		gross := bases[i]
+		net := gross
+		if breakdown.Inclusive && len(surcharges) > 0 {
+			totalBP := 0
+			weights := make([]int, len(surcharges))
+			for j, r := range surcharges {
+				weights[j] = int(r.GetRateBasisPoints())
+				totalBP += weights[j]
+			}
+			net = roundDiv(gross*10000, 10000+totalBP)
+			shares := splitLargestRemainder(gross-net, weights)
+			for j, r := range surcharges {
+				tl := group(r, TaxDirectionSurcharge)
+				tl.TaxableBase += net
+				tl.TaxAmount += shares[j]
+				tl.lineIndexes = append(tl.lineIndexes, i)
+			}
+		} else {
+			for _, r := range surcharges {
+				tl := group(r, TaxDirectionSurcharge)
+				tl.TaxableBase += net
+				tl.lineIndexes = append(tl.lineIndexes, i)
+			}
+		}
+		for _, r := range withholdings {
+			tl := group(r, TaxDirectionWithholding)
+			tl.TaxableBase += net
+			tl.lineIndexes = append(tl.lineIndexes, i)
+		}
+	}
+
+	for _, key := range order {
+		tl := byRate[key]
+		if !(breakdown.Inclusive && tl.Direction == TaxDirectionSurcharge) {
+			tl.TaxAmount = roundDiv(tl.TaxableBase*tl.RateBasisPoints, 10000)
+		}
+		if tl.Direction == TaxDirectionWithholding {
+			breakdown.Withholding += tl.TaxAmount
+		} else {
+			breakdown.Surcharge += tl.TaxAmount
+		}
+		breakdown.Lines = append(breakdown.Lines, *tl)
+	}
+	if len(breakdown.Lines) == 0 {
+		return nil, nil
+	}
+	return breakdown, nil
+}
+
+// createTaxLines writes one RevenueTaxLine per computed tax. lineItemIDs maps
+// checkout line index to the created RevenueLineItem ID (empty when the
+// backend did not return one). Tax lines are not compensated separately:
+// they stay with the cancelled revenue, like line items without
+// DeleteLineItem.
+func (s *Service) createTaxLines(ctx context.Context, revenueID string, breakdown *TaxBreakdown, lineItemIDs []string, now time.Time) error {
+	if breakdown == nil {
+		return nil
+	}
+	nowMillis := now.UnixMilli()
+	nowStr := now.Format(time.RFC3339)
+	for _, tl := range breakdown.Lines {
+		var applied []string
+		for _, i := range tl.lineIndexes {
+			if i < len(lineItemIDs) && lineItemIDs[i] != "" {
+				applied = append(applied, lineItemIDs[i])
+			}
+		}
+		direction := revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_SURCHARGE
+		if tl.Direction == TaxDirectionWithholding {
+			direction = revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING
+		}
+		row := &revenuetaxlinepb.RevenueTaxLine{
+			Active:                  true,
+			RevenueId:               revenueID,
+			AuthorityCodeSnapshot:   tl.AuthorityCode,
+			RegulatorCodeSnapshot:   tl.rate.RegulatorCode,
+			FilingFormCodeSnapshot:  tl.rate.FilingFormCode,
+			TaxKindSnapshot:         tl.Kind,
+			Direction:               direction,
+			TaxableBase:             int64(tl.TaxableBase),
+			TaxAmount:               int64(tl.TaxAmount),
+			RateBasisPointsSnapshot: int32(tl.RateBasisPoints),
+			AppliedToLineItemIds:    applied,
+			ComputedAt:              &nowStr,
+			DateCreated:             &nowMillis,
+			DateCreatedString:       &nowStr,
+			DateModified:            &nowMillis,
+			DateModifiedString:      &nowStr,
+		}
+		if tl.TaxRateID != "" {
+			row.TaxRateId = ptr(tl.TaxRateID)
+		}
+		if _, err := s.deps.CreateRevenueTaxLine(ctx, row); err != nil {
+			return fmt.Errorf("tax %s: %w", tl.Kind, err)
+		}
+	}
+	return nil
+}
+
+// taxRateEffective reports whether r is usable on today (YYYY-MM-DD): not
+// draft, superseded or voided, and inside its effective window.
+func taxRateEffective(r *taxratepb.TaxRate, today string) bool {
+	switch r.GetStatus() {
+	case taxratepb.TaxRateStatus_TAX_RATE_STATUS_DRAFT,
+		taxratepb.TaxRateStatus_TAX_RATE_STATUS_SUPERSEDED,
+		taxratepb.TaxRateStatus_TAX_RATE_STATUS_VOIDED:
+		return false
+	}
+	if from := r.GetEffectiveFrom(); len(from) >= 10 && from[:10] > today {
+		return false
+	}
+	if to := r.GetEffectiveTo(); len(to) >= 10 && to[:10] <= today {
+		return false
+	}
+	return true
+}
+
+// discountedBases returns each line's total after the item discounts that
+// apply to it. A discount is spread over its eligible lines pro rata by line
+// total; free-shipping discounts do not touch item lines.
+func discountedBases(lines []CheckoutItem, discounts []AppliedDiscount, promos []*Promotion) []int {
+	bases := make([]int, len(lines))
+	for i, line := range lines {
+		bases[i] = line.TotalPrice
+	}
+	for d, discount := range discounts {
+		var promo *Promotion
+		if d < len(promos) {
+			promo = promos[d]
+		}
+		if promo != nil && promo.Kind == PromotionFreeShipping {
+			continue
+		}
+		var eligible []int
+		weights := []int{}
+		remaining := 0
+		for i, line := range lines {
+			if promo != nil && !promo.appliesTo(line.ProductID) {
+				continue
+			}
+			if bases[i] <= 0 {
+				continue
+			}
+			eligible = append(eligible, i)
+			weights = append(weights, bases[i])
+			remaining += bases[i]
+		}
+		shares := splitLargestRemainder(min(discount.Amount, remaining), weights)
+		for j, i := range eligible {
+			bases[i] -= shares[j]
+		}
+	}
+	return bases
+}
+
+// splitLargestRemainder splits amount across weights in proportion, giving
+// the leftover centavos to the largest remainders (earlier index on ties).
+func splitLargestRemainder(amount int, weights []int) []int {
+	shares := make([]int, len(weights))
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 || amount == 0 {
+		return shares
+	}
+	type rem struct{ index, value int }
+	rems := make([]rem, len(weights))
+	allocated := 0
+	for i, w := range weights {
+		shares[i] = amount * w / total
+		rems[i] = rem{i, amount * w % total}
+		allocated += shares[i]
+	}
+	sort.SliceStable(rems, func(a, b int) bool { return rems[a].value > rems[b].value })
+	for k := 0; allocated < amount; k++ {
+		shares[rems[k%len(rems)].index]++
+		allocated++
+	}
+	return shares
+}
+
+// roundDiv is n / d rounded half-up, for non-negative n and positive d.
+func roundDiv(n, d int) int {
+	return (2*n + d) / (2 * d)
+}
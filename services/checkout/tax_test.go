@@ -0,0 +1,342 @@
+package checkout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
+	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
+	taxratepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/tax/tax_rate"
+	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
+)
+
+var (
+	vat12 = &taxratepb.TaxRate{Id: "tr-vat", Kind: "VAT_STANDARD", AuthorityCode: "BIR", RateBasisPoints: 1200,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_SURCHARGE, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_ACTIVE,
+		EffectiveFrom: "2018-01-01", RegulatorCode: ptr("VT010")}
+	levy3 = &taxratepb.TaxRate{Id: "tr-levy", Kind: "LOCAL_LEVY", AuthorityCode: "LGU", RateBasisPoints: 300,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_SURCHARGE, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_ACTIVE}
+	wht1 = &taxratepb.TaxRate{Id: "tr-wht", Kind: "WHT_GOODS", AuthorityCode: "BIR", RateBasisPoints: 100,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_WITHHOLDING, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_ACTIVE}
+	zeroRated = &taxratepb.TaxRate{Id: "tr-zero", Kind: "VAT_ZERO_RATED", AuthorityCode: "BIR", RateBasisPoints: 0,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_SURCHARGE, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_ACTIVE}
+	vatFuture = &taxratepb.TaxRate{Id: "tr-vat-next", Kind: "VAT_STANDARD", RateBasisPoints: 1500,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_SURCHARGE, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_ACTIVE,
+		EffectiveFrom: "2099-01-01"}
+	vatVoided = &taxratepb.TaxRate{Id: "tr-vat-old", Kind: "VAT_STANDARD", RateBasisPoints: 1000,
+		Direction: taxratepb.TaxRateDirection_TAX_RATE_DIRECTION_SURCHARGE, Status: taxratepb.TaxRateStatus_TAX_RATE_STATUS_VOIDED}
+)
+
+// taxRates returns a TaxResolver serving rates per product ID.
+func taxRates(byProduct map[string][]*taxratepb.TaxRate) TaxResolver {
+	return func(_ context.Context, productID, _ string, _ time.Time) ([]*taxratepb.TaxRate, error) {
+		return byProduct[productID], nil
+	}
+}
+
+func writeTaxLine(_ context.Context, line *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error) {
+	return line, nil
+}
+
+// ---------------------------------------------------------------------------
+// computeTaxes — amounts and rounding
+// ---------------------------------------------------------------------------
+
+func TestComputeTaxes(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	line := func(productID string, total int) CheckoutItem {
+		return CheckoutItem{ProductID: productID, LocationID: "loc-001", Quantity: 1, UnitPrice: total, TotalPrice: total}
+	}
+
+	tests := []struct {
+		name      string
+		inclusive bool
+		rates     map[string][]*taxratepb.TaxRate
+		lines     []CheckoutItem
+		discounts []AppliedDiscount
+		promos    []*Promotion
+		want      string // "kind:base/amount ..." in line order
+	}{
+		{
+			name:  "exclusive VAT",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {vat12}},
+			lines: []CheckoutItem{line("p1", 20000)},
+			want:  "VAT_STANDARD:20000/2400",
+		},
+		{
+			name:      "inclusive VAT is extracted so net + tax equals the price",
+			inclusive: true,
+			rates:     map[string][]*taxratepb.TaxRate{"p1": {vat12}},
+			lines:     []CheckoutItem{line("p1", 20000)},
+			want:      "VAT_STANDARD:17857/2143",
+		},
+		{
+			name:  "exclusive tax is rounded once per rate, not per line",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {vat12}},
+			lines: []CheckoutItem{line("p1", 1004), line("p1", 1004)},
+			want:  "VAT_STANDARD:2008/241",
+		},
+		{
+			name:      "inclusive rates on one line split the extracted tax by basis points",
+			inclusive: true,
+			rates:     map[string][]*taxratepb.TaxRate{"p1": {vat12, levy3}},
+			lines:     []CheckoutItem{line("p1", 11500)},
+			want:      "VAT_STANDARD:10000/1200 LOCAL_LEVY:10000/300",
+		},
+		{
+			name:  "withholding on the net base",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {vat12, wht1}},
+			lines: []CheckoutItem{line("p1", 20000)},
+			want:  "VAT_STANDARD:20000/2400 WHT_GOODS:20000/200",
+		},
+		{
+			name:  "only taxed products contribute",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {vat12}},
+			lines: []CheckoutItem{line("p1", 10000), line("p2", 5000)},
+			want:  "VAT_STANDARD:10000/1200",
+		},
+		{
+			name:  "zero-rated line is kept for the audit trail",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {zeroRated}},
+			lines: []CheckoutItem{line("p1", 10000)},
+			want:  "VAT_ZERO_RATED:10000/0",
+		},
+		{
+			name:  "rates outside their window or voided are ignored",
+			rates: map[string][]*taxratepb.TaxRate{"p1": {vatFuture, vatVoided, vat12}},
+			lines: []CheckoutItem{line("p1", 10000)},
+			want:  "VAT_STANDARD:10000/1200",
+		},
+		{
+			name:      "discount lowers the base of eligible lines pro rata",
+			rates:     map[string][]*taxratepb.TaxRate{"p1": {vat12}, "p2": {vat12}},
+			lines:     []CheckoutItem{line("p1", 10000), line("p2", 30000)},
+			discounts: []AppliedDiscount{{Amount: 4000}},
+			promos:    []*Promotion{{Kind: PromotionFixed}},
+			want:      "VAT_STANDARD:36000/4320",
+		},
+		{
+			name:      "product-restricted discount only touches its products",
+			rates:     map[string][]*taxratepb.TaxRate{"p1": {vat12}, "p2": {levy3}},
+			lines:     []CheckoutItem{line("p1", 10000), line("p2", 10000)},
+			discounts: []AppliedDiscount{{Amount: 5000}},
+			promos:    []*Promotion{{Kind: PromotionFixed, ProductIDs: []string{"p2"}}},
+			want:      "VAT_STANDARD:10000/1200 LOCAL_LEVY:5000/150",
+		},
+		{
+			name:      "free shipping leaves item bases alone",
+			rates:     map[string][]*taxratepb.TaxRate{"p1": {vat12}},
+			lines:     []CheckoutItem{line("p1", 10000)},
+			discounts: []AppliedDiscount{{Amount: 15000}},
+			promos:    []*Promotion{{Kind: PromotionFreeShipping}},
+			want:      "VAT_STANDARD:10000/1200",
+		},
+		{
+			name:  "untaxed order",
+			rates: map[string][]*taxratepb.TaxRate{},
+			lines: []CheckoutItem{line("p1", 10000)},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			svc := NewService(CheckoutDeps{
+				ResolveTaxRates:      taxRates(tt.rates),
+				CreateRevenueTaxLine: writeTaxLine,
+				TaxInclusivePricing:  tt.inclusive,
+			})
+			breakdown, err := svc.computeTaxes(context.Background(), CheckoutRequest{LocationID: "loc-001"}, tt.lines, tt.discounts, tt.promos, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if breakdown != nil {
+				for i, tl := range breakdown.Lines {
+					if i > 0 {
+						got += " "
+					}
+					got += fmt.Sprintf("%s:%d/%d", tl.Kind, tl.TaxableBase, tl.TaxAmount)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("tax lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLargestRemainder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		amount  int
+		weights []int
+		want    string
+	}{
+		{amount: 100, weights: []int{1, 1, 1}, want: "[34 33 33]"},
+		{amount: 1500, weights: []int{1200, 300}, want: "[1200 300]"},
+		{amount: 7, weights: []int{0, 5}, want: "[0 7]"},
+		{amount: 0, weights: []int{1, 2}, want: "[0 0]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(splitLargestRemainder(tt.amount, tt.weights)); got != tt.want {
+			t.Errorf("splitLargestRemainder(%d, %v) = %s, want %s", tt.amount, tt.weights, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// PlaceOrder — tax lines
+// ---------------------------------------------------------------------------
+
+func TestPlaceOrder_Tax(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exclusive tax is added to the total and written as tax lines", func(t *testing.T) {
+		t.Parallel()
+
+		var revenue *revenuepb.Revenue
+		var taxLines []*revenuetaxlinepb.RevenueTaxLine
+		var sessionAmount int64
+		deps := mockDeps()
+		deps.ResolveTaxRates = taxRates(map[string][]*taxratepb.TaxRate{"prod-001": {vat12, wht1}})
+		deps.CreateRevenueTaxLine = func(_ context.Context, line *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error) {
+			taxLines = append(taxLines, line)
+			return line, nil
+		}
+		deps.CreateRevenue = func(_ context.Context, req *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenue = req.GetData()
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+		deps.CreateLineItem = func(_ context.Context, _ *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error) {
+			return &lineItempb.CreateRevenueLineItemResponse{Success: true, Data: []*lineItempb.RevenueLineItem{{Id: "li-001"}}}, nil
+		}
+		deps.CreateCheckoutSession = func(_ context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error) {
+			sessionAmount = req.GetData().GetAmount()
+			return &paymentpb.CreateCheckoutSessionResponse{Success: true}, nil
+		}
+		req := sampleRequest()
+		req.PaymentProvider = "maya"
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.TotalAmount != 22400 || revenue.GetTotalAmount() != 22400 {
+			t.Errorf("TotalAmount = %d (revenue %d), want 22400", result.TotalAmount, revenue.GetTotalAmount())
+		}
+		if result.AmountDue != 22200 || sessionAmount != 22200 || revenue.GetCashAmountExpected() != 22200 {
+			t.Errorf("amount due = %d (session %d, revenue %d), want 22200", result.AmountDue, sessionAmount, revenue.GetCashAmountExpected())
+		}
+		if revenue.GetWhtAmountExpected() != 200 || revenue.GetTaxInclusivePricingSnapshot() || !revenue.GetTaxComputationEnabledSnapshot() {
+			t.Errorf("revenue tax snapshots = %+v", revenue)
+		}
+		if result.Tax == nil || result.Tax.Surcharge != 2400 || result.Tax.Withholding != 200 {
+			t.Fatalf("Tax = %+v", result.Tax)
+		}
+		if len(taxLines) != 2 {
+			t.Fatalf("wrote %d tax lines, want 2", len(taxLines))
+		}
+		vat := taxLines[0]
+		if vat.GetRevenueId() != "rev-001" || vat.GetTaxRateId() != "tr-vat" || vat.GetTaxKindSnapshot() != "VAT_STANDARD" ||
+			vat.GetRateBasisPointsSnapshot() != 1200 || vat.GetTaxableBase() != 20000 || vat.GetTaxAmount() != 2400 ||
+			vat.GetRegulatorCodeSnapshot() != "VT010" ||
+			vat.GetDirection() != revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_SURCHARGE {
+			t.Errorf("VAT line = %+v", vat)
+		}
+		if fmt.Sprint(vat.GetAppliedToLineItemIds()) != "[li-001]" {
+			t.Errorf("AppliedToLineItemIds = %v, want [li-001]", vat.GetAppliedToLineItemIds())
+		}
+		if taxLines[1].GetDirection() != revenuetaxlinepb.RevenueTaxLineDirection_REVENUE_TAX_LINE_DIRECTION_WITHHOLDING {
+			t.Errorf("second line direction = %v, want withholding", taxLines[1].GetDirection())
+		}
+	})
+
+	t.Run("inclusive tax leaves the total unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.ResolveTaxRates = taxRates(map[string][]*taxratepb.TaxRate{"prod-001": {vat12}})
+		deps.CreateRevenueTaxLine = writeTaxLine
+		deps.TaxInclusivePricing = true
+
+		result, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.TotalAmount != 20000 || result.AmountDue != 20000 {
+			t.Errorf("TotalAmount = %d, AmountDue = %d; want 20000", result.TotalAmount, result.AmountDue)
+		}
+		if !result.Tax.Inclusive || result.Tax.Surcharge != 2143 {
+			t.Errorf("Tax = %+v, want inclusive 2143", result.Tax)
+		}
+	})
+
+	t.Run("resolver failure stops the order before any write", func(t *testing.T) {
+		t.Parallel()
+
+		revenueCreated := false
+		deps := mockDeps()
+		deps.ResolveTaxRates = func(_ context.Context, _, _ string, _ time.Time) ([]*taxratepb.TaxRate, error) {
+			return nil, errors.New("tax service down")
+		}
+		deps.CreateRevenueTaxLine = writeTaxLine
+		deps.CreateRevenue = func(_ context.Context, _ *revenuepb.CreateRevenueRequest) (*revenuepb.CreateRevenueResponse, error) {
+			revenueCreated = true
+			return &revenuepb.CreateRevenueResponse{Success: true, Data: []*revenuepb.Revenue{{Id: "rev-001"}}}, nil
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepComputeTax {
+			t.Fatalf("expected StepError for %s, got %v", StepComputeTax, err)
+		}
+		if revenueCreated {
+			t.Error("CreateRevenue should not be called when tax cannot be resolved")
+		}
+	})
+
+	t.Run("failed tax line write rolls the order back", func(t *testing.T) {
+		t.Parallel()
+
+		var cancelled bool
+		deps := mockDeps()
+		deps.ResolveTaxRates = taxRates(map[string][]*taxratepb.TaxRate{"prod-001": {vat12}})
+		deps.CreateRevenueTaxLine = func(_ context.Context, _ *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error) {
+			return nil, errors.New("insert failed")
+		}
+		deps.UpdateRevenue = func(_ context.Context, req *revenuepb.UpdateRevenueRequest) (*revenuepb.UpdateRevenueResponse, error) {
+			cancelled = req.GetData().GetStatus() == "cancelled"
+			return &revenuepb.UpdateRevenueResponse{Success: true}, nil
+		}
+
+		_, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest())
+		var stepErr *StepError
+		if !errors.As(err, &stepErr) || stepErr.Step != StepCreateTaxLines {
+			t.Fatalf("expected StepError for %s, got %v", StepCreateTaxLines, err)
+		}
+		if !cancelled {
+			t.Error("revenue should be cancelled")
+		}
+	})
+
+	t.Run("resolver without a tax line writer fails closed", func(t *testing.T) {
+		t.Parallel()
+
+		deps := mockDeps()
+		deps.ResolveTaxRates = taxRates(nil)
+
+		if _, err := NewService(deps).PlaceOrder(context.Background(), sampleRequest()); err == nil {
+			t.Fatal("expected error when CreateRevenueTaxLine is not wired")
+		}
+	})
+}
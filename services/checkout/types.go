@@ -2,12 +2,18 @@ package checkout
 
 import (
 	"context"
+	"time"
+
+	shared "github.com/erniealice/centymo-golang/domain/shared"
 
 	inventoryItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_item"
 	serialpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/inventory_serial"
 	serialHistorypb "github.com/erniealice/esqyma/pkg/schema/v1/domain/inventory/serial_history"
+	pricelistpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_list"
+	priceproductpb "github.com/erniealice/esqyma/pkg/schema/v1/domain/product/price_product"
 	revenuepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue"
 	lineItempb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_line_item"
+	revenuetaxlinepb "github.com/erniealice/esqyma/pkg/schema/v1/domain/revenue/revenue_tax_line"
 	paymentpb "github.com/erniealice/esqyma/pkg/schema/v1/integration/payment"
 )
 
@@ -23,19 +29,93 @@ type CheckoutDeps struct {
 	// Revenue Line Items
 	CreateLineItem func(ctx context.Context, req *lineItempb.CreateRevenueLineItemRequest) (*lineItempb.CreateRevenueLineItemResponse, error)
 	ListLineItems  func(ctx context.Context, req *lineItempb.ListRevenueLineItemsRequest) (*lineItempb.ListRevenueLineItemsResponse, error)
+	// Optional — used to roll back line items when PlaceOrder fails. When nil,
+	// line items stay attached to the cancelled revenue.
+	DeleteLineItem func(ctx context.Context, req *lineItempb.DeleteRevenueLineItemRequest) (*lineItempb.DeleteRevenueLineItemResponse, error)
+
+	// Pricing (server-side price verification)
+	FindApplicablePriceList func(ctx context.Context, req *pricelistpb.FindApplicablePriceListRequest) (*pricelistpb.FindApplicablePriceListResponse, error)
+	ListPriceProducts       func(ctx context.Context, req *priceproductpb.ListPriceProductsRequest) (*priceproductpb.ListPriceProductsResponse, error)
+	// ResolveShippingFee (optional) prices shipping server-side; a client
+	// ShippingFee that differs is a price mismatch. When nil, the client's
+	// fee is charged as long as it is not negative.
+	ResolveShippingFee ShippingFeeResolver
 
 	// Inventory (for stock reservation)
 	UpdateInventoryItem func(ctx context.Context, req *inventoryItempb.UpdateInventoryItemRequest) (*inventoryItempb.UpdateInventoryItemResponse, error)
 	ListInventoryItems  func(ctx context.Context, req *inventoryItempb.ListInventoryItemsRequest) (*inventoryItempb.ListInventoryItemsResponse, error)
+	// AdjustInventoryQuantity reserves stock with an atomic delta so parallel
+	// orders cannot oversell. When nil and ReadInventoryItem is wired, a
+	// per-item serialized read+update is used instead (single process only);
+	// with neither, reservations are written from the listed snapshot.
+	AdjustInventoryQuantity shared.AdjustInventoryQuantity
+	ReadInventoryItem       func(ctx context.Context, req *inventoryItempb.ReadInventoryItemRequest) (*inventoryItempb.ReadInventoryItemResponse, error)
 
 	// Inventory Serial (for serial assignment)
 	ListSerials         func(ctx context.Context, req *serialpb.ListInventorySerialsRequest) (*serialpb.ListInventorySerialsResponse, error)
 	UpdateSerial        func(ctx context.Context, req *serialpb.UpdateInventorySerialRequest) (*serialpb.UpdateInventorySerialResponse, error)
 	CreateSerialHistory func(ctx context.Context, req *serialHistorypb.CreateInventorySerialHistoryRequest) (*serialHistorypb.CreateInventorySerialHistoryResponse, error)
+	// Optional — finds the serials an order reserved so they can be released
+	// when the payment expires. When nil, released orders keep their serials.
+	ListSerialHistory func(ctx context.Context, req *serialHistorypb.ListInventorySerialHistoryRequest) (*serialHistorypb.ListInventorySerialHistoryResponse, error)
 
-	// Payment (Maya integration)
+	// Payments selects the provider for each order and webhook by name. When
+	// nil, the single-provider closures below are used for every name.
+	Payments *PaymentRegistry
+
+	// Payment (single provider, e.g. Maya) — superseded by Payments.
 	CreateCheckoutSession func(ctx context.Context, req *paymentpb.CreateCheckoutSessionRequest) (*paymentpb.CreateCheckoutSessionResponse, error)
 	ProcessWebhook        func(ctx context.Context, req *paymentpb.ProcessWebhookRequest) (*paymentpb.ProcessWebhookResponse, error)
+	// Optional — voids an open checkout session when a later step fails.
+	VoidPayment func(ctx context.Context, req *paymentpb.VoidPaymentRequest) (*paymentpb.VoidPaymentResponse, error)
+
+	// Idempotency (optional) — deduplicates PlaceOrder retries and webhook
+	// redeliveries. When nil, every call is processed.
+	Idempotency IdempotencyStore
+
+	// ReservationTTL is how long a pending order holds its stock before
+	// ReleaseExpiredReservations gives it back. Also sent to the payment
+	// provider as the checkout session lifetime. Zero uses DefaultReservationTTL.
+	ReservationTTL time.Duration
+
+	// Allocation picks the location(s) each item's stock is reserved at.
+	// Nil uses StrictAllocation (the item's own location only).
+	Allocation AllocationStrategy
+
+	// Promotions resolves CheckoutRequest.PromotionCodes and tracks
+	// redemptions. When nil, orders carrying codes are rejected.
+	Promotions PromotionStore
+
+	// FulfillmentEvents (optional) records who moved each order through
+	// fulfillment and when. When nil, only the current state is kept on the
+	// revenue.
+	FulfillmentEvents FulfillmentEventStore
+
+	// Tax (optional) — when ResolveTaxRates is set, PlaceOrder computes tax
+	// per item and location and writes RevenueTaxLine rows through
+	// CreateRevenueTaxLine (required alongside it; esqyma has no create RPC
+	// for tax lines yet, so the consumer wires its repository directly).
+	// TaxInclusivePricing says price list amounts already contain surcharge
+	// taxes (workspace.tax_inclusive_pricing).
+	ResolveTaxRates      TaxResolver
+	CreateRevenueTaxLine func(ctx context.Context, line *revenuetaxlinepb.RevenueTaxLine) (*revenuetaxlinepb.RevenueTaxLine, error)
+	TaxInclusivePricing  bool
+
+	// StatusMachine (optional) validates webhook status changes and runs the
+	// workspace's revenue status hooks. Share the revenue module's machine so
+	// both paths follow the same rules. Nil uses shared.NewRevenueStatusMachine.
+	StatusMachine *shared.RevenueStatusMachine
+
+	// Numbering (optional) issues order references from the workspace's
+	// "order" sequence (or the order location's series). A number whose
+	// order is rolled back is voided. When nil or no sequence is configured,
+	// references fall back to a random ORD-XXXX-XXXX.
+	Numbering *shared.DocumentNumbering
+
+	// TrustClientPrices skips server-side re-pricing and writes the client's
+	// unit prices and totals unchanged. Only for trusted callers (POS,
+	// back-office imports) — never for a public storefront.
+	TrustClientPrices bool
 }
 
 // CheckoutItem represents a single item in the checkout request.
@@ -50,10 +130,17 @@ type CheckoutItem struct {
 	TotalPrice   int // centavos
 	CostPrice    float64
 	PriceListID  string
+
+	// inventoryItemID is the inventory record chosen by the allocation plan.
+	// Empty for items that are not stock-tracked or were never allocated.
+	inventoryItemID string
 }
 
 // CheckoutRequest holds all data needed for PlaceOrder.
 type CheckoutRequest struct {
+	// IdempotencyKey identifies one checkout attempt across client retries.
+	// A replay with a key that already completed returns the original result.
+	IdempotencyKey string
 	// Customer
 	ClientID      string
 	CustomerEmail string
@@ -61,12 +148,16 @@ type CheckoutRequest struct {
 	CustomerPhone string
 	// Order
 	Items           []CheckoutItem
-	TotalAmount     int    // centavos
+	ShippingFee     int    // centavos, checked against ResolveShippingFee; written as a "shipping" line item when set
+	TotalAmount     int    // centavos, items + ShippingFee before discounts and exclusive tax
 	Currency        string // "PHP"
 	FulfillmentType string // "store_pickup" or "home_delivery"
 	LocationID      string // pickup branch
 	DeliveryAddress string
-	PaymentProvider string // "maya"
+	PaymentProvider string // name registered in CheckoutDeps.Payments, e.g. "maya", "cod"
+	// PromotionCodes are applied in order; any code that cannot be applied
+	// fails the whole order with a *PromotionError.
+	PromotionCodes []string
 	// Payment redirect URLs
 	SuccessURL string
 	FailureURL string
@@ -77,10 +168,15 @@ type CheckoutRequest struct {
 type CheckoutResult struct {
 	RevenueID       string
 	ReferenceNumber string
-	CheckoutURL     string // Maya checkout redirect URL (empty if no payment provider)
-	CheckoutID      string // Maya session ID
-	TotalAmount     int    // centavos
+	CheckoutURL     string // provider checkout redirect URL (empty for offline providers)
+	CheckoutID      string // provider session ID
+	TotalAmount     int    // centavos, invoice total after discounts and tax
+	AmountDue       int    // centavos charged to the customer: TotalAmount less withholding
 	Status          string // "pending"
+	ExpiresAt       int64  // unix millis; stock is released if unpaid by then
+	Allocation      *AllocationPlan
+	Discounts       []AppliedDiscount
+	Tax             *TaxBreakdown // nil when tax is not computed
 }
 
 // WebhookResult holds the result of HandlePaymentWebhook.
@@ -89,6 +185,11 @@ type WebhookResult struct {
 	Status    string // "paid", "cancelled"
 	PaymentID string
 	Action    string
+	Ignored   bool // duplicate or out-of-order event; revenue left unchanged
+	// Unreserved is set when a payment lands on an order whose reservation
+	// was already released and its stock could not be reserved again. The
+	// order is still paid and carries a note for staff.
+	Unreserved bool
 }
 
 // OrderData holds full order data for GetOrder.
@@ -0,0 +1,87 @@
+package invoicepdf
+
+import "strings"
+
+// Glyph widths (1/1000 em) of the standard Helvetica faces for ASCII 32-126,
+// from the Adobe Core 14 AFM files. Other runes use defaultWidth.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+const defaultWidth = 556
+
+// textWidth returns the width of s in points when set in font at size.
+func textWidth(font string, size float64, s string) float64 {
+	widths := &helveticaWidths
+	if font == fontBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += defaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrap splits s into lines no wider than width, breaking at spaces. Words
+// wider than a whole line are cut at the character that overflows.
+func wrap(font string, size, width float64, s string) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		current := ""
+		for _, word := range strings.Fields(para) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if textWidth(font, size, candidate) <= width {
+				current = candidate
+				continue
+			}
+			if current != "" {
+				lines = append(lines, current)
+			}
+			for textWidth(font, size, word) > width {
+				cut := fit(font, size, width, word)
+				lines = append(lines, word[:cut])
+				word = word[cut:]
+			}
+			current = word
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// fit returns the byte length of the longest prefix of s (at least one rune)
+// that fits in width.
+func fit(font string, size, width float64, s string) int {
+	end := 0
+	for i, r := range s {
+		next := i + len(string(r))
+		if end > 0 && textWidth(font, size, s[:next]) > width {
+			break
+		}
+		end = next
+	}
+	return end
+}
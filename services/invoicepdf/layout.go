@@ -0,0 +1,152 @@
+// Package invoicepdf renders revenue documents (invoices, credit and debit
+// notes, customer statements) straight to PDF without LibreOffice. It reads the same data map the
+// DOCX templates use and lays it out from a Layout definition: header with an
+// optional logo, a line-item table that breaks across pages, a tax summary and
+// a footer.
+package invoicepdf
+
+// Page sizes in points.
+const (
+	A4Width      = 595.28
+	A4Height     = 841.89
+	LetterWidth  = 612
+	LetterHeight = 792
+)
+
+// Align is a table column's text alignment.
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignRight
+)
+
+// Field is a label/value line in the header block. Value is a text with
+// {{dotted.path}} placeholders resolved against the data map; fields whose
+// value resolves to "" are skipped.
+type Field struct {
+	Label string
+	Value string
+}
+
+// Column is one line-item table column. Key is the item map key; Width is the
+// column's share of the table width (shares are normalised, so any scale
+// works).
+type Column struct {
+	Header string
+	Key    string
+	Width  float64
+	Align  Align
+}
+
+// Layout defines how a document is drawn. Texts may contain {{dotted.path}}
+// placeholders; Footer also accepts {{page}} and {{pages}}. Zero values fall
+// back to the defaults noted on each field.
+type Layout struct {
+	PageWidth  float64 // default A4Width
+	PageHeight float64 // default A4Height
+	Margin     float64 // default 40
+	FontSize   float64 // body size; default 10
+
+	// Header
+	Title     string // large bold heading, e.g. "INVOICE"
+	Logo      []byte // optional JPEG or PNG, drawn top right
+	LogoWidth float64
+	Fields    []Field
+
+	// Line-item table, read from data[ItemsKey] (default "items") as a list
+	// of maps. The header row repeats on every page.
+	ItemsKey string
+	Columns  []Column
+
+	// Tax summary, read from data[TaxesKey] (default "taxes") as a list of
+	// maps with "label" and "amount", then a bold TotalLabel/Total row.
+	TaxesKey   string
+	TotalLabel string
+	Total      string
+
+	// Notes is printed under the totals (skipped when empty).
+	Notes string
+
+	// Footer is printed at the bottom of every page.
+	Footer string
+}
+
+// DefaultLayout returns the built-in layout for a document titled title
+// ("Invoice", "Credit Note", ...), matching the embedded DOCX templates.
+func DefaultLayout(title string) *Layout {
+	return &Layout{
+		Title: title,
+		Fields: []Field{
+			{Label: "Reference No.", Value: "{{invoice.reference_number}}"},
+			{Label: "Date", Value: "{{invoice.date}}"},
+			{Label: "Bill To", Value: "{{customer.name}}"},
+			{Label: "Original Invoice", Value: "{{note.original_reference}}"},
+			{Label: "Currency", Value: "{{currency}}"},
+		},
+		Columns: []Column{
+			{Header: "Description", Key: "description", Width: 50},
+			{Header: "Qty", Key: "quantity", Width: 10, Align: AlignRight},
+			{Header: "Unit Price", Key: "unit_price", Width: 20, Align: AlignRight},
+			{Header: "Amount", Key: "total", Width: 20, Align: AlignRight},
+		},
+		TotalLabel: "Total ({{currency}})",
+		Total:      "{{total}}",
+		Notes:      "{{invoice.notes}}",
+		Footer:     "{{invoice.reference_number}} - Page {{page}} of {{pages}}",
+	}
+}
+
+// StatementLayout returns the built-in layout for customer statements,
+// matching the embedded statement DOCX template: the ledger from
+// data["entries"] and the aging summary from data["aging"] above the balance
+// due.
+func StatementLayout() *Layout {
+	return &Layout{
+		Title: "Statement of Account",
+		Fields: []Field{
+			{Label: "Customer", Value: "{{customer.name}}"},
+			{Label: "Statement Date", Value: "{{statement.date}}"},
+			{Label: "Period", Value: "{{statement.period}}"},
+			{Label: "Currency", Value: "{{currency}}"},
+		},
+		ItemsKey: "entries",
+		Columns: []Column{
+			{Header: "Date", Key: "date", Width: 14},
+			{Header: "Details", Key: "description", Width: 38},
+			{Header: "Charges", Key: "charge", Width: 16, Align: AlignRight},
+			{Header: "Credits", Key: "credit", Width: 16, Align: AlignRight},
+			{Header: "Balance", Key: "balance", Width: 16, Align: AlignRight},
+		},
+		TaxesKey:   "aging",
+		TotalLabel: "Balance Due ({{currency}})",
+		Total:      "{{total}}",
+		Footer:     "{{customer.name}} - Page {{page}} of {{pages}}",
+	}
+}
+
+// withDefaults returns a copy of l with zero values filled in.
+func (l Layout) withDefaults() Layout {
+	if l.PageWidth <= 0 {
+		l.PageWidth = A4Width
+	}
+	if l.PageHeight <= 0 {
+		l.PageHeight = A4Height
+	}
+	if l.Margin <= 0 {
+		l.Margin = 40
+	}
+	if l.FontSize <= 0 {
+		l.FontSize = 10
+	}
+	if l.LogoWidth <= 0 {
+		l.LogoWidth = 120
+	}
+	if l.ItemsKey == "" {
+		l.ItemsKey = "items"
+	}
+	if l.TaxesKey == "" {
+		l.TaxesKey = "taxes"
+	}
+	return l
+}
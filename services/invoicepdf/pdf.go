@@ -0,0 +1,226 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // logo decoding
+	_ "image/png"
+	"io"
+	"strings"
+)
+
+// writer is a minimal streaming PDF 1.4 writer: the two standard Helvetica
+// faces, image XObjects and uncompressed content streams (so the output stays
+// byte-for-byte deterministic and text can be extracted without inflating).
+// Pages are written as they are finished; only object offsets are kept.
+type writer struct {
+	w       io.Writer
+	n       int
+	err     error
+	offsets []int // by object number - 1; -1 while reserved
+	pages   []int // page object numbers
+
+	images   map[[sha256.Size]byte]*pdfImage
+	xobjects []string // "/Im1 5 0 R" entries for page resources
+}
+
+// pdfImage is an embedded image XObject.
+type pdfImage struct {
+	name          string
+	width, height int
+}
+
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+
+	catalogObj = 1
+	pagesObj   = 2
+)
+
+func newWriter(w io.Writer) *writer {
+	pw := &writer{w: w, images: map[[sha256.Size]byte]*pdfImage{}}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.reserve() // catalog, written by close
+	pw.reserve() // page tree, written by close
+	pw.object(pw.reserve(), "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	pw.object(pw.reserve(), "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	return pw
+}
+
+func (pw *writer) printf(format string, args ...any) {
+	if pw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(pw.w, format, args...)
+	pw.n += n
+	pw.err = err
+}
+
+func (pw *writer) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.n += n
+	pw.err = err
+}
+
+// reserve allocates the next object number.
+func (pw *writer) reserve() int {
+	pw.offsets = append(pw.offsets, -1)
+	return len(pw.offsets)
+}
+
+// object writes object num with an optional stream.
+func (pw *writer) object(num int, body string, stream []byte) {
+	pw.offsets[num-1] = pw.n
+	pw.printf("%d 0 obj\n%s\n", num, body)
+	if stream != nil {
+		pw.printf("stream\n")
+		pw.write(stream)
+		pw.printf("\nendstream\n")
+	}
+	pw.printf("endobj\n")
+}
+
+// image embeds a JPEG or PNG once per distinct content and returns it. JPEGs
+// are embedded as-is; other formats are re-encoded as Flate-compressed RGB
+// (alpha is composited onto white).
+func (pw *writer) image(data []byte) (*pdfImage, error) {
+	key := sha256.Sum256(data)
+	if img, ok := pw.images[key]; ok {
+		return img, nil
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invoicepdf: decode logo: %w", err)
+	}
+	colorSpace, filter, stream := "/DeviceRGB", "/DCTDecode", data
+	if format == "jpeg" {
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			colorSpace = "/DeviceCMYK"
+		}
+	} else {
+		filter = "/FlateDecode"
+		if stream, err = flateRGB(data); err != nil {
+			return nil, err
+		}
+	}
+
+	num := pw.reserve()
+	img := &pdfImage{name: fmt.Sprintf("Im%d", len(pw.images)+1), width: cfg.Width, height: cfg.Height}
+	pw.object(num, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter %s /Length %d >>",
+		cfg.Width, cfg.Height, colorSpace, filter, len(stream)), stream)
+	pw.images[key] = img
+	pw.xobjects = append(pw.xobjects, fmt.Sprintf("/%s %d 0 R", img.name, num))
+	return img, pw.err
+}
+
+// flateRGB decodes an image and returns its pixels as zlib-compressed RGB.
+func flateRGB(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invoicepdf: decode logo: %w", err)
+	}
+	b := img.Bounds()
+	raw := make([]byte, 0, b.Dx()*b.Dy()*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			// Premultiplied colour over white, all in 16-bit.
+			bg := 0xffff - a
+			raw = append(raw, byte((r+bg)>>8), byte((g+bg)>>8), byte((bl+bg)>>8))
+		}
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, fmt.Errorf("invoicepdf: compress logo: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("invoicepdf: compress logo: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// page writes one finished page and its content stream.
+func (pw *writer) page(width, height float64, content []byte) {
+	resources := "/Font << /F1 3 0 R /F2 4 0 R >>"
+	if len(pw.xobjects) > 0 {
+		resources += " /XObject << " + strings.Join(pw.xobjects, " ") + " >>"
+	}
+	pageNum, contentNum := pw.reserve(), pw.reserve()
+	pw.object(pageNum, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+		pagesObj, num(width), num(height), resources, contentNum), nil)
+	pw.object(contentNum, fmt.Sprintf("<< /Length %d >>", len(content)), content)
+	pw.pages = append(pw.pages, pageNum)
+}
+
+// close writes the page tree, catalog and cross-reference table.
+func (pw *writer) close() error {
+	kids := make([]string, len(pw.pages))
+	for i, p := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", p)
+	}
+	pw.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pw.pages)), nil)
+	pw.object(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj), nil)
+
+	xref := pw.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		pw.printf("%010d 00000 n \n", off)
+	}
+	pw.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, catalogObj, xref)
+	return pw.err
+}
+
+// text draws s with its baseline starting at x, y.
+func text(page *bytes.Buffer, font string, size, x, y float64, s string) {
+	fmt.Fprintf(page, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(y), escape(s))
+}
+
+// line strokes a line from x1, y1 to x2, y2.
+func line(page *bytes.Buffer, x1, y1, x2, y2 float64) {
+	fmt.Fprintf(page, "%s %s m %s %s l S\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// drawImage places img with its lower-left corner at x, y.
+func drawImage(page *bytes.Buffer, img *pdfImage, x, y, w, h float64) {
+	fmt.Fprintf(page, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(y), img.name)
+}
+
+// num formats a coordinate with at most two decimals and no trailing zeros.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// escape encodes s as a WinAnsi PDF literal string. Latin-1 runes map
+// directly; anything else becomes '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,329 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	cellPadding = 4
+	labelWidth  = 110 // header field label column
+)
+
+// Render lays data out with layout and returns the PDF bytes. data is the map
+// the DOCX templates receive (invoice.*, customer.*, note.*, items, taxes,
+// total, currency). A nil layout uses DefaultLayout("Invoice"). Output is
+// deterministic: the same layout and data give the same bytes.
+func Render(layout *Layout, data map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	m := NewMerger(&buf)
+	if err := m.Add(layout, data); err != nil {
+		return nil, err
+	}
+	if err := m.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Merger streams several documents into a single PDF. Each Add lays one
+// document out (with its own {{page}} of {{pages}} numbering) and writes its
+// pages straight to the underlying writer, so memory stays bounded by the
+// largest document rather than the whole batch. Identical logos are embedded
+// once. Close must be called to finish the file.
+type Merger struct {
+	w *writer
+}
+
+// NewMerger starts a PDF on w.
+func NewMerger(w io.Writer) *Merger {
+	return &Merger{w: newWriter(w)}
+}
+
+// Add renders one document, as Render would, and appends its pages.
+func (m *Merger) Add(layout *Layout, data map[string]any) error {
+	if layout == nil {
+		layout = DefaultLayout("Invoice")
+	}
+	l := layout.withDefaults()
+	r := &renderer{l: l, data: data}
+	if len(l.Logo) > 0 {
+		img, err := m.w.image(l.Logo)
+		if err != nil {
+			return err
+		}
+		r.image = img
+	}
+
+	r.newPage()
+	r.header()
+	r.table()
+	r.totals()
+	r.notes()
+	r.footers()
+	for _, page := range r.pages {
+		m.w.page(l.PageWidth, l.PageHeight, page.Bytes())
+	}
+	return m.w.err
+}
+
+// Pages reports how many pages have been written so far.
+func (m *Merger) Pages() int { return len(m.w.pages) }
+
+// Close writes the page tree and trailer. It does not close the underlying
+// writer.
+func (m *Merger) Close() error {
+	return m.w.close()
+}
+
+// renderer lays out one document. It tracks the page being drawn and the
+// cursor, which is the top of the next row in points from the page bottom.
+type renderer struct {
+	l     Layout
+	data  map[string]any
+	image *pdfImage
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+func (r *renderer) lineHeight() float64 { return r.l.FontSize * 1.4 }
+
+func (r *renderer) top() float64 { return r.l.PageHeight - r.l.Margin }
+
+// bottom is the lowest a row may reach; the footer sits below it.
+func (r *renderer) bottom() float64 { return r.l.Margin + r.l.FontSize*2 }
+
+func (r *renderer) contentWidth() float64 { return r.l.PageWidth - 2*r.l.Margin }
+
+func (r *renderer) newPage() {
+	r.page = &bytes.Buffer{}
+	r.pages = append(r.pages, r.page)
+	r.y = r.top()
+}
+
+// ensure starts a new page when height no longer fits on this one and
+// reports whether it did.
+func (r *renderer) ensure(height float64) bool {
+	if r.y-height >= r.bottom() {
+		return false
+	}
+	r.newPage()
+	return true
+}
+
+// row draws one line of text at the cursor without advancing it.
+func (r *renderer) row(font string, x float64, s string) {
+	text(r.page, font, r.l.FontSize, x, r.y-r.l.FontSize, s)
+}
+
+func (r *renderer) header() {
+	logoHeight := 0.0
+	if img := r.image; img != nil {
+		logoHeight = r.l.LogoWidth * float64(img.height) / float64(img.width)
+		drawImage(r.page, img, r.l.PageWidth-r.l.Margin-r.l.LogoWidth, r.top()-logoHeight, r.l.LogoWidth, logoHeight)
+	}
+
+	if title := r.expand(r.l.Title, nil); title != "" {
+		size := r.l.FontSize * 2
+		text(r.page, fontBold, size, r.l.Margin, r.y-size, title)
+		r.y -= size * 1.6
+	}
+	for _, f := range r.l.Fields {
+		value := r.expand(f.Value, nil)
+		if value == "" {
+			continue
+		}
+		r.row(fontBold, r.l.Margin, f.Label)
+		r.row(fontRegular, r.l.Margin+labelWidth, value)
+		r.y -= r.lineHeight()
+	}
+
+	if below := r.top() - logoHeight; below < r.y {
+		r.y = below
+	}
+	r.y -= r.lineHeight()
+}
+
+// columnLayout returns each column's left edge and width.
+func (r *renderer) columnLayout() (xs, widths []float64) {
+	total := 0.0
+	for _, c := range r.l.Columns {
+		total += c.Width
+	}
+	x := r.l.Margin
+	for _, c := range r.l.Columns {
+		w := r.contentWidth() / float64(len(r.l.Columns))
+		if total > 0 {
+			w = r.contentWidth() * c.Width / total
+		}
+		xs = append(xs, x)
+		widths = append(widths, w)
+		x += w
+	}
+	return xs, widths
+}
+
+// cell draws s in a column, aligned within its padding.
+func (r *renderer) cell(font string, c Column, x, w float64, s string) {
+	if c.Align == AlignRight {
+		x += w - cellPadding - textWidth(font, r.l.FontSize, s)
+	} else {
+		x += cellPadding
+	}
+	r.row(font, x, s)
+}
+
+func (r *renderer) tableHeader(xs, widths []float64) {
+	for i, c := range r.l.Columns {
+		r.cell(fontBold, c, xs[i], widths[i], c.Header)
+	}
+	r.y -= r.lineHeight()
+	line(r.page, r.l.Margin, r.y+2, r.l.PageWidth-r.l.Margin, r.y+2)
+	r.y -= 4
+}
+
+func (r *renderer) table() {
+	if len(r.l.Columns) == 0 {
+		return
+	}
+	xs, widths := r.columnLayout()
+	lh := r.lineHeight()
+
+	r.ensure(lh * 3)
+	r.tableHeader(xs, widths)
+	for _, item := range list(r.data[r.l.ItemsKey]) {
+		cells := make([][]string, len(r.l.Columns))
+		lines := 1
+		for i, c := range r.l.Columns {
+			cells[i] = wrap(fontRegular, r.l.FontSize, widths[i]-2*cellPadding, stringify(item[c.Key]))
+			if len(cells[i]) > lines {
+				lines = len(cells[i])
+			}
+		}
+		if r.ensure(float64(lines) * lh) {
+			r.tableHeader(xs, widths)
+		}
+		for n := 0; n < lines; n++ {
+			for i, c := range r.l.Columns {
+				if n < len(cells[i]) {
+					r.cell(fontRegular, c, xs[i], widths[i], cells[i][n])
+				}
+			}
+			r.y -= lh
+		}
+	}
+	line(r.page, r.l.Margin, r.y+2, r.l.PageWidth-r.l.Margin, r.y+2)
+	r.y -= lh / 2
+}
+
+func (r *renderer) totals() {
+	type totalRow struct {
+		font          string
+		label, amount string
+	}
+	var rows []totalRow
+	for _, tax := range list(r.data[r.l.TaxesKey]) {
+		rows = append(rows, totalRow{fontRegular, stringify(tax["label"]), stringify(tax["amount"])})
+	}
+	if total := r.expand(r.l.Total, nil); total != "" {
+		rows = append(rows, totalRow{fontBold, r.expand(r.l.TotalLabel, nil), total})
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	lh := r.lineHeight()
+	r.ensure(float64(len(rows)) * lh)
+	labelX := r.l.Margin + r.contentWidth()*0.55
+	right := r.l.PageWidth - r.l.Margin - cellPadding
+	for _, row := range rows {
+		r.row(row.font, labelX, row.label)
+		r.row(row.font, right-textWidth(row.font, r.l.FontSize, row.amount), row.amount)
+		r.y -= lh
+	}
+	r.y -= lh / 2
+}
+
+func (r *renderer) notes() {
+	notes := r.expand(r.l.Notes, nil)
+	if strings.TrimSpace(notes) == "" {
+		return
+	}
+	for _, s := range wrap(fontRegular, r.l.FontSize, r.contentWidth(), notes) {
+		r.ensure(r.lineHeight())
+		r.row(fontRegular, r.l.Margin, s)
+		r.y -= r.lineHeight()
+	}
+}
+
+// footers draws the footer on every page once the page count is known.
+func (r *renderer) footers() {
+	if r.l.Footer == "" {
+		return
+	}
+	pages := strconv.Itoa(len(r.pages))
+	for i, page := range r.pages {
+		footer := r.expand(r.l.Footer, map[string]string{"page": strconv.Itoa(i + 1), "pages": pages})
+		x := (r.l.PageWidth - textWidth(fontRegular, r.l.FontSize, footer)) / 2
+		text(page, fontRegular, r.l.FontSize, x, r.l.Margin, footer)
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// expand resolves {{dotted.path}} placeholders in s against the data map,
+// with extra consulted first. Unknown paths resolve to "".
+func (r *renderer) expand(s string, extra map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholder.FindStringSubmatch(m)[1]
+		if v, ok := extra[key]; ok {
+			return v
+		}
+		return lookup(r.data, key)
+	})
+}
+
+// lookup walks a dotted path through nested maps.
+func lookup(data map[string]any, path string) string {
+	var v any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[part]
+	}
+	return stringify(v)
+}
+
+// list converts a data value to a list of maps, skipping other entries.
+func list(v any) []map[string]any {
+	var out []map[string]any
+	switch items := v.(type) {
+	case []map[string]any:
+		return items
+	case []any:
+		for _, item := range items {
+			if m, ok := item.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+	}
+	return out
+}
+
+func stringify(v any) string {
+	switch v := v.(type) {
+	case nil, map[string]any, []any:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
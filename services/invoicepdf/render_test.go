@@ -0,0 +1,273 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+func invoiceData(items int) map[string]any {
+	lines := make([]any, 0, items)
+	for i := 1; i <= items; i++ {
+		lines = append(lines, map[string]any{
+			"description": fmt.Sprintf("Item %d", i),
+			"quantity":    "2",
+			"unit_price":  "150.00",
+			"total":       "300.00",
+		})
+	}
+	return map[string]any{
+		"invoice": map[string]any{
+			"reference_number": "INV-0042",
+			"date":             "2026-03-01",
+			"notes":            "Thank you for your business.",
+		},
+		"customer": map[string]any{"name": "Peña Trading (Cebu)"},
+		"note":     map[string]any{"original_reference": ""},
+		"items":    lines,
+		"taxes": []any{
+			map[string]any{"label": "VAT (12.00%)", "amount": "72.00"},
+		},
+		"total":    "672.00",
+		"currency": "PHP",
+	}
+}
+
+func statementData() map[string]any {
+	return map[string]any{
+		"statement": map[string]any{"date": "2026-03-31", "period": "2026-03-01 to 2026-03-31"},
+		"customer":  map[string]any{"name": "Peña Trading (Cebu)"},
+		"entries": []any{
+			map[string]any{"date": "2026-03-01", "description": "Balance brought forward", "balance": "1,000.00"},
+			map[string]any{"date": "2026-03-05", "description": "Invoice INV-0042", "charge": "672.00", "balance": "1,672.00"},
+			map[string]any{"date": "2026-03-20", "description": "Payment OR-7 - INV-0041", "credit": "1,000.00", "balance": "672.00"},
+		},
+		"aging": []any{
+			map[string]any{"label": "Current", "amount": "672.00"},
+			map[string]any{"label": "1-30 days", "amount": "0.00"},
+		},
+		"total":    "672.00",
+		"currency": "PHP",
+	}
+}
+
+func TestRender_Golden(t *testing.T) {
+	t.Parallel()
+
+	long := invoiceData(2)
+	long["items"] = append(long["items"].([]any), map[string]any{
+		"description": "Annual support and maintenance covering on-site visits, remote troubleshooting and quarterly health checks",
+		"quantity":    "1",
+		"unit_price":  "72.00",
+		"total":       "72.00",
+	})
+
+	note := invoiceData(1)
+	note["note"] = map[string]any{"original_reference": "INV-0041"}
+	note["taxes"] = nil
+
+	cases := []struct {
+		name   string
+		layout *Layout
+		data   map[string]any
+	}{
+		{"invoice", DefaultLayout("Invoice"), invoiceData(3)},
+		{"wrapped", DefaultLayout("Invoice"), long},
+		{"page-breaks", DefaultLayout("Invoice"), invoiceData(70)},
+		{"credit-note", DefaultLayout("Credit Note"), note},
+		{"statement", StatementLayout(), statementData()},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			pdf, err := Render(c.layout, c.data)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			got := extractText(t, pdf)
+
+			golden := filepath.Join("testdata", c.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden (run with -update to create): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("text mismatch for %s\n--- got ---\n%s\n--- want ---\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestRender_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	a, err := Render(nil, invoiceData(5))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	b, _ := Render(nil, invoiceData(5))
+	if !bytes.Equal(a, b) {
+		t.Error("same input rendered different bytes")
+	}
+	if !bytes.HasPrefix(a, []byte("%PDF-1.4")) || !bytes.HasSuffix(a, []byte("%%EOF\n")) {
+		t.Error("output is not framed as a PDF")
+	}
+}
+
+func TestRender_Logo(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, img); err != nil {
+		t.Fatal(err)
+	}
+
+	layout := DefaultLayout("Invoice")
+	layout.Logo = logo.Bytes()
+	pdf, err := Render(layout, invoiceData(1))
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{"/Subtype /Image /Width 4 /Height 2", "/XObject << /Im1 5 0 R >>", "/Im1 Do"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF missing %q", want)
+		}
+	}
+
+	layout.Logo = []byte("not an image")
+	if _, err := Render(layout, invoiceData(1)); err == nil {
+		t.Error("expected an error for an undecodable logo")
+	}
+}
+
+var (
+	streamRe = regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`)
+	showRe   = regexp.MustCompile(`\(((?:[^()\\]|\\.)*)\) Tj`)
+)
+
+// extractText returns the text shown on each page, one Tj per line, the way a
+// PDF text extractor would read it.
+func extractText(t *testing.T, pdf []byte) string {
+	t.Helper()
+	var b strings.Builder
+	page := 0
+	for _, stream := range streamRe.FindAllSubmatch(pdf, -1) {
+		shows := showRe.FindAllSubmatch(stream[1], -1)
+		if len(shows) == 0 {
+			continue // image data
+		}
+		page++
+		fmt.Fprintf(&b, "=== page %d ===\n", page)
+		for _, s := range shows {
+			b.WriteString(unescape(t, string(s[1])))
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// unescape decodes a PDF literal string written by escape.
+func unescape(t *testing.T, s string) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i+3 <= len(s) && s[i] >= '0' && s[i] <= '7' {
+			code, err := strconv.ParseUint(s[i:i+3], 8, 8)
+			if err != nil {
+				t.Fatalf("bad escape in %q: %v", s, err)
+			}
+			b.WriteRune(rune(code))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func TestMerger(t *testing.T) {
+	t.Parallel()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, img); err != nil {
+		t.Fatal(err)
+	}
+	layout := DefaultLayout("Invoice")
+	layout.Logo = logo.Bytes()
+
+	var buf bytes.Buffer
+	m := NewMerger(&buf)
+	for _, items := range []int{1, 70, 3} {
+		if err := m.Add(layout, invoiceData(items)); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if m.Pages() != 4 {
+		t.Errorf("Pages() = %d, want 4", m.Pages())
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	pdf := buf.Bytes()
+
+	if n := bytes.Count(pdf, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("logo embedded %d times, want once", n)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 4 >>")) {
+		t.Error("page tree does not list 4 pages")
+	}
+	// Each document keeps its own page numbering.
+	text := extractText(t, pdf)
+	for _, want := range []string{"INV-0042 - Page 1 of 1", "INV-0042 - Page 2 of 2"} {
+		if strings.Count(text, want+"\n") == 0 {
+			t.Errorf("merged text missing footer %q", want)
+		}
+	}
+	if n := strings.Count(text, "INV-0042 - Page 1 of 1\n"); n != 2 {
+		t.Errorf("single-page footers = %d, want 2", n)
+	}
+
+	// The merged output equals rendering each document on its own.
+	var want strings.Builder
+	for _, items := range []int{1, 70, 3} {
+		single, err := Render(layout, invoiceData(items))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.WriteString(extractText(t, single))
+	}
+	if got := renumber(text); got != renumber(want.String()) {
+		t.Errorf("merged text differs from individual renders\n--- got ---\n%s\n--- want ---\n%s", got, want.String())
+	}
+}
+
+var pageMarkerRe = regexp.MustCompile(`(?m)^=== page \d+ ===\n`)
+
+// renumber drops extractText's page markers so outputs with different page
+// offsets compare equal.
+func renumber(s string) string { return pageMarkerRe.ReplaceAllString(s, "") }
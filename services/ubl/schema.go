@@ -0,0 +1,171 @@
+package ubl
+
+// The bundled schema: the UBL 2.1 content models of the elements a PEPPOL
+// BIS Billing 3.0 document from this package may contain, transcribed from
+// the OASIS maindoc and common XSDs. Each model lists its children in XSD
+// sequence order; elements the XSD allows but the profile does not use are
+// left out, so they are reported like unknown elements.
+
+// Leaf content kinds, checked by checkLeaf.
+const (
+	kindText     = "text"
+	kindID       = "id"
+	kindCode     = "code"
+	kindDate     = "date"
+	kindAmount   = "amount"   // decimal with currencyID
+	kindQuantity = "quantity" // decimal with unitCode
+	kindPercent  = "percent"  // decimal
+)
+
+// particle is one child of a content model. typ is a leaf kind or the name of
+// another content model; max 0 means unbounded.
+type particle struct {
+	ns       string
+	name     string
+	min, max int
+	typ      string
+}
+
+func cbc(name string, min, max int, kind string) particle {
+	return particle{ns: NamespaceCBC, name: name, min: min, max: max, typ: kind}
+}
+
+func cac(name string, min, max int, model string) particle {
+	return particle{ns: NamespaceCAC, name: name, min: min, max: max, typ: model}
+}
+
+// documentModel is the Invoice or, when credit, the CreditNote model: they
+// differ in the type code, the line element and the due date, which a UBL 2.1
+// credit note does not have.
+func documentModel(credit bool) []particle {
+	m := []particle{
+		cbc("CustomizationID", 0, 1, kindID),
+		cbc("ProfileID", 0, 1, kindID),
+		cbc("ID", 1, 1, kindID),
+		cbc("IssueDate", 1, 1, kindDate),
+	}
+	if credit {
+		m = append(m, cbc("CreditNoteTypeCode", 0, 1, kindCode))
+	} else {
+		m = append(m, cbc("DueDate", 0, 1, kindDate), cbc("InvoiceTypeCode", 0, 1, kindCode))
+	}
+	m = append(m,
+		cbc("Note", 0, 0, kindText),
+		cbc("DocumentCurrencyCode", 0, 1, kindCode),
+		cbc("TaxCurrencyCode", 0, 1, kindCode),
+		cbc("AccountingCost", 0, 1, kindText),
+		cbc("BuyerReference", 0, 1, kindText),
+		cac("BillingReference", 0, 0, "BillingReference"),
+		cac("AccountingSupplierParty", 1, 1, "SupplierParty"),
+		cac("AccountingCustomerParty", 1, 1, "CustomerParty"),
+		cac("PaymentTerms", 0, 0, "PaymentTerms"),
+		cac("TaxTotal", 0, 0, "TaxTotal"),
+		cac("LegalMonetaryTotal", 1, 1, "MonetaryTotal"),
+	)
+	if credit {
+		return append(m, cac("CreditNoteLine", 1, 0, "CreditNoteLine"))
+	}
+	return append(m, cac("InvoiceLine", 1, 0, "InvoiceLine"))
+}
+
+func lineModel(qty string) []particle {
+	return []particle{
+		cbc("ID", 1, 1, kindID),
+		cbc("Note", 0, 0, kindText),
+		cbc(qty, 0, 1, kindQuantity),
+		cbc("LineExtensionAmount", 0, 1, kindAmount),
+		cbc("AccountingCost", 0, 1, kindText),
+		cac("Item", 1, 1, "Item"),
+		cac("Price", 0, 1, "Price"),
+	}
+}
+
+var contentModels = map[string][]particle{
+	"Invoice":    documentModel(false),
+	"CreditNote": documentModel(true),
+	"BillingReference": {
+		cac("InvoiceDocumentReference", 0, 1, "DocumentReference"),
+	},
+	"DocumentReference": {
+		cbc("ID", 1, 1, kindID),
+		cbc("IssueDate", 0, 1, kindDate),
+	},
+	"SupplierParty": {cac("Party", 0, 1, "Party")},
+	"CustomerParty": {cac("Party", 0, 1, "Party")},
+	"Party": {
+		cbc("EndpointID", 0, 1, kindID),
+		cac("PartyIdentification", 0, 0, "PartyIdentification"),
+		cac("PartyName", 0, 0, "PartyName"),
+		cac("PostalAddress", 0, 1, "Address"),
+		cac("PartyTaxScheme", 0, 0, "PartyTaxScheme"),
+		cac("PartyLegalEntity", 0, 0, "PartyLegalEntity"),
+		cac("Contact", 0, 1, "Contact"),
+	},
+	"PartyIdentification": {cbc("ID", 1, 1, kindID)},
+	"PartyName":           {cbc("Name", 1, 1, kindText)},
+	"Address": {
+		cbc("StreetName", 0, 1, kindText),
+		cbc("AdditionalStreetName", 0, 1, kindText),
+		cbc("CityName", 0, 1, kindText),
+		cbc("PostalZone", 0, 1, kindText),
+		cbc("CountrySubentity", 0, 1, kindText),
+		cac("Country", 0, 1, "Country"),
+	},
+	"Country": {cbc("IdentificationCode", 0, 1, kindCode)},
+	"PartyTaxScheme": {
+		cbc("RegistrationName", 0, 1, kindText),
+		cbc("CompanyID", 0, 1, kindID),
+		cac("TaxScheme", 1, 1, "TaxScheme"),
+	},
+	"TaxScheme": {cbc("ID", 0, 1, kindID)},
+	"PartyLegalEntity": {
+		cbc("RegistrationName", 0, 1, kindText),
+		cbc("CompanyID", 0, 1, kindID),
+		cbc("CompanyLegalForm", 0, 1, kindText),
+	},
+	"Contact": {
+		cbc("Name", 0, 1, kindText),
+		cbc("Telephone", 0, 1, kindText),
+		cbc("ElectronicMail", 0, 1, kindText),
+	},
+	"PaymentTerms": {cbc("Note", 0, 0, kindText)},
+	"TaxTotal": {
+		cbc("TaxAmount", 1, 1, kindAmount),
+		cac("TaxSubtotal", 0, 0, "TaxSubtotal"),
+	},
+	"TaxSubtotal": {
+		cbc("TaxableAmount", 0, 1, kindAmount),
+		cbc("TaxAmount", 1, 1, kindAmount),
+		cac("TaxCategory", 1, 1, "TaxCategory"),
+	},
+	"TaxCategory": {
+		cbc("ID", 0, 1, kindID),
+		cbc("Percent", 0, 1, kindPercent),
+		cbc("TaxExemptionReasonCode", 0, 1, kindCode),
+		cbc("TaxExemptionReason", 0, 0, kindText),
+		cac("TaxScheme", 1, 1, "TaxScheme"),
+	},
+	"MonetaryTotal": {
+		cbc("LineExtensionAmount", 0, 1, kindAmount),
+		cbc("TaxExclusiveAmount", 0, 1, kindAmount),
+		cbc("TaxInclusiveAmount", 0, 1, kindAmount),
+		cbc("AllowanceTotalAmount", 0, 1, kindAmount),
+		cbc("ChargeTotalAmount", 0, 1, kindAmount),
+		cbc("PrepaidAmount", 0, 1, kindAmount),
+		cbc("PayableRoundingAmount", 0, 1, kindAmount),
+		cbc("PayableAmount", 1, 1, kindAmount),
+	},
+	"InvoiceLine":    lineModel("InvoicedQuantity"),
+	"CreditNoteLine": lineModel("CreditedQuantity"),
+	"Item": {
+		cbc("Description", 0, 0, kindText),
+		cbc("Name", 0, 1, kindText),
+		cac("SellersItemIdentification", 0, 1, "ItemIdentification"),
+		cac("ClassifiedTaxCategory", 0, 0, "TaxCategory"),
+	},
+	"ItemIdentification": {cbc("ID", 1, 1, kindID)},
+	"Price": {
+		cbc("PriceAmount", 1, 1, kindAmount),
+		cbc("BaseQuantity", 0, 1, kindQuantity),
+	},
+}
@@ -0,0 +1,431 @@
+// Package ubl writes revenue documents as UBL 2.1 Invoice and CreditNote XML
+// following the PEPPOL BIS Billing 3.0 profile (EN 16931), and validates XML
+// against the bundled UBL content models and business rules before it is
+// served. It has no dependencies beyond the standard library.
+package ubl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Namespaces and profile identifiers of a PEPPOL BIS Billing 3.0 document.
+const (
+	NamespaceInvoice    = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	NamespaceCreditNote = "urn:oasis:names:specification:ubl:schema:xsd:CreditNote-2"
+	NamespaceCAC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	NamespaceCBC        = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+
+	CustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	ProfileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+)
+
+// Document type codes (UNCL 1001). A credit note is written as a UBL
+// CreditNote; invoices and debit notes as a UBL Invoice.
+const (
+	TypeInvoice    = "380"
+	TypeCreditNote = "381"
+	TypeDebitNote  = "383"
+)
+
+// VAT category codes (UNCL 5305) used by the PEPPOL profile.
+const (
+	CategoryStandard      = "S"
+	CategoryZeroRated     = "Z"
+	CategoryExempt        = "E"
+	CategoryNotSubjectTax = "O"
+)
+
+// UnitOne is the default unit of measure (UN/ECE Rec 20 "one").
+const UnitOne = "C62"
+
+// Document is a revenue document to write. Amounts are in centavos of
+// Currency; Marshal derives the tax breakdown and the document totals from
+// the lines and Taxes.
+type Document struct {
+	TypeCode  string // TypeInvoice, TypeCreditNote or TypeDebitNote
+	ID        string
+	IssueDate time.Time
+	DueDate   time.Time // zero = none; not written on credit notes
+	Currency  string
+	Note      string
+	// BuyerReference is the buyer's own reference; PEPPOL requires it or an
+	// order reference, so Marshal falls back to ID.
+	BuyerReference string
+	// BillingReference is the invoice a credit or debit note corrects.
+	BillingReference string
+	PaymentTerms     string
+
+	Seller Party
+	Buyer  Party
+	Lines  []Line
+	// Taxes are the document's VAT amounts per category and rate. Lines whose
+	// category and rate have no entry carry no tax.
+	Taxes []Tax
+}
+
+// Party is the seller or the buyer. EndpointID is the party's electronic
+// address (PEPPOL participant ID); when it is empty Email is used with
+// scheme "EM". TaxID is the VAT/TIN registration, written as the party's tax
+// scheme unless every line is CategoryNotSubjectTax.
+type Party struct {
+	Name           string
+	EndpointID     string
+	EndpointScheme string // EAS code, e.g. "0088"
+	TaxID          string
+	RegistrationID string
+	Street         string
+	City           string
+	PostalCode     string
+	Region         string
+	CountryCode    string // ISO 3166-1 alpha-2
+	Email          string
+}
+
+// Line is one invoiced line. Net is the line amount without VAT; Price is the
+// net unit price as a decimal string (blank = Net ÷ Quantity).
+type Line struct {
+	ID       string
+	Name     string
+	Quantity float64
+	UnitCode string // blank = UnitOne
+	Price    string
+	Net      int64
+	Category string // blank = CategoryStandard
+	// PercentBP is the VAT rate in basis points (1200 = 12%).
+	PercentBP int32
+}
+
+// Tax is the VAT of one category and rate. ExemptionReason is required for
+// exempt and not-subject categories.
+type Tax struct {
+	Category        string
+	PercentBP       int32
+	Amount          int64
+	ExemptionReason string
+}
+
+// Totals are a document's monetary totals in centavos.
+type Totals struct {
+	LineExtension int64
+	TaxExclusive  int64
+	Tax           int64
+	TaxInclusive  int64
+	Payable       int64
+}
+
+// Subtotal is the tax breakdown of one category and rate.
+type Subtotal struct {
+	Tax
+	Taxable int64
+}
+
+// Breakdown groups the lines by category and rate with their tax, in
+// category then rate order, and the document totals.
+func (d *Document) Breakdown() ([]Subtotal, Totals) {
+	type key struct {
+		cat string
+		bp  int32
+	}
+	groups := map[key]*Subtotal{}
+	var order []key
+	add := func(k key) *Subtotal {
+		if s, ok := groups[k]; ok {
+			return s
+		}
+		s := &Subtotal{Tax: Tax{Category: k.cat, PercentBP: k.bp}}
+		groups[k] = s
+		order = append(order, k)
+		return s
+	}
+	var totals Totals
+	for _, l := range d.Lines {
+		add(key{category(l.Category), l.PercentBP}).Taxable += l.Net
+		totals.LineExtension += l.Net
+	}
+	for _, t := range d.Taxes {
+		s := add(key{category(t.Category), t.PercentBP})
+		s.Amount += t.Amount
+		if s.ExemptionReason == "" {
+			s.ExemptionReason = t.ExemptionReason
+		}
+		totals.Tax += t.Amount
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].cat != order[j].cat {
+			return order[i].cat < order[j].cat
+		}
+		return order[i].bp < order[j].bp
+	})
+	out := make([]Subtotal, 0, len(order))
+	for _, k := range order {
+		out = append(out, *groups[k])
+	}
+	totals.TaxExclusive = totals.LineExtension
+	totals.TaxInclusive = totals.TaxExclusive + totals.Tax
+	totals.Payable = totals.TaxInclusive
+	return out, totals
+}
+
+// IsCreditNote reports whether d is written as a UBL CreditNote.
+func (d *Document) IsCreditNote() bool {
+	return d.TypeCode == TypeCreditNote
+}
+
+// Marshal writes d as UBL 2.1 XML. It does not validate; see Validate.
+func Marshal(d *Document) ([]byte, error) {
+	root, lineName, qtyName := "Invoice", "cac:InvoiceLine", "cbc:InvoicedQuantity"
+	ns, typeName := NamespaceInvoice, "cbc:InvoiceTypeCode"
+	if d.IsCreditNote() {
+		root, lineName, qtyName = "CreditNote", "cac:CreditNoteLine", "cbc:CreditedQuantity"
+		ns, typeName = NamespaceCreditNote, "cbc:CreditNoteTypeCode"
+	}
+	subtotals, totals := d.Breakdown()
+	taxed := false
+	for _, s := range subtotals {
+		if s.Category != CategoryNotSubjectTax {
+			taxed = true
+		}
+	}
+
+	doc := &node{name: root, attrs: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: ns},
+		{Name: xml.Name{Local: "xmlns:cac"}, Value: NamespaceCAC},
+		{Name: xml.Name{Local: "xmlns:cbc"}, Value: NamespaceCBC},
+	}}
+	doc.text("cbc:CustomizationID", CustomizationID)
+	doc.text("cbc:ProfileID", ProfileID)
+	doc.text("cbc:ID", d.ID)
+	doc.text("cbc:IssueDate", date(d.IssueDate))
+	if !d.IsCreditNote() && !d.DueDate.IsZero() {
+		doc.text("cbc:DueDate", date(d.DueDate))
+	}
+	doc.text(typeName, d.TypeCode)
+	if d.Note != "" {
+		doc.text("cbc:Note", d.Note)
+	}
+	doc.text("cbc:DocumentCurrencyCode", d.Currency)
+	buyerRef := d.BuyerReference
+	if buyerRef == "" {
+		buyerRef = d.ID
+	}
+	doc.text("cbc:BuyerReference", buyerRef)
+	if d.BillingReference != "" {
+		doc.add("cac:BillingReference").add("cac:InvoiceDocumentReference").text("cbc:ID", d.BillingReference)
+	}
+	party(doc.add("cac:AccountingSupplierParty"), d.Seller, taxed)
+	party(doc.add("cac:AccountingCustomerParty"), d.Buyer, taxed)
+	if d.PaymentTerms != "" {
+		doc.add("cac:PaymentTerms").text("cbc:Note", d.PaymentTerms)
+	}
+
+	taxTotal := doc.add("cac:TaxTotal")
+	taxTotal.amount("cbc:TaxAmount", totals.Tax, d.Currency)
+	for _, s := range subtotals {
+		sub := taxTotal.add("cac:TaxSubtotal")
+		sub.amount("cbc:TaxableAmount", s.Taxable, d.Currency)
+		sub.amount("cbc:TaxAmount", s.Amount, d.Currency)
+		cat := sub.add("cac:TaxCategory")
+		cat.text("cbc:ID", s.Category)
+		if s.Category != CategoryNotSubjectTax {
+			cat.text("cbc:Percent", percent(s.PercentBP))
+		}
+		if s.ExemptionReason != "" && (s.Category == CategoryExempt || s.Category == CategoryNotSubjectTax) {
+			cat.text("cbc:TaxExemptionReason", s.ExemptionReason)
+		}
+		cat.add("cac:TaxScheme").text("cbc:ID", "VAT")
+	}
+
+	mt := doc.add("cac:LegalMonetaryTotal")
+	mt.amount("cbc:LineExtensionAmount", totals.LineExtension, d.Currency)
+	mt.amount("cbc:TaxExclusiveAmount", totals.TaxExclusive, d.Currency)
+	mt.amount("cbc:TaxInclusiveAmount", totals.TaxInclusive, d.Currency)
+	mt.amount("cbc:PayableAmount", totals.Payable, d.Currency)
+
+	for i, l := range d.Lines {
+		line := doc.add(lineName)
+		id := l.ID
+		if id == "" {
+			id = strconv.Itoa(i + 1)
+		}
+		line.text("cbc:ID", id)
+		unit := l.UnitCode
+		if unit == "" {
+			unit = UnitOne
+		}
+		qty := line.text(qtyName, decimal(l.Quantity))
+		qty.attrs = []xml.Attr{{Name: xml.Name{Local: "unitCode"}, Value: unit}}
+		line.amount("cbc:LineExtensionAmount", l.Net, d.Currency)
+		item := line.add("cac:Item")
+		item.text("cbc:Name", l.Name)
+		tc := item.add("cac:ClassifiedTaxCategory")
+		tc.text("cbc:ID", category(l.Category))
+		if category(l.Category) != CategoryNotSubjectTax {
+			tc.text("cbc:Percent", percent(l.PercentBP))
+		}
+		tc.add("cac:TaxScheme").text("cbc:ID", "VAT")
+		price := l.Price
+		if price == "" {
+			price = unitPrice(l.Net, l.Quantity)
+		}
+		line.add("cac:Price").amountText("cbc:PriceAmount", price, d.Currency)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := doc.encode(enc); err != nil {
+		return nil, fmt.Errorf("ubl: %w", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, fmt.Errorf("ubl: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// party writes p under parent (AccountingSupplierParty or
+// AccountingCustomerParty). The tax scheme is left out when taxed is false.
+func party(parent *node, p Party, taxed bool) {
+	n := parent.add("cac:Party")
+	endpoint, scheme := p.EndpointID, p.EndpointScheme
+	if endpoint == "" && p.Email != "" {
+		endpoint, scheme = p.Email, "EM"
+	}
+	if endpoint != "" {
+		e := n.text("cbc:EndpointID", endpoint)
+		e.attrs = []xml.Attr{{Name: xml.Name{Local: "schemeID"}, Value: scheme}}
+	}
+	if p.Name != "" {
+		n.add("cac:PartyName").text("cbc:Name", p.Name)
+	}
+	addr := n.add("cac:PostalAddress")
+	if p.Street != "" {
+		addr.text("cbc:StreetName", p.Street)
+	}
+	if p.City != "" {
+		addr.text("cbc:CityName", p.City)
+	}
+	if p.PostalCode != "" {
+		addr.text("cbc:PostalZone", p.PostalCode)
+	}
+	if p.Region != "" {
+		addr.text("cbc:CountrySubentity", p.Region)
+	}
+	addr.add("cac:Country").text("cbc:IdentificationCode", p.CountryCode)
+	if taxed && p.TaxID != "" {
+		ts := n.add("cac:PartyTaxScheme")
+		ts.text("cbc:CompanyID", p.TaxID)
+		ts.add("cac:TaxScheme").text("cbc:ID", "VAT")
+	}
+	le := n.add("cac:PartyLegalEntity")
+	le.text("cbc:RegistrationName", p.Name)
+	if p.RegistrationID != "" {
+		le.text("cbc:CompanyID", p.RegistrationID)
+	} else if !taxed && p.TaxID != "" {
+		le.text("cbc:CompanyID", p.TaxID)
+	}
+	if p.Email != "" {
+		n.add("cac:Contact").text("cbc:ElectronicMail", p.Email)
+	}
+}
+
+func category(c string) string {
+	if c == "" {
+		return CategoryStandard
+	}
+	return c
+}
+
+func date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// Amount formats centavos as a UBL amount, e.g. 123456 → "1234.56".
+func Amount(centavos int64) string {
+	sign := ""
+	if centavos < 0 {
+		sign, centavos = "-", -centavos
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, centavos/100, centavos%100)
+}
+
+// percent formats basis points as a percentage, e.g. 1200 → "12".
+func percent(bp int32) string {
+	return strconv.FormatFloat(float64(bp)/100, 'f', -1, 64)
+}
+
+// decimal formats a quantity without trailing zeros.
+func decimal(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// unitPrice is net ÷ qty to at most four decimals and at least two.
+func unitPrice(net int64, qty float64) string {
+	if qty == 0 {
+		return Amount(net)
+	}
+	s := strconv.FormatFloat(float64(net)/qty/100, 'f', 4, 64)
+	for strings.HasSuffix(s, "0") && len(s)-strings.IndexByte(s, '.') > 3 {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// node is an element of the document being written. Names carry their
+// cac:/cbc: prefix; the root declares the prefixes.
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	value    string
+	children []*node
+}
+
+func (n *node) add(name string) *node {
+	c := &node{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *node) text(name, value string) *node {
+	c := n.add(name)
+	c.value = value
+	return c
+}
+
+func (n *node) amount(name string, centavos int64, currency string) *node {
+	return n.amountText(name, Amount(centavos), currency)
+}
+
+func (n *node) amountText(name, value, currency string) *node {
+	c := n.text(name, value)
+	c.attrs = []xml.Attr{{Name: xml.Name{Local: "currencyID"}, Value: currency}}
+	return c
+}
+
+func (n *node) encode(enc *xml.Encoder) error {
+	start := xml.StartElement{Name: xml.Name{Local: n.name}, Attr: n.attrs}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if n.value != "" {
+		if err := enc.EncodeToken(xml.CharData(n.value)); err != nil {
+			return err
+		}
+	}
+	for _, c := range n.children {
+		if err := c.encode(enc); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,145 @@
+package ubl
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDocument() *Document {
+	return &Document{
+		TypeCode:  TypeInvoice,
+		ID:        "INV-000123",
+		IssueDate: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Currency:  "PHP",
+		Seller: Party{
+			Name: "Acme Services Inc.", EndpointID: "0088:1234567890123", EndpointScheme: "0088",
+			TaxID: "123-456-789-000", CountryCode: "PH", City: "Makati",
+		},
+		Buyer: Party{
+			Name: "Globex Corp", Email: "ap@globex.example", TaxID: "987-654-321-000",
+			Street: "1 Ayala Ave", City: "Makati", PostalCode: "1226", CountryCode: "PH",
+		},
+		Lines: []Line{
+			{Name: "Consulting & advice", Quantity: 3, Price: "1000.00", Net: 300000, PercentBP: 1200},
+			{Name: "Training (exempt)", Quantity: 1, Net: 50000, Category: CategoryExempt},
+		},
+		Taxes: []Tax{
+			{Category: CategoryStandard, PercentBP: 1200, Amount: 36000},
+			{Category: CategoryExempt, ExemptionReason: "Exempt educational services"},
+		},
+	}
+}
+
+func TestMarshalValidates(t *testing.T) {
+	t.Parallel()
+
+	data, err := Marshal(testDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("invoice: %v\n%s", err, data)
+	}
+	for _, want := range []string{
+		`<Invoice xmlns="` + NamespaceInvoice + `"`,
+		`<cbc:InvoiceTypeCode>380</cbc:InvoiceTypeCode>`,
+		`<cbc:EndpointID schemeID="EM">ap@globex.example</cbc:EndpointID>`,
+		`<cbc:Name>Consulting &amp; advice</cbc:Name>`,
+		`<cbc:TaxInclusiveAmount currencyID="PHP">3860.00</cbc:TaxInclusiveAmount>`,
+		`<cbc:InvoicedQuantity unitCode="C62">3</cbc:InvoicedQuantity>`,
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	credit := testDocument()
+	credit.TypeCode = TypeCreditNote
+	credit.ID = "CN-000001"
+	credit.BillingReference = "INV-000123"
+	data, err = Marshal(credit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(data); err != nil {
+		t.Fatalf("credit note: %v\n%s", err, data)
+	}
+	if !bytes.Contains(data, []byte("<cbc:CreditedQuantity")) || bytes.Contains(data, []byte("<cbc:DueDate")) {
+		t.Errorf("credit note lines or due date:\n%s", data)
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	t.Parallel()
+
+	subtotals, totals := testDocument().Breakdown()
+	if len(subtotals) != 2 || subtotals[0].Category != CategoryExempt || subtotals[1].Taxable != 300000 || subtotals[1].Amount != 36000 {
+		t.Fatalf("subtotals = %+v", subtotals)
+	}
+	if totals != (Totals{LineExtension: 350000, TaxExclusive: 350000, Tax: 36000, TaxInclusive: 386000, Payable: 386000}) {
+		t.Errorf("totals = %+v", totals)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		modify func(*Document)
+		edit   func(string) string
+		rule   string
+	}{
+		{"no seller endpoint", func(d *Document) { d.Seller.EndpointID = "" }, nil, "PEPPOL-EN16931-R020"},
+		{"no buyer country", func(d *Document) { d.Buyer.CountryCode = "" }, nil, "BR-11"},
+		{"no seller name", func(d *Document) { d.Seller.Name = "" }, nil, "BR-06"},
+		{"wrong VAT amount", func(d *Document) { d.Taxes[0].Amount = 30000 }, nil, "BR-CO-17"},
+		{"exempt without reason", func(d *Document) { d.Taxes[1].ExemptionReason = "" }, nil, "BR-E-10"},
+		{"standard without seller VAT", func(d *Document) { d.Seller.TaxID = "" }, nil, "BR-S-02"},
+		{"no lines", func(d *Document) { d.Lines = nil }, nil, RuleSchema},
+		{"line total tampered", nil, func(s string) string {
+			return strings.Replace(s, `<cbc:LineExtensionAmount currencyID="PHP">3500.00`, `<cbc:LineExtensionAmount currencyID="PHP">3400.00`, 1)
+		}, "BR-CO-10"},
+		{"three decimals", nil, func(s string) string {
+			return strings.Replace(s, `3860.00</cbc:TaxInclusiveAmount>`, `3860.001</cbc:TaxInclusiveAmount>`, 1)
+		}, "BR-DEC"},
+		{"out of order", nil, func(s string) string {
+			return strings.Replace(s, "<cbc:ProfileID>", "<cbc:Note>x</cbc:Note><cbc:ProfileID>", 1)
+		}, RuleSchema},
+		{"bad date", nil, func(s string) string {
+			return strings.Replace(s, "<cbc:IssueDate>2026-03-01", "<cbc:IssueDate>01/03/2026", 1)
+		}, RuleSchema},
+	}
+	for _, tt := range tests {
+		d := testDocument()
+		if tt.modify != nil {
+			tt.modify(d)
+		}
+		data, err := Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.edit != nil {
+			data = []byte(tt.edit(string(data)))
+		}
+		err = Validate(data)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: err = %v, want a ValidationError", tt.name, err)
+			continue
+		}
+		found := false
+		for _, v := range verr.Violations {
+			if v.Rule == tt.rule {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: violations %v, want rule %s", tt.name, verr.Violations, tt.rule)
+		}
+	}
+}
@@ -0,0 +1,467 @@
+package ubl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuleSchema marks violations of the bundled UBL content models; business
+// rule violations carry their EN 16931 (BR-*) or PEPPOL rule ID.
+const RuleSchema = "UBL-XSD"
+
+// Violation is one failed schema or business rule.
+type Violation struct {
+	Rule    string
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s: %s", v.Rule, v.Path, v.Message)
+}
+
+// ValidationError lists every violation Validate found.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.String())
+	}
+	return "ubl: document is not valid: " + strings.Join(parts, "; ")
+}
+
+// Validate checks a UBL Invoice or CreditNote against the bundled content
+// models (see schema.go) and the EN 16931 and PEPPOL BIS 3.0 business rules
+// that apply to documents without allowances, charges or prepayments. It
+// returns a *ValidationError listing every violation, or nil.
+func Validate(data []byte) error {
+	root, err := parse(data)
+	if err != nil {
+		return &ValidationError{Violations: []Violation{{Rule: RuleSchema, Path: "/", Message: err.Error()}}}
+	}
+	v := &validator{}
+	switch {
+	case root.space == NamespaceInvoice && root.local == "Invoice",
+		root.space == NamespaceCreditNote && root.local == "CreditNote":
+		v.checkModel(root, root.local, "/"+root.local)
+	default:
+		v.add(RuleSchema, "/"+root.local, "root element must be a UBL 2.1 Invoice or CreditNote")
+		return &ValidationError{Violations: v.violations}
+	}
+	if len(v.violations) == 0 {
+		// Business rules assume the structure; skip them on schema errors.
+		v.checkRules(root)
+	}
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+	return nil
+}
+
+// element is a parsed XML element.
+type element struct {
+	space, local string
+	attrs        map[string]string
+	text         string
+	children     []*element
+}
+
+// parse reads data into an element tree.
+func parse(data []byte) (*element, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*element
+	var root *element
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{space: t.Name.Space, local: t.Name.Local, attrs: map[string]string{}}
+			for _, a := range t.Attr {
+				if a.Name.Space == "" {
+					e.attrs[a.Name.Local] = a.Value
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// child returns the first child named local, or nil.
+func (e *element) child(local string) *element {
+	if e == nil {
+		return nil
+	}
+	for _, c := range e.children {
+		if c.local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns the children named local.
+func (e *element) all(local string) []*element {
+	if e == nil {
+		return nil
+	}
+	var out []*element
+	for _, c := range e.children {
+		if c.local == local {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// find follows a path of child names from e.
+func (e *element) find(path ...string) *element {
+	for _, p := range path {
+		e = e.child(p)
+	}
+	return e
+}
+
+// value returns the trimmed text at path, or "".
+func (e *element) value(path ...string) string {
+	if f := e.find(path...); f != nil {
+		return strings.TrimSpace(f.text)
+	}
+	return ""
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(rule, path, msg string) {
+	v.violations = append(v.violations, Violation{Rule: rule, Path: path, Message: msg})
+}
+
+func (v *validator) check(ok bool, rule, path, msg string) {
+	if !ok {
+		v.add(rule, path, msg)
+	}
+}
+
+// checkModel matches e's children against the content model in XSD sequence
+// order and recurses into them.
+func (v *validator) checkModel(e *element, model, path string) {
+	particles := contentModels[model]
+	counts := make([]int, len(particles))
+	i := 0
+	for _, c := range e.children {
+		j := i
+		for j < len(particles) && (particles[j].ns != c.space || particles[j].name != c.local) {
+			j++
+		}
+		if j == len(particles) {
+			v.add(RuleSchema, path+"/"+c.local, "element is not allowed here")
+			continue
+		}
+		for ; i < j; i++ {
+			if counts[i] < particles[i].min {
+				v.add(RuleSchema, path+"/"+particles[i].name, "required element is missing")
+			}
+		}
+		p := particles[j]
+		counts[j]++
+		if p.max > 0 && counts[j] > p.max {
+			v.add(RuleSchema, path+"/"+c.local, fmt.Sprintf("element occurs more than %d time(s)", p.max))
+		}
+		childPath := path + "/" + c.local
+		if _, ok := contentModels[p.typ]; ok {
+			if strings.TrimSpace(c.text) != "" {
+				v.add(RuleSchema, childPath, "aggregate element cannot contain text")
+			}
+			v.checkModel(c, p.typ, childPath)
+		} else {
+			v.checkLeaf(c, p.typ, childPath)
+		}
+	}
+	for ; i < len(particles); i++ {
+		if counts[i] < particles[i].min {
+			v.add(RuleSchema, path+"/"+particles[i].name, "required element is missing")
+		}
+	}
+}
+
+var (
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	decimalPattern  = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	countryPattern  = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// checkLeaf checks a basic component's lexical value and attributes.
+func (v *validator) checkLeaf(e *element, kind, path string) {
+	if len(e.children) > 0 {
+		v.add(RuleSchema, path, "basic component cannot contain elements")
+		return
+	}
+	s := strings.TrimSpace(e.text)
+	switch kind {
+	case kindDate:
+		if _, err := time.Parse("2006-01-02", s); err != nil || !datePattern.MatchString(s) {
+			v.add(RuleSchema, path, fmt.Sprintf("%q is not a date (YYYY-MM-DD)", s))
+		}
+	case kindAmount, kindQuantity, kindPercent:
+		if !decimalPattern.MatchString(s) {
+			v.add(RuleSchema, path, fmt.Sprintf("%q is not a decimal", s))
+		}
+		if kind == kindAmount && e.attrs["currencyID"] == "" {
+			v.add(RuleSchema, path, "currencyID attribute is required")
+		}
+	}
+}
+
+// parseAmount reads a decimal amount into centavos; ok is false when it has
+// more than two decimals (BR-DEC).
+func parseAmount(s string) (centavos int64, ok bool) {
+	s = strings.TrimSpace(s)
+	if _, frac, _ := strings.Cut(s, "."); len(frac) > 2 {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(math.Round(f * 100)), true
+}
+
+func parseDecimal(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
+
+// amount reads the amount at path into centavos, recording BR-DEC
+// violations; missing amounts read as 0.
+func (v *validator) amount(e *element, path string, names ...string) int64 {
+	s := e.value(names...)
+	if s == "" {
+		return 0
+	}
+	c, ok := parseAmount(s)
+	if !ok {
+		v.add("BR-DEC", path+"/"+strings.Join(names, "/"), "amounts shall have at most 2 decimals")
+	}
+	return c
+}
+
+// checkRules applies the EN 16931 and PEPPOL BIS 3.0 business rules.
+func (v *validator) checkRules(root *element) {
+	credit := root.local == "CreditNote"
+	base := "/" + root.local
+	lineName, qtyName, typeName := "InvoiceLine", "InvoicedQuantity", "InvoiceTypeCode"
+	if credit {
+		lineName, qtyName, typeName = "CreditNoteLine", "CreditedQuantity", "CreditNoteTypeCode"
+	}
+
+	v.check(root.value("CustomizationID") != "", "BR-01", base+"/CustomizationID", "an invoice shall have a specification identifier")
+	v.check(strings.HasPrefix(root.value("CustomizationID"), "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"),
+		"PEPPOL-EN16931-R004", base+"/CustomizationID", "specification identifier must be the PEPPOL BIS Billing 3.0 customization")
+	v.check(root.value("ProfileID") != "", "PEPPOL-EN16931-R001", base+"/ProfileID", "business process must be provided")
+	v.check(root.value("ID") != "", "BR-02", base+"/ID", "an invoice shall have an invoice number")
+	v.check(root.value("IssueDate") != "", "BR-03", base+"/IssueDate", "an invoice shall have an issue date")
+	typeCode := root.value(typeName)
+	v.check(typeCode != "", "BR-04", base+"/"+typeName, "an invoice shall have a type code")
+	if credit {
+		v.check(typeCode == "" || typeCode == TypeCreditNote || typeCode == "396" || typeCode == "532",
+			"BR-CL-01", base+"/"+typeName, "credit note type code must be a credit note code")
+	} else {
+		v.check(typeCode == "" || typeCode == TypeInvoice || typeCode == TypeDebitNote || typeCode == "384" || typeCode == "389",
+			"BR-CL-01", base+"/"+typeName, "invoice type code must be an invoice or debit note code")
+	}
+	currency := root.value("DocumentCurrencyCode")
+	v.check(currency != "", "BR-05", base+"/DocumentCurrencyCode", "an invoice shall have a currency code")
+	v.check(currency == "" || currencyPattern.MatchString(currency), "BR-CL-04", base+"/DocumentCurrencyCode", "currency code must be an ISO 4217 code")
+	v.check(root.value("BuyerReference") != "", "PEPPOL-EN16931-R003", base+"/BuyerReference", "a buyer reference or purchase order reference must be provided")
+
+	seller := root.find("AccountingSupplierParty", "Party")
+	buyer := root.find("AccountingCustomerParty", "Party")
+	sp, bp := base+"/AccountingSupplierParty/Party", base+"/AccountingCustomerParty/Party"
+	v.check(seller.value("PartyLegalEntity", "RegistrationName") != "", "BR-06", sp+"/PartyLegalEntity/RegistrationName", "an invoice shall contain the seller name")
+	v.check(buyer.value("PartyLegalEntity", "RegistrationName") != "", "BR-07", bp+"/PartyLegalEntity/RegistrationName", "an invoice shall contain the buyer name")
+	v.check(seller.child("PostalAddress") != nil, "BR-08", sp+"/PostalAddress", "an invoice shall contain the seller postal address")
+	v.check(countryPattern.MatchString(seller.value("PostalAddress", "Country", "IdentificationCode")), "BR-09", sp+"/PostalAddress/Country", "the seller postal address shall contain a country code (ISO 3166-1 alpha-2)")
+	v.check(buyer.child("PostalAddress") != nil, "BR-10", bp+"/PostalAddress", "an invoice shall contain the buyer postal address")
+	v.check(countryPattern.MatchString(buyer.value("PostalAddress", "Country", "IdentificationCode")), "BR-11", bp+"/PostalAddress/Country", "the buyer postal address shall contain a country code (ISO 3166-1 alpha-2)")
+	for _, p := range []struct {
+		party *element
+		path  string
+		rule  string
+		who   string
+	}{{seller, sp, "PEPPOL-EN16931-R020", "seller"}, {buyer, bp, "PEPPOL-EN16931-R010", "buyer"}} {
+		e := p.party.child("EndpointID")
+		v.check(e != nil && strings.TrimSpace(e.text) != "", p.rule, p.path+"/EndpointID", "the "+p.who+" electronic address must be provided")
+		if e != nil {
+			v.check(e.attrs["schemeID"] != "", "PEPPOL-EN16931-R020", p.path+"/EndpointID", "electronic address must have a scheme identifier")
+		}
+	}
+	sellerVAT := seller.value("PartyTaxScheme", "CompanyID") != ""
+
+	lines := root.all(lineName)
+	v.check(len(lines) > 0, "BR-16", base, "an invoice shall have at least one invoice line")
+	var lineTotal int64
+	type key struct {
+		cat string
+		pct float64
+	}
+	lineNets := map[key]int64{}
+	for i, l := range lines {
+		lp := fmt.Sprintf("%s/%s[%d]", base, lineName, i+1)
+		v.check(l.value("ID") != "", "BR-21", lp+"/ID", "each invoice line shall have an identifier")
+		qty := l.child(qtyName)
+		v.check(qty != nil, "BR-22", lp+"/"+qtyName, "each invoice line shall have an invoiced quantity")
+		v.check(qty == nil || qty.attrs["unitCode"] != "", "BR-23", lp+"/"+qtyName, "an invoiced quantity shall have a unit of measure code")
+		v.check(l.child("LineExtensionAmount") != nil, "BR-24", lp+"/LineExtensionAmount", "each invoice line shall have a line net amount")
+		v.check(l.value("Item", "Name") != "", "BR-25", lp+"/Item/Name", "each invoice line shall contain the item name")
+		price := l.child("Price")
+		v.check(price != nil, "BR-26", lp+"/Price", "each invoice line shall contain the item net price")
+		net := v.amount(l, lp, "LineExtensionAmount")
+		lineTotal += net
+		if price != nil {
+			p := parseDecimal(price.value("PriceAmount"))
+			v.check(p >= 0, "BR-27", lp+"/Price/PriceAmount", "the item net price shall not be negative")
+			if qty != nil {
+				baseQty := 1.0
+				if b := price.value("BaseQuantity"); b != "" {
+					baseQty = parseDecimal(b)
+				}
+				if baseQty != 0 {
+					expected := parseDecimal(qty.text) * p / baseQty
+					v.check(math.Abs(float64(net)/100-expected) <= 0.02, "PEPPOL-EN16931-R120", lp+"/LineExtensionAmount",
+						"invoice line net amount must equal quantity × price ÷ base quantity")
+				}
+			}
+		}
+		cat := l.find("Item", "ClassifiedTaxCategory")
+		v.check(cat != nil && cat.value("ID") != "", "BR-CO-04", lp+"/Item/ClassifiedTaxCategory", "each invoice line shall be categorized with a VAT category code")
+		if cat != nil {
+			id := cat.value("ID")
+			pct := parseDecimal(cat.value("Percent"))
+			v.checkCategoryRate(id, cat.value("Percent"), pct, lp+"/Item/ClassifiedTaxCategory", "05")
+			lineNets[key{id, pct}] += net
+		}
+	}
+
+	mt := root.child("LegalMonetaryTotal")
+	mp := base + "/LegalMonetaryTotal"
+	lineExt := v.amount(mt, mp, "LineExtensionAmount")
+	taxExcl := v.amount(mt, mp, "TaxExclusiveAmount")
+	taxIncl := v.amount(mt, mp, "TaxInclusiveAmount")
+	allowances := v.amount(mt, mp, "AllowanceTotalAmount")
+	charges := v.amount(mt, mp, "ChargeTotalAmount")
+	prepaid := v.amount(mt, mp, "PrepaidAmount")
+	rounding := v.amount(mt, mp, "PayableRoundingAmount")
+	payable := v.amount(mt, mp, "PayableAmount")
+	v.check(mt.child("LineExtensionAmount") != nil, "BR-12", mp+"/LineExtensionAmount", "an invoice shall have the sum of invoice line net amounts")
+	v.check(mt.child("TaxExclusiveAmount") != nil, "BR-13", mp+"/TaxExclusiveAmount", "an invoice shall have the invoice total amount without VAT")
+	v.check(mt.child("TaxInclusiveAmount") != nil, "BR-14", mp+"/TaxInclusiveAmount", "an invoice shall have the invoice total amount with VAT")
+	v.check(lineExt == lineTotal, "BR-CO-10", mp+"/LineExtensionAmount", "sum of invoice line net amounts must equal the sum of the line amounts")
+	v.check(taxExcl == lineExt-allowances+charges, "BR-CO-13", mp+"/TaxExclusiveAmount", "invoice total without VAT must equal line total minus allowances plus charges")
+
+	var taxTotal int64
+	var subtotals []*element
+	for _, tt := range root.all("TaxTotal") {
+		taxTotal += v.amount(tt, base+"/TaxTotal", "TaxAmount")
+		subtotals = append(subtotals, tt.all("TaxSubtotal")...)
+	}
+	v.check(taxIncl == taxExcl+taxTotal, "BR-CO-15", mp+"/TaxInclusiveAmount", "invoice total with VAT must equal the total without VAT plus the VAT total")
+	v.check(payable == taxIncl-prepaid+rounding, "BR-CO-16", mp+"/PayableAmount", "amount due must equal the total with VAT minus paid amounts plus rounding")
+	v.check(len(subtotals) > 0, "BR-CO-18", base+"/TaxTotal", "an invoice shall have at least one VAT breakdown group")
+
+	var subtotalTax int64
+	seen := map[key]bool{}
+	for i, st := range subtotals {
+		sp := fmt.Sprintf("%s/TaxTotal/TaxSubtotal[%d]", base, i+1)
+		taxable := v.amount(st, sp, "TaxableAmount")
+		tax := v.amount(st, sp, "TaxAmount")
+		subtotalTax += tax
+		cat := st.child("TaxCategory")
+		id := cat.value("ID")
+		pctText := cat.value("Percent")
+		pct := parseDecimal(pctText)
+		k := key{id, pct}
+		v.check(!seen[k], "BR-CO-18", sp, "VAT breakdown groups must have distinct category and rate")
+		seen[k] = true
+		v.check(st.child("TaxableAmount") != nil, "BR-45", sp+"/TaxableAmount", "each VAT breakdown shall have a taxable amount")
+		v.checkCategoryRate(id, pctText, pct, sp+"/TaxCategory", "06")
+		if id != CategoryNotSubjectTax {
+			expected := int64(math.Round(float64(taxable) * pct / 100))
+			v.check(abs(tax-expected) <= 1, "BR-CO-17", sp+"/TaxAmount", "VAT amount must equal the taxable amount × VAT rate")
+		}
+		v.check(taxable == lineNets[k], "BR-"+id+"-08", sp+"/TaxableAmount", "taxable amount must equal the sum of the line net amounts of its category and rate")
+		switch id {
+		case CategoryStandard, CategoryZeroRated, CategoryExempt:
+			v.check(sellerVAT, "BR-"+id+"-02", base+"/AccountingSupplierParty/Party/PartyTaxScheme", "documents with "+id+" VAT lines shall contain the seller VAT identifier")
+		case CategoryNotSubjectTax:
+			v.check(!sellerVAT, "BR-O-02", base+"/AccountingSupplierParty/Party/PartyTaxScheme", "documents with not-subject lines shall not contain the seller VAT identifier")
+		}
+		if id == CategoryExempt || id == CategoryNotSubjectTax {
+			v.check(tax == 0, "BR-"+id+"-09", sp+"/TaxAmount", "VAT amount of an exempt or not-subject breakdown shall be 0")
+			v.check(cat.value("TaxExemptionReason") != "" || cat.value("TaxExemptionReasonCode") != "", "BR-"+id+"-10", sp+"/TaxCategory",
+				"an exempt or not-subject breakdown shall have an exemption reason")
+		}
+	}
+	for k := range lineNets {
+		v.check(seen[k], "BR-CO-18", base+"/TaxTotal", fmt.Sprintf("lines of category %s at %g%% have no VAT breakdown", k.cat, k.pct))
+	}
+	v.check(taxTotal == subtotalTax, "BR-CO-14", base+"/TaxTotal/TaxAmount", "invoice total VAT must equal the sum of the VAT breakdown amounts")
+}
+
+// vatCategories are the UNCL 5305 codes allowed by EN 16931.
+var vatCategories = map[string]bool{"S": true, "Z": true, "E": true, "AE": true, "K": true, "G": true, "O": true, "L": true, "M": true}
+
+// checkCategoryRate applies the per-category rate rules (BR-x-05 on lines,
+// BR-x-06 on breakdowns).
+func (v *validator) checkCategoryRate(id, pctText string, pct float64, path, suffix string) {
+	switch id {
+	case CategoryStandard:
+		v.check(pct > 0, "BR-S-"+suffix, path+"/Percent", "standard rated VAT rate shall be greater than zero")
+	case CategoryZeroRated, CategoryExempt:
+		v.check(pctText != "" && pct == 0, "BR-"+id+"-"+suffix, path+"/Percent", "VAT rate shall be 0")
+	case CategoryNotSubjectTax:
+		v.check(pctText == "", "BR-O-"+suffix, path+"/Percent", "not-subject lines shall not have a VAT rate")
+	default:
+		v.check(vatCategories[id], "BR-CL-18", path+"/ID", fmt.Sprintf("%q is not a VAT category code", id))
+	}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
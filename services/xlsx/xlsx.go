@@ -0,0 +1,195 @@
+// Package xlsx writes simple Office Open XML workbooks: one or more sheets of
+// a bold header row followed by text and number cells. It covers report
+// exports without pulling in a spreadsheet library; there are no formulas,
+// merged cells or column widths.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the MIME type of a workbook written by Write.
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// maxSheetName is Excel's sheet name length limit.
+const maxSheetName = 31
+
+// Sheet is one worksheet. Row values may be strings, integers or floats;
+// anything else is written as its fmt %v text.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// Write writes a workbook holding sheets to w.
+func Write(w io.Writer, sheets ...Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("xlsx: no sheets")
+	}
+	zw := zip.NewWriter(w)
+
+	var contentTypes, workbook, workbookRels bytes.Buffer
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+
+	used := map[string]bool{}
+	for i, sheet := range sheets {
+		n := i + 1
+		name := sheetName(sheet.Name, n, used)
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), n, n)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+
+		if err := writePart(zw, fmt.Sprintf("xl/worksheets/sheet%d.xml", n), sheetXML(sheet)); err != nil {
+			return err
+		}
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	parts := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+		{"xl/styles.xml", []byte(stylesXML)},
+	}
+	for _, p := range parts {
+		if err := writePart(zw, p.name, p.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// stylesXML defines two cell formats: 0 is the default, 1 is bold (headers).
+const stylesXML = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="1"><fill><patternFill patternType="none"/></fill></fills>` +
+	`<borders count="1"><border/></borders>` +
+	`<cellStyleXfs count="1"><xf/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf fontId="0"/><xf fontId="1" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func writePart(zw *zip.Writer, name string, body []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("xlsx: %s: %w", name, err)
+	}
+	if _, err := f.Write(body); err != nil {
+		return fmt.Errorf("xlsx: %s: %w", name, err)
+	}
+	return nil
+}
+
+func sheetXML(sheet Sheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	row := 0
+	if len(sheet.Header) > 0 {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, h := range sheet.Header {
+			writeCell(&b, col, row, h, true)
+		}
+		b.WriteString(`</row>`)
+	}
+	for _, values := range sheet.Rows {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, v := range values {
+			writeCell(&b, col, row, v, false)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+func writeCell(b *bytes.Buffer, col, row int, v any, bold bool) {
+	ref := ColumnName(col) + strconv.Itoa(row)
+	style := ""
+	if bold {
+		style = ` s="1"`
+	}
+	var num string
+	switch n := v.(type) {
+	case int:
+		num = strconv.Itoa(n)
+	case int32:
+		num = strconv.FormatInt(int64(n), 10)
+	case int64:
+		num = strconv.FormatInt(n, 10)
+	case float64:
+		num = strconv.FormatFloat(n, 'f', -1, 64)
+	case nil:
+		return
+	}
+	if num != "" {
+		fmt.Fprintf(b, `<c r="%s"%s><v>%s</v></c>`, ref, style, num)
+		return
+	}
+	fmt.Fprintf(b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(fmt.Sprint(v)))
+}
+
+// ColumnName returns the spreadsheet letters for a zero-based column: A, B,
+// ... Z, AA, AB, ...
+func ColumnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName makes name a valid, unique sheet name, defaulting to "Sheet<n>".
+func sheetName(name string, n int, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		name = "Sheet" + strconv.Itoa(n)
+	}
+	if r := []rune(name); len(r) > maxSheetName {
+		name = string(r[:maxSheetName])
+	}
+	for base, i := name, 2; used[strings.ToLower(name)]; i++ {
+		suffix := " (" + strconv.Itoa(i) + ")"
+		r := []rune(base)
+		if len(r)+len(suffix) > maxSheetName {
+			r = r[:maxSheetName-len(suffix)]
+		}
+		name = string(r) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	t.Parallel()
+
+	for col, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := ColumnName(col); got != want {
+			t.Errorf("ColumnName(%d) = %q, want %q", col, got, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := Write(&buf,
+		Sheet{Name: "Aging: summary", Header: []string{"Client", "Balance"}, Rows: [][]any{{"Acme & Co <PH>", int64(122500)}, {"Bolt", 12.5}}},
+		Sheet{Name: "aging- summary", Rows: [][]any{{nil, "x"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+		if err := xml.Unmarshal(body, new(struct{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", f.Name, err)
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+
+	if wb := parts["xl/workbook.xml"]; !strings.Contains(wb, `name="Aging- summary"`) || !strings.Contains(wb, `name="aging- summary (2)"`) {
+		t.Errorf("sheet names not sanitised and deduplicated: %s", wb)
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Client</t></is></c>`,
+		`<t xml:space="preserve">Acme &amp; Co &lt;PH&gt;</t>`,
+		`<c r="B2"><v>122500</v></c>`,
+		`<c r="B3"><v>12.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1 missing %s", want)
+		}
+	}
+	if strings.Contains(parts["xl/worksheets/sheet2.xml"], `r="A1"`) {
+		t.Error("nil cell should be left empty")
+	}
+
+	if err := Write(io.Discard); err == nil {
+		t.Error("Write with no sheets should fail")
+	}
+}